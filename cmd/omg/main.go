@@ -1,34 +1,100 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"sort"
 	"strings"
+	"text/tabwriter"
 	"time"
 
 	omg "github.com/demetere/omg"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq" // PostgreSQL driver
+	openfgaSdk "github.com/openfga/go-sdk"
 )
 
 var (
 	migrationsDir    string
 	dbURL            string
 	migrationDBURL   string
+	trackerDBURL     string
+	trackerKind      string
 	modelPath        string
+	backfill         bool
+	withTests        bool
+	force            bool
+	verifyRollback   bool
+	purge            bool
+	split            bool
+	idempotent       bool
+	migrationPackage string
+	changesPath      string
+	inverseVersion   string
+	pruneType        string
+	pruneRelation    string
+	dryRun           bool
+	assumeYes        bool
+	nonInteractive   bool
+	usersPath        string
+	importDiff       bool
+	reportObject     string
+	noColor          bool
+	ignoreRules      string
+	ignoreChanges    string
+	envProfile       string
+	summaryPath      string
+	envFiles         stringList
+	outputFormat     string
+	storeMode        string
+	modelOnly        bool
+	checkOnly        bool
+	parserName       string
+	schemaVersion    string
+	failOn           string
+	diffFrom         string
+	diffTo           string
+	liveState        bool
+	countTuples      bool
+	strategy         string
+	templatePath     string
+	verifyGenerated  bool
+	seedPath         string
+	seedFile         string
+	configPath       string
+	promoteApply     bool
+	cloneName        string
+	rehearseSample   int
+	keepRehearsal    bool
+	rehearseLocally  bool
+	rehearseURL      string
+	resume           bool
+	verifyRenames    bool
 )
 
-func main() {
-	// Load .env file
-	_ = godotenv.Load()
+// stringList is a flag that may be repeated
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
 
+func main() {
 	// Define flags
 	flag.StringVar(&migrationsDir, "dir", "migrations", "directory with migration files")
 	flag.StringVar(&dbURL, "dburl", "", "OpenFGA database URL (openfga://store_id@host:port?auth=...)")
@@ -44,10 +110,101 @@ func main() {
 	flagSet := flag.NewFlagSet(command, flag.ExitOnError)
 	flagSet.StringVar(&migrationsDir, "dir", "migrations", "directory with migration files")
 	flagSet.StringVar(&dbURL, "dburl", os.Getenv("OPENFGA_DATABASE_URL"), "OpenFGA database URL")
-	flagSet.StringVar(&migrationDBURL, "migration-db", os.Getenv("MIGRATION_DATABASE_URL"), "Database URL for migration tracking (defaults to OPENFGA_DATASTORE_URI)")
-	flagSet.StringVar(&modelPath, "model", "model.fga", "path to authorization model file")
+	flagSet.StringVar(&trackerDBURL, "tracker-dburl", os.Getenv("OMG_TRACKER_DATABASE_URL"), "PostgreSQL URL of the migration tracking database (defaults to -migration-db, then OPENFGA_DATASTORE_URI)")
+	flagSet.StringVar(&migrationDBURL, "migration-db", os.Getenv("MIGRATION_DATABASE_URL"), "Database URL for migration tracking (older name for -tracker-dburl)")
+	flagSet.StringVar(&trackerKind, "tracker", os.Getenv("OMG_TRACKER"), "where applied migrations are tracked: postgres (default) or tuples (in the OpenFGA store)")
+	flagSet.StringVar(&modelPath, "model", "model.fga", "path to authorization model file (- reads from stdin)")
+	flagSet.StringVar(&outputFormat, "format", "", "output format: json, table or plain for status, diff, list-tuples and list-stores; json or table for store-info (changelog: markdown, plain; access-report: table, csv; diff also yaml; show-model and convert: dsl, json)")
+	flagSet.StringVar(&ignoreRules, "ignore", os.Getenv("OMG_IGNORE"), "comma-separated types or type#relation pairs to leave out of diffs (patterns allowed)")
+	flagSet.StringVar(&ignoreChanges, "ignore-changes", "", "comma-separated change kinds to leave out of diffs (e.g. remove_relation)")
+	flagSet.BoolVar(&backfill, "backfill", false, "generate data steps reporting direct tuples made redundant by updated relations")
+	flagSet.BoolVar(&force, "force", false, "apply migrations even if the live model does not match model.lock, record a baseline even if it does not match the schema snapshot, or delete a store without confirmation")
+	flagSet.BoolVar(&noColor, "no-color", false, "disable colored diff output")
+	flagSet.BoolVar(&verifyRollback, "verify-rollback", false, "roll back a migration whose verification checks fail")
+	flagSet.BoolVar(&split, "split", false, "with generate: write model, tuple and cleanup changes as separate migrations")
+	flagSet.StringVar(&strategy, "strategy", omg.StrategyIncremental, "with generate: incremental (one model helper per change) or model-apply (apply the whole model file, helpers only for tuples)")
+	flagSet.BoolVar(&idempotent, "idempotent", false, "with generate: make every step skip work that is already done, so re-running is safe")
+	flagSet.StringVar(&changesPath, "changes", "", "with generate: use changes saved by 'omg diff -format json|yaml' instead of detecting them")
+	flagSet.StringVar(&inverseVersion, "inverse", "", "with generate: write a migration that undoes this migration version")
+	flagSet.StringVar(&migrationPackage, "package", "", "with create and generate: write migrations for this Go package that register themselves for omg.Run")
+	flagSet.StringVar(&seedPath, "seed", "", "with create: write a migration that seeds the tuples of this JSON, YAML or CSV file")
+	flagSet.StringVar(&seedFile, "file", "", "with seed: the JSON, YAML or CSV tuple file to write")
+	flagSet.BoolVar(&verifyGenerated, "verify", false, "with generate: type-check the migration with go vet and discard it if it does not compile")
+	flagSet.StringVar(&templatePath, "template", "", "with create and generate: Go text/template for migration files (default: "+omg.MigrationTemplateFile+" when it exists)")
+	flagSet.StringVar(&pruneType, "type", "", "with prune-tuples: object type whose tuples are deleted")
+	flagSet.StringVar(&pruneRelation, "relation", "", "with prune-tuples: only delete tuples with this relation")
+	flagSet.BoolVar(&dryRun, "dry-run", false, "with prune-tuples, expire, seed and import -diff: show what would change without changing it")
+	flagSet.BoolVar(&assumeYes, "yes", false, "skip confirmation prompts: removals in up/down, renames in generate, prune-tuples and import -diff")
+	flagSet.BoolVar(&assumeYes, "y", false, "shorthand for -yes")
+	flagSet.BoolVar(&nonInteractive, "non-interactive", false, "never prompt; operations that need confirmation fail unless -yes is given (for CI)")
+	flagSet.BoolVar(&importDiff, "diff", false, "with import: only write missing tuples and delete extraneous ones in the file's scope")
+	flagSet.StringVar(&usersPath, "users", "", "with access-report: file listing users, one per line")
+	flagSet.StringVar(&reportObject, "object", "", "with access-report: object to check, e.g. document:readme")
+	flagSet.BoolVar(&resume, "resume", false, "with up, up-to and down: continue interrupted tuple renames and moves from their checkpoints in "+omg.CheckpointDir)
+	flagSet.BoolVar(&verifyRenames, "verify-renames", false, "with up, up-to and down: rename tuples in three phases (write, verify, delete), journaled in the tracker for repair-rename")
+	flagSet.BoolVar(&purge, "purge", false, "with down: delete the migration's tracker row instead of marking it rolled back")
+	flagSet.StringVar(&summaryPath, "summary", "", "write a JSON summary of the generated migration to this file (- for stdout)")
+	flagSet.BoolVar(&countTuples, "count-tuples", false, "with diff, generate and plan: count the tuples of removed types and relations to guide rename detection")
+	flagSet.BoolVar(&liveState, "live", false, "with generate: diff against the live model even when the migrations directory has a schema snapshot")
+	flagSet.BoolVar(&withTests, "with-tests", false, "generate a _test.go alongside the migration")
+	flagSet.StringVar(&envProfile, "env", os.Getenv("OMG_ENV"), "environment to use: one defined in the config file, or <ENV>_OPENFGA_* variables (e.g. staging)")
+	flagSet.BoolVar(&promoteApply, "apply", false, "with promote: apply the migrations the target environment is missing")
+	flagSet.StringVar(&configPath, "config", os.Getenv("OMG_CONFIG"), "config file with named environments (default: omg.yaml, omg.yml or .omgrc when present)")
+	flagSet.StringVar(&diffFrom, "from", "", "with diff: compare this model file (DSL, JSON, fga.mod or snapshot) instead of the live model; with clone-store: the ID of the store to copy (default: the configured store)")
+	flagSet.StringVar(&cloneName, "to-name", "", "with clone-store: name of the new store")
+	flagSet.IntVar(&rehearseSample, "sample", 0, "with rehearse: copy this many tuples of the store into the rehearsal store (default: the model only; -1 for all)")
+	flagSet.BoolVar(&keepRehearsal, "keep", false, "with rehearse: keep the rehearsal store instead of deleting it")
+	flagSet.BoolVar(&rehearseLocally, "container", false, "with rehearse: rehearse in a local OpenFGA container (needs Docker and an omg built with -tags rehearse_container) instead of a store next to the configured one")
+	flagSet.StringVar(&rehearseURL, "rehearse-url", "", "with rehearse: rehearse on the OpenFGA server at this URL, without authentication, instead of a store next to the configured one")
+	flagSet.StringVar(&diffTo, "to", "", "with diff: the desired model file (default: -model)")
+	flagSet.StringVar(&failOn, "fail-on", "changes", "with diff: exit with status 2 on any changes, only on destructive ones (removals, renames), or never")
+	flagSet.BoolVar(&checkOnly, "check", false, "with fmt: report files that are not formatted and exit non-zero, without changing them")
+	flagSet.StringVar(&storeMode, "mode", os.Getenv("OMG_MODE"), "store mode: full (default) or model-only, for stores whose tuples are managed by the application")
+	flagSet.BoolVar(&modelOnly, "model-only", false, "shorthand for -mode model-only")
+	flagSet.StringVar(&schemaVersion, "schema-version", os.Getenv("OMG_SCHEMA_VERSION"), "schema version of the models omg writes (e.g. 1.2), overriding the version the model declares")
+	flagSet.StringVar(&parserName, "parser", os.Getenv("OMG_PARSER"), "DSL parser: simple (default) or openfga (needs a build with -tags openfga_language)")
+	flagSet.Var(&envFiles, "env-file", "load variables from this file before ./.env (repeatable)")
 	flagSet.Parse(os.Args[2:])
 
+	if err := loadEnvFiles(envFiles); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	applyEnvDefaults(flagSet)
+
+	configEnv, configFile, err := loadConfigEnvironment()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if configEnv != nil {
+		applied := applyConfigEnvironment(flagSet, *configEnv, envProfile != "")
+		fmt.Fprintf(os.Stderr, "Using environment '%s' from %s (%d settings)\n", configEnvName(configFile), configFile.Path, applied)
+		applyEnvDefaults(flagSet)
+	} else if envProfile != "" {
+		applied := applyEnvProfile(envProfile)
+		fmt.Fprintf(os.Stderr, "Using environment profile '%s' (%d variables)\n", envProfile, applied)
+		applyEnvDefaults(flagSet)
+	}
+	if storeMode != "" && storeMode != "full" && storeMode != "model-only" {
+		fmt.Printf("Error: unknown -mode %q (expected full or model-only)\n", storeMode)
+		os.Exit(1)
+	}
+	if strategy != omg.StrategyIncremental && strategy != omg.StrategyModelApply {
+		fmt.Printf("Error: unknown -strategy %q (expected %s or %s)\n", strategy, omg.StrategyIncremental, omg.StrategyModelApply)
+		os.Exit(1)
+	}
+	if failOn != "changes" && failOn != "destructive" && failOn != "never" {
+		fmt.Printf("Error: unknown -fail-on %q (expected changes, destructive or never)\n", failOn)
+		os.Exit(1)
+	}
+	if parserName != "" {
+		if err := omg.SetModelParser(parserName); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
 	ctx := context.Background()
 
 	// Commands that don't need OpenFGA client
@@ -65,6 +222,17 @@ func main() {
 		return
 	case "generate":
 		args := flagSet.Args()
+		if inverseVersion != "" {
+			name := ""
+			if len(args) >= 1 {
+				name = args[0]
+			}
+			if err := generateInverseMigration(inverseVersion, name); err != nil {
+				fmt.Printf("Error: Failed to generate inverse migration: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
 		name := "auto_migration"
 		if len(args) >= 1 {
 			name = args[0]
@@ -75,10 +243,14 @@ func main() {
 		}
 		return
 	case "diff":
-		if err := showDiff(); err != nil {
+		changes, err := showDiff()
+		if err != nil {
 			fmt.Printf("Error: Failed to show diff: %v\n", err)
 			os.Exit(1)
 		}
+		if diffFails(changes) {
+			os.Exit(2)
+		}
 		return
 	case "init":
 		args := flagSet.Args()
@@ -97,6 +269,88 @@ func main() {
 			os.Exit(1)
 		}
 		return
+	case "clone-store":
+		if cloneName == "" {
+			fmt.Println("Usage: omg clone-store [-from <store_id>] -to-name <name>")
+			os.Exit(1)
+		}
+		if err := cloneStore(ctx, diffFrom, cloneName); err != nil {
+			fmt.Printf("Error: Failed to clone store: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	case "rehearse":
+		if err := rehearse(ctx); err != nil {
+			fmt.Printf("Error: Rehearsal failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	case "delete-store":
+		args := flagSet.Args()
+		if len(args) < 1 {
+			fmt.Println("Usage: omg delete-store [-force] <store_id>")
+			os.Exit(1)
+		}
+		if err := deleteStore(ctx, args[0]); err != nil {
+			fmt.Printf("Error: Failed to delete store: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	case "changelog":
+		if err := showChangelog(ctx); err != nil {
+			fmt.Printf("Error: Failed to generate changelog: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	case "history":
+		if err := showHistory(ctx); err != nil {
+			fmt.Printf("Error: Failed to show history: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	case "fmt":
+		files := flagSet.Args()
+		if len(files) == 0 {
+			files = []string{modelPath}
+		}
+		unformatted, err := formatModels(files)
+		if err != nil {
+			fmt.Printf("Error: Failed to format model: %v\n", err)
+			os.Exit(1)
+		}
+		if checkOnly && len(unformatted) > 0 {
+			os.Exit(1)
+		}
+		return
+	case "convert":
+		args := flagSet.Args()
+		if len(args) < 1 || len(args) > 2 {
+			fmt.Println("Usage: omg convert <input> [output] [-format dsl|json]")
+			os.Exit(1)
+		}
+		output := "-"
+		if len(args) == 2 {
+			output = args[1]
+		}
+		if err := convertModel(args[0], output); err != nil {
+			fmt.Printf("Error: Failed to convert model: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// promote connects to the environments it compares itself
+	if command == "promote" {
+		args := flagSet.Args()
+		if len(args) < 2 {
+			fmt.Println("Usage: omg promote [-apply] <source-env> <target-env>")
+			os.Exit(1)
+		}
+		if err := promote(ctx, flagSet, args[0], args[1]); err != nil {
+			fmt.Printf("Error: Promotion failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
 	}
 
 	// Initialize OpenFGA client for other commands
@@ -117,6 +371,36 @@ func main() {
 			fmt.Printf("Error: Migration down failed: %v\n", err)
 			os.Exit(1)
 		}
+	case "up-to":
+		args := flagSet.Args()
+		if len(args) < 1 {
+			fmt.Println("Usage: omg up-to <version>")
+			os.Exit(1)
+		}
+		if err := runUpTo(ctx, client, args[0]); err != nil {
+			fmt.Printf("Error: Migration up failed: %v\n", err)
+			os.Exit(1)
+		}
+	case "down-to":
+		args := flagSet.Args()
+		if len(args) < 1 {
+			fmt.Println("Usage: omg down-to <version>  (0 rolls back everything)")
+			os.Exit(1)
+		}
+		if err := runDownTo(ctx, client, args[0], 0); err != nil {
+			fmt.Printf("Error: Migration down failed: %v\n", err)
+			os.Exit(1)
+		}
+	case "baseline":
+		args := flagSet.Args()
+		if len(args) < 1 {
+			fmt.Println("Usage: omg baseline <version> [-force]")
+			os.Exit(1)
+		}
+		if err := baselineMigrations(ctx, client, args[0]); err != nil {
+			fmt.Printf("Error: Failed to record baseline: %v\n", err)
+			os.Exit(1)
+		}
 	case "status":
 		if err := showStatus(ctx, client); err != nil {
 			fmt.Printf("Error: Failed to show status: %v\n", err)
@@ -132,11 +416,151 @@ func main() {
 			fmt.Printf("Error: Failed to list tuples: %v\n", err)
 			os.Exit(1)
 		}
+	case "restore-backup":
+		args := flagSet.Args()
+		if len(args) < 1 {
+			fmt.Println("Usage: omg restore-backup <version> [type[#relation]]")
+			os.Exit(1)
+		}
+		target := ""
+		if len(args) >= 2 {
+			target = args[1]
+		}
+		if err := restoreBackup(ctx, client, args[0], target); err != nil {
+			fmt.Printf("Error: Failed to restore backup: %v\n", err)
+			os.Exit(1)
+		}
 	case "show-model":
 		if err := showModel(ctx, client); err != nil {
 			fmt.Printf("Error: Failed to show model: %v\n", err)
 			os.Exit(1)
 		}
+	case "snapshot":
+		args := flagSet.Args()
+		if len(args) < 1 {
+			fmt.Println("Usage: omg snapshot <file>")
+			os.Exit(1)
+		}
+		if err := takeSnapshot(ctx, client, args[0]); err != nil {
+			fmt.Printf("Error: Failed to take snapshot: %v\n", err)
+			os.Exit(1)
+		}
+	case "seed":
+		if seedFile == "" {
+			fmt.Println("Usage: omg seed -file <tuples.yaml|json|csv> [-dry-run]")
+			os.Exit(1)
+		}
+		if err := seedTuples(ctx, client, seedFile); err != nil {
+			fmt.Printf("Error: Failed to seed tuples: %v\n", err)
+			os.Exit(1)
+		}
+	case "import":
+		args := flagSet.Args()
+		if len(args) < 1 {
+			fmt.Println("Usage: omg import <tuples.json> [-diff] [-dry-run] [-yes]")
+			os.Exit(1)
+		}
+		if err := importTuples(ctx, client, args[0]); err != nil {
+			fmt.Printf("Error: Failed to import tuples: %v\n", err)
+			os.Exit(1)
+		}
+	case "tracker":
+		args := flagSet.Args()
+		if len(args) < 1 || (args[0] != "export" && args[0] != "import" && args[0] != "adopt") || (args[0] == "import" && len(args) < 2) {
+			fmt.Println("Usage: omg tracker [-tracker postgres|tuples] export [file]  (stdout by default)")
+			fmt.Println("       omg tracker [-tracker postgres|tuples] import <file>")
+			fmt.Println("       omg tracker adopt [-yes]")
+			os.Exit(1)
+		}
+		if args[0] == "adopt" {
+			if err := adoptTrackerRows(ctx, client); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: Failed to adopt migrations: %v\n", err)
+				os.Exit(1)
+			}
+		} else if args[0] == "export" {
+			file := "-"
+			if len(args) >= 2 {
+				file = args[1]
+			}
+			if err := exportTracker(ctx, client, file); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: Failed to export tracker: %v\n", err)
+				os.Exit(1)
+			}
+		} else if err := importTracker(ctx, client, args[1]); err != nil {
+			fmt.Printf("Error: Failed to import tracker: %v\n", err)
+			os.Exit(1)
+		}
+	case "plan":
+		args := flagSet.Args()
+		if len(args) < 1 {
+			fmt.Println("Usage: omg plan <plan.json|->")
+			os.Exit(1)
+		}
+		if err := planChanges(ctx, client, args[0]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Failed to plan changes: %v\n", err)
+			os.Exit(1)
+		}
+	case "apply":
+		args := flagSet.Args()
+		if len(args) < 1 {
+			fmt.Println("Usage: omg apply <plan.json> [-yes]")
+			os.Exit(1)
+		}
+		if err := applyPlanFile(ctx, client, args[0]); err != nil {
+			fmt.Printf("Error: Failed to apply plan: %v\n", err)
+			os.Exit(1)
+		}
+	case "repair-rename":
+		args := flagSet.Args()
+		if len(args) == 1 || (len(args) > 1 && args[0] != "finish" && args[0] != "rollback") {
+			fmt.Println("Usage: omg repair-rename                       List interrupted renames")
+			fmt.Println("       omg repair-rename finish <operation>    e.g. finish rename_type team organization")
+			fmt.Println("       omg repair-rename rollback <operation>")
+			os.Exit(1)
+		}
+		if err := repairRename(ctx, client, args); err != nil {
+			fmt.Printf("Error: Failed to repair rename: %v\n", err)
+			os.Exit(1)
+		}
+	case "expire":
+		if err := expireTuples(ctx, client); err != nil {
+			fmt.Printf("Error: Failed to expire tuples: %v\n", err)
+			os.Exit(1)
+		}
+	case "ready":
+		if err := checkReady(ctx, client); err != nil {
+			fmt.Printf("Not ready: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("Ready")
+	case "store-info":
+		if err := storeInfo(ctx, client); err != nil {
+			fmt.Printf("Error: Failed to get store info: %v\n", err)
+			os.Exit(1)
+		}
+	case "auth-check":
+		if err := authCheck(ctx, client); err != nil {
+			fmt.Printf("Error: Authentication check failed: %v\n", err)
+			os.Exit(1)
+		}
+	case "access-report":
+		if usersPath == "" || reportObject == "" {
+			fmt.Println("Usage: omg access-report -users <file> -object <type:id> [-format table|csv]")
+			os.Exit(1)
+		}
+		if err := accessReport(ctx, client, usersPath, reportObject); err != nil {
+			fmt.Printf("Error: Failed to build access report: %v\n", err)
+			os.Exit(1)
+		}
+	case "prune-tuples":
+		if pruneType == "" {
+			fmt.Println("Usage: omg prune-tuples -type <type> [-relation <relation>] [-dry-run] [-yes]")
+			os.Exit(1)
+		}
+		if err := pruneTuples(ctx, client, pruneType, pruneRelation); err != nil {
+			fmt.Printf("Error: Failed to prune tuples: %v\n", err)
+			os.Exit(1)
+		}
 	default:
 		fmt.Printf("Error: Unknown command: %s\n", command)
 		printUsage()
@@ -153,33 +577,115 @@ func printUsage() {
 	fmt.Println("Model-First Workflow:")
 	fmt.Println("  diff                Show changes between model.fga and current state")
 	fmt.Println("  generate [name]     Auto-generate migration from model.fga changes")
+	fmt.Println("  plan <file>         Write model.fga changes and their operations to a reviewable plan file")
+	fmt.Println("  apply <file>        Apply a plan file, if the store's model has not changed since")
+	fmt.Println("  fmt [files]         Rewrite model files (default: model.fga) in canonical form; -check only reports")
+	fmt.Println("  convert <in> [out]  Translate a model between DSL and JSON (-format dsl|json)")
 	fmt.Println("  up                  Apply pending migrations")
 	fmt.Println("  down                Rollback last migration")
+	fmt.Println("  up-to <version>     Apply pending migrations up to and including version")
+	fmt.Println("  down-to <version>   Roll back migrations newer than version (0 for all)")
+	fmt.Println("  baseline <version>  Record migrations up to version as applied without running them")
+	fmt.Println("  rehearse            Run pending migrations against a throwaway copy of the store's model")
+	fmt.Println("                      (-sample N: with N tuples; -container: in a local OpenFGA container;")
+	fmt.Println("                      -rehearse-url: on another OpenFGA server)")
 	fmt.Println("  status              Show migration status")
+	fmt.Println("  ready               Exit 0 only if OpenFGA is reachable, the store exists and nothing is pending")
+	fmt.Println("  auth-check          Validate the credentials and show the access token's expiry, without migrating")
+	fmt.Println("  changelog           Render applied migrations as a changelog")
+	fmt.Println("  history             Show every recorded up, down and failed run")
+	fmt.Println("  restore-backup <version> [type[#relation]]")
+	fmt.Println("                      Restore tuples a migration backed up before removing them")
 	fmt.Println("")
 	fmt.Println("Manual Migration Commands:")
-	fmt.Println("  create <name>       Create blank migration file")
+	fmt.Println("  create <name>       Create blank migration file (-seed <file>: seed the file's tuples)")
 	fmt.Println("")
 	fmt.Println("Store Management:")
 	fmt.Println("  init <name>         Create a new OpenFGA store")
 	fmt.Println("  list-stores         List all OpenFGA stores")
+	fmt.Println("  store-info          Show the store's metadata, latest model, model count and tuple count")
+	fmt.Println("  delete-store <id>   Delete a store with its models and tuples (-force: without confirmation)")
+	fmt.Println("  clone-store -to-name <name> [-from <id>]")
+	fmt.Println("                      Create a store with a copy of the latest model and all tuples of the store")
 	fmt.Println("")
 	fmt.Println("Utilities:")
-	fmt.Println("  show-model          Show current authorization model")
+	fmt.Println("  show-model          Show current authorization model (-format json for the API format)")
 	fmt.Println("  list-tuples [type]  List all tuples (optionally filtered)")
+	fmt.Println("  access-report -users <file> -object <type:id>")
+	fmt.Println("                      Check every relation on an object for each user")
+	fmt.Println("  snapshot <file>     Save the model and all tuples to a snapshot archive")
+	fmt.Println("  import <file>       Write tuples from a JSON file (-diff: sync the file's type#relation pairs)")
+	fmt.Println("  seed -file <file>   Write the tuples of a JSON, YAML or CSV file that are not in the store yet")
+	fmt.Println("  expire              Delete temporary tuples whose expiry has passed")
+	fmt.Println("  repair-rename [finish|rollback <operation>]")
+	fmt.Println("                      List renames interrupted under -verify-renames, or finish or roll one back")
+	fmt.Println("  tracker export [file]  Dump applied migrations and run history as JSON (stdout by default)")
+	fmt.Println("  tracker import <file>  Record the applied migrations of an export that the tracker lacks")
+	fmt.Println("  tracker adopt          Assign migrations recorded without a store ID to the current store")
+	fmt.Println("  promote [-apply] <source-env> <target-env>")
+	fmt.Println("                      List migrations applied in one environment but not the other; -apply runs them")
+	fmt.Println("  prune-tuples -type <type> [-relation <relation>]")
+	fmt.Println("                      Back up and delete a type's tuples without a migration")
 	fmt.Println("")
 	fmt.Println("Options:")
 	fmt.Println("  -dir string         Directory with migration files (default: migrations)")
 	fmt.Println("  -dburl string       OpenFGA database URL")
-	fmt.Println("  -model string       Path to authorization model file (default: model.fga)")
+	fmt.Println("  -tracker-dburl url  PostgreSQL database for migration tracking (env: OMG_TRACKER_DATABASE_URL)")
+	fmt.Println("  -model string       Path to authorization model file, - for stdin (default: model.fga)")
+	fmt.Println("  -env-file path      Load variables from this file before ./.env (repeatable)")
+	fmt.Println("  -env name           Use an environment of the config file, or <NAME>_OPENFGA_* variables (env: OMG_ENV)")
+	fmt.Println("  -config path        Config file with named environments (default: omg.yaml, omg.yml or .omgrc; env: OMG_CONFIG)")
+	fmt.Println("  -format string      Output format: json, table or plain for status, diff, list-tuples, list-stores")
+	fmt.Println("                      (changelog: markdown, plain; access-report: table, csv; diff also yaml)")
+	fmt.Println("  -force              With up: ignore a live model that does not match model.lock;")
+	fmt.Println("                      with baseline: one that does not match the schema snapshot")
+	fmt.Println("  -ignore list        Types or type#relation pairs to leave out of diffs (env: OMG_IGNORE)")
+	fmt.Println("  -ignore-changes list  Change kinds to leave out of diffs (e.g. remove_relation)")
+	fmt.Println("  -no-color           Disable colored diff output")
+	fmt.Println("  -summary path       With generate: write a JSON summary (- for stdout)")
+	fmt.Println("  -split              With generate: separate migrations for model changes, tuple migrations and cleanups")
+	fmt.Println("  -idempotent         With generate: skip steps already done, so an interrupted up can be re-run")
+	fmt.Println("  -strategy           With generate: incremental (default) or model-apply (apply the whole model file)")
+	fmt.Println("  -changes path       With generate: use changes saved by 'omg diff -format json' or 'yaml'")
+	fmt.Println("  -inverse version    With generate: write a forward migration that undoes the given migration")
+	fmt.Println("  -package name       With create and generate: register the migration in package <name> for omg.Run")
+	fmt.Println("  -template path      With create and generate: lay out migration files with this Go template")
+	fmt.Println("                      (default: " + omg.MigrationTemplateFile + " when it exists)")
+	fmt.Println("  -seed file          With create: write a migration that seeds the tuples of a JSON, YAML or CSV file")
+	fmt.Println("  -file path          With seed: the tuple file to write")
+	fmt.Println("  -verify             With generate: type-check the migration with go vet; it is discarded if it does not compile")
+	fmt.Println("  -verify-rollback    With up: roll back a migration whose // Verify: checks fail")
+	fmt.Println("  -resume             With up, up-to and down: continue interrupted renames from their checkpoints")
+	fmt.Println("  -verify-renames     With up, up-to and down: write, verify, then delete renamed tuples, journaled for repair-rename")
+	fmt.Println("  -users, -object     With access-report: the users and object to check")
+	fmt.Println("  -type, -relation    With prune-tuples: the tuples to delete")
+	fmt.Println("  -diff               With import: write missing and delete extraneous tuples only")
+	fmt.Println("  -dry-run            With prune-tuples, expire, seed and import -diff: preview changes only")
+	fmt.Println("  -yes, -y            Do not ask before removals in up/down, uncertain renames in generate, prune-tuples and import -diff")
+	fmt.Println("  -non-interactive    Never prompt: fail where confirmation is needed unless -yes is given (env: OMG_NON_INTERACTIVE)")
+	fmt.Println("  -from, -to file     With diff: compare two model files (DSL, JSON, fga.mod or snapshot), without OpenFGA")
+	fmt.Println("  -fail-on <when>     With diff: exit 2 on changes (default), destructive changes only, or never")
+	fmt.Println("  -check              With fmt: exit non-zero if a file is not formatted, without changing it")
+	fmt.Println("  -mode model-only    Change the model only, never tuples, for stores whose tuples the application manages (env: OMG_MODE)")
+	fmt.Println("  -model-only         Shorthand for -mode model-only")
+	fmt.Println("  -schema-version v   Schema version of the models up, apply and migrations write, e.g. 1.2 (env: OMG_SCHEMA_VERSION)")
+	fmt.Println("  -parser <name>      DSL parser: simple (default) or openfga, in builds with -tags openfga_language (env: OMG_PARSER)")
+	fmt.Println("  -purge              With down: delete the tracker row instead of marking it rolled back")
+	fmt.Println("  -tracker <kind>     Track applied migrations in postgres (default) or as tuples in the store (env: OMG_TRACKER)")
+	fmt.Println("  -backfill           With generate: report direct tuples made redundant by updated relations")
+	fmt.Println("  -with-tests         With generate: also write a _test.go for the migration")
+	fmt.Println("  -count-tuples       With diff, generate and plan: weigh renames by the tuples of removed types and relations")
+	fmt.Println("  -live               With generate: compare with OpenFGA even when migrations has a schema snapshot")
 	fmt.Println("")
 	fmt.Println("Database URL format:")
 	fmt.Println("  openfga://store_id@host:port")
+	fmt.Println("  openfga://store-name@host:port?by=name")
 	fmt.Println("")
 	fmt.Println("Environment variables:")
 	fmt.Println("  OPENFGA_DATABASE_URL   - Database URL (alternative to -dburl)")
 	fmt.Println("  OPENFGA_API_URL        - OpenFGA API URL (alternative)")
 	fmt.Println("  OPENFGA_STORE_ID       - OpenFGA Store ID (alternative)")
+	fmt.Println("  OPENFGA_STORE_NAME     - OpenFGA Store name, looked up when OPENFGA_STORE_ID is unset")
 	fmt.Println("  OPENFGA_AUTH_METHOD    - Auth method: none, token, client_credentials")
 	fmt.Println("  OPENFGA_API_TOKEN      - API token (if auth_method=token)")
 	fmt.Println("  OPENFGA_CLIENT_ID      - Client ID (if auth_method=client_credentials)")
@@ -199,325 +705,772 @@ func printUsage() {
 	fmt.Println("  omg diff -model custom/auth.fga        # Use custom model file")
 	fmt.Println("  omg generate add_files                 # Generate migration from model changes")
 	fmt.Println("  omg generate -model custom/auth.fga    # Generate with custom model")
+	fmt.Println("  git show main:model.fga | omg diff -model -  # Diff a model from stdin")
 	fmt.Println("  omg up                                 # Apply migrations")
 	fmt.Println("  omg down                               # Rollback last migration")
 	fmt.Println("  omg status                             # Check migration status")
 }
 
-func initOpenFGAClient() (*omg.Client, error) {
-	var cfg omg.Config
+// envFlags maps flags to the environment variable they default to
+var envFlags = map[string]string{
+	"dburl":           "OPENFGA_DATABASE_URL",
+	"migration-db":    "MIGRATION_DATABASE_URL",
+	"tracker-dburl":   "OMG_TRACKER_DATABASE_URL",
+	"tracker":         "OMG_TRACKER",
+	"ignore":          "OMG_IGNORE",
+	"env":             "OMG_ENV",
+	"config":          "OMG_CONFIG",
+	"non-interactive": "OMG_NON_INTERACTIVE",
+	"mode":            "OMG_MODE",
+	"parser":          "OMG_PARSER",
+	"schema-version":  "OMG_SCHEMA_VERSION",
+}
 
-	// Try to parse database URL first
-	if dbURL != "" {
-		parsedCfg, err := parseDBURL(dbURL)
-		if err != nil {
-			return nil, fmt.Errorf("invalid database URL: %w", err)
+// loadEnvFiles loads environment files without overriding variables that are already set
+// Lookup order, highest priority first:
+//  1. variables already in the environment
+//  2. -env-file files, in the order given
+//  3. ./.env, if present
+func loadEnvFiles(files []string) error {
+	for _, file := range files {
+		if err := godotenv.Load(file); err != nil {
+			return fmt.Errorf("failed to load env file %s: %w", file, err)
 		}
-		cfg = parsedCfg
-	} else {
-		// Fall back to environment variables
-		cfg = omg.Config{
-			ApiURL:        os.Getenv("OPENFGA_API_URL"),
-			StoreID:       os.Getenv("OPENFGA_STORE_ID"),
-			AuthMethod:    os.Getenv("OPENFGA_AUTH_METHOD"),
-			APIToken:      os.Getenv("OPENFGA_API_TOKEN"),
-			ClientID:      os.Getenv("OPENFGA_CLIENT_ID"),
-			ClientSecret:  os.Getenv("OPENFGA_CLIENT_SECRET"),
-			TokenIssuer:   os.Getenv("OPENFGA_TOKEN_ISSUER"),
-			TokenAudience: os.Getenv("OPENFGA_TOKEN_AUDIENCE"),
+	}
+
+	if _, err := os.Stat(".env"); err == nil {
+		if err := godotenv.Load(".env"); err != nil {
+			return fmt.Errorf("failed to load .env: %w", err)
 		}
 	}
+	return nil
+}
 
-	return omg.NewClient(cfg)
+// envProfilePrefixes limits which variables a profile may override
+var envProfilePrefixes = []string{"OPENFGA_", "MIGRATION_", "OMG_"}
+
+// applyEnvProfile copies <PROFILE>_OPENFGA_* (and MIGRATION_*, OMG_*) variables over
+// their unprefixed names, e.g. STAGING_OPENFGA_API_URL -> OPENFGA_API_URL
+// The variables are set in the process environment so migrations run by up/down see them too
+func applyEnvProfile(profile string) int {
+	prefix := strings.ToUpper(strings.ReplaceAll(profile, "-", "_")) + "_"
+
+	applied := 0
+	for _, entry := range os.Environ() {
+		key, value, _ := strings.Cut(entry, "=")
+		name, found := strings.CutPrefix(key, prefix)
+		if !found {
+			continue
+		}
+		for _, allowed := range envProfilePrefixes {
+			if strings.HasPrefix(name, allowed) {
+				os.Setenv(name, value)
+				applied++
+				break
+			}
+		}
+	}
+	return applied
 }
 
-// parseDBURL parses a database URL in the format:
-// openfga://store_id@host:port
-func parseDBURL(dburl string) (omg.Config, error) {
-	u, err := url.Parse(dburl)
+// loadConfigEnvironment loads the environment selected with -env from the config file
+// It returns nil when there is no config file, or when the file does not define the -env
+// environment, so <ENV>_OPENFGA_* variables keep working alongside it
+func loadConfigEnvironment() (*omg.EnvironmentConfig, *omg.ConfigFile, error) {
+	file, err := loadConfigFile()
+	if err != nil || file == nil {
+		return nil, nil, err
+	}
+	if envProfile != "" {
+		if _, ok := file.Environments[envProfile]; !ok && configPath == "" {
+			return nil, nil, nil
+		}
+	} else if file.Default == "" {
+		return nil, nil, nil
+	}
+
+	env, err := file.Environment(envProfile)
 	if err != nil {
-		return omg.Config{}, err
+		return nil, nil, err
+	}
+	return &env, file, nil
+}
+
+// loadConfigFile loads -config, or the config file of the working directory
+// It returns nil when -config is not set and there is no config file
+func loadConfigFile() (*omg.ConfigFile, error) {
+	path := configPath
+	if path == "" {
+		found, ok := omg.FindConfigFile(".")
+		if !ok {
+			return nil, nil
+		}
+		path = found
+	}
+	return omg.LoadConfigFile(path)
+}
+
+// configEnvName is the name of the config file environment in use
+func configEnvName(file *omg.ConfigFile) string {
+	if envProfile != "" {
+		return envProfile
 	}
+	return file.Default
+}
 
-	if u.Scheme != "openfga" {
-		return omg.Config{}, fmt.Errorf("invalid scheme: expected 'openfga', got '%s'", u.Scheme)
+// applyConfigEnvironment sets the variables and flag defaults of a config file environment
+// With override (an environment selected with -env) it replaces variables that are already
+// set, and clears the ones it leaves out so no store, credentials or tracker of another
+// environment leak into it; otherwise it only fills in unset ones. Explicit flags always win
+func applyConfigEnvironment(flagSet *flag.FlagSet, env omg.EnvironmentConfig, override bool) int {
+	applied := 0
+	for key, value := range env.Variables() {
+		_, set := os.LookupEnv(key)
+		switch {
+		case value == "":
+			if override {
+				os.Unsetenv(key)
+			}
+		case set && !override:
+		default:
+			os.Setenv(key, value)
+			applied++
+		}
 	}
 
-	storeID := u.User.Username()
-	if storeID == "" {
-		return omg.Config{}, fmt.Errorf("store ID is required")
+	explicit := make(map[string]bool)
+	flagSet.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+	for name, value := range map[string]string{"dir": env.MigrationsDir, "model": env.Model} {
+		if value != "" && !explicit[name] {
+			flagSet.Set(name, value)
+			applied++
+		}
 	}
+	return applied
+}
 
-	host := u.Host
-	if host == "" {
-		return omg.Config{}, fmt.Errorf("host is required")
+// applyEnvDefaults refreshes environment-backed flags that were not set explicitly,
+// since their defaults were read before the profile was applied
+func applyEnvDefaults(flagSet *flag.FlagSet) {
+	explicit := make(map[string]bool)
+	flagSet.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	for name, env := range envFlags {
+		if !explicit[name] {
+			flagSet.Set(name, os.Getenv(env))
+		}
 	}
+}
 
-	// Build API URL
-	scheme := "https"
-	if u.Query().Get("tls") == "false" {
-		scheme = "http"
+func initOpenFGAClient() (*omg.Client, error) {
+	cfg, err := clientConfig()
+	if err != nil {
+		return nil, err
 	}
-	apiURL := fmt.Sprintf("%s://%s", scheme, host)
+	return omg.NewClient(cfg)
+}
 
-	cfg := omg.Config{
-		ApiURL:  apiURL,
-		StoreID: storeID,
+// clientConfig reads the client config from -dburl, or else from the environment
+func clientConfig() (omg.Config, error) {
+	var cfg omg.Config
+	var err error
+	if dbURL != "" {
+		cfg, err = omg.ParseDatabaseURL(dbURL)
+		if err != nil {
+			return omg.Config{}, fmt.Errorf("invalid database URL: %w", err)
+		}
+	} else {
+		cfg, err = omg.ConfigFromEnv()
+		if err != nil {
+			return omg.Config{}, err
+		}
 	}
+	cfg.SchemaVersion = schemaVersion
+	return cfg, nil
+}
 
-	// Parse query parameters for auth
-	query := u.Query()
-	if authMethod := query.Get("auth"); authMethod != "" {
-		cfg.AuthMethod = authMethod
+// storeAdminConfig is the client config of the commands that manage stores rather than
+// work in one; no store needs to be set
+func storeAdminConfig() (omg.Config, error) {
+	cfg, err := clientConfig()
+	if err != nil {
+		return omg.Config{}, err
 	}
-	if token := query.Get("token"); token != "" {
-		cfg.APIToken = token
+	if cfg.ApiURL == "" {
+		return omg.Config{}, fmt.Errorf("OPENFGA_API_URL or -dburl is required")
 	}
-	if clientID := query.Get("client_id"); clientID != "" {
-		cfg.ClientID = clientID
+	return cfg, nil
+}
+
+// migrationEnv is the environment migration programs run with
+// A -dburl flag is passed on as OPENFGA_DATABASE_URL, -schema-version as OMG_SCHEMA_VERSION
+// and -resume as OMG_RESUME, so migrations connect, write models and resume like the CLI
+func migrationEnv() []string {
+	env := os.Environ()
+	if dbURL != "" {
+		env = append(env, "OPENFGA_DATABASE_URL="+dbURL)
 	}
-	if clientSecret := query.Get("client_secret"); clientSecret != "" {
-		cfg.ClientSecret = clientSecret
+	if schemaVersion != "" {
+		env = append(env, "OMG_SCHEMA_VERSION="+schemaVersion)
 	}
-	if issuer := query.Get("issuer"); issuer != "" {
-		cfg.TokenIssuer = issuer
+	if resume {
+		env = append(env, "OMG_RESUME=true")
 	}
-	if audience := query.Get("audience"); audience != "" {
-		cfg.TokenAudience = audience
+	if verifyRenames {
+		env = append(env, "OMG_VERIFY_RENAMES=true")
+		// Renames journal their phases in the tracking database
+		if trackerKind == "" || trackerKind == "postgres" {
+			if url := trackerDatabaseURL(); url != "" {
+				env = append(env, "OMG_TRACKER_DATABASE_URL="+url)
+			}
+		}
 	}
+	return env
+}
 
-	return cfg, nil
+// trackerConnectTimeout bounds how long opening the tracking database may take
+const trackerConnectTimeout = 10 * time.Second
+
+// trackerDatabaseURL returns the URL of the tracking database: -tracker-dburl
+// (OMG_TRACKER_DATABASE_URL), then -migration-db (MIGRATION_DATABASE_URL), then OpenFGA's
+// own OPENFGA_DATASTORE_URI. Empty when none is set
+func trackerDatabaseURL() string {
+	if trackerDBURL != "" {
+		return trackerDBURL
+	}
+	if migrationDBURL != "" {
+		return migrationDBURL
+	}
+	return os.Getenv("OPENFGA_DATASTORE_URI")
 }
 
+// initMigrationDB connects to the tracking database at trackerDatabaseURL
 func initMigrationDB() (*sql.DB, error) {
-	// Default to OPENFGA_DATASTORE_URI if migrationDBURL is not set
-	dbURL := migrationDBURL
+	dbURL := trackerDatabaseURL()
 	if dbURL == "" {
-		// Try to use the same database as OpenFGA
-		dbURL = os.Getenv("OPENFGA_DATASTORE_URI")
-		if dbURL == "" {
-			return nil, fmt.Errorf("MIGRATION_DATABASE_URL or OPENFGA_DATASTORE_URI is required. Set via environment variable or --migration-db flag")
-		}
-		fmt.Println("Using OpenFGA database for migration tracking (OPENFGA_DATASTORE_URI)")
+		return nil, fmt.Errorf("no migration tracking database configured. Set -tracker-dburl (or OMG_TRACKER_DATABASE_URL) to a PostgreSQL URL, or use -tracker tuples to track migrations in the OpenFGA store")
+	}
+	if trackerDBURL == "" && migrationDBURL == "" {
+		// The same database as OpenFGA
+		fmt.Fprintln(os.Stderr, "Using OpenFGA database for migration tracking (OPENFGA_DATASTORE_URI)")
 	}
+	return connectTrackerDB(dbURL)
+}
 
+// connectTrackerDB opens the tracking database at dbURL and checks that it is reachable
+func connectTrackerDB(dbURL string) (*sql.DB, error) {
 	db, err := sql.Open("postgres", dbURL)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
+		return nil, fmt.Errorf("invalid migration tracking database URL %s: %w", redactDBURL(dbURL), err)
 	}
 
-	// Test the connection
-	if err := db.Ping(); err != nil {
+	// Test the connection, without hanging on a host that drops packets
+	ctx, cancel := context.WithTimeout(context.Background(), trackerConnectTimeout)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
 		db.Close()
-		return nil, fmt.Errorf("failed to connect to database: %w", err)
+		return nil, fmt.Errorf("migration tracking database %s is unreachable: %w\nCheck -tracker-dburl (OMG_TRACKER_DATABASE_URL), or use -tracker tuples", redactDBURL(dbURL), err)
 	}
 
 	return db, nil
 }
 
-func runUp(ctx context.Context, client *omg.Client) error {
-	db, err := initMigrationDB()
-	if err != nil {
-		return err
+// redactDBURL hides the password in a database URL so it can be printed
+// Connection strings that are not URLs (key=value DSNs) are not printed at all
+func redactDBURL(dbURL string) string {
+	parsed, err := url.Parse(dbURL)
+	if err != nil || parsed.Host == "" {
+		return "(connection string)"
+	}
+	return parsed.Redacted()
+}
+
+// openTracker opens the tracker selected by -tracker; call close when done with it
+// The tuples tracker keeps no run history, so commands that need one stay Postgres-only
+func openTracker(client *omg.Client) (tracker omg.MigrationTracker, close func(), err error) {
+	return openTrackerWith(client, trackerKind, initMigrationDB)
+}
+
+// openTrackerWith opens a tracker of the given kind, connecting to Postgres with connect
+func openTrackerWith(client *omg.Client, kind string, connect func() (*sql.DB, error)) (tracker omg.MigrationTracker, close func(), err error) {
+	switch kind {
+	case "tuples":
+		return omg.NewOpenFGATracker(client), func() {}, nil
+	case "", "postgres":
+	default:
+		return nil, nil, fmt.Errorf("unknown tracker '%s' (expected postgres or tuples)", kind)
 	}
-	defer db.Close()
 
-	tracker, err := omg.NewTracker(db)
+	db, err := connect()
 	if err != nil {
-		return fmt.Errorf("failed to initialize tracker: %w", err)
+		return nil, nil, err
 	}
 
-	// Find all migration files in directory
-	pattern := filepath.Join(migrationsDir, "*_*.go")
-	files, err := filepath.Glob(pattern)
+	pgTracker, err := omg.NewTrackerForStore(db, client.GetStoreID())
 	if err != nil {
-		return err
+		db.Close()
+		return nil, nil, fmt.Errorf("failed to initialize tracker: %w", err)
 	}
 
-	// Filter out non-migration files
-	var migrationFiles []string
-	for _, file := range files {
-		base := filepath.Base(file)
-		// Skip migrations.go and example files
-		if base == "migrations.go" || strings.Contains(base, "example") {
-			continue
+	if pgTracker.StoreID() != "" {
+		unscoped, err := pgTracker.UnscopedMigrations(context.Background())
+		if err != nil {
+			db.Close()
+			return nil, nil, err
+		}
+		if unscoped > 0 {
+			fmt.Fprintf(os.Stderr, "Warning: %d migrations were recorded without a store ID and are ignored; "+
+				"run 'omg tracker adopt' against the store they belong to\n", unscoped)
 		}
-		migrationFiles = append(migrationFiles, file)
 	}
+	return pgTracker, func() { db.Close() }, nil
+}
 
-	// Sort by version (timestamp in filename)
-	sort.Strings(migrationFiles)
+func runUp(ctx context.Context, client *omg.Client) error {
+	return runUpTo(ctx, client, "")
+}
 
-	applied, err := tracker.GetApplied(ctx)
+// runUpTo applies pending migrations up to and including target ("" applies all)
+func runUpTo(ctx context.Context, client *omg.Client, target string) error {
+	tracker, closeTracker, err := openTracker(client)
 	if err != nil {
 		return err
 	}
+	defer closeTracker()
 
-	count := 0
-	for _, file := range migrationFiles {
-		version := extractVersionFromFilename(file)
-		name := extractNameFromFilename(file)
-
-		if _, exists := applied[version]; exists {
-			continue
-		}
-
-		fmt.Printf("OK  %s  %s\n", version, name)
-
-		// Run the migration file with 'go run'
-		cmd := exec.Command("go", "run", file, "up")
-		cmd.Env = os.Environ() // Pass through all environment variables
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
+	migrationFiles, err := findMigrationFiles()
+	if err != nil {
+		return err
+	}
+	if target != "" && !hasMigrationVersion(migrationFiles, target) {
+		return fmt.Errorf("no migration with version %s in %s", target, migrationsDir)
+	}
 
-		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("migration %s failed: %w", version, err)
+	// Refuse to build on a model that was changed outside of omg
+	lockPath := modelLockPath()
+	if err := omg.VerifyModelLock(ctx, client, lockPath); err != nil {
+		if !force {
+			return fmt.Errorf("%w\nRun with -force to apply migrations anyway", err)
 		}
+		fmt.Printf("Warning: %v (continuing because of -force)\n", err)
+	}
 
-		if err := tracker.Record(ctx, version, name); err != nil {
-			return fmt.Errorf("failed to record migration %s: %w", version, err)
+	// The tuples tracker needs its type in the model before it can record anything
+	if tuples, ok := tracker.(*omg.OpenFGATracker); ok {
+		if err := tuples.EnsureTrackingType(ctx); err != nil {
+			return err
 		}
+	}
 
-		count++
+	applied, err := tracker.GetApplied(ctx)
+	if err != nil {
+		return err
 	}
 
-	if count == 0 {
+	count, err := applyPending(ctx, client, tracker, migrationFiles, applied, target, true, lockPath)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case count == 0 && target != "":
+		fmt.Printf("No migrations to run. Already at or past %s\n", target)
+	case count == 0:
 		fmt.Println("No migrations to run. Current version: up to date")
-	} else {
+	case target != "":
+		fmt.Printf("\n✓ Migrated up to %s\n", target)
+	default:
 		fmt.Println("\n✓ All migrations applied successfully")
 	}
 
+	// Each migration updates the lock; this records the live model when none ran
+	if count == 0 {
+		if _, err := omg.UpdateModelLock(ctx, client, lockPath); err != nil {
+			return fmt.Errorf("failed to update %s: %w", lockPath, err)
+		}
+	}
+
 	return nil
 }
 
-// extractVersionFromFilename extracts the version (timestamp) from a migration filename
-// Example: "migrations/20251130123456_add_feature.go" -> "20251130123456"
-func extractVersionFromFilename(filename string) string {
-	base := filepath.Base(filename)
-	parts := strings.SplitN(base, "_", 2)
-	if len(parts) < 2 {
-		return ""
+// applyPending runs the migration files that are not in applied, up to and including
+// target ("" runs all), recording each run in tracker, and returns how many it ran
+// With confirm, removals ask for confirmation first. The model lock at lockPath ("" for
+// none) is updated after each migration, so a later failure does not leave it stale
+func applyPending(ctx context.Context, client *omg.Client, tracker omg.MigrationTracker, migrationFiles []string, applied map[string]omg.MigrationInfo, target string, confirm bool, lockPath string) (int, error) {
+	if journal, ok := tracker.(omg.RenameJournal); ok {
+		ctx = omg.WithRenameJournal(ctx, journal)
 	}
-	return parts[0]
-}
+	count := 0
+	for _, file := range migrationFiles {
+		version := extractVersionFromFilename(file)
+		name := extractNameFromFilename(file)
 
-// extractNameFromFilename extracts the name from a migration filename
-// Example: "migrations/20251130123456_add_feature.go" -> "add_feature"
-func extractNameFromFilename(filename string) string {
-	base := filepath.Base(filename)
-	parts := strings.SplitN(base, "_", 2)
-	if len(parts) < 2 {
-		return ""
+		if target != "" && version > target {
+			break
+		}
+		if _, exists := applied[version]; exists {
+			continue
+		}
+
+		if confirm && !modelOnlyMode() {
+			if err := confirmRemovals(ctx, client, file, omg.DirectionUp); err != nil {
+				return count, err
+			}
+		}
+
+		fmt.Printf("OK  %s  %s\n", version, name)
+
+		run := omg.MigrationRun{
+			Version:   version,
+			Name:      name,
+			Status:    omg.RunStatusApplied,
+			StartedAt: time.Now(),
+			AppliedBy: omg.CurrentOperator(),
+		}
+
+		if err := runMigrationFile(ctx, client, file, "up"); err != nil {
+			recordFailedRun(ctx, tracker, run, err)
+			return count, fmt.Errorf("migration %s failed: %w", version, err)
+		}
+
+		verifyErr := verifyMigration(ctx, client, file)
+		if verifyErr != nil && verifyRollback {
+			recordFailedRun(ctx, tracker, run, verifyErr)
+			fmt.Printf("Verification failed, rolling back %s\n", version)
+			if err := runMigrationFile(ctx, client, file, "down"); err != nil {
+				return count, fmt.Errorf("migration %s: %v; rollback failed: %w", version, verifyErr, err)
+			}
+			return count, fmt.Errorf("migration %s rolled back: %w", version, verifyErr)
+		}
+
+		run.Duration = time.Since(run.StartedAt)
+		if err := tracker.RecordRunWithOptions(ctx, run, omg.RemoveOptions{}); err != nil {
+			return count, fmt.Errorf("failed to record migration %s: %w", version, err)
+		}
+		if lockPath != "" {
+			if _, err := omg.UpdateModelLock(ctx, client, lockPath); err != nil {
+				return count, fmt.Errorf("failed to update %s: %w", lockPath, err)
+			}
+		}
+
+		// The migration stays applied, but the run stops so the failure is noticed
+		if verifyErr != nil {
+			return count, fmt.Errorf("migration %s applied but %w", version, verifyErr)
+		}
+
+		count++
 	}
-	name := strings.TrimSuffix(parts[1], ".go")
-	return name
+	return count, nil
 }
 
-func runDown(ctx context.Context, client *omg.Client) error {
-	db, err := initMigrationDB()
+// promote lists the migrations applied in the source environment but not in the target,
+// and with -apply runs them against the target like 'omg up-to -env <target>' would
+func promote(ctx context.Context, flagSet *flag.FlagSet, sourceName, targetName string) error {
+	file, err := loadConfigFile()
 	if err != nil {
 		return err
 	}
-	defer db.Close()
+	if file == nil {
+		return fmt.Errorf("promote compares the environments of a config file, but there is no omg.yaml or .omgrc (see -config)")
+	}
 
-	tracker, err := omg.NewTracker(db)
+	source, err := file.Environment(sourceName)
 	if err != nil {
-		return fmt.Errorf("failed to initialize tracker: %w", err)
+		return err
+	}
+	target, err := file.Environment(targetName)
+	if err != nil {
+		return err
 	}
 
-	applied, err := tracker.GetApplied(ctx)
+	sourceTracker, closeSource, err := openEnvTracker(sourceName, source)
+	if err != nil {
+		return err
+	}
+	defer closeSource()
+	targetTracker, closeTarget, err := openEnvTracker(targetName, target)
 	if err != nil {
 		return err
 	}
+	defer closeTarget()
 
-	if len(applied) == 0 {
-		fmt.Println("No migrations to roll back")
+	plan, err := omg.PlanPromotion(ctx, sourceTracker, targetTracker)
+	if err != nil {
+		return err
+	}
+
+	if len(plan.Extra) > 0 {
+		fmt.Printf("Warning: %d migrations are applied in %s but not in %s:\n", len(plan.Extra), targetName, sourceName)
+		for _, migration := range plan.Extra {
+			fmt.Printf("    %-15s  %s\n", migration.Version, migration.Name)
+		}
+		fmt.Println()
+	}
+	if len(plan.Missing) == 0 {
+		fmt.Printf("Nothing to promote. %s has every migration applied in %s\n", targetName, sourceName)
+		return nil
+	}
+
+	fmt.Printf("Applied in %s but not in %s:\n", sourceName, targetName)
+	for _, migration := range plan.Missing {
+		fmt.Printf("    %-15s  %s\n", migration.Version, migration.Name)
+	}
+	if !promoteApply {
+		fmt.Printf("\nRun 'omg promote -apply %s %s' to apply them\n", sourceName, targetName)
 		return nil
 	}
 
-	// Find all migration files in directory
-	pattern := filepath.Join(migrationsDir, "*_*.go")
-	files, err := filepath.Glob(pattern)
+	// From here on the target is the environment, for the migrations run below too
+	applyConfigEnvironment(flagSet, target, true)
+	applyEnvDefaults(flagSet)
+
+	migrationFiles, err := findMigrationFiles()
 	if err != nil {
 		return err
 	}
+	if err := checkPromotion(ctx, plan, migrationFiles, targetTracker, sourceName); err != nil {
+		return err
+	}
 
-	// Filter out non-migration files
-	var migrationFiles []string
-	for _, file := range files {
-		base := filepath.Base(file)
-		if base == "migrations.go" || strings.Contains(base, "example") {
-			continue
+	if !assumeYes {
+		if err := requireInteractive(); err != nil {
+			return err
+		}
+		ok, err := confirm(os.Stdout, fmt.Sprintf("\nApply %d migrations to %s?", len(plan.Missing), targetName), false)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("promotion cancelled")
 		}
-		migrationFiles = append(migrationFiles, file)
 	}
 
-	// Sort by version (timestamp in filename) in reverse order
-	sort.Sort(sort.Reverse(sort.StringSlice(migrationFiles)))
+	client, err := initOpenFGAClient()
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", targetName, err)
+	}
+	fmt.Println()
+	return runUpTo(ctx, client, plan.UpTo())
+}
 
-	// Find the last applied migration
-	var lastMigrationFile string
-	var lastVersion string
-	var lastName string
+// checkPromotion makes sure promoting runs exactly the missing migrations: each must be in
+// the migrations directory, and up-to must not pick up one the source has not applied
+func checkPromotion(ctx context.Context, plan omg.PromotionPlan, migrationFiles []string, target omg.MigrationTracker, sourceName string) error {
+	missing := make(map[string]bool, len(plan.Missing))
+	for _, migration := range plan.Missing {
+		if !hasMigrationVersion(migrationFiles, migration.Version) {
+			return fmt.Errorf("migration %s is applied in %s but not in %s", migration.Version, sourceName, migrationsDir)
+		}
+		missing[migration.Version] = true
+	}
 
+	applied, err := target.GetApplied(ctx)
+	if err != nil {
+		return err
+	}
 	for _, file := range migrationFiles {
 		version := extractVersionFromFilename(file)
-		if _, exists := applied[version]; exists {
-			lastMigrationFile = file
-			lastVersion = version
-			lastName = extractNameFromFilename(file)
+		if version > plan.UpTo() {
 			break
 		}
+		if _, exists := applied[version]; !exists && !missing[version] {
+			return fmt.Errorf("migration %s is not applied in %s; apply it there first, or use up-to to skip the promotion", version, sourceName)
+		}
+	}
+	return nil
+}
+
+// openEnvTracker opens the tracker of a config file environment; call close when done with it
+func openEnvTracker(name string, env omg.EnvironmentConfig) (omg.MigrationTracker, func(), error) {
+	cfg, err := env.ClientConfig()
+	if err != nil {
+		return nil, nil, fmt.Errorf("environment '%s': %w", name, err)
+	}
+	client, err := omg.NewClient(cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to %s: %w", name, err)
 	}
 
-	if lastMigrationFile == "" {
-		fmt.Println("No migrations to roll back")
-		return nil
+	return openTrackerWith(client, env.Tracker, func() (*sql.DB, error) {
+		if env.TrackerURL == "" {
+			return nil, fmt.Errorf("environment '%s' has no tracker_url (or set tracker: tuples)", name)
+		}
+		return connectTrackerDB(env.TrackerURL)
+	})
+}
+
+// baselineMigrations records the migrations up to version as applied without running
+// them, for stores that already have their model. When version is the latest migration,
+// the live model must match the schema snapshot (the model file without one)
+func baselineMigrations(ctx context.Context, client *omg.Client, version string) error {
+	migrationFiles, err := findMigrationFiles()
+	if err != nil {
+		return err
+	}
+	if !hasMigrationVersion(migrationFiles, version) {
+		return fmt.Errorf("no migration with version %s in %s", version, migrationsDir)
+	}
+
+	migrations := make([]omg.Migration, 0, len(migrationFiles))
+	for _, file := range migrationFiles {
+		migrations = append(migrations, omg.Migration{
+			Version: extractVersionFromFilename(file),
+			Name:    extractNameFromFilename(file),
+		})
 	}
+	opts := omg.BaselineOptions{Migrations: migrations}
 
-	fmt.Printf("OK  %s  %s\n", lastVersion, lastName)
+	if latest := migrations[len(migrations)-1].Version; version == latest {
+		expected, source, err := baselineModel()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Comparing the live model with %s...\n", source)
+		opts.Expected = expected
+	} else {
+		fmt.Printf("Not comparing the live model: the schema snapshot includes migrations after %s\n", version)
+	}
 
-	// Run the migration file with 'go run' and 'down' argument
-	cmd := exec.Command("go", "run", lastMigrationFile, "down")
-	cmd.Env = os.Environ()
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	tracker, closeTracker, err := openTracker(client)
+	if err != nil {
+		return err
+	}
+	defer closeTracker()
+
+	// The tuples tracker needs its type in the model before it can record anything
+	if tuples, ok := tracker.(*omg.OpenFGATracker); ok {
+		if err := tuples.EnsureTrackingType(ctx); err != nil {
+			return err
+		}
+	}
 
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("rollback %s failed: %w", lastVersion, err)
+	runs, err := omg.Baseline(ctx, client, tracker, version, opts)
+	var mismatch *omg.ModelMismatchError
+	if errors.As(err, &mismatch) {
+		if !force {
+			return fmt.Errorf("%w\nRun with -force to record the baseline anyway", err)
+		}
+		fmt.Printf("Warning: %v (continuing because of -force)\n", err)
+		opts.Force = true
+		runs, err = omg.Baseline(ctx, client, tracker, version, opts)
+	}
+	for _, run := range runs {
+		fmt.Printf("OK  %s  %s  (baseline)\n", run.Version, run.Name)
+	}
+	if err != nil {
+		return err
 	}
 
-	if err := tracker.Remove(ctx, lastVersion); err != nil {
-		return fmt.Errorf("failed to remove migration record %s: %w", lastVersion, err)
+	if len(runs) == 0 {
+		fmt.Printf("Nothing to record. Every migration up to %s is already applied\n", version)
+	} else {
+		fmt.Printf("\n✓ Recorded %d migrations as applied up to %s\n", len(runs), version)
 	}
 
+	lockPath := modelLockPath()
+	if _, err := omg.UpdateModelLock(ctx, client, lockPath); err != nil {
+		return fmt.Errorf("failed to update %s: %w", lockPath, err)
+	}
 	return nil
 }
 
-func showStatus(ctx context.Context, client *omg.Client) error {
-	db, err := initMigrationDB()
+// baselineModel returns the model the migrations build up to, and where it came from:
+// the schema snapshot of the migrations directory, or the model file without one
+func baselineModel() (*openfgaSdk.AuthorizationModel, string, error) {
+	snapshot, err := omg.ReadSchemaSnapshot(migrationsDir)
 	if err != nil {
-		return err
+		return nil, "", err
+	}
+	if snapshot != nil {
+		return snapshot, omg.SchemaSnapshotPath(migrationsDir), nil
 	}
-	defer db.Close()
 
-	tracker, err := omg.NewTracker(db)
+	dsl, err := omg.LoadCurrentModelFromPath(modelPath)
 	if err != nil {
-		return fmt.Errorf("failed to initialize tracker: %w", err)
+		return nil, "", fmt.Errorf("failed to load %s: %w", modelSourceName(), err)
+	}
+	model, err := omg.ParseModel(dsl)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to parse %s: %w", modelSourceName(), err)
 	}
+	return &model, modelSourceName(), nil
+}
+
+// recordFailedRun adds a failed run to the history; the run's own error is what gets reported
+func recordFailedRun(ctx context.Context, tracker omg.MigrationTracker, run omg.MigrationRun, runErr error) {
+	run.Status = omg.RunStatusFailed
+	run.Duration = time.Since(run.StartedAt)
+	run.Error = runErr.Error()
+	if err := tracker.RecordRunWithOptions(ctx, run, omg.RemoveOptions{}); err != nil {
+		fmt.Printf("Warning: failed to record failed run of %s: %v\n", run.Version, err)
+	}
+}
 
-	// Find all migration files in directory
-	pattern := filepath.Join(migrationsDir, "*_*.go")
-	files, err := filepath.Glob(pattern)
+// verifyMigration runs the "// Verify:" checks declared in a migration file's header
+func verifyMigration(ctx context.Context, client *omg.Client, file string) error {
+	meta, err := omg.ParseMigrationMetadata(file)
 	if err != nil {
 		return err
 	}
+	if len(meta.Checks) == 0 {
+		return nil
+	}
+
+	results, err := omg.VerifyChecks(ctx, client, meta.Checks)
+	for _, result := range results {
+		status := "✓"
+		if !result.Passed() {
+			status = "✗"
+		}
+		fmt.Printf("    %s verify %s\n", status, result.CheckExpectation)
+	}
+	return err
+}
+
+// modelSourceName describes where the desired model is read from, for messages
+func modelSourceName() string {
+	if modelPath == "-" {
+		return "stdin"
+	}
+	return modelPath
+}
+
+// modelLockPath returns the lock file location, next to the model file, or inside the
+// directory of a modular model
+func modelLockPath() string {
+	if info, err := os.Stat(modelPath); err == nil && info.IsDir() {
+		return filepath.Join(modelPath, omg.ModelLockFile)
+	}
+	return filepath.Join(filepath.Dir(modelPath), omg.ModelLockFile)
+}
+
+// findMigrationFiles returns the migration files in migrationsDir sorted by version:
+// Go migrations and declarative ones (.yaml, .yml, .json)
+func findMigrationFiles() ([]string, error) {
+	var files []string
+	for _, ext := range []string{".go", ".yaml", ".yml", ".json"} {
+		matches, err := filepath.Glob(filepath.Join(migrationsDir, "*_*"+ext))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
 
 	// Filter out non-migration files
 	var migrationFiles []string
 	for _, file := range files {
 		base := filepath.Base(file)
-		if base == "migrations.go" || strings.Contains(base, "example") {
+		// Skip migrations.go, example files and generated migration tests
+		if base == "migrations.go" || strings.Contains(base, "example") || strings.HasSuffix(base, "_test.go") {
+			continue
+		}
+		// Skip data files such as the schema snapshot
+		if omg.IsDeclarativeMigration(file) && strings.Trim(extractVersionFromFilename(file), "0123456789") != "" {
 			continue
 		}
 		migrationFiles = append(migrationFiles, file)
@@ -525,402 +1478,2222 @@ func showStatus(ctx context.Context, client *omg.Client) error {
 
 	// Sort by version (timestamp in filename)
 	sort.Strings(migrationFiles)
+	return migrationFiles, nil
+}
+
+// extractVersionFromFilename extracts the version (timestamp) from a migration filename
+// Example: "migrations/20251130123456_add_feature.go" -> "20251130123456"
+func extractVersionFromFilename(filename string) string {
+	base := filepath.Base(filename)
+	parts := strings.SplitN(base, "_", 2)
+	if len(parts) < 2 {
+		return ""
+	}
+	return parts[0]
+}
+
+// extractNameFromFilename extracts the name from a migration filename
+// Example: "migrations/20251130123456_add_feature.go" -> "add_feature"
+func extractNameFromFilename(filename string) string {
+	base := filepath.Base(filename)
+	parts := strings.SplitN(base, "_", 2)
+	if len(parts) < 2 {
+		return ""
+	}
+	name := strings.TrimSuffix(parts[1], filepath.Ext(parts[1]))
+	return name
+}
+
+func runDown(ctx context.Context, client *omg.Client) error {
+	return runDownTo(ctx, client, "", 1)
+}
+
+// runDownTo rolls back applied migrations newest first, stopping at target (which stays
+// applied) or after limit rollbacks (0 for no limit). A target of "0" rolls back everything
+func runDownTo(ctx context.Context, client *omg.Client, target string, limit int) error {
+	tracker, closeTracker, err := openTracker(client)
+	if err != nil {
+		return err
+	}
+	defer closeTracker()
+
+	migrationFiles, err := findMigrationFiles()
+	if err != nil {
+		return err
+	}
+	if target != "" && target != "0" && !hasMigrationVersion(migrationFiles, target) {
+		return fmt.Errorf("no migration with version %s in %s", target, migrationsDir)
+	}
 
 	applied, err := tracker.GetApplied(ctx)
 	if err != nil {
 		return err
 	}
 
-	if len(migrationFiles) == 0 {
-		fmt.Println("No migrations found")
-		return nil
+	// Walk in reverse version order
+	sort.Sort(sort.Reverse(sort.StringSlice(migrationFiles)))
+
+	count := 0
+	for _, file := range migrationFiles {
+		version := extractVersionFromFilename(file)
+		if target != "" && version <= target {
+			break
+		}
+		if _, exists := applied[version]; !exists {
+			continue
+		}
+
+		if err := rollbackMigration(ctx, client, tracker, file); err != nil {
+			return err
+		}
+
+		count++
+		if limit > 0 && count == limit {
+			break
+		}
+	}
+
+	switch {
+	case count == 0:
+		fmt.Println("No migrations to roll back")
+	case target != "":
+		fmt.Printf("\n✓ Rolled back %d migrations to %s\n", count, target)
+	}
+	return nil
+}
+
+// rollbackMigration runs a migration file's down function and records the rollback
+func rollbackMigration(ctx context.Context, client *omg.Client, tracker omg.MigrationTracker, file string) error {
+	version := extractVersionFromFilename(file)
+	name := extractNameFromFilename(file)
+
+	if !modelOnlyMode() {
+		if err := confirmRemovals(ctx, client, file, omg.DirectionDown); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("OK  %s  %s\n", version, name)
+
+	if journal, ok := tracker.(omg.RenameJournal); ok {
+		ctx = omg.WithRenameJournal(ctx, journal)
+	}
+	run := omg.MigrationRun{
+		Version:   version,
+		Name:      name,
+		Status:    omg.RunStatusRolledBack,
+		StartedAt: time.Now(),
+		AppliedBy: omg.CurrentOperator(),
+	}
+
+	if err := runMigrationFile(ctx, client, file, "down"); err != nil {
+		return fmt.Errorf("rollback %s failed: %w", version, err)
+	}
+
+	run.Duration = time.Since(run.StartedAt)
+	if err := tracker.RecordRunWithOptions(ctx, run, omg.RemoveOptions{Purge: purge}); err != nil {
+		return fmt.Errorf("failed to remove migration record %s: %w", version, err)
+	}
+
+	// The next up checks the live model against the lock
+	lockPath := modelLockPath()
+	if _, err := omg.UpdateModelLock(ctx, client, lockPath); err != nil {
+		return fmt.Errorf("failed to update %s: %w", lockPath, err)
+	}
+	return nil
+}
+
+// runMigrationFile runs a migration file with 'go run' in the given direction
+// Migrations generated with -package have no main() and are run by omg.Run instead.
+// Declarative migrations are run in-process
+func runMigrationFile(ctx context.Context, client *omg.Client, file, direction string) error {
+	if omg.IsDeclarativeMigration(file) {
+		migration, err := omg.LoadDeclarativeMigration(file)
+		if err != nil {
+			return err
+		}
+		if resume {
+			ctx = omg.WithResume(ctx)
+		}
+		if verifyRenames {
+			ctx = omg.WithVerifiedRenames(ctx)
+		}
+		if direction == "down" {
+			return migration.Down(ctx, client)
+		}
+		return migration.Up(ctx, client)
+	}
+
+	meta, err := omg.ParseMigrationMetadata(file)
+	if err != nil {
+		return err
+	}
+	if meta.Package != "main" {
+		return fmt.Errorf("%s registers itself with omg.Register; run it in-process from your binary with omg.Run", file)
+	}
+
+	cmd := exec.Command("go", "run", file, direction)
+	cmd.Env = migrationEnv() // Pass through all environment variables
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// hasMigrationVersion reports whether one of the migration files has the given version
+func hasMigrationVersion(migrationFiles []string, version string) bool {
+	for _, file := range migrationFiles {
+		if extractVersionFromFilename(file) == version {
+			return true
+		}
+	}
+	return false
+}
+
+func showStatus(ctx context.Context, client *omg.Client) error {
+	if err := checkFormat("status", "json", "table", "plain"); err != nil {
+		return err
+	}
+
+	tracker, closeTracker, err := openTracker(client)
+	if err != nil {
+		return err
+	}
+	defer closeTracker()
+
+	migrationFiles, err := findMigrationFiles()
+	if err != nil {
+		return err
+	}
+
+	applied, err := tracker.GetApplied(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Only the Postgres tracker remembers rollbacks
+	rolledBack := map[string]omg.MigrationInfo{}
+	if pgTracker, ok := tracker.(*omg.Tracker); ok {
+		if rolledBack, err = pgTracker.GetRolledBack(ctx); err != nil {
+			return err
+		}
+	}
+
+	switch outputFormat {
+	case "json":
+		return printStatusJSON(ctx, client, migrationFiles, applied, rolledBack)
+	case "plain":
+		for _, file := range migrationFiles {
+			version := extractVersionFromFilename(file)
+			status := "pending"
+			if _, exists := applied[version]; exists {
+				status = "applied"
+			}
+			fmt.Printf("%s\t%s\t%s\n", version, extractNameFromFilename(file), status)
+		}
+		return nil
+	}
+
+	if err := showModelStatus(ctx, client); err != nil {
+		return err
+	}
+	fmt.Println()
+
+	if len(migrationFiles) == 0 {
+		fmt.Println("No migrations found")
+		return nil
+	}
+
+	fmt.Printf("Migration status for directory '%s'\n", migrationsDir)
+	for _, file := range migrationFiles {
+		version := extractVersionFromFilename(file)
+		name := extractNameFromFilename(file)
+
+		status := "Pending"
+		if info, exists := applied[version]; exists {
+			status = fmt.Sprintf("Applied At: %s", info.AppliedAt.Format("Mon Jan  2 15:04:05 2006"))
+		} else if info, exists := rolledBack[version]; exists {
+			status = fmt.Sprintf("Pending (rolled back at %s)", info.RolledBackAt.Format("Mon Jan  2 15:04:05 2006"))
+		}
+		fmt.Printf("    %-15s  %-40s  %s\n", version, name, status)
+	}
+
+	return nil
+}
+
+// statusReport is the output of 'omg status -format json'
+type statusReport struct {
+	StoreID    string            `json:"store_id"`
+	Model      *omg.ModelStatus  `json:"model"` // Null when the store has no model
+	InSync     bool              `json:"in_sync"`
+	Applied    int               `json:"applied"`
+	Pending    int               `json:"pending"`
+	Migrations []migrationReport `json:"migrations"`
+}
+
+// migrationReport is one migration in a statusReport
+type migrationReport struct {
+	Version      string     `json:"version"`
+	Name         string     `json:"name"`
+	Status       string     `json:"status"` // applied or pending
+	AppliedAt    *time.Time `json:"applied_at,omitempty"`
+	RolledBackAt *time.Time `json:"rolled_back_at,omitempty"`
+}
+
+// printStatusJSON prints the model and migration status as JSON
+func printStatusJSON(ctx context.Context, client *omg.Client, migrationFiles []string, applied, rolledBack map[string]omg.MigrationInfo) error {
+	modelStatus, err := getModelStatus(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	report := statusReport{StoreID: client.GetStoreID(), Migrations: []migrationReport{}}
+	if modelStatus.HasModel() {
+		report.Model = &modelStatus
+		report.InSync = modelStatus.InSync()
+	}
+
+	for _, file := range migrationFiles {
+		version := extractVersionFromFilename(file)
+		migration := migrationReport{Version: version, Name: extractNameFromFilename(file), Status: "pending"}
+		if info, exists := applied[version]; exists {
+			migration.Status = "applied"
+			migration.AppliedAt = &info.AppliedAt
+			report.Applied++
+		} else {
+			if info, exists := rolledBack[version]; exists {
+				migration.RolledBackAt = &info.RolledBackAt
+			}
+			report.Pending++
+		}
+		report.Migrations = append(report.Migrations, migration)
+	}
+
+	return printJSON(report)
+}
+
+// getModelStatus compares the store's latest model with the model file, if there is one
+func getModelStatus(ctx context.Context, client *omg.Client) (omg.ModelStatus, error) {
+	modelDSL, err := omg.LoadCurrentModelFromPath(modelPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return omg.ModelStatus{}, err
+	}
+
+	status, err := omg.GetModelStatus(ctx, client, modelDSL)
+	if err != nil {
+		return omg.ModelStatus{}, fmt.Errorf("failed to get model status: %w", err)
+	}
+
+	// The schema snapshot drifts from the store when migrations are generated but not applied,
+	// or when the store is changed outside of omg
+	snapshot, err := omg.ReadSchemaSnapshot(migrationsDir)
+	if err != nil {
+		return omg.ModelStatus{}, err
+	}
+	if snapshot != nil {
+		status.SnapshotHash = omg.HashModelState(omg.BuildModelStateFromAuthorizationModel(*snapshot))
+	}
+	return status, nil
+}
+
+// showModelStatus prints the store's latest model and whether it matches the model file
+func showModelStatus(ctx context.Context, client *omg.Client) error {
+	status, err := getModelStatus(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Store:           %s\n", client.GetStoreID())
+	if !status.HasModel() {
+		fmt.Println("Model:           (none)")
+		return nil
+	}
+	fmt.Printf("Model ID:        %s\n", status.ModelID)
+	fmt.Printf("Schema version:  %s\n", status.SchemaVersion)
+	fmt.Printf("Model hash:      %s\n", status.LiveHash)
+
+	switch {
+	case status.FileHash == "":
+		fmt.Printf("%-17s(not found)\n", modelSourceName()+":")
+	case status.InSync():
+		fmt.Printf("%-17s✓ in sync\n", modelSourceName()+":")
+	default:
+		fmt.Printf("%-17s✗ differs (%s) - run 'omg diff'\n", modelSourceName()+":", status.FileHash)
+	}
+
+	switch {
+	case status.SnapshotHash == "":
+	case status.SnapshotInSync():
+		fmt.Printf("%-17s✓ in sync\n", "Schema snapshot:")
+	default:
+		fmt.Printf("%-17s✗ differs (%s) - pending migrations or out-of-band changes\n", "Schema snapshot:", status.SnapshotHash)
+	}
+	return nil
+}
+
+// restoreBackup restores the tuples (and definitions) a migration backed up before removing them
+// target optionally limits the restore to one "type" or "type#relation" backup
+func restoreBackup(ctx context.Context, client *omg.Client, version, target string) error {
+	if target == "" {
+		fmt.Printf("Restoring all backups for migration %s...\n", version)
+		if err := omg.RestoreVersionBackups(ctx, client, version); err != nil {
+			return err
+		}
+	} else {
+		objectType, relation, _ := strings.Cut(target, "#")
+		path := omg.BackupFilePath(version, objectType, relation)
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("no backup for %s in migration %s: %w", target, version, err)
+		}
+		if err := omg.RestoreFromBackup(ctx, client, path); err != nil {
+			return err
+		}
+	}
+
+	fmt.Println("✓ Backup restored")
+	fmt.Println("NOTE: The migration is still recorded as applied; run 'omg status' to review")
+	return nil
+}
+
+// formatModels rewrites model files, DSL or JSON, in canonical form and returns those that
+// were not formatted; with -check they are only listed. A file of "-" is formatted to stdout
+func formatModels(files []string) ([]string, error) {
+	var unformatted []string
+	for _, file := range files {
+		if filepath.Base(file) == omg.ModelManifestFile {
+			return nil, fmt.Errorf("%s: modular models are not formatted; format the module files with the fga CLI", file)
+		}
+		dsl, err := omg.LoadCurrentModelFromPath(file)
+		if err != nil {
+			return nil, err
+		}
+		format := omg.FormatDSL
+		if omg.IsJSONModel(dsl) {
+			format = func(content string) (string, error) { return omg.ConvertModel(content, "json") }
+		}
+		formatted, err := format(dsl)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+
+		if file == "-" && !checkOnly {
+			fmt.Print(formatted)
+			continue
+		}
+		if formatted == dsl {
+			continue
+		}
+		unformatted = append(unformatted, file)
+
+		if checkOnly {
+			fmt.Printf("%s is not formatted (run 'omg fmt %s')\n", file, file)
+			continue
+		}
+		if err := os.WriteFile(file, []byte(formatted), 0644); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", file, err)
+		}
+		fmt.Printf("Formatted %s\n", file)
+	}
+	return unformatted, nil
+}
+
+func showHistory(ctx context.Context) error {
+	// Only the store ID is needed; creating the client does not contact OpenFGA
+	client, err := initOpenFGAClient()
+	if err != nil {
+		return fmt.Errorf("failed to create client: %w", err)
+	}
+
+	db, err := initMigrationDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tracker, err := omg.NewTrackerForStore(db, client.GetStoreID())
+	if err != nil {
+		return fmt.Errorf("failed to initialize tracker: %w", err)
+	}
+
+	runs, err := tracker.History(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(runs) == 0 {
+		fmt.Println("No migration runs recorded")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tSTARTED AT\tDURATION\tBY")
+	for _, run := range runs {
+		duration := "-"
+		if run.Duration > 0 {
+			duration = run.Duration.Round(time.Millisecond).String()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", run.Version, run.Name, run.Status,
+			run.StartedAt.Format("2006-01-02 15:04:05"), duration, run.AppliedBy)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	for _, run := range runs {
+		if run.Error != "" {
+			fmt.Printf("\n%s failed at %s: %s\n", run.Version, run.StartedAt.Format("2006-01-02 15:04:05"), run.Error)
+		}
+	}
+	return nil
+}
+
+func showChangelog(ctx context.Context) error {
+	// Only the store ID is needed; creating the client does not contact OpenFGA
+	client, err := initOpenFGAClient()
+	if err != nil {
+		return fmt.Errorf("failed to create client: %w", err)
+	}
+
+	db, err := initMigrationDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tracker, err := omg.NewTrackerForStore(db, client.GetStoreID())
+	if err != nil {
+		return fmt.Errorf("failed to initialize tracker: %w", err)
+	}
+
+	applied, err := tracker.GetApplied(ctx)
+	if err != nil {
+		return err
+	}
+
+	migrationFiles, err := findMigrationFiles()
+	if err != nil {
+		return err
+	}
+
+	// Read descriptions and change summaries from the migration files
+	metadata := make(map[string]omg.MigrationMetadata)
+	for _, file := range migrationFiles {
+		version := extractVersionFromFilename(file)
+		if _, exists := applied[version]; !exists {
+			continue
+		}
+		meta, err := omg.ParseMigrationMetadata(file)
+		if err != nil {
+			return err
+		}
+		metadata[version] = meta
+	}
+
+	format := outputFormat
+	if format == "" {
+		format = "markdown"
+	}
+
+	changelog, err := omg.RenderChangelog(omg.BuildChangelog(applied, metadata), format)
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(changelog)
+	return nil
+}
+
+func createMigration(name string) error {
+	opts := omg.GenerateOptions{
+		Package:  migrationPackage,
+		Template: migrationTemplate(),
+	}
+
+	var filename string
+	if seedPath != "" {
+		tuples, err := omg.ReadTupleFile(seedPath)
+		if err != nil {
+			return err
+		}
+		if filename, err = omg.GenerateSeedMigration(name, migrationsDir, tuples, opts); err != nil {
+			return err
+		}
+	} else {
+		var err error
+		if filename, err = omg.GenerateScaffoldWithOptions(name, migrationsDir, opts); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Created migration file: %s\n", filename)
+	return nil
+}
+
+// migrationTemplate returns the template migration files are laid out with: -template,
+// or the project's template file when it exists. Empty uses the default layout
+func migrationTemplate() string {
+	if templatePath != "" {
+		return templatePath
+	}
+	if _, err := os.Stat(omg.MigrationTemplateFile); err == nil {
+		return omg.MigrationTemplateFile
+	}
+	return ""
+}
+
+func listTuples(ctx context.Context, client *omg.Client, filter string) error {
+	if err := checkFormat("list-tuples", "json", "table", "plain"); err != nil {
+		return err
+	}
+
+	req := omg.ReadTuplesRequest{}
+
+	if filter != "" {
+		req.Object = filter + ":"
+	}
+
+	tuples, err := client.ReadAllTuples(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	switch outputFormat {
+	case "json":
+		if tuples == nil {
+			tuples = []omg.Tuple{}
+		}
+		return printJSON(tuples)
+	case "plain":
+		for _, tuple := range tuples {
+			fmt.Printf("%s\t%s\t%s\n", tuple.User, tuple.Relation, tuple.Object)
+		}
+		return nil
+	}
+
+	fmt.Printf("Found %d tuples:\n\n", len(tuples))
+
+	for _, tuple := range tuples {
+		fmt.Printf("%s  %s  %s\n", tuple.User, tuple.Relation, tuple.Object)
+	}
+
+	return nil
+}
+
+// exportTracker writes the tracker's records to file ("-" for stdout)
+func exportTracker(ctx context.Context, client *omg.Client, file string) error {
+	tracker, closeTracker, err := openTracker(client)
+	if err != nil {
+		return err
+	}
+	defer closeTracker()
+
+	export, err := omg.ExportTracker(ctx, tracker, client.GetStoreID())
+	if err != nil {
+		return err
+	}
+	data, err := export.JSON()
+	if err != nil {
+		return err
+	}
+
+	if file == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(file, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", file, err)
+	}
+	fmt.Printf("Exported %d applied migrations and %d runs to %s\n", len(export.Applied), len(export.History), file)
+	return nil
+}
+
+// importTracker records the applied migrations of an export file
+// adoptTrackerRows assigns the tracker rows recorded without a store ID to the current store
+func adoptTrackerRows(ctx context.Context, client *omg.Client) error {
+	if trackerKind == "tuples" {
+		return fmt.Errorf("the tuples tracker is always scoped to its store; adopt applies to the postgres tracker")
+	}
+
+	db, err := initMigrationDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tracker, err := omg.NewTrackerForStore(db, client.GetStoreID())
+	if err != nil {
+		return fmt.Errorf("failed to initialize tracker: %w", err)
+	}
+
+	unscoped, err := tracker.UnscopedMigrations(ctx)
+	if err != nil {
+		return err
+	}
+	if unscoped == 0 {
+		fmt.Println("No migrations recorded without a store ID")
+		return nil
+	}
+
+	if !assumeYes {
+		if err := requireInteractive(); err != nil {
+			return err
+		}
+		ok, err := confirm(os.Stdout, fmt.Sprintf("Assign %d migrations recorded without a store ID to store %s?",
+			unscoped, client.GetStoreID()), false)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("adopt cancelled")
+		}
+	}
+
+	adopted, err := tracker.AdoptUnscopedRows(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("✓ Assigned %d migrations to store %s\n", adopted, client.GetStoreID())
+	return nil
+}
+
+func importTracker(ctx context.Context, client *omg.Client, file string) error {
+	export, err := omg.ReadTrackerExport(file)
+	if err != nil {
+		return err
+	}
+	if export.StoreID != "" && export.StoreID != client.GetStoreID() {
+		fmt.Printf("Warning: %s was exported from store %s, importing into %s\n", file, export.StoreID, client.GetStoreID())
+	}
+
+	tracker, closeTracker, err := openTracker(client)
+	if err != nil {
+		return err
+	}
+	defer closeTracker()
+
+	// Provisioning changes the model, so keep an up-to-date model.lock up to date
+	if tuples, ok := tracker.(*omg.OpenFGATracker); ok {
+		lockPath := modelLockPath()
+		lock, err := omg.ReadModelLock(lockPath)
+		if err != nil {
+			return err
+		}
+		lockMatched := lock != nil && omg.VerifyModelLock(ctx, client, lockPath) == nil
+
+		if err := tuples.EnsureTrackingType(ctx); err != nil {
+			return err
+		}
+		if lockMatched {
+			if _, err := omg.UpdateModelLock(ctx, client, lockPath); err != nil {
+				return fmt.Errorf("failed to update %s: %w", lockPath, err)
+			}
+		}
+	}
+
+	result, err := omg.ImportTracker(ctx, tracker, export)
+	for _, version := range result.Imported {
+		fmt.Printf("OK  %s  imported\n", version)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\n✓ Imported %d migrations (%d already applied)\n", len(result.Imported), len(result.Skipped))
+	if len(result.Extra) > 0 {
+		fmt.Printf("Note: %d migrations applied here are not in the export and were left alone: %s\n",
+			len(result.Extra), strings.Join(result.Extra, ", "))
+	}
+	return nil
+}
+
+// planChanges writes the changes between the store and the model file, with the operations
+// apply runs for them and the live model's hash, to a plan file (stdout for "-")
+func planChanges(ctx context.Context, client *omg.Client, file string) error {
+	out := io.Writer(os.Stdout)
+	if file == "-" {
+		out = os.Stderr
+	}
+
+	// Taken before detection, so a model change in between makes the plan stale, not wrong
+	source, err := omg.LoadModelLockFromOpenFGA(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	changes, raw, target, err := detectGenerateChanges(out, false)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		fmt.Fprintf(out, "No changes detected - the store matches %s\n", modelSourceName())
+		return nil
+	}
+
+	changes, err = confirmChanges(out, changes, raw)
+	if err != nil {
+		return err
+	}
+	printModelOnlyWarnings(out, changes)
+
+	plan := omg.NewExecutionPlanWithOptions(client.GetStoreID(), source, omg.BuildModelState(*target), changes, omg.GenerateOptions{ModelOnly: modelOnlyMode()})
+	printPlan(out, plan)
+
+	data, err := plan.JSON()
+	if err != nil {
+		return err
+	}
+	if file == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(file, data, 0644); err != nil {
+		return fmt.Errorf("failed to write plan: %w", err)
+	}
+	fmt.Fprintf(out, "\n✓ Plan written to %s\nReview it, then run: omg apply %s\n", file, file)
+	return nil
+}
+
+// printPlan lists a plan's changes in apply order with the operations each one runs
+func printPlan(out io.Writer, plan *omg.ExecutionPlan) {
+	fmt.Fprintf(out, "\nPlan %s for store %s (model %s):\n", plan.ID, plan.StoreID, plan.SourceModelID)
+	for i, change := range plan.Changes {
+		review := ""
+		if change.Review {
+			review = "  [review]"
+		}
+		fmt.Fprintf(out, "  %d. %s%s\n", i+1, change.Details, review)
+		fmt.Fprintf(out, "     %s\n", strings.Join(change.Operations, ", "))
+	}
+}
+
+// applyPlanFile applies a plan written by 'omg plan' after checking it is not stale
+func applyPlanFile(ctx context.Context, client *omg.Client, file string) error {
+	plan, err := omg.ReadExecutionPlan(file)
+	if err != nil {
+		return err
+	}
+	if err := omg.VerifyExecutionPlan(ctx, client, plan); err != nil {
+		return err
+	}
+
+	printPlan(os.Stdout, plan)
+	if !assumeYes {
+		if err := requireInteractive(); err != nil {
+			return err
+		}
+		ok, err := confirm(os.Stdout, fmt.Sprintf("\nApply %d changes?", len(plan.Changes)), false)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("aborted: nothing was applied")
+		}
+	}
+
+	if err := omg.ApplyExecutionPlan(ctx, client, plan); err != nil {
+		return err
+	}
+	fmt.Printf("\n✓ Applied %d changes from %s\n", len(plan.Changes), file)
+
+	lockPath := modelLockPath()
+	if _, err := omg.UpdateModelLock(ctx, client, lockPath); err != nil {
+		return fmt.Errorf("failed to update %s: %w", lockPath, err)
+	}
+	return nil
+}
+
+// readyTimeout bounds how long 'omg ready' waits on OpenFGA and the tracker database
+const readyTimeout = 10 * time.Second
+
+// checkReady returns why the deployment is not ready to serve, or nil
+// authCheck validates the client's credentials against the token issuer and the store
+// Client creation already fetched a client_credentials token, so issuer errors surface there
+func authCheck(ctx context.Context, client *omg.Client) error {
+	status, err := client.CheckAuth(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Auth method:   %s\n", status.Method)
+	if status.TokenURL != "" {
+		fmt.Printf("Token issuer:  %s\n", status.TokenURL)
+		if status.TokenExpiry.IsZero() {
+			fmt.Println("Token expiry:  never")
+		} else {
+			fmt.Printf("Token expiry:  %s (in %s)\n", status.TokenExpiry.Local().Format(time.RFC3339), time.Until(status.TokenExpiry).Round(time.Second))
+		}
+	}
+	fmt.Printf("Store:         %s\n", status.StoreID)
+	fmt.Println("✓ Credentials are valid")
+	return nil
+}
+
+func checkReady(ctx context.Context, client *omg.Client) error {
+	ctx, cancel := context.WithTimeout(ctx, readyTimeout)
+	defer cancel()
+
+	exists, err := client.CheckStore(ctx)
+	if err != nil {
+		return fmt.Errorf("OpenFGA unreachable: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("store %s does not exist", client.GetStoreID())
+	}
+
+	tracker, closeTracker, err := openTracker(client)
+	if err != nil {
+		return err
+	}
+	defer closeTracker()
+
+	applied, err := tracker.GetApplied(ctx)
+	if err != nil {
+		return err
+	}
+
+	migrationFiles, err := findMigrationFiles()
+	if err != nil {
+		return err
+	}
+
+	var pending []string
+	for _, file := range migrationFiles {
+		version := extractVersionFromFilename(file)
+		if _, ok := applied[version]; !ok {
+			pending = append(pending, version)
+		}
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%d pending migrations (%s)", len(pending), strings.Join(pending, ", "))
+	}
+	return nil
+}
+
+func accessReport(ctx context.Context, client *omg.Client, usersPath, object string) error {
+	users, err := omg.ReadUserList(usersPath)
+	if err != nil {
+		return err
+	}
+	if len(users) == 0 {
+		return fmt.Errorf("no users in %s", usersPath)
+	}
+
+	matrix, err := omg.AccessReport(ctx, client, users, object)
+	if err != nil {
+		return err
+	}
+
+	switch outputFormat {
+	case "", "table":
+		fmt.Printf("Access to %s:\n\n", object)
+		return matrix.WriteTable(os.Stdout)
+	case "csv":
+		return matrix.WriteCSV(os.Stdout)
+	default:
+		return fmt.Errorf("unknown format '%s' (expected table or csv)", outputFormat)
+	}
+}
+
+// repairRename lists the renames the tracker's journal shows were interrupted, or
+// finishes or rolls back the one args name
+func repairRename(ctx context.Context, client *omg.Client, args []string) error {
+	tracker, closeTracker, err := openTracker(client)
+	if err != nil {
+		return err
+	}
+	defer closeTracker()
+	journal, ok := tracker.(omg.RenameJournal)
+	if !ok {
+		return fmt.Errorf("the tuples tracker keeps no rename journal; renames are journaled with -tracker postgres")
+	}
+
+	if len(args) == 0 {
+		pending, err := journal.PendingRenames(ctx)
+		if err != nil {
+			return err
+		}
+		if len(pending) == 0 {
+			fmt.Println("No interrupted renames")
+			return nil
+		}
+		for _, entry := range pending {
+			fmt.Printf("  %s  (%s phase since %s", entry.Operation, entry.Phase, entry.RecordedAt.Local().Format("2006-01-02 15:04:05"))
+			if entry.Tuples > 0 {
+				fmt.Printf(", %d tuples", entry.Tuples)
+			}
+			fmt.Println(")")
+		}
+		fmt.Println("Finish one with 'omg repair-rename finish <operation>'; renames that were not deleting yet")
+		fmt.Println("can be undone with 'omg repair-rename rollback <operation>'")
+		return nil
+	}
+
+	operation := strings.Join(args[1:], " ")
+	if !assumeYes {
+		if err := requireInteractive(); err != nil {
+			return err
+		}
+		prompt := "Finish " + operation + "?"
+		if args[0] == "rollback" {
+			prompt = "Roll back " + operation + "?"
+		}
+		ok, err := confirm(os.Stdout, prompt, false)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("repair cancelled")
+		}
+	}
+	if args[0] == "rollback" {
+		if err := omg.RollBackRename(ctx, client, journal, operation); err != nil {
+			return err
+		}
+		fmt.Printf("✓ Rolled back %s\n", operation)
+		return nil
+	}
+	if err := omg.FinishRename(ctx, client, journal, operation); err != nil {
+		return err
+	}
+	fmt.Printf("✓ Finished %s\n", operation)
+	return nil
+}
+
+func expireTuples(ctx context.Context, client *omg.Client) error {
+	db, err := initMigrationDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tracker, err := omg.NewTrackerForStore(db, client.GetStoreID())
+	if err != nil {
+		return fmt.Errorf("failed to initialize tracker: %w", err)
+	}
+
+	if dryRun {
+		expired, err := tracker.ExpiredTuples(ctx, time.Now())
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%d tuples have expired:\n", len(expired))
+		for _, expiry := range expired {
+			fmt.Printf("  %s  %s  %s  (expired %s)\n", expiry.Tuple.User, expiry.Tuple.Relation, expiry.Tuple.Object,
+				expiry.ExpiresAt.Local().Format("2006-01-02 15:04:05"))
+		}
+		fmt.Println("Dry run: nothing deleted")
+		return nil
+	}
+
+	deleted, err := omg.ExpireTuples(ctx, client, tracker, time.Now())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("✓ Deleted %d expired tuples\n", len(deleted))
+	return nil
+}
+
+func takeSnapshot(ctx context.Context, client *omg.Client, path string) error {
+	snapshot, err := omg.TakeSnapshot(ctx, client)
+	if err != nil {
+		return err
+	}
+	if err := omg.WriteSnapshot(path, snapshot); err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Snapshot of store %s written to %s (%d tuples)\n", snapshot.StoreID, path, len(snapshot.Tuples))
+	return nil
+}
+
+func importTuples(ctx context.Context, client *omg.Client, path string) error {
+	tuples, err := omg.ReadTupleFile(path)
+	if err != nil {
+		return err
+	}
+
+	if !importDiff {
+		fmt.Printf("Importing %d tuples from %s\n", len(tuples), path)
+		if err := omg.WriteTuplesBatchWithOptions(ctx, client, tuples, omg.WriteOptions{Deduplicate: true}); err != nil {
+			return err
+		}
+		fmt.Printf("✓ Imported %d tuples\n", len(tuples))
+		return nil
+	}
+
+	diff, err := omg.DiffTuplesInScope(ctx, client, tuples)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s: %d to write, %d to delete, %d unchanged\n", path, len(diff.Missing), len(diff.Extraneous), diff.Unchanged)
+	printTuplePreview("+", diff.Missing)
+	printTuplePreview("-", diff.Extraneous)
+
+	if diff.IsEmpty() {
+		fmt.Println("✓ Store already matches the file")
+		return nil
+	}
+	if dryRun {
+		fmt.Println("Dry run: nothing changed")
+		return nil
+	}
+	if len(diff.Extraneous) > 0 && !assumeYes {
+		if err := confirmDestructive(fmt.Sprintf("Type 'yes' to delete %d tuples: ", len(diff.Extraneous)), "yes"); err != nil {
+			return err
+		}
+	}
+
+	if err := omg.ApplyTupleDiff(ctx, client, diff); err != nil {
+		return err
+	}
+	fmt.Printf("✓ Wrote %d tuples, deleted %d\n", len(diff.Missing), len(diff.Extraneous))
+	return nil
+}
+
+// seedTuples writes the tuples of a file that are not in the store yet, for ad-hoc
+// environments; tuples already there are left alone, so seeding twice is harmless
+func seedTuples(ctx context.Context, client *omg.Client, path string) error {
+	tuples, err := omg.ReadTupleFile(path)
+	if err != nil {
+		return err
+	}
+
+	diff, err := omg.DiffTuplesInScope(ctx, client, tuples)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s: %d to write, %d already in the store\n", path, len(diff.Missing), diff.Unchanged)
+	printTuplePreview("+", diff.Missing)
+
+	if len(diff.Missing) == 0 {
+		fmt.Println("✓ Nothing to seed")
+		return nil
+	}
+	if dryRun {
+		fmt.Println("Dry run: nothing changed")
+		return nil
+	}
+
+	if err := omg.WriteTuplesBatch(ctx, client, diff.Missing); err != nil {
+		return err
+	}
+	fmt.Printf("✓ Seeded %d tuples\n", len(diff.Missing))
+	return nil
+}
+
+// printTuplePreview prints up to prunePreviewLimit tuples, each prefixed with marker
+func printTuplePreview(marker string, tuples []omg.Tuple) {
+	for i, tuple := range tuples {
+		if i == prunePreviewLimit {
+			fmt.Printf("  %s ... and %d more\n", marker, len(tuples)-prunePreviewLimit)
+			return
+		}
+		fmt.Printf("  %s %s  %s  %s\n", marker, tuple.User, tuple.Relation, tuple.Object)
+	}
+}
+
+// prunePreviewLimit is how many tuples prune-tuples shows before deleting
+const prunePreviewLimit = 10
+
+func pruneTuples(ctx context.Context, client *omg.Client, objectType, relation string) error {
+	target := objectType
+	if relation != "" {
+		target += "#" + relation
+	}
+
+	tuples, err := omg.ReadAllTuples(ctx, client, objectType, relation)
+	if err != nil {
+		return err
+	}
+	if len(tuples) == 0 {
+		fmt.Printf("No tuples found for %s\n", target)
+		return nil
+	}
+
+	fmt.Printf("Found %d tuples for %s:\n\n", len(tuples), target)
+	for i, tuple := range tuples {
+		if i == prunePreviewLimit {
+			fmt.Printf("  ... and %d more\n", len(tuples)-prunePreviewLimit)
+			break
+		}
+		fmt.Printf("  %s  %s  %s\n", tuple.User, tuple.Relation, tuple.Object)
+	}
+	fmt.Println()
+
+	if dryRun {
+		fmt.Println("Dry run: nothing deleted")
+		return nil
+	}
+
+	if !assumeYes {
+		if err := confirmDestructive(fmt.Sprintf("Type '%s' to delete %d tuples: ", objectType, len(tuples)), objectType); err != nil {
+			return err
+		}
+	}
+
+	// Same backup as a removal migration, so 'omg restore-backup' can undo it
+	version := "prune-" + time.Now().UTC().Format("20060102150405")
+	path, err := omg.BackupForRemoval(ctx, client, version, objectType, relation)
+	if err != nil {
+		return fmt.Errorf("failed to back up tuples: %w", err)
+	}
+
+	if err := omg.DeleteTuplesBatchWithOptions(ctx, client, tuples, omg.DeleteOptions{Verify: true}); err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Deleted %d tuples for %s\n", len(tuples), target)
+	fmt.Printf("Backup: %s (undo with 'omg restore-backup %s %s')\n", path, version, target)
+	return nil
+}
+
+// confirmDestructive asks the user to type expected before a destructive operation
+// Without a terminal on stdin there is nobody to ask, so -yes is required
+func confirmDestructive(prompt, expected string) error {
+	if err := requireInteractive(); err != nil {
+		return err
+	}
+
+	fmt.Print(prompt)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to read confirmation: %w", err)
+	}
+	if strings.TrimSpace(answer) != expected {
+		return fmt.Errorf("confirmation did not match '%s', aborting", expected)
+	}
+	return nil
+}
+
+// modelOnlyMode reports whether the store's tuples are managed by the application, so
+// omg changes the model only and never reads or writes tuples
+func modelOnlyMode() bool {
+	return modelOnly || storeMode == "model-only"
+}
+
+// printModelOnlyWarnings lists the changes whose tuples the application has to migrate
+// itself in model-only mode
+func printModelOnlyWarnings(out io.Writer, changes []omg.ModelChange) {
+	if !modelOnlyMode() {
+		return
+	}
+	warnings := omg.ModelOnlyWarnings(changes)
+	if len(warnings) == 0 {
+		return
+	}
+	fmt.Fprintln(out, "\nModel-only mode: these changes need a data migration in your application:")
+	for _, warning := range warnings {
+		fmt.Fprintf(out, "  Warning: %s\n", warning)
+	}
+}
+
+// interactive reports whether the user can be prompted: stdin is a terminal and
+// -non-interactive is not set
+func interactive() bool {
+	if nonInteractive {
+		return false
+	}
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// requireInteractive explains why a confirmation cannot be asked for
+func requireInteractive() error {
+	if nonInteractive {
+		return fmt.Errorf("refusing to continue without confirmation in -non-interactive mode (pass -yes)")
+	}
+	if !interactive() {
+		return fmt.Errorf("refusing to continue without confirmation: stdin is not a terminal (pass -yes)")
+	}
+	return nil
+}
+
+// confirm asks a yes/no question on out; an empty answer picks defaultYes
+func confirm(out io.Writer, prompt string, defaultYes bool) (bool, error) {
+	choices := " [y/N] "
+	if defaultYes {
+		choices = " [Y/n] "
+	}
+	fmt.Fprint(out, prompt+choices)
+
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, fmt.Errorf("failed to read confirmation: %w", err)
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "":
+		return defaultYes, nil
+	case "y", "yes":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// confirmRemovals lists the types and relations a migration removes with their tuple
+// counts, and asks before running it. Without a terminal the migration runs as before,
+// unless -non-interactive asks for strict confirmation
+func confirmRemovals(ctx context.Context, client *omg.Client, file string, direction omg.Direction) error {
+	removals, err := omg.FindRemovals(file, direction)
+	if err != nil || len(removals) == 0 {
+		return err
+	}
+
+	total := 0
+	var lines []string
+	for _, removal := range removals {
+		count, err := omg.CountTuples(ctx, client, removal.Type, removal.Relation)
+		if err != nil {
+			return fmt.Errorf("failed to count tuples of %s: %w", removal, err)
+		}
+		total += count
+		lines = append(lines, fmt.Sprintf("      - %s (%d tuples)", removal, count))
+	}
+	if total == 0 || assumeYes {
+		return nil
+	}
+
+	fmt.Printf("    %s removes:\n%s\n", filepath.Base(file), strings.Join(lines, "\n"))
+	if !interactive() {
+		if nonInteractive {
+			return requireInteractive()
+		}
+		return nil
+	}
+
+	ok, err := confirm(os.Stdout, fmt.Sprintf("    Delete %d tuples?", total), false)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("aborted: %s was not run", filepath.Base(file))
+	}
+	return nil
+}
+
+func showModel(ctx context.Context, client *omg.Client) error {
+	if err := checkFormat("show-model", "dsl", "json"); err != nil {
+		return err
+	}
+
+	if outputFormat == "json" {
+		model, err := client.GetCurrentAuthorizationModel(ctx)
+		if err != nil {
+			return err
+		}
+		modelJSON, err := omg.FormatModelAsJSON(model)
+		if err != nil {
+			return err
+		}
+		fmt.Print(modelJSON)
+		return nil
+	}
+
+	model, err := client.GetCurrentModel(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(model)
+
+	return nil
+}
+
+// convertModel translates a model file between DSL and JSON
+// The target format is -format when given, otherwise .json outputs get JSON, other files
+// DSL, and stdout the format the input is not in
+func convertModel(input, output string) error {
+	content, err := omg.LoadCurrentModelFromPath(input)
+	if err != nil {
+		return err
+	}
+
+	format := outputFormat
+	switch {
+	case format != "":
+	case output != "-" && filepath.Ext(output) == ".json":
+		format = "json"
+	case output != "-":
+		format = "dsl"
+	case omg.IsJSONModel(content):
+		format = "dsl"
+	default:
+		format = "json"
+	}
+
+	converted, err := omg.ConvertModel(content, format)
+	if err != nil {
+		return fmt.Errorf("%s: %w", input, err)
+	}
+
+	if output == "-" {
+		fmt.Print(converted)
+		return nil
+	}
+	if err := os.WriteFile(output, []byte(converted), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", output, err)
+	}
+	fmt.Printf("Converted %s to %s (%s)\n", input, output, format)
+	return nil
+}
+
+func initStore(storeName string) error {
+	cfg, err := storeAdminConfig()
+	if err != nil {
+		return err
+	}
+	apiURL := cfg.ApiURL
+
+	fmt.Printf("Creating OpenFGA store '%s'...\n", storeName)
+
+	storeID, err := omg.CreateStoreWithConfig(context.Background(), cfg, storeName)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\nStore created successfully!\n\n")
+	fmt.Printf("Store ID: %s\n", storeID)
+	fmt.Printf("Store Name: %s\n\n", storeName)
+	fmt.Println("Add to your environment:")
+	fmt.Printf("  export OPENFGA_STORE_ID=%s\n", storeID)
+	fmt.Printf("  export OPENFGA_DATABASE_URL=openfga://%s@%s\n", storeID, strings.TrimPrefix(apiURL, "http://"))
+	fmt.Println("")
+	fmt.Println("Or use with -dburl flag:")
+	fmt.Printf("  omg -dburl openfga://%s@%s up\n", storeID, strings.TrimPrefix(apiURL, "http://"))
+
+	return nil
+}
+
+func listStores() error {
+	if err := checkFormat("list-stores", "json", "table", "plain"); err != nil {
+		return err
+	}
+
+	cfg, err := storeAdminConfig()
+	if err != nil {
+		return err
+	}
+
+	stores, err := omg.ListStoresWithConfig(context.Background(), cfg)
+	if err != nil {
+		return err
+	}
+
+	switch outputFormat {
+	case "json":
+		return printJSON(stores)
+	case "plain":
+		for _, store := range stores {
+			fmt.Printf("%s\t%s\n", store.ID, store.Name)
+		}
+		return nil
+	}
+
+	if len(stores) == 0 {
+		fmt.Println("No stores found")
+		return nil
+	}
+
+	fmt.Printf("Found %d store(s):\n\n", len(stores))
+	for _, store := range stores {
+		fmt.Printf("  ID:   %s\n", store.ID)
+		fmt.Printf("  Name: %s\n\n", store.Name)
+	}
+
+	return nil
+}
+
+// storeInfo prints the store's metadata, latest model and how many models and tuples it has
+func storeInfo(ctx context.Context, client *omg.Client) error {
+	if err := checkFormat("store-info", "json", "table"); err != nil {
+		return err
+	}
+
+	info, err := client.GetStoreInfo(ctx)
+	if err != nil {
+		return err
+	}
+	if outputFormat == "json" {
+		return printJSON(info)
+	}
+
+	latestModel := info.LatestModelID
+	if latestModel == "" {
+		latestModel = "none"
+	}
+	fmt.Printf("Store:         %s\n", info.ID)
+	fmt.Printf("Name:          %s\n", info.Name)
+	fmt.Printf("Created:       %s\n", info.CreatedAt.Local().Format("2006-01-02 15:04:05"))
+	fmt.Printf("Updated:       %s\n", info.UpdatedAt.Local().Format("2006-01-02 15:04:05"))
+	fmt.Printf("Latest model:  %s\n", latestModel)
+	fmt.Printf("Models:        %d\n", info.ModelCount)
+	fmt.Printf("Tuples:        %s\n", tupleCount(info))
+	return nil
+}
+
+// cloneStore copies the latest model and the tuples of a store (the configured one unless
+// sourceID is set) into a new store called name
+func cloneStore(ctx context.Context, sourceID, name string) error {
+	cfg, err := storeAdminConfig()
+	if err != nil {
+		return err
+	}
+	if sourceID != "" {
+		cfg.StoreID = sourceID
+		cfg.StoreName = ""
+	}
+	source := cfg.StoreID
+	if source == "" {
+		source = cfg.StoreName
+	}
+	if source == "" {
+		return fmt.Errorf("no source store: pass -from or set OPENFGA_STORE_ID")
+	}
+
+	fmt.Printf("Cloning store %s into new store '%s'...\n", source, name)
+	result, err := omg.CloneStore(ctx, cfg, name)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\n✓ Created store %s with ", result.StoreID)
+	if result.SourceModel == "" {
+		fmt.Println("no model (the source has none)")
+	} else {
+		fmt.Printf("a copy of model %s and %d tuples\n", result.SourceModel, result.Tuples)
+	}
+	fmt.Printf("  export OPENFGA_STORE_ID=%s\n", result.StoreID)
+	return nil
+}
+
+// rehearsalImage is the OpenFGA image rehearse -container runs
+const rehearsalImage = "openfga/openfga:v1.8.0"
+
+// rehearse applies the pending migrations to a throwaway store with the store's current
+// model, and -sample of its tuples, to find out whether they would succeed. Neither the
+// store, its tracker nor model.lock are changed, and the backups and checkpoints the
+// migrations take go to a temporary directory, not .omg
+func rehearse(ctx context.Context) error {
+	cfg, err := clientConfig()
+	if err != nil {
+		return err
+	}
+	production, err := omg.NewClient(cfg)
+	if err != nil {
+		return err
+	}
+	tracker, closeTracker, err := openTracker(production)
+	if err != nil {
+		return err
+	}
+	applied, err := tracker.GetApplied(ctx)
+	closeTracker()
+	if err != nil {
+		return err
+	}
+
+	migrationFiles, err := findMigrationFiles()
+	if err != nil {
+		return err
+	}
+	pending := 0
+	for _, file := range migrationFiles {
+		if _, exists := applied[extractVersionFromFilename(file)]; !exists {
+			pending++
+		}
+	}
+	if pending == 0 {
+		fmt.Println("Nothing to rehearse: no pending migrations")
+		return nil
+	}
+
+	target := cfg
+	if rehearseLocally {
+		endpoint, stop, err := startRehearsalContainer(ctx)
+		if err != nil {
+			return err
+		}
+		defer stop()
+		target = omg.Config{ApiURL: endpoint, AuthMethod: "none"}
+	} else if rehearseURL != "" {
+		target = omg.Config{ApiURL: rehearseURL, AuthMethod: "none"}
+	}
+
+	name := "omg-rehearsal-" + time.Now().UTC().Format("20060102150405")
+	opts := omg.CloneOptions{Target: &target, MaxTuples: rehearseSample, SkipTuples: rehearseSample == 0}
+	if rehearseSample < 0 {
+		opts.MaxTuples = 0
+	}
+	fmt.Printf("Copying the model of store %s into rehearsal store '%s'...\n", production.GetStoreID(), name)
+	result, err := omg.CloneStoreWithOptions(ctx, cfg, name, opts)
+	if err != nil {
+		return err
+	}
+	target.StoreID = result.StoreID
+	target.StoreName = ""
+
+	// Backups are not keyed by store: the rehearsal's would be taken for the store's own,
+	// e.g. by BackupForRemovalIfMissing. Checkpoints are, but go with the rehearsal too
+	stateDir, err := os.MkdirTemp("", "omg-rehearsal-")
+	if err != nil {
+		return fmt.Errorf("failed to create rehearsal directory: %w", err)
+	}
+	defer os.RemoveAll(stateDir)
+	os.Setenv("OMG_BACKUP_DIR", filepath.Join(stateDir, "backups"))
+	os.Setenv("OMG_CHECKPOINT_DIR", filepath.Join(stateDir, "checkpoints"))
+
+	useRehearsalStore(target, rehearseLocally || rehearseURL != "")
+	client, err := initOpenFGAClient()
+	if err != nil {
+		return err
+	}
+	if !keepRehearsal && !rehearseLocally {
+		defer func() {
+			if err := client.DeleteStore(context.Background()); err != nil {
+				fmt.Printf("Warning: %v\n", err)
+			}
+		}()
+	}
+
+	fmt.Printf("Rehearsing %d pending migrations with %d tuples...\n\n", pending, result.Tuples)
+	count, err := applyPending(ctx, client, omg.NewMemoryTracker(), migrationFiles, applied, "", false, "")
+	if err != nil {
+		return fmt.Errorf("after %d of %d migrations: %w", count, pending, err)
+	}
+
+	fmt.Printf("\n✓ Rehearsed %d migrations; store %s was not changed\n", count, production.GetStoreID())
+	if keepRehearsal && !rehearseLocally {
+		fmt.Printf("  Rehearsal store kept: export OPENFGA_STORE_ID=%s\n", result.StoreID)
+	}
+	return nil
+}
+
+// useRehearsalStore points the CLI, and the migration programs it runs, at the rehearsal
+// store described by cfg, away from the store's tracking database. A local store also
+// drops the credentials, TLS and proxy settings
+func useRehearsalStore(cfg omg.Config, local bool) {
+	dbURL, trackerDBURL, migrationDBURL = "", "", ""
+	for _, name := range []string{"OPENFGA_DATABASE_URL", "OPENFGA_STORE_NAME",
+		"OMG_TRACKER_DATABASE_URL", "MIGRATION_DATABASE_URL", "OPENFGA_DATASTORE_URI"} {
+		os.Unsetenv(name)
+	}
+	vars := map[string]string{
+		"OPENFGA_API_URL":        cfg.ApiURL,
+		"OPENFGA_STORE_ID":       cfg.StoreID,
+		"OPENFGA_AUTH_METHOD":    cfg.AuthMethod,
+		"OPENFGA_API_TOKEN":      cfg.APIToken,
+		"OPENFGA_CLIENT_ID":      cfg.ClientID,
+		"OPENFGA_CLIENT_SECRET":  cfg.ClientSecret,
+		"OPENFGA_TOKEN_ISSUER":   cfg.TokenIssuer,
+		"OPENFGA_TOKEN_AUDIENCE": cfg.TokenAudience,
+	}
+	if local {
+		for _, name := range []string{"OPENFGA_TLS_CA_FILE", "OPENFGA_TLS_CLIENT_CERT", "OPENFGA_TLS_CLIENT_KEY",
+			"OPENFGA_TLS_INSECURE_SKIP_VERIFY", "OPENFGA_PROXY_URL", "OPENFGA_HEADERS"} {
+			vars[name] = ""
+		}
+	}
+	for name, value := range vars {
+		os.Setenv(name, value)
+	}
+}
+
+// tupleCount formats the tuple count of info, e.g. 10000+ when not all tuples were counted
+func tupleCount(info omg.StoreInfo) string {
+	if info.MoreTuples {
+		return fmt.Sprintf("%d+", info.TupleCount)
+	}
+	return fmt.Sprint(info.TupleCount)
+}
+
+// deleteStore deletes a store after showing what it holds and asking for its name,
+// unless -force or -yes is given
+func deleteStore(ctx context.Context, storeID string) error {
+	cfg, err := storeAdminConfig()
+	if err != nil {
+		return err
+	}
+	cfg.StoreID = storeID
+	cfg.StoreName = ""
+	client, err := omg.NewClient(cfg)
+	if err != nil {
+		return err
+	}
+
+	info, err := client.GetStoreInfo(ctx)
+	if err != nil {
+		return err
 	}
+	fmt.Printf("Store %s (%s): %d models, %s tuples\n", info.ID, info.Name, info.ModelCount, tupleCount(info))
 
-	fmt.Printf("Migration status for directory '%s'\n", migrationsDir)
-	for _, file := range migrationFiles {
-		version := extractVersionFromFilename(file)
-		name := extractNameFromFilename(file)
-
-		status := "Pending"
-		if info, exists := applied[version]; exists {
-			status = fmt.Sprintf("Applied At: %s", info.AppliedAt.Format("Mon Jan  2 15:04:05 2006"))
+	if !force && !assumeYes {
+		expected := info.Name
+		if expected == "" {
+			expected = info.ID
+		}
+		if err := confirmDestructive(fmt.Sprintf("Type '%s' to delete the store: ", expected), expected); err != nil {
+			return err
 		}
-		fmt.Printf("    %-15s  %-40s  %s\n", version, name, status)
 	}
 
+	if err := client.DeleteStore(ctx); err != nil {
+		return err
+	}
+	fmt.Printf("✓ Deleted store %s\n", info.ID)
 	return nil
 }
 
-func createMigration(name string) error {
-	timestamp := time.Now().Format("20060102150405")
-	filename := fmt.Sprintf("%s/%s_%s.go", migrationsDir, timestamp, name)
-
-	template := fmt.Sprintf(`package main
-
-// Migration: %s
-// Version: %s
+func generateMigration(name string) error {
+	// With a summary on stdout, progress messages go to stderr
+	out := io.Writer(os.Stdout)
+	if summaryPath == "-" {
+		out = os.Stderr
+	}
 
-import (
-	"context"
-	"fmt"
-	"os"
+	if strategy == omg.StrategyModelApply && changesPath != "" {
+		return fmt.Errorf("-strategy %s applies the model file and cannot be used with -changes", omg.StrategyModelApply)
+	}
+	if strategy == omg.StrategyModelApply && split {
+		return fmt.Errorf("-strategy %s applies the whole model and cannot be used with -split", omg.StrategyModelApply)
+	}
 
-	"github.com/demetere/omg/pkg"
-)
+	var changes, raw omg.ChangeSet
+	var desiredModel *openfgaSdk.AuthorizationModel // The model file; nil with -changes
+	if changesPath != "" {
+		saved, err := omg.ReadChangeSet(changesPath)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Using changes from %s\n", changesPath)
+		changes = saved
+	} else {
+		detected, undetected, desired, err := detectGenerateChanges(out, !liveState)
+		if err != nil {
+			return err
+		}
+		changes, raw, desiredModel = detected, undetected, desired
+	}
 
-func main() {
-	// Get connection info from environment
-	client, err := omg.NewClient(
-		os.Getenv("OPENFGA_API_URL"),
-		os.Getenv("OPENFGA_STORE_ID"),
-		os.Getenv("OPENFGA_API_TOKEN"),
-	)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to create client: %%v\n", err)
-		os.Exit(1)
+	if len(changes) == 0 {
+		fmt.Fprintln(out, "No changes detected")
+		if summaryPath != "" {
+			return omg.WriteGenerateSummary(summaryPath, omg.GenerateSummary{Changes: []omg.ChangeSummary{}})
+		}
+		return nil
 	}
 
-	ctx := context.Background()
+	// Print detected changes
+	fmt.Fprintf(out, "\nDetected %d change(s):\n", len(changes))
+	for i, change := range changes {
+		fmt.Fprintf(out, "  %d. %s\n", i+1, change.Details)
+	}
 
-	// Check if we should run Up or Down
-	if len(os.Args) > 1 && os.Args[1] == "down" {
-		if err := down(ctx, client); err != nil {
-			fmt.Fprintf(os.Stderr, "Migration down failed: %%v\n", err)
-			os.Exit(1)
+	// Ask for confirmation on potential renames
+	confirmedChanges, err := confirmChanges(out, changes, raw)
+	if err != nil {
+		return err
+	}
+	printModelOnlyWarnings(out, confirmedChanges)
+
+	genOpts := omg.GenerateOptions{
+		Backfill:   backfill,
+		WithTests:  withTests,
+		Idempotent: idempotent,
+		Package:    migrationPackage,
+		ModelOnly:  modelOnlyMode(),
+		Strategy:   strategy,
+		Template:   migrationTemplate(),
+		Verify:     verifyGenerated,
+	}
+	if withTests || strategy == omg.StrategyModelApply {
+		// The generated test starts from the model as it is now, and model-apply's down goes back to it
+		genOpts.PriorModel, err = priorModel()
+		if err != nil {
+			return fmt.Errorf("failed to read current model: %w", err)
 		}
-	} else {
-		if err := up(ctx, client); err != nil {
-			fmt.Fprintf(os.Stderr, "Migration up failed: %%v\n", err)
-			os.Exit(1)
+	}
+	if strategy == omg.StrategyModelApply {
+		genOpts.TargetModel, err = omg.FormatModelAsDSL(*desiredModel)
+		if err != nil {
+			return err
 		}
 	}
-}
 
-func up(ctx context.Context, client *omg.Client) error {
-	// TODO: Implement migration
-	//
-	// Available omg functions:
-	//
-	// MODEL OPERATIONS:
-	// - omg.GetCurrentModel(ctx, client) - Get current model as DSL string
-	//
-	// TUPLE OPERATIONS:
-	// - omg.RenameRelation(ctx, client, objectType, oldRel, newRel) - Rename relation on all tuples
-	// - omg.RenameType(ctx, client, oldType, newType) - Rename object type on all tuples
-	// - omg.CopyRelation(ctx, client, objectType, sourceRel, targetRel) - Copy tuples to new relation
-	// - omg.DeleteRelation(ctx, client, objectType, relation) - Delete all tuples with relation
-	// - omg.MigrateRelationWithTransform(ctx, client, objectType, oldRel, newRel, transform) - Custom transform
-	//
-	// READ OPERATIONS:
-	// - omg.ReadAllTuples(ctx, client, objectType, relation) - Read tuples by type/relation
-	// - omg.CountTuples(ctx, client, objectType, relation) - Count matching tuples
-	//
-	// BATCH OPERATIONS:
-	// - omg.WriteTuplesBatch(ctx, client, tuples) - Write tuples in batches
-	// - omg.DeleteTuplesBatch(ctx, client, tuples) - Delete tuples in batches
-	//
-	// UTILITY:
-	// - omg.BackupTuples(ctx, client) - Backup all tuples before migration
-	// - omg.RestoreTuples(ctx, client, tuples) - Restore tuples from backup
+	if split {
+		if err := generateSplitMigrations(out, confirmedChanges, name, genOpts); err != nil {
+			return err
+		}
+		return updateSchemaSnapshot(out, desiredModel)
+	}
 
-	return nil
-}
+	// Generate migration
+	fmt.Fprintln(out, "\nGenerating migration...")
+	filename, err := omg.GenerateMigrationFromChangesWithOptions(confirmedChanges, name, migrationsDir, genOpts)
+	if err != nil {
+		return fmt.Errorf("failed to generate migration: %w", err)
+	}
 
-func down(ctx context.Context, client *omg.Client) error {
-	// TODO: Implement rollback
-	// Reverse the operations from Up
+	if summaryPath != "" {
+		summary := omg.BuildGenerateSummary(filename, confirmedChanges, genOpts)
+		if err := omg.WriteGenerateSummary(summaryPath, summary); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(out, "\n✓ Migration created: %s\n", filename)
+	if withTests {
+		fmt.Fprintf(out, "✓ Test created: %s\n", strings.TrimSuffix(filename, ".go")+"_test.go")
+	}
+	if err := updateSchemaSnapshot(out, desiredModel); err != nil {
+		return err
+	}
+	fmt.Fprintln(out, "\nNext steps:")
+	fmt.Fprintln(out, "  1. Review the generated migration file")
+	fmt.Fprintln(out, "  2. Edit if needed (especially for renames)")
+	fmt.Fprintln(out, "  3. Run 'omg up' to apply the migration")
 
 	return nil
 }
-`, name, timestamp)
 
-	if err := os.MkdirAll(migrationsDir, 0755); err != nil {
+// generateInverseMigration writes a migration undoing the migration with the given version
+// name defaults to revert_<name of that migration>
+func generateInverseMigration(version, name string) error {
+	migrationFiles, err := findMigrationFiles()
+	if err != nil {
 		return err
 	}
 
-	if err := os.WriteFile(filename, []byte(template), 0644); err != nil {
+	var source string
+	for _, file := range migrationFiles {
+		if extractVersionFromFilename(file) == version {
+			source = file
+			break
+		}
+	}
+	if source == "" {
+		return fmt.Errorf("no migration with version %s in %s", version, migrationsDir)
+	}
+	if name == "" {
+		name = "revert_" + extractNameFromFilename(source)
+	}
+
+	filename, err := omg.GenerateInverseMigration(source, name, migrationsDir)
+	if err != nil {
 		return err
 	}
 
-	fmt.Printf("Created migration file: %s\n", filename)
+	fmt.Printf("✓ Migration created: %s\n", filename)
+	fmt.Printf("  Its up runs the down of %s, so applying it reverts that migration\n", filepath.Base(source))
 	return nil
 }
 
-func listTuples(ctx context.Context, client *omg.Client, filter string) error {
-	req := omg.ReadTuplesRequest{}
+// detectGenerateChanges compares the model file with the live model for generate and plan
+// With fromSnapshot, the schema snapshot of the migrations directory is used instead of
+// the live model when there is one.
+// It returns the changes, the same changes before rename detection, and the desired model
+func detectGenerateChanges(out io.Writer, fromSnapshot bool) (omg.ChangeSet, omg.ChangeSet, *openfgaSdk.AuthorizationModel, error) {
+	fmt.Fprintln(out, "Detecting model changes...")
 
-	if filter != "" {
-		req.Object = filter + ":"
+	oldState, err := loadGenerateBase(out, fromSnapshot)
+	if err != nil {
+		return nil, nil, nil, err
 	}
 
-	tuples, err := client.ReadAllTuples(ctx, req)
+	// Load desired model from file
+	newModelDSL, err := omg.LoadCurrentModelFromPath(modelPath)
 	if err != nil {
-		return err
+		return nil, nil, nil, fmt.Errorf("failed to load %s: %w", modelSourceName(), err)
 	}
 
-	fmt.Printf("Found %d tuples:\n\n", len(tuples))
-
-	for _, tuple := range tuples {
-		fmt.Printf("%s  %s  %s\n", tuple.User, tuple.Relation, tuple.Object)
+	// Parse desired model
+	newModel, err := omg.ParseModel(newModelDSL)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to parse %s: %w", modelSourceName(), err)
 	}
 
-	return nil
-}
-
-func showModel(ctx context.Context, client *omg.Client) error {
-	model, err := client.GetCurrentModel(ctx)
-	if err != nil {
-		return err
+	// Build desired state
+	newState := omg.BuildModelState(newModel)
+	if trackerKind == "tuples" {
+		if err := omg.CheckTrackingTypes(newState); err != nil {
+			return nil, nil, nil, err
+		}
 	}
 
-	fmt.Println(model)
+	// Detect changes
+	opts := detectOptions()
+	changes := omg.DetectChangesWithOptions(oldState, newState, opts)
+	if len(changes) == 0 {
+		return nil, nil, &newModel, nil
+	}
 
-	return nil
+	// Detect potential renames
+	if opts, err = withTupleCounts(opts, changes); err != nil {
+		return nil, nil, nil, err
+	}
+	return omg.DetectPotentialRenamesWithOptions(changes, oldState, newState, opts), changes, &newModel, nil
 }
 
-func initStore(storeName string) error {
-	// Get API URL from environment or dbURL
-	apiURL := os.Getenv("OPENFGA_API_URL")
-	if apiURL == "" && dbURL != "" {
-		cfg, err := parseDBURL(dbURL)
+// loadGenerateBase loads the state generate compares the model file with: the schema
+// snapshot when fromSnapshot is set and there is one, the live model otherwise
+func loadGenerateBase(out io.Writer, fromSnapshot bool) (*omg.ModelState, error) {
+	if fromSnapshot {
+		snapshot, err := omg.ReadSchemaSnapshot(migrationsDir)
 		if err != nil {
-			return fmt.Errorf("invalid database URL: %w", err)
+			return nil, err
+		}
+		if snapshot != nil {
+			fmt.Fprintf(out, "Comparing with %s (-live compares with OpenFGA)...\n", omg.SchemaSnapshotPath(migrationsDir))
+			return omg.BuildModelStateFromAuthorizationModel(*snapshot), nil
 		}
-		apiURL = cfg.ApiURL
 	}
 
-	if apiURL == "" {
-		return fmt.Errorf("OPENFGA_API_URL or -dburl is required")
+	// Create client to query OpenFGA
+	client, err := initOpenFGAClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client: %w", err)
 	}
 
-	fmt.Printf("Creating OpenFGA store '%s'...\n", storeName)
-
-	storeID, err := omg.CreateStore(apiURL, storeName)
+	// Load current state from OpenFGA
+	fmt.Fprintln(out, "Querying OpenFGA for current model...")
+	oldState, err := omg.LoadModelStateFromOpenFGA(context.Background(), client)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("failed to load current model from OpenFGA: %w\nMake sure OpenFGA is running and accessible", err)
 	}
-
-	fmt.Printf("\nStore created successfully!\n\n")
-	fmt.Printf("Store ID: %s\n", storeID)
-	fmt.Printf("Store Name: %s\n\n", storeName)
-	fmt.Println("Add to your environment:")
-	fmt.Printf("  export OPENFGA_STORE_ID=%s\n", storeID)
-	fmt.Printf("  export OPENFGA_DATABASE_URL=openfga://%s@%s\n", storeID, strings.TrimPrefix(apiURL, "http://"))
-	fmt.Println("")
-	fmt.Println("Or use with -dburl flag:")
-	fmt.Printf("  omg -dburl openfga://%s@%s up\n", storeID, strings.TrimPrefix(apiURL, "http://"))
-
-	return nil
+	return oldState, nil
 }
 
-func listStores() error {
-	// Get API URL from environment or dbURL
-	apiURL := os.Getenv("OPENFGA_API_URL")
-	if apiURL == "" && dbURL != "" {
-		cfg, err := parseDBURL(dbURL)
+// priorModel returns the model DSL generated tests start from: the schema snapshot when
+// generate compared with it, the live model otherwise
+func priorModel() (string, error) {
+	if !liveState {
+		snapshot, err := omg.ReadSchemaSnapshot(migrationsDir)
 		if err != nil {
-			return fmt.Errorf("invalid database URL: %w", err)
+			return "", err
 		}
-		apiURL = cfg.ApiURL
+		if snapshot != nil {
+			return omg.FormatModelAsDSL(*snapshot)
+		}
+	}
+
+	client, err := initOpenFGAClient()
+	if err != nil {
+		return "", fmt.Errorf("failed to create client: %w", err)
 	}
+	return client.GetCurrentModel(context.Background())
+}
 
-	if apiURL == "" {
-		return fmt.Errorf("OPENFGA_API_URL or -dburl is required")
+// updateSchemaSnapshot records the model file as the schema snapshot of the migrations
+// directory, the state the generated migrations build up to
+// model is nil when the changes did not come from the model file, which is then left alone
+func updateSchemaSnapshot(out io.Writer, model *openfgaSdk.AuthorizationModel) error {
+	if model == nil {
+		return nil
 	}
 
-	stores, err := omg.ListStores(apiURL)
+	path, err := omg.WriteSchemaSnapshot(migrationsDir, *model)
 	if err != nil {
 		return err
 	}
+	fmt.Fprintf(out, "✓ Schema snapshot updated: %s\n", path)
+	return nil
+}
 
-	if len(stores) == 0 {
-		fmt.Println("No stores found")
-		return nil
+// generateSplitMigrations writes one migration per change category (see omg.SplitChanges)
+func generateSplitMigrations(out io.Writer, changes []omg.ModelChange, name string, genOpts omg.GenerateOptions) error {
+	fmt.Fprintln(out, "\nGenerating split migrations...")
+	filenames, err := omg.GenerateSplitMigrations(changes, name, migrationsDir, genOpts)
+	if err != nil {
+		return fmt.Errorf("failed to generate migrations: %w", err)
 	}
 
-	fmt.Printf("Found %d store(s):\n\n", len(stores))
-	for _, store := range stores {
-		fmt.Printf("  ID:   %s\n", store.ID)
-		fmt.Printf("  Name: %s\n\n", store.Name)
+	if summaryPath != "" {
+		groups := omg.SplitChanges(changes)
+		summaries := make([]omg.GenerateSummary, len(filenames))
+		for i, filename := range filenames {
+			summaries[i] = omg.BuildGenerateSummary(filename, groups[i].Changes, genOpts)
+		}
+		if err := omg.WriteGenerateSummaries(summaryPath, summaries); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintln(out)
+	for _, filename := range filenames {
+		fmt.Fprintf(out, "✓ Migration created: %s\n", filename)
 	}
+	fmt.Fprintln(out, "\nNext steps:")
+	fmt.Fprintln(out, "  1. Review each migration file; they can be applied one at a time")
+	fmt.Fprintln(out, "  2. Edit if needed (especially for renames)")
+	fmt.Fprintln(out, "  3. Run 'omg up' to apply the migrations")
 
 	return nil
 }
 
-func generateMigration(name string) error {
-	fmt.Println("Detecting model changes...")
+// showDiff prints the changes between the model file and the live model and returns them
+func showDiff() (omg.ChangeSet, error) {
+	if err := checkFormat("diff", "text", "table", "plain", "json", "yaml"); err != nil {
+		return nil, err
+	}
 
-	// Create client to query OpenFGA
-	client, err := initOpenFGAClient()
-	if err != nil {
-		return fmt.Errorf("failed to create client: %w", err)
+	// Structured output goes to stdout on its own, progress messages to stderr
+	out := io.Writer(os.Stdout)
+	if outputFormat == "json" || outputFormat == "yaml" {
+		out = os.Stderr
 	}
-	ctx := context.Background()
 
-	// Load current state from OpenFGA
-	fmt.Println("Querying OpenFGA for current model...")
-	oldState, err := omg.LoadModelStateFromOpenFGA(ctx, client)
+	if diffTo != "" {
+		modelPath = diffTo
+	}
+	oldState, err := loadDiffBase(out)
 	if err != nil {
-		return fmt.Errorf("failed to load current model from OpenFGA: %w\nMake sure OpenFGA is running and accessible", err)
+		return nil, err
 	}
 
 	// Load desired model from file
 	newModelDSL, err := omg.LoadCurrentModelFromPath(modelPath)
 	if err != nil {
-		return fmt.Errorf("failed to load %s: %w", modelPath, err)
+		return nil, fmt.Errorf("failed to load %s: %w", modelSourceName(), err)
 	}
 
 	// Parse desired model
-	newModel, err := omg.ParseDSLToModel(newModelDSL)
+	newModel, err := omg.ParseModel(newModelDSL)
 	if err != nil {
-		return fmt.Errorf("failed to parse model.fga: %w", err)
+		return nil, fmt.Errorf("failed to parse %s: %w", modelSourceName(), err)
 	}
 
 	// Build desired state
 	newState := omg.BuildModelState(newModel)
-
-	// Detect changes
-	changes := omg.DetectChanges(oldState, newState)
-	if len(changes) == 0 {
-		fmt.Println("No changes detected")
-		return nil
+	if trackerKind == "tuples" {
+		if err := omg.CheckTrackingTypes(newState); err != nil {
+			return nil, err
+		}
 	}
 
-	// Detect potential renames
-	changes = omg.DetectPotentialRenames(changes, oldState, newState)
-
-	// Print detected changes
-	fmt.Printf("\nDetected %d change(s):\n", len(changes))
-	for i, change := range changes {
-		fmt.Printf("  %d. %s\n", i+1, change.Details)
+	// Detect changes
+	opts := detectOptions()
+	changes := omg.DetectChangesWithOptions(oldState, newState, opts)
+	if len(changes) > 0 {
+		// Detect potential renames
+		if opts, err = withTupleCounts(opts, changes); err != nil {
+			return nil, err
+		}
+		changes = omg.DetectPotentialRenamesWithOptions(changes, oldState, newState, opts)
 	}
 
-	// Ask for confirmation on potential renames
-	confirmedChanges, err := confirmChanges(changes)
-	if err != nil {
-		return err
+	switch outputFormat {
+	case "json", "yaml":
+		encode := changes.JSON
+		if outputFormat == "yaml" {
+			encode = changes.YAML
+		}
+		data, err := encode()
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(out, "%s\n", changes.Summary())
+		_, err = os.Stdout.Write(data)
+		return changes, err
 	}
 
-	// Generate migration
-	fmt.Println("\nGenerating migration...")
-	filename, err := omg.GenerateMigrationFromChanges(confirmedChanges, name, migrationsDir)
-	if err != nil {
-		return fmt.Errorf("failed to generate migration: %w", err)
+	if len(changes) == 0 {
+		fmt.Println("\n✓ No changes detected - model.fga matches current state")
+		return changes, nil
 	}
 
-	fmt.Printf("\n✓ Migration created: %s\n", filename)
-	fmt.Println("\nNext steps:")
-	fmt.Println("  1. Review the generated migration file")
-	fmt.Println("  2. Edit if needed (especially for renames)")
-	fmt.Println("  3. Run 'omg up' to apply the migration")
+	// Print changes
+	fmt.Printf("\nDetected %d change(s):\n\n", len(changes))
+	fmt.Print(omg.RenderChanges(changes, useColor() && outputFormat != "plain"))
 
-	return nil
+	fmt.Println("\nRun 'omg generate <name>' to create a migration for these changes")
+	return changes, nil
 }
 
-func showDiff() error {
-	fmt.Printf("Comparing %s with OpenFGA...\n", modelPath)
+// loadDiffBase loads the state diff compares the model file with: the -from file, or
+// the live model, in which case OpenFGA is contacted
+func loadDiffBase(out io.Writer) (*omg.ModelState, error) {
+	if diffFrom != "" {
+		fmt.Fprintf(out, "Comparing %s with %s...\n", modelSourceName(), diffFrom)
+		return omg.LoadModelStateFromFile(diffFrom)
+	}
+
+	fmt.Fprintf(out, "Comparing %s with OpenFGA...\n", modelSourceName())
 
 	// Create client to query OpenFGA
 	client, err := initOpenFGAClient()
 	if err != nil {
-		return fmt.Errorf("failed to create client: %w", err)
+		return nil, fmt.Errorf("failed to create client: %w", err)
 	}
-	ctx := context.Background()
 
 	// Load current state from OpenFGA
-	oldState, err := omg.LoadModelStateFromOpenFGA(ctx, client)
+	oldState, err := omg.LoadModelStateFromOpenFGA(context.Background(), client)
 	if err != nil {
-		return fmt.Errorf("failed to load current model from OpenFGA: %w\nMake sure OpenFGA is running and accessible", err)
+		return nil, fmt.Errorf("failed to load current model from OpenFGA: %w\nMake sure OpenFGA is running and accessible", err)
 	}
+	return oldState, nil
+}
 
-	// Load desired model from file
-	newModelDSL, err := omg.LoadCurrentModelFromPath(modelPath)
-	if err != nil {
-		return fmt.Errorf("failed to load %s: %w", modelPath, err)
+// diffFails reports whether diff should exit with status 2 for the changes, per -fail-on
+func diffFails(changes omg.ChangeSet) bool {
+	switch failOn {
+	case "never":
+		return false
+	case "destructive":
+		if destructive := changes.Destructive(); len(destructive) > 0 {
+			fmt.Fprintf(os.Stderr, "Destructive: %s\n", destructive.Summary())
+			return true
+		}
+		return false
 	}
+	return len(changes) > 0
+}
 
-	// Parse desired model
-	newModel, err := omg.ParseDSLToModel(newModelDSL)
-	if err != nil {
-		return fmt.Errorf("failed to parse model.fga: %w", err)
+// detectOptions builds change detection options from the -ignore flags
+func detectOptions() omg.DetectOptions {
+	var opts omg.DetectOptions
+	for _, rule := range splitList(ignoreRules) {
+		if strings.Contains(rule, "#") {
+			opts.IgnoreRelations = append(opts.IgnoreRelations, rule)
+		} else {
+			opts.IgnoreTypes = append(opts.IgnoreTypes, rule)
+		}
 	}
+	for _, kind := range splitList(ignoreChanges) {
+		opts.IgnoreChangeTypes = append(opts.IgnoreChangeTypes, omg.ChangeType(kind))
+	}
+	// The tuples tracker adds its types to the live model; model.fga may not declare them
+	// (see omg.CheckTrackingTypes)
+	if trackerKind == "tuples" {
+		opts.IgnoreTypes = append(opts.IgnoreTypes, omg.TrackingObjectType, omg.TrackingUserType)
+	}
+	return opts
+}
 
-	// Build desired state
-	newState := omg.BuildModelState(newModel)
+// withTupleCounts adds the tuple counts of removed types and relations to opts with
+// -count-tuples, so rename detection weighs the data each rename would migrate
+func withTupleCounts(opts omg.DetectOptions, changes []omg.ModelChange) (omg.DetectOptions, error) {
+	if !countTuples {
+		return opts, nil
+	}
 
-	// Detect changes
-	changes := omg.DetectChanges(oldState, newState)
-	if len(changes) == 0 {
-		fmt.Println("\n✓ No changes detected - model.fga matches current state")
-		return nil
+	client, err := initOpenFGAClient()
+	if err != nil {
+		return opts, fmt.Errorf("failed to create client: %w", err)
 	}
+	opts.TupleCounts, err = omg.CountRemovedTuples(context.Background(), client, changes)
+	return opts, err
+}
 
-	// Detect potential renames
-	changes = omg.DetectPotentialRenames(changes, oldState, newState)
+// checkFormat rejects a -format value the command does not support
+// An empty -format selects the command's default output
+func checkFormat(command string, formats ...string) error {
+	if outputFormat == "" {
+		return nil
+	}
+	for _, format := range formats {
+		if outputFormat == format {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown %s format '%s' (use %s)", command, outputFormat, strings.Join(formats, ", "))
+}
 
-	// Print changes
-	fmt.Printf("\nDetected %d change(s):\n\n", len(changes))
-	for _, change := range changes {
-		symbol := getChangeSymbol(change.Type)
-		fmt.Printf("%s %s\n", symbol, change.Details)
+// printJSON prints v to stdout as indented JSON
+func printJSON(v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode output: %w", err)
+	}
+	_, err = fmt.Printf("%s\n", data)
+	return err
+}
 
-		switch change.Type {
-		case omg.ChangeTypeRenameType, omg.ChangeTypeRenameRelation:
-			fmt.Printf("    Old: %s\n", change.OldValue)
-			fmt.Printf("    New: %s\n", change.NewValue)
+// splitList splits a comma-separated flag value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
 		}
 	}
-
-	fmt.Println("\nRun 'omg generate <name>' to create a migration for these changes")
-	return nil
+	return items
 }
 
-func getChangeSymbol(changeType omg.ChangeType) string {
-	switch changeType {
-	case omg.ChangeTypeAddType, omg.ChangeTypeAddRelation:
-		return "+"
-	case omg.ChangeTypeRemoveType, omg.ChangeTypeRemoveRelation:
-		return "-"
-	case omg.ChangeTypeUpdateRelation:
-		return "~"
-	case omg.ChangeTypeRenameType, omg.ChangeTypeRenameRelation:
-		return "→"
-	default:
-		return "•"
+// useColor reports whether diff output should be colored
+// Color is disabled by -no-color, the NO_COLOR convention, or a non-terminal stdout
+func useColor() bool {
+	if noColor || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := os.Stdout.Stat()
+	if err != nil {
+		return false
 	}
+	return info.Mode()&os.ModeCharDevice != 0
 }
 
-func confirmChanges(changes []omg.ModelChange) ([]omg.ModelChange, error) {
+// raw holds the changes before rename detection; when given, uncertain renames are
+// confirmed interactively and a rejected rename becomes a removal and an addition
+func confirmChanges(out io.Writer, changes []omg.ModelChange, raw omg.ChangeSet) ([]omg.ModelChange, error) {
 	// Process changes with confidence-aware handling
 	var confirmed []omg.ModelChange
 	for _, change := range changes {
+		if (change.Type == omg.ChangeTypeRenameType || change.Type == omg.ChangeTypeRenameRelation) && modelOnlyMode() && raw != nil {
+			// Model-only migrations never move tuples, so a rename is a removal and an addition
+			confirmed = append(confirmed, raw.RenameParts(change)...)
+			continue
+		}
+		if change.Type == omg.ChangeTypeMoveRelation {
+			moved, err := confirmMove(out, change, raw)
+			if err != nil {
+				return nil, err
+			}
+			confirmed = append(confirmed, moved...)
+			continue
+		}
 		if change.Type == omg.ChangeTypeRenameType || change.Type == omg.ChangeTypeRenameRelation {
 			// Handle based on confidence level
 			switch change.Confidence {
 			case omg.ConfidenceHigh:
 				// High confidence: keep as rename, inform user
-				fmt.Printf("\n✓ Rename detected: %s -> %s (high confidence)\n", change.OldValue, change.NewValue)
-				fmt.Println("   Will generate rename migration that preserves tuples.")
+				fmt.Fprintf(out, "\n✓ Rename detected: %s -> %s (high confidence)\n", change.OldValue, change.NewValue)
+				fmt.Fprintln(out, "   Will generate rename migration that preserves tuples.")
 				confirmed = append(confirmed, change)
 
 			case omg.ConfidenceMedium:
 				// Medium confidence: keep as rename but warn user to review
-				fmt.Printf("\n⚠  Possible rename: %s -> %s (medium confidence - review required)\n", change.OldValue, change.NewValue)
-				fmt.Println("   Will generate rename migration - review before applying.")
+				fmt.Fprintf(out, "\n⚠  Possible rename: %s -> %s (medium confidence - review required)\n", change.OldValue, change.NewValue)
+				fmt.Fprintln(out, "   Will generate rename migration - review before applying.")
 				confirmed = append(confirmed, change)
 
 			case omg.ConfidenceLow:
 				// Low confidence: keep as rename, generator will create commented code
-				fmt.Printf("\n⚠  Potential rename: %s -> %s (low confidence)\n", change.OldValue, change.NewValue)
-				fmt.Println("   Will generate both options - uncomment the rename if confirmed.")
+				fmt.Fprintf(out, "\n⚠  Potential rename: %s -> %s (low confidence)\n", change.OldValue, change.NewValue)
+				fmt.Fprintln(out, "   Will generate both options - uncomment the rename if confirmed.")
 				confirmed = append(confirmed, change)
 
 			default:
 				// No confidence info (legacy): treat conservatively
-				fmt.Printf("\n⚠  Detected potential rename: %s -> %s\n", change.OldValue, change.NewValue)
-				fmt.Println("   Will generate rename migration - review carefully.")
+				fmt.Fprintf(out, "\n⚠  Detected potential rename: %s -> %s\n", change.OldValue, change.NewValue)
+				fmt.Fprintln(out, "   Will generate rename migration - review carefully.")
 				confirmed = append(confirmed, change)
 			}
+
+			if change.Confidence == omg.ConfidenceHigh || assumeYes || !interactive() {
+				continue
+			}
+			rename, err := confirm(out, "   Treat as rename?", true)
+			if err != nil {
+				return nil, err
+			}
+			if rename {
+				continue
+			}
+			if raw == nil {
+				return nil, fmt.Errorf("rename %s -> %s rejected: edit the changes file to replace it with a removal and an addition", change.OldValue, change.NewValue)
+			}
+			fmt.Fprintf(out, "   Will remove %s and add %s instead; tuples of %s will be deleted.\n", change.OldValue, change.NewValue, change.OldValue)
+			confirmed = append(confirmed[:len(confirmed)-1], raw.RenameParts(change)...)
 		} else {
 			confirmed = append(confirmed, change)
 		}
@@ -928,3 +3701,37 @@ func confirmChanges(changes []omg.ModelChange) ([]omg.ModelChange, error) {
 
 	return confirmed, nil
 }
+
+// confirmMove confirms a relation moved to another type, like confirmChanges does renames
+// A rejected or model-only move becomes a removal and an addition
+func confirmMove(out io.Writer, change omg.ModelChange, raw omg.ChangeSet) ([]omg.ModelChange, error) {
+	from := change.TypeName + "#" + change.RelationName
+	to := change.TargetType + "#" + change.RelationName
+	if modelOnlyMode() && raw != nil {
+		return raw.RenameParts(change), nil
+	}
+
+	if change.Confidence == omg.ConfidenceHigh {
+		fmt.Fprintf(out, "\n✓ Move detected: %s -> %s (high confidence)\n", from, to)
+		fmt.Fprintln(out, "   Will generate move migration that rewrites the tuples' object type.")
+		return []omg.ModelChange{change}, nil
+	}
+	fmt.Fprintf(out, "\n⚠  Possible move: %s -> %s (medium confidence - review required)\n", from, to)
+	fmt.Fprintln(out, "   Will generate move migration - review before applying.")
+	if assumeYes || !interactive() {
+		return []omg.ModelChange{change}, nil
+	}
+
+	move, err := confirm(out, "   Treat as move?", true)
+	if err != nil {
+		return nil, err
+	}
+	if move {
+		return []omg.ModelChange{change}, nil
+	}
+	if raw == nil {
+		return nil, fmt.Errorf("move %s -> %s rejected: edit the changes file to replace it with a removal and an addition", from, to)
+	}
+	fmt.Fprintf(out, "   Will remove %s and add %s instead; tuples of %s will be deleted.\n", from, to, from)
+	return raw.RenameParts(change), nil
+}
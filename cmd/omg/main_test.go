@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	omg "github.com/demetere/omg/pkg"
+	"github.com/stretchr/testify/require"
+)
+
+const testStoreID = "01HVMMBCMGZNT3SED4Z17ECXCA"
+
+// fakeOpenFGA is an in-memory OpenFGA store: authorization models, newest last, and tuples
+type fakeOpenFGA struct {
+	mu     sync.Mutex
+	models []map[string]any
+	tuples map[omg.Tuple]bool
+}
+
+type tupleKey struct {
+	User     string `json:"user"`
+	Relation string `json:"relation"`
+	Object   string `json:"object"`
+}
+
+// newFakeOpenFGA starts a fake OpenFGA API whose store has the given model, in JSON
+func newFakeOpenFGA(t *testing.T, model string) *httptest.Server {
+	fake := &fakeOpenFGA{tuples: map[omg.Tuple]bool{}}
+	var initial map[string]any
+	require.NoError(t, json.Unmarshal([]byte(model), &initial))
+	fake.addModel(initial)
+
+	server := httptest.NewServer(http.HandlerFunc(fake.serve))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// addModel stores model as the latest and returns its ID
+func (f *fakeOpenFGA) addModel(model map[string]any) string {
+	id := fmt.Sprintf("01HVMMBCMGZNT3SED4Z17EC%03d", len(f.models))
+	model["id"] = id
+	f.models = append(f.models, model)
+	return id
+}
+
+func (f *fakeOpenFGA) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+
+	switch {
+	case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/authorization-models"):
+		json.NewEncoder(w).Encode(map[string]any{"authorization_models": []any{f.models[len(f.models)-1]}})
+
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/authorization-models"):
+		var model map[string]any
+		json.NewDecoder(r.Body).Decode(&model)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]any{"authorization_model_id": f.addModel(model)})
+
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/read"):
+		var body struct {
+			TupleKey tupleKey `json:"tuple_key"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		var found []any
+		for tuple := range f.tuples {
+			key := body.TupleKey
+			objectMatches := key.Object == "" || tuple.Object == key.Object ||
+				(strings.HasSuffix(key.Object, ":") && strings.HasPrefix(tuple.Object, key.Object))
+			if objectMatches && (key.User == "" || tuple.User == key.User) && (key.Relation == "" || tuple.Relation == key.Relation) {
+				found = append(found, map[string]any{"key": tuple, "timestamp": "2024-11-28T15:00:00Z"})
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]any{"tuples": found, "continuation_token": ""})
+
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/write"):
+		var body struct {
+			Writes struct {
+				TupleKeys []tupleKey `json:"tuple_keys"`
+			} `json:"writes"`
+			Deletes struct {
+				TupleKeys []tupleKey `json:"tuple_keys"`
+			} `json:"deletes"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		for _, key := range body.Writes.TupleKeys {
+			f.tuples[omg.Tuple(key)] = true
+		}
+		for _, key := range body.Deletes.TupleKeys {
+			delete(f.tuples, omg.Tuple(key))
+		}
+		w.Write([]byte(`{}`))
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"code":"not_found","message":"not found"}`))
+	}
+}
+
+func TestUpDownUp_KeepsModelLock(t *testing.T) {
+	server := newFakeOpenFGA(t, `{"schema_version":"1.1","type_definitions":[{"type":"user"},{"type":"doc","relations":{"viewer":{"this":{}}},"metadata":{"relations":{"viewer":{"directly_related_user_types":[{"type":"user"}]}}}}]}`)
+	client, err := omg.NewClient(omg.Config{ApiURL: server.URL, StoreID: testStoreID, AuthMethod: "none"})
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	migrationsDir = filepath.Join(dir, "migrations")
+	modelPath = filepath.Join(dir, "model.fga")
+	trackerKind = "tuples"
+	force = false
+	require.NoError(t, os.MkdirAll(migrationsDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(migrationsDir, "20240101000000_add_editor.yaml"), []byte(`up:
+  - op: add_relation
+    type: doc
+    relation: editor
+    definition: "[user]"
+down:
+  - op: remove_relation
+    type: doc
+    relation: editor
+`), 0644))
+
+	ctx := context.Background()
+	require.NoError(t, runUp(ctx, client))
+	require.NoError(t, runDown(ctx, client))
+	require.NoError(t, omg.VerifyModelLock(ctx, client, modelLockPath()), "down updates model.lock")
+	require.NoError(t, runUp(ctx, client), "up after down needs no -force")
+	require.NoError(t, omg.VerifyModelLock(ctx, client, modelLockPath()))
+}
@@ -0,0 +1,28 @@
+//go:build rehearse_container
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	openfgacontainer "github.com/testcontainers/testcontainers-go/modules/openfga"
+)
+
+// startRehearsalContainer starts a local OpenFGA container for rehearse -container and
+// returns its API URL and a function that stops it
+func startRehearsalContainer(ctx context.Context) (string, func(), error) {
+	fmt.Printf("Starting OpenFGA container (%s)...\n", rehearsalImage)
+	container, err := openfgacontainer.Run(ctx, rehearsalImage)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to start OpenFGA container: %w", err)
+	}
+	stop := func() { container.Terminate(context.Background()) }
+
+	endpoint, err := container.HttpEndpoint(ctx)
+	if err != nil {
+		stop()
+		return "", nil, fmt.Errorf("failed to get container endpoint: %w", err)
+	}
+	return endpoint, stop, nil
+}
@@ -0,0 +1,14 @@
+//go:build !rehearse_container
+
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// startRehearsalContainer is not built in: running containers pulls the Docker client
+// into the CLI, so it takes -tags rehearse_container
+func startRehearsalContainer(ctx context.Context) (string, func(), error) {
+	return "", nil, fmt.Errorf("rehearse -container is not built in (build omg with -tags rehearse_container), or pass -rehearse-url to rehearse on an OpenFGA server of your own")
+}
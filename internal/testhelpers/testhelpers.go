@@ -45,6 +45,21 @@ func SetupOpenFGAContainer(t *testing.T, ctx context.Context, modelDSL string) (
 	return container, client
 }
 
+// SetupFromSnapshot starts an OpenFGA container and restores a snapshot archive
+// (see omg.WriteSnapshot) into a new store, for tests against production-shaped data
+func SetupFromSnapshot(t *testing.T, ctx context.Context, snapshotPath string) (*openfgacontainer.OpenFGAContainer, *omg.Client) {
+	snapshot, err := omg.ReadSnapshot(snapshotPath)
+	require.NoError(t, err)
+
+	container, client := SetupOpenFGAContainer(t, ctx, "")
+	if err := omg.RestoreSnapshot(ctx, client, snapshot); err != nil {
+		container.Terminate(ctx)
+		require.NoError(t, err)
+	}
+
+	return container, client
+}
+
 func createStore(ctx context.Context, apiURL, name string) (string, error) {
 	reqBody := fmt.Sprintf(`{"name":"%s"}`, name)
 	resp, err := http.Post(apiURL+"/stores", "application/json", strings.NewReader(reqBody))
@@ -15,13 +15,14 @@ import (
 )
 
 func main() {
-	// Get connection info from environment
-	client, err := omg.NewClient(omg.Config{
-		ApiURL:     os.Getenv("OPENFGA_API_URL"),
-		StoreID:    os.Getenv("OPENFGA_STORE_ID"),
-		AuthMethod: getAuthMethod(),
-		APIToken:   os.Getenv("OPENFGA_API_TOKEN"),
-	})
+	// Get connection info from environment, the same way the omg CLI does
+	cfg, err := omg.ConfigFromEnv()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	client, err := omg.NewClient(cfg)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to create client: %v\n", err)
 		os.Exit(1)
@@ -43,13 +44,6 @@ func main() {
 	}
 }
 
-func getAuthMethod() string {
-	if token := os.Getenv("OPENFGA_API_TOKEN"); token != "" {
-		return "token"
-	}
-	return "none"
-}
-
 func up(ctx context.Context, client *omg.Client) error {
 	// ============================================================================
 	// EXAMPLE 1: Rename relation with tuple migration
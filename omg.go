@@ -26,6 +26,9 @@ type (
 	// Config holds OpenFGA client configuration
 	Config = omgpkg.Config
 
+	// AuthStatus is what Client.CheckAuth found out about a client's credentials
+	AuthStatus = omgpkg.AuthStatus
+
 	// Tuple represents an OpenFGA relationship tuple
 	Tuple = omgpkg.Tuple
 
@@ -36,14 +39,72 @@ type (
 	Store = omgpkg.Store
 )
 
-// NewClient creates a new OpenFGA client from configuration
-var NewClient = omgpkg.NewClient
+// Client constructors and configuration
+var (
+	// NewClient creates a new OpenFGA client from configuration
+	NewClient = omgpkg.NewClient
+
+	// ConfigFromEnv builds a Config from OPENFGA_DATABASE_URL or the OPENFGA_* variables
+	ConfigFromEnv = omgpkg.ConfigFromEnv
+
+	// ParseDatabaseURL parses an openfga://store_id@host URL into a Config
+	ParseDatabaseURL = omgpkg.ParseDatabaseURL
+
+	// ParseHeaders parses Name=value pairs separated by commas into Config.Headers
+	ParseHeaders = omgpkg.ParseHeaders
+
+	// CurrentVersion returns the omg version, or "dev" for local builds
+	CurrentVersion = omgpkg.CurrentVersion
+
+	// DefaultUserAgent is the User-Agent of API requests unless Config.UserAgent is set
+	DefaultUserAgent = omgpkg.DefaultUserAgent
+
+	// FindConfigFile returns the omg.yaml, omg.yml or .omgrc of a directory
+	FindConfigFile = omgpkg.FindConfigFile
+
+	// LoadConfigFile reads a config file with named environments
+	LoadConfigFile = omgpkg.LoadConfigFile
+
+	// ConfigFileNames are the config files FindConfigFile looks for, in order
+	ConfigFileNames = omgpkg.ConfigFileNames
+)
+
+// Config file types
+type (
+	// ConfigFile is a project config file with named environments
+	ConfigFile = omgpkg.ConfigFile
+
+	// EnvironmentConfig is one environment of a ConfigFile
+	EnvironmentConfig = omgpkg.EnvironmentConfig
+
+	// AuthConfig holds the credentials of an environment
+	AuthConfig = omgpkg.AuthConfig
+
+	// TLSConfig holds the TLS settings of an environment
+	TLSConfig = omgpkg.TLSConfig
+)
 
 // Store operations
 var (
-	CreateStore = omgpkg.CreateStore
-	ListStores  = omgpkg.ListStores
-	StoreExists = omgpkg.StoreExists
+	CreateStore           = omgpkg.CreateStore
+	ListStores            = omgpkg.ListStores
+	StoreExists           = omgpkg.StoreExists
+	CreateStoreWithConfig = omgpkg.CreateStoreWithConfig
+	ListStoresWithConfig  = omgpkg.ListStoresWithConfig
+	StoreExistsWithConfig = omgpkg.StoreExistsWithConfig
+	CloneStore            = omgpkg.CloneStore
+	CloneStoreWithOptions = omgpkg.CloneStoreWithOptions
+)
+
+type (
+	// StoreInfo describes a store and what it holds (Client.GetStoreInfo)
+	StoreInfo = omgpkg.StoreInfo
+
+	// CloneResult is what CloneStore copied
+	CloneResult = omgpkg.CloneResult
+
+	// CloneOptions controls CloneStoreWithOptions
+	CloneOptions = omgpkg.CloneOptions
 )
 
 // Migration types and functions
@@ -57,12 +118,58 @@ type (
 	// Tracker tracks applied migrations
 	Tracker = omgpkg.Tracker
 
+	// MigrationRun is one entry in the migration run history
+	MigrationRun = omgpkg.MigrationRun
+
+	// RunStatus is the outcome of a recorded migration run
+	RunStatus = omgpkg.RunStatus
+
+	// RemoveOptions controls whether rolling back purges the tracker row
+	RemoveOptions = omgpkg.RemoveOptions
+
 	// TransformFunc is a function that transforms tuples during migration
 	TransformFunc = omgpkg.TransformFunc
+
+	// WriteOptions controls deduplication and verification in WriteTuplesBatchWithOptions
+	WriteOptions = omgpkg.WriteOptions
+
+	// DeleteOptions controls skipping and verification in DeleteTuplesBatchWithOptions
+	DeleteOptions = omgpkg.DeleteOptions
+
+	// BatchProgress reports throughput and ETA of a batched write or delete, a rename or a backup
+	BatchProgress = omgpkg.BatchProgress
+)
+
+// Tracker constructors
+var (
+	// NewTracker creates a new migration tracker
+	NewTracker = omgpkg.NewTracker
+
+	// NewTrackerForStore creates a migration tracker scoped to one store
+	NewTrackerForStore = omgpkg.NewTrackerForStore
+
+	// TrackerSchemaVersion returns the tracker table schema version
+	TrackerSchemaVersion = omgpkg.TrackerSchemaVersion
+
+	// CurrentOperator identifies who is running migrations, for the run history
+	CurrentOperator = omgpkg.CurrentOperator
 )
 
-// NewTracker creates a new migration tracker
-var NewTracker = omgpkg.NewTracker
+// TupleExpiry records when a temporary tuple should be removed
+type TupleExpiry = omgpkg.TupleExpiry
+
+// Temporary tuple operations
+var (
+	WriteTemporaryTuples = omgpkg.WriteTemporaryTuples
+	ExpireTuples         = omgpkg.ExpireTuples
+)
+
+// Migration run statuses
+const (
+	RunStatusApplied    = omgpkg.RunStatusApplied
+	RunStatusRolledBack = omgpkg.RunStatusRolledBack
+	RunStatusFailed     = omgpkg.RunStatusFailed
+)
 
 // Migration registry functions
 var (
@@ -71,6 +178,208 @@ var (
 	Reset    = omgpkg.Reset
 )
 
+// Declarative (YAML or JSON) migrations
+type (
+	DeclarativeMigration = omgpkg.DeclarativeMigration
+	MigrationOperation   = omgpkg.MigrationOperation
+)
+
+var (
+	IsDeclarativeMigration    = omgpkg.IsDeclarativeMigration
+	ParseDeclarativeMigration = omgpkg.ParseDeclarativeMigration
+	ReadDeclarativeMigration  = omgpkg.ReadDeclarativeMigration
+	LoadDeclarativeMigration  = omgpkg.LoadDeclarativeMigration
+	RegisterDeclarative       = omgpkg.RegisterDeclarative
+)
+
+// In-process migration runner
+type (
+	// Direction is the direction a migration is run in
+	Direction = omgpkg.Direction
+
+	// MigrationEvent is a progress event emitted by RunMigration and Run
+	MigrationEvent = omgpkg.MigrationEvent
+
+	// EventStream fans migration events out to subscribed channels
+	EventStream = omgpkg.EventStream
+
+	// MigrationEventType identifies a migration progress event
+	MigrationEventType = omgpkg.MigrationEventType
+
+	// RunOptions configures RunMigration
+	RunOptions = omgpkg.RunOptions
+
+	// MigrateOptions configures Run
+	MigrateOptions = omgpkg.MigrateOptions
+
+	// Migrator runs registered migrations from application code
+	Migrator = omgpkg.Migrator
+
+	// MigrationTracker records applied migrations for Run and Migrator
+	MigrationTracker = omgpkg.MigrationTracker
+
+	// MigrationStatus is the state of one migration, from Migrator.Status
+	MigrationStatus = omgpkg.MigrationStatus
+
+	// MemoryTracker is an in-memory MigrationTracker for tests
+	MemoryTracker = omgpkg.MemoryTracker
+
+	// OpenFGATracker records applied migrations as tuples in the store itself
+	OpenFGATracker = omgpkg.OpenFGATracker
+
+	// Removal is a type or relation a migration removes
+	Removal = omgpkg.Removal
+
+	// ExecutionPlan is a reviewable plan file written by 'omg plan'
+	ExecutionPlan = omgpkg.ExecutionPlan
+
+	// TrackerExport is a JSON dump of a tracker's records
+	TrackerExport = omgpkg.TrackerExport
+
+	// ExportedMigration is an applied migration in a TrackerExport
+	ExportedMigration = omgpkg.ExportedMigration
+
+	// ExportedRun is a recorded run in a TrackerExport
+	ExportedRun = omgpkg.ExportedRun
+
+	// TrackerImportResult reports what ImportTracker changed
+	TrackerImportResult = omgpkg.TrackerImportResult
+
+	// PanicError is returned when a migration function panics
+	PanicError = omgpkg.PanicError
+
+	// MigrationPlan is an ordered list of steps, some of which may run in parallel
+	MigrationPlan = omgpkg.MigrationPlan
+
+	// PlanStep is one operation of a MigrationPlan
+	PlanStep = omgpkg.PlanStep
+
+	// ApplyPlanOptions configures ApplyPlanWithOptions
+	ApplyPlanOptions = omgpkg.ApplyPlanOptions
+)
+
+const (
+	DirectionUp             = omgpkg.DirectionUp
+	DirectionDown           = omgpkg.DirectionDown
+	EventRunStarted         = omgpkg.EventRunStarted
+	EventRunFinished        = omgpkg.EventRunFinished
+	EventMigrationStarted   = omgpkg.EventMigrationStarted
+	EventMigrationCompleted = omgpkg.EventMigrationCompleted
+	EventMigrationFailed    = omgpkg.EventMigrationFailed
+	EventBatchProgress      = omgpkg.EventBatchProgress
+	EventWarning            = omgpkg.EventWarning
+	TrackingObjectType      = omgpkg.TrackingObjectType
+	TrackingUserType        = omgpkg.TrackingUserType
+	TrackingRelation        = omgpkg.TrackingRelation
+	TrackingUser            = omgpkg.TrackingUser
+	DefaultPlanWorkers      = omgpkg.DefaultPlanWorkers
+
+	// DefaultMaxConcurrentRequests is the default Config.MaxConcurrentRequests
+	DefaultMaxConcurrentRequests = omgpkg.DefaultMaxConcurrentRequests
+
+	// DefaultMaxRetries is the default Config.MaxRetries
+	DefaultMaxRetries = omgpkg.DefaultMaxRetries
+
+	// DefaultBackoffBase is the default Config.BackoffBase
+	DefaultBackoffBase = omgpkg.DefaultBackoffBase
+
+	// DefaultRequestTimeout is the default Config.RequestTimeout
+	DefaultRequestTimeout = omgpkg.DefaultRequestTimeout
+
+	// MaxReadPageSize is the largest Config.ReadPageSize
+	MaxReadPageSize = omgpkg.MaxReadPageSize
+
+	// ExecutionPlanFormat is the format version of plan files
+	ExecutionPlanFormat = omgpkg.ExecutionPlanFormat
+)
+
+// In-process migration runners
+var (
+	// RunMigration runs a migration's Up or Down function with panic recovery and timing
+	RunMigration = omgpkg.RunMigration
+
+	// Run applies or rolls back the registered migrations and records them with a tracker
+	Run = omgpkg.Run
+
+	// NewMigrator creates a Migrator for the registered migrations
+	NewMigrator = omgpkg.NewMigrator
+
+	// NewMemoryTracker creates an empty in-memory MigrationTracker
+	NewMemoryTracker = omgpkg.NewMemoryTracker
+
+	// NewOpenFGATracker creates a MigrationTracker that stores migrations as tuples
+	NewOpenFGATracker = omgpkg.NewOpenFGATracker
+
+	// CheckTrackingTypes fails when a model declares the tuple tracker's reserved types
+	CheckTrackingTypes = omgpkg.CheckTrackingTypes
+
+	// FindRemovals lists the types and relations a migration file removes
+	FindRemovals = omgpkg.FindRemovals
+
+	// NewExecutionPlan plans changes against the store's live model
+	NewExecutionPlan = omgpkg.NewExecutionPlan
+
+	// NewExecutionPlanWithOptions plans changes, e.g. model-only
+	NewExecutionPlanWithOptions = omgpkg.NewExecutionPlanWithOptions
+
+	// ModelOnlyWarnings describes changes whose tuples model-only mode leaves alone
+	ModelOnlyWarnings = omgpkg.ModelOnlyWarnings
+
+	// FormatDSL returns the canonical form of a model in DSL
+	FormatDSL = omgpkg.FormatDSL
+
+	// SetModelParser selects the DSL parser by name ("simple" or "openfga")
+	SetModelParser = omgpkg.SetModelParser
+
+	// RegisterModelParser makes a parser available to SetModelParser
+	RegisterModelParser = omgpkg.RegisterModelParser
+
+	// CurrentModelParser returns the DSL parser in use
+	CurrentModelParser = omgpkg.CurrentModelParser
+
+	// ParseExecutionPlan parses a plan file
+	ParseExecutionPlan = omgpkg.ParseExecutionPlan
+
+	// ReadExecutionPlan reads a plan file
+	ReadExecutionPlan = omgpkg.ReadExecutionPlan
+
+	// VerifyExecutionPlan checks that a plan still matches the store's live model
+	VerifyExecutionPlan = omgpkg.VerifyExecutionPlan
+
+	// ApplyExecutionPlan verifies and applies a plan
+	ApplyExecutionPlan = omgpkg.ApplyExecutionPlan
+
+	// ErrStalePlan is returned for plans made against a model the store no longer has
+	ErrStalePlan = omgpkg.ErrStalePlan
+
+	// ExportTracker dumps a tracker's applied migrations and run history
+	ExportTracker = omgpkg.ExportTracker
+
+	// ImportTracker records an export's applied migrations that a tracker lacks
+	ImportTracker = omgpkg.ImportTracker
+
+	// ParseTrackerExport parses a tracker export
+	ParseTrackerExport = omgpkg.ParseTrackerExport
+
+	// ReadTrackerExport reads a tracker export file
+	ReadTrackerExport = omgpkg.ReadTrackerExport
+
+	// NewEventStream creates an EventStream; pass its Emit method as OnEvent
+	NewEventStream = omgpkg.NewEventStream
+
+	// Warn reports a warning from inside a migration, as output and as an event
+	Warn = omgpkg.Warn
+
+	// NewPlan creates an empty MigrationPlan
+	NewPlan = omgpkg.NewPlan
+
+	// ApplyPlan runs a plan's steps, running consecutive parallel steps concurrently
+	ApplyPlan = omgpkg.ApplyPlan
+
+	// ApplyPlanWithOptions runs a plan's steps with a custom worker count
+	ApplyPlanWithOptions = omgpkg.ApplyPlanWithOptions
+)
+
 // Model parsing and state management
 type (
 	// ModelState represents the state of an authorization model
@@ -82,11 +391,32 @@ type (
 	// ModelChange represents a detected change in the model
 	ModelChange = omgpkg.ModelChange
 
+	// ChangeSet is a list of model changes with JSON/YAML serialization
+	ChangeSet = omgpkg.ChangeSet
+
 	// ChangeType represents the type of model change
 	ChangeType = omgpkg.ChangeType
 
 	// ConfidenceLevel represents confidence in rename detection
 	ConfidenceLevel = omgpkg.ConfidenceLevel
+
+	// DetectOptions controls which parts of a model are compared
+	DetectOptions = omgpkg.DetectOptions
+
+	// SimilarityScorer scores rename candidates for DetectPotentialRenames
+	SimilarityScorer = omgpkg.SimilarityScorer
+
+	// DefaultSimilarity is the built-in Levenshtein and Jaccard scorer
+	DefaultSimilarity = omgpkg.DefaultSimilarity
+
+	// TupleCounts holds tuple counts of removed types and relations for rename detection
+	TupleCounts = omgpkg.TupleCounts
+
+	// ModelParser turns a model in DSL into an authorization model
+	ModelParser = omgpkg.ModelParser
+
+	// SimpleModelParser is the built-in, dependency-free parser
+	SimpleModelParser = omgpkg.SimpleModelParser
 )
 
 // ChangeType constants
@@ -97,7 +427,12 @@ const (
 	ChangeTypeAddRelation     = omgpkg.ChangeTypeAddRelation
 	ChangeTypeRemoveRelation  = omgpkg.ChangeTypeRemoveRelation
 	ChangeTypeRenameRelation  = omgpkg.ChangeTypeRenameRelation
+	ChangeTypeMoveRelation    = omgpkg.ChangeTypeMoveRelation
 	ChangeTypeUpdateRelation  = omgpkg.ChangeTypeUpdateRelation
+	ChangeTypeUpdateTypeRestrictions = omgpkg.ChangeTypeUpdateTypeRestrictions
+	ChangeTypeAddCondition    = omgpkg.ChangeTypeAddCondition
+	ChangeTypeRemoveCondition = omgpkg.ChangeTypeRemoveCondition
+	ChangeTypeUpdateCondition = omgpkg.ChangeTypeUpdateCondition
 )
 
 // ConfidenceLevel constants
@@ -111,22 +446,209 @@ const (
 // Model operations
 var (
 	ParseDSLToModel                     = omgpkg.ParseDSLToModel
+	ParseJSONToModel                    = omgpkg.ParseJSONToModel
+	ParseModel                          = omgpkg.ParseModel
+	LoadModularModel                    = omgpkg.LoadModularModel
+	ValidateSchemaVersion               = omgpkg.ValidateSchemaVersion
+	ValidateModelSchema                 = omgpkg.ValidateModelSchema
+	IsJSONModel                         = omgpkg.IsJSONModel
+	FormatModelAsJSON                   = omgpkg.FormatModelAsJSON
+	FormatModelAsDSL                    = omgpkg.FormatModelAsDSL
+	ConvertModel                        = omgpkg.ConvertModel
 	LoadCurrentModel                    = omgpkg.LoadCurrentModel
 	LoadCurrentModelFromPath            = omgpkg.LoadCurrentModelFromPath
 	GetCurrentModel                     = omgpkg.GetCurrentModel
 	LoadModelStateFromOpenFGA           = omgpkg.LoadModelStateFromOpenFGA
+	LoadModelStateFromFile              = omgpkg.LoadModelStateFromFile
 	BuildModelState                     = omgpkg.BuildModelState
 	BuildModelStateFromAuthorizationModel = omgpkg.BuildModelStateFromAuthorizationModel
 	CompareModels                       = omgpkg.CompareModels
 	DetectChanges                       = omgpkg.DetectChanges
 	DetectPotentialRenames              = omgpkg.DetectPotentialRenames
+	DetectChangesWithOptions            = omgpkg.DetectChangesWithOptions
+	DetectPotentialRenamesWithOptions   = omgpkg.DetectPotentialRenamesWithOptions
+	CountRemovedTuples                  = omgpkg.CountRemovedTuples
+	FilterChanges                       = omgpkg.FilterChanges
+	RenderChanges                       = omgpkg.RenderChanges
+	RelationDefinitionDSL               = omgpkg.RelationDefinitionDSL
+	RemovedTypeRestrictions             = omgpkg.RemovedTypeRestrictions
+	ParseChangeSet                      = omgpkg.ParseChangeSet
+	WithAbbreviations                   = omgpkg.WithAbbreviations
+	ReadChangeSet                       = omgpkg.ReadChangeSet
 	ApplyModelFromDSL                   = omgpkg.ApplyModelFromDSL
 	ApplyModelFromFile                  = omgpkg.ApplyModelFromFile
 )
 
+// GenerateOptions controls optional parts of generated migrations
+type GenerateOptions = omgpkg.GenerateOptions
+
+// Generation strategies for GenerateOptions.Strategy
+const (
+	StrategyIncremental = omgpkg.StrategyIncremental
+	StrategyModelApply  = omgpkg.StrategyModelApply
+)
+
+// ModelLock records the authorization model the store is expected to be on
+type ModelLock = omgpkg.ModelLock
+
+// ModelLockFile is the default name of the model lock file
+const ModelLockFile = omgpkg.ModelLockFile
+
+// ModelManifestFile is the manifest of a modular model
+const ModelManifestFile = omgpkg.ModelManifestFile
+
+// HeavyTupleCount is the tuple count from which rename detection leans towards a rename
+const HeavyTupleCount = omgpkg.HeavyTupleCount
+
+// Schema versions
+const (
+	DefaultSchemaVersion = omgpkg.DefaultSchemaVersion
+	LatestSchemaVersion  = omgpkg.LatestSchemaVersion
+)
+
+// Model lock operations
+var (
+	HashModelState           = omgpkg.HashModelState
+	LoadModelLockFromOpenFGA = omgpkg.LoadModelLockFromOpenFGA
+	ReadModelLock            = omgpkg.ReadModelLock
+	WriteModelLock           = omgpkg.WriteModelLock
+	UpdateModelLock          = omgpkg.UpdateModelLock
+	VerifyModelLock          = omgpkg.VerifyModelLock
+)
+
+// Schema snapshot files kept in the migrations directory
+const (
+	SchemaSnapshotFile     = omgpkg.SchemaSnapshotFile
+	SchemaSnapshotJSONFile = omgpkg.SchemaSnapshotJSONFile
+)
+
+// Schema snapshot operations
+var (
+	SchemaSnapshotPath  = omgpkg.SchemaSnapshotPath
+	ReadSchemaSnapshot  = omgpkg.ReadSchemaSnapshot
+	WriteSchemaSnapshot = omgpkg.WriteSchemaSnapshot
+)
+
+// ModelStatus summarizes a store's latest authorization model relative to a model file
+type ModelStatus = omgpkg.ModelStatus
+
+// Model status operations
+var (
+	NewModelStatus = omgpkg.NewModelStatus
+	GetModelStatus = omgpkg.GetModelStatus
+)
+
+// Drift watching
+type (
+	// Drift describes a live model that no longer matches the expected one
+	Drift = omgpkg.Drift
+
+	// DriftWatcher periodically compares the live model with model.fga or model.lock
+	DriftWatcher = omgpkg.DriftWatcher
+)
+
+// NewDriftWatcher creates a watcher that calls onDrift when the live model drifts
+var NewDriftWatcher = omgpkg.NewDriftWatcher
+
+// Baselines
+type (
+	// BaselineOptions configures Baseline
+	BaselineOptions = omgpkg.BaselineOptions
+
+	// ModelMismatchError reports a live model that differs from the expected one
+	ModelMismatchError = omgpkg.ModelMismatchError
+)
+
+// Baseline operations
+var (
+	Baseline         = omgpkg.Baseline
+	LiveModelChanges = omgpkg.LiveModelChanges
+)
+
+// PromotionPlan lists the migrations one environment has applied and another has not
+type PromotionPlan = omgpkg.PromotionPlan
+
+// PlanPromotion compares the applied migrations of two trackers
+var PlanPromotion = omgpkg.PlanPromotion
+
+// MigrationTemplateFile is the migration template create and generate use when it exists
+const MigrationTemplateFile = omgpkg.MigrationTemplateFile
+
+// MigrationTemplateData is what a custom migration template is executed with
+type MigrationTemplateData = omgpkg.MigrationTemplateData
+
 // Migration generation
 var (
-	GenerateMigrationFromChanges = omgpkg.GenerateMigrationFromChanges
+	GenerateMigrationFromChanges            = omgpkg.GenerateMigrationFromChanges
+	GenerateMigrationFromChangesWithOptions = omgpkg.GenerateMigrationFromChangesWithOptions
+	BuildGenerateSummary                    = omgpkg.BuildGenerateSummary
+	GenerateScaffold                        = omgpkg.GenerateScaffold
+	GenerateScaffoldWithOptions             = omgpkg.GenerateScaffoldWithOptions
+	GenerateSeedMigration                   = omgpkg.GenerateSeedMigration
+	GenerateInverseMigration                = omgpkg.GenerateInverseMigration
+	GenerateSplitMigrations                 = omgpkg.GenerateSplitMigrations
+	SplitChanges                            = omgpkg.SplitChanges
+	WriteGenerateSummaries                  = omgpkg.WriteGenerateSummaries
+	WriteGenerateSummary                    = omgpkg.WriteGenerateSummary
+)
+
+// Generate summary types
+type (
+	// GenerateSummary describes a generated migration for tooling
+	GenerateSummary = omgpkg.GenerateSummary
+
+	// ChangeSummary describes one change and the operations generated for it
+	ChangeSummary = omgpkg.ChangeSummary
+
+	// SplitGroup is the part of a change set that goes into one file of a split migration
+	SplitGroup = omgpkg.SplitGroup
+)
+
+// Verification types
+type (
+	// CheckExpectation describes an authorization result expected after a migration
+	CheckExpectation = omgpkg.CheckExpectation
+
+	// CheckResult is the outcome of verifying a single expectation
+	CheckResult = omgpkg.CheckResult
+
+	// TupleMismatch is a tuple whose stored state differs from what was expected
+	TupleMismatch = omgpkg.TupleMismatch
+
+	// TupleVerificationError reports tuples that failed read-your-writes verification
+	TupleVerificationError = omgpkg.TupleVerificationError
+)
+
+// Verification functions
+var (
+	ParseCheckExpectation = omgpkg.ParseCheckExpectation
+	VerifyChecks          = omgpkg.VerifyChecks
+	WaitForTupleCount     = omgpkg.WaitForTupleCount
+)
+
+// AccessMatrix holds the results of checking every relation on an object for a list of users
+type AccessMatrix = omgpkg.AccessMatrix
+
+// Access report functions
+var (
+	AccessReport = omgpkg.AccessReport
+	ReadUserList = omgpkg.ReadUserList
+)
+
+// Changelog types
+type (
+	// MigrationMetadata contains the descriptive header of a migration file
+	MigrationMetadata = omgpkg.MigrationMetadata
+
+	// ChangelogEntry describes one applied migration in a changelog
+	ChangelogEntry = omgpkg.ChangelogEntry
+)
+
+// Changelog functions
+var (
+	ParseMigrationMetadata = omgpkg.ParseMigrationMetadata
+	BuildChangelog         = omgpkg.BuildChangelog
+	RenderChangelog        = omgpkg.RenderChangelog
 )
 
 // Helper functions for migrations
@@ -134,24 +656,144 @@ var (
 	// Tuple operations
 	ReadAllTuples          = omgpkg.ReadAllTuples
 	WriteTuplesBatch       = omgpkg.WriteTuplesBatch
+	WriteTuplesBatchWithOptions = omgpkg.WriteTuplesBatchWithOptions
+	DeleteTuplesBatchWithOptions = omgpkg.DeleteTuplesBatchWithOptions
+	VerifyTuples           = omgpkg.VerifyTuples
+	DeduplicateTuples      = omgpkg.DeduplicateTuples
 	DeleteTuplesBatch      = omgpkg.DeleteTuplesBatch
 	CountTuples            = omgpkg.CountTuples
 	BackupTuples           = omgpkg.BackupTuples
+	BackupTuplesTo         = omgpkg.BackupTuplesTo
 	RestoreTuples          = omgpkg.RestoreTuples
 
 	// Type operations
 	AddTypeToModel         = omgpkg.AddTypeToModel
 	RemoveTypeFromModel    = omgpkg.RemoveTypeFromModel
 	RenameType             = omgpkg.RenameType
+	RenameTypeWithOptions  = omgpkg.RenameTypeWithOptions
+	RenameUserType         = omgpkg.RenameUserType
+	RenameUserTypeWithOptions = omgpkg.RenameUserTypeWithOptions
+	PrefixObjectIDs        = omgpkg.PrefixObjectIDs
+	UnprefixObjectIDs      = omgpkg.UnprefixObjectIDs
 
 	// Relation operations
 	AddRelationToType      = omgpkg.AddRelationToType
 	RemoveRelationFromType = omgpkg.RemoveRelationFromType
 	UpdateRelationDefinition = omgpkg.UpdateRelationDefinition
 	RenameRelation         = omgpkg.RenameRelation
+	RenameRelationWithOptions = omgpkg.RenameRelationWithOptions
+	MoveRelation              = omgpkg.MoveRelation
+	MoveRelationWithOptions   = omgpkg.MoveRelationWithOptions
 	CopyRelation           = omgpkg.CopyRelation
 	DeleteRelation         = omgpkg.DeleteRelation
 
+	// Condition operations
+	SetCondition    = omgpkg.SetCondition
+	RemoveCondition = omgpkg.RemoveCondition
+
 	// Advanced operations
 	MigrateRelationWithTransform = omgpkg.MigrateRelationWithTransform
+
+	// State checks and idempotent operations (safe to re-run)
+	TypeExists                     = omgpkg.TypeExists
+	RelationExists                 = omgpkg.RelationExists
+	ConditionExists                = omgpkg.ConditionExists
+	AddTypeToModelIfMissing        = omgpkg.AddTypeToModelIfMissing
+	AddRelationToTypeIfMissing     = omgpkg.AddRelationToTypeIfMissing
+	RemoveRelationFromTypeIfExists = omgpkg.RemoveRelationFromTypeIfExists
+	RemoveTypeFromModelIfExists    = omgpkg.RemoveTypeFromModelIfExists
+	BackupForRemovalIfMissing      = omgpkg.BackupForRemovalIfMissing
+	RemoveConditionIfExists        = omgpkg.RemoveConditionIfExists
+)
+
+// TupleDiff is what it takes to make a store match an imported tuple file
+type TupleDiff = omgpkg.TupleDiff
+
+// Tuple file import
+var (
+	ReadTupleFile     = omgpkg.ReadTupleFile
+	DiffTuplesInScope = omgpkg.DiffTuplesInScope
+	ApplyTupleDiff    = omgpkg.ApplyTupleDiff
+)
+
+// TupleBackup holds a removed type or relation's definition and deleted tuples
+type TupleBackup = omgpkg.TupleBackup
+
+// BackupDir is where migrations store backups, one directory per version
+const BackupDir = omgpkg.BackupDir
+
+// Backup operations for destructive migrations
+var (
+	BackupFilePath        = omgpkg.BackupFilePath
+	BackupForRemoval      = omgpkg.BackupForRemoval
+	WriteTupleBackup      = omgpkg.WriteTupleBackup
+	ReadTupleBackup       = omgpkg.ReadTupleBackup
+	RestoreFromBackup     = omgpkg.RestoreFromBackup
+	RestoreVersionBackups = omgpkg.RestoreVersionBackups
+)
+
+// Checkpoint records how far a streamed tuple operation such as RenameType has got
+type Checkpoint = omgpkg.Checkpoint
+
+// CheckpointDir is where tuple operations keep their checkpoints
+const CheckpointDir = omgpkg.CheckpointDir
+
+// Checkpoints of resumable tuple operations
+var (
+	CheckpointPath  = omgpkg.CheckpointPath
+	LoadCheckpoint  = omgpkg.LoadCheckpoint
+	SaveCheckpoint  = omgpkg.SaveCheckpoint
+	ClearCheckpoint = omgpkg.ClearCheckpoint
+	WithResume      = omgpkg.WithResume
+)
+
+// RenameJournal records the phases of verify-and-swap renames; *Tracker and MemoryTracker implement it
+type RenameJournal = omgpkg.RenameJournal
+
+// RenameJournalEntry records that a rename entered a phase
+type RenameJournalEntry = omgpkg.RenameJournalEntry
+
+// RenamePhase is how far a verify-and-swap rename has got
+type RenamePhase = omgpkg.RenamePhase
+
+// Rename phases
+const (
+	RenamePhaseWrite      = omgpkg.RenamePhaseWrite
+	RenamePhaseVerify     = omgpkg.RenamePhaseVerify
+	RenamePhaseDelete     = omgpkg.RenamePhaseDelete
+	RenamePhaseDone       = omgpkg.RenamePhaseDone
+	RenamePhaseRolledBack = omgpkg.RenamePhaseRolledBack
+)
+
+// Verify-and-swap renames
+var (
+	WithVerifiedRenames = omgpkg.WithVerifiedRenames
+	WithRenameJournal   = omgpkg.WithRenameJournal
+	FinishRename        = omgpkg.FinishRename
+	RollBackRename      = omgpkg.RollBackRename
+)
+
+// ProgressReporter shows the progress of batched writes and deletes, renames and backups
+type ProgressReporter = omgpkg.ProgressReporter
+
+// ProgressEvent is a line of the JSON progress reporter
+type ProgressEvent = omgpkg.ProgressEvent
+
+// Progress reporting
+var (
+	SetProgressReporter = omgpkg.SetProgressReporter
+	NewProgressReporter = omgpkg.NewProgressReporter
+	NewTerminalProgress = omgpkg.NewTerminalProgress
+	NewJSONProgress     = omgpkg.NewJSONProgress
+)
+
+// StoreSnapshot is a point-in-time copy of a store's model and tuples
+type StoreSnapshot = omgpkg.StoreSnapshot
+
+// Store snapshots
+var (
+	TakeSnapshot    = omgpkg.TakeSnapshot
+	WriteSnapshot   = omgpkg.WriteSnapshot
+	ReadSnapshot    = omgpkg.ReadSnapshot
+	RestoreSnapshot = omgpkg.RestoreSnapshot
 )
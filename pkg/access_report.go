@@ -0,0 +1,134 @@
+package omg
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// AccessMatrix holds the result of checking every relation on an object for a list of users
+type AccessMatrix struct {
+	Object    string
+	Relations []string // Relations of the object's type, sorted
+	Users     []string
+	Allowed   [][]bool // Allowed[user][relation], indexed like Users and Relations
+}
+
+// AccessReport checks every relation of an object's type for each user
+// Example: AccessReport(ctx, client, []string{"user:anne", "user:bob"}, "document:readme")
+func AccessReport(ctx context.Context, client *Client, users []string, object string) (*AccessMatrix, error) {
+	objectType, _, found := strings.Cut(object, ":")
+	if !found || objectType == "" {
+		return nil, fmt.Errorf("invalid object '%s': expected type:id", object)
+	}
+
+	model, err := client.GetCurrentAuthorizationModel(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current model: %w", err)
+	}
+
+	var relations []string
+	typeFound := false
+	for _, typeDef := range model.GetTypeDefinitions() {
+		if typeDef.GetType() == objectType {
+			typeFound = true
+			for relation := range typeDef.GetRelations() {
+				relations = append(relations, relation)
+			}
+			break
+		}
+	}
+	if !typeFound {
+		return nil, fmt.Errorf("type '%s' not found", objectType)
+	}
+	sort.Strings(relations)
+
+	checks := make([]Tuple, 0, len(users)*len(relations))
+	for _, user := range users {
+		for _, relation := range relations {
+			checks = append(checks, Tuple{User: user, Relation: relation, Object: object})
+		}
+	}
+
+	allowed, err := client.BatchCheck(ctx, checks)
+	if err != nil {
+		return nil, err
+	}
+
+	matrix := &AccessMatrix{
+		Object:    object,
+		Relations: relations,
+		Users:     users,
+		Allowed:   make([][]bool, len(users)),
+	}
+	for i := range users {
+		matrix.Allowed[i] = allowed[i*len(relations) : (i+1)*len(relations)]
+	}
+	return matrix, nil
+}
+
+// ReadUserList reads users from a file, one per line (e.g. user:anne)
+// Blank lines and lines starting with # are ignored
+func ReadUserList(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open user list: %w", err)
+	}
+	defer file.Close()
+
+	var users []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		users = append(users, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read user list: %w", err)
+	}
+	return users, nil
+}
+
+// WriteTable writes the matrix as an aligned table with one row per user
+func (m *AccessMatrix) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "USER\t%s\n", strings.Join(m.Relations, "\t"))
+	for i, user := range m.Users {
+		cells := make([]string, len(m.Relations))
+		for j, allowed := range m.Allowed[i] {
+			cells[j] = "-"
+			if allowed {
+				cells[j] = "✓"
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%s\n", user, strings.Join(cells, "\t"))
+	}
+	return tw.Flush()
+}
+
+// WriteCSV writes the matrix as CSV (true/false cells), suited to diffing before and after a migration
+func (m *AccessMatrix) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(append([]string{"user"}, m.Relations...)); err != nil {
+		return err
+	}
+	for i, user := range m.Users {
+		record := []string{user}
+		for _, allowed := range m.Allowed[i] {
+			record = append(record, fmt.Sprintf("%t", allowed))
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
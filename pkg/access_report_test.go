@@ -0,0 +1,73 @@
+package omg_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/demetere/omg/internal/testhelpers"
+	"github.com/demetere/omg/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadUserList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.txt")
+	require.NoError(t, os.WriteFile(path, []byte("# reviewers\nuser:anne\n\n  user:bob  \n"), 0644))
+
+	users, err := omg.ReadUserList(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user:anne", "user:bob"}, users)
+}
+
+func TestAccessMatrix_Write(t *testing.T) {
+	matrix := &omg.AccessMatrix{
+		Object:    "document:readme",
+		Relations: []string{"editor", "viewer"},
+		Users:     []string{"user:anne", "user:bob"},
+		Allowed:   [][]bool{{true, true}, {false, true}},
+	}
+
+	var csvOut bytes.Buffer
+	require.NoError(t, matrix.WriteCSV(&csvOut))
+	assert.Equal(t, "user,editor,viewer\nuser:anne,true,true\nuser:bob,false,true\n", csvOut.String())
+
+	var tableOut bytes.Buffer
+	require.NoError(t, matrix.WriteTable(&tableOut))
+	assert.Contains(t, tableOut.String(), "USER       editor  viewer")
+	assert.Contains(t, tableOut.String(), "user:bob   -       ✓")
+}
+
+func TestAccessReport(t *testing.T) {
+	ctx := context.Background()
+
+	container, client := testhelpers.SetupOpenFGAContainer(t, ctx, "")
+	defer container.Terminate(ctx)
+
+	require.NoError(t, omg.ApplyModelFromDSL(ctx, client, `model
+  schema 1.1
+
+type user
+
+type document
+  relations
+    define editor: [user]
+    define viewer: [user] or editor
+`))
+
+	require.NoError(t, omg.WriteTuplesBatch(ctx, client, []omg.Tuple{
+		{User: "user:anne", Relation: "editor", Object: "document:readme"},
+		{User: "user:bob", Relation: "viewer", Object: "document:readme"},
+	}))
+
+	matrix, err := omg.AccessReport(ctx, client, []string{"user:anne", "user:bob", "user:carol"}, "document:readme")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"editor", "viewer"}, matrix.Relations)
+	assert.Equal(t, [][]bool{{true, true}, {false, true}, {false, false}}, matrix.Allowed)
+
+	_, err = omg.AccessReport(ctx, client, []string{"user:anne"}, "folder:root")
+	assert.Error(t, err)
+}
@@ -0,0 +1,134 @@
+package omg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	openfgaSdk "github.com/openfga/go-sdk"
+	"github.com/openfga/go-sdk/credentials"
+	"github.com/openfga/go-sdk/oauth2"
+	"github.com/openfga/go-sdk/oauth2/clientcredentials"
+)
+
+// tokenAuth gets and caches the access tokens of client_credentials auth
+type tokenAuth struct {
+	source   oauth2.TokenSource // Reuses a token until shortly before it expires
+	tokenURL string
+	audience string
+}
+
+// newTokenAuth builds the token source of client_credentials config, fetching tokens
+// through transport, each request bounded by timeout (0 for none)
+// config must have been validated, which turns the issuer into the token URL
+func newTokenAuth(config *credentials.Config, transport http.RoundTripper, timeout time.Duration) *tokenAuth {
+	ccConfig := clientcredentials.Config{
+		ClientID:     config.ClientCredentialsClientId,
+		ClientSecret: config.ClientCredentialsClientSecret,
+		TokenURL:     config.ClientCredentialsApiTokenIssuer,
+	}
+	if config.ClientCredentialsApiAudience != "" {
+		ccConfig.EndpointParams = map[string][]string{
+			"audience": {config.ClientCredentialsApiAudience},
+		}
+	}
+
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{Transport: transport, Timeout: timeout})
+	return &tokenAuth{
+		source:   ccConfig.TokenSource(ctx),
+		tokenURL: ccConfig.TokenURL,
+		audience: config.ClientCredentialsApiAudience,
+	}
+}
+
+// token returns the cached token, fetching a new one when it has expired
+func (a *tokenAuth) token() (*oauth2.Token, error) {
+	token, err := a.source.Token()
+	if err != nil {
+		return nil, a.explain(err)
+	}
+	return token, nil
+}
+
+// transport adds the access token to the requests sent through base
+// A nil tokenAuth returns base
+func (a *tokenAuth) transport(base http.RoundTripper) http.RoundTripper {
+	if a == nil {
+		return base
+	}
+	return &oauth2.Transport{Source: a.source, Base: base}
+}
+
+// explain turns a failed token request into an error that says what to check
+func (a *tokenAuth) explain(err error) error {
+	var retrieve *oauth2.RetrieveError
+	if errors.As(err, &retrieve) {
+		status := retrieve.Response.StatusCode
+		body := strings.TrimSpace(string(retrieve.Body))
+		if len(body) > 200 {
+			body = body[:200] + "..."
+		}
+		switch {
+		case a.audience != "" && strings.Contains(strings.ToLower(body), "audience"):
+			return fmt.Errorf("token issuer %s does not accept audience '%s' (%s): check OPENFGA_TOKEN_AUDIENCE: %w", a.tokenURL, a.audience, body, err)
+		case status == http.StatusUnauthorized || status == http.StatusForbidden || strings.Contains(body, "invalid_client"):
+			return fmt.Errorf("token issuer %s rejected the client credentials (%s): check OPENFGA_CLIENT_ID and OPENFGA_CLIENT_SECRET: %w", a.tokenURL, body, err)
+		case status == http.StatusNotFound:
+			return fmt.Errorf("token endpoint %s was not found: check OPENFGA_TOKEN_ISSUER: %w", a.tokenURL, err)
+		default:
+			return fmt.Errorf("token issuer %s returned %d %s (%s): %w", a.tokenURL, status, http.StatusText(status), body, err)
+		}
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return fmt.Errorf("token issuer %s is unreachable: check OPENFGA_TOKEN_ISSUER: %w", a.tokenURL, err)
+	}
+	return fmt.Errorf("failed to get an access token from %s: %w", a.tokenURL, err)
+}
+
+// AuthStatus is what CheckAuth found out about a client's credentials
+type AuthStatus struct {
+	Method      string    // none, token or client_credentials
+	TokenURL    string    // Token endpoint of client_credentials auth
+	TokenExpiry time.Time // Of the current client_credentials token; zero if it does not expire
+	StoreID     string
+}
+
+// CheckAuth validates the client's credentials without changing anything: it gets an
+// access token for client_credentials auth and reads the store with the credentials
+func (c *Client) CheckAuth(ctx context.Context) (AuthStatus, error) {
+	status := AuthStatus{Method: c.authMethod, StoreID: c.storeID}
+	if status.Method == "" {
+		status.Method = "none"
+	}
+
+	if c.auth != nil {
+		status.TokenURL = c.auth.tokenURL
+		token, err := c.auth.token()
+		if err != nil {
+			return status, err
+		}
+		status.TokenExpiry = token.Expiry
+	}
+
+	exists, err := c.CheckStore(ctx)
+	if err != nil {
+		var authErr openfgaSdk.FgaApiAuthenticationError
+		if errors.As(err, &authErr) {
+			if c.auth != nil {
+				return status, fmt.Errorf("OpenFGA rejected the access token: check that OPENFGA_TOKEN_AUDIENCE ('%s') is the audience it expects: %w", c.auth.audience, err)
+			}
+			return status, fmt.Errorf("OpenFGA rejected the credentials: %w", err)
+		}
+		return status, err
+	}
+	if !exists {
+		return status, fmt.Errorf("store %s does not exist", c.storeID)
+	}
+	return status, nil
+}
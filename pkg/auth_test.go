@@ -0,0 +1,144 @@
+package omg_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/demetere/omg/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTokenIssuer starts an OAuth2 token endpoint that hands out "token-1" to client
+// "omg" with secret "secret" for audience "https://fga.example"
+func newTokenIssuer(t *testing.T, requests *atomic.Int32) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		require.NoError(t, r.ParseForm())
+		w.Header().Set("Content-Type", "application/json")
+
+		id, secret, ok := r.BasicAuth()
+		if !ok {
+			id, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+		}
+		switch {
+		case r.URL.Path != "/oauth/token":
+			w.WriteHeader(http.StatusNotFound)
+		case id != "omg" || secret != "secret":
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"access_denied","error_description":"Unauthorized"}`))
+		case r.PostForm.Get("audience") != "https://fga.example":
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error":"access_denied","error_description":"Unknown audience"}`))
+		default:
+			w.Write([]byte(`{"access_token":"token-1","token_type":"Bearer","expires_in":3600}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newTokenProtectedServer starts an OpenFGA API that only accepts "token-1"
+func newTokenProtectedServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Header.Get("Authorization") != "Bearer token-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"code":"unauthenticated","message":"unauthenticated"}`))
+			return
+		}
+		if r.Method == http.MethodGet {
+			w.Write([]byte(`{"id":"01HVMMBCMGZNT3SED4Z17ECXCA","name":"test"}`))
+			return
+		}
+		emptyReadHandler(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func clientCredentialsConfig(apiURL, issuer string) omg.Config {
+	return omg.Config{
+		ApiURL:        apiURL,
+		StoreID:       "01HVMMBCMGZNT3SED4Z17ECXCA",
+		AuthMethod:    "client_credentials",
+		ClientID:      "omg",
+		ClientSecret:  "secret",
+		TokenIssuer:   issuer,
+		TokenAudience: "https://fga.example",
+		MaxRetries:    -1,
+	}
+}
+
+func TestClient_ClientCredentials(t *testing.T) {
+	var tokenRequests atomic.Int32
+	issuer := newTokenIssuer(t, &tokenRequests)
+	api := newTokenProtectedServer(t)
+
+	client, err := omg.NewClient(clientCredentialsConfig(api.URL, issuer.URL))
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), tokenRequests.Load(), "token is fetched when the client is created")
+
+	for i := 0; i < 3; i++ {
+		_, err = client.ReadAllTuples(context.Background(), omg.ReadTuplesRequest{})
+		require.NoError(t, err)
+	}
+
+	status, err := client.CheckAuth(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "client_credentials", status.Method)
+	assert.Equal(t, issuer.URL+"/oauth/token", status.TokenURL)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), status.TokenExpiry, time.Minute)
+	assert.Equal(t, int32(1), tokenRequests.Load(), "token is reused until it expires")
+}
+
+func TestNewClient_ClientCredentialsErrors(t *testing.T) {
+	var tokenRequests atomic.Int32
+	issuer := newTokenIssuer(t, &tokenRequests)
+
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+
+	tests := []struct {
+		name   string
+		modify func(*omg.Config)
+		want   string
+	}{
+		{"wrong secret", func(cfg *omg.Config) { cfg.ClientSecret = "wrong" }, "rejected the client credentials"},
+		{"wrong audience", func(cfg *omg.Config) { cfg.TokenAudience = "https://other.example" }, "does not accept audience 'https://other.example'"},
+		{"wrong path", func(cfg *omg.Config) { cfg.TokenIssuer = issuer.URL + "/token" }, "was not found"},
+		{"unreachable issuer", func(cfg *omg.Config) { cfg.TokenIssuer = unreachable.URL }, "is unreachable"},
+		{"no issuer", func(cfg *omg.Config) { cfg.TokenIssuer = "" }, "OPENFGA_TOKEN_ISSUER is required"},
+		{"invalid issuer", func(cfg *omg.Config) { cfg.TokenIssuer = "ftp://issuer.example" }, "invalid OPENFGA_TOKEN_ISSUER"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := clientCredentialsConfig("http://localhost:8080", issuer.URL)
+			tt.modify(&cfg)
+			_, err := omg.NewClient(cfg)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestClient_CheckAuth(t *testing.T) {
+	api := newTokenProtectedServer(t)
+
+	client, err := omg.NewClient(omg.Config{ApiURL: api.URL, StoreID: "01HVMMBCMGZNT3SED4Z17ECXCA", AuthMethod: "token", APIToken: "token-1"})
+	require.NoError(t, err)
+	status, err := client.CheckAuth(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "token", status.Method)
+	assert.True(t, status.TokenExpiry.IsZero())
+
+	client, err = omg.NewClient(omg.Config{ApiURL: api.URL, StoreID: "01HVMMBCMGZNT3SED4Z17ECXCA", AuthMethod: "token", APIToken: "stale", MaxRetries: -1})
+	require.NoError(t, err)
+	_, err = client.CheckAuth(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "OpenFGA rejected the credentials")
+}
@@ -0,0 +1,285 @@
+package omg
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	openfgaSdk "github.com/openfga/go-sdk"
+)
+
+// BackupDir is where migrations store backups of removed types and relations,
+// one directory per migration version, unless OMG_BACKUP_DIR names another
+const BackupDir = ".omg/backups"
+
+// backupDir returns the directory backups are kept in: OMG_BACKUP_DIR, or BackupDir
+func backupDir() string {
+	if dir := os.Getenv("OMG_BACKUP_DIR"); dir != "" {
+		return dir
+	}
+	return BackupDir
+}
+
+// TupleBackup holds what a migration removed: the type definition as it was
+// before the removal and the tuples that were deleted
+type TupleBackup struct {
+	Version        string                     `json:"version"`
+	Type           string                     `json:"type"`
+	Relation       string                     `json:"relation,omitempty"` // Empty when the whole type was removed
+	CreatedAt      time.Time                  `json:"created_at"`
+	TypeDefinition *openfgaSdk.TypeDefinition `json:"type_definition,omitempty"`
+	Tuples         []Tuple                    `json:"tuples"`
+}
+
+// BackupFilePath returns the backup file for a type (relation "") or relation within a version
+func BackupFilePath(version, objectType, relation string) string {
+	name := objectType
+	if relation != "" {
+		name += "#" + relation
+	}
+	return filepath.Join(backupDir(), version, name+".json")
+}
+
+// BackupForRemoval saves a type's definition and the tuples about to be deleted
+// Pass an empty relation to back up the whole type. Returns the backup file path
+// Example: BackupForRemoval(ctx, client, "20240101120000", "document", "viewer")
+func BackupForRemoval(ctx context.Context, client *Client, version, objectType, relation string) (string, error) {
+	model, err := client.GetCurrentAuthorizationModel(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	backup := TupleBackup{
+		Version:   version,
+		Type:      objectType,
+		Relation:  relation,
+		CreatedAt: time.Now().UTC(),
+	}
+	for _, typeDef := range model.GetTypeDefinitions() {
+		if typeDef.GetType() == objectType {
+			backup.TypeDefinition = &typeDef
+			break
+		}
+	}
+
+	// Stream the tuples into the file, so removing a huge type needs no memory for them
+	path := BackupFilePath(version, objectType, relation)
+	count, err := writeStreamedBackup(path, backup, func(fn func(Tuple) error) error {
+		req := ReadTuplesRequest{Object: objectType + ":", Relation: relation}
+		return client.IterateTuples(ctx, req, fn)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	fmt.Printf("Backed up %d tuples to %s\n", count, path)
+	return path, nil
+}
+
+// writeStreamedBackup writes backup to path with the tuples iterate yields in place of
+// backup.Tuples, and returns how many there were. A failed backup leaves no file behind
+func writeStreamedBackup(path string, backup TupleBackup, iterate func(fn func(Tuple) error) error) (int, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	backup.Tuples = []Tuple{}
+	data, err := json.MarshalIndent(backup, "", "  ")
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode backup: %w", err)
+	}
+	// The tuples are the last field: write everything before their empty array
+	head := strings.TrimSuffix(string(data), "[]\n}")
+
+	file, err := os.Create(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to write backup: %w", err)
+	}
+	writer := bufio.NewWriter(file)
+	count := 0
+	_, err = writer.WriteString(head)
+	if err == nil {
+		count, err = writeTuplesJSON(writer, "  ", iterate)
+	}
+	if err == nil {
+		_, err = writer.WriteString("\n}\n")
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return count, fmt.Errorf("failed to write backup %s: %w", path, err)
+	}
+	return count, nil
+}
+
+// WriteTupleBackup writes a backup file, creating its directory
+func WriteTupleBackup(path string, backup TupleBackup) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(backup, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode backup: %w", err)
+	}
+
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+	return nil
+}
+
+// ReadTupleBackup reads a backup file
+func ReadTupleBackup(path string) (*TupleBackup, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup: %w", err)
+	}
+
+	var backup TupleBackup
+	if err := json.Unmarshal(data, &backup); err != nil {
+		return nil, fmt.Errorf("failed to parse backup %s: %w", path, err)
+	}
+	return &backup, nil
+}
+
+// RestoreFromBackup restores a backup file
+// The type or relation is added back to the model if it is missing, then any
+// backed-up tuples not already in the store are written
+func RestoreFromBackup(ctx context.Context, client *Client, path string) error {
+	backup, err := ReadTupleBackup(path)
+	if err != nil {
+		return err
+	}
+
+	if err := restoreDefinition(ctx, client, backup); err != nil {
+		return err
+	}
+
+	existing, err := ReadAllTuples(ctx, client, backup.Type, backup.Relation)
+	if err != nil {
+		return fmt.Errorf("failed to read tuples: %w", err)
+	}
+	present := make(map[Tuple]bool, len(existing))
+	for _, tuple := range existing {
+		present[tuple] = true
+	}
+
+	var missing []Tuple
+	for _, tuple := range backup.Tuples {
+		if !present[tuple] {
+			missing = append(missing, tuple)
+		}
+	}
+
+	fmt.Printf("Restoring %d of %d tuples from %s\n", len(missing), len(backup.Tuples), path)
+	return WriteTuplesBatch(ctx, client, missing)
+}
+
+// RestoreVersionBackups restores every backup taken by a migration version
+// Whole types are restored before relations so relations have a type to go into
+func RestoreVersionBackups(ctx context.Context, client *Client, version string) error {
+	paths, err := filepath.Glob(filepath.Join(backupDir(), version, "*.json"))
+	if err != nil {
+		return fmt.Errorf("failed to list backups: %w", err)
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("no backups found for version %s in %s", version, backupDir())
+	}
+
+	sort.Slice(paths, func(i, j int) bool {
+		iRelation := strings.Contains(filepath.Base(paths[i]), "#")
+		jRelation := strings.Contains(filepath.Base(paths[j]), "#")
+		if iRelation != jRelation {
+			return !iRelation
+		}
+		return paths[i] < paths[j]
+	})
+
+	for _, path := range paths {
+		if err := RestoreFromBackup(ctx, client, path); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// restoreDefinition adds a backed-up type or relation back to the live model if it is missing
+func restoreDefinition(ctx context.Context, client *Client, backup *TupleBackup) error {
+	if backup.TypeDefinition == nil {
+		return nil
+	}
+
+	unlock := client.lockModel()
+	defer unlock()
+
+	model, err := client.GetCurrentAuthorizationModel(ctx)
+	if err != nil {
+		return err
+	}
+
+	typeIndex := -1
+	for i, typeDef := range model.TypeDefinitions {
+		if typeDef.Type == backup.Type {
+			typeIndex = i
+			break
+		}
+	}
+
+	switch {
+	case typeIndex == -1 && backup.Relation == "":
+		model.TypeDefinitions = append(model.TypeDefinitions, *backup.TypeDefinition)
+
+	case typeIndex == -1:
+		return fmt.Errorf("cannot restore relation '%s': type '%s' does not exist", backup.Relation, backup.Type)
+
+	case backup.Relation == "":
+		return nil // Type is already present
+
+	default:
+		typeDef := &model.TypeDefinitions[typeIndex]
+		relations := typeDef.GetRelations()
+		if _, exists := relations[backup.Relation]; exists {
+			return nil
+		}
+
+		userset, exists := backup.TypeDefinition.GetRelations()[backup.Relation]
+		if !exists {
+			return fmt.Errorf("backup has no definition for relation '%s'", backup.Relation)
+		}
+		if relations == nil {
+			relations = make(map[string]openfgaSdk.Userset)
+		}
+		relations[backup.Relation] = userset
+		typeDef.Relations = &relations
+
+		// Type restrictions live in the type metadata
+		backupMetadata := backup.TypeDefinition.GetMetadata()
+		if backupMeta, ok := backupMetadata.GetRelations()[backup.Relation]; ok {
+			metadata := typeDef.GetMetadata()
+			relationsMeta := metadata.GetRelations()
+			if relationsMeta == nil {
+				relationsMeta = make(map[string]openfgaSdk.RelationMetadata)
+			}
+			relationsMeta[backup.Relation] = backupMeta
+			metadata.Relations = &relationsMeta
+			typeDef.Metadata = &metadata
+		}
+	}
+
+	if err := client.WriteAuthorizationModel(ctx, model); err != nil {
+		return fmt.Errorf("failed to write model: %w", err)
+	}
+	return nil
+}
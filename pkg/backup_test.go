@@ -0,0 +1,87 @@
+package omg_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/demetere/omg/pkg"
+	openfgaSdk "github.com/openfga/go-sdk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackupFilePath(t *testing.T) {
+	assert.Equal(t, filepath.Join(".omg", "backups", "20240101120000", "document.json"),
+		omg.BackupFilePath("20240101120000", "document", ""))
+	assert.Equal(t, filepath.Join(".omg", "backups", "20240101120000", "document#viewer.json"),
+		omg.BackupFilePath("20240101120000", "document", "viewer"))
+}
+
+func TestTupleBackup_ReadWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "document#viewer.json")
+
+	relations := map[string]openfgaSdk.Userset{
+		"viewer": {This: &map[string]interface{}{}},
+	}
+	backup := omg.TupleBackup{
+		Version:  "20240101120000",
+		Type:     "document",
+		Relation: "viewer",
+		TypeDefinition: &openfgaSdk.TypeDefinition{
+			Type:      "document",
+			Relations: &relations,
+		},
+		Tuples: []omg.Tuple{
+			{User: "user:alice", Relation: "viewer", Object: "document:readme"},
+		},
+	}
+
+	require.NoError(t, omg.WriteTupleBackup(path, backup))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"user": "user:alice"`)
+
+	loaded, err := omg.ReadTupleBackup(path)
+	require.NoError(t, err)
+	assert.Equal(t, backup.Tuples, loaded.Tuples)
+	assert.Equal(t, "viewer", loaded.Relation)
+	require.NotNil(t, loaded.TypeDefinition)
+	assert.Contains(t, loaded.TypeDefinition.GetRelations(), "viewer")
+}
+
+func TestBackupForRemoval_StreamsTuples(t *testing.T) {
+	server, _ := newStoreServer(t)
+	client, err := omg.NewClient(omg.Config{ApiURL: server.URL, StoreID: testStoreID, AuthMethod: "token", APIToken: "secret"})
+	require.NoError(t, err)
+
+	dir, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(t.TempDir()))
+	defer os.Chdir(dir)
+
+	path, err := omg.BackupForRemoval(context.Background(), client, "20240101120000", "doc", "viewer")
+	require.NoError(t, err)
+
+	loaded, err := omg.ReadTupleBackup(path)
+	require.NoError(t, err)
+	assert.Equal(t, "doc", loaded.Type)
+	assert.Equal(t, "viewer", loaded.Relation)
+	require.NotNil(t, loaded.TypeDefinition)
+	assert.Equal(t, "doc", loaded.TypeDefinition.GetType())
+	require.Len(t, loaded.Tuples, 120)
+	assert.Equal(t, omg.Tuple{User: "user:0-0", Relation: "viewer", Object: "doc:1"}, loaded.Tuples[0])
+
+	// The whole store, as a JSON array
+	var out bytes.Buffer
+	count, err := omg.BackupTuplesTo(context.Background(), client, &out)
+	require.NoError(t, err)
+	assert.Equal(t, 120, count)
+	var tuples []omg.Tuple
+	require.NoError(t, json.Unmarshal(out.Bytes(), &tuples))
+	assert.Len(t, tuples, 120)
+}
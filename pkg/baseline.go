@@ -0,0 +1,118 @@
+package omg
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	openfgaSdk "github.com/openfga/go-sdk"
+)
+
+// BaselineOptions configures Baseline
+type BaselineOptions struct {
+	// Migrations to consider; nil uses the registered ones
+	Migrations []Migration
+
+	// Expected is the model the baselined migrations build up to. When set, the store's
+	// live model must match it before anything is recorded
+	Expected *openfgaSdk.AuthorizationModel
+
+	// Force records the migrations even when the live model does not match Expected
+	Force bool
+}
+
+// ModelMismatchError reports a live model that differs from the expected one
+type ModelMismatchError struct {
+	Changes ChangeSet // Changes from the expected model to the live one
+}
+
+// Error lists every difference
+func (e *ModelMismatchError) Error() string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("live model does not match the expected model (%d differences):", len(e.Changes)))
+	for _, change := range e.Changes {
+		b.WriteString("\n  " + change.Details)
+	}
+	return b.String()
+}
+
+// LiveModelChanges returns the changes from expected to the store's live model
+// Returns nil when they match
+func LiveModelChanges(ctx context.Context, client *Client, expected openfgaSdk.AuthorizationModel) (ChangeSet, error) {
+	liveState, err := LoadModelStateFromOpenFGA(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	expectedState := BuildModelState(expected)
+	if HashModelState(expectedState) == HashModelState(liveState) {
+		return nil, nil
+	}
+	return DetectChanges(expectedState, liveState), nil
+}
+
+// Baseline records the migrations up to and including target as applied without running them
+// It is for adopting omg on a store that already has the model those migrations build.
+// Migrations already applied are left alone. A live model that differs from opts.Expected
+// is returned as a *ModelMismatchError unless opts.Force is set. Returns the recorded runs
+func Baseline(ctx context.Context, client *Client, tracker MigrationTracker, target string, opts BaselineOptions) ([]MigrationRun, error) {
+	if tracker == nil {
+		return nil, fmt.Errorf("a tracker is required to record a baseline")
+	}
+
+	all := opts.Migrations
+	if all == nil {
+		all = GetAll()
+	} else {
+		all = sortMigrations(all)
+	}
+	if !hasVersion(all, target) {
+		return nil, fmt.Errorf("no migration with version %s", target)
+	}
+
+	if opts.Expected != nil {
+		changes, err := LiveModelChanges(ctx, client, *opts.Expected)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compare the live model: %w", err)
+		}
+		if _, ok := tracker.(*OpenFGATracker); ok {
+			// The tuples tracker's own types are not part of the migrated model
+			changes = changes.Filter(func(change ModelChange) bool {
+				return change.Type != ChangeTypeAddType && change.Type != ChangeTypeAddRelation ||
+					change.TypeName != TrackingObjectType && change.TypeName != TrackingUserType
+			})
+		}
+		if len(changes) > 0 && !opts.Force {
+			return nil, &ModelMismatchError{Changes: changes}
+		}
+	}
+
+	applied, err := tracker.GetApplied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var runs []MigrationRun
+	for _, m := range all {
+		if m.Version > target {
+			break
+		}
+		if _, exists := applied[m.Version]; exists {
+			continue
+		}
+
+		run := MigrationRun{
+			Version:   m.Version,
+			Name:      m.Name,
+			Status:    RunStatusApplied,
+			StartedAt: time.Now(),
+			AppliedBy: CurrentOperator(),
+		}
+		if err := tracker.RecordRunWithOptions(ctx, run, RemoveOptions{}); err != nil {
+			return runs, fmt.Errorf("failed to record migration %s: %w", m.Version, err)
+		}
+		runs = append(runs, run)
+	}
+	return runs, nil
+}
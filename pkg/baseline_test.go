@@ -0,0 +1,80 @@
+package omg_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/demetere/omg/internal/testhelpers"
+	"github.com/demetere/omg/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBaseline(t *testing.T) {
+	ctx := context.Background()
+	var calls []string
+
+	tracker := omg.NewMemoryTracker()
+	migrations := recordingMigrations(&calls, "003", "001", "002")
+	require.NoError(t, tracker.RecordRunWithOptions(ctx, omg.MigrationRun{Version: "001", Status: omg.RunStatusApplied}, omg.RemoveOptions{}))
+
+	runs, err := omg.Baseline(ctx, nil, tracker, "002", omg.BaselineOptions{Migrations: migrations})
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	assert.Equal(t, "002", runs[0].Version)
+	assert.Equal(t, "migration_002", runs[0].Name)
+
+	// Nothing runs: the migrations are only recorded
+	assert.Empty(t, calls)
+
+	applied, err := tracker.GetApplied(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, applied, "002")
+	assert.NotContains(t, applied, "003")
+
+	_, err = omg.Baseline(ctx, nil, tracker, "999", omg.BaselineOptions{Migrations: migrations})
+	assert.ErrorContains(t, err, "no migration with version 999")
+}
+
+func TestBaseline_ExpectedModel(t *testing.T) {
+	ctx := context.Background()
+
+	container, client := testhelpers.SetupOpenFGAContainer(t, ctx, `
+type user
+type document
+  relations
+    define viewer: [user]
+`)
+	defer container.Terminate(ctx)
+
+	expected, err := omg.ParseModel(`model
+  schema 1.1
+
+type user
+
+type document
+  relations
+    define viewer: [user]
+    define editor: [user]
+`)
+	require.NoError(t, err)
+
+	var calls []string
+	tracker := omg.NewMemoryTracker()
+	opts := omg.BaselineOptions{Migrations: recordingMigrations(&calls, "001"), Expected: &expected}
+
+	_, err = omg.Baseline(ctx, client, tracker, "001", opts)
+	var mismatch *omg.ModelMismatchError
+	require.ErrorAs(t, err, &mismatch)
+	require.Len(t, mismatch.Changes, 1)
+	assert.Equal(t, omg.ChangeTypeRemoveRelation, mismatch.Changes[0].Type)
+
+	applied, err := tracker.GetApplied(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, applied)
+
+	opts.Force = true
+	runs, err := omg.Baseline(ctx, client, tracker, "001", opts)
+	require.NoError(t, err)
+	assert.Len(t, runs, 1)
+}
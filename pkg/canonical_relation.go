@@ -0,0 +1,58 @@
+package omg
+
+import (
+	"sort"
+
+	openfgaSdk "github.com/openfga/go-sdk"
+)
+
+// canonicalRelation formats a relation definition for comparison: DSL with the directly
+// related types sorted and the operands of "or" and "and" flattened and sorted, so
+// "owner or [user]" and "[user] or owner" are the same definition
+// The operands of "but not" keep their order, since it matters
+func canonicalRelation(userset openfgaSdk.Userset, typeRestrictions []openfgaSdk.RelationReference) string {
+	restrictions := append([]openfgaSdk.RelationReference(nil), typeRestrictions...)
+	sort.SliceStable(restrictions, func(i, j int) bool {
+		return formatRelationReference(restrictions[i]) < formatRelationReference(restrictions[j])
+	})
+	return formatUsersetWithMetadata(canonicalUserset(userset, restrictions), restrictions)
+}
+
+// canonicalUserset returns a userset with the operands of unions and intersections in
+// canonical order
+func canonicalUserset(userset openfgaSdk.Userset, restrictions []openfgaSdk.RelationReference) openfgaSdk.Userset {
+	switch {
+	case userset.Union != nil:
+		children := canonicalOperands(userset.Union.Child, func(child openfgaSdk.Userset) *openfgaSdk.Usersets { return child.Union }, restrictions)
+		return openfgaSdk.Userset{Union: &openfgaSdk.Usersets{Child: children}}
+
+	case userset.Intersection != nil:
+		children := canonicalOperands(userset.Intersection.Child, func(child openfgaSdk.Userset) *openfgaSdk.Usersets { return child.Intersection }, restrictions)
+		return openfgaSdk.Userset{Intersection: &openfgaSdk.Usersets{Child: children}}
+
+	case userset.Difference != nil:
+		return openfgaSdk.Userset{Difference: &openfgaSdk.Difference{
+			Base:     canonicalUserset(userset.Difference.Base, restrictions),
+			Subtract: canonicalUserset(userset.Difference.Subtract, restrictions),
+		}}
+	}
+	return userset
+}
+
+// canonicalOperands flattens operands nested under the same operator, so "a or (b or c)"
+// has three operands, and sorts them by their DSL
+func canonicalOperands(children []openfgaSdk.Userset, nested func(openfgaSdk.Userset) *openfgaSdk.Usersets, restrictions []openfgaSdk.RelationReference) []openfgaSdk.Userset {
+	var operands []openfgaSdk.Userset
+	for _, child := range children {
+		if inner := nested(child); inner != nil {
+			operands = append(operands, canonicalOperands(inner.Child, nested, restrictions)...)
+			continue
+		}
+		operands = append(operands, canonicalUserset(child, restrictions))
+	}
+
+	sort.SliceStable(operands, func(i, j int) bool {
+		return formatUsersetWithMetadata(operands[i], restrictions) < formatUsersetWithMetadata(operands[j], restrictions)
+	})
+	return operands
+}
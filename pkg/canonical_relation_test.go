@@ -0,0 +1,50 @@
+package omg_test
+
+import (
+	"testing"
+
+	"github.com/demetere/omg/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func relationState(t *testing.T, definition string) string {
+	t.Helper()
+	model, err := omg.ParseDSLToModel("type user\ntype group\n  relations\n    define member: [user]\ntype document\n  relations\n    define owner: [user]\n    define editor: [user]\n    define blocked: [user]\n    define viewer: " + definition + "\n")
+	require.NoError(t, err)
+	return omg.BuildModelState(model).Types["document"].Relations["viewer"]
+}
+
+func TestBuildModelState_CanonicalRelations(t *testing.T) {
+	tests := []struct {
+		name      string
+		a, b      string
+		canonical string
+	}{
+		{"union order", "owner or [user]", "[user] or owner", "[user] or owner"},
+		{"restriction order", "[group#member, user]", "[user, group#member]", "[group#member, user]"},
+		{"nested union", "owner or (editor or [user])", "[user] or editor or owner", "[user] or editor or owner"},
+		{"intersection order", "(owner or editor) and [user]", "[user] and (editor or owner)", "[user] and (editor or owner)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.canonical, relationState(t, tt.a))
+			assert.Equal(t, tt.canonical, relationState(t, tt.b))
+		})
+	}
+}
+
+func TestBuildModelState_DifferenceKeepsOrder(t *testing.T) {
+	assert.Equal(t, "[user] but not blocked", relationState(t, "[user] but not blocked"))
+	assert.Equal(t, "blocked but not [user]", relationState(t, "blocked but not [user]"))
+}
+
+func TestDetectChanges_OperandOrderIsNotAChange(t *testing.T) {
+	live, err := omg.ParseDSLToModel("type user\ntype document\n  relations\n    define owner: [user]\n    define viewer: owner or [user]\n")
+	require.NoError(t, err)
+	file, err := omg.ParseDSLToModel("type user\ntype document\n  relations\n    define owner: [user]\n    define viewer: [user] or owner\n")
+	require.NoError(t, err)
+
+	assert.Empty(t, omg.DetectChanges(omg.BuildModelStateFromAuthorizationModel(live), omg.BuildModelState(file)))
+}
@@ -0,0 +1,228 @@
+package omg
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	openfgaSdk "github.com/openfga/go-sdk"
+)
+
+// ANSI escape codes used when rendering changes in color
+const (
+	colorReset  = "\033[0m"
+	colorBold   = "\033[1m"
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+	colorCyan   = "\033[36m"
+)
+
+// changeRow is a single rendered line of a change listing
+type changeRow struct {
+	symbol  string
+	kind    string
+	subject string
+	detail  string
+	color   string
+}
+
+// RenderChanges renders changes grouped by type, with aligned columns; condition changes
+// follow in a section of their own
+// When color is true, additions, removals, updates and renames are colored
+func RenderChanges(changes []ModelChange, color bool) string {
+	groups := make(map[string][]changeRow)
+	typeNotes := make(map[string]string)
+	var conditionRows []changeRow
+
+	for _, change := range changes {
+		row := changeRow{symbol: changeSymbol(change.Type), color: changeColor(change.Type)}
+
+		switch change.Type {
+		case ChangeTypeAddType:
+			typeNotes[change.TypeName] = "new type"
+			continue
+		case ChangeTypeRemoveType:
+			typeNotes[change.TypeName] = "removed"
+			row.kind = "type"
+			row.subject = change.TypeName
+		case ChangeTypeRenameType:
+			typeNotes[change.TypeName] = "renamed to " + change.NewValue
+			row.kind = "type"
+			row.subject = change.OldValue
+			row.detail = "→ " + change.NewValue + confidenceSuffix(change.Confidence)
+		case ChangeTypeAddRelation:
+			row.kind = "relation"
+			row.subject = change.RelationName
+			row.detail = RelationDefinitionDSL(change.NewValue)
+		case ChangeTypeRemoveRelation:
+			row.kind = "relation"
+			row.subject = change.RelationName
+			row.detail = RelationDefinitionDSL(change.OldValue)
+		case ChangeTypeUpdateRelation, ChangeTypeUpdateTypeRestrictions:
+			row.kind = "relation"
+			row.subject = change.RelationName
+			row.detail = RelationDefinitionDSL(change.OldValue) + " → " + RelationDefinitionDSL(change.NewValue)
+		case ChangeTypeRenameRelation:
+			row.kind = "relation"
+			row.subject = change.OldValue
+			row.detail = "→ " + change.NewValue + confidenceSuffix(change.Confidence)
+		case ChangeTypeMoveRelation:
+			row.kind = "relation"
+			row.subject = change.RelationName
+			row.detail = "→ " + change.TargetType + confidenceSuffix(change.Confidence)
+		case ChangeTypeAddCondition, ChangeTypeRemoveCondition, ChangeTypeUpdateCondition:
+			row.kind = "condition"
+			row.subject = change.TypeName
+			row.detail = conditionDetail(change)
+			conditionRows = append(conditionRows, row)
+			continue
+		default:
+			row.kind = string(change.Type)
+			row.detail = change.Details
+		}
+
+		groups[change.TypeName] = append(groups[change.TypeName], row)
+	}
+
+	typeNames := make([]string, 0, len(groups)+len(typeNotes))
+	for name := range groups {
+		typeNames = append(typeNames, name)
+	}
+	for name := range typeNotes {
+		if _, exists := groups[name]; !exists {
+			typeNames = append(typeNames, name)
+		}
+	}
+	sort.Strings(typeNames)
+
+	// Column widths are shared across sections so the whole listing lines up
+	kindWidth, subjectWidth := 0, 0
+	for _, rows := range groups {
+		for _, row := range rows {
+			kindWidth = max(kindWidth, len(row.kind))
+			subjectWidth = max(subjectWidth, len(row.subject))
+		}
+	}
+	for _, row := range conditionRows {
+		kindWidth = max(kindWidth, len(row.kind))
+		subjectWidth = max(subjectWidth, len(row.subject))
+	}
+
+	paint := func(code, s string) string {
+		if !color || code == "" {
+			return s
+		}
+		return code + s + colorReset
+	}
+
+	var b strings.Builder
+	for i, typeName := range typeNames {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+
+		header := "type " + typeName
+		if note, exists := typeNotes[typeName]; exists {
+			header += " (" + note + ")"
+		}
+		b.WriteString(paint(colorBold, header) + "\n")
+
+		for _, row := range groups[typeName] {
+			b.WriteString(paint(row.color, formatChangeRow(row, kindWidth, subjectWidth)) + "\n")
+		}
+	}
+
+	if len(conditionRows) > 0 {
+		if len(typeNames) > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(paint(colorBold, "conditions") + "\n")
+		for _, row := range conditionRows {
+			b.WriteString(paint(row.color, formatChangeRow(row, kindWidth, subjectWidth)) + "\n")
+		}
+	}
+
+	return b.String()
+}
+
+// formatChangeRow lays out a change row in columns of the given widths
+func formatChangeRow(row changeRow, kindWidth, subjectWidth int) string {
+	line := fmt.Sprintf("  %s %-*s  %-*s", row.symbol, kindWidth, row.kind, subjectWidth, row.subject)
+	if row.detail != "" {
+		line += "  " + row.detail
+	}
+	return strings.TrimRight(line, " ")
+}
+
+// conditionDetail shows a condition change's definition without the keyword and name
+func conditionDetail(change ModelChange) string {
+	strip := func(definition string) string {
+		return strings.TrimPrefix(definition, "condition "+change.TypeName)
+	}
+	switch change.Type {
+	case ChangeTypeAddCondition:
+		return strip(change.NewValue)
+	case ChangeTypeRemoveCondition:
+		return strip(change.OldValue)
+	}
+	return strip(change.OldValue) + " → " + strip(change.NewValue)
+}
+
+// changeSymbol returns the diff marker for a change type
+func changeSymbol(changeType ChangeType) string {
+	switch changeType {
+	case ChangeTypeAddType, ChangeTypeAddRelation, ChangeTypeAddCondition:
+		return "+"
+	case ChangeTypeRemoveType, ChangeTypeRemoveRelation, ChangeTypeRemoveCondition:
+		return "-"
+	case ChangeTypeUpdateRelation, ChangeTypeUpdateTypeRestrictions, ChangeTypeUpdateCondition:
+		return "~"
+	case ChangeTypeRenameType, ChangeTypeRenameRelation, ChangeTypeMoveRelation:
+		return "→"
+	default:
+		return "•"
+	}
+}
+
+// changeColor returns the ANSI color for a change type
+func changeColor(changeType ChangeType) string {
+	switch changeType {
+	case ChangeTypeAddType, ChangeTypeAddRelation, ChangeTypeAddCondition:
+		return colorGreen
+	case ChangeTypeRemoveType, ChangeTypeRemoveRelation, ChangeTypeRemoveCondition:
+		return colorRed
+	case ChangeTypeUpdateRelation, ChangeTypeUpdateTypeRestrictions, ChangeTypeUpdateCondition:
+		return colorYellow
+	case ChangeTypeRenameType, ChangeTypeRenameRelation, ChangeTypeMoveRelation:
+		return colorCyan
+	default:
+		return ""
+	}
+}
+
+// confidenceSuffix formats a rename confidence level for display
+func confidenceSuffix(confidence ConfidenceLevel) string {
+	if confidence == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (%s confidence)", confidence)
+}
+
+// RelationDefinitionDSL returns a relation definition as DSL, e.g. "[user] or editor"
+// Detected changes already hold DSL; definitions serialized as JSON usersets (as some
+// change files hold) are converted. Type restrictions are not part of a userset, so a
+// direct assignment in JSON renders as [user]. Anything else is returned unchanged
+func RelationDefinitionDSL(definition string) string {
+	trimmed := strings.TrimSpace(definition)
+	if !strings.HasPrefix(trimmed, "{") {
+		return definition
+	}
+
+	var userset openfgaSdk.Userset
+	if err := json.Unmarshal([]byte(trimmed), &userset); err != nil {
+		return definition
+	}
+	return formatUserset(userset)
+}
@@ -0,0 +1,64 @@
+package omg_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/demetere/omg/pkg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderChanges_GroupedAndAligned(t *testing.T) {
+	changes := []omg.ModelChange{
+		{Type: omg.ChangeTypeAddType, TypeName: "folder"},
+		{Type: omg.ChangeTypeAddRelation, TypeName: "folder", RelationName: "owner", NewValue: "[user]"},
+		{Type: omg.ChangeTypeUpdateRelation, TypeName: "document", RelationName: "viewer", OldValue: "[user]", NewValue: "[user] or editor"},
+		{Type: omg.ChangeTypeRenameRelation, TypeName: "document", RelationName: "can_edit", OldValue: "can_edit", NewValue: "editor", Confidence: omg.ConfidenceHigh},
+	}
+
+	output := omg.RenderChanges(changes, false)
+
+	expected := strings.Join([]string{
+		"type document",
+		"  ~ relation  viewer    [user] → [user] or editor",
+		"  → relation  can_edit  → editor (high confidence)",
+		"",
+		"type folder (new type)",
+		"  + relation  owner     [user]",
+		"",
+	}, "\n")
+	assert.Equal(t, expected, output)
+	assert.NotContains(t, output, "\033[")
+}
+
+func TestRenderChanges_Color(t *testing.T) {
+	changes := []omg.ModelChange{
+		{Type: omg.ChangeTypeRemoveRelation, TypeName: "document", RelationName: "viewer", OldValue: "[user]"},
+	}
+
+	output := omg.RenderChanges(changes, true)
+
+	assert.Contains(t, output, "\033[31m  - relation  viewer  [user]\033[0m")
+}
+
+func TestRenderChanges_JSONUsersetsAsDSL(t *testing.T) {
+	changes := []omg.ModelChange{
+		{
+			Type:         omg.ChangeTypeUpdateRelation,
+			TypeName:     "document",
+			RelationName: "viewer",
+			OldValue:     `{"this":{}}`,
+			NewValue:     `{"union":{"child":[{"this":{}},{"computedUserset":{"relation":"editor"}}]}}`,
+		},
+	}
+
+	output := omg.RenderChanges(changes, false)
+
+	assert.Contains(t, output, "~ relation  viewer  [user] → [user] or editor")
+}
+
+func TestRelationDefinitionDSL(t *testing.T) {
+	assert.Equal(t, "[user] or editor", omg.RelationDefinitionDSL("[user] or editor"))
+	assert.Equal(t, "owner from parent", omg.RelationDefinitionDSL(`{"tupleToUserset":{"tupleset":{"relation":"parent"},"computedUserset":{"relation":"owner"}}}`))
+	assert.Equal(t, "{not json", omg.RelationDefinitionDSL("{not json"))
+}
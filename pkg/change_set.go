@@ -0,0 +1,187 @@
+package omg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ChangeSet is the list of changes between two models
+// It marshals to stable JSON and YAML (an empty set is [] rather than null), so diff
+// results can be stored, passed from 'omg diff' to 'omg generate' or read by other tools
+type ChangeSet []ModelChange
+
+// changeTypeOrder is the order Summary lists change types in
+var changeTypeOrder = []ChangeType{
+	ChangeTypeAddType,
+	ChangeTypeRenameType,
+	ChangeTypeRemoveType,
+	ChangeTypeAddRelation,
+	ChangeTypeUpdateRelation,
+	ChangeTypeUpdateTypeRestrictions,
+	ChangeTypeRenameRelation,
+	ChangeTypeMoveRelation,
+	ChangeTypeRemoveRelation,
+	ChangeTypeAddCondition,
+	ChangeTypeUpdateCondition,
+	ChangeTypeRemoveCondition,
+}
+
+// Filter returns the changes keep returns true for
+func (c ChangeSet) Filter(keep func(ModelChange) bool) ChangeSet {
+	var filtered ChangeSet
+	for _, change := range c {
+		if keep(change) {
+			filtered = append(filtered, change)
+		}
+	}
+	return filtered
+}
+
+// Destructive returns the changes that remove or rename types, relations or conditions,
+// which lose or move tuples or break clients using the old names
+func (c ChangeSet) Destructive() ChangeSet {
+	return c.Filter(func(change ModelChange) bool {
+		switch change.Type {
+		case ChangeTypeRemoveType, ChangeTypeRemoveRelation, ChangeTypeRemoveCondition,
+			ChangeTypeRenameType, ChangeTypeRenameRelation, ChangeTypeMoveRelation:
+			return true
+		}
+		return false
+	})
+}
+
+// ByType groups the changes by change type, keeping their order within each group
+func (c ChangeSet) ByType() map[ChangeType]ChangeSet {
+	groups := make(map[ChangeType]ChangeSet)
+	for _, change := range c {
+		groups[change.Type] = append(groups[change.Type], change)
+	}
+	return groups
+}
+
+// RenameParts returns the changes in c (as detected before rename detection) that a
+// rename was built from: the removal of the old type or relation and the addition of the
+// new one, with the new type's relations. Relation moves split the same way. Use it to
+// undo a rename the user rejects
+func (c ChangeSet) RenameParts(rename ModelChange) ChangeSet {
+	return c.Filter(func(change ModelChange) bool {
+		switch rename.Type {
+		case ChangeTypeRenameType:
+			return (change.Type == ChangeTypeRemoveType && change.TypeName == rename.OldValue) ||
+				((change.Type == ChangeTypeAddType || change.Type == ChangeTypeAddRelation) && change.TypeName == rename.NewValue)
+		case ChangeTypeRenameRelation:
+			return change.TypeName == rename.TypeName &&
+				((change.Type == ChangeTypeRemoveRelation && change.RelationName == rename.OldValue) ||
+					(change.Type == ChangeTypeAddRelation && change.RelationName == rename.NewValue))
+		case ChangeTypeMoveRelation:
+			return change.RelationName == rename.RelationName &&
+				((change.Type == ChangeTypeRemoveRelation && change.TypeName == rename.TypeName) ||
+					(change.Type == ChangeTypeAddRelation && change.TypeName == rename.TargetType))
+		}
+		return false
+	})
+}
+
+// Summary counts the changes per type, e.g. "3 changes: 1 add_type, 2 add_relation"
+func (c ChangeSet) Summary() string {
+	if len(c) == 0 {
+		return "no changes"
+	}
+
+	groups := c.ByType()
+	var counts []string
+	for _, changeType := range changeTypeOrder {
+		if n := len(groups[changeType]); n > 0 {
+			counts = append(counts, fmt.Sprintf("%d %s", n, changeType))
+			delete(groups, changeType)
+		}
+	}
+	// Unknown change types (e.g. from a newer omg) are listed last
+	for _, change := range c {
+		if n := len(groups[change.Type]); n > 0 {
+			counts = append(counts, fmt.Sprintf("%d %s", n, change.Type))
+			delete(groups, change.Type)
+		}
+	}
+
+	noun := "changes"
+	if len(c) == 1 {
+		noun = "change"
+	}
+	return fmt.Sprintf("%d %s: %s", len(c), noun, strings.Join(counts, ", "))
+}
+
+// MarshalJSON implements json.Marshaler
+func (c ChangeSet) MarshalJSON() ([]byte, error) {
+	if c == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal([]ModelChange(c))
+}
+
+// MarshalYAML implements yaml.Marshaler
+func (c ChangeSet) MarshalYAML() (any, error) {
+	if c == nil {
+		return []ModelChange{}, nil
+	}
+	return []ModelChange(c), nil
+}
+
+// JSON returns the change set as indented JSON
+func (c ChangeSet) JSON() ([]byte, error) {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode changes: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// YAML returns the change set as YAML
+func (c ChangeSet) YAML() ([]byte, error) {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode changes: %w", err)
+	}
+	return data, nil
+}
+
+// ParseChangeSet parses a change set written as JSON or YAML
+func ParseChangeSet(data []byte) (ChangeSet, error) {
+	var changes ChangeSet
+	if trimmed := bytes.TrimSpace(data); bytes.HasPrefix(trimmed, []byte("[")) {
+		if err := json.Unmarshal(trimmed, &changes); err != nil {
+			return nil, fmt.Errorf("failed to parse changes: %w", err)
+		}
+	} else if err := yaml.Unmarshal(data, &changes); err != nil {
+		return nil, fmt.Errorf("failed to parse changes: %w", err)
+	}
+
+	for i, change := range changes {
+		if change.Type == "" || change.TypeName == "" {
+			return nil, fmt.Errorf("change %d is missing its type or type_name", i+1)
+		}
+		if change.RelationName != "" {
+			changes[i].OldValue = RelationDefinitionDSL(change.OldValue)
+			changes[i].NewValue = RelationDefinitionDSL(change.NewValue)
+		}
+	}
+	return changes, nil
+}
+
+// ReadChangeSet reads a change set file written by 'omg diff -format json' or 'yaml'
+func ReadChangeSet(path string) (ChangeSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read changes: %w", err)
+	}
+	changes, err := ParseChangeSet(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return changes, nil
+}
@@ -0,0 +1,122 @@
+package omg_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/demetere/omg/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sampleChangeSet() omg.ChangeSet {
+	return omg.ChangeSet{
+		{Type: omg.ChangeTypeAddType, TypeName: "folder", Details: "New type 'folder'"},
+		{Type: omg.ChangeTypeAddRelation, TypeName: "document", RelationName: "editor", NewValue: "[user]", Details: "Add editor"},
+		{Type: omg.ChangeTypeRenameRelation, TypeName: "document", OldValue: "reader", NewValue: "viewer", Confidence: omg.ConfidenceHigh, Details: "Rename reader"},
+		{Type: omg.ChangeTypeAddRelation, TypeName: "folder", RelationName: "owner", NewValue: "[user]", Details: "Add owner"},
+	}
+}
+
+func TestChangeSet_FilterAndByType(t *testing.T) {
+	changes := sampleChangeSet()
+
+	folder := changes.Filter(func(c omg.ModelChange) bool { return c.TypeName == "folder" })
+	require.Len(t, folder, 2)
+	assert.Equal(t, omg.ChangeTypeAddType, folder[0].Type)
+
+	groups := changes.ByType()
+	require.Len(t, groups[omg.ChangeTypeAddRelation], 2)
+	assert.Equal(t, "editor", groups[omg.ChangeTypeAddRelation][0].RelationName)
+	assert.Equal(t, "owner", groups[omg.ChangeTypeAddRelation][1].RelationName)
+
+	assert.Equal(t, "4 changes: 1 add_type, 2 add_relation, 1 rename_relation", changes.Summary())
+	assert.Equal(t, "no changes", omg.ChangeSet(nil).Summary())
+}
+
+func TestChangeSet_Destructive(t *testing.T) {
+	destructive := sampleChangeSet().Destructive()
+	require.Len(t, destructive, 1)
+	assert.Equal(t, omg.ChangeTypeRenameRelation, destructive[0].Type)
+
+	changes := append(sampleChangeSet(),
+		omg.ModelChange{Type: omg.ChangeTypeUpdateRelation, TypeName: "document", RelationName: "viewer"},
+		omg.ModelChange{Type: omg.ChangeTypeRemoveCondition, TypeName: "legacy"},
+		omg.ModelChange{Type: omg.ChangeTypeRemoveType, TypeName: "team"},
+	)
+	assert.Equal(t, "3 changes: 1 remove_type, 1 rename_relation, 1 remove_condition", changes.Destructive().Summary())
+	assert.Empty(t, sampleChangeSet()[:2].Destructive())
+}
+
+func TestChangeSet_JSONRoundTrip(t *testing.T) {
+	changes := sampleChangeSet()
+
+	data, err := changes.JSON()
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"type_name": "document"`)
+	assert.Contains(t, string(data), `"confidence": "high"`)
+	assert.NotContains(t, string(data), `"RelationName"`)
+
+	parsed, err := omg.ParseChangeSet(data)
+	require.NoError(t, err)
+	assert.Equal(t, changes, parsed)
+
+	empty, err := json.Marshal(omg.ChangeSet(nil))
+	require.NoError(t, err)
+	assert.Equal(t, "[]", string(empty))
+}
+
+func TestChangeSet_YAMLRoundTrip(t *testing.T) {
+	changes := sampleChangeSet()
+
+	data, err := changes.YAML()
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "type_name: document")
+
+	parsed, err := omg.ParseChangeSet(data)
+	require.NoError(t, err)
+	assert.Equal(t, changes, parsed)
+
+	empty, err := omg.ChangeSet(nil).YAML()
+	require.NoError(t, err)
+	assert.Equal(t, "[]\n", string(empty))
+
+	_, err = omg.ParseChangeSet([]byte("- details: no type\n"))
+	assert.Error(t, err)
+}
+
+func TestChangeSet_RenameParts(t *testing.T) {
+	raw := omg.ChangeSet{
+		{Type: omg.ChangeTypeRemoveRelation, TypeName: "document", RelationName: "reader"},
+		{Type: omg.ChangeTypeAddRelation, TypeName: "document", RelationName: "viewer"},
+		{Type: omg.ChangeTypeRemoveType, TypeName: "team"},
+		{Type: omg.ChangeTypeAddType, TypeName: "group"},
+		{Type: omg.ChangeTypeAddRelation, TypeName: "group", RelationName: "member"},
+		{Type: omg.ChangeTypeAddRelation, TypeName: "folder", RelationName: "viewer"},
+	}
+
+	relation := raw.RenameParts(omg.ModelChange{Type: omg.ChangeTypeRenameRelation, TypeName: "document", OldValue: "reader", NewValue: "viewer"})
+	require.Len(t, relation, 2)
+	assert.Equal(t, omg.ChangeTypeRemoveRelation, relation[0].Type)
+	assert.Equal(t, omg.ChangeTypeAddRelation, relation[1].Type)
+	assert.Equal(t, "document", relation[1].TypeName)
+
+	typ := raw.RenameParts(omg.ModelChange{Type: omg.ChangeTypeRenameType, OldValue: "team", NewValue: "group"})
+	require.Len(t, typ, 3)
+	assert.Equal(t, "team", typ[0].TypeName)
+	assert.Equal(t, "member", typ[2].RelationName)
+
+	move := raw.RenameParts(omg.ModelChange{Type: omg.ChangeTypeMoveRelation, TypeName: "document", RelationName: "viewer", TargetType: "folder"})
+	require.Len(t, move, 1)
+	assert.Equal(t, "folder", move[0].TypeName)
+}
+
+func TestParseChangeSet_ConvertsJSONUsersets(t *testing.T) {
+	data := []byte(`[{"type":"update_relation","type_name":"document","relation":"viewer","old_value":"{\"this\":{}}","new_value":"[user] or editor","details":"Updated"}]`)
+
+	changes, err := omg.ParseChangeSet(data)
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, "[user]", changes[0].OldValue)
+	assert.Equal(t, "[user] or editor", changes[0].NewValue)
+}
@@ -0,0 +1,80 @@
+package omg
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ChangelogEntry describes one applied migration in a changelog
+type ChangelogEntry struct {
+	Version     string
+	Name        string
+	AppliedAt   time.Time
+	Description string
+	Changes     []string
+}
+
+// BuildChangelog combines tracker records with parsed migration metadata
+// Entries are ordered newest first. Metadata is keyed by version and may be
+// missing for migrations whose files are no longer present
+func BuildChangelog(applied map[string]MigrationInfo, metadata map[string]MigrationMetadata) []ChangelogEntry {
+	entries := make([]ChangelogEntry, 0, len(applied))
+	for version, info := range applied {
+		entry := ChangelogEntry{
+			Version:   version,
+			Name:      info.Name,
+			AppliedAt: info.AppliedAt,
+		}
+		if meta, exists := metadata[version]; exists {
+			entry.Description = meta.Description
+			entry.Changes = meta.Changes
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Version > entries[j].Version
+	})
+	return entries
+}
+
+// RenderChangelog renders changelog entries in the given format ("markdown" or "plain")
+func RenderChangelog(entries []ChangelogEntry, format string) (string, error) {
+	var b strings.Builder
+
+	switch format {
+	case "markdown", "md", "":
+		b.WriteString("# Changelog\n")
+		for _, entry := range entries {
+			b.WriteString(fmt.Sprintf("\n## %s %s\n\n", entry.Version, entry.Name))
+			b.WriteString(fmt.Sprintf("_Applied: %s_\n", entry.AppliedAt.Format("2006-01-02 15:04:05")))
+			if entry.Description != "" {
+				b.WriteString("\n" + entry.Description + "\n")
+			}
+			if len(entry.Changes) > 0 {
+				b.WriteString("\n")
+				for _, change := range entry.Changes {
+					b.WriteString(fmt.Sprintf("- %s\n", change))
+				}
+			}
+		}
+
+	case "plain", "text":
+		for _, entry := range entries {
+			b.WriteString(fmt.Sprintf("%s  %s  (applied %s)\n", entry.Version, entry.Name, entry.AppliedAt.Format("2006-01-02 15:04:05")))
+			if entry.Description != "" {
+				b.WriteString("    " + entry.Description + "\n")
+			}
+			for _, change := range entry.Changes {
+				b.WriteString(fmt.Sprintf("    - %s\n", change))
+			}
+		}
+
+	default:
+		return "", fmt.Errorf("unknown changelog format: %s", format)
+	}
+
+	return b.String(), nil
+}
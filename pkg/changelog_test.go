@@ -0,0 +1,39 @@
+package omg_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/demetere/omg/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderChangelog_Markdown(t *testing.T) {
+	applied := map[string]omg.MigrationInfo{
+		"20240101000000": {Version: "20240101000000", Name: "initial", AppliedAt: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)},
+		"20240102000000": {Version: "20240102000000", Name: "add_folders", AppliedAt: time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)},
+	}
+	metadata := map[string]omg.MigrationMetadata{
+		"20240102000000": {Description: "Adds folders", Changes: []string{"New type 'folder'"}},
+	}
+
+	entries := omg.BuildChangelog(applied, metadata)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "20240102000000", entries[0].Version) // newest first
+
+	out, err := omg.RenderChangelog(entries, "markdown")
+	require.NoError(t, err)
+
+	assert.Contains(t, out, "# Changelog")
+	assert.Contains(t, out, "## 20240102000000 add_folders")
+	assert.Contains(t, out, "_Applied: 2024-01-02 10:00:00_")
+	assert.Contains(t, out, "Adds folders")
+	assert.Contains(t, out, "- New type 'folder'")
+	assert.Contains(t, out, "## 20240101000000 initial")
+}
+
+func TestRenderChangelog_UnknownFormat(t *testing.T) {
+	_, err := omg.RenderChangelog(nil, "xml")
+	assert.Error(t, err)
+}
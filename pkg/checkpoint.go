@@ -0,0 +1,110 @@
+package omg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CheckpointDir is where tuple operations record how far they have got, so an
+// interrupted run can be resumed, unless OMG_CHECKPOINT_DIR names another directory
+const CheckpointDir = ".omg/checkpoints"
+
+// Checkpoint records the progress of a streamed tuple operation such as RenameType
+// Everything before ContinuationToken has been processed
+type Checkpoint struct {
+	Operation         string    `json:"operation"` // e.g. "rename_type team organization"
+	StoreID           string    `json:"store_id"`
+	ContinuationToken string    `json:"continuation_token"`
+	Processed         int       `json:"processed"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// CheckpointPath returns the checkpoint file of an operation on a store
+// Each store has its own directory, so runs against several stores keep their own
+// checkpoints. Path separators in the operation, as in an ID prefix such as "acme/", become '_'
+func CheckpointPath(storeID, operation string) string {
+	dir := CheckpointDir
+	if env := os.Getenv("OMG_CHECKPOINT_DIR"); env != "" {
+		dir = env
+	}
+	name := strings.Join(strings.Fields(operation), "-")
+	name = strings.NewReplacer("/", "_", `\`, "_").Replace(name)
+	return filepath.Join(dir, storeID, name+".json")
+}
+
+// LoadCheckpoint reads the checkpoint of an operation on a store
+// Returns nil when there is none, or when it belongs to another store
+func LoadCheckpoint(storeID, operation string) (*Checkpoint, error) {
+	data, err := os.ReadFile(CheckpointPath(storeID, operation))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+
+	var checkpoint Checkpoint
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint %s: %w", CheckpointPath(storeID, operation), err)
+	}
+	if checkpoint.StoreID != storeID || checkpoint.Operation != operation {
+		return nil, nil
+	}
+	return &checkpoint, nil
+}
+
+// SaveCheckpoint writes a checkpoint, replacing the previous one of its operation
+// The file is replaced in one step, so a crash never leaves half a checkpoint
+func SaveCheckpoint(checkpoint Checkpoint) error {
+	path := CheckpointPath(checkpoint.StoreID, checkpoint.Operation)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create checkpoint directory: %w", err)
+	}
+
+	checkpoint.UpdatedAt = time.Now().UTC()
+	data, err := json.MarshalIndent(checkpoint, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoint: %w", err)
+	}
+	if err := os.WriteFile(path+".tmp", append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	return nil
+}
+
+// ClearCheckpoint removes the checkpoint of a finished operation on a store
+func ClearCheckpoint(storeID, operation string) error {
+	if err := os.Remove(CheckpointPath(storeID, operation)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove checkpoint: %w", err)
+	}
+	return nil
+}
+
+// resumeKey is the context key WithResume stores its flag under
+type resumeKey struct{}
+
+// WithResume returns a context whose tuple operations continue from their checkpoints
+// instead of starting over. Setting OMG_RESUME=true does the same, e.g. for migration
+// programs run by 'omg up -resume'
+func WithResume(ctx context.Context) context.Context {
+	return context.WithValue(ctx, resumeKey{}, true)
+}
+
+// resuming reports whether tuple operations should continue from their checkpoints
+func resuming(ctx context.Context) bool {
+	if resume, ok := ctx.Value(resumeKey{}).(bool); ok {
+		return resume
+	}
+	resume, _ := strconv.ParseBool(os.Getenv("OMG_RESUME"))
+	return resume
+}
@@ -0,0 +1,57 @@
+package omg_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/demetere/omg/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckpoint_SaveLoadClear(t *testing.T) {
+	dir, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(t.TempDir()))
+	defer os.Chdir(dir)
+
+	assert.Equal(t, filepath.Join(".omg", "checkpoints", "01HSTORE", "rename_type-team-organization.json"), omg.CheckpointPath("01HSTORE", "rename_type team organization"))
+
+	checkpoint, err := omg.LoadCheckpoint("01HSTORE", "rename_type team organization")
+	require.NoError(t, err)
+	assert.Nil(t, checkpoint, "no checkpoint yet")
+
+	require.NoError(t, omg.SaveCheckpoint(omg.Checkpoint{Operation: "rename_type team organization", StoreID: "01HSTORE", ContinuationToken: "abc", Processed: 2000}))
+	checkpoint, err = omg.LoadCheckpoint("01HSTORE", "rename_type team organization")
+	require.NoError(t, err)
+	require.NotNil(t, checkpoint)
+	assert.Equal(t, "abc", checkpoint.ContinuationToken)
+	assert.Equal(t, 2000, checkpoint.Processed)
+	assert.False(t, checkpoint.UpdatedAt.IsZero())
+
+	checkpoint, err = omg.LoadCheckpoint("01HOTHER", "rename_type team organization")
+	require.NoError(t, err)
+	assert.Nil(t, checkpoint, "checkpoints of other stores are ignored")
+
+	// Another store's run keeps its own checkpoint
+	require.NoError(t, omg.SaveCheckpoint(omg.Checkpoint{Operation: "rename_type team organization", StoreID: "01HOTHER", ContinuationToken: "xyz", Processed: 1000}))
+	checkpoint, err = omg.LoadCheckpoint("01HSTORE", "rename_type team organization")
+	require.NoError(t, err)
+	require.NotNil(t, checkpoint)
+	assert.Equal(t, "abc", checkpoint.ContinuationToken)
+
+	require.NoError(t, omg.ClearCheckpoint("01HSTORE", "rename_type team organization"))
+	assert.NoFileExists(t, omg.CheckpointPath("01HSTORE", "rename_type team organization"))
+	assert.FileExists(t, omg.CheckpointPath("01HOTHER", "rename_type team organization"))
+	require.NoError(t, omg.ClearCheckpoint("01HSTORE", "rename_type team organization"), "clearing twice is fine")
+}
+
+func TestStateDirectories_Env(t *testing.T) {
+	t.Setenv("OMG_CHECKPOINT_DIR", filepath.Join("tmp", "checkpoints"))
+	t.Setenv("OMG_BACKUP_DIR", filepath.Join("tmp", "backups"))
+
+	assert.Equal(t, filepath.Join("tmp", "checkpoints", "01HSTORE", "rename_type-team-organization.json"), omg.CheckpointPath("01HSTORE", "rename_type team organization"))
+	assert.Equal(t, filepath.Join("tmp", "checkpoints", "01HSTORE", "prefix_ids-team-acme_.json"), omg.CheckpointPath("01HSTORE", "prefix_ids team acme/"))
+	assert.Equal(t, filepath.Join("tmp", "backups", "20240101000000", "document#viewer.json"), omg.BackupFilePath("20240101000000", "document", "viewer"))
+}
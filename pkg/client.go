@@ -1,35 +1,105 @@
 package omg
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
+	"sort"
 	"strings"
+	"time"
 
 	openfgaSdk "github.com/openfga/go-sdk"
 	"github.com/openfga/go-sdk/client"
 	"github.com/openfga/go-sdk/credentials"
+	"github.com/openfga/go-sdk/telemetry"
 )
 
 // Client wraps the OpenFGA SDK client with convenient methods
+// A Client is safe for concurrent use by multiple goroutines: its fields do not change
+// after NewClient, the SDK client keeps no per-request state, at most
+// Config.MaxConcurrentRequests requests are in flight at once, and model helpers
+// (AddTypeToModel, AddRelationToType, ...) take turns changing the model
 type Client struct {
-	sdk     *client.OpenFgaClient
-	storeID string
+	sdk                  *client.OpenFgaClient
+	storeID              string
+	authorizationModelID string
+	schemaVersion        string
+	authMethod           string
+	auth                 *tokenAuth // Nil unless auth method is client_credentials
+	pool                 *clientPool
 }
 
 // Config holds OpenFGA client configuration
 type Config struct {
 	ApiURL        string
 	StoreID       string
+	StoreName     string // Looked up by NewClient when StoreID is empty; must name exactly one store
 	AuthMethod    string // "none", "token", or "client_credentials"
 	APIToken      string
 	ClientID      string
 	ClientSecret  string
 	TokenIssuer   string
 	TokenAudience string
+
+	// AuthorizationModelID validates tuple writes and checks against a specific model
+	// instead of the store's latest one. Leave empty to use the latest model
+	AuthorizationModelID string
+
+	// SchemaVersion, when set, is the schema version of every model the client writes,
+	// whatever the model declares, e.g. "1.2". Leave empty to keep the declared version
+	SchemaVersion string
+
+	// DisableWritePacing turns off pacing tuple writes by the server's rate-limit headers
+	DisableWritePacing bool
+
+	// MaxConcurrentRequests caps the API requests the client and its clones have in
+	// flight at once, e.g. from parallel plan steps. 0 means DefaultMaxConcurrentRequests
+	MaxConcurrentRequests int
+
+	// MaxRetries is how often a tuple read, write or delete that fails with a 429, a 5xx
+	// or a network error is retried. Writes that may have been applied are retried a tuple
+	// at a time. 0 means DefaultMaxRetries; negative disables retries
+	MaxRetries int
+
+	// BackoffBase is the wait before the first retry, doubled for each further one (with
+	// jitter, at most 30s). 0 means DefaultBackoffBase
+	BackoffBase time.Duration
+
+	// RequestsPerSecond caps the API requests the client and its clones send per second
+	// 0 means no limit
+	RequestsPerSecond float64
+
+	// RequestTimeout bounds each API request, so a hung server fails the request (which
+	// is then retried) instead of blocking forever. 0 means DefaultRequestTimeout;
+	// negative disables it. The context passed to the client's methods is honored either way
+	RequestTimeout time.Duration
+
+	// ReadPageSize is how many tuples each read asks for, 1 to MaxReadPageSize
+	// 0 lets the server choose (50 in OpenFGA)
+	ReadPageSize int
+
+	// TLSCAFile is a PEM bundle of CAs to trust in addition to the system ones, e.g. a
+	// corporate CA
+	TLSCAFile string
+
+	// TLSClientCert and TLSClientKey are PEM files of a client certificate for mutual TLS
+	TLSClientCert string
+	TLSClientKey  string
+
+	// InsecureSkipVerify accepts any server certificate. Only for testing
+	InsecureSkipVerify bool
+
+	// ProxyURL is the proxy API requests go through, e.g. http://proxy.corp:3128
+	// Leave empty to use HTTPS_PROXY, HTTP_PROXY and NO_PROXY
+	ProxyURL string
+
+	// Headers are sent with every API request, e.g. a tenant, tracing or API gateway key
+	// header. The Authorization header of token auth takes precedence
+	Headers map[string]string
+
+	// UserAgent replaces the default User-Agent, DefaultUserAgent()
+	UserAgent string
 }
 
 // NewClient creates a new OpenFGA client from configuration
@@ -37,13 +107,54 @@ func NewClient(cfg Config) (*Client, error) {
 	if cfg.ApiURL == "" {
 		return nil, fmt.Errorf("OPENFGA_API_URL is required")
 	}
+	if cfg.StoreID == "" && cfg.StoreName == "" {
+		return nil, fmt.Errorf("OPENFGA_STORE_ID or OPENFGA_STORE_NAME is required")
+	}
+
+	c, err := newClient(cfg)
+	if err != nil {
+		return nil, err
+	}
 	if cfg.StoreID == "" {
-		return nil, fmt.Errorf("OPENFGA_STORE_ID is required")
+		storeID, err := c.findStoreID(context.Background(), cfg.StoreName)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.sdk.SetStoreId(storeID); err != nil {
+			return nil, fmt.Errorf("invalid ID %s of store '%s': %w", storeID, cfg.StoreName, err)
+		}
+		c.storeID = storeID
+	}
+	return c, nil
+}
+
+// newClient creates a client from cfg without requiring a store, e.g. to create or list stores
+func newClient(cfg Config) (*Client, error) {
+	if cfg.ApiURL == "" {
+		return nil, fmt.Errorf("OPENFGA_API_URL is required")
+	}
+	if cfg.SchemaVersion != "" {
+		if err := ValidateSchemaVersion(cfg.SchemaVersion); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.ReadPageSize < 0 || cfg.ReadPageSize > MaxReadPageSize {
+		return nil, fmt.Errorf("read page size %d is out of range: expected 1 to %d", cfg.ReadPageSize, MaxReadPageSize)
 	}
 
 	configuration := &client.ClientConfiguration{
-		ApiUrl:  cfg.ApiURL,
-		StoreId: cfg.StoreID,
+		ApiUrl:    cfg.ApiURL,
+		StoreId:   cfg.StoreID,
+		UserAgent: cfg.UserAgent,
+	}
+	if configuration.UserAgent == "" {
+		configuration.UserAgent = DefaultUserAgent()
+	}
+	if len(cfg.Headers) > 0 {
+		configuration.DefaultHeaders = make(map[string]string, len(cfg.Headers))
+		for name, value := range cfg.Headers {
+			configuration.DefaultHeaders[name] = value
+		}
 	}
 
 	// Configure authentication
@@ -62,6 +173,9 @@ func NewClient(cfg Config) (*Client, error) {
 		if cfg.ClientID == "" || cfg.ClientSecret == "" {
 			return nil, fmt.Errorf("OPENFGA_CLIENT_ID and OPENFGA_CLIENT_SECRET are required for client_credentials auth")
 		}
+		if cfg.TokenIssuer == "" {
+			return nil, fmt.Errorf("OPENFGA_TOKEN_ISSUER is required for client_credentials auth")
+		}
 		configuration.Credentials = &credentials.Credentials{
 			Method: credentials.CredentialsMethodClientCredentials,
 			Config: &credentials.Config{
@@ -71,28 +185,158 @@ func NewClient(cfg Config) (*Client, error) {
 				ClientCredentialsApiAudience:    cfg.TokenAudience,
 			},
 		}
+		// Turns the issuer into the token URL
+		if err := configuration.Credentials.ValidateCredentialsConfig(); err != nil {
+			return nil, fmt.Errorf("invalid OPENFGA_TOKEN_ISSUER: %w", err)
+		}
 	case "none", "":
 		// No authentication
 	default:
 		return nil, fmt.Errorf("unknown auth method: %s", cfg.AuthMethod)
 	}
 
+	transport, err := newTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var auth *tokenAuth
+	if cfg.AuthMethod == "client_credentials" {
+		// Tokens are fetched through the same TLS and proxy settings. The first one is
+		// fetched now, so bad credentials fail here rather than in the middle of a migration
+		auth = newTokenAuth(configuration.Credentials.Config, transport, requestTimeout(cfg.RequestTimeout))
+		if _, err := auth.token(); err != nil {
+			return nil, err
+		}
+	}
+	configuration.HTTPClient = sdkHTTPClient(configuration, auth.transport(transport), !cfg.DisableWritePacing)
+	configuration.Telemetry = telemetry.DefaultTelemetryConfiguration()
+
 	sdkClient, err := client.NewSdkClient(configuration)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create OpenFGA client: %w", err)
 	}
+	warmTelemetry(configuration.Telemetry)
 
 	return &Client{
-		sdk:     sdkClient,
-		storeID: cfg.StoreID,
+		sdk:                  sdkClient,
+		storeID:              cfg.StoreID,
+		authorizationModelID: cfg.AuthorizationModelID,
+		schemaVersion:        cfg.SchemaVersion,
+		authMethod:           cfg.AuthMethod,
+		auth:                 auth,
+		pool:                 newClientPool(cfg),
 	}, nil
 }
 
+// sdkHTTPClient builds the SDK's HTTP client over transport, with writes paced by
+// rate-limit headers unless paced is false
+// The SDK only applies credentials to HTTP clients it creates itself, so the API token
+// header is applied here; client_credentials tokens are added by transport
+func sdkHTTPClient(configuration *client.ClientConfiguration, transport http.RoundTripper, paced bool) *http.Client {
+	if configuration.Credentials != nil {
+		if header := configuration.Credentials.GetApiTokenHeader(); header != nil {
+			if configuration.DefaultHeaders == nil {
+				configuration.DefaultHeaders = make(map[string]string)
+			}
+			configuration.DefaultHeaders[header.Key] = header.Value
+		}
+	}
+
+	if paced {
+		transport = newWritePacer(transport)
+	}
+	return &http.Client{Transport: transport}
+}
+
+// WithAuthorizationModelID returns a copy of the client whose tuple writes and checks
+// are validated against the given authorization model
+// Tuple reads are not model-specific in OpenFGA and are unaffected. Model reads and
+// writes (GetCurrentModel, AddRelationToType, ...) still work on the latest model
+// Example: omg.RenameRelation(ctx, client.WithAuthorizationModelID(modelID), "team", "admin", "owner")
+func (c *Client) WithAuthorizationModelID(modelID string) *Client {
+	scoped := *c
+	scoped.authorizationModelID = modelID
+	return &scoped
+}
+
+// GetAuthorizationModelID returns the model the client is scoped to, or "" for the latest model
+func (c *Client) GetAuthorizationModelID() string {
+	return c.authorizationModelID
+}
+
+// writeOptions returns the SDK options for tuple writes and deletes
+func (c *Client) writeOptions() client.ClientWriteOptions {
+	options := client.ClientWriteOptions{}
+	if c.authorizationModelID != "" {
+		options.AuthorizationModelId = openfgaSdk.PtrString(c.authorizationModelID)
+	}
+	return options
+}
+
+// write sends a tuple write or delete request
+// The request is only sent again when it certainly was not applied (see unsentError).
+// After other transient failures it may have been, so its tuples are written or deleted
+// one request at a time instead, treating a tuple already written (or deleted) as done
+func (c *Client) write(ctx context.Context, body client.ClientWriteRequest) error {
+	err := c.retryOn(ctx, unsentError, func() error {
+		return c.send(ctx, body)
+	})
+	if err == nil || ctx.Err() != nil || c.retries().maxRetries == 0 {
+		return err
+	}
+
+	reason, _, ok := transientError(err)
+	if !ok {
+		return err
+	}
+	fmt.Printf("%s, writing the request's %d tuples one at a time\n", reason, len(body.Writes)+len(body.Deletes))
+	return c.writeEach(ctx, body)
+}
+
+// writeEach writes and deletes the tuples of body one request at a time, retrying
+// transient failures; a tuple already written, or already deleted, counts as done
+func (c *Client) writeEach(ctx context.Context, body client.ClientWriteRequest) error {
+	for _, key := range body.Writes {
+		single := client.ClientWriteRequest{Writes: []openfgaSdk.TupleKey{key}}
+		err := c.retry(ctx, func() error {
+			if err := c.send(ctx, single); err != nil && !isTupleExistsError(err) {
+				return err
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to write tuple %s %s %s: %w", key.User, key.Relation, key.Object, err)
+		}
+	}
+
+	for _, key := range body.Deletes {
+		single := client.ClientWriteRequest{Deletes: []openfgaSdk.TupleKeyWithoutCondition{key}}
+		err := c.retry(ctx, func() error {
+			if err := c.send(ctx, single); err != nil && !isTupleMissingError(err) {
+				return err
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to delete tuple %s %s %s: %w", key.User, key.Relation, key.Object, err)
+		}
+	}
+	return nil
+}
+
+// send sends a tuple write or delete request once
+func (c *Client) send(ctx context.Context, body client.ClientWriteRequest) error {
+	return c.call(ctx, func(ctx context.Context) error {
+		_, err := c.sdk.Write(ctx).Body(body).Options(c.writeOptions()).Execute()
+		return err
+	})
+}
+
 // Tuple represents an OpenFGA relationship tuple
 type Tuple struct {
-	User     string
-	Relation string
-	Object   string
+	User     string `json:"user"`
+	Relation string `json:"relation"`
+	Object   string `json:"object"`
 }
 
 // ReadTuplesRequest defines parameters for reading tuples
@@ -114,8 +358,7 @@ func (c *Client) WriteTuple(ctx context.Context, tuple Tuple) error {
 		},
 	}
 
-	_, err := c.sdk.Write(ctx).Body(body).Execute()
-	return err
+	return c.write(ctx, body)
 }
 
 // WriteTuples writes multiple tuples in a single request
@@ -137,8 +380,7 @@ func (c *Client) WriteTuples(ctx context.Context, tuples []Tuple) error {
 		Writes: keys,
 	}
 
-	_, err := c.sdk.Write(ctx).Body(body).Execute()
-	return err
+	return c.write(ctx, body)
 }
 
 // DeleteTuple deletes a single tuple
@@ -153,8 +395,7 @@ func (c *Client) DeleteTuple(ctx context.Context, tuple Tuple) error {
 		},
 	}
 
-	_, err := c.sdk.Write(ctx).Body(body).Execute()
-	return err
+	return c.write(ctx, body)
 }
 
 // DeleteTuples deletes multiple tuples in a single request
@@ -176,90 +417,171 @@ func (c *Client) DeleteTuples(ctx context.Context, tuples []Tuple) error {
 		Deletes: keys,
 	}
 
-	_, err := c.sdk.Write(ctx).Body(body).Execute()
-	return err
+	return c.write(ctx, body)
 }
 
 // ReadAllTuples reads all tuples matching the request parameters
-// Use empty strings to match all values for that parameter
-// Note: OpenFGA API requires at least an object type prefix when filtering
+// Use empty strings to match all values for that parameter. An Object ending in ':' (e.g.
+// "document:") matches every object of that type
+// The tuples are held in memory; use IterateTuples for large stores
 func (c *Client) ReadAllTuples(ctx context.Context, req ReadTuplesRequest) ([]Tuple, error) {
-	var allTuples []Tuple
-	continuationToken := ""
-
-	for {
-		body := client.ClientReadRequest{}
+	var tuples []Tuple
+	err := c.IterateTuples(ctx, req, func(tuple Tuple) error {
+		tuples = append(tuples, tuple)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tuples, nil
+}
 
-		// OpenFGA requires tuple_key to have at least object type if any field is set
-		// If only user/relation is set without object, we need to provide empty object prefix
-		hasFilter := req.User != "" || req.Relation != "" || req.Object != ""
+// IterateTuples calls fn with each tuple matching the request, reading a page at a time
+// (Config.ReadPageSize), so only one page is held in memory. An error from fn stops the
+// iteration and is returned
+// Filters are applied by the server where the Read API supports them: a full object, or an
+// object type with a user. Other filters read the whole store and filter page by page
+func (c *Client) IterateTuples(ctx context.Context, req ReadTuplesRequest, fn func(Tuple) error) error {
+	return c.iteratePages(ctx, req, "", func(page []Tuple, _ string) error {
+		for _, tuple := range page {
+			if err := fn(tuple); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
 
-		if hasFilter {
-			// OpenFGA API requires object type when filtering by user or relation
-			// If we don't have an object filter, or if object is just a type prefix ending in ":"
-			// we need to filter client-side
-			objectIsTypeOnly := req.Object != "" && req.Object[len(req.Object)-1] == ':'
+// iteratePages calls fn with each page of tuples matching the request, starting at
+// continuationToken ("" for the first page), and the token of the page after it ("" after
+// the last page), e.g. to checkpoint where a long-running operation has got to
+func (c *Client) iteratePages(ctx context.Context, req ReadTuplesRequest, continuationToken string, fn func(page []Tuple, next string) error) error {
+	objectIsTypeOnly := strings.HasSuffix(req.Object, ":")
 
-			if req.Object == "" && (req.User != "" || req.Relation != "") {
-				return c.readAndFilter(ctx, req)
-			}
+	// The Read API needs an object type to filter on
+	if req.Object == "" && (req.User != "" || req.Relation != "") {
+		return c.readPages(ctx, client.ClientReadRequest{}, req.matches, continuationToken, fn)
+	}
+	if objectIsTypeOnly && req.User == "" && c.pool != nil && c.pool.noTypeReads.Load() {
+		return c.readPages(ctx, client.ClientReadRequest{}, req.matches, continuationToken, fn)
+	}
 
-			if objectIsTypeOnly {
-				// Filter by object type prefix client-side
-				return c.readAndFilterByType(ctx, req)
-			}
+	body := client.ClientReadRequest{}
+	if req.User != "" {
+		body.User = openfgaSdk.PtrString(req.User)
+	}
+	if req.Relation != "" {
+		body.Relation = openfgaSdk.PtrString(req.Relation)
+	}
+	if req.Object != "" {
+		body.Object = openfgaSdk.PtrString(req.Object)
+	}
 
-			// Set filters
-			if req.User != "" {
-				body.User = openfgaSdk.PtrString(req.User)
-			}
-			if req.Relation != "" {
-				body.Relation = openfgaSdk.PtrString(req.Relation)
-			}
-			if req.Object != "" {
-				body.Object = openfgaSdk.PtrString(req.Object)
-			}
+	read := false
+	err := c.readPages(ctx, body, nil, continuationToken, func(page []Tuple, next string) error {
+		read = true
+		return fn(page, next)
+	})
+	var validationErr openfgaSdk.FgaApiValidationError
+	if err != nil && !read && objectIsTypeOnly && errors.As(err, &validationErr) {
+		// OpenFGA only reads by object type together with a user; remember that, so
+		// later reads by type go straight to the fallback
+		if req.User == "" && c.pool != nil {
+			c.pool.noTypeReads.Store(true)
 		}
+		return c.readPages(ctx, client.ClientReadRequest{}, req.matches, continuationToken, fn)
+	}
+	return err
+}
 
-		options := client.ClientReadOptions{}
-		if continuationToken != "" {
-			options.ContinuationToken = openfgaSdk.PtrString(continuationToken)
+// readPages calls fn with every page of tuples matching body, from continuationToken on,
+// keeping the tuples keep accepts (all when keep is nil)
+func (c *Client) readPages(ctx context.Context, body client.ClientReadRequest, keep func(Tuple) bool, continuationToken string, fn func(page []Tuple, next string) error) error {
+	for {
+		// Stop between pages once the caller gives up
+		if err := ctx.Err(); err != nil {
+			return err
 		}
 
-		response, err := c.sdk.Read(ctx).Body(body).Options(options).Execute()
+		response, err := c.readPage(ctx, body, continuationToken)
 		if err != nil {
-			return nil, fmt.Errorf("failed to read tuples: %w", err)
+			return fmt.Errorf("failed to read tuples: %w", err)
 		}
 
 		// Convert SDK tuples to our Tuple type
+		page := make([]Tuple, 0, len(response.GetTuples()))
 		for _, t := range response.GetTuples() {
 			key := t.GetKey()
-			allTuples = append(allTuples, Tuple{
+			tuple := Tuple{
 				User:     key.GetUser(),
 				Relation: key.GetRelation(),
 				Object:   key.GetObject(),
-			})
+			}
+			if keep == nil || keep(tuple) {
+				page = append(page, tuple)
+			}
 		}
 
 		// Check if there are more pages
 		continuationToken = response.GetContinuationToken()
+		if err := fn(page, continuationToken); err != nil {
+			return err
+		}
 		if continuationToken == "" {
-			break
+			return nil
 		}
 	}
+}
+
+// BatchCheck checks whether each tuple's user has the relation on the object
+// Results are returned in the same order as the input
+func (c *Client) BatchCheck(ctx context.Context, checks []Tuple) ([]bool, error) {
+	if len(checks) == 0 {
+		return nil, nil
+	}
 
-	return allTuples, nil
+	body := make(client.ClientBatchCheckBody, len(checks))
+	for i, check := range checks {
+		body[i] = client.ClientCheckRequest{
+			User:     check.User,
+			Relation: check.Relation,
+			Object:   check.Object,
+		}
+	}
+
+	options := client.ClientBatchCheckOptions{}
+	if c.authorizationModelID != "" {
+		options.AuthorizationModelId = openfgaSdk.PtrString(c.authorizationModelID)
+	}
+
+	var response *client.ClientBatchCheckResponse
+	err := c.call(ctx, func(ctx context.Context) error {
+		var err error
+		response, err = c.sdk.BatchCheck(ctx).Body(body).Options(options).Execute()
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to run batch check: %w", err)
+	}
+
+	allowed := make([]bool, len(checks))
+	for i, single := range *response {
+		if single.Error != nil {
+			return nil, fmt.Errorf("check %s %s %s failed: %w", checks[i].User, checks[i].Relation, checks[i].Object, single.Error)
+		}
+		allowed[i] = single.GetAllowed()
+	}
+
+	return allowed, nil
 }
 
 // GetCurrentModel retrieves the current authorization model as DSL string
 func (c *Client) GetCurrentModel(ctx context.Context) (string, error) {
-	response, err := c.sdk.ReadLatestAuthorizationModel(ctx).Execute()
+	model, err := c.GetCurrentAuthorizationModel(ctx)
 	if err != nil {
-		return "", fmt.Errorf("failed to read authorization model: %w", err)
+		return "", err
 	}
 
-	model := response.GetAuthorizationModel()
-
 	// Convert model to DSL format
 	dsl := formatModelAsDSL(model)
 	return dsl, nil
@@ -287,7 +609,14 @@ func formatModelAsDSL(model openfgaSdk.AuthorizationModel) string {
 				relationsMetadata = metadata.GetRelations()
 			}
 
-			for relName, userset := range relations {
+			relNames := make([]string, 0, len(relations))
+			for relName := range relations {
+				relNames = append(relNames, relName)
+			}
+			sort.Strings(relNames)
+
+			for _, relName := range relNames {
+				userset := relations[relName]
 				var typeRestrictions []openfgaSdk.RelationReference
 				if relMeta, exists := relationsMetadata[relName]; exists {
 					typeRestrictions = relMeta.GetDirectlyRelatedUserTypes()
@@ -298,6 +627,17 @@ func formatModelAsDSL(model openfgaSdk.AuthorizationModel) string {
 		dsl.WriteString("\n")
 	}
 
+	// Conditions follow the types, sorted by name
+	conditions := model.GetConditions()
+	conditionNames := make([]string, 0, len(conditions))
+	for name := range conditions {
+		conditionNames = append(conditionNames, name)
+	}
+	sort.Strings(conditionNames)
+	for _, name := range conditionNames {
+		dsl.WriteString(formatConditionBlock(conditions[name]) + "\n")
+	}
+
 	return dsl.String()
 }
 
@@ -307,6 +647,7 @@ func formatUserset(userset openfgaSdk.Userset) string {
 }
 
 // formatUsersetWithMetadata converts a Userset to DSL format with type restriction metadata
+// The restrictions belong to the relation, so operands of or/and/but not share them
 func formatUsersetWithMetadata(userset openfgaSdk.Userset, typeRestrictions []openfgaSdk.RelationReference) string {
 	// Direct assignment (e.g., [user, group#member])
 	if this := userset.This; this != nil {
@@ -314,11 +655,7 @@ func formatUsersetWithMetadata(userset openfgaSdk.Userset, typeRestrictions []op
 			// Format type restrictions
 			var types []string
 			for _, tr := range typeRestrictions {
-				if tr.Relation != nil && *tr.Relation != "" {
-					types = append(types, fmt.Sprintf("%s#%s", tr.Type, *tr.Relation))
-				} else {
-					types = append(types, tr.Type)
-				}
+				types = append(types, formatRelationReference(tr))
 			}
 			return "[" + strings.Join(types, ", ") + "]"
 		}
@@ -353,7 +690,7 @@ func formatUsersetWithMetadata(userset openfgaSdk.Userset, typeRestrictions []op
 	if union := userset.Union; union != nil {
 		var parts []string
 		for _, child := range union.GetChild() {
-			parts = append(parts, formatUserset(child))
+			parts = append(parts, formatOperand(child, typeRestrictions, child.Union != nil))
 		}
 		return strings.Join(parts, " or ")
 	}
@@ -362,36 +699,82 @@ func formatUsersetWithMetadata(userset openfgaSdk.Userset, typeRestrictions []op
 	if intersection := userset.Intersection; intersection != nil {
 		var parts []string
 		for _, child := range intersection.GetChild() {
-			parts = append(parts, formatUserset(child))
+			parts = append(parts, formatOperand(child, typeRestrictions, child.Union != nil || child.Intersection != nil))
 		}
 		return strings.Join(parts, " and ")
 	}
 
 	// Difference (e.g., [user] but not blocked)
 	if difference := userset.Difference; difference != nil {
-		base := formatUserset(difference.Base)
-		subtract := formatUserset(difference.Subtract)
+		base := formatOperand(difference.Base, typeRestrictions, isCompoundUserset(difference.Base))
+		subtract := formatOperand(difference.Subtract, typeRestrictions, isCompoundUserset(difference.Subtract))
 		return fmt.Sprintf("%s but not %s", base, subtract)
 	}
 
 	return "[unknown]"
 }
 
+// formatRelationReference formats a directly related type: user, user:*, group#member or
+// user with condition
+func formatRelationReference(tr openfgaSdk.RelationReference) string {
+	restriction := tr.Type
+	if tr.Wildcard != nil {
+		restriction += ":*"
+	} else if tr.Relation != nil && *tr.Relation != "" {
+		restriction = fmt.Sprintf("%s#%s", tr.Type, *tr.Relation)
+	}
+	if tr.Condition != nil && *tr.Condition != "" {
+		restriction += " with " + *tr.Condition
+	}
+	return restriction
+}
+
+// formatOperand formats an operand of union, intersection or difference, in parentheses
+// when grouped is set, so "(owner or editor) and approved" keeps its meaning
+func formatOperand(userset openfgaSdk.Userset, typeRestrictions []openfgaSdk.RelationReference, grouped bool) string {
+	formatted := formatUsersetWithMetadata(userset, typeRestrictions)
+	if grouped {
+		return "(" + formatted + ")"
+	}
+	return formatted
+}
+
+// isCompoundUserset reports whether a userset combines other usersets
+func isCompoundUserset(userset openfgaSdk.Userset) bool {
+	return userset.Union != nil || userset.Intersection != nil || userset.Difference != nil
+}
+
 // WriteAuthorizationModel writes a new authorization model
-// Note: This requires the model in the correct format
+// Note: This requires the model in the correct format. The client's SchemaVersion, if set,
+// replaces the model's, and the model must only use features of its schema version
 func (c *Client) WriteAuthorizationModel(ctx context.Context, model openfgaSdk.AuthorizationModel) error {
+	if c.schemaVersion != "" {
+		model.SchemaVersion = c.schemaVersion
+	}
+	if err := ValidateModelSchema(model); err != nil {
+		return err
+	}
+
 	body := client.ClientWriteAuthorizationModelRequest{
 		TypeDefinitions: model.TypeDefinitions,
 		SchemaVersion:   model.SchemaVersion,
+		Conditions:      model.Conditions,
 	}
 
-	_, err := c.sdk.WriteAuthorizationModel(ctx).Body(body).Execute()
-	return err
+	return c.call(ctx, func(ctx context.Context) error {
+		_, err := c.sdk.WriteAuthorizationModel(ctx).Body(body).Execute()
+		return err
+	})
 }
 
 // GetCurrentAuthorizationModel retrieves the current authorization model from OpenFGA
 func (c *Client) GetCurrentAuthorizationModel(ctx context.Context) (openfgaSdk.AuthorizationModel, error) {
-	response, err := c.sdk.ReadLatestAuthorizationModel(ctx).Execute()
+	var response *client.ClientReadAuthorizationModelResponse
+	err := c.call(ctx, func(ctx context.Context) error {
+		var err error
+		response, err = c.sdk.ReadLatestAuthorizationModel(ctx).Execute()
+		return err
+	})
 	if err != nil {
 		return openfgaSdk.AuthorizationModel{}, fmt.Errorf("failed to read authorization model: %w", err)
 	}
@@ -399,192 +782,67 @@ func (c *Client) GetCurrentAuthorizationModel(ctx context.Context) (openfgaSdk.A
 	return response.GetAuthorizationModel(), nil
 }
 
-// GetStoreID returns the store ID
-func (c *Client) GetStoreID() string {
-	return c.storeID
-}
-
-// GetSDKClient returns the underlying SDK client (for testing)
-func (c *Client) GetSDKClient() *client.OpenFgaClient {
-	return c.sdk
-}
-
-// readAndFilter reads all tuples and filters client-side
-// Used when OpenFGA API constraints don't allow server-side filtering
-func (c *Client) readAndFilter(ctx context.Context, req ReadTuplesRequest) ([]Tuple, error) {
-	var allTuples []Tuple
-	continuationToken := ""
-
-	// Read all tuples without any filters (direct API call)
-	for {
-		body := client.ClientReadRequest{}
-
-		options := client.ClientReadOptions{}
-		if continuationToken != "" {
-			options.ContinuationToken = openfgaSdk.PtrString(continuationToken)
-		}
-
-		response, err := c.sdk.Read(ctx).Body(body).Options(options).Execute()
-		if err != nil {
-			return nil, fmt.Errorf("failed to read tuples: %w", err)
-		}
-
-		// Convert SDK tuples to our Tuple type
-		for _, t := range response.GetTuples() {
-			key := t.GetKey()
-			allTuples = append(allTuples, Tuple{
-				User:     key.GetUser(),
-				Relation: key.GetRelation(),
-				Object:   key.GetObject(),
-			})
-		}
-
-		// Check if there are more pages
-		continuationToken = response.GetContinuationToken()
-		if continuationToken == "" {
-			break
-		}
+// CheckStore reports whether the client's store exists, using the client's credentials
+// An error means OpenFGA could not be reached or rejected the request
+func (c *Client) CheckStore(ctx context.Context) (bool, error) {
+	err := c.call(ctx, func(ctx context.Context) error {
+		_, err := c.sdk.GetStore(ctx).Execute()
+		return err
+	})
+	if err == nil {
+		return true, nil
 	}
 
-	// Filter client-side
-	var filtered []Tuple
-	for _, tuple := range allTuples {
-		match := true
-
-		if req.User != "" && tuple.User != req.User {
-			match = false
-		}
-		if req.Relation != "" && tuple.Relation != req.Relation {
-			match = false
-		}
-		if req.Object != "" && tuple.Object != req.Object {
-			match = false
-		}
-
-		if match {
-			filtered = append(filtered, tuple)
-		}
+	var notFound openfgaSdk.FgaApiNotFoundError
+	if errors.As(err, &notFound) {
+		return false, nil
 	}
-
-	return filtered, nil
+	return false, fmt.Errorf("failed to get store: %w", err)
 }
 
-// readAndFilterByType reads all tuples and filters by object type prefix
-func (c *Client) readAndFilterByType(ctx context.Context, req ReadTuplesRequest) ([]Tuple, error) {
-	// Read all tuples
-	allTuples, err := c.readAndFilter(ctx, ReadTuplesRequest{})
+// findStoreID returns the ID of the only store called name
+func (c *Client) findStoreID(ctx context.Context, name string) (string, error) {
+	stores, err := c.listStores(ctx)
 	if err != nil {
-		return nil, err
+		return "", fmt.Errorf("failed to find store '%s': %w", name, err)
 	}
 
-	// Filter by object type prefix (e.g., "document:")
-	var filtered []Tuple
-	for _, tuple := range allTuples {
-		// Check if object starts with the type prefix
-		if strings.HasPrefix(tuple.Object, req.Object) {
-			// Also filter by user/relation if specified
-			match := true
-			if req.User != "" && tuple.User != req.User {
-				match = false
-			}
-			if req.Relation != "" && tuple.Relation != req.Relation {
-				match = false
-			}
-			if match {
-				filtered = append(filtered, tuple)
-			}
+	var matches []string
+	for _, store := range stores {
+		if store.Name == name {
+			matches = append(matches, store.ID)
 		}
 	}
-
-	return filtered, nil
-}
-
-// CreateStore creates a new OpenFGA store
-// Returns the store ID
-func CreateStore(apiURL, storeName string) (string, error) {
-	reqBody := map[string]string{"name": storeName}
-	jsonData, err := json.Marshal(reqBody)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	resp, err := http.Post(apiURL+"/stores", "application/json", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return "", fmt.Errorf("failed to create store: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("failed to create store: status %d, body: %s", resp.StatusCode, string(body))
-	}
-
-	var result struct {
-		ID   string `json:"id"`
-		Name string `json:"name"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", fmt.Errorf("failed to decode response: %w", err)
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("no store is named '%s'", name)
+	case 1:
+		return matches[0], nil
+	default:
+		return "", fmt.Errorf("%d stores are named '%s' (%s): set the store ID instead", len(matches), name, strings.Join(matches, ", "))
 	}
-
-	return result.ID, nil
 }
 
-// Store represents an OpenFGA store
-type Store struct {
-	ID   string
-	Name string
+// GetStoreID returns the store ID
+func (c *Client) GetStoreID() string {
+	return c.storeID
 }
 
-// ListStores lists all stores in the OpenFGA instance
-func ListStores(apiURL string) ([]Store, error) {
-	resp, err := http.Get(apiURL + "/stores")
-	if err != nil {
-		return nil, fmt.Errorf("failed to list stores: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("failed to list stores: status %d, body: %s", resp.StatusCode, string(body))
-	}
-
-	var result struct {
-		Stores []struct {
-			ID   string `json:"id"`
-			Name string `json:"name"`
-		} `json:"stores"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
-	}
-
-	// Convert to our Store type
-	stores := make([]Store, len(result.Stores))
-	for i, s := range result.Stores {
-		stores[i] = Store{ID: s.ID, Name: s.Name}
-	}
-
-	return stores, nil
+// GetSDKClient returns the underlying SDK client (for testing)
+func (c *Client) GetSDKClient() *client.OpenFgaClient {
+	return c.sdk
 }
 
-// StoreExists checks if a store with the given ID exists
-func StoreExists(apiURL, storeID string) (bool, error) {
-	resp, err := http.Get(fmt.Sprintf("%s/stores/%s", apiURL, storeID))
-	if err != nil {
-		return false, fmt.Errorf("failed to check store: %w", err)
+// matches reports whether the tuple matches the request's filters
+func (r ReadTuplesRequest) matches(tuple Tuple) bool {
+	if r.User != "" && tuple.User != r.User {
+		return false
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode == http.StatusOK {
-		return true, nil
+	if r.Relation != "" && tuple.Relation != r.Relation {
+		return false
 	}
-	if resp.StatusCode == http.StatusNotFound {
-		return false, nil
+	if strings.HasSuffix(r.Object, ":") {
+		return strings.HasPrefix(tuple.Object, r.Object)
 	}
-
-	body, _ := io.ReadAll(resp.Body)
-	return false, fmt.Errorf("unexpected status checking store: %d, body: %s", resp.StatusCode, string(body))
+	return r.Object == "" || tuple.Object == r.Object
 }
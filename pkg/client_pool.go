@@ -0,0 +1,166 @@
+package omg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	openfgaSdk "github.com/openfga/go-sdk"
+	"github.com/openfga/go-sdk/client"
+	"github.com/openfga/go-sdk/telemetry"
+)
+
+// DefaultMaxConcurrentRequests is how many API requests a Client and its clones have in
+// flight at once unless Config.MaxConcurrentRequests says otherwise
+const DefaultMaxConcurrentRequests = 8
+
+// MaxReadPageSize is the largest page of tuples OpenFGA returns from one read
+const MaxReadPageSize = 100
+
+// DefaultRequestTimeout bounds each API request unless Config.RequestTimeout says otherwise
+const DefaultRequestTimeout = 30 * time.Second
+
+// clientPool is the state a Client shares with its clones and scoped copies
+type clientPool struct {
+	requests chan struct{}   // One slot per API request in flight
+	model    sync.Mutex      // Held across a read-modify-write of the authorization model
+	limiter  *requestLimiter // Nil without Config.RequestsPerSecond
+	retries  retryPolicy
+	timeout  time.Duration // Of each request; 0 for none
+	pageSize int           // Of tuple reads; 0 for the server's default
+
+	noTypeReads atomic.Bool // The server rejected a read by object type without a user
+}
+
+// newClientPool creates a pool with the request limits and retries of cfg
+func newClientPool(cfg Config) *clientPool {
+	maxRequests := cfg.MaxConcurrentRequests
+	if maxRequests <= 0 {
+		maxRequests = DefaultMaxConcurrentRequests
+	}
+	return &clientPool{
+		requests: make(chan struct{}, maxRequests),
+		limiter:  newRequestLimiter(cfg.RequestsPerSecond),
+		retries:  newRetryPolicy(cfg),
+		timeout:  requestTimeout(cfg.RequestTimeout),
+		pageSize: cfg.ReadPageSize,
+	}
+}
+
+// requestTimeout applies the default to Config.RequestTimeout
+func requestTimeout(timeout time.Duration) time.Duration {
+	switch {
+	case timeout == 0:
+		return DefaultRequestTimeout
+	case timeout < 0:
+		return 0
+	default:
+		return timeout
+	}
+}
+
+// warmTelemetry creates the SDK's request metrics for configuration up front
+// The SDK creates each metric on first use and stores it in a map without a lock, so
+// the first requests of a client, sent at once, would write that map concurrently
+func warmTelemetry(configuration *telemetry.Configuration) {
+	metrics := telemetry.GetMetrics(telemetry.TelemetryFactoryParameters{Configuration: configuration})
+	metrics.GetCounter(telemetry.CredentialsRequest.Name, telemetry.CredentialsRequest.Description)
+	metrics.GetHistogram(telemetry.RequestDuration.Name, telemetry.RequestDuration.Description, telemetry.RequestDuration.Unit)
+	metrics.GetHistogram(telemetry.QueryDuration.Name, telemetry.QueryDuration.Description, telemetry.QueryDuration.Unit)
+}
+
+// Clone returns a client for another goroutine
+// A Client is already safe for concurrent use; Clone gives a goroutine its own copy to
+// scope (e.g. with WithAuthorizationModelID) without affecting the others. Clones share
+// the SDK client, the HTTP connections, the request limit and the model lock
+func (c *Client) Clone() *Client {
+	clone := *c
+	return &clone
+}
+
+// acquire waits for a request slot, and for the request rate to allow another request,
+// and returns the function that frees the slot
+func (c *Client) acquire(ctx context.Context) (func(), error) {
+	if c.pool == nil {
+		return func() {}, nil
+	}
+	select {
+	case c.pool.requests <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if err := c.pool.limiter.wait(ctx); err != nil {
+		<-c.pool.requests
+		return nil, err
+	}
+	return func() { <-c.pool.requests }, nil
+}
+
+// call runs one API request once a request slot is free, bounded by the request timeout
+func (c *Client) call(ctx context.Context, request func(ctx context.Context) error) error {
+	release, err := c.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if c.pool == nil || c.pool.timeout <= 0 {
+		return request(ctx)
+	}
+
+	requestCtx, cancel := context.WithTimeout(ctx, c.pool.timeout)
+	defer cancel()
+	err = request(requestCtx)
+	if err != nil && ctx.Err() == nil && errors.Is(requestCtx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("request timed out after %s: %w", c.pool.timeout, err)
+	}
+	return err
+}
+
+// lockModel serializes changes to the authorization model
+// Model helpers read the latest model, change it and write it back; without the lock,
+// two goroutines changing the model at once would each drop the other's change
+func (c *Client) lockModel() func() {
+	if c == nil || c.pool == nil {
+		return func() {}
+	}
+	c.pool.model.Lock()
+	return c.pool.model.Unlock
+}
+
+// readPage reads one page of tuples, retrying transient failures
+func (c *Client) readPage(ctx context.Context, body client.ClientReadRequest, continuationToken string) (*client.ClientReadResponse, error) {
+	options := client.ClientReadOptions{}
+	if continuationToken != "" {
+		options.ContinuationToken = openfgaSdk.PtrString(continuationToken)
+	}
+	if c.pool != nil && c.pool.pageSize > 0 {
+		options.PageSize = openfgaSdk.PtrInt32(int32(c.pool.pageSize))
+	}
+
+	var response *client.ClientReadResponse
+	err := c.retry(ctx, func() error {
+		return c.call(ctx, func(ctx context.Context) error {
+			var err error
+			response, err = c.sdk.Read(ctx).Body(body).Options(options).Execute()
+			return err
+		})
+	})
+	return response, err
+}
+
+// pooledTransport returns a copy of http.DefaultTransport that keeps enough idle
+// connections to the server for maxRequests requests at once
+// The default keeps two, so parallel batches would keep opening new connections
+func pooledTransport(maxRequests int) *http.Transport {
+	if maxRequests <= 0 {
+		maxRequests = DefaultMaxConcurrentRequests
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = maxRequests
+	return transport
+}
@@ -0,0 +1,286 @@
+package omg_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/demetere/omg/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_LimitsConcurrentRequests(t *testing.T) {
+	var inFlight, maxInFlight, writes atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		for {
+			max := maxInFlight.Load()
+			if n <= max || maxInFlight.CompareAndSwap(max, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		inFlight.Add(-1)
+		writes.Add(1)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	client, err := omg.NewClient(omg.Config{
+		ApiURL:                server.URL,
+		StoreID:               "01HVMMBCMGZNT3SED4Z17ECXCA",
+		AuthMethod:            "none",
+		MaxConcurrentRequests: 2,
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(c *omg.Client) {
+			defer wg.Done()
+			assert.NoError(t, c.WriteTuple(ctx, omg.Tuple{User: "user:anne", Relation: "viewer", Object: "document:readme"}))
+		}(client.Clone())
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(8), writes.Load())
+	assert.Equal(t, int32(2), maxInFlight.Load(), "clones share the request limit")
+}
+
+func TestClient_CloneIsIndependent(t *testing.T) {
+	client, err := omg.NewClient(omg.Config{
+		ApiURL:  "http://localhost:8080",
+		StoreID: "01HVMMBCMGZNT3SED4Z17ECXCA",
+	})
+	require.NoError(t, err)
+
+	clone := client.Clone()
+	require.NotSame(t, client, clone)
+	assert.Equal(t, client.GetStoreID(), clone.GetStoreID())
+	assert.Same(t, client.GetSDKClient(), clone.GetSDKClient())
+
+	scoped := clone.WithAuthorizationModelID("01HVMMBCMGZNT3SED4Z17ECXCB")
+	assert.Equal(t, "01HVMMBCMGZNT3SED4Z17ECXCB", scoped.GetAuthorizationModelID())
+	assert.Empty(t, client.GetAuthorizationModelID())
+}
+
+func TestClient_RequestWaitHonorsContext(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client, err := omg.NewClient(omg.Config{
+		ApiURL:                server.URL,
+		StoreID:               "01HVMMBCMGZNT3SED4Z17ECXCA",
+		AuthMethod:            "none",
+		MaxConcurrentRequests: 1,
+	})
+	require.NoError(t, err)
+
+	tuple := omg.Tuple{User: "user:anne", Relation: "viewer", Object: "document:readme"}
+	go client.WriteTuple(context.Background(), tuple)
+	time.Sleep(50 * time.Millisecond) // Let the first write take the only slot
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = client.WriteTuple(ctx, tuple)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestClient_RequestTimeout(t *testing.T) {
+	var requests atomic.Int32
+	hang := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The first request hangs until the client gives up on it
+		if requests.Add(1) == 1 {
+			select {
+			case <-hang:
+			case <-r.Context().Done():
+			}
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"tuples":[],"continuation_token":""}`))
+	}))
+	defer server.Close()
+	defer close(hang)
+
+	newClient := func(maxRetries int) *omg.Client {
+		client, err := omg.NewClient(omg.Config{
+			ApiURL:         server.URL,
+			StoreID:        "01HVMMBCMGZNT3SED4Z17ECXCA",
+			AuthMethod:     "none",
+			RequestTimeout: 50 * time.Millisecond,
+			MaxRetries:     maxRetries,
+			BackoffBase:    time.Millisecond,
+		})
+		require.NoError(t, err)
+		return client
+	}
+
+	ctx := context.Background()
+	_, err := newClient(-1).ReadAllTuples(ctx, omg.ReadTuplesRequest{})
+	assert.ErrorContains(t, err, "request timed out after 50ms")
+
+	// A retry gets past the hung request
+	requests.Store(0)
+	_, err = newClient(1).ReadAllTuples(ctx, omg.ReadTuplesRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), requests.Load())
+}
+
+func TestClient_ReadAllTuplesStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var pages atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Every page has a next one; the caller gives up after the third
+		if pages.Add(1) == 3 {
+			cancel()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"tuples":[{"key":{"user":"user:anne","relation":"viewer","object":"document:%d"}}],"continuation_token":"page"}`, pages.Load())
+	}))
+	defer server.Close()
+
+	client, err := omg.NewClient(omg.Config{
+		ApiURL:     server.URL,
+		StoreID:    "01HVMMBCMGZNT3SED4Z17ECXCA",
+		AuthMethod: "none",
+	})
+	require.NoError(t, err)
+
+	_, err = client.ReadAllTuples(ctx, omg.ReadTuplesRequest{})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, int32(3), pages.Load())
+}
+
+func TestClient_ReadAllTuplesFiltersOnServer(t *testing.T) {
+	stored := []omg.Tuple{
+		{User: "user:anne", Relation: "viewer", Object: "document:1"},
+		{User: "user:bob", Relation: "editor", Object: "document:1"},
+		{User: "user:anne", Relation: "viewer", Object: "folder:1"},
+	}
+
+	// Like OpenFGA: reads by object type need a user
+	var reads []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			TupleKey *omg.Tuple `json:"tuple_key"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Header().Set("Content-Type", "application/json")
+		filter := omg.Tuple{}
+		if body.TupleKey != nil {
+			filter = *body.TupleKey
+		}
+		reads = append(reads, fmt.Sprintf("%s %s %s", filter.User, filter.Relation, filter.Object))
+		if strings.HasSuffix(filter.Object, ":") && filter.User == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"code":"validation_error","message":"the object id and user cannot both be empty"}`))
+			return
+		}
+
+		var keys []string
+		for _, tuple := range stored {
+			if (filter.User == "" || tuple.User == filter.User) && (filter.Relation == "" || tuple.Relation == filter.Relation) &&
+				(filter.Object == "" || tuple.Object == filter.Object || strings.HasSuffix(filter.Object, ":") && strings.HasPrefix(tuple.Object, filter.Object)) {
+				keys = append(keys, fmt.Sprintf(`{"key":{"user":"%s","relation":"%s","object":"%s"}}`, tuple.User, tuple.Relation, tuple.Object))
+			}
+		}
+		fmt.Fprintf(w, `{"tuples":[%s],"continuation_token":""}`, strings.Join(keys, ","))
+	}))
+	defer server.Close()
+
+	client, err := omg.NewClient(omg.Config{ApiURL: server.URL, StoreID: "01HVMMBCMGZNT3SED4Z17ECXCA", AuthMethod: "none", MaxRetries: -1})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	// Type and user: filtered by the server
+	tuples, err := client.ReadAllTuples(ctx, omg.ReadTuplesRequest{User: "user:anne", Object: "document:"})
+	require.NoError(t, err)
+	assert.Equal(t, stored[:1], tuples)
+	assert.Equal(t, []string{"user:anne  document:"}, reads)
+
+	// Type only: rejected once, then read in full straight away
+	reads = nil
+	tuples, err = client.ReadAllTuples(ctx, omg.ReadTuplesRequest{Object: "document:", Relation: "editor"})
+	require.NoError(t, err)
+	assert.Equal(t, stored[1:2], tuples)
+	assert.Equal(t, []string{" editor document:", "  "}, reads)
+
+	reads = nil
+	tuples, err = omg.ReadAllTuples(ctx, client, "folder", "")
+	require.NoError(t, err)
+	assert.Equal(t, stored[2:], tuples)
+	assert.Equal(t, []string{"  "}, reads)
+
+	// No object type: read in full
+	reads = nil
+	tuples, err = client.ReadAllTuples(ctx, omg.ReadTuplesRequest{User: "user:anne"})
+	require.NoError(t, err)
+	assert.Equal(t, []omg.Tuple{stored[0], stored[2]}, tuples)
+	assert.Equal(t, []string{"  "}, reads)
+}
+
+func TestClient_IterateTuples(t *testing.T) {
+	var pageSizes []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			PageSize int `json:"page_size"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		pageSizes = append(pageSizes, body.PageSize)
+		w.Header().Set("Content-Type", "application/json")
+		next := "page"
+		if len(pageSizes) == 3 {
+			next = ""
+		}
+		fmt.Fprintf(w, `{"tuples":[{"key":{"user":"user:anne","relation":"viewer","object":"document:%d"}},{"key":{"user":"user:bob","relation":"viewer","object":"document:%d"}}],"continuation_token":"%s"}`,
+			len(pageSizes), len(pageSizes), next)
+	}))
+	defer server.Close()
+
+	client, err := omg.NewClient(omg.Config{ApiURL: server.URL, StoreID: "01HVMMBCMGZNT3SED4Z17ECXCA", AuthMethod: "none", ReadPageSize: 2})
+	require.NoError(t, err)
+
+	var objects []string
+	err = client.IterateTuples(context.Background(), omg.ReadTuplesRequest{}, func(tuple omg.Tuple) error {
+		objects = append(objects, tuple.Object)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"document:1", "document:1", "document:2", "document:2", "document:3", "document:3"}, objects)
+	assert.Equal(t, []int{2, 2, 2}, pageSizes)
+
+	// An error from the callback stops reading
+	pageSizes = nil
+	stop := errors.New("stop")
+	err = client.IterateTuples(context.Background(), omg.ReadTuplesRequest{}, func(tuple omg.Tuple) error {
+		return stop
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Len(t, pageSizes, 1)
+
+	_, err = omg.NewClient(omg.Config{ApiURL: server.URL, StoreID: "01HVMMBCMGZNT3SED4Z17ECXCA", AuthMethod: "none", ReadPageSize: 500})
+	assert.ErrorContains(t, err, "read page size 500 is out of range")
+}
@@ -232,3 +232,37 @@ type folder
 	}
 }
 
+
+func TestClient_WithAuthorizationModelID(t *testing.T) {
+	ctx := context.Background()
+
+	container, client := testhelpers.SetupOpenFGAContainer(t, ctx, `
+type user
+type document
+  relations
+    define owner: [user]
+    define editor: [user]
+`)
+	defer container.Terminate(ctx)
+
+	original, err := client.GetCurrentAuthorizationModel(ctx)
+	require.NoError(t, err)
+
+	// Drop "editor" in a newer model
+	err = omg.RemoveRelationFromType(ctx, client, "document", "editor")
+	require.NoError(t, err)
+
+	tuple := omg.Tuple{User: "user:alice", Relation: "editor", Object: "document:readme"}
+
+	// Validated against the latest model, the relation no longer exists
+	err = client.WriteTuple(ctx, tuple)
+	assert.Error(t, err)
+
+	// Scoped to the original model, the write is valid
+	scoped := client.WithAuthorizationModelID(original.GetId())
+	assert.Equal(t, original.GetId(), scoped.GetAuthorizationModelID())
+	assert.Empty(t, client.GetAuthorizationModelID())
+
+	err = scoped.WriteTuple(ctx, tuple)
+	require.NoError(t, err)
+}
@@ -0,0 +1,209 @@
+package omg
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	openfgaSdk "github.com/openfga/go-sdk"
+)
+
+// conditionPattern matches a condition block: condition name(param: type, ...) { expression }
+var conditionPattern = regexp.MustCompile(`(?s)^condition\s+([A-Za-z_][A-Za-z0-9_]*)\s*\((.*?)\)\s*\{(.*)\}$`)
+
+// parseCondition parses a condition block from the DSL
+// Lines of a multi-line expression are trimmed and joined with spaces
+func parseCondition(block string) (openfgaSdk.Condition, error) {
+	match := conditionPattern.FindStringSubmatch(strings.TrimSpace(block))
+	if match == nil {
+		return openfgaSdk.Condition{}, fmt.Errorf("invalid condition: %s", block)
+	}
+
+	condition := openfgaSdk.Condition{Name: match[1]}
+
+	parameters := make(map[string]openfgaSdk.ConditionParamTypeRef)
+	for _, param := range strings.Split(match[2], ",") {
+		param = strings.TrimSpace(param)
+		if param == "" {
+			continue
+		}
+		name, typeName, found := strings.Cut(param, ":")
+		if !found {
+			return condition, fmt.Errorf("invalid parameter '%s' in condition '%s'", param, condition.Name)
+		}
+		ref, err := parseConditionParamType(strings.TrimSpace(typeName))
+		if err != nil {
+			return condition, fmt.Errorf("invalid parameter '%s' in condition '%s': %w", param, condition.Name, err)
+		}
+		parameters[strings.TrimSpace(name)] = ref
+	}
+	if len(parameters) > 0 {
+		condition.Parameters = &parameters
+	}
+
+	var expression []string
+	for _, line := range strings.Split(match[3], "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			expression = append(expression, line)
+		}
+	}
+	condition.Expression = strings.Join(expression, " ")
+	if condition.Expression == "" {
+		return condition, fmt.Errorf("condition '%s' has no expression", condition.Name)
+	}
+
+	return condition, nil
+}
+
+// parseConditionParamType parses a parameter type such as int, timestamp or map<string>
+func parseConditionParamType(s string) (openfgaSdk.ConditionParamTypeRef, error) {
+	name, generic, isGeneric := strings.Cut(s, "<")
+
+	typeName, err := openfgaSdk.NewTypeNameFromValue("TYPE_NAME_" + strings.ToUpper(strings.TrimSpace(name)))
+	if err != nil || *typeName == openfgaSdk.TYPENAME_UNSPECIFIED {
+		return openfgaSdk.ConditionParamTypeRef{}, fmt.Errorf("unknown type '%s'", s)
+	}
+	ref := openfgaSdk.ConditionParamTypeRef{TypeName: *typeName}
+
+	if isGeneric {
+		if !strings.HasSuffix(generic, ">") {
+			return ref, fmt.Errorf("unterminated generic type '%s'", s)
+		}
+		inner, err := parseConditionParamType(strings.TrimSuffix(generic, ">"))
+		if err != nil {
+			return ref, err
+		}
+		ref.GenericTypes = &[]openfgaSdk.ConditionParamTypeRef{inner}
+	}
+	return ref, nil
+}
+
+// formatConditionParamType formats a parameter type as it is written in the DSL
+func formatConditionParamType(ref openfgaSdk.ConditionParamTypeRef) string {
+	name := strings.ToLower(strings.TrimPrefix(string(ref.TypeName), "TYPE_NAME_"))
+	if generics := ref.GetGenericTypes(); len(generics) > 0 {
+		var inner []string
+		for _, generic := range generics {
+			inner = append(inner, formatConditionParamType(generic))
+		}
+		name += "<" + strings.Join(inner, ", ") + ">"
+	}
+	return name
+}
+
+// conditionSignature formats a condition's name and parameters, sorted by name
+func conditionSignature(condition openfgaSdk.Condition) string {
+	parameters := condition.GetParameters()
+	names := make([]string, 0, len(parameters))
+	for name := range parameters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	params := make([]string, len(names))
+	for i, name := range names {
+		params[i] = name + ": " + formatConditionParamType(parameters[name])
+	}
+	return fmt.Sprintf("condition %s(%s)", condition.Name, strings.Join(params, ", "))
+}
+
+// conditionDSL formats a condition on one line, as model states and changes record it
+func conditionDSL(condition openfgaSdk.Condition) string {
+	return fmt.Sprintf("%s { %s }", conditionSignature(condition), condition.Expression)
+}
+
+// formatConditionBlock formats a condition the way model files lay it out
+func formatConditionBlock(condition openfgaSdk.Condition) string {
+	return fmt.Sprintf("%s {\n  %s\n}\n", conditionSignature(condition), condition.Expression)
+}
+
+// conditionBlockEnd returns the index of the line that closes the condition block
+// starting at lines[start]
+func conditionBlockEnd(lines []string, start int) (int, error) {
+	depth := 0
+	opened := false
+	for i := start; i < len(lines); i++ {
+		depth += strings.Count(lines[i], "{") - strings.Count(lines[i], "}")
+		opened = opened || strings.Contains(lines[i], "{")
+		if opened && depth <= 0 {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("condition at line %d is not closed", start+1)
+}
+
+// SetCondition adds a condition to the model, or replaces the condition of the same name
+// Example: SetCondition(ctx, client, "condition non_expired(now: timestamp, expires: timestamp) { now < expires }")
+func SetCondition(ctx context.Context, client *Client, conditionDSL string) error {
+	condition, err := parseCondition(conditionDSL)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Setting condition '%s'\n", condition.Name)
+
+	unlock := client.lockModel()
+	defer unlock()
+
+	currentModel, err := currentParsedModel(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	conditions := currentModel.GetConditions()
+	if conditions == nil {
+		conditions = make(map[string]openfgaSdk.Condition)
+	}
+	conditions[condition.Name] = condition
+	currentModel.Conditions = &conditions
+
+	if err := client.WriteAuthorizationModel(ctx, currentModel); err != nil {
+		return fmt.Errorf("failed to write model: %w", err)
+	}
+
+	fmt.Printf("Condition '%s' set successfully\n", condition.Name)
+	return nil
+}
+
+// RemoveCondition removes a condition from the model
+// Relations that still use it must be updated first, or OpenFGA rejects the model
+func RemoveCondition(ctx context.Context, client *Client, name string) error {
+	fmt.Printf("Removing condition '%s'\n", name)
+
+	unlock := client.lockModel()
+	defer unlock()
+
+	currentModel, err := currentParsedModel(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	conditions := currentModel.GetConditions()
+	if _, exists := conditions[name]; !exists {
+		return fmt.Errorf("condition '%s' not found", name)
+	}
+	delete(conditions, name)
+	currentModel.Conditions = &conditions
+
+	if err := client.WriteAuthorizationModel(ctx, currentModel); err != nil {
+		return fmt.Errorf("failed to write model: %w", err)
+	}
+
+	fmt.Printf("Condition '%s' removed successfully\n", name)
+	return nil
+}
+
+// currentParsedModel reads the current model the way the model helpers edit it
+func currentParsedModel(ctx context.Context, client *Client) (openfgaSdk.AuthorizationModel, error) {
+	currentDSL, err := client.GetCurrentModel(ctx)
+	if err != nil {
+		return openfgaSdk.AuthorizationModel{}, fmt.Errorf("failed to get current model: %w", err)
+	}
+
+	currentModel, err := parseDSLToModel(currentDSL)
+	if err != nil {
+		return openfgaSdk.AuthorizationModel{}, fmt.Errorf("failed to parse current model: %w", err)
+	}
+	return currentModel, nil
+}
@@ -0,0 +1,175 @@
+package omg_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/demetere/omg/pkg"
+	openfgaSdk "github.com/openfga/go-sdk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const conditionalModel = `model
+  schema 1.1
+
+type user
+
+type document
+  relations
+    define viewer: [user, user with non_expired]
+
+condition non_expired(current_time: timestamp, expires_at: timestamp, tags: map<string>) {
+  current_time <
+    expires_at
+}
+`
+
+func TestParseDSLToModel_Conditions(t *testing.T) {
+	model, err := omg.ParseDSLToModel(conditionalModel)
+	require.NoError(t, err)
+
+	condition, exists := model.GetConditions()["non_expired"]
+	require.True(t, exists)
+	assert.Equal(t, "current_time < expires_at", condition.Expression)
+	params := condition.GetParameters()
+	require.Len(t, params, 3)
+	assert.Equal(t, openfgaSdk.TYPENAME_TIMESTAMP, params["expires_at"].TypeName)
+	assert.Equal(t, openfgaSdk.TYPENAME_MAP, params["tags"].TypeName)
+	tags := params["tags"]
+	assert.Equal(t, openfgaSdk.TYPENAME_STRING, tags.GetGenericTypes()[0].TypeName)
+
+	viewer := model.TypeDefinitions[1].Metadata.GetRelations()["viewer"]
+	restrictions := viewer.GetDirectlyRelatedUserTypes()
+	require.Len(t, restrictions, 2)
+	assert.Nil(t, restrictions[0].Condition)
+	assert.Equal(t, "non_expired", restrictions[1].GetCondition())
+
+	state := omg.BuildModelState(model)
+	assert.Equal(t, "[user, user with non_expired]", state.Types["document"].Relations["viewer"])
+	assert.Equal(t, "condition non_expired(current_time: timestamp, expires_at: timestamp, tags: map<string>) { current_time < expires_at }",
+		state.Conditions["non_expired"])
+}
+
+func TestParseDSLToModel_InvalidCondition(t *testing.T) {
+	_, err := omg.ParseDSLToModel("condition broken(x: money) {\n  x > 0\n}\n")
+	assert.ErrorContains(t, err, "unknown type 'money'")
+
+	_, err = omg.ParseDSLToModel("condition open(x: int) {\n  x > 0\n")
+	assert.ErrorContains(t, err, "not closed")
+}
+
+func TestDetectChanges_Conditions(t *testing.T) {
+	oldState := &omg.ModelState{
+		Types: map[string]omg.TypeState{},
+		Conditions: map[string]string{
+			"non_expired": "condition non_expired(expires_at: timestamp) { now < expires_at }",
+			"legacy":      "condition legacy(x: int) { x > 0 }",
+		},
+	}
+	newState := &omg.ModelState{
+		Types: map[string]omg.TypeState{},
+		Conditions: map[string]string{
+			"non_expired": "condition non_expired(expires_at: timestamp, now: timestamp) { now < expires_at }",
+			"in_region":   "condition in_region(region: string) { region == 'eu' }",
+		},
+	}
+
+	byType := omg.DetectChanges(oldState, newState).ByType()
+	require.Len(t, byType[omg.ChangeTypeUpdateCondition], 1)
+	assert.Equal(t, "non_expired", byType[omg.ChangeTypeUpdateCondition][0].TypeName)
+	require.Len(t, byType[omg.ChangeTypeAddCondition], 1)
+	assert.Equal(t, "in_region", byType[omg.ChangeTypeAddCondition][0].TypeName)
+	require.Len(t, byType[omg.ChangeTypeRemoveCondition], 1)
+	assert.Equal(t, "legacy", byType[omg.ChangeTypeRemoveCondition][0].TypeName)
+
+	assert.NotEqual(t, omg.HashModelState(oldState), omg.HashModelState(newState))
+}
+
+func TestGenerateMigration_Conditions(t *testing.T) {
+	changes := []omg.ModelChange{
+		{Type: omg.ChangeTypeRemoveCondition, TypeName: "legacy", OldValue: "condition legacy(x: int) { x > 0 }"},
+		{Type: omg.ChangeTypeAddRelation, TypeName: "document", RelationName: "viewer", NewValue: "[user with non_expired]"},
+		{Type: omg.ChangeTypeAddCondition, TypeName: "non_expired", NewValue: `condition non_expired(expires_at: timestamp) { now < expires_at }`},
+	}
+
+	filename, err := omg.GenerateMigrationFromChanges(changes, "conditions", t.TempDir())
+	require.NoError(t, err)
+	content, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	up, down, _ := strings.Cut(string(content), "func down(")
+
+	setCondition := strings.Index(up, `omg.SetCondition(ctx, client, "condition non_expired(expires_at: timestamp) { now < expires_at }")`)
+	addRelation := strings.Index(up, `omg.AddRelationToType(ctx, client, "document", "viewer", "[user with non_expired]")`)
+	removeCondition := strings.Index(up, `omg.RemoveCondition(ctx, client, "legacy")`)
+	require.True(t, setCondition >= 0 && addRelation >= 0 && removeCondition >= 0, up)
+	assert.Less(t, setCondition, addRelation, "conditions are added before relations use them")
+	assert.Less(t, addRelation, removeCondition, "conditions are removed last")
+
+	assert.Contains(t, down, `omg.SetCondition(ctx, client, "condition legacy(x: int) { x > 0 }")`)
+	assert.Contains(t, down, `omg.RemoveCondition(ctx, client, "non_expired")`)
+}
+
+func TestFormatDSL_Conditions(t *testing.T) {
+	formatted, err := omg.FormatDSL(`type user
+condition non_expired(now: timestamp,expires_at: timestamp) { now < expires_at }
+type document
+  relations
+    define viewer: [user with  non_expired]
+`)
+	require.NoError(t, err)
+	assert.Equal(t, `model
+  schema 1.1
+
+type user
+
+type document
+  relations
+    define viewer: [user with non_expired]
+
+condition non_expired(expires_at: timestamp, now: timestamp) {
+  now < expires_at
+}
+`, formatted)
+}
+
+func TestSetCondition_KeepsConditionsInModel(t *testing.T) {
+	model, err := omg.ParseDSLToModel(conditionalModel)
+	require.NoError(t, err)
+	model.Id = "01HVMMBCMGZNT3SED4Z17ECXCB"
+
+	var written openfgaSdk.WriteAuthorizationModelRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodPost {
+			body, _ := io.ReadAll(r.Body)
+			require.NoError(t, json.Unmarshal(body, &written))
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"authorization_model_id": "01HVMMBCMGZNT3SED4Z17ECXCC"}`))
+			return
+		}
+		json.NewEncoder(w).Encode(openfgaSdk.ReadAuthorizationModelsResponse{AuthorizationModels: []openfgaSdk.AuthorizationModel{model}})
+	}))
+	defer server.Close()
+
+	client, err := omg.NewClient(omg.Config{ApiURL: server.URL, StoreID: "01HVMMBCMGZNT3SED4Z17ECXCA", AuthMethod: "none"})
+	require.NoError(t, err)
+
+	require.NoError(t, omg.SetCondition(context.Background(), client, "condition in_region(region: string) { region == 'eu' }"))
+
+	conditions := written.GetConditions()
+	require.Len(t, conditions, 2, "existing conditions survive the round trip through DSL")
+	assert.Equal(t, "current_time < expires_at", conditions["non_expired"].Expression)
+	assert.Equal(t, "region == 'eu'", conditions["in_region"].Expression)
+
+	viewer := written.TypeDefinitions[1].Metadata.GetRelations()["viewer"]
+	restrictions := viewer.GetDirectlyRelatedUserTypes()
+	require.Len(t, restrictions, 2)
+	assert.Equal(t, "non_expired", restrictions[1].GetCondition())
+}
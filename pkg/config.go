@@ -0,0 +1,211 @@
+package omg
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ConfigFromEnv builds a client Config the way the omg CLI does:
+// from OPENFGA_DATABASE_URL when it is set, otherwise from the OPENFGA_* variables
+// (OPENFGA_API_URL, OPENFGA_STORE_ID or OPENFGA_STORE_NAME, OPENFGA_AUTH_METHOD, OPENFGA_API_TOKEN,
+// OPENFGA_CLIENT_ID, OPENFGA_CLIENT_SECRET, OPENFGA_TOKEN_ISSUER, OPENFGA_TOKEN_AUDIENCE)
+// When OPENFGA_AUTH_METHOD is unset, the method is inferred from the credentials present
+// OMG_SCHEMA_VERSION sets the schema version of written models either way, and
+// OMG_MAX_RETRIES, OMG_BACKOFF_BASE (e.g. 500ms), OMG_REQUESTS_PER_SECOND and
+// OMG_REQUEST_TIMEOUT (e.g. 1m) the retries, request rate and request timeout, and
+// OMG_READ_PAGE_SIZE the tuples per read
+// OPENFGA_TLS_CA_FILE, OPENFGA_TLS_CLIENT_CERT, OPENFGA_TLS_CLIENT_KEY,
+// OPENFGA_TLS_INSECURE_SKIP_VERIFY and OPENFGA_PROXY_URL set up TLS and the proxy,
+// OPENFGA_HEADERS (Name=value pairs separated by commas) and OPENFGA_USER_AGENT the headers
+func ConfigFromEnv() (Config, error) {
+	if dbURL := os.Getenv("OPENFGA_DATABASE_URL"); dbURL != "" {
+		cfg, err := ParseDatabaseURL(dbURL)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid OPENFGA_DATABASE_URL: %w", err)
+		}
+		cfg.SchemaVersion = os.Getenv("OMG_SCHEMA_VERSION")
+		return cfg, transportSettingsFromEnv(&cfg)
+	}
+
+	cfg := Config{
+		ApiURL:        os.Getenv("OPENFGA_API_URL"),
+		StoreID:       os.Getenv("OPENFGA_STORE_ID"),
+		StoreName:     os.Getenv("OPENFGA_STORE_NAME"),
+		AuthMethod:    os.Getenv("OPENFGA_AUTH_METHOD"),
+		APIToken:      os.Getenv("OPENFGA_API_TOKEN"),
+		ClientID:      os.Getenv("OPENFGA_CLIENT_ID"),
+		ClientSecret:  os.Getenv("OPENFGA_CLIENT_SECRET"),
+		TokenIssuer:   os.Getenv("OPENFGA_TOKEN_ISSUER"),
+		TokenAudience: os.Getenv("OPENFGA_TOKEN_AUDIENCE"),
+		SchemaVersion: os.Getenv("OMG_SCHEMA_VERSION"),
+	}
+	if cfg.AuthMethod == "" {
+		cfg.AuthMethod = inferAuthMethod(cfg)
+	}
+
+	return cfg, transportSettingsFromEnv(&cfg)
+}
+
+// transportSettingsFromEnv reads the TLS, proxy, retry, rate-limit and timeout settings into cfg
+func transportSettingsFromEnv(cfg *Config) error {
+	cfg.TLSCAFile = os.Getenv("OPENFGA_TLS_CA_FILE")
+	cfg.TLSClientCert = os.Getenv("OPENFGA_TLS_CLIENT_CERT")
+	cfg.TLSClientKey = os.Getenv("OPENFGA_TLS_CLIENT_KEY")
+	cfg.ProxyURL = os.Getenv("OPENFGA_PROXY_URL")
+	cfg.UserAgent = os.Getenv("OPENFGA_USER_AGENT")
+	if value := os.Getenv("OPENFGA_HEADERS"); value != "" {
+		headers, err := ParseHeaders(value)
+		if err != nil {
+			return fmt.Errorf("invalid OPENFGA_HEADERS: %w", err)
+		}
+		cfg.Headers = headers
+	}
+	if value := os.Getenv("OPENFGA_TLS_INSECURE_SKIP_VERIFY"); value != "" {
+		insecure, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid OPENFGA_TLS_INSECURE_SKIP_VERIFY %q: %w", value, err)
+		}
+		cfg.InsecureSkipVerify = insecure
+	}
+	return requestSettingsFromEnv(cfg)
+}
+
+// requestSettingsFromEnv reads the retry, rate-limit, timeout and page size settings into cfg
+func requestSettingsFromEnv(cfg *Config) error {
+	if value := os.Getenv("OMG_MAX_RETRIES"); value != "" {
+		retries, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid OMG_MAX_RETRIES %q: %w", value, err)
+		}
+		cfg.MaxRetries = retries
+	}
+	if value := os.Getenv("OMG_BACKOFF_BASE"); value != "" {
+		base, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid OMG_BACKOFF_BASE %q: %w", value, err)
+		}
+		cfg.BackoffBase = base
+	}
+	if value := os.Getenv("OMG_REQUESTS_PER_SECOND"); value != "" {
+		rate, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid OMG_REQUESTS_PER_SECOND %q: %w", value, err)
+		}
+		cfg.RequestsPerSecond = rate
+	}
+	if value := os.Getenv("OMG_REQUEST_TIMEOUT"); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid OMG_REQUEST_TIMEOUT %q: %w", value, err)
+		}
+		cfg.RequestTimeout = timeout
+	}
+	if value := os.Getenv("OMG_READ_PAGE_SIZE"); value != "" {
+		size, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid OMG_READ_PAGE_SIZE %q: %w", value, err)
+		}
+		cfg.ReadPageSize = size
+	}
+	return nil
+}
+
+// ParseHeaders parses Name=value pairs separated by commas, e.g. "X-Tenant=acme,X-Api-Key=secret"
+func ParseHeaders(value string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		name, headerValue, found := strings.Cut(pair, "=")
+		name = strings.TrimSpace(name)
+		if !found || name == "" {
+			return nil, fmt.Errorf("expected Name=value, got %q", pair)
+		}
+		headers[http.CanonicalHeaderKey(name)] = strings.TrimSpace(headerValue)
+	}
+	return headers, nil
+}
+
+// formatHeaders formats headers as ParseHeaders reads them, sorted by name
+func formatHeaders(headers map[string]string) string {
+	pairs := make([]string, 0, len(headers))
+	for name, value := range headers {
+		pairs = append(pairs, name+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// ParseDatabaseURL parses a database URL in the format:
+// openfga://store_id@host:port?auth=client_credentials&client_id=...&client_secret=...
+// Supported query parameters: tls=false, auth, token, client_id, client_secret, issuer, audience,
+// and by=name, which makes the user part the name of the store instead of its ID
+func ParseDatabaseURL(dburl string) (Config, error) {
+	u, err := url.Parse(dburl)
+	if err != nil {
+		return Config{}, err
+	}
+
+	if u.Scheme != "openfga" {
+		return Config{}, fmt.Errorf("invalid scheme: expected 'openfga', got '%s'", u.Scheme)
+	}
+
+	store := u.User.Username()
+	if store == "" {
+		return Config{}, fmt.Errorf("store ID is required")
+	}
+
+	host := u.Host
+	if host == "" {
+		return Config{}, fmt.Errorf("host is required")
+	}
+
+	// Build API URL
+	scheme := "https"
+	if u.Query().Get("tls") == "false" {
+		scheme = "http"
+	}
+
+	query := u.Query()
+	cfg := Config{
+		ApiURL:        fmt.Sprintf("%s://%s", scheme, host),
+		AuthMethod:    query.Get("auth"),
+		APIToken:      query.Get("token"),
+		ClientID:      query.Get("client_id"),
+		ClientSecret:  query.Get("client_secret"),
+		TokenIssuer:   query.Get("issuer"),
+		TokenAudience: query.Get("audience"),
+	}
+	switch by := query.Get("by"); by {
+	case "", "id":
+		cfg.StoreID = store
+	case "name":
+		cfg.StoreName = store
+	default:
+		return Config{}, fmt.Errorf("invalid by=%s: expected id or name", by)
+	}
+	if cfg.AuthMethod == "" {
+		cfg.AuthMethod = inferAuthMethod(cfg)
+	}
+
+	return cfg, nil
+}
+
+// inferAuthMethod picks an auth method from the credentials set in cfg
+func inferAuthMethod(cfg Config) string {
+	switch {
+	case cfg.APIToken != "":
+		return "token"
+	case cfg.ClientID != "":
+		return "client_credentials"
+	default:
+		return "none"
+	}
+}
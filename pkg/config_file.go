@@ -0,0 +1,236 @@
+package omg
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFileNames are the project config files omg looks for, in order
+var ConfigFileNames = []string{"omg.yaml", "omg.yml", ".omgrc"}
+
+// ConfigFile is a project config file (omg.yaml or .omgrc) with named environments
+//
+//	default: dev
+//	environments:
+//	  staging:
+//	    api_url: https://staging.fga.example
+//	    store_id: ${STAGING_STORE_ID}
+//	    auth:
+//	      method: token
+//	      token: ${STAGING_FGA_TOKEN}
+type ConfigFile struct {
+	Path         string                       `yaml:"-"`
+	Default      string                       `yaml:"default"`
+	Environments map[string]EnvironmentConfig `yaml:"environments"`
+}
+
+// EnvironmentConfig is one environment of a ConfigFile
+type EnvironmentConfig struct {
+	APIURL        string            `yaml:"api_url"`
+	StoreID       string            `yaml:"store_id"`
+	StoreName     string            `yaml:"store_name"`   // Instead of store_id; must name exactly one store
+	DatabaseURL   string            `yaml:"database_url"` // openfga:// URL, instead of api_url and store_id
+	Auth          AuthConfig        `yaml:"auth"`
+	TLS           TLSConfig         `yaml:"tls"`
+	ProxyURL      string            `yaml:"proxy_url"`
+	Headers       map[string]string `yaml:"headers"` // Sent with every API request
+	MigrationsDir string            `yaml:"migrations_dir"`
+	Model         string            `yaml:"model"`
+	Tracker       string            `yaml:"tracker"`     // postgres or tuples
+	TrackerURL    string            `yaml:"tracker_url"` // PostgreSQL URL of the tracking database
+	SchemaVersion string            `yaml:"schema_version"`
+}
+
+// AuthConfig holds the credentials of an environment
+type AuthConfig struct {
+	Method       string `yaml:"method"` // none, token or client_credentials; inferred when empty
+	Token        string `yaml:"token"`
+	ClientID     string `yaml:"client_id"`
+	ClientSecret string `yaml:"client_secret"`
+	Issuer       string `yaml:"issuer"`
+	Audience     string `yaml:"audience"`
+}
+
+// TLSConfig holds the TLS settings of an environment
+type TLSConfig struct {
+	CAFile             string `yaml:"ca_file"`
+	ClientCert         string `yaml:"client_cert"`
+	ClientKey          string `yaml:"client_key"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+// FindConfigFile returns the first of ConfigFileNames that exists in dir
+func FindConfigFile(dir string) (string, bool) {
+	for _, name := range ConfigFileNames {
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// LoadConfigFile reads a config file. ${VAR} and ${VAR:-default} in values are replaced
+// with environment variables when an environment is selected, so secrets can stay out of it
+func LoadConfigFile(path string) (*ConfigFile, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var cfg ConfigFile
+	if err := yaml.Unmarshal(content, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	cfg.Path = path
+
+	if cfg.Default != "" {
+		if _, ok := cfg.Environments[cfg.Default]; !ok {
+			return nil, fmt.Errorf("config file %s: default environment '%s' is not defined", path, cfg.Default)
+		}
+	}
+	return &cfg, nil
+}
+
+// EnvironmentNames returns the names of the environments, sorted
+func (c *ConfigFile) EnvironmentNames() []string {
+	names := make([]string, 0, len(c.Environments))
+	for name := range c.Environments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Environment returns the named environment with variables interpolated
+// An empty name selects the default environment
+func (c *ConfigFile) Environment(name string) (EnvironmentConfig, error) {
+	if name == "" {
+		name = c.Default
+	}
+	if name == "" {
+		return EnvironmentConfig{}, fmt.Errorf("config file %s has no default environment; select one with -env", c.Path)
+	}
+
+	env, ok := c.Environments[name]
+	if !ok {
+		return EnvironmentConfig{}, fmt.Errorf("environment '%s' is not defined in %s (available: %s)",
+			name, c.Path, strings.Join(c.EnvironmentNames(), ", "))
+	}
+
+	var missing []string
+	expand := func(value string) string {
+		return os.Expand(value, func(key string) string {
+			key, fallback, hasFallback := strings.Cut(key, ":-")
+			if value, ok := os.LookupEnv(key); ok && value != "" {
+				return value
+			}
+			if !hasFallback {
+				missing = append(missing, key)
+			}
+			return fallback
+		})
+	}
+
+	for _, field := range env.fields() {
+		*field = expand(*field)
+	}
+	if len(env.Headers) > 0 {
+		headers := make(map[string]string, len(env.Headers))
+		for name, value := range env.Headers {
+			headers[name] = expand(value)
+		}
+		env.Headers = headers
+	}
+	if len(missing) > 0 {
+		return EnvironmentConfig{}, fmt.Errorf("environment '%s' uses unset variables: %s", name, strings.Join(missing, ", "))
+	}
+	return env, nil
+}
+
+// fields returns pointers to every string value of the environment
+func (e *EnvironmentConfig) fields() []*string {
+	return []*string{
+		&e.APIURL, &e.StoreID, &e.StoreName, &e.DatabaseURL,
+		&e.Auth.Method, &e.Auth.Token, &e.Auth.ClientID, &e.Auth.ClientSecret, &e.Auth.Issuer, &e.Auth.Audience,
+		&e.TLS.CAFile, &e.TLS.ClientCert, &e.TLS.ClientKey, &e.ProxyURL,
+		&e.MigrationsDir, &e.Model, &e.Tracker, &e.TrackerURL, &e.SchemaVersion,
+	}
+}
+
+// Variables returns the environment as the variables ConfigFromEnv and the CLI read,
+// e.g. api_url as OPENFGA_API_URL. Settings the environment leaves out have empty values
+func (e EnvironmentConfig) Variables() map[string]string {
+	insecure := ""
+	if e.TLS.InsecureSkipVerify {
+		insecure = "true"
+	}
+	return map[string]string{
+		"OPENFGA_API_URL":                  e.APIURL,
+		"OPENFGA_STORE_ID":                 e.StoreID,
+		"OPENFGA_STORE_NAME":               e.StoreName,
+		"OPENFGA_DATABASE_URL":             e.DatabaseURL,
+		"OPENFGA_AUTH_METHOD":              e.Auth.Method,
+		"OPENFGA_API_TOKEN":                e.Auth.Token,
+		"OPENFGA_CLIENT_ID":                e.Auth.ClientID,
+		"OPENFGA_CLIENT_SECRET":            e.Auth.ClientSecret,
+		"OPENFGA_TOKEN_ISSUER":             e.Auth.Issuer,
+		"OPENFGA_TOKEN_AUDIENCE":           e.Auth.Audience,
+		"OPENFGA_TLS_CA_FILE":              e.TLS.CAFile,
+		"OPENFGA_TLS_CLIENT_CERT":          e.TLS.ClientCert,
+		"OPENFGA_TLS_CLIENT_KEY":           e.TLS.ClientKey,
+		"OPENFGA_PROXY_URL":                e.ProxyURL,
+		"OPENFGA_HEADERS":                  formatHeaders(e.Headers),
+		"OPENFGA_TLS_INSECURE_SKIP_VERIFY": insecure,
+		"OMG_TRACKER":                      e.Tracker,
+		"OMG_TRACKER_DATABASE_URL":         e.TrackerURL,
+		"OMG_SCHEMA_VERSION":               e.SchemaVersion,
+	}
+}
+
+// ClientConfig builds the client Config of the environment, like ConfigFromEnv would
+// with its variables set
+func (e EnvironmentConfig) ClientConfig() (Config, error) {
+	if e.DatabaseURL != "" {
+		cfg, err := ParseDatabaseURL(e.DatabaseURL)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid database_url: %w", err)
+		}
+		cfg.SchemaVersion = e.SchemaVersion
+		e.applyTransport(&cfg)
+		return cfg, nil
+	}
+
+	cfg := Config{
+		ApiURL:        e.APIURL,
+		StoreID:       e.StoreID,
+		StoreName:     e.StoreName,
+		AuthMethod:    e.Auth.Method,
+		APIToken:      e.Auth.Token,
+		ClientID:      e.Auth.ClientID,
+		ClientSecret:  e.Auth.ClientSecret,
+		TokenIssuer:   e.Auth.Issuer,
+		TokenAudience: e.Auth.Audience,
+		SchemaVersion: e.SchemaVersion,
+	}
+	if cfg.AuthMethod == "" {
+		cfg.AuthMethod = inferAuthMethod(cfg)
+	}
+	e.applyTransport(&cfg)
+	return cfg, nil
+}
+
+// applyTransport copies the TLS and proxy settings into cfg
+func (e EnvironmentConfig) applyTransport(cfg *Config) {
+	cfg.TLSCAFile = e.TLS.CAFile
+	cfg.TLSClientCert = e.TLS.ClientCert
+	cfg.TLSClientKey = e.TLS.ClientKey
+	cfg.InsecureSkipVerify = e.TLS.InsecureSkipVerify
+	cfg.ProxyURL = e.ProxyURL
+	cfg.Headers = e.Headers
+}
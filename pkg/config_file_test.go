@@ -0,0 +1,101 @@
+package omg_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/demetere/omg/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testConfigFile = `default: dev
+environments:
+  dev:
+    api_url: http://localhost:8080
+    store_id: 01HDEV
+    model: model.fga
+  staging:
+    api_url: https://staging.fga.example
+    store_id: ${STAGING_STORE_ID}
+    auth:
+      method: client_credentials
+      client_id: omg
+      client_secret: ${STAGING_CLIENT_SECRET}
+      issuer: ${STAGING_ISSUER:-auth.example.com}
+    headers:
+      X-Tenant: ${STAGING_TENANT:-acme}
+    migrations_dir: migrations/staging
+    tracker: tuples
+`
+
+func TestLoadConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "omg.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(testConfigFile), 0644))
+
+	found, ok := omg.FindConfigFile(dir)
+	require.True(t, ok)
+	assert.Equal(t, path, found)
+
+	file, err := omg.LoadConfigFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dev", "staging"}, file.EnvironmentNames())
+
+	dev, err := file.Environment("")
+	require.NoError(t, err)
+	vars := dev.Variables()
+	assert.Equal(t, "http://localhost:8080", vars["OPENFGA_API_URL"])
+	assert.Equal(t, "01HDEV", vars["OPENFGA_STORE_ID"])
+	assert.Empty(t, vars["OPENFGA_API_TOKEN"])
+	assert.Equal(t, "model.fga", dev.Model)
+
+	cfg, err := dev.ClientConfig()
+	require.NoError(t, err)
+	assert.Equal(t, omg.Config{ApiURL: "http://localhost:8080", StoreID: "01HDEV", AuthMethod: "none"}, cfg)
+
+	t.Setenv("STAGING_STORE_ID", "01HSTAGING")
+	t.Setenv("STAGING_CLIENT_SECRET", "secret")
+	t.Setenv("STAGING_ISSUER", "")
+	staging, err := file.Environment("staging")
+	require.NoError(t, err)
+	assert.Equal(t, "01HSTAGING", staging.StoreID)
+	assert.Equal(t, "auth.example.com", staging.Auth.Issuer)
+	assert.Equal(t, "migrations/staging", staging.MigrationsDir)
+
+	vars = staging.Variables()
+	assert.Equal(t, "secret", vars["OPENFGA_CLIENT_SECRET"])
+	assert.Equal(t, "client_credentials", vars["OPENFGA_AUTH_METHOD"])
+	assert.Equal(t, "tuples", vars["OMG_TRACKER"])
+	assert.Equal(t, "X-Tenant=acme", vars["OPENFGA_HEADERS"])
+
+	// The file itself is left untouched
+	assert.Equal(t, "${STAGING_STORE_ID}", file.Environments["staging"].StoreID)
+}
+
+func TestLoadConfigFile_Errors(t *testing.T) {
+	dir := t.TempDir()
+
+	_, ok := omg.FindConfigFile(dir)
+	assert.False(t, ok)
+
+	path := filepath.Join(dir, ".omgrc")
+	require.NoError(t, os.WriteFile(path, []byte(testConfigFile), 0644))
+	file, err := omg.LoadConfigFile(path)
+	require.NoError(t, err)
+
+	_, err = file.Environment("prod")
+	assert.ErrorContains(t, err, "environment 'prod' is not defined")
+	assert.ErrorContains(t, err, "available: dev, staging")
+
+	t.Setenv("STAGING_STORE_ID", "")
+	t.Setenv("STAGING_CLIENT_SECRET", "")
+	_, err = file.Environment("staging")
+	assert.ErrorContains(t, err, "unset variables: STAGING_STORE_ID, STAGING_CLIENT_SECRET")
+
+	badDefault := filepath.Join(dir, "omg.yml")
+	require.NoError(t, os.WriteFile(badDefault, []byte("default: prod\nenvironments:\n  dev:\n    store_id: 01HDEV\n"), 0644))
+	_, err = omg.LoadConfigFile(badDefault)
+	assert.ErrorContains(t, err, "default environment 'prod' is not defined")
+}
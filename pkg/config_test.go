@@ -0,0 +1,136 @@
+package omg_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/demetere/omg/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigFromEnv_ClientCredentials(t *testing.T) {
+	t.Setenv("OPENFGA_DATABASE_URL", "")
+	t.Setenv("OPENFGA_API_URL", "https://fga.example.com")
+	t.Setenv("OPENFGA_STORE_ID", "01HSTORE")
+	t.Setenv("OPENFGA_AUTH_METHOD", "")
+	t.Setenv("OPENFGA_API_TOKEN", "")
+	t.Setenv("OPENFGA_CLIENT_ID", "client")
+	t.Setenv("OPENFGA_CLIENT_SECRET", "secret")
+	t.Setenv("OPENFGA_TOKEN_ISSUER", "auth.example.com")
+	t.Setenv("OPENFGA_TOKEN_AUDIENCE", "https://fga.example.com/")
+
+	cfg, err := omg.ConfigFromEnv()
+	require.NoError(t, err)
+
+	assert.Equal(t, omg.Config{
+		ApiURL:        "https://fga.example.com",
+		StoreID:       "01HSTORE",
+		AuthMethod:    "client_credentials",
+		ClientID:      "client",
+		ClientSecret:  "secret",
+		TokenIssuer:   "auth.example.com",
+		TokenAudience: "https://fga.example.com/",
+	}, cfg)
+}
+
+func TestConfigFromEnv_DatabaseURL(t *testing.T) {
+	t.Setenv("OPENFGA_DATABASE_URL", "openfga://01HSTORE@localhost:8080?tls=false&token=secret")
+	t.Setenv("OPENFGA_API_URL", "https://ignored.example.com")
+
+	cfg, err := omg.ConfigFromEnv()
+	require.NoError(t, err)
+
+	assert.Equal(t, "http://localhost:8080", cfg.ApiURL)
+	assert.Equal(t, "01HSTORE", cfg.StoreID)
+	assert.Equal(t, "token", cfg.AuthMethod)
+	assert.Equal(t, "secret", cfg.APIToken)
+}
+
+func TestParseDatabaseURL_Invalid(t *testing.T) {
+	_, err := omg.ParseDatabaseURL("postgres://store@localhost")
+	assert.Error(t, err)
+
+	_, err = omg.ParseDatabaseURL("openfga://localhost:8080")
+	assert.Error(t, err, "store ID is required")
+}
+
+func TestParseDatabaseURL_StoreName(t *testing.T) {
+	cfg, err := omg.ParseDatabaseURL("openfga://billing%20prod@localhost:8080?tls=false&by=name")
+	require.NoError(t, err)
+	assert.Equal(t, "billing prod", cfg.StoreName)
+	assert.Empty(t, cfg.StoreID)
+
+	_, err = omg.ParseDatabaseURL("openfga://billing@localhost:8080?by=title")
+	assert.ErrorContains(t, err, "invalid by=title")
+}
+
+func TestNewClient_StoreName(t *testing.T) {
+	// Two pages of stores, "shared" twice
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("continuation_token") == "" {
+			w.Write([]byte(`{"stores":[{"id":"01HVMMBCMGZNT3SED4Z17ECXCA","name":"billing"},{"id":"01HVMMBCMGZNT3SED4Z17ECXCB","name":"shared"}],"continuation_token":"page-2"}`))
+			return
+		}
+		w.Write([]byte(`{"stores":[{"id":"01HVMMBCMGZNT3SED4Z17ECXCC","name":"shared"},{"id":"01HVMMBCMGZNT3SED4Z17ECXCD","name":"orders"}],"continuation_token":""}`))
+	}))
+	defer server.Close()
+
+	newClient := func(name string) (*omg.Client, error) {
+		return omg.NewClient(omg.Config{ApiURL: server.URL, StoreName: name, AuthMethod: "none"})
+	}
+
+	client, err := newClient("orders")
+	require.NoError(t, err)
+	assert.Equal(t, "01HVMMBCMGZNT3SED4Z17ECXCD", client.GetStoreID())
+
+	_, err = newClient("shared")
+	assert.ErrorContains(t, err, "2 stores are named 'shared'")
+
+	_, err = newClient("missing")
+	assert.ErrorContains(t, err, "no store is named 'missing'")
+
+	_, err = omg.NewClient(omg.Config{ApiURL: server.URL, AuthMethod: "none"})
+	assert.ErrorContains(t, err, "OPENFGA_STORE_ID or OPENFGA_STORE_NAME is required")
+}
+
+func TestConfigFromEnv_RequestSettings(t *testing.T) {
+	t.Setenv("OPENFGA_DATABASE_URL", "openfga://01HSTORE@localhost:8080?tls=false")
+	t.Setenv("OMG_MAX_RETRIES", "5")
+	t.Setenv("OMG_BACKOFF_BASE", "500ms")
+	t.Setenv("OMG_REQUESTS_PER_SECOND", "50")
+	t.Setenv("OMG_REQUEST_TIMEOUT", "1m")
+	t.Setenv("OMG_READ_PAGE_SIZE", "100")
+
+	cfg, err := omg.ConfigFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, 5, cfg.MaxRetries)
+	assert.Equal(t, 500*time.Millisecond, cfg.BackoffBase)
+	assert.Equal(t, 50.0, cfg.RequestsPerSecond)
+	assert.Equal(t, time.Minute, cfg.RequestTimeout)
+	assert.Equal(t, 100, cfg.ReadPageSize)
+
+	t.Setenv("OMG_BACKOFF_BASE", "soon")
+	_, err = omg.ConfigFromEnv()
+	assert.ErrorContains(t, err, "invalid OMG_BACKOFF_BASE")
+}
+
+func TestParseHeaders(t *testing.T) {
+	headers, err := omg.ParseHeaders("x-tenant=acme, X-Api-Key = a=b ,")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"X-Tenant": "acme", "X-Api-Key": "a=b"}, headers)
+
+	_, err = omg.ParseHeaders("X-Tenant")
+	assert.ErrorContains(t, err, "expected Name=value")
+
+	t.Setenv("OPENFGA_DATABASE_URL", "openfga://01HSTORE@localhost:8080?tls=false")
+	t.Setenv("OPENFGA_HEADERS", "X-Tenant=acme")
+	t.Setenv("OPENFGA_USER_AGENT", "ci")
+	cfg, err := omg.ConfigFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"X-Tenant": "acme"}, cfg.Headers)
+	assert.Equal(t, "ci", cfg.UserAgent)
+}
@@ -0,0 +1,442 @@
+package omg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DeclarativeMigration is a migration written as a list of operations in YAML or JSON
+// instead of Go code. omg runs it in-process, so no Go toolchain is needed:
+//
+//	description: Move invites to organizations
+//	verify:
+//	  - user:alice can_invite organization:acme
+//	up:
+//	  - op: add_relation
+//	    type: organization
+//	    relation: can_invite
+//	    definition: "[user]"
+//	  - op: move_relation
+//	    type: team
+//	    relation: can_invite
+//	    to: organization
+//	down:
+//	  - op: move_relation
+//	    type: organization
+//	    relation: can_invite
+//	    to: team
+type DeclarativeMigration struct {
+	Description string               `yaml:"description,omitempty" json:"description,omitempty"`
+	Verify      []string             `yaml:"verify,omitempty" json:"verify,omitempty"` // Check expectations, as in "// Verify:" headers
+	Up          []MigrationOperation `yaml:"up" json:"up"`
+	Down        []MigrationOperation `yaml:"down" json:"down"`
+}
+
+// MigrationOperation is one step of a declarative migration
+// Op selects the helper it runs; declarativeOperations lists the fields each op needs
+type MigrationOperation struct {
+	Op         string            `yaml:"op" json:"op"`
+	Type       string            `yaml:"type,omitempty" json:"type,omitempty"`
+	Relation   string            `yaml:"relation,omitempty" json:"relation,omitempty"`
+	From       string            `yaml:"from,omitempty" json:"from,omitempty"`
+	To         string            `yaml:"to,omitempty" json:"to,omitempty"`
+	Definition string            `yaml:"definition,omitempty" json:"definition,omitempty"` // Relation definition, or condition DSL for set_condition
+	Relations  map[string]string `yaml:"relations,omitempty" json:"relations,omitempty"`   // add_type
+	Condition  string            `yaml:"condition,omitempty" json:"condition,omitempty"`   // remove_condition
+	Model      string            `yaml:"model,omitempty" json:"model,omitempty"`           // apply_model, in DSL
+	Tuples     []Tuple           `yaml:"tuples,omitempty" json:"tuples,omitempty"`
+	Version    string            `yaml:"version,omitempty" json:"version,omitempty"` // backup and restore: the migration version the backup belongs to (default: this one)
+}
+
+// declarativeOperations lists the operations of declarative migrations and the fields
+// each one requires
+var declarativeOperations = map[string][]string{
+	"apply_model":      {"model"},
+	"add_type":         {"type"},
+	"remove_type":      {"type"},
+	"rename_type":      {"from", "to"},
+	"rename_user_type": {"from", "to"},
+	"add_relation":     {"type", "relation", "definition"},
+	"update_relation":  {"type", "relation", "definition"},
+	"remove_relation":  {"type", "relation"},
+	"rename_relation":  {"type", "from", "to"},
+	"move_relation":    {"type", "relation", "to"},
+	"copy_relation":    {"type", "from", "to"},
+	"delete_relation":  {"type", "relation"},
+	"set_condition":    {"definition"},
+	"remove_condition": {"condition"},
+	"write_tuples":     {"tuples"},
+	"delete_tuples":    {"tuples"},
+	"backup":           {"type"},
+	"restore":          {"type"},
+}
+
+// declarativeExtensions are the file extensions of declarative migrations
+var declarativeExtensions = []string{".yaml", ".yml", ".json"}
+
+// IsDeclarativeMigration reports whether a migration file is a declarative (YAML or JSON)
+// migration rather than Go code
+func IsDeclarativeMigration(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, declarative := range declarativeExtensions {
+		if ext == declarative {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseDeclarativeMigration parses and validates a declarative migration
+// JSON is read as YAML, of which it is a subset; unknown fields are rejected
+func ParseDeclarativeMigration(data []byte) (DeclarativeMigration, error) {
+	var migration DeclarativeMigration
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&migration); err != nil && !errors.Is(err, io.EOF) {
+		return migration, fmt.Errorf("failed to parse declarative migration: %w", err)
+	}
+	if len(migration.Up) == 0 {
+		return migration, fmt.Errorf("declarative migration has no up operations")
+	}
+
+	for direction, operations := range map[Direction][]MigrationOperation{DirectionUp: migration.Up, DirectionDown: migration.Down} {
+		for i, operation := range operations {
+			if err := operation.validate(); err != nil {
+				return migration, fmt.Errorf("%s operation %d: %w", direction, i+1, err)
+			}
+		}
+	}
+	for _, check := range migration.Verify {
+		if _, err := ParseCheckExpectation(check); err != nil {
+			return migration, err
+		}
+	}
+	return migration, nil
+}
+
+// ReadDeclarativeMigration reads a declarative migration file
+func ReadDeclarativeMigration(path string) (DeclarativeMigration, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return DeclarativeMigration{}, fmt.Errorf("failed to read migration: %w", err)
+	}
+	migration, err := ParseDeclarativeMigration(data)
+	if err != nil {
+		return migration, fmt.Errorf("%s: %w", path, err)
+	}
+	return migration, nil
+}
+
+// LoadDeclarativeMigration reads a declarative migration file as a Migration that runs its
+// operations. Version and name come from the file name: <version>_<name>.yaml
+func LoadDeclarativeMigration(path string) (Migration, error) {
+	declarative, err := ReadDeclarativeMigration(path)
+	if err != nil {
+		return Migration{}, err
+	}
+	version, name := declarativeFileName(path)
+	if version == "" {
+		return Migration{}, fmt.Errorf("%s: file name is not <version>_<name>%s", path, filepath.Ext(path))
+	}
+	return declarative.Migration(version, name)
+}
+
+// RegisterDeclarative registers the declarative migrations of a directory, so a binary
+// running Go migrations with Run picks them up too
+func RegisterDeclarative(dir string) error {
+	for _, ext := range declarativeExtensions {
+		files, err := filepath.Glob(filepath.Join(dir, "*_*"+ext))
+		if err != nil {
+			return err
+		}
+		sort.Strings(files)
+		for _, file := range files {
+			if version, _ := declarativeFileName(file); version == "" {
+				continue // e.g. the schema snapshot
+			}
+			migration, err := LoadDeclarativeMigration(file)
+			if err != nil {
+				return err
+			}
+			Register(migration)
+		}
+	}
+	return nil
+}
+
+// Migration returns the migration that runs the declarative operations
+func (d DeclarativeMigration) Migration(version, name string) (Migration, error) {
+	migration := Migration{
+		Version: version,
+		Name:    name,
+		Up: func(ctx context.Context, client *Client) error {
+			return runOperations(ctx, client, version, d.Up)
+		},
+		Down: func(ctx context.Context, client *Client) error {
+			return runOperations(ctx, client, version, d.Down)
+		},
+	}
+	for _, check := range d.Verify {
+		expectation, err := ParseCheckExpectation(check)
+		if err != nil {
+			return Migration{}, err
+		}
+		migration.Checks = append(migration.Checks, expectation)
+	}
+	return migration, nil
+}
+
+// Inverse returns the migration with up and down swapped. Backups and restores keep
+// reading the backups of version, the migration being inverted
+func (d DeclarativeMigration) Inverse(version string) DeclarativeMigration {
+	pin := func(operations []MigrationOperation) []MigrationOperation {
+		pinned := make([]MigrationOperation, len(operations))
+		for i, operation := range operations {
+			if (operation.Op == "backup" || operation.Op == "restore") && operation.Version == "" {
+				operation.Version = version
+			}
+			pinned[i] = operation
+		}
+		return pinned
+	}
+	return DeclarativeMigration{Up: pin(d.Down), Down: pin(d.Up)}
+}
+
+// runOperations runs declarative operations in order, stopping at the first error
+func runOperations(ctx context.Context, client *Client, version string, operations []MigrationOperation) error {
+	for i, operation := range operations {
+		if err := operation.run(ctx, client, version); err != nil {
+			return fmt.Errorf("operation %d (%s): %w", i+1, operation, err)
+		}
+	}
+	return nil
+}
+
+// validate checks that the operation is known and has the fields it requires
+func (o MigrationOperation) validate() error {
+	required, known := declarativeOperations[o.Op]
+	if !known {
+		return fmt.Errorf("unknown op '%s'", o.Op)
+	}
+	for _, field := range required {
+		if !o.has(field) {
+			return fmt.Errorf("%s needs '%s'", o.Op, field)
+		}
+	}
+	return nil
+}
+
+// has reports whether a field of the operation is set
+func (o MigrationOperation) has(field string) bool {
+	switch field {
+	case "type":
+		return o.Type != ""
+	case "relation":
+		return o.Relation != ""
+	case "from":
+		return o.From != ""
+	case "to":
+		return o.To != ""
+	case "definition":
+		return o.Definition != ""
+	case "condition":
+		return o.Condition != ""
+	case "model":
+		return o.Model != ""
+	case "tuples":
+		return len(o.Tuples) > 0
+	}
+	return false
+}
+
+// String describes the operation, e.g. "rename_relation document reader -> viewer"
+func (o MigrationOperation) String() string {
+	subject := o.Type
+	if o.Relation != "" {
+		subject += "#" + o.Relation
+	}
+	switch o.Op {
+	case "rename_type", "rename_user_type":
+		return fmt.Sprintf("%s %s -> %s", o.Op, o.From, o.To)
+	case "rename_relation", "copy_relation":
+		return fmt.Sprintf("%s %s %s -> %s", o.Op, o.Type, o.From, o.To)
+	case "move_relation":
+		return fmt.Sprintf("%s %s -> %s", o.Op, subject, o.To)
+	case "remove_condition":
+		return o.Op + " " + o.Condition
+	case "write_tuples", "delete_tuples":
+		return fmt.Sprintf("%s (%d tuples)", o.Op, len(o.Tuples))
+	case "apply_model", "set_condition":
+		return o.Op
+	}
+	return o.Op + " " + subject
+}
+
+// run calls the helper the operation stands for
+func (o MigrationOperation) run(ctx context.Context, client *Client, version string) error {
+	if o.Version != "" {
+		version = o.Version
+	}
+
+	switch o.Op {
+	case "apply_model":
+		return ApplyModelFromDSL(ctx, client, o.Model)
+	case "add_type":
+		return AddTypeToModel(ctx, client, o.Type, o.Relations)
+	case "remove_type":
+		if err := deleteTypeTuples(ctx, client, o.Type); err != nil {
+			return err
+		}
+		return RemoveTypeFromModel(ctx, client, o.Type)
+	case "rename_type":
+		return RenameType(ctx, client, o.From, o.To)
+	case "rename_user_type":
+		return RenameUserType(ctx, client, o.From, o.To)
+	case "add_relation":
+		return AddRelationToType(ctx, client, o.Type, o.Relation, o.Definition)
+	case "update_relation":
+		return UpdateRelationDefinition(ctx, client, o.Type, o.Relation, o.Definition)
+	case "remove_relation":
+		if err := RemoveRelationFromType(ctx, client, o.Type, o.Relation); err != nil {
+			return err
+		}
+		return DeleteRelation(ctx, client, o.Type, o.Relation)
+	case "rename_relation":
+		return RenameRelation(ctx, client, o.Type, o.From, o.To)
+	case "move_relation":
+		return MoveRelation(ctx, client, o.Type, o.To, o.Relation)
+	case "copy_relation":
+		return CopyRelation(ctx, client, o.Type, o.From, o.To)
+	case "delete_relation":
+		return DeleteRelation(ctx, client, o.Type, o.Relation)
+	case "set_condition":
+		return SetCondition(ctx, client, o.Definition)
+	case "remove_condition":
+		return RemoveCondition(ctx, client, o.Condition)
+	case "write_tuples":
+		return WriteTuplesBatch(ctx, client, o.Tuples)
+	case "delete_tuples":
+		return DeleteTuplesBatch(ctx, client, o.Tuples)
+	case "backup":
+		_, err := BackupForRemoval(ctx, client, version, o.Type, o.Relation)
+		return err
+	case "restore":
+		return RestoreFromBackup(ctx, client, BackupFilePath(version, o.Type, o.Relation))
+	}
+	return fmt.Errorf("unknown op '%s'", o.Op)
+}
+
+// declarativeFileName returns the version and name of a declarative migration file
+// The version is empty when the file name does not start with a numeric version
+func declarativeFileName(path string) (string, string) {
+	base := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	version, name, found := strings.Cut(base, "_")
+	if !found || version == "" || strings.Trim(version, "0123456789") != "" {
+		return "", ""
+	}
+	return version, name
+}
+
+// declarativeMetadata describes a declarative migration the way ParseMigrationMetadata
+// describes Go ones; the operations of up are its changes
+func declarativeMetadata(path string) (MigrationMetadata, error) {
+	declarative, err := ReadDeclarativeMigration(path)
+	if err != nil {
+		return MigrationMetadata{}, err
+	}
+
+	meta := MigrationMetadata{Description: declarative.Description}
+	meta.Version, meta.Name = declarativeFileName(path)
+	for _, operation := range declarative.Up {
+		meta.Changes = append(meta.Changes, operation.String())
+	}
+	for _, check := range declarative.Verify {
+		expectation, err := ParseCheckExpectation(check)
+		if err != nil {
+			return MigrationMetadata{}, err
+		}
+		meta.Checks = append(meta.Checks, expectation)
+	}
+	return meta, nil
+}
+
+// declarativeRemovals lists the types and relations a declarative migration removes
+// in one direction, like FindRemovals does for Go migrations
+func declarativeRemovals(path string, direction Direction) ([]Removal, error) {
+	declarative, err := ReadDeclarativeMigration(path)
+	if err != nil {
+		return nil, err
+	}
+	operations := declarative.Up
+	if direction == DirectionDown {
+		operations = declarative.Down
+	}
+
+	found := make(map[Removal]bool)
+	for _, operation := range operations {
+		switch operation.Op {
+		case "remove_type", "remove_relation", "delete_relation", "backup":
+			found[Removal{Type: operation.Type, Relation: operation.Relation}] = true
+		}
+	}
+	return sortedRemovals(found), nil
+}
+
+// writeDeclarativeMigration writes a declarative migration in the format its extension names
+func writeDeclarativeMigration(path string, migration DeclarativeMigration) error {
+	var data []byte
+	var err error
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		data, err = json.MarshalIndent(migration, "", "  ")
+		data = append(data, '\n')
+	} else {
+		data, err = yaml.Marshal(migration)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode migration: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create migrations directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write migration file: %w", err)
+	}
+	return nil
+}
+
+// generateInverseDeclarative writes the inverse of a declarative migration next to it,
+// in the same format. See GenerateInverseMigration
+func generateInverseDeclarative(sourcePath, name, migrationsDir string) (string, error) {
+	declarative, err := ReadDeclarativeMigration(sourcePath)
+	if err != nil {
+		return "", err
+	}
+	sourceVersion, sourceName := declarativeFileName(sourcePath)
+	if sourceVersion == "" {
+		return "", fmt.Errorf("%s: file name is not <version>_<name>%s", sourcePath, filepath.Ext(sourcePath))
+	}
+	if len(declarative.Down) == 0 {
+		return "", fmt.Errorf("%s has no down operations to invert", sourcePath)
+	}
+
+	inverse := declarative.Inverse(sourceVersion)
+	inverse.Description = fmt.Sprintf("Inverse of %s_%s: up is its down, down is its up", sourceVersion, sourceName)
+
+	version := time.Now().Format("20060102150405")
+	filename := filepath.Join(migrationsDir, version+"_"+sanitizeName(name)+filepath.Ext(sourcePath))
+	if err := writeDeclarativeMigration(filename, inverse); err != nil {
+		return "", err
+	}
+	return filename, nil
+}
@@ -0,0 +1,159 @@
+package omg_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/demetere/omg/internal/testhelpers"
+	"github.com/demetere/omg/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const declarativeYAML = `description: Rename document readers to viewers
+verify:
+  - user:alice viewer document:readme
+up:
+  - op: add_relation
+    type: document
+    relation: viewer
+    definition: "[user]"
+  - op: rename_relation
+    type: document
+    from: reader
+    to: viewer
+  - op: backup
+    type: document
+    relation: reader
+  - op: remove_relation
+    type: document
+    relation: reader
+down:
+  - op: add_relation
+    type: document
+    relation: reader
+    definition: "[user]"
+  - op: restore
+    type: document
+    relation: reader
+  - op: rename_relation
+    type: document
+    from: viewer
+    to: reader
+  - op: remove_relation
+    type: document
+    relation: viewer
+`
+
+func writeDeclarative(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestParseDeclarativeMigration(t *testing.T) {
+	migration, err := omg.ParseDeclarativeMigration([]byte(declarativeYAML))
+	require.NoError(t, err)
+	assert.Equal(t, "Rename document readers to viewers", migration.Description)
+	require.Len(t, migration.Up, 4)
+	assert.Equal(t, "rename_relation document reader -> viewer", migration.Up[1].String())
+
+	// JSON is read the same way
+	migration, err = omg.ParseDeclarativeMigration([]byte(`{"up": [{"op": "add_type", "type": "folder", "relations": {"owner": "[user]"}}]}`))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"owner": "[user]"}, migration.Up[0].Relations)
+}
+
+func TestParseDeclarativeMigration_Invalid(t *testing.T) {
+	tests := []struct {
+		content string
+		err     string
+	}{
+		{`down: [{op: remove_type, type: folder}]`, "no up operations"},
+		{`up: [{op: drop_everything}]`, "unknown op 'drop_everything'"},
+		{`up: [{op: rename_relation, type: document, from: reader}]`, "rename_relation needs 'to'"},
+		{`up: [{op: add_type, type: folder, relation_map: {}}]`, "relation_map"},
+		{"verify: [nonsense]\nup: [{op: add_type, type: folder}]", "nonsense"},
+	}
+	for _, tt := range tests {
+		_, err := omg.ParseDeclarativeMigration([]byte(tt.content))
+		assert.ErrorContains(t, err, tt.err, tt.content)
+	}
+}
+
+func TestDeclarativeMigration_Metadata(t *testing.T) {
+	path := writeDeclarative(t, "20240101120000_rename_readers.yaml", declarativeYAML)
+	assert.True(t, omg.IsDeclarativeMigration(path))
+
+	meta, err := omg.ParseMigrationMetadata(path)
+	require.NoError(t, err)
+	assert.Equal(t, "20240101120000", meta.Version)
+	assert.Equal(t, "rename_readers", meta.Name)
+	assert.Equal(t, "Rename document readers to viewers", meta.Description)
+	assert.Contains(t, meta.Changes, "remove_relation document#reader")
+	require.Len(t, meta.Checks, 1)
+
+	removals, err := omg.FindRemovals(path, omg.DirectionUp)
+	require.NoError(t, err)
+	assert.Equal(t, []omg.Removal{{Type: "document", Relation: "reader"}}, removals)
+
+	removals, err = omg.FindRemovals(path, omg.DirectionDown)
+	require.NoError(t, err)
+	assert.Equal(t, []omg.Removal{{Type: "document", Relation: "viewer"}}, removals)
+
+	migration, err := omg.LoadDeclarativeMigration(path)
+	require.NoError(t, err)
+	assert.Equal(t, "20240101120000", migration.Version)
+	assert.Len(t, migration.Checks, 1)
+
+	_, err = omg.LoadDeclarativeMigration(writeDeclarative(t, "rename_readers.yaml", declarativeYAML))
+	assert.ErrorContains(t, err, "<version>_<name>.yaml")
+}
+
+func TestGenerateInverseMigration_Declarative(t *testing.T) {
+	source := writeDeclarative(t, "20240101120000_rename_readers.yaml", declarativeYAML)
+	dir := t.TempDir()
+
+	filename, err := omg.GenerateInverseMigration(source, "restore_readers", dir)
+	require.NoError(t, err)
+	assert.Equal(t, ".yaml", filepath.Ext(filename))
+
+	inverse, err := omg.ReadDeclarativeMigration(filename)
+	require.NoError(t, err)
+	require.Len(t, inverse.Up, 4)
+	assert.Equal(t, "add_relation", inverse.Up[0].Op)
+	assert.Equal(t, "reader", inverse.Up[0].Relation)
+
+	// The restore reads the backup the source migration took
+	assert.Equal(t, "restore", inverse.Up[1].Op)
+	assert.Equal(t, "20240101120000", inverse.Up[1].Version)
+}
+
+func TestDeclarativeMigration_Run(t *testing.T) {
+	ctx := context.Background()
+
+	container, client := testhelpers.SetupOpenFGAContainer(t, ctx, `
+type user
+type document
+  relations
+    define reader: [user]
+`)
+	defer container.Terminate(ctx)
+
+	require.NoError(t, client.WriteTuples(ctx, []omg.Tuple{{User: "user:alice", Relation: "reader", Object: "document:readme"}}))
+
+	path := writeDeclarative(t, "20240101120000_rename_readers.yaml", declarativeYAML)
+	migration, err := omg.LoadDeclarativeMigration(path)
+	require.NoError(t, err)
+
+	require.NoError(t, migration.Up(ctx, client))
+	exists, err := omg.RelationExists(ctx, client, "document", "reader")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	viewers, err := omg.ReadAllTuples(ctx, client, "document", "viewer")
+	require.NoError(t, err)
+	assert.Len(t, viewers, 1)
+}
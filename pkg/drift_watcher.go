@@ -0,0 +1,129 @@
+package omg
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+)
+
+// Drift describes a live model that no longer matches the expected one
+type Drift struct {
+	Time         time.Time
+	ModelID      string // Live model ID
+	LiveHash     string
+	ExpectedHash string
+	Changes      []ModelChange // Changes from the model file to the live model; nil for lock files
+}
+
+// DriftWatcher periodically compares the store's live model with model.fga or model.lock
+// and calls onDrift when they stop matching, e.g. to page someone or bump a metric
+type DriftWatcher struct {
+	client    *Client
+	modelPath string
+	interval  time.Duration
+	onDrift   func(Drift)
+
+	// OnError receives errors from periodic checks; nil ignores them
+	OnError func(error)
+
+	lastReported string // Live hash of the last drift passed to onDrift
+}
+
+// NewDriftWatcher creates a watcher for the model at modelPath
+// A path ending in .lock is read as a lock file, anything else as a model DSL file.
+// onDrift is called once per distinct drifted model, not on every check
+func NewDriftWatcher(client *Client, modelPath string, interval time.Duration, onDrift func(Drift)) *DriftWatcher {
+	return &DriftWatcher{
+		client:    client,
+		modelPath: modelPath,
+		interval:  interval,
+		onDrift:   onDrift,
+	}
+}
+
+// Run checks immediately and then every interval until ctx is cancelled
+// It returns ctx.Err() when stopped
+func (w *DriftWatcher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		w.poll(ctx)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll runs one check and reports new drift or errors
+func (w *DriftWatcher) poll(ctx context.Context) {
+	drift, err := w.Check(ctx)
+	if err != nil {
+		if w.OnError != nil && ctx.Err() == nil {
+			w.OnError(err)
+		}
+		return
+	}
+
+	if drift == nil {
+		w.lastReported = ""
+		return
+	}
+	if drift.LiveHash == w.lastReported {
+		return
+	}
+	w.lastReported = drift.LiveHash
+	if w.onDrift != nil {
+		w.onDrift(*drift)
+	}
+}
+
+// Check compares the live model with the expected model once
+// Returns nil when they match
+func (w *DriftWatcher) Check(ctx context.Context) (*Drift, error) {
+	model, err := w.client.GetCurrentAuthorizationModel(ctx)
+	if err != nil {
+		return nil, err
+	}
+	liveState := BuildModelStateFromAuthorizationModel(model)
+
+	drift := &Drift{
+		Time:     time.Now().UTC(),
+		ModelID:  model.GetId(),
+		LiveHash: HashModelState(liveState),
+	}
+
+	if filepath.Ext(w.modelPath) == ".lock" {
+		lock, err := ReadModelLock(w.modelPath)
+		if err != nil {
+			return nil, err
+		}
+		if lock == nil {
+			return nil, fmt.Errorf("lock file %s not found", w.modelPath)
+		}
+		drift.ExpectedHash = lock.ModelHash
+	} else {
+		dsl, err := LoadCurrentModelFromPath(w.modelPath)
+		if err != nil {
+			return nil, err
+		}
+		fileModel, err := ParseModel(dsl)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse model: %w", err)
+		}
+		fileState := BuildModelState(fileModel)
+		drift.ExpectedHash = HashModelState(fileState)
+		if drift.ExpectedHash != drift.LiveHash {
+			drift.Changes = DetectChanges(fileState, liveState)
+		}
+	}
+
+	if drift.ExpectedHash == drift.LiveHash {
+		return nil, nil
+	}
+	return drift, nil
+}
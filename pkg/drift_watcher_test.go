@@ -0,0 +1,58 @@
+package omg_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/demetere/omg/internal/testhelpers"
+	"github.com/demetere/omg/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDriftWatcher(t *testing.T) {
+	ctx := context.Background()
+
+	const model = `model
+  schema 1.1
+
+type user
+
+type document
+  relations
+    define viewer: [user]
+`
+	container, client := testhelpers.SetupOpenFGAContainer(t, ctx, "")
+	defer container.Terminate(ctx)
+	require.NoError(t, omg.ApplyModelFromDSL(ctx, client, model))
+
+	modelPath := filepath.Join(t.TempDir(), "model.fga")
+	require.NoError(t, os.WriteFile(modelPath, []byte(model), 0644))
+
+	var drifts []omg.Drift
+	watcher := omg.NewDriftWatcher(client, modelPath, 50*time.Millisecond, func(d omg.Drift) {
+		drifts = append(drifts, d)
+	})
+
+	drift, err := watcher.Check(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, drift, "live model matches the file")
+
+	require.NoError(t, omg.AddRelationToType(ctx, client, "document", "editor", "[user]"))
+
+	drift, err = watcher.Check(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, drift)
+	assert.NotEqual(t, drift.ExpectedHash, drift.LiveHash)
+	require.Len(t, drift.Changes, 1)
+	assert.Equal(t, omg.ChangeTypeAddRelation, drift.Changes[0].Type)
+
+	// Run reports the same drift once, however many checks see it
+	runCtx, cancel := context.WithTimeout(ctx, 300*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, watcher.Run(runCtx), context.DeadlineExceeded)
+	assert.Len(t, drifts, 1)
+}
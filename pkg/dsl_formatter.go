@@ -0,0 +1,182 @@
+package omg
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// dslType is a type definition as FormatDSL lays it out
+type dslType struct {
+	comments  []string // Comment lines above the type
+	name      string
+	relations []dslRelation
+	trailing  []string // Comment lines after the last relation
+}
+
+// dslCondition is a condition block with the comment lines above it
+type dslCondition struct {
+	comments []string
+	block    string
+}
+
+// dslRelation is a relation definition with the comment lines above it
+type dslRelation struct {
+	comments   []string
+	name       string
+	definition string
+}
+
+// FormatDSL returns the canonical form of a model in OpenFGA DSL
+// The model header is written out (schema 1.1 when missing), types keep their order and
+// relations are sorted by name. Conditions follow the types, in their order, with their
+// parameters sorted and the expression on one line. Definitions are respaced ("[user,team#member]  or owner"
+// becomes "[user, team#member] or owner", "parent->owner" becomes "owner from parent").
+// Comment lines move with the type or relation below them, except indented comments
+// closing a type, which stay at its end; blank lines are normalized.
+// Formatting a formatted model returns it unchanged
+func FormatDSL(dsl string) (string, error) {
+	if _, err := parseDSLToModel(dsl); err != nil {
+		return "", err
+	}
+
+	var header, pending []string
+	var types []*dslType
+	var conditions []dslCondition
+	var current *dslType
+	schema := DefaultSchemaVersion
+	closing := 0 // Leading pending comments that are indented, so belong to the current type
+
+	lines := strings.Split(dsl, "\n")
+	for i := 0; i < len(lines); i++ {
+		raw := lines[i]
+		line := strings.TrimSpace(raw)
+
+		switch {
+		case line == "":
+			continue
+
+		case strings.HasPrefix(line, "#"):
+			if current != nil && closing == len(pending) && line != raw {
+				closing++
+			}
+			pending = append(pending, line)
+
+		case line == "model":
+			if current != nil {
+				return "", fmt.Errorf("line %d: model header after type definitions", i+1)
+			}
+			header, pending = append(header, pending...), nil
+
+		case strings.HasPrefix(line, "schema "):
+			schema = strings.Join(strings.Fields(strings.TrimPrefix(line, "schema")), " ")
+			header, pending = append(header, pending...), nil
+
+		case strings.HasPrefix(line, "condition "):
+			end, err := conditionBlockEnd(lines, i)
+			if err != nil {
+				return "", err
+			}
+			condition, err := parseCondition(strings.Join(lines[i:end+1], "\n"))
+			if err != nil {
+				return "", fmt.Errorf("line %d: %w", i+1, err)
+			}
+			if current != nil {
+				current.trailing = pending[:closing]
+				pending = pending[closing:]
+			}
+			conditions = append(conditions, dslCondition{comments: pending, block: formatConditionBlock(condition)})
+			current, pending, closing = nil, nil, 0
+			i = end
+
+		case strings.HasPrefix(line, "type "):
+			if current != nil {
+				current.trailing = pending[:closing]
+				pending = pending[closing:]
+			}
+			current = &dslType{comments: pending, name: strings.TrimSpace(strings.TrimPrefix(line, "type"))}
+			types = append(types, current)
+			pending, closing = nil, 0
+
+		case line == "relations":
+			if current == nil {
+				return "", fmt.Errorf("line %d: relations outside of a type", i+1)
+			}
+
+		case strings.HasPrefix(line, "define "):
+			if current == nil {
+				return "", fmt.Errorf("line %d: relation defined outside of a type", i+1)
+			}
+			name, definition, _ := strings.Cut(strings.TrimPrefix(line, "define"), ":")
+			formatted, err := formatRelationDefinition(strings.TrimSpace(definition))
+			if err != nil {
+				return "", fmt.Errorf("line %d: %w", i+1, err)
+			}
+			current.relations = append(current.relations, dslRelation{
+				comments:   pending,
+				name:       strings.TrimSpace(name),
+				definition: formatted,
+			})
+			pending, closing = nil, 0
+
+		default:
+			return "", fmt.Errorf("line %d: cannot format %q", i+1, line)
+		}
+	}
+
+	// Unindented comments after the last type stay at the end of the file
+	if current != nil {
+		current.trailing = pending[:closing]
+		pending = pending[closing:]
+	}
+
+	var out strings.Builder
+	writeDSLComments(&out, header, "")
+	fmt.Fprintf(&out, "model\n  schema %s\n", schema)
+
+	for _, typeDef := range types {
+		out.WriteString("\n")
+		writeDSLComments(&out, typeDef.comments, "")
+		fmt.Fprintf(&out, "type %s\n", typeDef.name)
+
+		if len(typeDef.relations) > 0 {
+			out.WriteString("  relations\n")
+			sort.SliceStable(typeDef.relations, func(i, j int) bool {
+				return typeDef.relations[i].name < typeDef.relations[j].name
+			})
+			for _, relation := range typeDef.relations {
+				writeDSLComments(&out, relation.comments, "    ")
+				fmt.Fprintf(&out, "    define %s: %s\n", relation.name, relation.definition)
+			}
+		}
+		writeDSLComments(&out, typeDef.trailing, "    ")
+	}
+
+	for _, condition := range conditions {
+		out.WriteString("\n")
+		writeDSLComments(&out, condition.comments, "")
+		out.WriteString(condition.block)
+	}
+
+	if len(pending) > 0 {
+		out.WriteString("\n")
+		writeDSLComments(&out, pending, "")
+	}
+	return out.String(), nil
+}
+
+// formatRelationDefinition respaces a relation definition by parsing and rewriting it
+func formatRelationDefinition(definition string) (string, error) {
+	userset, typeRestrictions, err := parseRelationDefinition(definition)
+	if err != nil {
+		return "", err
+	}
+	return formatUsersetWithMetadata(userset, typeRestrictions), nil
+}
+
+// writeDSLComments writes comment lines with the given indentation
+func writeDSLComments(out *strings.Builder, comments []string, indent string) {
+	for _, comment := range comments {
+		out.WriteString(indent + comment + "\n")
+	}
+}
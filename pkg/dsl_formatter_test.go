@@ -0,0 +1,90 @@
+package omg_test
+
+import (
+	"testing"
+
+	"github.com/demetere/omg/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatDSL(t *testing.T) {
+	input := `# Document sharing
+model
+    schema 1.1
+type user
+
+
+type document
+	relations
+    # Who can read
+	  define viewer:   [user,team#member]  or editor
+    define editor: [user] or owner from parent
+        define parent: [folder]
+  define owner:[user]
+  # TODO: approvals
+type folder
+  relations
+    define owner: [user]
+    define viewer: parent->viewer or owner
+`
+
+	formatted, err := omg.FormatDSL(input)
+	require.NoError(t, err)
+	assert.Equal(t, `# Document sharing
+model
+  schema 1.1
+
+type user
+
+type document
+  relations
+    define editor: [user] or owner from parent
+    define owner: [user]
+    define parent: [folder]
+    # Who can read
+    define viewer: [user, team#member] or editor
+    # TODO: approvals
+
+type folder
+  relations
+    define owner: [user]
+    define viewer: viewer from parent or owner
+`, formatted)
+
+	again, err := omg.FormatDSL(formatted)
+	require.NoError(t, err)
+	assert.Equal(t, formatted, again, "formatting is idempotent")
+
+	before, err := omg.ParseDSLToModel(input)
+	require.NoError(t, err)
+	after, err := omg.ParseDSLToModel(formatted)
+	require.NoError(t, err)
+	assert.Empty(t, omg.DetectChanges(omg.BuildModelState(before), omg.BuildModelState(after)), "formatting does not change the model")
+}
+
+func TestFormatDSL_AddsHeader(t *testing.T) {
+	formatted, err := omg.FormatDSL("type user\n")
+	require.NoError(t, err)
+	assert.Equal(t, "model\n  schema 1.1\n\ntype user\n", formatted)
+}
+
+func TestFormatDSL_Invalid(t *testing.T) {
+	_, err := omg.FormatDSL("type document\n  relations\n    define viewer: [user\n")
+	assert.Error(t, err)
+
+	_, err = omg.FormatDSL("type document\n  unknown line\n")
+	assert.ErrorContains(t, err, "line 2")
+}
+
+func TestFormatDSL_TrailingComments(t *testing.T) {
+	formatted, err := omg.FormatDSL("type user\n  relations\n    define self: [user]\n    # end of user\n# end of file\n")
+	require.NoError(t, err)
+	assert.Equal(t, "model\n  schema 1.1\n\ntype user\n  relations\n    define self: [user]\n    # end of user\n\n# end of file\n", formatted)
+}
+
+func TestFormatDSL_Parentheses(t *testing.T) {
+	formatted, err := omg.FormatDSL("type document\n  relations\n    define can_view: ( owner or editor)and (approved)\n")
+	require.NoError(t, err)
+	assert.Contains(t, formatted, "    define can_view: (owner or editor) and approved\n")
+}
@@ -0,0 +1,121 @@
+package omg
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// eventSinkKey is the context key RunMigration stores its event callback under
+type eventSinkKey struct{}
+
+// withEventSink returns a context whose helpers report events to sink
+func withEventSink(ctx context.Context, sink func(MigrationEvent)) context.Context {
+	return context.WithValue(ctx, eventSinkKey{}, sink)
+}
+
+// emitEvent reports an event to the sink of a migration run by RunMigration, if any
+func emitEvent(ctx context.Context, event MigrationEvent) {
+	sink, ok := ctx.Value(eventSinkKey{}).(func(MigrationEvent))
+	if !ok {
+		return
+	}
+	event.Time = time.Now()
+	sink(event)
+}
+
+// Warn reports a warning from inside a migration
+// It is printed, and sent as a warning event when the migration runs under Run or Migrator
+func Warn(ctx context.Context, format string, args ...any) {
+	message := fmt.Sprintf(format, args...)
+	fmt.Printf("Warning: %s\n", message)
+	emitEvent(ctx, MigrationEvent{Type: EventWarning, Message: message})
+}
+
+// EventStream fans migration events out to subscribers, for dashboards and TUIs
+// Pass its Emit method as OnEvent to Run, Migrator or RunMigration
+type EventStream struct {
+	mu          sync.RWMutex // Held for reading while sending, for writing while closing channels
+	subscribers map[*eventSubscriber]struct{}
+	closed      bool
+}
+
+// eventSubscriber is one Subscribe call; done is closed to stop sends to events
+type eventSubscriber struct {
+	events chan MigrationEvent
+	done   chan struct{}
+	once   sync.Once
+}
+
+// stop unblocks senders waiting on a full channel
+func (sub *eventSubscriber) stop() {
+	sub.once.Do(func() { close(sub.done) })
+}
+
+// NewEventStream creates an event stream without subscribers
+func NewEventStream() *EventStream {
+	return &EventStream{subscribers: make(map[*eventSubscriber]struct{})}
+}
+
+// Subscribe returns a channel receiving every event emitted from now on, and a function
+// that unsubscribes and closes it. Lifecycle events wait for a full channel; batch
+// progress events are dropped for subscribers that fall behind
+func (s *EventStream) Subscribe(buffer int) (<-chan MigrationEvent, func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub := &eventSubscriber{events: make(chan MigrationEvent, buffer), done: make(chan struct{})}
+	if s.closed {
+		close(sub.events)
+		return sub.events, func() {}
+	}
+	s.subscribers[sub] = struct{}{}
+
+	return sub.events, func() {
+		sub.stop()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, ok := s.subscribers[sub]; ok {
+			delete(s.subscribers, sub)
+			close(sub.events)
+		}
+	}
+}
+
+// Emit sends an event to all subscribers
+func (s *EventStream) Emit(event MigrationEvent) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for sub := range s.subscribers {
+		if event.Type == EventBatchProgress {
+			select {
+			case sub.events <- event:
+			default:
+			}
+			continue
+		}
+		select {
+		case sub.events <- event:
+		case <-sub.done:
+		}
+	}
+}
+
+// Close closes all subscriber channels; later events are discarded
+func (s *EventStream) Close() {
+	s.mu.RLock()
+	for sub := range s.subscribers {
+		sub.stop()
+	}
+	s.mu.RUnlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for sub := range s.subscribers {
+		close(sub.events)
+	}
+	s.subscribers = make(map[*eventSubscriber]struct{})
+	s.closed = true
+}
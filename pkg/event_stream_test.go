@@ -0,0 +1,80 @@
+package omg_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/demetere/omg/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventStream_RunEvents(t *testing.T) {
+	ctx := context.Background()
+	var calls []string
+
+	stream := omg.NewEventStream()
+	events, unsubscribe := stream.Subscribe(64)
+	defer unsubscribe()
+
+	migrations := recordingMigrations(&calls, "001", "002")
+	migrations[1].Up = func(ctx context.Context, client *omg.Client) error {
+		omg.Warn(ctx, "%d tuples have no owner", 3)
+		return nil
+	}
+
+	_, err := omg.Run(ctx, nil, omg.MigrateOptions{
+		Tracker:    omg.NewMemoryTracker(),
+		Migrations: migrations,
+		OnEvent:    stream.Emit,
+	})
+	require.NoError(t, err)
+	stream.Close()
+
+	var received []omg.MigrationEvent
+	for event := range events {
+		received = append(received, event)
+	}
+
+	require.Len(t, received, 7)
+	assert.Equal(t, omg.EventRunStarted, received[0].Type)
+	assert.Equal(t, 2, received[0].Count)
+	assert.Equal(t, omg.DirectionUp, received[0].Direction)
+
+	warning := received[4]
+	assert.Equal(t, omg.EventWarning, warning.Type)
+	assert.Equal(t, "3 tuples have no owner", warning.Message)
+	assert.Equal(t, "002", warning.Version)
+
+	finished := received[6]
+	assert.Equal(t, omg.EventRunFinished, finished.Type)
+	assert.Equal(t, 2, finished.Count)
+	assert.NoError(t, finished.Err)
+}
+
+func TestEventStream_Unsubscribe(t *testing.T) {
+	stream := omg.NewEventStream()
+	first, unsubscribeFirst := stream.Subscribe(1)
+	second, unsubscribeSecond := stream.Subscribe(1)
+	defer unsubscribeSecond()
+
+	unsubscribeFirst()
+	unsubscribeFirst() // Safe to call twice
+	_, open := <-first
+	assert.False(t, open)
+
+	// Batch progress is dropped rather than blocking on a full channel
+	stream.Emit(omg.MigrationEvent{Type: omg.EventBatchProgress})
+	stream.Emit(omg.MigrationEvent{Type: omg.EventBatchProgress})
+	assert.Len(t, second, 1)
+
+	stream.Close()
+	_, open = <-second
+	assert.True(t, open)
+	_, open = <-second
+	assert.False(t, open)
+
+	late, _ := stream.Subscribe(1)
+	_, open = <-late
+	assert.False(t, open)
+}
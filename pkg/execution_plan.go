@@ -0,0 +1,258 @@
+package omg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ExecutionPlanFormat is the format version of plan files written by 'omg plan'
+const ExecutionPlanFormat = 1
+
+// ErrStalePlan is returned when the store's model changed after a plan was made
+var ErrStalePlan = errors.New("the store's model changed since the plan was made")
+
+// ExecutionPlan is a reviewable record of the changes 'omg apply' makes, written by 'omg plan'
+// It is bound to the model the store had when it was planned: ApplyExecutionPlan refuses
+// to run once the live model has changed, so what was reviewed is what gets applied
+type ExecutionPlan struct {
+	Format    int       `json:"format"`
+	ID        string    `json:"id"` // Version-style timestamp; removal backups go to .omg/backups/<id>/
+	StoreID   string    `json:"store_id"`
+	CreatedAt time.Time `json:"created_at"`
+	CreatedBy string    `json:"created_by,omitempty"`
+
+	SourceModelID   string `json:"source_model_id"`
+	SourceModelHash string `json:"source_model_hash"`           // Live model the plan was computed against
+	TargetModelHash string `json:"target_model_hash,omitempty"` // Model file the changes lead to
+
+	// ModelOnly plans leave tuples alone: removals keep their tuples and take no backup
+	ModelOnly bool `json:"model_only,omitempty"`
+
+	Changes []ChangeSummary `json:"changes"` // In the order they are applied
+}
+
+// NewExecutionPlan plans changes against the store model recorded in source
+// target is the desired model, recorded so reviewers can match the plan to a model file
+func NewExecutionPlan(storeID string, source ModelLock, target *ModelState, changes []ModelChange) *ExecutionPlan {
+	return NewExecutionPlanWithOptions(storeID, source, target, changes, GenerateOptions{})
+}
+
+// NewExecutionPlanWithOptions plans changes with the operations a migration generated
+// with opts would run; of the options only ModelOnly applies
+func NewExecutionPlanWithOptions(storeID string, source ModelLock, target *ModelState, changes []ModelChange, opts GenerateOptions) *ExecutionPlan {
+	opts = GenerateOptions{ModelOnly: opts.ModelOnly}
+	now := time.Now()
+	plan := &ExecutionPlan{
+		Format:          ExecutionPlanFormat,
+		ID:              now.Format("20060102150405"),
+		StoreID:         storeID,
+		CreatedAt:       now.UTC(),
+		CreatedBy:       CurrentOperator(),
+		SourceModelID:   source.ModelID,
+		SourceModelHash: source.ModelHash,
+		ModelOnly:       opts.ModelOnly,
+		Changes:         BuildGenerateSummary("", changes, opts).Changes,
+	}
+	if target != nil {
+		plan.TargetModelHash = HashModelState(target)
+	}
+	return plan
+}
+
+// JSON returns the plan as indented JSON
+func (p *ExecutionPlan) JSON() ([]byte, error) {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode plan: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// ParseExecutionPlan parses a plan written by 'omg plan'
+func ParseExecutionPlan(data []byte) (*ExecutionPlan, error) {
+	var plan ExecutionPlan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return nil, fmt.Errorf("failed to parse plan: %w", err)
+	}
+	if plan.Format != ExecutionPlanFormat {
+		return nil, fmt.Errorf("unsupported plan format %d (expected %d)", plan.Format, ExecutionPlanFormat)
+	}
+	if plan.SourceModelHash == "" {
+		return nil, fmt.Errorf("plan has no source_model_hash")
+	}
+	for i, change := range plan.Changes {
+		if change.Type == "" || change.TypeName == "" {
+			return nil, fmt.Errorf("change %d is missing its type or type_name", i+1)
+		}
+	}
+	return &plan, nil
+}
+
+// ReadExecutionPlan reads a plan file written by 'omg plan'
+func ReadExecutionPlan(path string) (*ExecutionPlan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan: %w", err)
+	}
+	plan, err := ParseExecutionPlan(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return plan, nil
+}
+
+// ModelChanges returns the plan's changes as model changes, in the order they are applied
+func (p *ExecutionPlan) ModelChanges() ChangeSet {
+	changes := make(ChangeSet, len(p.Changes))
+	for i, change := range p.Changes {
+		changes[i] = ModelChange{
+			Type:         change.Type,
+			TypeName:     change.TypeName,
+			RelationName: change.Relation,
+			TargetType:   change.TargetType,
+			OldValue:     change.OldValue,
+			NewValue:     change.NewValue,
+			Details:      change.Details,
+			Confidence:   change.Confidence,
+		}
+	}
+	return changes
+}
+
+// VerifyExecutionPlan checks that a plan still applies to the client's store: same store
+// and the same live model as when it was planned. A stale plan returns ErrStalePlan
+func VerifyExecutionPlan(ctx context.Context, client *Client, plan *ExecutionPlan) error {
+	if plan.StoreID != "" && plan.StoreID != client.GetStoreID() {
+		return fmt.Errorf("plan was made for store %s, not %s", plan.StoreID, client.GetStoreID())
+	}
+
+	live, err := LoadModelLockFromOpenFGA(ctx, client)
+	if err != nil {
+		return err
+	}
+	if live.ModelHash != plan.SourceModelHash {
+		return fmt.Errorf("%w: planned against %s (model %s), live model is %s (model %s); run 'omg plan' again",
+			ErrStalePlan, plan.SourceModelHash, plan.SourceModelID, live.ModelHash, live.ModelID)
+	}
+	return nil
+}
+
+// ApplyExecutionPlan verifies a plan and applies its changes with the same helpers a
+// migration generated from them would call. Removed types and relations are backed up
+// under the plan's ID first, unless the plan is model-only. Stops at the first change that fails
+func ApplyExecutionPlan(ctx context.Context, client *Client, plan *ExecutionPlan) error {
+	if err := VerifyExecutionPlan(ctx, client, plan); err != nil {
+		return err
+	}
+	if plan.ModelOnly {
+		if err := checkModelOnly(plan.ModelChanges()); err != nil {
+			return err
+		}
+	}
+
+	for _, change := range plan.ModelChanges() {
+		if err := applyPlannedChange(ctx, client, plan, change); err != nil {
+			return fmt.Errorf("failed to apply %s: %w", change.Details, err)
+		}
+	}
+	return nil
+}
+
+// applyPlannedChange runs the operations listed for a change in its ChangeSummary
+func applyPlannedChange(ctx context.Context, client *Client, plan *ExecutionPlan, change ModelChange) error {
+	if plan.ModelOnly {
+		switch change.Type {
+		case ChangeTypeRemoveRelation:
+			return RemoveRelationFromType(ctx, client, change.TypeName, change.RelationName)
+		case ChangeTypeRemoveType:
+			return RemoveTypeFromModel(ctx, client, change.TypeName)
+		}
+	}
+
+	switch change.Type {
+	case ChangeTypeAddCondition, ChangeTypeUpdateCondition:
+		return SetCondition(ctx, client, change.NewValue)
+
+	case ChangeTypeRemoveCondition:
+		return RemoveCondition(ctx, client, change.TypeName)
+
+	case ChangeTypeAddType:
+		return AddTypeToModel(ctx, client, change.TypeName, nil)
+
+	case ChangeTypeAddRelation:
+		return AddRelationToType(ctx, client, change.TypeName, change.RelationName, RelationDefinitionDSL(change.NewValue))
+
+	case ChangeTypeUpdateRelation, ChangeTypeUpdateTypeRestrictions:
+		return UpdateRelationDefinition(ctx, client, change.TypeName, change.RelationName, RelationDefinitionDSL(change.NewValue))
+
+	case ChangeTypeRenameRelation:
+		if change.Confidence != ConfidenceLow {
+			return RenameRelation(ctx, client, change.TypeName, change.OldValue, change.NewValue)
+		}
+		// Low confidence renames are applied as separate relations, like generated migrations
+		if _, err := BackupForRemoval(ctx, client, plan.ID, change.TypeName, change.OldValue); err != nil {
+			return err
+		}
+		if err := DeleteRelation(ctx, client, change.TypeName, change.OldValue); err != nil {
+			return err
+		}
+		return RemoveRelationFromType(ctx, client, change.TypeName, change.OldValue)
+
+	case ChangeTypeMoveRelation:
+		if err := AddRelationToType(ctx, client, change.TargetType, change.RelationName, RelationDefinitionDSL(change.NewValue)); err != nil {
+			return err
+		}
+		if err := MoveRelation(ctx, client, change.TypeName, change.TargetType, change.RelationName); err != nil {
+			return err
+		}
+		return RemoveRelationFromType(ctx, client, change.TypeName, change.RelationName)
+
+	case ChangeTypeRemoveRelation:
+		if _, err := BackupForRemoval(ctx, client, plan.ID, change.TypeName, change.RelationName); err != nil {
+			return err
+		}
+		if err := RemoveRelationFromType(ctx, client, change.TypeName, change.RelationName); err != nil {
+			return err
+		}
+		return DeleteRelation(ctx, client, change.TypeName, change.RelationName)
+
+	case ChangeTypeRenameType:
+		if change.Confidence != ConfidenceLow {
+			return RenameType(ctx, client, change.OldValue, change.NewValue)
+		}
+		if _, err := BackupForRemoval(ctx, client, plan.ID, change.OldValue, ""); err != nil {
+			return err
+		}
+		if err := deleteTypeTuples(ctx, client, change.OldValue); err != nil {
+			return err
+		}
+		return RemoveTypeFromModel(ctx, client, change.OldValue)
+
+	case ChangeTypeRemoveType:
+		if _, err := BackupForRemoval(ctx, client, plan.ID, change.TypeName, ""); err != nil {
+			return err
+		}
+		if err := deleteTypeTuples(ctx, client, change.TypeName); err != nil {
+			return err
+		}
+		return RemoveTypeFromModel(ctx, client, change.TypeName)
+	}
+	return fmt.Errorf("unknown change type '%s'", change.Type)
+}
+
+// deleteTypeTuples deletes every tuple whose object is of the given type
+func deleteTypeTuples(ctx context.Context, client *Client, objectType string) error {
+	tuples, err := ReadAllTuples(ctx, client, objectType, "")
+	if err != nil {
+		return fmt.Errorf("failed to read tuples: %w", err)
+	}
+	if len(tuples) == 0 {
+		return nil
+	}
+	fmt.Printf("Deleting %d tuples of type %s\n", len(tuples), objectType)
+	return DeleteTuplesBatch(ctx, client, tuples)
+}
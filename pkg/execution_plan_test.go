@@ -0,0 +1,70 @@
+package omg_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/demetere/omg/internal/testhelpers"
+	"github.com/demetere/omg/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecutionPlan_RoundTrip(t *testing.T) {
+	source := omg.ModelLock{ModelID: "01HVMMBCMGZNT3SED4Z17ECXCA", ModelHash: "sha256:abc"}
+	changes := []omg.ModelChange{
+		{Type: omg.ChangeTypeRemoveRelation, TypeName: "document", RelationName: "legacy", OldValue: "[user]", Details: "Removed relation 'document.legacy'"},
+		{Type: omg.ChangeTypeAddRelation, TypeName: "document", RelationName: "editor", NewValue: "[user]", Details: "Added relation 'document.editor'"},
+	}
+
+	plan := omg.NewExecutionPlan("store-1", source, nil, changes)
+	assert.Equal(t, omg.ExecutionPlanFormat, plan.Format)
+	assert.Equal(t, "sha256:abc", plan.SourceModelHash)
+	require.Len(t, plan.Changes, 2)
+	assert.Equal(t, omg.ChangeTypeAddRelation, plan.Changes[0].Type, "changes are stored in apply order")
+	assert.Equal(t, []string{"BackupForRemoval", "RemoveRelationFromType", "DeleteRelation"}, plan.Changes[1].Operations)
+
+	data, err := plan.JSON()
+	require.NoError(t, err)
+	parsed, err := omg.ParseExecutionPlan(data)
+	require.NoError(t, err)
+	assert.Equal(t, plan.ID, parsed.ID)
+	assert.Equal(t, "editor", parsed.ModelChanges()[0].RelationName)
+	assert.Equal(t, "[user]", parsed.ModelChanges()[1].OldValue)
+}
+
+func TestParseExecutionPlan_Invalid(t *testing.T) {
+	_, err := omg.ParseExecutionPlan([]byte(`{"format": 99, "source_model_hash": "sha256:abc"}`))
+	assert.ErrorContains(t, err, "unsupported plan format")
+
+	_, err = omg.ParseExecutionPlan([]byte(`{"format": 1}`))
+	assert.ErrorContains(t, err, "source_model_hash")
+}
+
+func TestApplyExecutionPlan(t *testing.T) {
+	ctx := context.Background()
+
+	container, client := testhelpers.SetupOpenFGAContainer(t, ctx, `
+type user
+type document
+  relations
+    define viewer: [user]
+`)
+	defer container.Terminate(ctx)
+
+	source, err := omg.LoadModelLockFromOpenFGA(ctx, client)
+	require.NoError(t, err)
+	plan := omg.NewExecutionPlan(client.GetStoreID(), source, nil, []omg.ModelChange{
+		{Type: omg.ChangeTypeAddRelation, TypeName: "document", RelationName: "editor", NewValue: "[user]", Details: "Added relation 'document.editor'"},
+	})
+
+	require.NoError(t, omg.ApplyExecutionPlan(ctx, client, plan))
+
+	state, err := omg.LoadModelStateFromOpenFGA(ctx, client)
+	require.NoError(t, err)
+	assert.Contains(t, state.Types["document"].Relations, "editor")
+
+	// The model has moved on, so applying the same plan again is refused
+	err = omg.ApplyExecutionPlan(ctx, client, plan)
+	assert.ErrorIs(t, err, omg.ErrStalePlan)
+}
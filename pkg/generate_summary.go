@@ -0,0 +1,161 @@
+package omg
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// GenerateSummary describes a generated migration for tooling, e.g. a bot posting a PR comment
+type GenerateSummary struct {
+	File     string          `json:"file"`
+	TestFile string          `json:"test_file,omitempty"`
+	Version  string          `json:"version"`
+	Name     string          `json:"name"`
+	Changes  []ChangeSummary `json:"changes"`
+}
+
+// ChangeSummary describes one detected change and the operations generated for it
+type ChangeSummary struct {
+	Type       ChangeType      `json:"type"`
+	TypeName   string          `json:"type_name"`
+	Relation   string          `json:"relation,omitempty"`
+	TargetType string          `json:"target_type,omitempty"`
+	OldValue   string          `json:"old_value,omitempty"`
+	NewValue   string          `json:"new_value,omitempty"`
+	Confidence ConfidenceLevel `json:"confidence,omitempty"`
+	Details    string          `json:"details"`
+	Operations []string        `json:"operations"`
+	Review     bool            `json:"review_required"`
+}
+
+// BuildGenerateSummary summarizes a migration generated from changes
+// Changes are listed in the order their operations run in up
+func BuildGenerateSummary(filename string, changes []ModelChange, opts GenerateOptions) GenerateSummary {
+	base := strings.TrimSuffix(filepath.Base(filename), ".go")
+	version, name, _ := strings.Cut(base, "_")
+
+	summary := GenerateSummary{
+		File:    filename,
+		Version: version,
+		Name:    name,
+		Changes: make([]ChangeSummary, 0, len(changes)),
+	}
+	if opts.WithTests {
+		summary.TestFile = strings.TrimSuffix(filename, ".go") + "_test.go"
+	}
+
+	for _, change := range orderChangesForUp(changes) {
+		summary.Changes = append(summary.Changes, ChangeSummary{
+			Type:       change.Type,
+			TypeName:   change.TypeName,
+			Relation:   change.RelationName,
+			TargetType: change.TargetType,
+			OldValue:   change.OldValue,
+			NewValue:   change.NewValue,
+			Confidence: change.Confidence,
+			Details:    change.Details,
+			Operations: changeOperations(change, opts),
+			Review:     change.Confidence == ConfidenceMedium || change.Confidence == ConfidenceLow,
+		})
+	}
+
+	return summary
+}
+
+// WriteGenerateSummary writes a summary as indented JSON to path, or to stdout when path is "-"
+func WriteGenerateSummary(path string, summary GenerateSummary) error {
+	return writeSummaryJSON(path, summary)
+}
+
+// WriteGenerateSummaries writes the summaries of a split migration as a JSON array
+func WriteGenerateSummaries(path string, summaries []GenerateSummary) error {
+	return writeSummaryJSON(path, summaries)
+}
+
+// writeSummaryJSON writes v as indented JSON to path, or to stdout when path is "-"
+func writeSummaryJSON(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode summary: %w", err)
+	}
+	data = append(data, '\n')
+
+	if path == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write summary: %w", err)
+	}
+	return nil
+}
+
+// changeOperations lists the helpers the generated up migration calls for a change
+// It mirrors generateUpMigration; commented-out alternatives are not included
+func changeOperations(change ModelChange, opts GenerateOptions) []string {
+	operations := baseChangeOperations(change, opts)
+	if opts.Idempotent {
+		for i, operation := range operations {
+			if variant, ok := idempotentOperations[operation]; ok {
+				operations[i] = variant
+			}
+		}
+	}
+	return operations
+}
+
+// baseChangeOperations lists the helpers called for a change, before swapping in the idempotent variants
+func baseChangeOperations(change ModelChange, opts GenerateOptions) []string {
+	if opts.Strategy == StrategyModelApply {
+		return modelApplyOperations(change, opts)
+	}
+	if opts.ModelOnly {
+		switch change.Type {
+		case ChangeTypeUpdateRelation, ChangeTypeUpdateTypeRestrictions:
+			return []string{"UpdateRelationDefinition"}
+		case ChangeTypeRemoveRelation:
+			return []string{"RemoveRelationFromType"}
+		case ChangeTypeRemoveType:
+			return []string{"RemoveTypeFromModel"}
+		}
+	}
+
+	switch change.Type {
+	case ChangeTypeAddCondition, ChangeTypeUpdateCondition:
+		return []string{"SetCondition"}
+	case ChangeTypeRemoveCondition:
+		return []string{"RemoveCondition"}
+	case ChangeTypeAddType:
+		return []string{"AddTypeToModel"}
+	case ChangeTypeAddRelation:
+		return []string{"AddRelationToType"}
+	case ChangeTypeUpdateRelation:
+		if opts.Backfill && len(addedComputedRelations(change.OldValue, change.NewValue)) > 0 {
+			return []string{"UpdateRelationDefinition", "ReadAllTuples (backfill analysis)"}
+		}
+		return []string{"UpdateRelationDefinition"}
+	case ChangeTypeUpdateTypeRestrictions:
+		return []string{"UpdateRelationDefinition"}
+	case ChangeTypeRenameRelation:
+		if change.Confidence == ConfidenceLow {
+			return []string{"BackupForRemoval", "ReadAllTuples", "DeleteTuplesBatch", "RemoveRelationFromType"}
+		}
+		return []string{"RenameRelation"}
+	case ChangeTypeMoveRelation:
+		return []string{"AddRelationToType", "MoveRelation", "RemoveRelationFromType"}
+	case ChangeTypeRemoveRelation:
+		return []string{"BackupForRemoval", "RemoveRelationFromType", "DeleteRelation"}
+	case ChangeTypeRenameType:
+		if change.Confidence == ConfidenceLow {
+			return []string{"BackupForRemoval", "ReadAllTuples", "DeleteTuplesBatch", "RemoveTypeFromModel"}
+		}
+		return []string{"RenameType"}
+	case ChangeTypeRemoveType:
+		return []string{"BackupForRemoval", "ReadAllTuples", "DeleteTuplesBatch", "RemoveTypeFromModel"}
+	}
+	return []string{}
+}
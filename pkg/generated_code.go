@@ -0,0 +1,51 @@
+package omg
+
+import (
+	"errors"
+	"fmt"
+	"go/format"
+	"go/scanner"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// formatGeneratedCode gofmts generated Go code. Code that does not parse, e.g. because a
+// definition could not be quoted, is an error naming the offending line of name
+func formatGeneratedCode(name, code string) (string, error) {
+	formatted, err := format.Source([]byte(code))
+	if err == nil {
+		return string(formatted), nil
+	}
+
+	var list scanner.ErrorList
+	if errors.As(err, &list) && len(list) > 0 {
+		lines := strings.Split(code, "\n")
+		if line := list[0].Pos.Line; line > 0 && line <= len(lines) {
+			return "", fmt.Errorf("generated %s is not valid Go: %w\n  line %d: %s",
+				filepath.Base(name), err, line, strings.TrimSpace(lines[line-1]))
+		}
+	}
+	return "", fmt.Errorf("generated %s is not valid Go: %w", filepath.Base(name), err)
+}
+
+// vetMigration type-checks written migration files with 'go vet', run in their directory
+// so the Go module holding them resolves the imports. Registered migrations share their
+// package, so the whole package is vetted
+func vetMigration(files []string, pkg string) error {
+	args := []string{"vet"}
+	if pkg != "" {
+		args = append(args, ".")
+	} else {
+		for _, file := range files {
+			args = append(args, filepath.Base(file))
+		}
+	}
+
+	cmd := exec.Command("go", args...)
+	cmd.Dir = filepath.Dir(files[0])
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("generated %s does not compile: %w\n%s", filepath.Base(files[0]), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package omg_test
+
+import (
+	"go/format"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/demetere/omg/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateMigration_Gofmt(t *testing.T) {
+	dir := t.TempDir()
+	changes := []omg.ModelChange{
+		{Type: omg.ChangeTypeAddType, TypeName: "folder", Details: "New type 'folder'"},
+		{Type: omg.ChangeTypeAddRelation, TypeName: "folder", RelationName: "owner", NewValue: "[user]", Details: "Add relation 'folder.owner'"},
+		{Type: omg.ChangeTypeRemoveRelation, TypeName: "document", RelationName: "legacy", OldValue: "[user]", Details: "Removed relation 'document.legacy'"},
+	}
+
+	filename, err := omg.GenerateMigrationFromChangesWithOptions(changes, "gofmt", dir, omg.GenerateOptions{WithTests: true})
+	require.NoError(t, err)
+
+	for _, file := range []string{filename, filename[:len(filename)-len(".go")] + "_test.go"} {
+		content, err := os.ReadFile(file)
+		require.NoError(t, err)
+		formatted, err := format.Source(content)
+		require.NoError(t, err)
+		assert.Equal(t, string(formatted), string(content), "%s is not gofmt'd", file)
+	}
+}
+
+func TestGenerateMigration_InvalidCode(t *testing.T) {
+	dir := t.TempDir()
+	changes := []omg.ModelChange{
+		{Type: omg.ChangeTypeAddType, TypeName: "folder", Details: "New type\nwith a line break"},
+	}
+
+	_, err := omg.GenerateMigrationFromChanges(changes, "broken", dir)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "is not valid Go")
+	assert.Contains(t, err.Error(), "with a line break")
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestGenerateMigration_Verify(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not available")
+	}
+
+	// The migration imports this module, so it must be vetted from inside it.
+	// A leading underscore keeps the directory out of ./... patterns
+	dir, err := os.MkdirTemp(".", "_verify")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	changes := []omg.ModelChange{
+		{Type: omg.ChangeTypeAddRelation, TypeName: "document", RelationName: "editor", NewValue: "[user]", Details: "Add relation 'document.editor'"},
+	}
+	_, err = omg.GenerateMigrationFromChangesWithOptions(changes, "valid", dir, omg.GenerateOptions{Verify: true})
+	require.NoError(t, err)
+
+	// Parses, but does not type-check
+	templatePath := filepath.Join(t.TempDir(), "migration.go.tmpl")
+	require.NoError(t, os.WriteFile(templatePath, []byte(`package main
+
+import (
+{{range .Imports}}	{{.}}
+{{end}})
+
+func main() {}
+
+func up(ctx context.Context, client *omg.Client) error {
+	return omg.NoSuchHelper(ctx, client)
+}
+`), 0644))
+
+	_, err = omg.GenerateMigrationFromChangesWithOptions(changes, "invalid", dir, omg.GenerateOptions{Verify: true, Template: templatePath})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "does not compile")
+	assert.Contains(t, err.Error(), "NoSuchHelper")
+
+	// Only the valid migration is kept
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
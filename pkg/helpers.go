@@ -2,8 +2,14 @@ package omg
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	openfgaSdk "github.com/openfga/go-sdk"
 )
@@ -21,6 +27,7 @@ func GetCurrentModel(ctx context.Context, client *Client) (string, error) {
 }
 
 // ApplyModelFromDSL parses and applies an authorization model from DSL string
+// The tuple tracker's types are kept when the live model has them and the DSL does not
 // Example DSL:
 //
 //	model
@@ -39,6 +46,12 @@ func ApplyModelFromDSL(ctx context.Context, client *Client, dsl string) error {
 		return fmt.Errorf("failed to parse DSL: %w", err)
 	}
 
+	unlock := client.lockModel()
+	defer unlock()
+
+	if err := keepTrackingTypes(ctx, client, &model); err != nil {
+		return err
+	}
 	if err := client.WriteAuthorizationModel(ctx, model); err != nil {
 		return fmt.Errorf("failed to write model: %w", err)
 	}
@@ -87,89 +100,414 @@ func CompareModels(ctx context.Context, client *Client, newDSL string) (string,
 // RenameRelation renames a relation on all tuples of a specific object type
 // Example: RenameRelation(ctx, client, "team", "can_manage_members", "can_manage")
 func RenameRelation(ctx context.Context, client *Client, objectType, oldRelation, newRelation string) error {
+	return RenameRelationWithOptions(ctx, client, objectType, oldRelation, newRelation, WriteOptions{})
+}
+
+// RenameRelationWithOptions renames a relation, writing the renamed tuples with opts
+// SkipExisting makes it safe to re-run after an interrupted rename
+func RenameRelationWithOptions(ctx context.Context, client *Client, objectType, oldRelation, newRelation string, opts WriteOptions) error {
 	fmt.Printf("Renaming relation %s -> %s on type %s\n", oldRelation, newRelation, objectType)
 
-	// Read all tuples with old relation
-	tuples, err := ReadAllTuples(ctx, client, objectType, oldRelation)
+	moved, err := renameTuples(ctx, client, "rename_relation "+objectType+" "+oldRelation+" "+newRelation, opts)
 	if err != nil {
-		return fmt.Errorf("failed to read tuples: %w", err)
+		return err
 	}
-
-	if len(tuples) == 0 {
+	if moved == 0 {
 		fmt.Println("No tuples found to rename")
 		return nil
 	}
 
-	fmt.Printf("Found %d tuples to rename\n", len(tuples))
+	fmt.Printf("Relation rename completed (%d tuples)\n", moved)
+	return nil
+}
 
-	// Create new tuples with new relation
-	var newTuples []Tuple
-	for _, t := range tuples {
-		newTuples = append(newTuples, Tuple{
-			User:     t.User,
-			Relation: newRelation,
-			Object:   t.Object,
-		})
+// RenameType renames an object type on all tuples
+// Example: RenameType(ctx, client, "team", "organization")
+func RenameType(ctx context.Context, client *Client, oldType, newType string) error {
+	return RenameTypeWithOptions(ctx, client, oldType, newType, WriteOptions{})
+}
+
+// RenameTypeWithOptions renames an object type, writing the renamed tuples with opts
+// SkipExisting makes it safe to re-run after an interrupted rename; RenameUserRefs renames
+// the type on the user side of tuples too
+func RenameTypeWithOptions(ctx context.Context, client *Client, oldType, newType string, opts WriteOptions) error {
+	fmt.Printf("Renaming type %s -> %s\n", oldType, newType)
+
+	moved, err := renameTuples(ctx, client, "rename_type "+oldType+" "+newType, opts)
+	if err != nil {
+		return err
+	}
+	if opts.RenameUserRefs {
+		users, err := renameTuples(ctx, client, "rename_user_type "+oldType+" "+newType, opts)
+		if err != nil {
+			return err
+		}
+		moved += users
+	}
+	if moved == 0 {
+		fmt.Println("No tuples found to rename")
+		return nil
 	}
 
-	// Write new tuples
-	if err := WriteTuplesBatch(ctx, client, newTuples); err != nil {
-		return fmt.Errorf("failed to write new tuples: %w", err)
+	fmt.Printf("Type rename completed (%d tuples)\n", moved)
+	return nil
+}
+
+// RenameUserType renames a type where it appears as the user of tuples, in any of their
+// forms: team:eng, team:eng#member (a userset) and team:* (a wildcard). RenameType only
+// renames objects; the model must already allow the new type wherever the old one was
+// Example: RenameUserType(ctx, client, "team", "organization") turns
+// document:readme#viewer@team:eng#member into document:readme#viewer@organization:eng#member
+func RenameUserType(ctx context.Context, client *Client, oldType, newType string) error {
+	return RenameUserTypeWithOptions(ctx, client, oldType, newType, WriteOptions{})
+}
+
+// RenameUserTypeWithOptions renames a user type, writing the renamed tuples with opts
+// User references can appear in tuples of any type, so every tuple of the store is read
+func RenameUserTypeWithOptions(ctx context.Context, client *Client, oldType, newType string, opts WriteOptions) error {
+	fmt.Printf("Renaming user type %s -> %s\n", oldType, newType)
+
+	moved, err := renameTuples(ctx, client, "rename_user_type "+oldType+" "+newType, opts)
+	if err != nil {
+		return err
+	}
+	if moved == 0 {
+		fmt.Println("No tuples found to rename")
+		return nil
 	}
 
-	// Delete old tuples
-	if err := DeleteTuplesBatch(ctx, client, tuples); err != nil {
-		return fmt.Errorf("failed to delete old tuples: %w", err)
+	fmt.Printf("User type rename completed (%d tuples)\n", moved)
+	return nil
+}
+
+// MoveRelation moves a relation's tuples to another object type, keeping object IDs
+// Example: MoveRelation(ctx, client, "team", "organization", "can_invite") turns
+// team:eng#can_invite tuples into organization:eng#can_invite
+func MoveRelation(ctx context.Context, client *Client, fromType, toType, relation string) error {
+	return MoveRelationWithOptions(ctx, client, fromType, toType, relation, WriteOptions{})
+}
+
+// MoveRelationWithOptions moves a relation's tuples, writing the moved tuples with opts
+// SkipExisting makes it safe to re-run after an interrupted move
+func MoveRelationWithOptions(ctx context.Context, client *Client, fromType, toType, relation string, opts WriteOptions) error {
+	fmt.Printf("Moving relation %s from type %s to %s\n", relation, fromType, toType)
+
+	moved, err := renameTuples(ctx, client, "move_relation "+fromType+" "+toType+" "+relation, opts)
+	if err != nil {
+		return err
+	}
+	if moved == 0 {
+		fmt.Println("No tuples found to move")
+		return nil
 	}
 
-	fmt.Println("Relation rename completed")
+	fmt.Printf("Relation move completed (%d tuples)\n", moved)
 	return nil
 }
 
-// RenameType renames an object type on all tuples
-// Example: RenameType(ctx, client, "team", "organization")
-func RenameType(ctx context.Context, client *Client, oldType, newType string) error {
-	fmt.Printf("Renaming type %s -> %s\n", oldType, newType)
+// moveChunkSize is how many tuples moveTuples replaces at a time, at least
+const moveChunkSize = 1000
 
-	// Read all tuples for old type
-	tuples, err := ReadAllTuples(ctx, client, oldType, "")
+// renameOperation is what a rename, type rename or relation move reads and writes
+type renameOperation struct {
+	source  ReadTuplesRequest // The tuples to replace
+	target  ReadTuplesRequest // Where the replacements go
+	replace func(Tuple) Tuple
+
+	// sourceUserType and targetUserType, when set, narrow source and target to tuples
+	// whose user has the type, as reads cannot filter on it
+	sourceUserType string
+	targetUserType string
+
+	// inPlace is set when the replacements land in source itself, as for ID prefixes: the
+	// tuples replace leaves unchanged, including those already replaced, are skipped
+	inPlace bool
+}
+
+// inSource reports whether t, read from source, is a tuple the operation replaces
+func (r renameOperation) inSource(t Tuple) bool {
+	if r.inPlace && r.replace(t) == t {
+		return false
+	}
+	return r.sourceUserType == "" || strings.HasPrefix(t.User, r.sourceUserType+":")
+}
+
+// inTarget reports whether t, read from target, is where a replacement would go
+func (r renameOperation) inTarget(t Tuple) bool {
+	return r.targetUserType == "" || strings.HasPrefix(t.User, r.targetUserType+":")
+}
+
+// sourceTuples returns the tuples of a page read from source that the operation replaces
+func (r renameOperation) sourceTuples(page []Tuple) []Tuple {
+	if r.sourceUserType == "" && !r.inPlace {
+		return page
+	}
+	var tuples []Tuple
+	for _, t := range page {
+		if r.inSource(t) {
+			tuples = append(tuples, t)
+		}
+	}
+	return tuples
+}
+
+// parseRenameOperation reads an operation such as "rename_type team organization"; the
+// operations double as checkpoint and rename journal keys
+func parseRenameOperation(operation string) (renameOperation, error) {
+	fields := strings.Fields(operation)
+	switch {
+	case len(fields) == 4 && fields[0] == "rename_relation":
+		objectType, oldRelation, newRelation := fields[1], fields[2], fields[3]
+		return renameOperation{
+			source: ReadTuplesRequest{Object: objectType + ":", Relation: oldRelation},
+			target: ReadTuplesRequest{Object: objectType + ":", Relation: newRelation},
+			replace: func(t Tuple) Tuple {
+				return Tuple{User: t.User, Relation: newRelation, Object: t.Object}
+			},
+		}, nil
+	case len(fields) == 3 && fields[0] == "rename_type":
+		oldType, newType := fields[1], fields[2]
+		return renameOperation{
+			source: ReadTuplesRequest{Object: oldType + ":"},
+			target: ReadTuplesRequest{Object: newType + ":"},
+			replace: func(t Tuple) Tuple {
+				// Replace type in object: "team:123" -> "organization:123"
+				return Tuple{User: t.User, Relation: t.Relation, Object: newType + ":" + strings.TrimPrefix(t.Object, oldType+":")}
+			},
+		}, nil
+	case len(fields) == 3 && fields[0] == "rename_user_type":
+		oldType, newType := fields[1], fields[2]
+		return renameOperation{
+			sourceUserType: oldType,
+			targetUserType: newType,
+			replace: func(t Tuple) Tuple {
+				// "team:eng#member" -> "organization:eng#member", "team:*" -> "organization:*"
+				return Tuple{User: newType + ":" + strings.TrimPrefix(t.User, oldType+":"), Relation: t.Relation, Object: t.Object}
+			},
+		}, nil
+	case len(fields) == 4 && fields[0] == "move_relation":
+		fromType, toType, relation := fields[1], fields[2], fields[3]
+		return renameOperation{
+			source: ReadTuplesRequest{Object: fromType + ":", Relation: relation},
+			target: ReadTuplesRequest{Object: toType + ":", Relation: relation},
+			replace: func(t Tuple) Tuple {
+				// "team:123" -> "organization:123"
+				return Tuple{User: t.User, Relation: t.Relation, Object: toType + ":" + strings.TrimPrefix(t.Object, fromType+":")}
+			},
+		}, nil
+	case len(fields) == 3 && (fields[0] == "prefix_ids" || fields[0] == "unprefix_ids"):
+		objectType, prefix := fields[1], fields[2]
+		rewrite := func(id string) string {
+			if strings.HasPrefix(id, prefix) {
+				return id
+			}
+			return prefix + id
+		}
+		if fields[0] == "unprefix_ids" {
+			rewrite = func(id string) string {
+				return strings.TrimPrefix(id, prefix)
+			}
+		}
+		return renameOperation{
+			source: ReadTuplesRequest{Object: objectType + ":"},
+			target: ReadTuplesRequest{Object: objectType + ":"},
+			replace: func(t Tuple) Tuple {
+				// "team:eng" -> "team:acme/eng", as are users of the type ("team:eng#member")
+				return Tuple{
+					User:     rewriteObjectRef(t.User, objectType, rewrite),
+					Relation: t.Relation,
+					Object:   rewriteObjectRef(t.Object, objectType, rewrite),
+				}
+			},
+			inPlace: true,
+		}, nil
+	}
+	return renameOperation{}, fmt.Errorf("unknown rename operation '%s'", operation)
+}
+
+// renameTuples runs a rename operation: verified and swapped (see swapTuples) when
+// opts.VerifyAndSwap or the context asks for it, streamed with checkpoints otherwise
+func renameTuples(ctx context.Context, client *Client, operation string, opts WriteOptions) (int, error) {
+	if verifiedRenames(ctx, opts) {
+		return swapTuples(ctx, client, operation, opts)
+	}
+	return moveTuples(ctx, client, operation, opts)
+}
+
+// moveTuples streams the tuples a rename operation replaces, writing each one's
+// replacement and then deleting the originals, a chunk of whole pages at a time, so
+// stores of any size are renamed without holding them in memory. Returns how many tuples
+// were moved
+// After each chunk, a checkpoint of the operation records where the next one starts; a run
+// with WithResume (or OMG_RESUME=true) continues from there, skipping replacements that
+// were written before the interruption
+// With opts.SkipExisting, each chunk's replacements that are already stored are skipped,
+// reading them by object and relation. User type renames write idempotently instead
+// Progress is reported per chunk, as operation; the batches of a chunk report only to
+// opts.Progress
+func moveTuples(ctx context.Context, client *Client, operation string, opts WriteOptions) (int, error) {
+	rename, err := parseRenameOperation(operation)
 	if err != nil {
-		return fmt.Errorf("failed to read tuples: %w", err)
+		return 0, err
 	}
 
-	if len(tuples) == 0 {
-		fmt.Println("No tuples found to rename")
-		return nil
+	skipExisting := opts.SkipExisting
+	opts.SkipExisting = false
+	if rename.sourceUserType != "" {
+		opts.Idempotent = opts.Idempotent || skipExisting
+		skipExisting = false
 	}
 
-	fmt.Printf("Found %d tuples to rename\n", len(tuples))
+	moved, from := 0, ""
+	if resuming(ctx) {
+		checkpoint, err := LoadCheckpoint(client.GetStoreID(), operation)
+		if err != nil {
+			return 0, err
+		}
+		if checkpoint != nil {
+			fmt.Printf("Resuming %s after %d tuples (checkpoint of %s)\n", operation, checkpoint.Processed, checkpoint.UpdatedAt.Format(time.RFC3339))
+			moved, from = checkpoint.Processed, checkpoint.ContinuationToken
+			// The interrupted chunk may have been written but not deleted
+			if rename.sourceUserType != "" {
+				opts.Idempotent = true
+			} else {
+				skipExisting = true
+			}
+		}
+	}
+	resumedFrom := moved
+	progress := startProgress(ctx, operation, 0, nil)
+	batchCtx := withoutProgressReporter(ctx)
+
+	chunk := make([]Tuple, 0, moveChunkSize)
+	flush := func() error {
+		if len(chunk) == 0 {
+			return nil
+		}
+		replacements := make([]Tuple, 0, len(chunk))
+		for _, t := range chunk {
+			replacements = append(replacements, rename.replace(t))
+		}
+		if skipExisting {
+			stored, err := readStoredTuples(ctx, client, replacements)
+			if err != nil {
+				return err
+			}
+			missing := replacements[:0]
+			for _, t := range replacements {
+				if !stored[t] {
+					missing = append(missing, t)
+				}
+			}
+			if skipped := len(replacements) - len(missing); skipped > 0 {
+				fmt.Printf("Skipping %d tuples that already exist\n", skipped)
+			}
+			replacements = missing
+		}
 
-	// Create new tuples with new type
-	var newTuples []Tuple
-	for _, t := range tuples {
-		// Replace type in object: "team:123" -> "organization:123"
-		newObject := strings.Replace(t.Object, oldType+":", newType+":", 1)
-		newTuples = append(newTuples, Tuple{
-			User:     t.User,
-			Relation: t.Relation,
-			Object:   newObject,
-		})
+		if err := WriteTuplesBatchWithOptions(batchCtx, client, replacements, opts); err != nil {
+			return fmt.Errorf("failed to write new tuples: %w", err)
+		}
+		deleteOpts := DeleteOptions{BatchSize: opts.BatchSize, Workers: opts.Workers}
+		if err := DeleteTuplesBatchWithOptions(batchCtx, client, chunk, deleteOpts); err != nil {
+			return fmt.Errorf("failed to delete old tuples: %w", err)
+		}
+		moved += len(chunk)
+		chunk = chunk[:0]
+		progress.update(moved - resumedFrom)
+		return nil
 	}
 
-	// Write new tuples
-	if err := WriteTuplesBatch(ctx, client, newTuples); err != nil {
-		return fmt.Errorf("failed to write new tuples: %w", err)
+	req := rename.source
+	movePage := func(page []Tuple, next string) error {
+		chunk = append(chunk, rename.sourceTuples(page)...)
+		if len(chunk) < moveChunkSize || next == "" {
+			return nil
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+		return SaveCheckpoint(Checkpoint{Operation: operation, StoreID: client.GetStoreID(), ContinuationToken: next, Processed: moved})
+	}
+	err = client.iteratePages(ctx, req, from, movePage)
+	var validationErr openfgaSdk.FgaApiValidationError
+	if err != nil && from != "" && moved == resumedFrom && errors.As(err, &validationErr) {
+		fmt.Println("The server rejected the checkpoint; starting over, skipping tuples that already exist")
+		err = client.iteratePages(ctx, req, "", movePage)
+	}
+	if err == nil {
+		err = flush()
+	}
+	if moved > resumedFrom || err != nil {
+		progress.finish(moved-resumedFrom, err)
 	}
+	if err != nil {
+		if moved > 0 {
+			fmt.Printf("Progress is saved in %s: run again with -resume (OMG_RESUME=true) to continue\n", CheckpointPath(client.GetStoreID(), operation))
+		}
+		return moved, fmt.Errorf("failed to move tuples after %d: %w", moved, err)
+	}
+	return moved, ClearCheckpoint(client.GetStoreID(), operation)
+}
 
-	// Delete old tuples
-	if err := DeleteTuplesBatch(ctx, client, tuples); err != nil {
-		return fmt.Errorf("failed to delete old tuples: %w", err)
+// PrefixObjectIDs prefixes the IDs of every object of a type
+// The type's tuples are streamed and rewritten with checkpoints, as in RenameType, and user
+// references to the type within them ("team:eng#member" on a team) are rewritten too;
+// references from other types' tuples are left alone. Wildcards ("team:*") and IDs that
+// already carry the prefix are left alone
+// Example: PrefixObjectIDs(ctx, client, "team", "acme/") turns team:eng into team:acme/eng
+func PrefixObjectIDs(ctx context.Context, client *Client, objectType, prefix string) error {
+	fmt.Printf("Prefixing %s IDs with %q\n", objectType, prefix)
+	return rewriteObjectIDs(ctx, client, "prefix_ids", objectType, prefix)
+}
+
+// UnprefixObjectIDs reverses PrefixObjectIDs, removing prefix from the IDs of a type's objects
+// IDs without the prefix are left alone. As rewritten tuples can be read again, an ID that
+// carries the prefix more than once may lose it more than once
+// Example: UnprefixObjectIDs(ctx, client, "team", "acme/") turns team:acme/eng into team:eng
+func UnprefixObjectIDs(ctx context.Context, client *Client, objectType, prefix string) error {
+	fmt.Printf("Removing prefix %q from %s IDs\n", prefix, objectType)
+	return rewriteObjectIDs(ctx, client, "unprefix_ids", objectType, prefix)
+}
+
+// rewriteObjectIDs runs a prefix_ids or unprefix_ids operation through moveTuples
+func rewriteObjectIDs(ctx context.Context, client *Client, kind, objectType, prefix string) error {
+	if prefix == "" || strings.ContainsAny(prefix, " \t\n") {
+		return fmt.Errorf("invalid ID prefix %q", prefix)
+	}
+
+	rewritten, err := moveTuples(ctx, client, kind+" "+objectType+" "+prefix, WriteOptions{})
+	if err != nil {
+		return err
+	}
+	if rewritten == 0 {
+		fmt.Println("No tuples found to rewrite")
+		return nil
 	}
 
-	fmt.Println("Type rename completed")
+	fmt.Printf("Object ID rewrite completed (%d tuples)\n", rewritten)
 	return nil
 }
 
+// rewriteObjectRef rewrites the ID in "type:id" or "type:id#relation" when type matches
+func rewriteObjectRef(ref, objectType string, rewrite func(id string) string) string {
+	refType, rest, found := strings.Cut(ref, ":")
+	if !found || refType != objectType {
+		return ref
+	}
+
+	id, relation, hasRelation := strings.Cut(rest, "#")
+	if id == "*" {
+		return ref
+	}
+
+	ref = objectType + ":" + rewrite(id)
+	if hasRelation {
+		ref += "#" + relation
+	}
+	return ref
+}
+
 // CopyRelation copies tuples from one relation to another
 // Example: CopyRelation(ctx, client, "team", "can_manage_members", "can_manage")
 func CopyRelation(ctx context.Context, client *Client, objectType, sourceRelation, targetRelation string) error {
@@ -268,8 +606,8 @@ func MigrateRelationWithTransform(ctx context.Context, client *Client, objectTyp
 		newTuples = append(newTuples, transformed)
 	}
 
-	// Write new tuples
-	if err := WriteTuplesBatch(ctx, client, newTuples); err != nil {
+	// Write new tuples; a transform may map several old tuples onto the same new one
+	if err := WriteTuplesBatchWithOptions(ctx, client, newTuples, WriteOptions{Deduplicate: true}); err != nil {
 		return fmt.Errorf("failed to write new tuples: %w", err)
 	}
 
@@ -315,57 +653,458 @@ func CountTuples(ctx context.Context, client *Client, objectType, relation strin
 
 // WriteTuplesBatch writes tuples in batches to avoid overwhelming the API
 func WriteTuplesBatch(ctx context.Context, client *Client, tuples []Tuple) error {
+	return runBatches(ctx, tuples, "write", batchOptions{}, client.WriteTuples)
+}
+
+// BatchProgress reports how far a batched write or delete, a rename or a backup has got
+type BatchProgress struct {
+	Operation string // "write", "delete", "backup", or a rename, e.g. "rename_type team organization"
+	Done      int
+	Total     int // 0 when not known up front, as for renames and backups
+	Elapsed   time.Duration
+}
+
+// Rate returns the throughput so far in tuples per second
+func (p BatchProgress) Rate() float64 {
+	if p.Elapsed <= 0 {
+		return 0
+	}
+	return float64(p.Done) / p.Elapsed.Seconds()
+}
+
+// ETA estimates the time left at the current rate, or 0 when it is not known
+func (p BatchProgress) ETA() time.Duration {
+	rate := p.Rate()
+	if rate == 0 || p.Done >= p.Total {
+		return 0
+	}
+	return time.Duration(float64(p.Total-p.Done) / rate * float64(time.Second))
+}
+
+// String formats the throughput and ETA, e.g. "250 tuples/s, ETA 1m20s"
+func (p BatchProgress) String() string {
+	if p.Done == 0 {
+		return "starting"
+	}
+	if p.Total <= 0 {
+		return fmt.Sprintf("%.0f tuples/s", p.Rate())
+	}
+	return fmt.Sprintf("%.0f tuples/s, ETA %s", p.Rate(), p.ETA().Round(time.Second))
+}
+
+// batchOptions are the batching settings shared by WriteOptions and DeleteOptions
+type batchOptions struct {
+	size     int // Tuples per request; 0 means batchSize
+	workers  int // Batches in flight at once; 0 or 1 runs them one after another
+	progress func(BatchProgress)
+}
+
+// runBatches applies fn to tuples in batches, on up to opts.workers goroutines, reporting
+// each batch to the progress reporter, to opts.progress and to the event stream of the
+// migration that is running
+// The first failing batch stops new batches from starting; the errors of every batch
+// that failed are returned together
+func runBatches(ctx context.Context, tuples []Tuple, operation string, opts batchOptions, fn func(context.Context, []Tuple) error) error {
+	size := opts.size
+	if size <= 0 {
+		size = batchSize
+	}
+	workers := opts.workers
+	if workers <= 0 {
+		workers = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex // Guards done and errs, and keeps progress reports in order
+		done int
+		errs []error
+	)
 	total := len(tuples)
-	for i := 0; i < total; i += batchSize {
-		end := i + batchSize
+	progress := startProgress(ctx, operation, total, opts.progress)
+	slots := make(chan struct{}, workers)
+
+	for i := 0; i < total; i += size {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break // A batch failed or the caller cancelled: start nothing new
+		}
+
+		end := i + size
 		if end > total {
 			end = total
 		}
 
-		batch := tuples[i:end]
-		fmt.Printf("Writing batch %d-%d of %d tuples\n", i+1, end, total)
+		wg.Add(1)
+		go func(i, end int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			err := fn(ctx, tuples[i:end])
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to %s batch %d-%d: %w", operation, i+1, end, err))
+				cancel()
+				return
+			}
+
+			done += end - i
+			progress.update(done)
+		}(i, end)
 
-		if err := client.WriteTuples(ctx, batch); err != nil {
-			return fmt.Errorf("failed to write batch %d-%d: %w", i+1, end, err)
+		// One worker runs the batches in order, as if there were no goroutines
+		if workers == 1 {
+			wg.Wait()
 		}
 	}
+	wg.Wait()
 
-	return nil
+	var err error
+	if len(errs) > 0 {
+		err = errors.Join(errs...)
+	} else if done < total {
+		err = ctx.Err() // Cancelled by the caller before every batch could start
+	}
+	if total > 0 {
+		progress.finish(done, err)
+	}
+	return err
 }
 
-// DeleteTuplesBatch deletes tuples in batches
-func DeleteTuplesBatch(ctx context.Context, client *Client, tuples []Tuple) error {
-	total := len(tuples)
-	for i := 0; i < total; i += batchSize {
-		end := i + batchSize
-		if end > total {
-			end = total
+// WriteOptions controls how WriteTuplesBatchWithOptions filters tuples before writing
+type WriteOptions struct {
+	// Deduplicate drops repeated tuples within the input
+	Deduplicate bool
+
+	// SkipExisting reads the store first and drops tuples that are already written
+	// Each object and relation in the input is read, so this costs a read request per
+	// distinct object and relation, however large the store
+	SkipExisting bool
+
+	// Verify re-reads the written tuples afterwards and fails with a
+	// *TupleVerificationError if any are missing
+	Verify bool
+
+	// VerifySample limits verification to a random sample of this many tuples (0 = all)
+	VerifySample int
+
+	// Progress is called after each batch with the throughput so far
+	Progress func(BatchProgress)
+
+	// BatchSize is how many tuples each write request carries; 0 means 100
+	BatchSize int
+
+	// Workers is how many batches are written at once; 0 or 1 writes them one after
+	// another. Requests still share the client's MaxConcurrentRequests and rate limit
+	Workers int
+
+	// Idempotent tolerates tuples that are already written, e.g. when re-running a
+	// partially applied migration: a batch OpenFGA rejects for one is retried a tuple at
+	// a time, skipping those. Unlike SkipExisting, it reads nothing up front
+	Idempotent bool
+
+	// RenameUserRefs makes RenameTypeWithOptions rename the type on the user side of
+	// tuples too, as RenameUserType does. Other writes ignore it
+	RenameUserRefs bool
+
+	// VerifyAndSwap makes renames run in three phases: write every replacement, verify
+	// them (counts and a spot check), then delete the originals, recording each phase in
+	// the rename journal (see WithRenameJournal). An interrupted rename is finished or
+	// rolled back with FinishRename or RollBackRename. Other writes ignore it
+	VerifyAndSwap bool
+}
+
+// DeleteOptions controls DeleteTuplesBatchWithOptions
+type DeleteOptions struct {
+	// SkipMissing reads the store first and drops tuples that are not written
+	// Each object and relation in the input is read, as for WriteOptions.SkipExisting
+	SkipMissing bool
+
+	// Verify re-reads the deleted tuples afterwards and fails with a
+	// *TupleVerificationError if any are still present
+	Verify bool
+
+	// VerifySample limits verification to a random sample of this many tuples (0 = all)
+	VerifySample int
+
+	// Progress is called after each batch with the throughput so far
+	Progress func(BatchProgress)
+
+	// BatchSize is how many tuples each delete request carries; 0 means 100
+	BatchSize int
+
+	// Workers is how many batches are deleted at once; 0 or 1 deletes them one after
+	// another. Requests still share the client's MaxConcurrentRequests and rate limit
+	Workers int
+
+	// Idempotent tolerates tuples that are already deleted: a batch OpenFGA rejects for
+	// one is retried a tuple at a time, skipping those. Unlike SkipMissing, it reads
+	// nothing up front
+	Idempotent bool
+}
+
+// WriteTuplesBatchWithOptions writes tuples in batches, optionally dropping duplicates first
+// OpenFGA rejects a write request containing a tuple that already exists, so this avoids
+// failures when transforms map several old tuples onto the same new tuple
+func WriteTuplesBatchWithOptions(ctx context.Context, client *Client, tuples []Tuple, opts WriteOptions) error {
+	if opts.Deduplicate {
+		tuples = DeduplicateTuples(tuples)
+	}
+
+	if opts.SkipExisting {
+		var err error
+		tuples, err = filterExistingTuples(ctx, client, tuples)
+		if err != nil {
+			return err
 		}
+	}
 
-		batch := tuples[i:end]
-		fmt.Printf("Deleting batch %d-%d of %d tuples\n", i+1, end, total)
+	write := client.WriteTuples
+	var skipped atomic.Int64
+	if opts.Idempotent {
+		write = idempotentBatch(write, isTupleExistsError, &skipped)
+	}
+	if err := runBatches(ctx, tuples, "write", batchOptions{opts.BatchSize, opts.Workers, opts.Progress}, write); err != nil {
+		return err
+	}
+	if skipped.Load() > 0 {
+		fmt.Printf("Skipped %d tuples that already exist\n", skipped.Load())
+	}
+
+	if opts.Verify {
+		return VerifyTuples(ctx, client, tuples, true, opts.VerifySample)
+	}
+	return nil
+}
 
-		if err := client.DeleteTuples(ctx, batch); err != nil {
-			return fmt.Errorf("failed to delete batch %d-%d: %w", i+1, end, err)
+// DeleteTuplesBatchWithOptions deletes tuples in batches, optionally verifying they are gone
+func DeleteTuplesBatchWithOptions(ctx context.Context, client *Client, tuples []Tuple, opts DeleteOptions) error {
+	if opts.SkipMissing {
+		stored, err := readStoredTuples(ctx, client, tuples)
+		if err != nil {
+			return err
+		}
+		var present []Tuple
+		for _, tuple := range tuples {
+			if stored[tuple] {
+				present = append(present, tuple)
+			}
 		}
+		if skipped := len(tuples) - len(present); skipped > 0 {
+			fmt.Printf("Skipping %d tuples that are not in the store\n", skipped)
+		}
+		tuples = present
 	}
 
+	del := client.DeleteTuples
+	var skipped atomic.Int64
+	if opts.Idempotent {
+		del = idempotentBatch(del, isTupleMissingError, &skipped)
+	}
+	if err := runBatches(ctx, tuples, "delete", batchOptions{opts.BatchSize, opts.Workers, opts.Progress}, del); err != nil {
+		return err
+	}
+	if skipped.Load() > 0 {
+		fmt.Printf("Skipped %d tuples that were not in the store\n", skipped.Load())
+	}
+
+	if opts.Verify {
+		return VerifyTuples(ctx, client, tuples, false, opts.VerifySample)
+	}
 	return nil
 }
 
+// idempotentBatch wraps fn, the write or delete of a batch, so that a batch rejected
+// because some of its tuples are already written (or already deleted), as satisfied tells,
+// is retried a tuple at a time, skipping those. skipped counts the tuples skipped
+func idempotentBatch(fn func(context.Context, []Tuple) error, satisfied func(error) bool, skipped *atomic.Int64) func(context.Context, []Tuple) error {
+	return func(ctx context.Context, batch []Tuple) error {
+		err := fn(ctx, batch)
+		if err == nil || !satisfied(err) {
+			return err
+		}
+		for _, tuple := range batch {
+			if err := fn(ctx, []Tuple{tuple}); err != nil {
+				if !satisfied(err) {
+					return fmt.Errorf("failed on tuple %s %s %s: %w", tuple.User, tuple.Relation, tuple.Object, err)
+				}
+				skipped.Add(1)
+			}
+		}
+		return nil
+	}
+}
+
+// isTupleExistsError reports whether OpenFGA rejected a write because a tuple in it is
+// already written
+func isTupleExistsError(err error) bool {
+	var validationErr openfgaSdk.FgaApiValidationError
+	return errors.As(err, &validationErr) && strings.Contains(err.Error(), "cannot write a tuple which already exists")
+}
+
+// isTupleMissingError reports whether OpenFGA rejected a delete because a tuple in it is
+// not written
+func isTupleMissingError(err error) bool {
+	var validationErr openfgaSdk.FgaApiValidationError
+	return errors.As(err, &validationErr) && strings.Contains(err.Error(), "cannot delete a tuple which does not exist")
+}
+
+// DeduplicateTuples returns the tuples with repeats removed, keeping the first occurrence
+func DeduplicateTuples(tuples []Tuple) []Tuple {
+	seen := make(map[Tuple]bool, len(tuples))
+	unique := make([]Tuple, 0, len(tuples))
+	for _, tuple := range tuples {
+		if !seen[tuple] {
+			seen[tuple] = true
+			unique = append(unique, tuple)
+		}
+	}
+
+	if removed := len(tuples) - len(unique); removed > 0 {
+		fmt.Printf("Skipping %d duplicate tuples\n", removed)
+	}
+	return unique
+}
+
+// filterExistingTuples drops tuples that are already in the store
+func filterExistingTuples(ctx context.Context, client *Client, tuples []Tuple) ([]Tuple, error) {
+	existing, err := readStoredTuples(ctx, client, tuples)
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []Tuple
+	for _, tuple := range tuples {
+		if !existing[tuple] {
+			missing = append(missing, tuple)
+		}
+	}
+
+	if skipped := len(tuples) - len(missing); skipped > 0 {
+		fmt.Printf("Skipping %d tuples that already exist\n", skipped)
+	}
+	return missing, nil
+}
+
+// readStoredTuples reports which of the tuples are stored
+// Each object and relation in the input is read once, a filter the Read API serves
+// directly, so the cost follows the input rather than the size of the store
+func readStoredTuples(ctx context.Context, client *Client, tuples []Tuple) (map[Tuple]bool, error) {
+	wanted := make(map[Tuple]bool, len(tuples))
+	for _, tuple := range tuples {
+		wanted[tuple] = true
+	}
+
+	stored := make(map[Tuple]bool)
+	read := make(map[ReadTuplesRequest]bool)
+	for _, tuple := range tuples {
+		req := ReadTuplesRequest{Relation: tuple.Relation, Object: tuple.Object}
+		if read[req] {
+			continue
+		}
+		read[req] = true
+
+		err := client.IterateTuples(ctx, req, func(t Tuple) error {
+			if wanted[t] {
+				stored[t] = true
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read existing tuples: %w", err)
+		}
+	}
+	return stored, nil
+}
+
+// DeleteTuplesBatch deletes tuples in batches
+func DeleteTuplesBatch(ctx context.Context, client *Client, tuples []Tuple) error {
+	return runBatches(ctx, tuples, "delete", batchOptions{}, client.DeleteTuples)
+}
+
 // UTILITY FUNCTIONS
 
 // BackupTuples exports all tuples to a backup (for safety before migrations)
+// The backup is held in memory; BackupTuplesTo streams large stores to a file instead
 func BackupTuples(ctx context.Context, client *Client) ([]Tuple, error) {
 	fmt.Println("Backing up all tuples...")
-	tuples, err := ReadAllTuples(ctx, client, "", "")
+	var tuples []Tuple
+	err := iterateBackup(ctx, client, func(t Tuple) error {
+		tuples = append(tuples, t)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	fmt.Printf("Backed up %d tuples\n", len(tuples))
 	return tuples, nil
 }
 
+// BackupTuplesTo writes all tuples to w as a JSON array, a page at a time, and returns
+// how many it wrote. The array reads back with json.Unmarshal into []Tuple
+func BackupTuplesTo(ctx context.Context, client *Client, w io.Writer) (int, error) {
+	fmt.Println("Backing up all tuples...")
+	return writeTuplesJSON(w, "", func(fn func(Tuple) error) error {
+		return iterateBackup(ctx, client, fn)
+	})
+}
+
+// iterateBackup calls fn with every tuple of the store, reporting the tuples read so far to
+// the progress reporter after each page
+func iterateBackup(ctx context.Context, client *Client, fn func(Tuple) error) error {
+	progress := startProgress(ctx, "backup", 0, nil)
+	done := 0
+	err := client.iteratePages(ctx, ReadTuplesRequest{}, "", func(page []Tuple, _ string) error {
+		for _, t := range page {
+			if err := fn(t); err != nil {
+				return err
+			}
+		}
+		done += len(page)
+		progress.update(done)
+		return nil
+	})
+	progress.finish(done, err)
+	return err
+}
+
+// writeTuplesJSON writes the tuples iterate yields to w as a JSON array indented by indent,
+// one tuple per line, and returns how many it wrote
+func writeTuplesJSON(w io.Writer, indent string, iterate func(fn func(Tuple) error) error) (int, error) {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return 0, err
+	}
+	count := 0
+	err := iterate(func(t Tuple) error {
+		data, err := json.Marshal(t)
+		if err != nil {
+			return err
+		}
+		separator := "\n  " + indent
+		if count > 0 {
+			separator = "," + separator
+		}
+		count++
+		_, err = io.WriteString(w, separator+string(data))
+		return err
+	})
+	if err != nil {
+		return count, err
+	}
+	end := "]"
+	if count > 0 {
+		end = "\n" + indent + "]"
+	}
+	_, err = io.WriteString(w, end)
+	return count, err
+}
+
 // RestoreTuples restores tuples from a backup
 func RestoreTuples(ctx context.Context, client *Client, tuples []Tuple) error {
 	fmt.Printf("Restoring %d tuples...\n", len(tuples))
@@ -379,6 +1118,9 @@ func RestoreTuples(ctx context.Context, client *Client, tuples []Tuple) error {
 func AddTypeToModel(ctx context.Context, client *Client, typeName string, relations map[string]string) error {
 	fmt.Printf("Adding type '%s' to model\n", typeName)
 
+	unlock := client.lockModel()
+	defer unlock()
+
 	// Get current model
 	currentDSL, err := client.GetCurrentModel(ctx)
 	if err != nil {
@@ -399,19 +1141,19 @@ func AddTypeToModel(ctx context.Context, client *Client, typeName string, relati
 
 	// Create new type definition
 	relationMap := make(map[string]openfgaSdk.Userset)
+	newType := openfgaSdk.TypeDefinition{
+		Type:      typeName,
+		Relations: &relationMap,
+	}
 
 	// Parse relations
 	for relName, relDef := range relations {
-		userset, err := parseRelationDefinition(relDef)
+		userset, typeRestrictions, err := parseRelationDefinition(relDef)
 		if err != nil {
 			return fmt.Errorf("failed to parse relation '%s': %w", relName, err)
 		}
 		relationMap[relName] = userset
-	}
-
-	newType := openfgaSdk.TypeDefinition{
-		Type:      typeName,
-		Relations: &relationMap,
+		setRelationTypeRestrictions(&newType, relName, typeRestrictions)
 	}
 
 	// Add to model
@@ -431,6 +1173,9 @@ func AddTypeToModel(ctx context.Context, client *Client, typeName string, relati
 func AddRelationToType(ctx context.Context, client *Client, typeName, relationName, relationDef string) error {
 	fmt.Printf("Adding relation '%s' to type '%s'\n", relationName, typeName)
 
+	unlock := client.lockModel()
+	defer unlock()
+
 	// Get current model
 	currentDSL, err := client.GetCurrentModel(ctx)
 	if err != nil {
@@ -455,7 +1200,7 @@ func AddRelationToType(ctx context.Context, client *Client, typeName, relationNa
 			}
 
 			// Parse relation definition
-			userset, err := parseRelationDefinition(relationDef)
+			userset, typeRestrictions, err := parseRelationDefinition(relationDef)
 			if err != nil {
 				return fmt.Errorf("failed to parse relation definition: %w", err)
 			}
@@ -464,32 +1209,8 @@ func AddRelationToType(ctx context.Context, client *Client, typeName, relationNa
 			relations[relationName] = userset
 			currentModel.TypeDefinitions[i].Relations = &relations
 
-			// Extract and set type restrictions in metadata
-			// Only set metadata for direct relations (with brackets), not for computed relations
-			typeRestrictions := extractTypeRestrictions(relationDef)
-			fmt.Printf("DEBUG: Adding %s.%s with def='%s', extracted types=%+v\n", typeName, relationName, relationDef, typeRestrictions)
-			// Only set DirectlyRelatedUserTypes for direct relations (those with [...])
-			// Tuple-to-userset relations (with 'from' or '->') should NOT have this metadata
-			isDirect := strings.Contains(relationDef, "[")
-			if len(typeRestrictions) > 0 && isDirect {
-				// Ensure metadata exists
-				if currentModel.TypeDefinitions[i].Metadata == nil {
-					currentModel.TypeDefinitions[i].Metadata = &openfgaSdk.Metadata{}
-				}
-
-				// Ensure relations metadata map exists
-				if currentModel.TypeDefinitions[i].Metadata.Relations == nil {
-					relationsMetadata := make(map[string]openfgaSdk.RelationMetadata)
-					currentModel.TypeDefinitions[i].Metadata.Relations = &relationsMetadata
-				}
-
-				// Set directly related user types for this relation
-				relationsMetadata := *currentModel.TypeDefinitions[i].Metadata.Relations
-				relationsMetadata[relationName] = openfgaSdk.RelationMetadata{
-					DirectlyRelatedUserTypes: &typeRestrictions,
-				}
-				currentModel.TypeDefinitions[i].Metadata.Relations = &relationsMetadata
-			}
+			// Record the directly related types, with their conditions, in metadata
+			setRelationTypeRestrictions(&currentModel.TypeDefinitions[i], relationName, typeRestrictions)
 
 			break
 		}
@@ -513,6 +1234,9 @@ func AddRelationToType(ctx context.Context, client *Client, typeName, relationNa
 func RemoveRelationFromType(ctx context.Context, client *Client, typeName, relationName string) error {
 	fmt.Printf("Removing relation '%s' from type '%s'\n", relationName, typeName)
 
+	unlock := client.lockModel()
+	defer unlock()
+
 	// Get current model
 	currentDSL, err := client.GetCurrentModel(ctx)
 	if err != nil {
@@ -564,6 +1288,9 @@ func RemoveRelationFromType(ctx context.Context, client *Client, typeName, relat
 func RemoveTypeFromModel(ctx context.Context, client *Client, typeName string) error {
 	fmt.Printf("Removing type '%s' from model\n", typeName)
 
+	unlock := client.lockModel()
+	defer unlock()
+
 	// Get current model
 	currentDSL, err := client.GetCurrentModel(ctx)
 	if err != nil {
@@ -607,6 +1334,9 @@ func RemoveTypeFromModel(ctx context.Context, client *Client, typeName string) e
 func UpdateRelationDefinition(ctx context.Context, client *Client, typeName, relationName, newDefinition string) error {
 	fmt.Printf("Updating relation '%s' on type '%s'\n", relationName, typeName)
 
+	unlock := client.lockModel()
+	defer unlock()
+
 	// Get current model
 	currentDSL, err := client.GetCurrentModel(ctx)
 	if err != nil {
@@ -630,13 +1360,14 @@ func UpdateRelationDefinition(ctx context.Context, client *Client, typeName, rel
 				relationFound = true
 
 				// Parse new definition
-				userset, err := parseRelationDefinition(newDefinition)
+				userset, typeRestrictions, err := parseRelationDefinition(newDefinition)
 				if err != nil {
 					return fmt.Errorf("failed to parse new relation definition: %w", err)
 				}
 
 				relations[relationName] = userset
 				currentModel.TypeDefinitions[i].Relations = &relations
+				setRelationTypeRestrictions(&currentModel.TypeDefinitions[i], relationName, typeRestrictions)
 			}
 			break
 		}
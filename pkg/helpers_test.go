@@ -2,8 +2,14 @@ package omg_test
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/demetere/omg/internal/testhelpers"
 	"github.com/demetere/omg/pkg"
@@ -50,6 +56,40 @@ type organization
 	}
 }
 
+func TestMoveRelation(t *testing.T) {
+	ctx := context.Background()
+
+	container, client := testhelpers.SetupOpenFGAContainer(t, ctx, `
+type user
+type team
+  relations
+    define can_invite: [user]
+type organization
+  relations
+    define can_invite: [user]
+`)
+	defer container.Terminate(ctx)
+
+	tuples := []omg.Tuple{
+		{User: "user:alice", Relation: "can_invite", Object: "team:engineering"},
+		{User: "user:bob", Relation: "can_invite", Object: "team:sales"},
+	}
+	require.NoError(t, client.WriteTuples(ctx, tuples))
+
+	err := omg.MoveRelation(ctx, client, "team", "organization", "can_invite")
+	require.NoError(t, err)
+
+	oldTuples, err := omg.ReadAllTuples(ctx, client, "team", "can_invite")
+	require.NoError(t, err)
+	assert.Len(t, oldTuples, 0)
+
+	newTuples, err := omg.ReadAllTuples(ctx, client, "organization", "can_invite")
+	require.NoError(t, err)
+	require.Len(t, newTuples, 2)
+	objects := []string{newTuples[0].Object, newTuples[1].Object}
+	assert.ElementsMatch(t, []string{"organization:engineering", "organization:sales"}, objects)
+}
+
 func TestRenameType(t *testing.T) {
 	ctx := context.Background()
 
@@ -90,6 +130,86 @@ type organization
 	}
 }
 
+func TestPrefixObjectIDs(t *testing.T) {
+	ctx := context.Background()
+
+	container, client := testhelpers.SetupOpenFGAContainer(t, ctx, "")
+	defer container.Terminate(ctx)
+
+	require.NoError(t, omg.ApplyModelFromDSL(ctx, client, `model
+  schema 1.1
+
+type user
+
+type team
+  relations
+    define member: [user]
+
+type document
+  relations
+    define viewer: [user, team#member, team]
+`))
+
+	require.NoError(t, client.WriteTuples(ctx, []omg.Tuple{
+		{User: "user:alice", Relation: "member", Object: "team:eng"},
+		{User: "team:eng#member", Relation: "viewer", Object: "document:readme"},
+		{User: "team:acme/sales", Relation: "viewer", Object: "document:readme"},
+		{User: "user:bob", Relation: "viewer", Object: "document:readme"},
+	}))
+
+	require.NoError(t, omg.PrefixObjectIDs(ctx, client, "team", "acme/"))
+
+	members, err := omg.ReadAllTuples(ctx, client, "team", "member")
+	require.NoError(t, err)
+	assert.Equal(t, []omg.Tuple{{User: "user:alice", Relation: "member", Object: "team:acme/eng"}}, members)
+
+	viewers, err := omg.ReadAllTuples(ctx, client, "document", "viewer")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []omg.Tuple{
+		{User: "team:eng#member", Relation: "viewer", Object: "document:readme"},
+		{User: "team:acme/sales", Relation: "viewer", Object: "document:readme"},
+		{User: "user:bob", Relation: "viewer", Object: "document:readme"},
+	}, viewers, "references from other types are left alone")
+
+	require.NoError(t, omg.UnprefixObjectIDs(ctx, client, "team", "acme/"))
+
+	members, err = omg.ReadAllTuples(ctx, client, "team", "member")
+	require.NoError(t, err)
+	assert.Equal(t, []omg.Tuple{{User: "user:alice", Relation: "member", Object: "team:eng"}}, members)
+}
+
+func TestPrefixObjectIDs_StreamsTheType(t *testing.T) {
+	t.Setenv("OMG_CHECKPOINT_DIR", t.TempDir())
+	tuples := []omg.Tuple{
+		{User: "team:eng#member", Relation: "parent", Object: "team:ops"},
+		{User: "team:eng#member", Relation: "viewer", Object: "document:readme"},
+		{User: "user:anne", Relation: "member", Object: "team:acme/sales"},
+	}
+	for i := 0; i < 120; i++ {
+		tuples = append(tuples, omg.Tuple{User: fmt.Sprintf("user:%d", i), Relation: "member", Object: "team:eng"})
+	}
+	store, client := newTupleStore(t, tuples)
+	ctx := context.Background()
+
+	require.NoError(t, omg.PrefixObjectIDs(ctx, client, "team", "acme/"))
+
+	for _, read := range store.reads {
+		assert.Equal(t, omg.Tuple{Object: "team:"}, read, "only the type's tuples are read")
+	}
+	assert.True(t, store.tuples[omg.Tuple{User: "team:acme/eng#member", Relation: "parent", Object: "team:acme/ops"}])
+	assert.True(t, store.tuples[omg.Tuple{User: "team:eng#member", Relation: "viewer", Object: "document:readme"}])
+	assert.True(t, store.tuples[omg.Tuple{User: "user:anne", Relation: "member", Object: "team:acme/sales"}])
+	assert.True(t, store.tuples[omg.Tuple{User: "user:0", Relation: "member", Object: "team:acme/eng"}])
+	assert.Len(t, store.tuples, len(tuples))
+
+	require.NoError(t, omg.UnprefixObjectIDs(ctx, client, "team", "acme/"))
+
+	assert.True(t, store.tuples[omg.Tuple{User: "team:eng#member", Relation: "parent", Object: "team:ops"}])
+	assert.True(t, store.tuples[omg.Tuple{User: "user:anne", Relation: "member", Object: "team:sales"}])
+	assert.True(t, store.tuples[omg.Tuple{User: "user:119", Relation: "member", Object: "team:eng"}])
+	assert.Len(t, store.tuples, len(tuples))
+}
+
 func TestCopyRelation(t *testing.T) {
 	ctx := context.Background()
 
@@ -327,3 +447,414 @@ type organization
 	assert.Len(t, remainingTuples, 0)
 }
 
+
+func TestDeduplicateTuples(t *testing.T) {
+	alice := omg.Tuple{User: "user:alice", Relation: "member", Object: "team:eng"}
+	bob := omg.Tuple{User: "user:bob", Relation: "member", Object: "team:eng"}
+
+	unique := omg.DeduplicateTuples([]omg.Tuple{alice, bob, alice, alice})
+
+	assert.Equal(t, []omg.Tuple{alice, bob}, unique)
+}
+
+func TestWriteTuplesBatchWithOptions(t *testing.T) {
+	ctx := context.Background()
+
+	container, client := testhelpers.SetupOpenFGAContainer(t, ctx, `
+type user
+type team
+  relations
+    define member: [user]
+`)
+	defer container.Terminate(ctx)
+
+	alice := omg.Tuple{User: "user:alice", Relation: "member", Object: "team:eng"}
+	bob := omg.Tuple{User: "user:bob", Relation: "member", Object: "team:eng"}
+	require.NoError(t, client.WriteTuple(ctx, alice))
+
+	// Without filtering, the duplicate and the existing tuple are rejected
+	err := omg.WriteTuplesBatch(ctx, client, []omg.Tuple{bob, bob})
+	assert.Error(t, err)
+
+	err = omg.WriteTuplesBatchWithOptions(ctx, client, []omg.Tuple{alice, bob, bob}, omg.WriteOptions{
+		Deduplicate:  true,
+		SkipExisting: true,
+	})
+	require.NoError(t, err)
+
+	tuples, err := omg.ReadAllTuples(ctx, client, "team", "member")
+	require.NoError(t, err)
+	assert.Len(t, tuples, 2)
+}
+
+func TestBatchOperations_Verify(t *testing.T) {
+	ctx := context.Background()
+
+	container, client := testhelpers.SetupOpenFGAContainer(t, ctx, `
+type user
+type team
+  relations
+    define member: [user]
+`)
+	defer container.Terminate(ctx)
+
+	var tuples []omg.Tuple
+	for i := 0; i < 20; i++ {
+		tuples = append(tuples, omg.Tuple{User: fmt.Sprintf("user:u%d", i), Relation: "member", Object: "team:eng"})
+	}
+
+	err := omg.WriteTuplesBatchWithOptions(ctx, client, tuples, omg.WriteOptions{Verify: true})
+	require.NoError(t, err)
+
+	err = omg.DeleteTuplesBatchWithOptions(ctx, client, tuples[:10], omg.DeleteOptions{Verify: true, VerifySample: 5})
+	require.NoError(t, err)
+
+	// The deleted half is reported as missing
+	err = omg.VerifyTuples(ctx, client, tuples, true, 0)
+	var verifyErr *omg.TupleVerificationError
+	require.ErrorAs(t, err, &verifyErr)
+	assert.Equal(t, 20, verifyErr.Checked)
+	assert.Len(t, verifyErr.Mismatches, 10)
+}
+
+func TestWaitForTupleCount(t *testing.T) {
+	ctx := context.Background()
+
+	container, client := testhelpers.SetupOpenFGAContainer(t, ctx, `
+type user
+type team
+  relations
+    define member: [user]
+`)
+	defer container.Terminate(ctx)
+
+	require.NoError(t, omg.WriteTuplesBatch(ctx, client, []omg.Tuple{
+		{User: "user:alice", Relation: "member", Object: "team:eng"},
+		{User: "user:bob", Relation: "member", Object: "team:eng"},
+	}))
+
+	assert.NoError(t, omg.WaitForTupleCount(ctx, client, "team", "member", 2, 5*time.Second))
+
+	err := omg.WaitForTupleCount(ctx, client, "team", "member", 3, 300*time.Millisecond)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "found 2")
+}
+
+func TestBatchProgress(t *testing.T) {
+	progress := omg.BatchProgress{Operation: "write", Done: 500, Total: 2000, Elapsed: 2 * time.Second}
+
+	assert.Equal(t, 250.0, progress.Rate())
+	assert.Equal(t, 6*time.Second, progress.ETA())
+	assert.Equal(t, "250 tuples/s, ETA 6s", progress.String())
+
+	assert.Equal(t, time.Duration(0), omg.BatchProgress{Total: 2000}.ETA(), "ETA is unknown before the first batch")
+}
+
+func TestRenameRelationWithOptions_Streams(t *testing.T) {
+	dir, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(t.TempDir()))
+	defer os.Chdir(dir)
+
+	// 1200 team#old tuples on 12 pages; team:0#new is already written
+	// While failDelete is set, deleting user:1100 fails
+	var requests []string
+	var failDelete atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			TupleKey          omg.Tuple `json:"tuple_key"`
+			ContinuationToken string    `json:"continuation_token"`
+			Writes            struct {
+				TupleKeys []omg.Tuple `json:"tuple_keys"`
+			} `json:"writes"`
+			Deletes struct {
+				TupleKeys []omg.Tuple `json:"tuple_keys"`
+			} `json:"deletes"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Header().Set("Content-Type", "application/json")
+
+		switch {
+		case r.URL.Path == "/stores/01HVMMBCMGZNT3SED4Z17ECXCA/write":
+			for _, tuple := range body.Deletes.TupleKeys {
+				if tuple.User == "user:1100" && failDelete.Load() {
+					w.WriteHeader(http.StatusBadRequest)
+					w.Write([]byte(`{"code":"validation_error","message":"invalid tuple"}`))
+					return
+				}
+			}
+			if n := len(body.Writes.TupleKeys); n > 0 {
+				requests = append(requests, fmt.Sprintf("write %d", n))
+			}
+			if n := len(body.Deletes.TupleKeys); n > 0 {
+				requests = append(requests, fmt.Sprintf("delete %d", n))
+			}
+			w.Write([]byte(`{}`))
+		case body.TupleKey.Relation == "old":
+			page := 0
+			fmt.Sscan(body.ContinuationToken, &page)
+			requests = append(requests, fmt.Sprintf("read %d", page))
+			keys := ""
+			for i := page * 100; i < page*100+100; i++ {
+				if i > page*100 {
+					keys += ","
+				}
+				keys += fmt.Sprintf(`{"key":{"user":"user:%d","relation":"old","object":"team:%d"}}`, i, i)
+			}
+			next := ""
+			if page < 11 {
+				next = fmt.Sprint(page + 1)
+			}
+			fmt.Fprintf(w, `{"tuples":[%s],"continuation_token":"%s"}`, keys, next)
+		default:
+			w.Write([]byte(`{"tuples":[{"key":{"user":"user:0","relation":"new","object":"team:0"}}],"continuation_token":""}`))
+		}
+	}))
+	defer server.Close()
+
+	// counts adds up the tuples written and deleted since requests was reset
+	counts := func() (written, deleted, firstDelete, lastRead int) {
+		firstDelete, lastRead = -1, -1
+		for i, request := range requests {
+			var n int
+			if _, err := fmt.Sscanf(request, "write %d", &n); err == nil {
+				written += n
+			} else if _, err := fmt.Sscanf(request, "delete %d", &n); err == nil {
+				deleted += n
+				if firstDelete < 0 {
+					firstDelete = i
+				}
+			} else {
+				lastRead = i
+			}
+		}
+		return
+	}
+
+	client, err := omg.NewClient(omg.Config{ApiURL: server.URL, StoreID: "01HVMMBCMGZNT3SED4Z17ECXCA", AuthMethod: "none", MaxRetries: -1})
+	require.NoError(t, err)
+	require.NoError(t, omg.RenameRelationWithOptions(context.Background(), client, "team", "old", "new", omg.WriteOptions{SkipExisting: true}))
+
+	written, deleted, firstDelete, lastRead := counts()
+	assert.Equal(t, 1199, written)
+	assert.Equal(t, 1200, deleted)
+	assert.Less(t, firstDelete, lastRead, "the first chunk is moved before the last page is read")
+	assert.NoFileExists(t, omg.CheckpointPath("01HVMMBCMGZNT3SED4Z17ECXCA", "rename_relation team old new"))
+
+	// Interrupted after the first chunk, then resumed from its checkpoint
+	requests = nil
+	failDelete.Store(true)
+	err = omg.RenameRelation(context.Background(), client, "team", "old", "new")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "after 1000")
+
+	checkpoint, err := omg.LoadCheckpoint("01HVMMBCMGZNT3SED4Z17ECXCA", "rename_relation team old new")
+	require.NoError(t, err)
+	require.NotNil(t, checkpoint)
+	assert.Equal(t, 1000, checkpoint.Processed)
+	assert.Equal(t, "10", checkpoint.ContinuationToken)
+
+	requests = nil
+	failDelete.Store(false)
+	require.NoError(t, omg.RenameRelation(omg.WithResume(context.Background()), client, "team", "old", "new"))
+	assert.Equal(t, "read 10", requests[0], "reading continues after the checkpoint")
+	written, deleted, _, _ = counts()
+	assert.Equal(t, 200, written)
+	assert.Equal(t, 200, deleted)
+	assert.NoFileExists(t, omg.CheckpointPath("01HVMMBCMGZNT3SED4Z17ECXCA", "rename_relation team old new"))
+}
+
+func TestWriteTuplesBatchWithOptions_Workers(t *testing.T) {
+	// Writes take 20ms; the batch with user:130 is rejected
+	var inFlight, maxInFlight, written atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Writes struct {
+				TupleKeys []omg.Tuple `json:"tuple_keys"`
+			} `json:"writes"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			max := maxInFlight.Load()
+			if n <= max || maxInFlight.CompareAndSwap(max, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+
+		w.Header().Set("Content-Type", "application/json")
+		for _, tuple := range body.Writes.TupleKeys {
+			if tuple.User == "user:130" {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"code":"validation_error","message":"invalid tuple"}`))
+				return
+			}
+		}
+		written.Add(int32(len(body.Writes.TupleKeys)))
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	client, err := omg.NewClient(omg.Config{ApiURL: server.URL, StoreID: "01HVMMBCMGZNT3SED4Z17ECXCA", AuthMethod: "none", MaxRetries: -1})
+	require.NoError(t, err)
+
+	tuples := make([]omg.Tuple, 1000)
+	for i := range tuples {
+		tuples[i] = omg.Tuple{User: fmt.Sprintf("user:%d", i+1000), Relation: "viewer", Object: "document:readme"}
+	}
+	var reports []int
+	opts := omg.WriteOptions{BatchSize: 50, Workers: 4, Progress: func(p omg.BatchProgress) { reports = append(reports, p.Done) }}
+	require.NoError(t, omg.WriteTuplesBatchWithOptions(context.Background(), client, tuples, opts))
+	assert.Equal(t, int32(1000), written.Load())
+	assert.Greater(t, maxInFlight.Load(), int32(1), "batches run in parallel")
+	assert.LessOrEqual(t, maxInFlight.Load(), int32(4))
+	assert.Len(t, reports, 20)
+	assert.IsIncreasing(t, reports)
+
+	// A failed batch stops the rest
+	tuples[130].User = "user:130"
+	written.Store(0)
+	err = omg.WriteTuplesBatchWithOptions(context.Background(), client, tuples, omg.WriteOptions{BatchSize: 50, Workers: 4})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to write batch 101-150")
+	assert.Less(t, written.Load(), int32(1000))
+}
+
+func TestBatchOperations_Idempotent(t *testing.T) {
+	// Like OpenFGA, rejects whole writes that contain a written tuple, and deletes that
+	// contain a missing one
+	stored := map[omg.Tuple]bool{}
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Writes struct {
+				TupleKeys []omg.Tuple `json:"tuple_keys"`
+			} `json:"writes"`
+			Deletes struct {
+				TupleKeys []omg.Tuple `json:"tuple_keys"`
+			} `json:"deletes"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		requests++
+
+		w.Header().Set("Content-Type", "application/json")
+		reject := func(message string, tuple omg.Tuple) {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"code":"write_failed_due_to_invalid_input","message":"%s: user: '%s', relation: '%s', object: '%s'"}`, message, tuple.User, tuple.Relation, tuple.Object)
+		}
+		for _, tuple := range body.Writes.TupleKeys {
+			if tuple.User == "user:invalid" {
+				reject("invalid user", tuple)
+				return
+			}
+			if stored[tuple] {
+				reject("cannot write a tuple which already exists", tuple)
+				return
+			}
+		}
+		for _, tuple := range body.Deletes.TupleKeys {
+			if !stored[tuple] {
+				reject("cannot delete a tuple which does not exist", tuple)
+				return
+			}
+		}
+		for _, tuple := range body.Writes.TupleKeys {
+			stored[tuple] = true
+		}
+		for _, tuple := range body.Deletes.TupleKeys {
+			delete(stored, tuple)
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	client, err := omg.NewClient(omg.Config{ApiURL: server.URL, StoreID: "01HVMMBCMGZNT3SED4Z17ECXCA", AuthMethod: "none", MaxRetries: -1})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	tuples := make([]omg.Tuple, 150)
+	for i := range tuples {
+		tuples[i] = omg.Tuple{User: fmt.Sprintf("user:%d", i), Relation: "viewer", Object: "document:readme"}
+	}
+	require.NoError(t, omg.WriteTuplesBatch(ctx, client, tuples[:120]))
+
+	// Re-running fails without Idempotent
+	err = omg.WriteTuplesBatch(ctx, client, tuples)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "already exists")
+
+	// With it, only the batch holding written tuples is retried tuple by tuple
+	requests = 0
+	require.NoError(t, omg.WriteTuplesBatchWithOptions(ctx, client, tuples, omg.WriteOptions{Idempotent: true}))
+	assert.Len(t, stored, 150)
+	assert.Equal(t, 1+100+1+50, requests)
+
+	require.NoError(t, omg.DeleteTuplesBatch(ctx, client, tuples[:20]))
+	err = omg.DeleteTuplesBatch(ctx, client, tuples)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "does not exist")
+
+	require.NoError(t, omg.DeleteTuplesBatchWithOptions(ctx, client, tuples, omg.DeleteOptions{Idempotent: true}))
+	assert.Empty(t, stored)
+
+	// Other errors still fail, naming the tuple
+	tuples[130].User = "user:invalid"
+	require.NoError(t, omg.WriteTuplesBatch(ctx, client, tuples[:10]))
+	err = omg.WriteTuplesBatchWithOptions(ctx, client, append(tuples[:10:10], tuples[130]), omg.WriteOptions{Idempotent: true})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed on tuple user:invalid viewer document:readme")
+}
+
+func TestRenameUserType(t *testing.T) {
+	tuples := []omg.Tuple{
+		{User: "team:eng#member", Relation: "viewer", Object: "document:readme"},
+		{User: "team:eng", Relation: "owner", Object: "folder:plans"},
+		{User: "team:*", Relation: "viewer", Object: "document:public"},
+		{User: "team:acme", Relation: "parent", Object: "team:eng"},
+		{User: "user:anne", Relation: "member", Object: "team:eng"},
+		{User: "teams:eng", Relation: "viewer", Object: "document:readme"},
+	}
+	for i := 0; i < 120; i++ {
+		tuples = append(tuples, omg.Tuple{User: fmt.Sprintf("team:%d#member", i), Relation: "viewer", Object: fmt.Sprintf("document:%d", i)})
+	}
+	stored := func(store *tupleStore) map[omg.Tuple]bool {
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		snapshot := map[omg.Tuple]bool{}
+		for tuple := range store.tuples {
+			snapshot[tuple] = true
+		}
+		return snapshot
+	}
+
+	// Only the user side is renamed
+	store, client := newTupleStore(t, tuples)
+	require.NoError(t, omg.RenameUserType(context.Background(), client, "team", "organization"))
+	after := stored(store)
+	assert.Len(t, after, len(tuples))
+	for _, tuple := range []omg.Tuple{
+		{User: "organization:eng#member", Relation: "viewer", Object: "document:readme"},
+		{User: "organization:eng", Relation: "owner", Object: "folder:plans"},
+		{User: "organization:*", Relation: "viewer", Object: "document:public"},
+		{User: "organization:acme", Relation: "parent", Object: "team:eng"},
+		{User: "user:anne", Relation: "member", Object: "team:eng"},
+		{User: "teams:eng", Relation: "viewer", Object: "document:readme"},
+		{User: "organization:119#member", Relation: "viewer", Object: "document:119"},
+	} {
+		assert.True(t, after[tuple], tuple)
+	}
+
+	// RenameUserRefs renames both sides, here verified and swapped
+	store, client = newTupleStore(t, tuples)
+	opts := omg.WriteOptions{RenameUserRefs: true, VerifyAndSwap: true}
+	require.NoError(t, omg.RenameTypeWithOptions(context.Background(), client, "team", "organization", opts))
+	after = stored(store)
+	assert.Len(t, after, len(tuples))
+	assert.True(t, after[omg.Tuple{User: "organization:acme", Relation: "parent", Object: "organization:eng"}])
+	assert.True(t, after[omg.Tuple{User: "user:anne", Relation: "member", Object: "organization:eng"}])
+	for tuple := range after {
+		assert.NotContains(t, tuple.User+" "+tuple.Object, "team:", tuple)
+	}
+}
@@ -0,0 +1,159 @@
+package omg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// TypeExists reports whether the current model defines a type
+func TypeExists(ctx context.Context, client *Client, typeName string) (bool, error) {
+	model, err := client.GetCurrentAuthorizationModel(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to get current model: %w", err)
+	}
+
+	for _, typeDef := range model.GetTypeDefinitions() {
+		if typeDef.GetType() == typeName {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// RelationExists reports whether a type in the current model defines a relation
+// Returns false when the type itself does not exist
+func RelationExists(ctx context.Context, client *Client, typeName, relationName string) (bool, error) {
+	model, err := client.GetCurrentAuthorizationModel(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to get current model: %w", err)
+	}
+
+	for _, typeDef := range model.GetTypeDefinitions() {
+		if typeDef.GetType() == typeName {
+			_, exists := typeDef.GetRelations()[relationName]
+			return exists, nil
+		}
+	}
+	return false, nil
+}
+
+// AddTypeToModelIfMissing adds a type unless the model already defines it
+func AddTypeToModelIfMissing(ctx context.Context, client *Client, typeName string, relations map[string]string) error {
+	exists, err := TypeExists(ctx, client, typeName)
+	if err != nil {
+		return err
+	}
+	if exists {
+		fmt.Printf("Type '%s' already exists, skipping\n", typeName)
+		return nil
+	}
+	return AddTypeToModel(ctx, client, typeName, relations)
+}
+
+// AddRelationToTypeIfMissing adds a relation unless the type already defines it
+func AddRelationToTypeIfMissing(ctx context.Context, client *Client, typeName, relationName, relationDef string) error {
+	exists, err := RelationExists(ctx, client, typeName, relationName)
+	if err != nil {
+		return err
+	}
+	if exists {
+		fmt.Printf("Relation '%s' already exists on type '%s', skipping\n", relationName, typeName)
+		return nil
+	}
+	return AddRelationToType(ctx, client, typeName, relationName, relationDef)
+}
+
+// RemoveRelationFromTypeIfExists removes a relation unless it is already gone
+func RemoveRelationFromTypeIfExists(ctx context.Context, client *Client, typeName, relationName string) error {
+	exists, err := RelationExists(ctx, client, typeName, relationName)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		fmt.Printf("Relation '%s' not found on type '%s', skipping\n", relationName, typeName)
+		return nil
+	}
+	return RemoveRelationFromType(ctx, client, typeName, relationName)
+}
+
+// RemoveTypeFromModelIfExists removes a type unless it is already gone
+func RemoveTypeFromModelIfExists(ctx context.Context, client *Client, typeName string) error {
+	exists, err := TypeExists(ctx, client, typeName)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		fmt.Printf("Type '%s' not found, skipping\n", typeName)
+		return nil
+	}
+	return RemoveTypeFromModel(ctx, client, typeName)
+}
+
+// ConditionExists reports whether the current model defines a condition
+func ConditionExists(ctx context.Context, client *Client, name string) (bool, error) {
+	model, err := client.GetCurrentAuthorizationModel(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to get current model: %w", err)
+	}
+
+	_, exists := model.GetConditions()[name]
+	return exists, nil
+}
+
+// RemoveConditionIfExists removes a condition unless it is already gone
+func RemoveConditionIfExists(ctx context.Context, client *Client, name string) error {
+	exists, err := ConditionExists(ctx, client, name)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		fmt.Printf("Condition '%s' not found, skipping\n", name)
+		return nil
+	}
+	return RemoveCondition(ctx, client, name)
+}
+
+// BackupForRemovalIfMissing takes a removal backup unless one already exists for this version
+// An interrupted run has already backed up the full data; backing up again would
+// overwrite it with whatever is left. Returns the backup file path
+func BackupForRemovalIfMissing(ctx context.Context, client *Client, version, objectType, relation string) (string, error) {
+	path := BackupFilePath(version, objectType, relation)
+	if _, err := os.Stat(path); err == nil {
+		fmt.Printf("Backup %s already exists, skipping\n", path)
+		return path, nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("failed to check backup: %w", err)
+	}
+	return BackupForRemoval(ctx, client, version, objectType, relation)
+}
+
+// idempotentOperations maps helper names to the state-checking variants generated with
+// GenerateOptions.Idempotent
+var idempotentOperations = map[string]string{
+	"AddTypeToModel":         "AddTypeToModelIfMissing",
+	"AddRelationToType":      "AddRelationToTypeIfMissing",
+	"RemoveRelationFromType": "RemoveRelationFromTypeIfExists",
+	"RemoveTypeFromModel":    "RemoveTypeFromModelIfExists",
+	"BackupForRemoval":       "BackupForRemovalIfMissing",
+	"RemoveCondition":        "RemoveConditionIfExists",
+	"RenameRelation":         "RenameRelationWithOptions",
+	"RenameType":             "RenameTypeWithOptions",
+	"RenameUserType":         "RenameUserTypeWithOptions",
+	"MoveRelation":           "MoveRelationWithOptions",
+}
+
+// helperCall returns generated code calling the named helper with ctx, client and args,
+// or its state-checking variant when opts.Idempotent is set
+// The WithOptions variants skip tuples that are already written
+func helperCall(opts GenerateOptions, helper, args string) string {
+	if variant, ok := idempotentOperations[helper]; ok && opts.Idempotent {
+		if strings.HasSuffix(variant, "WithOptions") {
+			args += ", omg.WriteOptions{SkipExisting: true}"
+		}
+		helper = variant
+	}
+	return "omg." + helper + "(ctx, client, " + args + ")"
+}
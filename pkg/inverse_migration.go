@@ -0,0 +1,169 @@
+package omg
+
+import (
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrationVersionRef matches references to a migration's migrationVersion constant
+var migrationVersionRef = regexp.MustCompile(`\bmigrationVersion\b`)
+
+// GenerateInverseMigration writes a new migration that undoes the migration at sourcePath:
+// its up is the source's down and its down is the source's up. Use it to revert an
+// applied migration with a forward migration instead of running down out of order.
+// Backups the source made under .omg/backups keep being read from the source's version.
+// The inverse keeps the source's style: a 'go run' migration or one registered from
+// init() in the same package, or a declarative file of the same format. Returns the file path
+func GenerateInverseMigration(sourcePath, name, migrationsDir string) (string, error) {
+	if IsDeclarativeMigration(sourcePath) {
+		return generateInverseDeclarative(sourcePath, name, migrationsDir)
+	}
+
+	src, err := os.ReadFile(sourcePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read migration: %w", err)
+	}
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, sourcePath, src, parser.ParseComments)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse migration: %w", err)
+	}
+
+	meta, err := ParseMigrationMetadata(sourcePath)
+	if err != nil {
+		return "", err
+	}
+	if meta.Version == "" {
+		return "", fmt.Errorf("%s has no '// Version:' header", sourcePath)
+	}
+
+	upName, downName := "up", "down"
+	if file.Name.Name != "main" {
+		upName, downName = "up"+meta.Version, "down"+meta.Version
+	}
+
+	// Keep everything but the wiring the new preamble replaces, with up and down swapped
+	var upCode, downCode string
+	var rest []string
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.GenDecl:
+			if d.Tok == token.IMPORT || isMigrationVersionConst(d) {
+				continue
+			}
+		case *ast.FuncDecl:
+			if d.Recv == nil {
+				switch d.Name.Name {
+				case "main", "init":
+					continue
+				case upName:
+					downCode = renamedFunc(fset, src, d, "down")
+					continue
+				case downName:
+					upCode = renamedFunc(fset, src, d, "up")
+					continue
+				}
+			}
+		}
+		rest = append(rest, declSource(fset, src, decl))
+	}
+	if upCode == "" || downCode == "" {
+		return "", fmt.Errorf("%s has no %s and %s functions", sourcePath, upName, downName)
+	}
+
+	funcs := upCode + "\n\n" + downCode + "\n"
+	// Registered migrations share their package, so the source's helpers are already in scope
+	if len(rest) > 0 && file.Name.Name == "main" {
+		funcs += "\n" + strings.Join(rest, "\n\n") + "\n"
+	}
+	// Restores must read the backups the source migration wrote
+	funcs = migrationVersionRef.ReplaceAllString(funcs, strconv.Quote(meta.Version))
+
+	version := time.Now().Format("20060102150405")
+	description := fmt.Sprintf("Inverse of %s_%s: up is its down, down is its up", meta.Version, meta.Name)
+	var code string
+	if file.Name.Name == "main" {
+		code = migrationPreamble(version, name, description) + funcs
+	} else {
+		code = registeredMigrationCode(file.Name.Name, version, name, description, funcs)
+	}
+	code = addImports(code, file.Imports)
+
+	formatted, err := format.Source([]byte(code))
+	if err != nil {
+		return "", fmt.Errorf("failed to format inverse migration: %w", err)
+	}
+
+	filename := fmt.Sprintf("%s/%s_%s.go", migrationsDir, version, sanitizeName(name))
+	if err := os.MkdirAll(migrationsDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create migrations directory: %w", err)
+	}
+	if err := os.WriteFile(filename, formatted, 0644); err != nil {
+		return "", fmt.Errorf("failed to write migration file: %w", err)
+	}
+	return filename, nil
+}
+
+// isMigrationVersionConst reports whether decl declares the migrationVersion constant
+func isMigrationVersionConst(decl *ast.GenDecl) bool {
+	if decl.Tok != token.CONST || len(decl.Specs) != 1 {
+		return false
+	}
+	spec, ok := decl.Specs[0].(*ast.ValueSpec)
+	return ok && len(spec.Names) == 1 && spec.Names[0].Name == "migrationVersion"
+}
+
+// declSource returns the source of a declaration, including its doc comment
+func declSource(fset *token.FileSet, src []byte, decl ast.Decl) string {
+	start := decl.Pos()
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		if d.Doc != nil {
+			start = d.Doc.Pos()
+		}
+	case *ast.GenDecl:
+		if d.Doc != nil {
+			start = d.Doc.Pos()
+		}
+	}
+	return string(src[fset.Position(start).Offset:fset.Position(decl.End()).Offset])
+}
+
+// renamedFunc returns the source of a function declared under a new name, without its doc comment
+func renamedFunc(fset *token.FileSet, src []byte, fn *ast.FuncDecl, name string) string {
+	start := fset.Position(fn.Pos()).Offset
+	nameStart := fset.Position(fn.Name.Pos()).Offset
+	nameEnd := fset.Position(fn.Name.End()).Offset
+	end := fset.Position(fn.End()).Offset
+	return string(src[start:nameStart]) + name + string(src[nameEnd:end])
+}
+
+// addImports adds the source's imports that the generated preamble lacks
+func addImports(code string, imports []*ast.ImportSpec) string {
+	const anchor = "\tomg \"github.com/demetere/omg\"\n"
+
+	var extra strings.Builder
+	for _, spec := range imports {
+		line := spec.Path.Value
+		if spec.Name != nil {
+			line = spec.Name.Name + " " + line
+		}
+		if strings.Contains(code, "\t"+line+"\n") || spec.Path.Value == `"github.com/demetere/omg"` {
+			continue
+		}
+		extra.WriteString("\t" + line + "\n")
+	}
+	if extra.Len() == 0 {
+		return code
+	}
+	return strings.Replace(code, anchor, extra.String()+anchor, 1)
+}
@@ -0,0 +1,80 @@
+package omg_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/demetere/omg/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateInverseMigration(t *testing.T) {
+	dir := t.TempDir()
+	changes := []omg.ModelChange{
+		{Type: "add_relation", TypeName: "document", RelationName: "editor", NewValue: "[user]", Details: "Add editor"},
+		{Type: "remove_relation", TypeName: "document", RelationName: "legacy", OldValue: "[user]", Details: "Remove legacy"},
+	}
+	source, err := omg.GenerateMigrationFromChanges(changes, "cleanup", dir)
+	require.NoError(t, err)
+	sourceVersion := strings.SplitN(filepath.Base(source), "_", 2)[0]
+
+	time.Sleep(time.Second)
+	filename, err := omg.GenerateInverseMigration(source, "revert_cleanup", dir)
+	require.NoError(t, err)
+	assert.NotEqual(t, source, filename)
+
+	content, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	code := string(content)
+
+	up := code[strings.Index(code, "func up("):strings.Index(code, "func down(")]
+	down := code[strings.Index(code, "func down("):]
+
+	// up is the source's down: restore the backup the source made, drop the added relation
+	assert.Contains(t, up, `omg.BackupFilePath("`+sourceVersion+`", "document", "legacy")`)
+	assert.Contains(t, up, `omg.RemoveRelationFromType(ctx, client, "document", "editor")`)
+	// down is the source's up
+	assert.Contains(t, down, `omg.AddRelationToType(ctx, client, "document", "editor", "[user]")`)
+	assert.Contains(t, down, `omg.BackupForRemoval(ctx, client, "`+sourceVersion+`", "document", "legacy")`)
+
+	meta, err := omg.ParseMigrationMetadata(filename)
+	require.NoError(t, err)
+	assert.Equal(t, "revert_cleanup", meta.Name)
+	assert.Contains(t, meta.Description, "Inverse of "+sourceVersion+"_cleanup")
+}
+
+func TestGenerateInverseMigration_Compiles(t *testing.T) {
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go toolchain not available")
+	}
+
+	dir, err := os.MkdirTemp(".", "_inverse")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	changes := []omg.ModelChange{
+		{Type: "add_type", TypeName: "folder", Details: "Add folder"},
+		{Type: "remove_relation", TypeName: "document", RelationName: "legacy", OldValue: "[user]", Details: "Remove legacy"},
+	}
+	source, err := omg.GenerateMigrationFromChangesWithOptions(changes, "folders", dir, omg.GenerateOptions{Package: "migrations"})
+	require.NoError(t, err)
+
+	time.Sleep(time.Second)
+	filename, err := omg.GenerateInverseMigration(source, "revert_folders", dir)
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "package migrations")
+	assert.Contains(t, string(content), "omg.Register(omg.Migration{")
+
+	// Both files share the package, so they only compile if nothing clashes
+	out, err := exec.Command(goBin, "vet", "./"+filepath.Base(dir)).CombinedOutput()
+	assert.NoError(t, err, "inverse migration does not compile:\n%s", out)
+}
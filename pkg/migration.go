@@ -11,6 +11,9 @@ type Migration struct {
 	Name    string
 	Up      func(ctx context.Context, client *Client) error
 	Down    func(ctx context.Context, client *Client) error
+
+	// Checks are verified with BatchCheck after Up has been applied
+	Checks []CheckExpectation
 }
 
 var migrations []Migration
@@ -23,8 +26,13 @@ func Register(m Migration) {
 
 // GetAll returns all registered migrations sorted by version (timestamp)
 func GetAll() []Migration {
-	sorted := make([]Migration, len(migrations))
-	copy(sorted, migrations)
+	return sortMigrations(migrations)
+}
+
+// sortMigrations returns a copy of all sorted by version
+func sortMigrations(all []Migration) []Migration {
+	sorted := make([]Migration, len(all))
+	copy(sorted, all)
 	sort.Slice(sorted, func(i, j int) bool {
 		return sorted[i].Version < sorted[j].Version
 	})
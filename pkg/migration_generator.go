@@ -3,21 +3,163 @@ package omg
 import (
 	"fmt"
 	"os"
+	"regexp"
 	"strings"
 	"time"
 )
 
+// GenerateOptions controls optional parts of the generated migration code
+type GenerateOptions struct {
+	// Backfill adds a data step after update_relation changes that widen a
+	// direct relation with computed relations (e.g. [user] -> [user] or editor),
+	// reporting direct tuples made redundant by the new definition
+	Backfill bool
+
+	// WithTests writes a <version>_<name>_test.go next to the migration that
+	// applies PriorModel to a throwaway OpenFGA container, runs up() and checks
+	// the expected post-conditions
+	WithTests bool
+
+	// PriorModel is the model DSL before the migration (used by WithTests)
+	PriorModel string
+
+	// Idempotent makes every step check the current state first and skip work
+	// that is already done (type exists, relation gone, tuples already renamed),
+	// so re-running an interrupted up or down is safe
+	Idempotent bool
+
+	// Package writes a migration for the named Go package that registers itself with
+	// Register from init() instead of having a main(), so migrations can be compiled
+	// into a binary and run in-process with Run. Empty writes a 'go run' migration
+	Package string
+
+	// ModelOnly generates model changes only, for stores whose tuples are managed by the
+	// application: removals leave their tuples in place without a backup, Backfill is
+	// ignored, and renames are rejected since they migrate tuples
+	ModelOnly bool
+
+	// Strategy is how model changes are made: StrategyIncremental (the default) or
+	// StrategyModelApply, which applies TargetModel as a whole and PriorModel in down
+	Strategy string
+
+	// TargetModel is the model DSL after the migration (used by StrategyModelApply)
+	TargetModel string
+
+	// Template is a Go text/template that lays out the migration file instead of the
+	// default layout (see MigrationTemplateData). Empty uses the default
+	Template string
+
+	// Verify type-checks the written migration with 'go vet', from the Go module that
+	// holds the migrations directory, and removes it again when that fails
+	Verify bool
+}
+
 // GenerateMigrationFromChanges generates a migration file from detected model changes
 func GenerateMigrationFromChanges(changes []ModelChange, name string, migrationsDir string) (string, error) {
+	return GenerateMigrationFromChangesWithOptions(changes, name, migrationsDir, GenerateOptions{})
+}
+
+// GenerateMigrationFromChangesWithOptions generates a migration file using the given generation options
+func GenerateMigrationFromChangesWithOptions(changes []ModelChange, name string, migrationsDir string, opts GenerateOptions) (string, error) {
 	if len(changes) == 0 {
 		return "", fmt.Errorf("no changes detected")
 	}
 
 	timestamp := time.Now().Format("20060102150405")
-	filename := fmt.Sprintf("%s/%s_%s.go", migrationsDir, timestamp, sanitizeName(name))
+	return writeMigrationFiles(timestamp, name, changes, migrationsDir, opts)
+}
+
+// SplitGroup is the part of a change set that goes into one file of a split migration
+type SplitGroup struct {
+	Suffix  string // Appended to the migration name: model, tuples or cleanup
+	Changes []ModelChange
+}
+
+// SplitChanges groups changes by category, in the order they must be applied:
+// model-only changes (added types and relations, updated relations), tuple migrations
+// (renames and moves) and destructive cleanups (removals). Empty groups are left out
+func SplitChanges(changes []ModelChange) []SplitGroup {
+	groups := []SplitGroup{{Suffix: "model"}, {Suffix: "tuples"}, {Suffix: "cleanup"}}
+	for _, change := range changes {
+		switch change.Type {
+		case ChangeTypeAddCondition, ChangeTypeUpdateCondition, ChangeTypeAddType, ChangeTypeAddRelation, ChangeTypeUpdateRelation, ChangeTypeUpdateTypeRestrictions:
+			groups[0].Changes = append(groups[0].Changes, change)
+		case ChangeTypeRenameType, ChangeTypeRenameRelation, ChangeTypeMoveRelation:
+			groups[1].Changes = append(groups[1].Changes, change)
+		case ChangeTypeRemoveType, ChangeTypeRemoveRelation, ChangeTypeRemoveCondition:
+			groups[2].Changes = append(groups[2].Changes, change)
+		}
+	}
+
+	var nonEmpty []SplitGroup
+	for _, group := range groups {
+		if len(group.Changes) > 0 {
+			nonEmpty = append(nonEmpty, group)
+		}
+	}
+	return nonEmpty
+}
+
+// GenerateSplitMigrations generates one migration per SplitChanges group, named
+// <name>_model, <name>_tuples and <name>_cleanup, with consecutive versions so they
+// apply in order. Each file can be reviewed and applied on its own
+// WithTests is not supported, since only the first file starts from the current model
+func GenerateSplitMigrations(changes []ModelChange, name string, migrationsDir string, opts GenerateOptions) ([]string, error) {
+	if len(changes) == 0 {
+		return nil, fmt.Errorf("no changes detected")
+	}
+	if opts.WithTests {
+		return nil, fmt.Errorf("tests cannot be generated for split migrations")
+	}
+	if opts.Strategy == StrategyModelApply {
+		return nil, fmt.Errorf("the %s strategy applies the whole model and cannot be split", StrategyModelApply)
+	}
+
+	start := time.Now()
+	var filenames []string
+	for i, group := range SplitChanges(changes) {
+		version := start.Add(time.Duration(i) * time.Second).Format("20060102150405")
+		filename, err := writeMigrationFiles(version, name+"_"+group.Suffix, group.Changes, migrationsDir, opts)
+		if err != nil {
+			return filenames, err
+		}
+		filenames = append(filenames, filename)
+	}
+	return filenames, nil
+}
+
+// writeMigrationFiles writes a migration (and its test, with WithTests) for a version
+func writeMigrationFiles(version, name string, changes []ModelChange, migrationsDir string, opts GenerateOptions) (string, error) {
+	if opts.WithTests && opts.Package != "" {
+		return "", fmt.Errorf("tests cannot be generated for registered migrations")
+	}
+	if opts.ModelOnly {
+		if err := checkModelOnly(changes); err != nil {
+			return "", err
+		}
+	}
+	if err := checkStrategy(opts); err != nil {
+		return "", err
+	}
+
+	filename := fmt.Sprintf("%s/%s_%s.go", migrationsDir, version, sanitizeName(name))
 
 	// Generate migration code
-	code := generateMigrationCode(timestamp, name, changes)
+	var code string
+	if opts.Template != "" {
+		up, down := generateMigrationBodies(changes, opts)
+		var err error
+		code, err = renderMigrationTemplate(opts.Template, newMigrationTemplateData(version, name, "Auto-generated migration", opts.Package, changes, up, down))
+		if err != nil {
+			return "", err
+		}
+	} else {
+		var err error
+		code, err = formatGeneratedCode(filename, generateMigrationCode(version, name, changes, opts))
+		if err != nil {
+			return "", err
+		}
+	}
 
 	// Write to file
 	if err := os.MkdirAll(migrationsDir, 0755); err != nil {
@@ -27,21 +169,94 @@ func GenerateMigrationFromChanges(changes []ModelChange, name string, migrations
 	if err := os.WriteFile(filename, []byte(code), 0644); err != nil {
 		return "", fmt.Errorf("failed to write migration file: %w", err)
 	}
+	written := []string{filename}
+
+	if opts.WithTests {
+		testFilename := strings.TrimSuffix(filename, ".go") + "_test.go"
+		testCode, err := formatGeneratedCode(testFilename, generateMigrationTestCode(version, name, changes, opts.PriorModel))
+		if err != nil {
+			os.Remove(filename)
+			return "", err
+		}
+		if err := os.WriteFile(testFilename, []byte(testCode), 0644); err != nil {
+			return "", fmt.Errorf("failed to write migration test file: %w", err)
+		}
+		written = append(written, testFilename)
+	}
+
+	if opts.Verify {
+		if err := vetMigration(written, opts.Package); err != nil {
+			for _, file := range written {
+				os.Remove(file)
+			}
+			return "", err
+		}
+	}
 
 	return filename, nil
 }
 
 // generateMigrationCode generates the Go code for a migration
-func generateMigrationCode(version, name string, changes []ModelChange) string {
+func generateMigrationCode(version, name string, changes []ModelChange, opts GenerateOptions) string {
+	up, down := generateMigrationBodies(changes, opts)
+	funcs := migrationFuncs(up, down)
+
+	if opts.Package != "" {
+		return registeredMigrationCode(opts.Package, version, name, "Auto-generated migration", funcs)
+	}
+	return migrationPreamble(version, name, "Auto-generated migration") + funcs
+}
+
+// generateMigrationBodies generates the bodies of a migration's up and down functions
+func generateMigrationBodies(changes []ModelChange, opts GenerateOptions) (string, string) {
 	var builder strings.Builder
 
-	// Package and imports for standalone executable
-	builder.WriteString(`package main
+	builder.WriteString("\t// Auto-generated migration\n")
+	builder.WriteString("\t// Changes detected:\n")
+
+	for _, change := range changes {
+		builder.WriteString(fmt.Sprintf("\t// - %s\n", change.Details))
+	}
+	if opts.Idempotent {
+		builder.WriteString("\t// Idempotent: each step skips work that is already done, so re-running is safe\n")
+	}
 
-// Migration: ` + sanitizeName(name) + `
-// Version: ` + version + `
-// Auto-generated migration
+	builder.WriteString("\n")
 
+	// Generate up migration code
+	upCode := generateUpMigration(changes, opts)
+	if opts.Strategy == StrategyModelApply {
+		upCode = generateModelApplyUp(changes, opts)
+	}
+	builder.WriteString(upCode)
+	up := builder.String()
+
+	// Generate down migration code
+	downCode := generateDownMigration(changes, opts)
+	if opts.Strategy == StrategyModelApply {
+		downCode = generateModelApplyDown(changes, opts)
+	}
+
+	return up, "\t// Rollback operations\n\n" + downCode
+}
+
+// migrationFuncs wraps the bodies of up and down in their functions
+func migrationFuncs(up, down string) string {
+	return "func up(ctx context.Context, client *omg.Client) error {\n" + up + "\n\treturn nil\n}\n\n" +
+		"func down(ctx context.Context, client *omg.Client) error {\n" + down + "\n\treturn nil\n}\n"
+}
+
+// migrationPreamble returns the package clause, imports and main() shared by
+// generated and scaffolded migrations. description is an optional header line
+func migrationPreamble(version, name, description string) string {
+	header := "// Migration: " + sanitizeName(name) + "\n// Version: " + version + "\n"
+	if description != "" {
+		header += "// " + description + "\n"
+	}
+
+	return `package main
+
+` + header + `
 import (
 	"context"
 	"fmt"
@@ -50,14 +265,18 @@ import (
 	omg "github.com/demetere/omg"
 )
 
+// migrationVersion identifies this migration's backups under .omg/backups
+const migrationVersion = "` + version + `"
+
 func main() {
-	// Get connection info from environment
-	client, err := omg.NewClient(omg.Config{
-		ApiURL:     os.Getenv("OPENFGA_API_URL"),
-		StoreID:    os.Getenv("OPENFGA_STORE_ID"),
-		AuthMethod: getAuthMethod(),
-		APIToken:   os.Getenv("OPENFGA_API_TOKEN"),
-	})
+	// Get connection info from environment, the same way the omg CLI does
+	cfg, err := omg.ConfigFromEnv()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	client, err := omg.NewClient(cfg)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to create client: %v\n", err)
 		os.Exit(1)
@@ -79,82 +298,194 @@ func main() {
 	}
 }
 
-func getAuthMethod() string {
-	if token := os.Getenv("OPENFGA_API_TOKEN"); token != "" {
-		return "token"
-	}
-	return "none"
+`
 }
 
-`)
+// registeredMigrationCode turns the up and down functions of a migration into a file of
+// package pkg that registers them from init(). The functions are renamed after the
+// version (up20251130123456), so many migrations can share the package
+func registeredMigrationCode(pkg, version, name, description, funcs string) string {
+	header := "// Migration: " + sanitizeName(name) + "\n// Version: " + version + "\n"
+	if description != "" {
+		header += "// " + description + "\n"
+	}
 
-	// Up function
-	builder.WriteString("func up(ctx context.Context, client *omg.Client) error {\n")
-	builder.WriteString("\t// Auto-generated migration\n")
-	builder.WriteString("\t// Changes detected:\n")
+	funcs = strings.Replace(funcs, "func up(", "func up"+version+"(", 1)
+	funcs = strings.Replace(funcs, "func down(", "func down"+version+"(", 1)
+	funcs = strings.ReplaceAll(funcs, "migrationVersion", fmt.Sprintf("%q", version))
 
-	for _, change := range changes {
-		builder.WriteString(fmt.Sprintf("\t// - %s\n", change.Details))
+	imports := "\t\"context\"\n"
+	if strings.Contains(funcs, "fmt.") {
+		imports += "\t\"fmt\"\n"
 	}
 
-	builder.WriteString("\n")
+	return `package ` + pkg + `
 
-	// Generate up migration code
-	builder.WriteString(generateUpMigration(changes))
+` + header + `
+import (
+` + imports + `
+	omg "github.com/demetere/omg"
+)
 
-	builder.WriteString("\n\treturn nil\n")
-	builder.WriteString("}\n\n")
+func init() {
+	omg.Register(omg.Migration{
+		Version: "` + version + `",
+		Name:    "` + sanitizeName(name) + `",
+		Up:      up` + version + `,
+		Down:    down` + version + `,
+	})
+}
 
-	// Down function
-	builder.WriteString("func down(ctx context.Context, client *omg.Client) error {\n")
-	builder.WriteString("\t// Rollback operations\n\n")
+` + funcs
+}
 
-	// Generate down migration code
-	builder.WriteString(generateDownMigration(changes))
+// GenerateScaffold writes an empty migration for hand-written changes (omg create)
+// It shares its main() with generated migrations. Returns the file path
+func GenerateScaffold(name string, migrationsDir string) (string, error) {
+	return GenerateScaffoldWithOptions(name, migrationsDir, GenerateOptions{})
+}
 
-	builder.WriteString("\n\treturn nil\n")
-	builder.WriteString("}\n")
+// GenerateScaffoldWithOptions writes an empty migration; only opts.Package and opts.Template apply
+func GenerateScaffoldWithOptions(name string, migrationsDir string, opts GenerateOptions) (string, error) {
+	return writeHandwrittenMigration(name, "", migrationsDir, scaffoldUp, scaffoldDown, opts)
+}
 
-	return builder.String()
+// writeHandwrittenMigration writes a migration with the given up and down bodies, for
+// migrations that do not come from model changes. Only opts.Package and opts.Template apply
+func writeHandwrittenMigration(name, description, migrationsDir, up, down string, opts GenerateOptions) (string, error) {
+	timestamp := time.Now().Format("20060102150405")
+	filename := fmt.Sprintf("%s/%s_%s.go", migrationsDir, timestamp, sanitizeName(name))
+
+	var code string
+	var err error
+	switch {
+	case opts.Template != "":
+		code, err = renderMigrationTemplate(opts.Template, newMigrationTemplateData(timestamp, name, description, opts.Package, nil, up, down))
+	case opts.Package != "":
+		code, err = formatGeneratedCode(filename, registeredMigrationCode(opts.Package, timestamp, name, description, migrationFuncs(up, down)))
+	default:
+		code, err = formatGeneratedCode(filename, migrationPreamble(timestamp, name, description)+migrationFuncs(up, down))
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(migrationsDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create migrations directory: %w", err)
+	}
+	if err := os.WriteFile(filename, []byte(code), 0644); err != nil {
+		return "", fmt.Errorf("failed to write migration file: %w", err)
+	}
+
+	return filename, nil
 }
 
+// Bodies of the empty up and down functions of a scaffolded migration
+const (
+	scaffoldUp = `	// TODO: Implement migration
+	//
+	// Available omg functions:
+	//
+	// MODEL OPERATIONS:
+	// - omg.GetCurrentModel(ctx, client) - Get current model as DSL string
+	// - omg.AddTypeToModel(ctx, client, typeName, relations) - Add a type
+	// - omg.AddRelationToType(ctx, client, typeName, relation, definition) - Add a relation
+	//
+	// TUPLE OPERATIONS:
+	// - omg.RenameRelation(ctx, client, objectType, oldRel, newRel) - Rename relation on all tuples
+	// - omg.RenameType(ctx, client, oldType, newType) - Rename object type on all tuples
+	// - omg.RenameUserType(ctx, client, oldType, newType) - Rename the type where tuples use it as the user
+	// - omg.MoveRelation(ctx, client, fromType, toType, relation) - Move relation tuples to another type
+	// - omg.CopyRelation(ctx, client, objectType, sourceRel, targetRel) - Copy tuples to new relation
+	// - omg.DeleteRelation(ctx, client, objectType, relation) - Delete all tuples with relation
+	// - omg.MigrateRelationWithTransform(ctx, client, objectType, oldRel, newRel, transform) - Custom transform
+	//
+	// READ OPERATIONS:
+	// - omg.ReadAllTuples(ctx, client, objectType, relation) - Read tuples by type/relation
+	// - omg.CountTuples(ctx, client, objectType, relation) - Count matching tuples
+	//
+	// BATCH OPERATIONS:
+	// - omg.WriteTuplesBatch(ctx, client, tuples) - Write tuples in batches
+	// - omg.DeleteTuplesBatch(ctx, client, tuples) - Delete tuples in batches
+	//
+	// UTILITY:
+	// - omg.BackupTuples(ctx, client) - Backup all tuples before migration
+	// - omg.RestoreTuples(ctx, client, tuples) - Restore tuples from backup
+`
+
+	scaffoldDown = `	// TODO: Implement rollback
+	// Reverse the operations from Up
+`
+)
+
 // generateUpMigration generates the up migration code
-func generateUpMigration(changes []ModelChange) string {
+func generateUpMigration(changes []ModelChange, opts GenerateOptions) string {
 	var builder strings.Builder
 
 	// Process changes in order:
-	// 1. Add types
-	// 2. Add relations
-	// 3. Update relations
-	// 4. Rename relations (with tuple migration)
-	// 5. Remove relations (with tuple cleanup)
-	// 6. Rename types (with tuple migration)
-	// 7. Remove types (with tuple cleanup)
+	// 1. Add and update conditions, so relations can use them
+	// 2. Add types
+	// 3. Add relations
+	// 4. Update relations
+	// 5. Rename relations (with tuple migration)
+	// 6. Remove relations (with tuple cleanup)
+	// 7. Rename types (with tuple migration)
+	// 8. Remove types (with tuple cleanup)
+	// 9. Remove conditions, once no relation uses them
 
 	orderedChanges := orderChangesForUp(changes)
+	typeRelations := foldTypeRelations(changes)
 
 	for _, change := range orderedChanges {
 		switch change.Type {
+		case ChangeTypeAddCondition, ChangeTypeUpdateCondition:
+			builder.WriteString(generateSetCondition(change, change.NewValue))
+
+		case ChangeTypeRemoveCondition:
+			builder.WriteString(generateRemoveCondition(change, opts))
+
 		case ChangeTypeAddType:
-			builder.WriteString(generateAddType(change))
+			builder.WriteString(generateAddTypeWithRelations(change, typeRelations[change.TypeName], opts))
 
 		case ChangeTypeAddRelation:
-			builder.WriteString(generateAddRelation(change))
+			if typeRelations.contains(change) {
+				continue
+			}
+			builder.WriteString(generateAddRelation(change, opts))
 
 		case ChangeTypeUpdateRelation:
 			builder.WriteString(generateUpdateRelation(change))
+			if opts.Backfill && !opts.ModelOnly {
+				builder.WriteString(generateRelationBackfill(change))
+			}
+
+		case ChangeTypeUpdateTypeRestrictions:
+			builder.WriteString(generateUpdateTypeRestrictions(change))
 
 		case ChangeTypeRenameRelation:
-			builder.WriteString(generateRenameRelation(change))
+			builder.WriteString(generateRenameRelation(change, opts))
+
+		case ChangeTypeMoveRelation:
+			builder.WriteString(generateMoveRelation(change, opts))
 
 		case ChangeTypeRemoveRelation:
-			builder.WriteString(generateRemoveRelation(change))
+			if opts.ModelOnly {
+				builder.WriteString(generateModelOnlyRemoval(change, opts))
+				continue
+			}
+			builder.WriteString(generateRemovalBackup(change, opts))
+			builder.WriteString(generateRemoveRelation(change, opts))
 
 		case ChangeTypeRenameType:
-			builder.WriteString(generateRenameType(change))
+			builder.WriteString(generateRenameType(change, opts))
 
 		case ChangeTypeRemoveType:
-			builder.WriteString(generateRemoveType(change))
+			if opts.ModelOnly {
+				builder.WriteString(generateModelOnlyRemoval(change, opts))
+				continue
+			}
+			builder.WriteString(generateRemovalBackup(change, opts))
+			builder.WriteString(generateRemoveType(change, opts))
 		}
 	}
 
@@ -162,56 +493,112 @@ func generateUpMigration(changes []ModelChange) string {
 }
 
 // generateDownMigration generates the down migration code (reverse order)
-func generateDownMigration(changes []ModelChange) string {
+func generateDownMigration(changes []ModelChange, opts GenerateOptions) string {
 	var builder strings.Builder
 
 	// Reverse the order for down migration
 	orderedChanges := orderChangesForDown(changes)
+	typeRelations := foldTypeRelations(changes)
 
 	for _, change := range orderedChanges {
 		switch change.Type {
+		case ChangeTypeAddCondition:
+			// Reverse: remove condition
+			builder.WriteString(generateRemoveCondition(change, opts))
+
+		case ChangeTypeUpdateCondition, ChangeTypeRemoveCondition:
+			// Reverse: restore the old condition
+			builder.WriteString(generateSetCondition(change, change.OldValue))
+
 		case ChangeTypeAddType:
 			// Reverse: remove type
 			builder.WriteString(generateRemoveType(ModelChange{
 				TypeName: change.TypeName,
-			}))
+			}, opts))
 
 		case ChangeTypeRemoveType:
-			builder.WriteString("\t// NOTE: Cannot automatically restore removed type\n")
-			builder.WriteString(fmt.Sprintf("\t// You need to manually add type '%s' back\n\n", change.TypeName))
+			if opts.ModelOnly {
+				// Reverse: add the type back; up took no backup of its definition
+				builder.WriteString(generateAddType(change, opts))
+				continue
+			}
+			// Reverse: restore type and tuples from the backup taken by up
+			builder.WriteString(generateRestoreFromBackup(change))
 
 		case ChangeTypeAddRelation:
+			if typeRelations.contains(change) {
+				// Removed with its type
+				continue
+			}
 			// Reverse: remove relation
-			builder.WriteString(generateRemoveRelation(change))
+			builder.WriteString(generateRemoveRelation(change, opts))
 
 		case ChangeTypeRemoveRelation:
-			builder.WriteString("\t// NOTE: Cannot automatically restore removed relation\n")
-			builder.WriteString(fmt.Sprintf("\t// You need to manually add relation '%s.%s' back\n\n", change.TypeName, change.RelationName))
+			if opts.ModelOnly {
+				// Reverse: add the relation back with its old definition
+				builder.WriteString(generateAddRelation(ModelChange{
+					TypeName:     change.TypeName,
+					RelationName: change.RelationName,
+					NewValue:     change.OldValue,
+				}, opts))
+				continue
+			}
+			// Reverse: restore relation and tuples from the backup taken by up
+			builder.WriteString(generateRestoreFromBackup(change))
 
 		case ChangeTypeUpdateRelation:
 			// Reverse: update back to old definition
 			builder.WriteString(generateUpdateRelation(ModelChange{
 				TypeName:     change.TypeName,
 				RelationName: change.RelationName,
+				OldValue:     change.NewValue,
 				NewValue:     change.OldValue, // Swap old and new
 			}))
 
+		case ChangeTypeUpdateTypeRestrictions:
+			// Reverse: restore the old directly related types
+			builder.WriteString(generateUpdateTypeRestrictions(ModelChange{
+				TypeName:     change.TypeName,
+				RelationName: change.RelationName,
+				OldValue:     change.NewValue,
+				NewValue:     change.OldValue,
+			}))
+
 		case ChangeTypeRenameRelation:
+			if change.Confidence == ConfidenceLow {
+				builder.WriteString(generateRestoreRenamed(change.TypeName, change.OldValue))
+				continue
+			}
 			// Reverse: rename back
 			builder.WriteString(generateRenameRelation(ModelChange{
 				TypeName:     change.TypeName,
 				RelationName: change.NewValue,
 				OldValue:     change.NewValue,
 				NewValue:     change.OldValue,
-			}))
+			}, opts))
+
+		case ChangeTypeMoveRelation:
+			// Reverse: move back
+			builder.WriteString(generateMoveRelation(ModelChange{
+				TypeName:     change.TargetType,
+				RelationName: change.RelationName,
+				OldValue:     change.NewValue,
+				NewValue:     change.OldValue,
+				TargetType:   change.TypeName,
+				Confidence:   change.Confidence,
+			}, opts))
 
 		case ChangeTypeRenameType:
+			if change.Confidence == ConfidenceLow {
+				builder.WriteString(generateRestoreRenamed(change.OldValue, ""))
+				continue
+			}
 			// Reverse: rename back
 			builder.WriteString(generateRenameType(ModelChange{
 				TypeName: change.NewValue,
 				OldValue: change.NewValue,
 				NewValue: change.OldValue,
-			}))
+			}, opts))
 		}
 	}
 
@@ -220,54 +607,295 @@ func generateDownMigration(changes []ModelChange) string {
 
 // Code generators for each change type
 
-func generateAddType(change ModelChange) string {
-	return fmt.Sprintf(`	// Add type: %s
-	// TODO: Define relations for this type
-	if err := omg.AddTypeToModel(ctx, client, "%s", map[string]string{
+func generateAddType(change ModelChange, opts GenerateOptions) string {
+	relations := fmt.Sprintf(`"%s", map[string]string{
 		// Add your relations here
 		// "owner": "[user]",
-	}); err != nil {
+	}`, change.TypeName)
+
+	return fmt.Sprintf(`	// Add type: %s
+	// TODO: Define relations for this type
+	if err := %s; err != nil {
+		return fmt.Errorf("failed to add type %s: %%w", err)
+	}
+
+`, change.TypeName, helperCall(opts, "AddTypeToModel", relations), change.TypeName)
+}
+
+// generateAddTypeWithRelations adds a type with the relations folded into it by
+// foldTypeRelations, so the migration runs without editing
+func generateAddTypeWithRelations(change ModelChange, relations []ModelChange, opts GenerateOptions) string {
+	if len(relations) == 0 {
+		return fmt.Sprintf(`	// Add type: %s
+	if err := %s; err != nil {
+		return fmt.Errorf("failed to add type %s: %%w", err)
+	}
+
+`, change.TypeName, helperCall(opts, "AddTypeToModel", fmt.Sprintf(`"%s", nil`, change.TypeName)), change.TypeName)
+	}
+
+	width := 0
+	for _, relation := range relations {
+		width = max(width, len(relation.RelationName))
+	}
+	var entries strings.Builder
+	for _, relation := range relations {
+		key := fmt.Sprintf("%q:", relation.RelationName)
+		fmt.Fprintf(&entries, "\t\t%-*s \"%s\",\n", width+3, key, extractRelationDefinition(relation.NewValue))
+	}
+
+	args := fmt.Sprintf("\"%s\", map[string]string{\n%s\t}", change.TypeName, entries.String())
+	return fmt.Sprintf(`	// Add type: %s
+	if err := %s; err != nil {
 		return fmt.Errorf("failed to add type %s: %%w", err)
 	}
 
-`, change.TypeName, change.TypeName, change.TypeName)
+`, change.TypeName, helperCall(opts, "AddTypeToModel", args), change.TypeName)
 }
 
-func generateAddRelation(change ModelChange) string {
+// typeRelations holds the added relations of each added type that are defined with
+// the type rather than by a separate AddRelationToType
+type typeRelations map[string][]ModelChange
+
+// contains reports whether an add_relation change is defined with its type
+func (t typeRelations) contains(change ModelChange) bool {
+	for _, relation := range t[change.TypeName] {
+		if relation.RelationName == change.RelationName {
+			return true
+		}
+	}
+	return false
+}
+
+// foldTypeRelations picks the added relations of added types that can be defined in
+// AddTypeToModel. A relation stays a separate AddRelationToType when it refers, directly
+// or through other relations of its type, to another added type or to a relation added to
+// an existing type: neither exists yet when the type is added
+func foldTypeRelations(changes []ModelChange) typeRelations {
+	newTypes := make(map[string]bool)
+	added := make(map[string]ModelChange) // "type#relation" -> add_relation change
+	for _, change := range changes {
+		switch change.Type {
+		case ChangeTypeAddType:
+			newTypes[change.TypeName] = true
+		case ChangeTypeAddRelation:
+			added[change.TypeName+"#"+change.RelationName] = change
+		}
+	}
+
+	foldable := make(map[string]bool)
+	for key, change := range added {
+		foldable[key] = newTypes[change.TypeName]
+	}
+
+	// Whether a relation referred to from typeName stops it from being folded
+	blocks := func(typeName, refType, relation string) bool {
+		if refType == typeName {
+			_, exists := added[refType+"#"+relation]
+			return exists && !foldable[refType+"#"+relation]
+		}
+		if newTypes[refType] {
+			return true
+		}
+		_, exists := added[refType+"#"+relation]
+		return relation != "" && exists
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for key, change := range added {
+			if !foldable[key] {
+				continue
+			}
+			refs := parseRelationRefs(change.NewValue)
+			blocked := false
+			for _, typeName := range refs.directTypes {
+				blocked = blocked || blocks(change.TypeName, typeName, "")
+			}
+			for _, userset := range refs.directUsersets {
+				blocked = blocked || blocks(change.TypeName, userset[0], userset[1])
+			}
+			for _, relation := range refs.computed {
+				blocked = blocked || blocks(change.TypeName, change.TypeName, relation)
+			}
+			for _, ttu := range refs.tupleToUserset {
+				tupleset, exists := added[change.TypeName+"#"+ttu[0]]
+				if !exists {
+					continue
+				}
+				for _, typeName := range parseRelationRefs(tupleset.NewValue).directTypes {
+					blocked = blocked || blocks(change.TypeName, typeName, ttu[1])
+				}
+			}
+			if blocked {
+				foldable[key] = false
+				changed = true
+			}
+		}
+	}
+
+	folded := make(typeRelations)
+	for _, change := range changes {
+		if change.Type == ChangeTypeAddRelation && foldable[change.TypeName+"#"+change.RelationName] {
+			folded[change.TypeName] = append(folded[change.TypeName], change)
+		}
+	}
+	return folded
+}
+
+func generateAddRelation(change ModelChange, opts GenerateOptions) string {
 	// Try to extract a readable definition (simplified)
 	def := extractRelationDefinition(change.NewValue)
 
 	return fmt.Sprintf(`	// Add relation: %s.%s
-	if err := omg.AddRelationToType(ctx, client, "%s", "%s", "%s"); err != nil {
+	if err := %s; err != nil {
 		return fmt.Errorf("failed to add relation: %%w", err)
 	}
 
-`, change.TypeName, change.RelationName, change.TypeName, change.RelationName, def)
+`, change.TypeName, change.RelationName,
+		helperCall(opts, "AddRelationToType", fmt.Sprintf(`"%s", "%s", "%s"`, change.TypeName, change.RelationName, def)))
 }
 
 func generateUpdateRelation(change ModelChange) string {
 	def := extractRelationDefinition(change.NewValue)
 
+	comment := ""
+	if change.OldValue != "" {
+		comment = fmt.Sprintf("\t// %s → %s\n", RelationDefinitionDSL(change.OldValue), RelationDefinitionDSL(change.NewValue))
+	}
+
 	return fmt.Sprintf(`	// Update relation: %s.%s
-	if err := omg.UpdateRelationDefinition(ctx, client, "%s", "%s", "%s"); err != nil {
+%s	if err := omg.UpdateRelationDefinition(ctx, client, "%s", "%s", "%s"); err != nil {
 		return fmt.Errorf("failed to update relation: %%w", err)
 	}
 
-`, change.TypeName, change.RelationName, change.TypeName, change.RelationName, def)
+`, change.TypeName, change.RelationName, comment, change.TypeName, change.RelationName, def)
 }
 
-func generateRenameRelation(change ModelChange) string {
+// generateUpdateTypeRestrictions generates the relation update for changed directly related
+// types, noting types that are no longer allowed, since their tuples stay in the store
+func generateUpdateTypeRestrictions(change ModelChange) string {
+	code := generateUpdateRelation(change)
+	removed := RemovedTypeRestrictions(change.OldValue, change.NewValue)
+	if len(removed) == 0 {
+		return code
+	}
+	return fmt.Sprintf("\t// No longer allowed: %s (existing tuples with these users are not deleted)\n", strings.Join(removed, ", ")) + code
+}
+
+// generateSetCondition generates code that adds a condition or replaces its definition
+func generateSetCondition(change ModelChange, definition string) string {
+	return fmt.Sprintf(`	// Set condition: %s
+	if err := omg.SetCondition(ctx, client, %q); err != nil {
+		return fmt.Errorf("failed to set condition: %%w", err)
+	}
+
+`, change.TypeName, definition)
+}
+
+// generateRemoveCondition generates code that removes a condition
+func generateRemoveCondition(change ModelChange, opts GenerateOptions) string {
+	return fmt.Sprintf(`	// Remove condition: %s
+	if err := %s; err != nil {
+		return fmt.Errorf("failed to remove condition: %%w", err)
+	}
+
+`, change.TypeName, helperCall(opts, "RemoveCondition", fmt.Sprintf("%q", change.TypeName)))
+}
+
+// generateRelationBackfill generates a data step for an updated relation that now
+// also grants access through computed relations. A direct tuple is redundant when
+// the same user already holds one of the newly added relations on the same object.
+// Deletion is left commented out so the analysis can be reviewed first.
+func generateRelationBackfill(change ModelChange) string {
+	added := addedComputedRelations(change.OldValue, change.NewValue)
+	if len(added) == 0 {
+		return ""
+	}
+
+	quoted := make([]string, len(added))
+	for i, rel := range added {
+		quoted[i] = fmt.Sprintf("%q", rel)
+	}
+
+	return fmt.Sprintf(`	// Backfill: %s.%s now also includes: %s
+	// Direct tuples whose user already has one of these relations on the same
+	// object are redundant after this change. Only direct tuples are compared.
+	{
+		tuples, err := omg.ReadAllTuples(ctx, client, "%s", "%s")
+		if err != nil {
+			return fmt.Errorf("failed to read tuples: %%w", err)
+		}
+
+		covered := make(map[string]bool)
+		for _, rel := range []string{%s} {
+			related, err := omg.ReadAllTuples(ctx, client, "%s", rel)
+			if err != nil {
+				return fmt.Errorf("failed to read tuples: %%w", err)
+			}
+			for _, t := range related {
+				covered[t.User+"|"+t.Object] = true
+			}
+		}
+
+		var redundant []omg.Tuple
+		for _, t := range tuples {
+			if covered[t.User+"|"+t.Object] {
+				redundant = append(redundant, t)
+			}
+		}
+		fmt.Printf("Found %%d redundant direct tuples on %s.%s\n", len(redundant))
+
+		// Uncomment to delete the redundant direct tuples:
+		// if err := omg.DeleteTuplesBatch(ctx, client, redundant); err != nil {
+		// 	return fmt.Errorf("failed to delete redundant tuples: %%w", err)
+		// }
+	}
+
+`, change.TypeName, change.RelationName, strings.Join(added, ", "),
+		change.TypeName, change.RelationName,
+		strings.Join(quoted, ", "), change.TypeName,
+		change.TypeName, change.RelationName)
+}
+
+// addedComputedRelations returns computed relations that appear as union branches
+// in newDef but not in oldDef. Returns nil unless both definitions keep a direct
+// assignment, since only then do existing direct tuples remain valid.
+func addedComputedRelations(oldDef, newDef string) []string {
+	if !strings.Contains(oldDef, "[") || !strings.Contains(newDef, "[") {
+		return nil
+	}
+
+	existing := make(map[string]bool)
+	for _, term := range strings.Split(oldDef, " or ") {
+		existing[strings.TrimSpace(term)] = true
+	}
+
+	var added []string
+	for _, term := range strings.Split(newDef, " or ") {
+		term = strings.TrimSpace(term)
+		if !existing[term] && relationNamePattern.MatchString(term) {
+			added = append(added, term)
+		}
+	}
+	return added
+}
+
+var relationNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+func generateRenameRelation(change ModelChange, opts GenerateOptions) string {
+	rename := helperCall(opts, "RenameRelation", fmt.Sprintf(`"%s", "%s", "%s"`, change.TypeName, change.OldValue, change.NewValue))
+
 	switch change.Confidence {
 	case ConfidenceHigh:
 		// High confidence: straightforward rename
 		return fmt.Sprintf(`	// Rename relation: %s.%s -> %s.%s (high confidence)
 	// This will copy all tuples from the old relation to the new one
-	if err := omg.RenameRelation(ctx, client, "%s", "%s", "%s"); err != nil {
+	if err := %s; err != nil {
 		return fmt.Errorf("failed to rename relation: %%w", err)
 	}
 
-`, change.TypeName, change.OldValue, change.TypeName, change.NewValue,
-			change.TypeName, change.OldValue, change.NewValue)
+`, change.TypeName, change.OldValue, change.TypeName, change.NewValue, rename)
 
 	case ConfidenceMedium:
 		// Medium confidence: generate rename with review notice
@@ -277,12 +905,11 @@ func generateRenameRelation(change ModelChange) string {
 	// This appears to be a rename. Review and confirm before applying.
 	// If correct, this will preserve all existing tuples.
 	//
-	if err := omg.RenameRelation(ctx, client, "%s", "%s", "%s"); err != nil {
+	if err := %s; err != nil {
 		return fmt.Errorf("failed to rename relation: %%w", err)
 	}
 
-`, change.TypeName, change.OldValue, change.TypeName, change.NewValue,
-			change.TypeName, change.OldValue, change.NewValue)
+`, change.TypeName, change.OldValue, change.TypeName, change.NewValue, rename)
 
 	case ConfidenceLow:
 		// Low confidence: offer both options
@@ -290,12 +917,17 @@ func generateRenameRelation(change ModelChange) string {
 	// Potential relation rename: %s.%s -> %s.%s (low confidence)
 	//
 	// OPTION 1: If this IS a rename (preserve tuples), uncomment:
-	// if err := omg.RenameRelation(ctx, client, "%s", "%s", "%s"); err != nil {
+	// if err := %s; err != nil {
 	// 	return fmt.Errorf("failed to rename relation: %%w", err)
 	// }
 	//
 	// OPTION 2: If these are separate relations (default, safe):
 
+	// Back up the old relation before removing it (restored by down)
+	if _, err := %s; err != nil {
+		return fmt.Errorf("failed to back up %s.%s: %%w", err)
+	}
+
 	// Remove old relation (new relation already in model.fga)
 	tuples, err := omg.ReadAllTuples(ctx, client, "%s", "%s")
 	if err != nil {
@@ -307,32 +939,67 @@ func generateRenameRelation(change ModelChange) string {
 			return fmt.Errorf("failed to delete tuples: %%w", err)
 		}
 	}
-	if err := omg.RemoveRelationFromType(ctx, client, "%s", "%s"); err != nil {
+	if err := %s; err != nil {
 		return fmt.Errorf("failed to remove old relation: %%w", err)
 	}
 
 `, change.TypeName, change.OldValue, change.TypeName, change.NewValue,
-			change.TypeName, change.OldValue, change.NewValue,
+			rename,
+			helperCall(opts, "BackupForRemoval", fmt.Sprintf(`migrationVersion, "%s", "%s"`, change.TypeName, change.OldValue)),
+			change.TypeName, change.OldValue,
 			change.TypeName, change.OldValue,
 			change.TypeName, change.OldValue,
-			change.TypeName, change.OldValue)
+			helperCall(opts, "RemoveRelationFromType", fmt.Sprintf(`"%s", "%s"`, change.TypeName, change.OldValue)))
 
 	default:
 		// Fallback
 		return fmt.Sprintf(`	// Rename relation: %s.%s -> %s.%s
-	if err := omg.RenameRelation(ctx, client, "%s", "%s", "%s"); err != nil {
+	if err := %s; err != nil {
 		return fmt.Errorf("failed to rename relation: %%w", err)
 	}
 
-`, change.TypeName, change.OldValue, change.TypeName, change.NewValue,
-			change.TypeName, change.OldValue, change.NewValue)
+`, change.TypeName, change.OldValue, change.TypeName, change.NewValue, rename)
 	}
 }
 
-func generateRemoveRelation(change ModelChange) string {
+// generateMoveRelation adds the relation to the target type, moves its tuples there and
+// removes it from the source type
+func generateMoveRelation(change ModelChange, opts GenerateOptions) string {
+	header := fmt.Sprintf(`	// Move relation: %s.%s -> %s.%s (high confidence)
+	// Tuples keep their object IDs: %s:<id> becomes %s:<id>
+`, change.TypeName, change.RelationName, change.TargetType, change.RelationName,
+		change.TypeName, change.TargetType)
+	if change.Confidence != ConfidenceHigh {
+		header = fmt.Sprintf(`	// ⚠️  REVIEW REQUIRED: Possible relation move
+	// Detected: %s.%s -> %s.%s
+	//
+	// Tuples keep their object IDs: %s:<id> becomes %s:<id>. Review and confirm
+	// the objects exist under the new type before applying.
+	//
+`, change.TypeName, change.RelationName, change.TargetType, change.RelationName,
+			change.TypeName, change.TargetType)
+	}
+
+	return header + fmt.Sprintf(`	if err := %s; err != nil {
+		return fmt.Errorf("failed to add relation: %%w", err)
+	}
+	if err := %s; err != nil {
+		return fmt.Errorf("failed to move relation: %%w", err)
+	}
+	if err := %s; err != nil {
+		return fmt.Errorf("failed to remove old relation: %%w", err)
+	}
+
+`, helperCall(opts, "AddRelationToType", fmt.Sprintf(`"%s", "%s", "%s"`,
+		change.TargetType, change.RelationName, extractRelationDefinition(change.NewValue))),
+		helperCall(opts, "MoveRelation", fmt.Sprintf(`"%s", "%s", "%s"`, change.TypeName, change.TargetType, change.RelationName)),
+		helperCall(opts, "RemoveRelationFromType", fmt.Sprintf(`"%s", "%s"`, change.TypeName, change.RelationName)))
+}
+
+func generateRemoveRelation(change ModelChange, opts GenerateOptions) string {
 	return fmt.Sprintf(`	// Remove relation: %s.%s
 	// Step 1: Remove from model
-	if err := omg.RemoveRelationFromType(ctx, client, "%s", "%s"); err != nil {
+	if err := %s; err != nil {
 		return fmt.Errorf("failed to remove relation from model: %%w", err)
 	}
 
@@ -341,21 +1008,63 @@ func generateRemoveRelation(change ModelChange) string {
 		return fmt.Errorf("failed to delete tuples: %%w", err)
 	}
 
-`, change.TypeName, change.RelationName, change.TypeName, change.RelationName,
+`, change.TypeName, change.RelationName,
+		helperCall(opts, "RemoveRelationFromType", fmt.Sprintf(`"%s", "%s"`, change.TypeName, change.RelationName)),
 		change.TypeName, change.RelationName)
 }
 
-func generateRenameType(change ModelChange) string {
+// generateRemovalBackup backs up a type's definition and the tuples about to be
+// deleted, so down can restore them
+func generateRemovalBackup(change ModelChange, opts GenerateOptions) string {
+	target := change.TypeName
+	if change.RelationName != "" {
+		target += "." + change.RelationName
+	}
+
+	return fmt.Sprintf(`	// Back up %s before removing it (restored by down)
+	if _, err := %s; err != nil {
+		return fmt.Errorf("failed to back up %s: %%w", err)
+	}
+
+`, target, helperCall(opts, "BackupForRemoval", fmt.Sprintf(`migrationVersion, "%s", "%s"`, change.TypeName, change.RelationName)), target)
+}
+
+// generateRestoreFromBackup restores a removed type or relation from the backup taken by up
+func generateRestoreFromBackup(change ModelChange) string {
+	target := change.TypeName
+	if change.RelationName != "" {
+		target += "." + change.RelationName
+	}
+
+	return fmt.Sprintf(`	// Restore %s and its tuples from the backup taken by up
+	if err := omg.RestoreFromBackup(ctx, client, omg.BackupFilePath(migrationVersion, "%s", "%s")); err != nil {
+		return fmt.Errorf("failed to restore %s: %%w", err)
+	}
+
+`, target, change.TypeName, change.RelationName, target)
+}
+
+// generateRestoreRenamed restores the type or relation a low confidence rename removed
+// as a separate one, from the backup taken by up
+func generateRestoreRenamed(typeName, relation string) string {
+	return "\t// Low confidence rename: up backed up and removed the old one (OPTION 2).\n" +
+		"\t// If you chose OPTION 1 there, rename back here instead.\n" +
+		generateRestoreFromBackup(ModelChange{TypeName: typeName, RelationName: relation})
+}
+
+func generateRenameType(change ModelChange, opts GenerateOptions) string {
+	rename := helperCall(opts, "RenameType", fmt.Sprintf(`"%s", "%s"`, change.OldValue, change.NewValue))
+
 	switch change.Confidence {
 	case ConfidenceHigh:
 		// High confidence: generate rename with minimal comments
 		return fmt.Sprintf(`	// Rename type: %s -> %s (high confidence rename detected)
 	// This will migrate all existing tuples to the new type name
-	if err := omg.RenameType(ctx, client, "%s", "%s"); err != nil {
+	if err := %s; err != nil {
 		return fmt.Errorf("failed to rename type: %%w", err)
 	}
 
-`, change.OldValue, change.NewValue, change.OldValue, change.NewValue)
+`, change.OldValue, change.NewValue, rename)
 
 	case ConfidenceMedium:
 		// Medium confidence: generate rename but warn user to review
@@ -366,11 +1075,11 @@ func generateRenameType(change ModelChange) string {
 	// If this IS a rename (preserving tuples), keep the code below.
 	// If these are separate types, replace with AddType + DeleteType operations.
 	//
-	if err := omg.RenameType(ctx, client, "%s", "%s"); err != nil {
+	if err := %s; err != nil {
 		return fmt.Errorf("failed to rename type: %%w", err)
 	}
 
-`, change.OldValue, change.NewValue, change.OldValue, change.NewValue)
+`, change.OldValue, change.NewValue, rename)
 
 	case ConfidenceLow:
 		// Low confidence: generate commented-out rename with add+remove as default
@@ -378,7 +1087,7 @@ func generateRenameType(change ModelChange) string {
 	// Detected potential rename: %s -> %s (low confidence)
 	//
 	// OPTION 1: If this IS a rename (preserve tuples), uncomment:
-	// if err := omg.RenameType(ctx, client, "%s", "%s"); err != nil {
+	// if err := %s; err != nil {
 	// 	return fmt.Errorf("failed to rename type: %%w", err)
 	// }
 	//
@@ -387,6 +1096,11 @@ func generateRenameType(change ModelChange) string {
 	// Add new type (already in model.fga)
 	// The new model definition is already applied
 
+	// Back up the old type before removing it (restored by down)
+	if _, err := %s; err != nil {
+		return fmt.Errorf("failed to back up %s: %%w", err)
+	}
+
 	// Delete old type and its tuples
 	tuples, err := omg.ReadAllTuples(ctx, client, "%s", "")
 	if err != nil {
@@ -398,25 +1112,27 @@ func generateRenameType(change ModelChange) string {
 			return fmt.Errorf("failed to delete tuples: %%w", err)
 		}
 	}
-	if err := omg.RemoveTypeFromModel(ctx, client, "%s"); err != nil {
+	if err := %s; err != nil {
 		return fmt.Errorf("failed to remove old type: %%w", err)
 	}
 
-`, change.OldValue, change.NewValue, change.OldValue, change.NewValue,
-			change.OldValue, change.OldValue, change.OldValue)
+`, change.OldValue, change.NewValue, rename,
+			helperCall(opts, "BackupForRemoval", fmt.Sprintf(`migrationVersion, "%s", ""`, change.OldValue)), change.OldValue,
+			change.OldValue, change.OldValue,
+			helperCall(opts, "RemoveTypeFromModel", fmt.Sprintf(`"%s"`, change.OldValue)))
 
 	default:
 		// Fallback to simple rename
 		return fmt.Sprintf(`	// Rename type: %s -> %s
-	if err := omg.RenameType(ctx, client, "%s", "%s"); err != nil {
+	if err := %s; err != nil {
 		return fmt.Errorf("failed to rename type: %%w", err)
 	}
 
-`, change.OldValue, change.NewValue, change.OldValue, change.NewValue)
+`, change.OldValue, change.NewValue, rename)
 	}
 }
 
-func generateRemoveType(change ModelChange) string {
+func generateRemoveType(change ModelChange, opts GenerateOptions) string {
 	return fmt.Sprintf(`	// Remove type: %s
 	// Step 1: Delete all tuples of this type
 	{
@@ -434,11 +1150,11 @@ func generateRemoveType(change ModelChange) string {
 	}
 
 	// Step 2: Remove type from model
-	if err := omg.RemoveTypeFromModel(ctx, client, "%s"); err != nil {
+	if err := %s; err != nil {
 		return fmt.Errorf("failed to remove type from model: %%w", err)
 	}
 
-`, change.TypeName, change.TypeName, change.TypeName, change.TypeName)
+`, change.TypeName, change.TypeName, change.TypeName, helperCall(opts, "RemoveTypeFromModel", fmt.Sprintf(`"%s"`, change.TypeName)))
 }
 
 // Helper functions
@@ -465,7 +1181,7 @@ func extractRelationDefinition(serialized string) string {
 		return "[user]" // fallback
 	}
 	// Escape quotes for embedding in Go string literal
-	return strings.ReplaceAll(serialized, `"`, `\"`)
+	return strings.ReplaceAll(RelationDefinitionDSL(serialized), `"`, `\"`)
 }
 
 func orderChangesForUp(changes []ModelChange) []ModelChange {
@@ -473,13 +1189,18 @@ func orderChangesForUp(changes []ModelChange) []ModelChange {
 
 	// Order: add types, add relations (sorted by dependency), update relations, renames, removes
 	order := []ChangeType{
+		ChangeTypeAddCondition,
+		ChangeTypeUpdateCondition,
 		ChangeTypeAddType,
 		ChangeTypeAddRelation,
 		ChangeTypeUpdateRelation,
+		ChangeTypeUpdateTypeRestrictions,
 		ChangeTypeRenameRelation,
+		ChangeTypeMoveRelation,
 		ChangeTypeRenameType,
 		ChangeTypeRemoveRelation,
 		ChangeTypeRemoveType,
+		ChangeTypeRemoveCondition,
 	}
 
 	for _, changeType := range order {
@@ -505,198 +1226,174 @@ func orderChangesForUp(changes []ModelChange) []ModelChange {
 	return ordered
 }
 
-// sortRelationChanges sorts relation changes by dependency across all types
-func sortRelationChanges(changes []ModelChange) []ModelChange {
-	// Build a map of all relations for dependency analysis
-	relations := make(map[string]string) // "type.relation" -> definition
-	changeMap := make(map[string]ModelChange) // "type.relation" -> change
+// relationRefs is what a relation definition refers to
+type relationRefs struct {
+	computed       []string    // Same-type relations, including tuplesets ("owner", "parent" in "viewer from parent")
+	tupleToUserset [][2]string // {tupleset, computed relation} pairs, e.g. {"parent", "viewer"}
+	directTypes    []string    // Directly related types, e.g. "user" for [user] or [user:*]
+	directUsersets [][2]string // {type, relation} pairs for [team#member]
+}
 
-	for _, change := range changes {
-		key := fmt.Sprintf("%s.%s", change.TypeName, change.RelationName)
-		relations[key] = change.NewValue
-		changeMap[key] = change
-	}
-
-	// Build dependency graph
-	deps := make(map[string][]string) // relation -> dependencies
-
-	for key, relDef := range relations {
-		parts := strings.Split(key, ".")
-		typeName := parts[0]
-		relName := parts[1]
-
-		// Extract dependencies
-		depNames := extractRelationDependenciesFromDef(relDef, relName)
-
-		// Convert to full keys (type.relation)
-		var fullDeps []string
-
-		// Special handling for tuple-to-userset (e.g., "member from team")
-		if strings.Contains(relDef, " from ") {
-			parts := strings.Split(relDef, " from ")
-			if len(parts) == 2 {
-				computedUserset := strings.TrimSpace(parts[0])
-				tuplesetType := strings.TrimSpace(parts[1])
-				// The dependency is on tuplesetType.computedUserset
-				depKey := fmt.Sprintf("%s.%s", tuplesetType, computedUserset)
-				if _, exists := relations[depKey]; exists {
-					fullDeps = append(fullDeps, depKey)
-				}
-			}
-		} else if strings.Contains(relDef, "->") {
-			// Handle arrow syntax: "parent->owner"
-			parts := strings.Split(relDef, "->")
-			if len(parts) == 2 {
-				_ = strings.TrimSpace(parts[0]) // tuplesetRel - not used for dependency
-				computedUserset := strings.TrimSpace(parts[1])
-				// The dependency is on typeName.computedUserset
-				depKey := fmt.Sprintf("%s.%s", typeName, computedUserset)
-				if _, exists := relations[depKey]; exists {
-					fullDeps = append(fullDeps, depKey)
-				}
-			}
-		} else {
-			// Regular dependencies (e.g., "owner", "[user] or owner")
-			for _, depName := range depNames {
-				// Check if it exists in same type first
-				sameTypeKey := fmt.Sprintf("%s.%s", typeName, depName)
-				if _, exists := relations[sameTypeKey]; exists {
-					fullDeps = append(fullDeps, sameTypeKey)
-				} else {
-					// Could be a cross-type reference, search all types
-					for otherKey := range relations {
-						if strings.HasSuffix(otherKey, "."+depName) {
-							fullDeps = append(fullDeps, otherKey)
-						}
-					}
-				}
+// parseRelationRefs extracts the relations and types a DSL relation definition refers to
+// e.g. "[user, team#member] or owner or viewer from parent"
+func parseRelationRefs(relDef string) relationRefs {
+	var refs relationRefs
+
+	// Type restrictions
+	for _, match := range typeRestrictionPattern.FindAllStringSubmatch(relDef, -1) {
+		for _, restriction := range strings.Split(match[1], ",") {
+			restriction, _, _ = strings.Cut(strings.TrimSpace(restriction), " with ")
+			restriction = strings.TrimSuffix(restriction, ":*")
+			if typeName, relation, found := strings.Cut(restriction, "#"); found {
+				refs.directUsersets = append(refs.directUsersets, [2]string{typeName, relation})
+			} else if restriction != "" {
+				refs.directTypes = append(refs.directTypes, restriction)
 			}
 		}
-		deps[key] = fullDeps
 	}
+	rest := typeRestrictionPattern.ReplaceAllString(relDef, " ")
 
-	// Topological sort
-	sorted := topologicalSort(deps, relations)
+	words := strings.FieldsFunc(rest, func(r rune) bool {
+		return r == ' ' || r == '(' || r == ')' || r == ','
+	})
+	for i := 0; i < len(words); i++ {
+		word := words[i]
+
+		// Tuple-to-userset: "viewer from parent"
+		if i+2 < len(words) && words[i+1] == "from" {
+			refs.tupleToUserset = append(refs.tupleToUserset, [2]string{words[i+2], word})
+			refs.computed = append(refs.computed, words[i+2])
+			i += 2
+			continue
+		}
 
-	// Convert back to changes
-	var result []ModelChange
-	for _, key := range sorted {
-		if change, exists := changeMap[key]; exists {
-			result = append(result, change)
+		// Arrow syntax: "parent->viewer"
+		if tupleset, computed, found := strings.Cut(word, "->"); found {
+			refs.tupleToUserset = append(refs.tupleToUserset, [2]string{tupleset, computed})
+			refs.computed = append(refs.computed, tupleset)
+			continue
 		}
+
+		switch word {
+		case "or", "and", "but", "not", "from":
+			continue
+		}
+		refs.computed = append(refs.computed, word)
 	}
 
-	return result
+	return refs
 }
 
-// topologicalSort performs topological sort on the dependency graph
-func topologicalSort(deps map[string][]string, allNodes map[string]string) []string {
-	inDegree := make(map[string]int)
-	for node := range allNodes {
-		inDegree[node] = 0
-	}
+// typeRestrictionPattern matches a bracketed type restriction list such as [user, team#member]
+var typeRestrictionPattern = regexp.MustCompile(`\[([^\]]*)\]`)
 
-	// Count incoming edges: if node X depends on Y, then X has an incoming edge
-	for node, dependencies := range deps {
-		inDegree[node] = len(dependencies)
+// sortRelationChanges sorts added relations so each comes after the added relations it refers to,
+// across all types: same-type computed relations, [type#relation] restrictions and the
+// relations reached through tuple-to-userset. Types are added before any relation, so only
+// relation-to-relation dependencies matter. Ties and cycles keep the input order
+func sortRelationChanges(changes []ModelChange) []ModelChange {
+	index := make(map[string]int, len(changes)) // "type#relation" -> position in changes
+	for i, change := range changes {
+		index[change.TypeName+"#"+change.RelationName] = i
 	}
 
-	// Find nodes with no incoming edges
-	var queue []string
-	for node, degree := range inDegree {
-		if degree == 0 {
-			queue = append(queue, node)
+	deps := make([][]int, len(changes))
+	var soft [][2]int // Guessed dependencies, added only when they don't form a cycle
+	for i, change := range changes {
+		refs := parseRelationRefs(change.NewValue)
+		seen := map[int]bool{i: true}
+		addDep := func(typeName, relation string) {
+			if j, exists := index[typeName+"#"+relation]; exists && !seen[j] {
+				seen[j] = true
+				deps[i] = append(deps[i], j)
+			}
 		}
-	}
-
-	var sorted []string
-	for len(queue) > 0 {
-		current := queue[0]
-		queue = queue[1:]
-		sorted = append(sorted, current)
 
-		// Reduce in-degree for dependents
-		for dependent, dependencies := range deps {
-			for _, dep := range dependencies {
-				if dep == current {
-					inDegree[dependent]--
-					if inDegree[dependent] == 0 {
-						queue = append(queue, dependent)
-					}
+		for _, relation := range refs.computed {
+			addDep(change.TypeName, relation)
+		}
+		for _, userset := range refs.directUsersets {
+			addDep(userset[0], userset[1])
+		}
+		for _, ttu := range refs.tupleToUserset {
+			tupleset, computed := ttu[0], ttu[1]
+			if j, exists := index[change.TypeName+"#"+tupleset]; exists {
+				// The tupleset is added here too, so its types are known
+				for _, typeName := range parseRelationRefs(changes[j].NewValue).directTypes {
+					addDep(typeName, computed)
+				}
+				continue
+			}
+			// The tupleset already exists, so its types are unknown: prefer to add the
+			// relation in any type that adds it first, unless that would form a cycle
+			for j, other := range changes {
+				if other.RelationName == computed && !seen[j] {
+					soft = append(soft, [2]int{i, j})
 				}
 			}
 		}
 	}
 
-	// If we couldn't sort everything (cycle), append remaining
-	if len(sorted) < len(allNodes) {
-		for node := range allNodes {
-			found := false
-			for _, s := range sorted {
-				if s == node {
-					found = true
+	for _, edge := range soft {
+		if !dependsOn(deps, edge[1], edge[0]) {
+			deps[edge[0]] = append(deps[edge[0]], edge[1])
+		}
+	}
+
+	// Kahn's algorithm, always taking the earliest ready change so the result is deterministic
+	done := make([]bool, len(changes))
+	result := make([]ModelChange, 0, len(changes))
+	for len(result) < len(changes) {
+		next := -1
+		for i := range changes {
+			if done[i] {
+				continue
+			}
+			ready := true
+			for _, j := range deps[i] {
+				if !done[j] {
+					ready = false
 					break
 				}
 			}
-			if !found {
-				sorted = append(sorted, node)
+			if ready {
+				next = i
+				break
 			}
 		}
+		if next == -1 {
+			// Cycle: fall back to the earliest remaining change
+			for i := range changes {
+				if !done[i] {
+					next = i
+					break
+				}
+			}
+		}
+		done[next] = true
+		result = append(result, changes[next])
 	}
 
-	return sorted
+	return result
 }
 
-// extractRelationDependenciesFromDef extracts relation names from a definition
-// Returns relation names that this definition depends on
-// For "member from team", returns ["member"] (the computed userset, not the tupleset)
-// The tupleset ("team") is handled separately as it's just a type reference
-func extractRelationDependenciesFromDef(relDef, currentRel string) []string {
-	var deps []string
-
-	// Handle tuple-to-userset with 'from' syntax: "member from team"
-	// This depends on the "member" relation (computedUserset), not "team" (which is the tupleset type)
-	// The actual dependency will be resolved later as "team.member"
-	if strings.Contains(relDef, " from ") {
-		parts := strings.Split(relDef, " from ")
-		if len(parts) == 2 {
-			computedUserset := strings.TrimSpace(parts[0])
-			// The computed userset is the relation we depend on
-			deps = append(deps, computedUserset)
-			return deps
+// dependsOn reports whether change from transitively depends on change to
+func dependsOn(deps [][]int, from, to int) bool {
+	visited := make([]bool, len(deps))
+	stack := []int{from}
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if current == to {
+			return true
 		}
-	}
-
-	// Handle arrow syntax: "parent->owner"
-	// This depends on the "owner" relation
-	if strings.Contains(relDef, "->") {
-		parts := strings.Split(relDef, "->")
-		if len(parts) == 2 {
-			computedUserset := strings.TrimSpace(parts[1])
-			deps = append(deps, computedUserset)
-			return deps
-		}
-	}
-
-	// Extract words that could be relation names
-	words := strings.FieldsFunc(relDef, func(r rune) bool {
-		return r == ' ' || r == ',' || r == ':' || r == '#'
-	})
-
-	for _, word := range words {
-		// Skip keywords and the relation itself
-		if word == "or" || word == "and" || word == "but" || word == "not" ||
-			word == "from" || word == "define" || word == currentRel ||
-			strings.HasPrefix(word, "[") || strings.HasSuffix(word, "]") ||
-			strings.Contains(word, "[") || strings.Contains(word, "]") {
+		if visited[current] {
 			continue
 		}
-
-		// This might be a relation dependency
-		deps = append(deps, word)
+		visited[current] = true
+		stack = append(stack, deps[current]...)
 	}
-
-	return deps
+	return false
 }
 
 func orderChangesForDown(changes []ModelChange) []ModelChange {
@@ -1,9 +1,13 @@
 package omg_test
 
 import (
+	"encoding/json"
 	"os"
+	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/demetere/omg/pkg"
 	"github.com/stretchr/testify/assert"
@@ -51,6 +55,46 @@ func TestGenerateMigrationFromChanges_AddType(t *testing.T) {
 	assert.Contains(t, code, "func down(")
 }
 
+func TestGenerateMigrationFromChanges_AddTypeWithRelations(t *testing.T) {
+	changes := []omg.ModelChange{
+		{Type: omg.ChangeTypeAddType, TypeName: "folder", Details: "New type 'folder' with 4 relations"},
+		{Type: omg.ChangeTypeAddRelation, TypeName: "folder", RelationName: "owner", NewValue: "[user]"},
+		{Type: omg.ChangeTypeAddRelation, TypeName: "folder", RelationName: "parent", NewValue: "[folder]"},
+		{Type: omg.ChangeTypeAddRelation, TypeName: "folder", RelationName: "viewer", NewValue: "[user] or owner or viewer from parent"},
+		{Type: omg.ChangeTypeAddRelation, TypeName: "folder", RelationName: "approver", NewValue: "[document#reviewer]"},
+		{Type: omg.ChangeTypeAddRelation, TypeName: "document", RelationName: "reviewer", NewValue: "[user]"},
+	}
+
+	filename, err := omg.GenerateMigrationFromChanges(changes, "add_folder", "migrations")
+	require.NoError(t, err)
+	defer os.Remove(filename)
+
+	content, err := os.ReadFile(filename)
+	require.NoError(t, err)
+
+	code := string(content)
+	downStart := strings.Index(code, "func down(")
+	upCode, downCode := code[:downStart], code[downStart:]
+
+	// The type is added with its relations
+	assert.Contains(t, upCode, `omg.AddTypeToModel(ctx, client, "folder", map[string]string{
+		"owner":  "[user]",
+		"parent": "[folder]",
+		"viewer": "[user] or owner or viewer from parent",
+	})`)
+	assert.NotContains(t, upCode, "TODO")
+	assert.NotContains(t, upCode, `omg.AddRelationToType(ctx, client, "folder", "owner"`)
+
+	// A relation to a relation added to another type is added once that exists
+	assert.Contains(t, upCode, `omg.AddRelationToType(ctx, client, "folder", "approver", "[document#reviewer]")`)
+	assert.Contains(t, upCode, `omg.AddRelationToType(ctx, client, "document", "reviewer", "[user]")`)
+
+	// Relations defined with the type are removed with it
+	assert.NotContains(t, downCode, `omg.RemoveRelationFromType(ctx, client, "folder", "owner")`)
+	assert.Contains(t, downCode, `omg.RemoveRelationFromType(ctx, client, "folder", "approver")`)
+	assert.Contains(t, downCode, `omg.RemoveTypeFromModel(ctx, client, "folder")`)
+}
+
 func TestGenerateMigrationFromChanges_AddRelation(t *testing.T) {
 	changes := []omg.ModelChange{
 		{
@@ -201,6 +245,47 @@ func TestGenerateMigrationFromChanges_RenameType_LowConfidence(t *testing.T) {
 	// Default safe option should be active (delete old type)
 	assert.Contains(t, code, "tuples, err := omg.ReadAllTuples")
 	assert.Contains(t, code, "DeleteTuplesBatch")
+
+	// The old type is backed up before it is deleted, and down restores it
+	downStart := strings.Index(code, "func down(")
+	backupIndex := strings.Index(code, `omg.BackupForRemoval(ctx, client, migrationVersion, "grp", "")`)
+	require.NotEqual(t, -1, backupIndex)
+	assert.Less(t, backupIndex, strings.Index(code, "DeleteTuplesBatch"))
+	assert.Contains(t, code[downStart:], `omg.RestoreFromBackup(ctx, client, omg.BackupFilePath(migrationVersion, "grp", ""))`)
+	assert.NotContains(t, code[downStart:], "omg.RenameType")
+}
+
+func TestGenerateMigrationFromChanges_RenameRelation_LowConfidence(t *testing.T) {
+	changes := []omg.ModelChange{
+		{
+			Type:         "rename_relation",
+			TypeName:     "document",
+			RelationName: "reader",
+			OldValue:     "reader",
+			NewValue:     "auditor",
+			Confidence:   "low",
+			Details:      "Potential rename: 'document.reader' -> 'document.auditor' (low confidence)",
+		},
+	}
+
+	filename, err := omg.GenerateMigrationFromChanges(changes, "rename_reader", "migrations")
+	require.NoError(t, err)
+	defer os.Remove(filename)
+
+	content, err := os.ReadFile(filename)
+	require.NoError(t, err)
+
+	code := string(content)
+	downStart := strings.Index(code, "func down(")
+	upCode, downCode := code[:downStart], code[downStart:]
+
+	backupIndex := strings.Index(upCode, `omg.BackupForRemoval(ctx, client, migrationVersion, "document", "reader")`)
+	require.NotEqual(t, -1, backupIndex)
+	assert.Less(t, backupIndex, strings.Index(upCode, "DeleteTuplesBatch"))
+
+	// down restores the deleted relation instead of renaming back
+	assert.Contains(t, downCode, `omg.RestoreFromBackup(ctx, client, omg.BackupFilePath(migrationVersion, "document", "reader"))`)
+	assert.NotContains(t, downCode, "omg.RenameRelation")
 }
 
 func TestGenerateMigrationFromChanges_RenameRelation_HighConfidence(t *testing.T) {
@@ -231,6 +316,42 @@ func TestGenerateMigrationFromChanges_RenameRelation_HighConfidence(t *testing.T
 	assert.Contains(t, code, "RenameRelation")
 }
 
+func TestGenerateMigrationFromChanges_MoveRelation(t *testing.T) {
+	changes := []omg.ModelChange{
+		{
+			Type:         omg.ChangeTypeMoveRelation,
+			TypeName:     "team",
+			RelationName: "can_invite",
+			OldValue:     "[user] or admin",
+			NewValue:     "[user] or admin",
+			TargetType:   "organization",
+			Confidence:   omg.ConfidenceHigh,
+			Details:      "Move detected: 'team.can_invite' -> 'organization.can_invite' (high confidence: identical definition)",
+		},
+	}
+
+	filename, err := omg.GenerateMigrationFromChanges(changes, "move_relation", "migrations")
+	require.NoError(t, err)
+	defer os.Remove(filename)
+
+	content, err := os.ReadFile(filename)
+	require.NoError(t, err)
+
+	code := string(content)
+	downStart := strings.Index(code, "func down(")
+	upCode, downCode := code[:downStart], code[downStart:]
+
+	assert.Contains(t, upCode, "Move relation: team.can_invite -> organization.can_invite (high confidence)")
+	assert.Contains(t, upCode, `omg.AddRelationToType(ctx, client, "organization", "can_invite", "[user] or admin")`)
+	assert.Contains(t, upCode, `omg.MoveRelation(ctx, client, "team", "organization", "can_invite")`)
+	assert.Contains(t, upCode, `omg.RemoveRelationFromType(ctx, client, "team", "can_invite")`)
+	assert.NotContains(t, upCode, "DeleteRelation(")
+
+	assert.Contains(t, downCode, `omg.AddRelationToType(ctx, client, "team", "can_invite", "[user] or admin")`)
+	assert.Contains(t, downCode, `omg.MoveRelation(ctx, client, "organization", "team", "can_invite")`)
+	assert.Contains(t, downCode, `omg.RemoveRelationFromType(ctx, client, "organization", "can_invite")`)
+}
+
 func TestGenerateMigrationFromChanges_UpdateRelation(t *testing.T) {
 	changes := []omg.ModelChange{
 		{
@@ -255,6 +376,37 @@ func TestGenerateMigrationFromChanges_UpdateRelation(t *testing.T) {
 	// Verify update relation code
 	assert.Contains(t, code, "Update relation: document.viewer")
 	assert.Contains(t, code, "UpdateRelationDefinition")
+
+	// JSON usersets are rendered as DSL in comments and calls
+	assert.Contains(t, code, "// [user] → owner")
+	assert.Contains(t, code, `UpdateRelationDefinition(ctx, client, "document", "viewer", "owner")`)
+	assert.Contains(t, code, "// owner → [user]")
+}
+
+func TestGenerateMigrationFromChanges_UpdateTypeRestrictions(t *testing.T) {
+	changes := []omg.ModelChange{
+		{
+			Type:         omg.ChangeTypeUpdateTypeRestrictions,
+			TypeName:     "document",
+			RelationName: "viewer",
+			OldValue:     "[user, group#member]",
+			NewValue:     "[user]",
+			Details:      "Updated type restrictions of 'document.viewer': [user, group#member] → [user]",
+		},
+	}
+
+	filename, err := omg.GenerateMigrationFromChanges(changes, "narrow_viewer", "migrations")
+	require.NoError(t, err)
+	defer os.Remove(filename)
+
+	content, err := os.ReadFile(filename)
+	require.NoError(t, err)
+
+	code := string(content)
+	assert.Contains(t, code, "// No longer allowed: group#member (existing tuples with these users are not deleted)")
+	assert.Contains(t, code, `UpdateRelationDefinition(ctx, client, "document", "viewer", "[user]")`)
+	assert.Contains(t, code, `UpdateRelationDefinition(ctx, client, "document", "viewer", "[user, group#member]")`)
+	assert.Equal(t, 1, strings.Count(code, "No longer allowed"), "widening back in down removes nothing")
 }
 
 func TestGenerateMigrationFromChanges_RemoveRelation(t *testing.T) {
@@ -363,6 +515,48 @@ func TestGenerateMigrationFromChanges_OperationOrdering(t *testing.T) {
 	assert.True(t, removeRelPos < removeTypePos, "Remove relation should come before remove type")
 }
 
+func TestGenerateMigrationFromChanges_CrossTypeRelationOrdering(t *testing.T) {
+	// Listed so that every relation comes before the relations it refers to
+	changes := []omg.ModelChange{
+		{Type: "add_relation", TypeName: "document", RelationName: "viewer", NewValue: "[team#member] or viewer from parent", Details: "Add document.viewer"},
+		{Type: "add_relation", TypeName: "document", RelationName: "parent", NewValue: "[folder]", Details: "Add document.parent"},
+		{Type: "add_relation", TypeName: "folder", RelationName: "viewer", NewValue: "[user] or viewer from parent", Details: "Add folder.viewer"},
+		{Type: "add_relation", TypeName: "team", RelationName: "member", NewValue: "[user]", Details: "Add team.member"},
+		{Type: "add_type", TypeName: "team", Details: "Add team"},
+		{Type: "add_type", TypeName: "folder", Details: "Add folder"},
+	}
+
+	filename, err := omg.GenerateMigrationFromChanges(changes, "cross_type", "migrations")
+	require.NoError(t, err)
+	defer os.Remove(filename)
+
+	content, err := os.ReadFile(filename)
+	require.NoError(t, err)
+
+	code := string(content)
+	upCode := code[strings.Index(code, "func up("):strings.Index(code, "func down(")]
+
+	pos := func(marker string) int {
+		i := strings.Index(upCode, marker)
+		require.GreaterOrEqual(t, i, 0, marker)
+		return i
+	}
+
+	addTeam := pos("Add type: team")
+	addFolder := pos("Add type: folder")
+	// Relations of added types are defined with the type
+	teamMember := pos(`"member": "[user]"`)
+	folderViewer := pos(`"viewer": "[user] or viewer from parent"`)
+	documentParent := pos("Add relation: document.parent")
+	documentViewer := pos("Add relation: document.viewer")
+
+	assert.Less(t, addTeam, teamMember, "types are added before relations")
+	assert.Less(t, addFolder, folderViewer, "types are added before relations")
+	assert.Less(t, teamMember, documentViewer, "[team#member] needs team.member")
+	assert.Less(t, documentParent, documentViewer, "viewer from parent needs the parent tupleset")
+	assert.Less(t, folderViewer, documentViewer, "viewer from parent needs viewer on the parent's type")
+}
+
 func TestGenerateMigrationFromChanges_DownMigration(t *testing.T) {
 	changes := []omg.ModelChange{
 		{
@@ -430,6 +624,48 @@ func TestGenerateMigrationFromChanges_DownMigration_RenameReversal(t *testing.T)
 	assert.Contains(t, downCode, `"team"`)
 }
 
+func TestGenerateMigrationFromChanges_DownMigration_RestoresRemovals(t *testing.T) {
+	changes := []omg.ModelChange{
+		{
+			Type:     "remove_type",
+			TypeName: "legacy",
+			Details:  "Type 'legacy' removed",
+		},
+		{
+			Type:         "remove_relation",
+			TypeName:     "document",
+			RelationName: "deprecated",
+			Details:      "Removed relation 'document.deprecated'",
+		},
+	}
+
+	filename, err := omg.GenerateMigrationFromChanges(changes, "test_restore", "migrations")
+	require.NoError(t, err)
+	defer os.Remove(filename)
+
+	content, err := os.ReadFile(filename)
+	require.NoError(t, err)
+
+	code := string(content)
+	downStart := strings.Index(code, "func down(")
+	upCode := code[:downStart]
+	downCode := code[downStart:]
+
+	// up backs up before anything is removed
+	assert.Contains(t, code, `const migrationVersion = "`)
+	backupIndex := strings.Index(upCode, `omg.BackupForRemoval(ctx, client, migrationVersion, "document", "deprecated")`)
+	require.NotEqual(t, -1, backupIndex)
+	assert.Less(t, backupIndex, strings.Index(upCode, "RemoveRelationFromType"))
+	assert.Contains(t, upCode, `omg.BackupForRemoval(ctx, client, migrationVersion, "legacy", "")`)
+
+	// down restores the type before the relation
+	typeRestore := strings.Index(downCode, `omg.BackupFilePath(migrationVersion, "legacy", "")`)
+	relationRestore := strings.Index(downCode, `omg.BackupFilePath(migrationVersion, "document", "deprecated")`)
+	require.NotEqual(t, -1, typeRestore)
+	require.NotEqual(t, -1, relationRestore)
+	assert.Less(t, typeRestore, relationRestore)
+}
+
 func TestGenerateMigrationFromChanges_ValidGoSyntax(t *testing.T) {
 	changes := []omg.ModelChange{
 		{
@@ -595,3 +831,288 @@ func TestGenerateMigrationFromChanges_CommentsInGenerated(t *testing.T) {
 	assert.Contains(t, code, "// ⚠️  REVIEW REQUIRED")
 	assert.Contains(t, code, "// This appears to be a rename")
 }
+
+func TestGenerateMigrationFromChanges_UpdateRelationBackfill(t *testing.T) {
+	changes := []omg.ModelChange{
+		{
+			Type:         "update_relation",
+			TypeName:     "document",
+			RelationName: "viewer",
+			OldValue:     "[user]",
+			NewValue:     "[user] or editor",
+			Details:      "Updated relation 'document.viewer' definition",
+		},
+	}
+
+	filename, err := omg.GenerateMigrationFromChangesWithOptions(changes, "widen_viewer", "migrations", omg.GenerateOptions{
+		Backfill: true,
+	})
+	require.NoError(t, err)
+	defer os.Remove(filename)
+
+	content, err := os.ReadFile(filename)
+	require.NoError(t, err)
+
+	code := string(content)
+
+	// Verify backfill analysis follows the model update
+	assert.Contains(t, code, "UpdateRelationDefinition")
+	assert.Contains(t, code, "Backfill: document.viewer now also includes: editor")
+	assert.Contains(t, code, `[]string{"editor"}`)
+	assert.Contains(t, code, "// if err := omg.DeleteTuplesBatch(ctx, client, redundant)")
+	assert.Less(t, strings.Index(code, "UpdateRelationDefinition"), strings.Index(code, "Backfill:"))
+}
+
+func TestGenerateMigrationFromChanges_UpdateRelationBackfill_NotWidened(t *testing.T) {
+	changes := []omg.ModelChange{
+		{
+			Type:         "update_relation",
+			TypeName:     "document",
+			RelationName: "viewer",
+			OldValue:     "[user]",
+			NewValue:     "[user, group#member]",
+			Details:      "Updated relation 'document.viewer' definition",
+		},
+	}
+
+	filename, err := omg.GenerateMigrationFromChangesWithOptions(changes, "narrow_viewer", "migrations", omg.GenerateOptions{
+		Backfill: true,
+	})
+	require.NoError(t, err)
+	defer os.Remove(filename)
+
+	content, err := os.ReadFile(filename)
+	require.NoError(t, err)
+
+	assert.NotContains(t, string(content), "Backfill:")
+}
+
+func TestGenerateMigrationFromChanges_WithTests(t *testing.T) {
+	changes := []omg.ModelChange{
+		{
+			Type:     "add_type",
+			TypeName: "folder",
+			Details:  "New type 'folder' with 1 relations",
+		},
+		{
+			Type:         "add_relation",
+			TypeName:     "folder",
+			RelationName: "owner",
+			NewValue:     "[user]",
+			Details:      "Add relation 'folder.owner'",
+		},
+		{
+			Type:         "remove_relation",
+			TypeName:     "document",
+			RelationName: "legacy",
+			OldValue:     "[user]",
+			Details:      "Removed relation 'document.legacy'",
+		},
+	}
+
+	filename, err := omg.GenerateMigrationFromChangesWithOptions(changes, "add_folders", "migrations", omg.GenerateOptions{
+		WithTests:  true,
+		PriorModel: "model\n  schema 1.1\n\ntype user\n",
+	})
+	require.NoError(t, err)
+	defer os.Remove(filename)
+
+	testFilename := strings.TrimSuffix(filename, ".go") + "_test.go"
+	defer os.Remove(testFilename)
+
+	content, err := os.ReadFile(testFilename)
+	require.NoError(t, err)
+
+	code := string(content)
+
+	assert.Contains(t, code, "package main")
+	assert.Contains(t, code, "func TestMigrationUp(t *testing.T)")
+	assert.Contains(t, code, "const priorModel = `model\n  schema 1.1\n\ntype user\n`")
+	assert.Contains(t, code, "up(ctx, client)")
+	assert.Contains(t, code, `assertTypeExists(t, state, "folder", true)`)
+	assert.Contains(t, code, `assertRelationExists(t, state, "folder", "owner", true)`)
+	assert.Contains(t, code, `assertRelationExists(t, state, "document", "legacy", false)`)
+	assert.Contains(t, code, `assertNoTuples(ctx, t, client, "document", "legacy")`)
+}
+
+func TestBuildGenerateSummary(t *testing.T) {
+	changes := []omg.ModelChange{
+		{
+			Type:         omg.ChangeTypeRemoveRelation,
+			TypeName:     "document",
+			RelationName: "legacy",
+			Details:      "Removed relation 'document.legacy'",
+		},
+		{
+			Type:       omg.ChangeTypeRenameType,
+			TypeName:   "team",
+			OldValue:   "team",
+			NewValue:   "organization",
+			Confidence: omg.ConfidenceMedium,
+			Details:    "Possible rename: 'team' -> 'organization'",
+		},
+		{
+			Type:     omg.ChangeTypeAddType,
+			TypeName: "folder",
+			Details:  "New type 'folder' with 0 relations",
+		},
+	}
+
+	summary := omg.BuildGenerateSummary("migrations/20240101120000_reshape.go", changes, omg.GenerateOptions{WithTests: true})
+
+	assert.Equal(t, "20240101120000", summary.Version)
+	assert.Equal(t, "reshape", summary.Name)
+	assert.Equal(t, "migrations/20240101120000_reshape_test.go", summary.TestFile)
+
+	// Changes follow the order operations run in up
+	require.Len(t, summary.Changes, 3)
+	assert.Equal(t, omg.ChangeTypeAddType, summary.Changes[0].Type)
+	assert.Equal(t, []string{"AddTypeToModel"}, summary.Changes[0].Operations)
+	assert.Equal(t, []string{"RenameType"}, summary.Changes[1].Operations)
+	assert.True(t, summary.Changes[1].Review)
+	assert.Equal(t, []string{"BackupForRemoval", "RemoveRelationFromType", "DeleteRelation"}, summary.Changes[2].Operations)
+	assert.False(t, summary.Changes[2].Review)
+
+	path := filepath.Join(t.TempDir(), "summary.json")
+	require.NoError(t, omg.WriteGenerateSummary(path, summary))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var decoded map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, "migrations/20240101120000_reshape.go", decoded["file"])
+}
+
+func TestGenerateScaffold_Compiles(t *testing.T) {
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go toolchain not available")
+	}
+
+	// The scaffold imports this module, so it must be built from inside it.
+	// A leading underscore keeps the directory out of ./... patterns
+	dir, err := os.MkdirTemp(".", "_scaffold")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	filename, err := omg.GenerateScaffold("add feature flags", dir)
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(filename, "_add_feature_flags.go"))
+
+	code, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	assert.Contains(t, string(code), "omg.ConfigFromEnv()")
+
+	out, err := exec.Command(goBin, "vet", "./"+filepath.Base(dir)).CombinedOutput()
+	assert.NoError(t, err, "scaffold does not compile:\n%s", out)
+}
+
+func TestGenerateSplitMigrations(t *testing.T) {
+	dir := t.TempDir()
+	changes := []omg.ModelChange{
+		{Type: "remove_relation", TypeName: "document", RelationName: "legacy", OldValue: "[user]", Details: "Remove legacy"},
+		{Type: "add_relation", TypeName: "document", RelationName: "editor", NewValue: "[user]", Details: "Add editor"},
+		{Type: "rename_relation", TypeName: "document", OldValue: "reader", NewValue: "viewer", Confidence: "high", Details: "Rename reader"},
+	}
+
+	filenames, err := omg.GenerateSplitMigrations(changes, "overhaul", dir, omg.GenerateOptions{})
+	require.NoError(t, err)
+	require.Len(t, filenames, 3)
+
+	assert.True(t, strings.HasSuffix(filenames[0], "_overhaul_model.go"))
+	assert.True(t, strings.HasSuffix(filenames[1], "_overhaul_tuples.go"))
+	assert.True(t, strings.HasSuffix(filenames[2], "_overhaul_cleanup.go"))
+
+	// Versions are distinct and sort in application order
+	assert.Less(t, filepath.Base(filenames[0]), filepath.Base(filenames[1]))
+	assert.Less(t, filepath.Base(filenames[1]), filepath.Base(filenames[2]))
+
+	upCode := func(filename string) string {
+		content, err := os.ReadFile(filename)
+		require.NoError(t, err)
+		code := string(content)
+		return code[strings.Index(code, "func up("):strings.Index(code, "func down(")]
+	}
+
+	assert.Contains(t, upCode(filenames[0]), "AddRelationToType")
+	assert.NotContains(t, upCode(filenames[0]), "RemoveRelationFromType")
+	assert.Contains(t, upCode(filenames[1]), "RenameRelation")
+	assert.Contains(t, upCode(filenames[2]), "RemoveRelationFromType")
+	assert.NotContains(t, upCode(filenames[2]), "AddRelationToType")
+
+	_, err = omg.GenerateSplitMigrations(changes, "overhaul", dir, omg.GenerateOptions{WithTests: true})
+	assert.Error(t, err)
+}
+
+func TestGenerateMigration_Idempotent(t *testing.T) {
+	dir := t.TempDir()
+	changes := []omg.ModelChange{
+		{Type: "add_type", TypeName: "folder", Details: "Add folder"},
+		{Type: "add_relation", TypeName: "document", RelationName: "editor", NewValue: "[user]", Details: "Add editor"},
+		{Type: "rename_relation", TypeName: "document", OldValue: "reader", NewValue: "viewer", Confidence: "high", Details: "Rename reader"},
+		{Type: "remove_relation", TypeName: "document", RelationName: "legacy", OldValue: "[user]", Details: "Remove legacy"},
+	}
+
+	filename, err := omg.GenerateMigrationFromChangesWithOptions(changes, "idempotent", dir, omg.GenerateOptions{Idempotent: true})
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	code := string(content)
+
+	assert.Contains(t, code, "omg.AddTypeToModelIfMissing(ctx, client, \"folder\"")
+	assert.Contains(t, code, "omg.AddRelationToTypeIfMissing(ctx, client, \"document\", \"editor\"")
+	assert.Contains(t, code, `omg.RenameRelationWithOptions(ctx, client, "document", "reader", "viewer", omg.WriteOptions{SkipExisting: true})`)
+	assert.Contains(t, code, "omg.BackupForRemovalIfMissing(ctx, client, migrationVersion, \"document\", \"legacy\")")
+	assert.Contains(t, code, "omg.RemoveRelationFromTypeIfExists(ctx, client, \"document\", \"legacy\")")
+	assert.NotContains(t, code, "omg.AddRelationToType(ctx")
+	assert.NotContains(t, code, "omg.RenameRelation(ctx")
+
+	summary := omg.BuildGenerateSummary(filename, changes, omg.GenerateOptions{Idempotent: true})
+	assert.Equal(t, []string{"AddTypeToModelIfMissing"}, summary.Changes[0].Operations)
+}
+
+func TestGenerateMigration_Package(t *testing.T) {
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go toolchain not available")
+	}
+
+	dir, err := os.MkdirTemp(".", "_registered")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	changes := []omg.ModelChange{
+		{Type: "add_relation", TypeName: "document", RelationName: "editor", NewValue: "[user]", Details: "Add editor"},
+		{Type: "remove_relation", TypeName: "document", RelationName: "legacy", OldValue: "[user]", Details: "Remove legacy"},
+	}
+	filename, err := omg.GenerateMigrationFromChangesWithOptions(changes, "registered", dir, omg.GenerateOptions{Package: "migrations"})
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	code := string(content)
+
+	version := strings.SplitN(filepath.Base(filename), "_", 2)[0]
+	assert.Contains(t, code, "package migrations")
+	assert.Contains(t, code, "omg.Register(omg.Migration{")
+	assert.Contains(t, code, "func up"+version+"(")
+	assert.Contains(t, code, `omg.BackupForRemoval(ctx, client, "`+version+`", "document", "legacy")`)
+	assert.NotContains(t, code, "func main()")
+
+	meta, err := omg.ParseMigrationMetadata(filename)
+	require.NoError(t, err)
+	assert.Equal(t, "migrations", meta.Package)
+	assert.Equal(t, version, meta.Version)
+
+	// A scaffold in the same package must not clash with the generated migration
+	time.Sleep(time.Second)
+	_, err = omg.GenerateScaffoldWithOptions("manual", dir, omg.GenerateOptions{Package: "migrations"})
+	require.NoError(t, err)
+
+	out, err := exec.Command(goBin, "vet", "./"+filepath.Base(dir)).CombinedOutput()
+	assert.NoError(t, err, "registered migrations do not compile:\n%s", out)
+
+	_, err = omg.GenerateMigrationFromChangesWithOptions(changes, "registered", dir, omg.GenerateOptions{Package: "migrations", WithTests: true})
+	assert.Error(t, err)
+}
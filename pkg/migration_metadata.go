@@ -0,0 +1,92 @@
+package omg
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// MigrationMetadata contains the descriptive header of a migration file
+type MigrationMetadata struct {
+	Package     string // "main" for 'go run' migrations
+	Version     string
+	Name        string
+	Description string
+	Changes     []string
+	Checks      []CheckExpectation
+}
+
+// ParseMigrationMetadata reads the header comments of a migration file
+// It picks up the "// Migration:" and "// Version:" lines, "// Verify:" check
+// expectations, any free-form comment lines before the imports (the description),
+// and the "// Changes detected:" list written by the generator
+// Declarative migrations have no package; their up operations are listed as changes
+func ParseMigrationMetadata(path string) (MigrationMetadata, error) {
+	if IsDeclarativeMigration(path) {
+		return declarativeMetadata(path)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return MigrationMetadata{}, fmt.Errorf("failed to open migration file: %w", err)
+	}
+	defer file.Close()
+
+	var meta MigrationMetadata
+	var description []string
+	inHeader := true
+	inChanges := false
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if meta.Package == "" && strings.HasPrefix(line, "package ") {
+			meta.Package = strings.TrimSpace(strings.TrimPrefix(line, "package "))
+			continue
+		}
+
+		if inHeader {
+			if strings.HasPrefix(line, "import") || strings.HasPrefix(line, "func ") {
+				inHeader = false
+			} else if strings.HasPrefix(line, "//") {
+				comment := strings.TrimSpace(strings.TrimPrefix(line, "//"))
+				switch {
+				case strings.HasPrefix(comment, "Migration:"):
+					meta.Name = strings.TrimSpace(strings.TrimPrefix(comment, "Migration:"))
+				case strings.HasPrefix(comment, "Version:"):
+					meta.Version = strings.TrimSpace(strings.TrimPrefix(comment, "Version:"))
+				case strings.HasPrefix(comment, "Verify:"):
+					check, err := ParseCheckExpectation(strings.TrimPrefix(comment, "Verify:"))
+					if err != nil {
+						return MigrationMetadata{}, fmt.Errorf("%s: %w", path, err)
+					}
+					meta.Checks = append(meta.Checks, check)
+				case comment != "":
+					description = append(description, comment)
+				}
+				continue
+			}
+		}
+
+		if line == "// Changes detected:" {
+			inChanges = true
+			continue
+		}
+		if inChanges {
+			if strings.HasPrefix(line, "// - ") {
+				meta.Changes = append(meta.Changes, strings.TrimPrefix(line, "// - "))
+				continue
+			}
+			inChanges = false
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return MigrationMetadata{}, fmt.Errorf("failed to read migration file: %w", err)
+	}
+
+	meta.Description = strings.Join(description, " ")
+	return meta, nil
+}
@@ -0,0 +1,94 @@
+package omg_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/demetere/omg/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMigrationMetadata(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "20240101000000_add_folders.go")
+	err := os.WriteFile(path, []byte(`package main
+
+// Migration: add_folders
+// Version: 20240101000000
+// Auto-generated migration
+
+import (
+	"context"
+)
+
+func up(ctx context.Context, client *omg.Client) error {
+	// Auto-generated migration
+	// Changes detected:
+	// - New type 'folder' with 1 relations
+	// - Add relation 'folder.owner'
+
+	return nil
+}
+`), 0644)
+	require.NoError(t, err)
+
+	meta, err := omg.ParseMigrationMetadata(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, "add_folders", meta.Name)
+	assert.Equal(t, "20240101000000", meta.Version)
+	assert.Equal(t, "Auto-generated migration", meta.Description)
+	assert.Equal(t, []string{"New type 'folder' with 1 relations", "Add relation 'folder.owner'"}, meta.Changes)
+}
+
+func TestParseMigrationMetadata_Checks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "20240101000000_share_docs.go")
+	err := os.WriteFile(path, []byte(`package main
+
+// Migration: share_docs
+// Version: 20240101000000
+// Verify: user:alice viewer document:readme
+// Verify: user:bob viewer document:readme deny
+
+import "context"
+`), 0644)
+	require.NoError(t, err)
+
+	meta, err := omg.ParseMigrationMetadata(path)
+	require.NoError(t, err)
+
+	require.Len(t, meta.Checks, 2)
+	assert.Equal(t, omg.Tuple{User: "user:alice", Relation: "viewer", Object: "document:readme"}, meta.Checks[0].Tuple)
+	assert.True(t, meta.Checks[0].Allowed)
+	assert.False(t, meta.Checks[1].Allowed)
+	assert.Empty(t, meta.Description)
+}
+
+func TestParseCheckExpectation(t *testing.T) {
+	check, err := omg.ParseCheckExpectation("user:alice viewer document:readme deny")
+	require.NoError(t, err)
+	assert.Equal(t, "user:alice", check.User)
+	assert.False(t, check.Allowed)
+	assert.Equal(t, "user:alice viewer document:readme deny", check.String())
+
+	_, err = omg.ParseCheckExpectation("user:alice viewer")
+	assert.Error(t, err)
+
+	_, err = omg.ParseCheckExpectation("user:alice viewer document:readme maybe")
+	assert.Error(t, err)
+}
+
+func TestTupleVerificationError(t *testing.T) {
+	err := &omg.TupleVerificationError{
+		Checked: 3,
+		Mismatches: []omg.TupleMismatch{
+			{Tuple: omg.Tuple{User: "user:alice", Relation: "viewer", Object: "document:a"}, ExpectedPresent: true},
+			{Tuple: omg.Tuple{User: "user:bob", Relation: "viewer", Object: "document:b"}, ExpectedPresent: false},
+		},
+	}
+
+	assert.Equal(t, "2 of 3 verified tuples do not match the expected state:\n"+
+		"  user:alice viewer document:a: missing\n"+
+		"  user:bob viewer document:b: still present", err.Error())
+}
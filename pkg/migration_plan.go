@@ -0,0 +1,144 @@
+package omg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// DefaultPlanWorkers is how many parallel steps ApplyPlan runs at once by default
+const DefaultPlanWorkers = 4
+
+// PlanStep is one operation of a MigrationPlan
+type PlanStep struct {
+	Name string
+	Run  func(ctx context.Context, client *Client) error
+
+	// Parallel steps run concurrently with the Parallel steps next to them. Only mark
+	// steps that touch unrelated types or relations, e.g. renames on different types.
+	// Model changes from parallel steps take turns but run in no particular order
+	Parallel bool
+}
+
+// MigrationPlan is an ordered list of steps for a migration's Up or Down function
+// Steps run in order, except that a run of consecutive Parallel steps runs concurrently;
+// the next sequential step starts once all of them are done
+type MigrationPlan struct {
+	Steps []PlanStep
+}
+
+// NewPlan creates an empty plan
+func NewPlan() *MigrationPlan {
+	return &MigrationPlan{}
+}
+
+// Add appends a step that runs on its own, after everything before it
+func (p *MigrationPlan) Add(name string, run func(ctx context.Context, client *Client) error) *MigrationPlan {
+	p.Steps = append(p.Steps, PlanStep{Name: name, Run: run})
+	return p
+}
+
+// AddParallel appends a step that may run alongside the Parallel steps next to it
+func (p *MigrationPlan) AddParallel(name string, run func(ctx context.Context, client *Client) error) *MigrationPlan {
+	p.Steps = append(p.Steps, PlanStep{Name: name, Run: run, Parallel: true})
+	return p
+}
+
+// ApplyPlanOptions configures ApplyPlan
+type ApplyPlanOptions struct {
+	Workers int // Parallel steps run at once; 0 means DefaultPlanWorkers
+}
+
+// ApplyPlan runs a plan's steps against client
+func ApplyPlan(ctx context.Context, client *Client, plan *MigrationPlan) error {
+	return ApplyPlanWithOptions(ctx, client, plan, ApplyPlanOptions{})
+}
+
+// ApplyPlanWithOptions runs a plan's steps with bounded concurrency for Parallel steps
+// The first failing step stops the plan: parallel steps that have not started yet are
+// skipped, and the errors of all steps that failed are returned together
+func ApplyPlanWithOptions(ctx context.Context, client *Client, plan *MigrationPlan, opts ApplyPlanOptions) error {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = DefaultPlanWorkers
+	}
+
+	steps := plan.Steps
+	for len(steps) > 0 {
+		// Collect the run of consecutive parallel steps, or a single sequential step
+		n := 1
+		if steps[0].Parallel {
+			for n < len(steps) && steps[n].Parallel {
+				n++
+			}
+		}
+
+		var err error
+		if n == 1 {
+			err = runPlanStep(ctx, client, steps[0])
+		} else {
+			err = runParallelSteps(ctx, client, steps[:n], workers)
+		}
+		if err != nil {
+			return err
+		}
+		steps = steps[n:]
+	}
+	return nil
+}
+
+// runParallelSteps runs steps concurrently on at most workers goroutines
+func runParallelSteps(ctx context.Context, client *Client, steps []PlanStep, workers int) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	skipped := false
+	slots := make(chan struct{}, workers)
+
+	for _, step := range steps {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			skipped = true // A step failed or the caller cancelled: start nothing new
+			break
+		}
+
+		wg.Add(1)
+		go func(step PlanStep) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			if err := runPlanStep(ctx, client, step); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+				cancel()
+			}
+		}(step)
+	}
+	wg.Wait()
+
+	if len(errs) == 0 && skipped {
+		return ctx.Err() // Cancelled by the caller before every step could start
+	}
+	return errors.Join(errs...)
+}
+
+// runPlanStep runs one step, naming it in the error
+func runPlanStep(ctx context.Context, client *Client, step PlanStep) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := step.Run(ctx, client); err != nil {
+		return fmt.Errorf("step '%s' failed: %w", step.Name, err)
+	}
+	return nil
+}
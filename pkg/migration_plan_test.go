@@ -0,0 +1,88 @@
+package omg_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/demetere/omg/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyPlan_SequentialOrder(t *testing.T) {
+	var calls []string
+	step := func(name string) func(context.Context, *omg.Client) error {
+		return func(ctx context.Context, client *omg.Client) error {
+			calls = append(calls, name)
+			return nil
+		}
+	}
+
+	plan := omg.NewPlan().Add("a", step("a")).Add("b", step("b")).Add("c", step("c"))
+	require.NoError(t, omg.ApplyPlan(context.Background(), nil, plan))
+	assert.Equal(t, []string{"a", "b", "c"}, calls)
+}
+
+func TestApplyPlan_ParallelStepsAreBounded(t *testing.T) {
+	var running, maxRunning int32
+	var mu sync.Mutex
+	var order []string
+
+	parallel := func(ctx context.Context, client *omg.Client) error {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			max := atomic.LoadInt32(&maxRunning)
+			if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+
+		mu.Lock()
+		order = append(order, "parallel")
+		mu.Unlock()
+		return nil
+	}
+	last := func(ctx context.Context, client *omg.Client) error {
+		assert.Equal(t, int32(0), atomic.LoadInt32(&running), "sequential step must wait for parallel steps")
+		order = append(order, "last")
+		return nil
+	}
+
+	plan := omg.NewPlan()
+	for _, name := range []string{"rename user", "rename team", "rename folder", "rename doc", "rename org"} {
+		plan.AddParallel(name, parallel)
+	}
+	plan.Add("cleanup", last)
+
+	require.NoError(t, omg.ApplyPlanWithOptions(context.Background(), nil, plan, omg.ApplyPlanOptions{Workers: 2}))
+	assert.Equal(t, int32(2), maxRunning)
+	assert.Len(t, order, 6)
+	assert.Equal(t, "last", order[5])
+}
+
+func TestApplyPlan_FailureStopsPlan(t *testing.T) {
+	var ran int32
+	ok := func(ctx context.Context, client *omg.Client) error {
+		atomic.AddInt32(&ran, 1)
+		return nil
+	}
+	failing := func(ctx context.Context, client *omg.Client) error {
+		return errors.New("boom")
+	}
+
+	plan := omg.NewPlan().
+		AddParallel("fails", failing).
+		AddParallel("skipped", ok).
+		Add("after", ok)
+
+	err := omg.ApplyPlanWithOptions(context.Background(), nil, plan, omg.ApplyPlanOptions{Workers: 1})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "step 'fails' failed: boom")
+	assert.Equal(t, int32(0), atomic.LoadInt32(&ran))
+}
@@ -0,0 +1,325 @@
+package omg
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+)
+
+// Direction is the direction a migration is run in
+type Direction string
+
+const (
+	DirectionUp   Direction = "up"
+	DirectionDown Direction = "down"
+)
+
+// MigrationEventType identifies a progress event emitted while running migrations
+type MigrationEventType string
+
+const (
+	EventRunStarted         MigrationEventType = "run_started"
+	EventRunFinished        MigrationEventType = "run_finished"
+	EventMigrationStarted   MigrationEventType = "started"
+	EventMigrationCompleted MigrationEventType = "completed"
+	EventMigrationFailed    MigrationEventType = "failed"
+	EventBatchProgress      MigrationEventType = "batch_progress"
+	EventWarning            MigrationEventType = "warning"
+)
+
+// MigrationEvent is a structured progress event
+// Run events describe a whole Run; Version and Name are empty for them. Batch progress
+// and warning events come from inside the migration that is running
+type MigrationEvent struct {
+	Type      MigrationEventType
+	Version   string
+	Name      string
+	Direction Direction
+	Time      time.Time
+	Duration  time.Duration  // Set for completed, failed and run_finished events
+	Err       error          // Set for failed events, and run_finished events of failed runs
+	Count     int            // Migrations to run (run_started) or that ran (run_finished)
+	Batch     *BatchProgress // Set for batch_progress events
+	Message   string         // Set for warning events
+}
+
+// RunOptions configures RunMigration
+type RunOptions struct {
+	// OnEvent receives progress events; nil discards them
+	OnEvent func(MigrationEvent)
+}
+
+// PanicError is returned when a migration function panics
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("migration panicked: %v", e.Value)
+}
+
+// RunMigration runs a migration's Up or Down function in-process
+// A panic in the function is recovered and returned as a *PanicError, so the caller
+// can still record the failure. Returns how long the function ran
+func RunMigration(ctx context.Context, client *Client, m Migration, direction Direction, opts RunOptions) (time.Duration, error) {
+	var fn func(ctx context.Context, client *Client) error
+	switch direction {
+	case DirectionUp:
+		fn = m.Up
+	case DirectionDown:
+		fn = m.Down
+	default:
+		return 0, fmt.Errorf("unknown direction '%s'", direction)
+	}
+	if fn == nil {
+		return 0, fmt.Errorf("migration %s has no %s function", m.Version, direction)
+	}
+
+	emit := func(eventType MigrationEventType, duration time.Duration, err error) {
+		if opts.OnEvent == nil {
+			return
+		}
+		opts.OnEvent(MigrationEvent{
+			Type:      eventType,
+			Version:   m.Version,
+			Name:      m.Name,
+			Direction: direction,
+			Time:      time.Now(),
+			Duration:  duration,
+			Err:       err,
+		})
+	}
+
+	if opts.OnEvent != nil {
+		// Batch progress and warnings from helpers called by fn are attributed to m
+		ctx = withEventSink(ctx, func(event MigrationEvent) {
+			event.Version, event.Name, event.Direction = m.Version, m.Name, direction
+			opts.OnEvent(event)
+		})
+	}
+
+	emit(EventMigrationStarted, 0, nil)
+	start := time.Now()
+	err := callRecovered(ctx, client, fn)
+	duration := time.Since(start)
+
+	if err != nil {
+		err = fmt.Errorf("migration %s %s failed: %w", m.Version, direction, err)
+		emit(EventMigrationFailed, duration, err)
+		return duration, err
+	}
+
+	emit(EventMigrationCompleted, duration, nil)
+	return duration, nil
+}
+
+// callRecovered calls a migration function, converting a panic into a *PanicError
+func callRecovered(ctx context.Context, client *Client, fn func(ctx context.Context, client *Client) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+	return fn(ctx, client)
+}
+
+// MigrateOptions configures Run
+type MigrateOptions struct {
+	// Direction defaults to DirectionUp
+	Direction Direction
+
+	// Tracker records applied migrations (required)
+	Tracker MigrationTracker
+
+	// Target stops the run at a version. Up applies migrations through Target; down rolls
+	// back migrations newer than Target, which stays applied ("0" rolls back everything)
+	Target string
+
+	// Steps is how many migrations down rolls back when Target is empty (default 1)
+	Steps int
+
+	// Migrations defaults to the registered migrations (GetAll)
+	Migrations []Migration
+
+	// Purge deletes the tracker rows of rolled back migrations instead of marking them
+	Purge bool
+
+	// OnEvent receives progress events; nil discards them
+	OnEvent func(MigrationEvent)
+}
+
+// Run applies or rolls back registered migrations in-process, without 'go run'
+// Migrations compiled into the binary register themselves with Register; Run executes
+// the pending ones in version order and records every run with the tracker.
+// Returns the runs that were recorded
+func Run(ctx context.Context, client *Client, opts MigrateOptions) ([]MigrationRun, error) {
+	if opts.Tracker == nil {
+		return nil, fmt.Errorf("a tracker is required to run migrations")
+	}
+
+	all := opts.Migrations
+	if all == nil {
+		all = GetAll()
+	} else {
+		all = sortMigrations(all)
+	}
+
+	if opts.Target != "" && !(opts.Direction == DirectionDown && opts.Target == "0") && !hasVersion(all, opts.Target) {
+		return nil, fmt.Errorf("no migration with version %s is registered", opts.Target)
+	}
+
+	applied, err := opts.Tracker.GetApplied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	direction := opts.Direction
+	if direction == "" {
+		direction = DirectionUp
+	}
+	if direction != DirectionUp && direction != DirectionDown {
+		return nil, fmt.Errorf("unknown direction '%s'", opts.Direction)
+	}
+
+	if journal, ok := opts.Tracker.(RenameJournal); ok && ctx.Value(renameJournalKey{}) == nil {
+		ctx = WithRenameJournal(ctx, journal)
+	}
+
+	emit := func(event MigrationEvent) {
+		if opts.OnEvent != nil {
+			event.Direction, event.Time = direction, time.Now()
+			opts.OnEvent(event)
+		}
+	}
+
+	candidates := runCandidates(all, applied, direction, opts)
+	start := time.Now()
+	emit(MigrationEvent{Type: EventRunStarted, Count: len(candidates)})
+
+	var runs []MigrationRun
+	if direction == DirectionUp {
+		runs, err = runPendingUp(ctx, client, candidates, opts)
+	} else {
+		runs, err = runAppliedDown(ctx, client, candidates, opts)
+	}
+
+	emit(MigrationEvent{Type: EventRunFinished, Count: len(runs), Duration: time.Since(start), Err: err})
+	return runs, err
+}
+
+// runCandidates returns the migrations a run would execute, in execution order
+func runCandidates(all []Migration, applied map[string]MigrationInfo, direction Direction, opts MigrateOptions) []Migration {
+	var candidates []Migration
+	if direction == DirectionUp {
+		for _, m := range all {
+			if opts.Target != "" && m.Version > opts.Target {
+				break
+			}
+			if _, exists := applied[m.Version]; !exists {
+				candidates = append(candidates, m)
+			}
+		}
+		return candidates
+	}
+
+	limit := downLimit(opts)
+	for i := len(all) - 1; i >= 0; i-- {
+		m := all[i]
+		if opts.Target != "" && m.Version <= opts.Target {
+			break
+		}
+		if _, exists := applied[m.Version]; !exists {
+			continue
+		}
+		candidates = append(candidates, m)
+		if limit > 0 && len(candidates) == limit {
+			break
+		}
+	}
+	return candidates
+}
+
+// downLimit is how many migrations a down run rolls back (0 for no limit)
+func downLimit(opts MigrateOptions) int {
+	if opts.Target != "" {
+		return 0
+	}
+	if opts.Steps <= 0 {
+		return 1
+	}
+	return opts.Steps
+}
+
+// runPendingUp applies pending migrations in order
+func runPendingUp(ctx context.Context, client *Client, pending []Migration, opts MigrateOptions) ([]MigrationRun, error) {
+	var runs []MigrationRun
+	for _, m := range pending {
+		run := MigrationRun{
+			Version:   m.Version,
+			Name:      m.Name,
+			Status:    RunStatusApplied,
+			StartedAt: time.Now(),
+			AppliedBy: CurrentOperator(),
+		}
+
+		duration, err := RunMigration(ctx, client, m, DirectionUp, RunOptions{OnEvent: opts.OnEvent})
+		run.Duration = duration
+		if err != nil {
+			run.Status = RunStatusFailed
+			run.Error = err.Error()
+			if recordErr := opts.Tracker.RecordRunWithOptions(ctx, run, RemoveOptions{}); recordErr != nil {
+				return runs, fmt.Errorf("%w (and failed to record the failed run: %v)", err, recordErr)
+			}
+			return append(runs, run), err
+		}
+
+		if err := opts.Tracker.RecordRunWithOptions(ctx, run, RemoveOptions{}); err != nil {
+			return runs, fmt.Errorf("failed to record migration %s: %w", m.Version, err)
+		}
+		runs = append(runs, run)
+
+		// The migration stays applied, but the run stops so the failure is noticed
+		if _, err := VerifyChecks(ctx, client, m.Checks); err != nil {
+			return runs, fmt.Errorf("migration %s applied but %w", m.Version, err)
+		}
+	}
+	return runs, nil
+}
+
+// runAppliedDown rolls back applied migrations in order, newest first
+func runAppliedDown(ctx context.Context, client *Client, rollbacks []Migration, opts MigrateOptions) ([]MigrationRun, error) {
+	var runs []MigrationRun
+	for _, m := range rollbacks {
+		run := MigrationRun{
+			Version:   m.Version,
+			Name:      m.Name,
+			Status:    RunStatusRolledBack,
+			StartedAt: time.Now(),
+			AppliedBy: CurrentOperator(),
+		}
+
+		duration, err := RunMigration(ctx, client, m, DirectionDown, RunOptions{OnEvent: opts.OnEvent})
+		if err != nil {
+			return runs, err
+		}
+
+		run.Duration = duration
+		if err := opts.Tracker.RecordRunWithOptions(ctx, run, RemoveOptions{Purge: opts.Purge}); err != nil {
+			return runs, fmt.Errorf("failed to remove migration record %s: %w", m.Version, err)
+		}
+		runs = append(runs, run)
+	}
+	return runs, nil
+}
+
+// hasVersion reports whether one of the migrations has the given version
+func hasVersion(all []Migration, version string) bool {
+	for _, m := range all {
+		if m.Version == version {
+			return true
+		}
+	}
+	return false
+}
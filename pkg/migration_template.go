@@ -0,0 +1,77 @@
+package omg
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// MigrationTemplateFile is the migration template omg create and generate use when it exists
+const MigrationTemplateFile = ".omg/templates/migration.go.tmpl"
+
+// MigrationTemplateData is what a custom migration template is executed with
+// The template writes the whole file: package clause, imports, the up and down functions
+// around Up and Down, and main() or the Register call
+type MigrationTemplateData struct {
+	Version     string
+	Name        string        // Sanitized migration name
+	Description string        // "Auto-generated migration" for generate, empty for create
+	Package     string        // Package of registered migrations; empty for 'go run' ones
+	Changes     []ModelChange // Changes a generated migration makes; nil for create
+	Imports     []string      // Import specs the up and down functions need
+	Up          string        // Body of up, without its final return
+	Down        string        // Body of down, without its final return
+}
+
+// newMigrationTemplateData builds the data of a migration. The bodies refer to the
+// migration's version directly, so templates need not declare migrationVersion
+func newMigrationTemplateData(version, name, description, pkg string, changes []ModelChange, up, down string) MigrationTemplateData {
+	up = migrationVersionRef.ReplaceAllString(up, strconv.Quote(version))
+	down = migrationVersionRef.ReplaceAllString(down, strconv.Quote(version))
+
+	imports := []string{`"context"`}
+	if strings.Contains(up, "fmt.") || strings.Contains(down, "fmt.") {
+		imports = append(imports, `"fmt"`)
+	}
+	imports = append(imports, `omg "github.com/demetere/omg"`)
+
+	return MigrationTemplateData{
+		Version:     version,
+		Name:        sanitizeName(name),
+		Description: description,
+		Package:     pkg,
+		Changes:     changes,
+		Imports:     imports,
+		Up:          up,
+		Down:        down,
+	}
+}
+
+// renderMigrationTemplate executes the template at path and formats the result as Go
+func renderMigrationTemplate(path string, data MigrationTemplateData) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read migration template: %w", err)
+	}
+
+	tmpl, err := template.New(filepath.Base(path)).Parse(string(content))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse migration template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to execute migration template: %w", err)
+	}
+
+	formatted, err := format.Source(buf.Bytes())
+	if err != nil {
+		return "", fmt.Errorf("migration template %s did not produce valid Go: %w", path, err)
+	}
+	return string(formatted), nil
+}
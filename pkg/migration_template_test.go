@@ -0,0 +1,95 @@
+package omg_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/demetere/omg/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const customMigrationTemplate = `package main
+
+// {{.Name}} ({{.Version}}){{range .Changes}}
+// - {{.Details}}{{end}}
+
+import (
+{{range .Imports}}	{{.}}
+{{end}}	"log"
+)
+
+func main() {
+	client, err := newClient()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := up(context.Background(), client); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func newClient() (*omg.Client, error) {
+	cfg, err := omg.ConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return omg.NewClient(cfg)
+}
+
+func up(ctx context.Context, client *omg.Client) error {
+	log.Println("up {{.Version}}")
+{{.Up}}
+	return nil
+}
+
+func down(ctx context.Context, client *omg.Client) error {
+{{.Down}}
+	return nil
+}
+`
+
+func TestGenerateMigration_Template(t *testing.T) {
+	dir := t.TempDir()
+	templatePath := filepath.Join(dir, "migration.go.tmpl")
+	require.NoError(t, os.WriteFile(templatePath, []byte(customMigrationTemplate), 0644))
+
+	changes := []omg.ModelChange{
+		{Type: omg.ChangeTypeRemoveRelation, TypeName: "document", RelationName: "legacy", OldValue: "[user]", Details: "Removed relation 'document.legacy'"},
+	}
+	filename, err := omg.GenerateMigrationFromChangesWithOptions(changes, "drop legacy", dir, omg.GenerateOptions{Template: templatePath})
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	code := string(content)
+
+	assert.Contains(t, code, "// drop_legacy (")
+	assert.Contains(t, code, "// - Removed relation 'document.legacy'")
+	assert.Contains(t, code, "\t\"fmt\"\n")
+	assert.Contains(t, code, "return omg.NewClient(cfg)")
+	assert.Contains(t, code, `omg.RemoveRelationFromType(ctx, client, "document", "legacy")`)
+	// The template does not declare migrationVersion, so the version is inlined
+	assert.NotContains(t, code, "migrationVersion")
+
+	scaffold, err := omg.GenerateScaffoldWithOptions("manual", dir, omg.GenerateOptions{Template: templatePath})
+	require.NoError(t, err)
+	content, err = os.ReadFile(scaffold)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "// TODO: Implement migration")
+	assert.NotContains(t, string(content), "\"fmt\"")
+}
+
+func TestGenerateMigration_TemplateErrors(t *testing.T) {
+	dir := t.TempDir()
+	changes := []omg.ModelChange{{Type: omg.ChangeTypeAddType, TypeName: "folder", Details: "New type"}}
+
+	_, err := omg.GenerateMigrationFromChangesWithOptions(changes, "missing", dir, omg.GenerateOptions{Template: filepath.Join(dir, "missing.tmpl")})
+	assert.ErrorContains(t, err, "failed to read migration template")
+
+	invalid := filepath.Join(dir, "invalid.tmpl")
+	require.NoError(t, os.WriteFile(invalid, []byte("package main\n\nfunc up( {{.Up}}"), 0644))
+	_, err = omg.GenerateMigrationFromChangesWithOptions(changes, "invalid", dir, omg.GenerateOptions{Template: invalid})
+	assert.ErrorContains(t, err, "did not produce valid Go")
+}
@@ -60,7 +60,7 @@ type migration
 `)
 	defer container.Terminate(ctx)
 
-	tracker := omg.NewTracker(client)
+	tracker := omg.NewOpenFGATracker(client)
 
 	// Initially no migrations applied
 	applied, err := tracker.GetApplied(ctx)
@@ -97,7 +97,7 @@ type migration
 `)
 	defer container.Terminate(ctx)
 
-	tracker := omg.NewTracker(client)
+	tracker := omg.NewOpenFGATracker(client)
 
 	// Record migrations
 	err := tracker.Record(ctx, "20240101000000", "first")
@@ -0,0 +1,140 @@
+package omg
+
+import (
+	"fmt"
+	"strings"
+)
+
+// generateMigrationTestCode generates a Go test for a migration
+// The test starts an OpenFGA container (like internal/testhelpers does), applies the
+// model as it was before the migration, runs up() and asserts the expected state
+func generateMigrationTestCode(version, name string, changes []ModelChange, priorModelDSL string) string {
+	var builder strings.Builder
+
+	builder.WriteString(`package main
+
+// Test for migration: ` + sanitizeName(name) + `
+// Version: ` + version + `
+// Auto-generated test - extend with tuples and assertions specific to your data
+//
+// Run with: go test ` + version + `_` + sanitizeName(name) + `.go ` + version + `_` + sanitizeName(name) + `_test.go
+// Requires Docker and github.com/testcontainers/testcontainers-go/modules/openfga
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	omg "github.com/demetere/omg"
+	openfgacontainer "github.com/testcontainers/testcontainers-go/modules/openfga"
+)
+
+// priorModel is the authorization model before this migration
+const priorModel = ` + "`" + escapeBackticks(priorModelDSL) + "`" + `
+
+func TestMigrationUp(t *testing.T) {
+	ctx := context.Background()
+
+	container, err := openfgacontainer.Run(ctx, "openfga/openfga:v1.8.0")
+	if err != nil {
+		t.Fatalf("failed to start OpenFGA container: %v", err)
+	}
+	defer container.Terminate(ctx)
+
+	apiURL, err := container.HttpEndpoint(ctx)
+	if err != nil {
+		t.Fatalf("failed to get OpenFGA endpoint: %v", err)
+	}
+
+	storeID, err := omg.CreateStore(apiURL, "migration-test")
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+
+	client, err := omg.NewClient(omg.Config{ApiURL: apiURL, StoreID: storeID})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	if err := omg.ApplyModelFromDSL(ctx, client, priorModel); err != nil {
+		t.Fatalf("failed to apply prior model: %v", err)
+	}
+
+	// TODO: Seed tuples the migration is expected to transform, e.g.
+	// if err := omg.WriteTuplesBatch(ctx, client, []omg.Tuple{
+	// 	{User: "user:alice", Relation: "owner", Object: "document:readme"},
+	// }); err != nil {
+	// 	t.Fatalf("failed to seed tuples: %v", err)
+	// }
+
+	if err := up(ctx, client); err != nil {
+		t.Fatalf("migration up failed: %v", err)
+	}
+
+	state, err := omg.LoadModelStateFromOpenFGA(ctx, client)
+	if err != nil {
+		t.Fatalf("failed to load model state: %v", err)
+	}
+	_ = state
+
+	// Expected post-conditions
+`)
+
+	for _, change := range orderChangesForUp(changes) {
+		builder.WriteString(generateChangeAssertion(change))
+	}
+
+	builder.WriteString(`}
+
+func assertTypeExists(t *testing.T, state *omg.ModelState, typeName string, want bool) {
+	t.Helper()
+	if _, exists := state.Types[typeName]; exists != want {
+		t.Errorf("type %s exists = %v, want %v", typeName, exists, want)
+	}
+}
+
+func assertRelationExists(t *testing.T, state *omg.ModelState, typeName, relation string, want bool) {
+	t.Helper()
+	_, exists := state.Types[typeName].Relations[relation]
+	if exists != want {
+		t.Errorf("relation %s.%s exists = %v, want %v", typeName, relation, exists, want)
+	}
+}
+
+func assertNoTuples(ctx context.Context, t *testing.T, client *omg.Client, objectType, relation string) {
+	t.Helper()
+	if err := omg.WaitForTupleCount(ctx, client, objectType, relation, 0, 10*time.Second); err != nil {
+		t.Errorf("expected no tuples: %v", err)
+	}
+}
+`)
+
+	return builder.String()
+}
+
+// generateChangeAssertion generates the post-condition check for a single change
+func generateChangeAssertion(change ModelChange) string {
+	switch change.Type {
+	case ChangeTypeAddType:
+		return fmt.Sprintf("\tassertTypeExists(t, state, %q, true)\n", change.TypeName)
+	case ChangeTypeRemoveType:
+		return fmt.Sprintf("\tassertTypeExists(t, state, %q, false)\n\tassertNoTuples(ctx, t, client, %q, \"\")\n",
+			change.TypeName, change.TypeName)
+	case ChangeTypeAddRelation, ChangeTypeUpdateRelation:
+		return fmt.Sprintf("\tassertRelationExists(t, state, %q, %q, true)\n", change.TypeName, change.RelationName)
+	case ChangeTypeRemoveRelation:
+		return fmt.Sprintf("\tassertRelationExists(t, state, %q, %q, false)\n\tassertNoTuples(ctx, t, client, %q, %q)\n",
+			change.TypeName, change.RelationName, change.TypeName, change.RelationName)
+	case ChangeTypeRenameType:
+		return fmt.Sprintf("\tassertNoTuples(ctx, t, client, %q, \"\") // tuples moved to %s\n", change.OldValue, change.NewValue)
+	case ChangeTypeRenameRelation:
+		return fmt.Sprintf("\tassertNoTuples(ctx, t, client, %q, %q) // tuples moved to %s\n",
+			change.TypeName, change.OldValue, change.NewValue)
+	}
+	return ""
+}
+
+// escapeBackticks makes a string safe to embed in a Go raw string literal
+func escapeBackticks(s string) string {
+	return strings.ReplaceAll(s, "`", "` + \"`\" + `")
+}
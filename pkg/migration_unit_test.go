@@ -1,7 +1,9 @@
 package omg
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -66,3 +68,61 @@ func TestGetAllReturnsCopy(t *testing.T) {
 	all1[0].Name = "modified"
 	assert.NotEqual(t, all1[0].Name, all2[0].Name)
 }
+
+func TestRunMigration_RecoversPanic(t *testing.T) {
+	m := Migration{
+		Version: "20240101000000",
+		Name:    "panics",
+		Up: func(ctx context.Context, client *Client) error {
+			var tuples []Tuple
+			_ = tuples[1]
+			return nil
+		},
+	}
+
+	var events []MigrationEvent
+	_, err := RunMigration(context.Background(), nil, m, DirectionUp, RunOptions{
+		OnEvent: func(e MigrationEvent) { events = append(events, e) },
+	})
+
+	var panicErr *PanicError
+	assert.ErrorAs(t, err, &panicErr)
+	assert.NotEmpty(t, panicErr.Stack)
+	assert.Len(t, events, 2)
+	assert.Equal(t, EventMigrationStarted, events[0].Type)
+	assert.Equal(t, EventMigrationFailed, events[1].Type)
+	assert.Equal(t, DirectionUp, events[1].Direction)
+}
+
+func TestRunMigration_ReportsDuration(t *testing.T) {
+	m := Migration{
+		Version: "20240101000000",
+		Name:    "sleeps",
+		Down: func(ctx context.Context, client *Client) error {
+			time.Sleep(10 * time.Millisecond)
+			return nil
+		},
+	}
+
+	var events []MigrationEvent
+	duration, err := RunMigration(context.Background(), nil, m, DirectionDown, RunOptions{
+		OnEvent: func(e MigrationEvent) { events = append(events, e) },
+	})
+
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, duration, 10*time.Millisecond)
+	assert.Len(t, events, 2)
+	assert.Equal(t, EventMigrationCompleted, events[1].Type)
+	assert.Equal(t, duration, events[1].Duration)
+
+	_, err = RunMigration(context.Background(), nil, m, DirectionUp, RunOptions{})
+	assert.Error(t, err, "migration without an Up function")
+}
+
+func TestCurrentOperator(t *testing.T) {
+	t.Setenv("OMG_APPLIED_BY", "ci-deploy")
+	assert.Equal(t, "ci-deploy", CurrentOperator())
+
+	t.Setenv("OMG_APPLIED_BY", "")
+	assert.NotEmpty(t, CurrentOperator())
+}
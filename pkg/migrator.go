@@ -0,0 +1,203 @@
+package omg
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MigrationTracker records which migrations have been applied
+// *Tracker (PostgreSQL) implements it; MemoryTracker is an in-memory implementation for
+// tests and throwaway stores
+type MigrationTracker interface {
+	// GetApplied returns the applied migrations keyed by version
+	GetApplied(ctx context.Context) (map[string]MigrationInfo, error)
+
+	// RecordRunWithOptions records an applied, rolled back or failed run
+	RecordRunWithOptions(ctx context.Context, run MigrationRun, opts RemoveOptions) error
+}
+
+// MigrationStatus is the state of one migration
+type MigrationStatus struct {
+	Version    string
+	Name       string
+	Applied    bool
+	AppliedAt  time.Time // Zero unless applied
+	Registered bool      // False for applied migrations that are not registered in this binary
+}
+
+// Migrator runs registered migrations from application code, e.g. on startup,
+// without the omg CLI or a Go toolchain
+type Migrator struct {
+	client  *Client
+	tracker MigrationTracker
+
+	// Migrations are run in version order; NewMigrator sets them to GetAll()
+	Migrations []Migration
+
+	// Purge deletes the tracker rows of rolled back migrations instead of marking them
+	Purge bool
+
+	// OnEvent receives progress events; nil discards them
+	OnEvent func(MigrationEvent)
+}
+
+// NewMigrator creates a migrator for the registered migrations
+func NewMigrator(client *Client, tracker MigrationTracker) *Migrator {
+	return &Migrator{
+		client:     client,
+		tracker:    tracker,
+		Migrations: GetAll(),
+	}
+}
+
+// Up applies all pending migrations
+func (m *Migrator) Up(ctx context.Context) ([]MigrationRun, error) {
+	return Run(ctx, m.client, m.options(DirectionUp, ""))
+}
+
+// UpTo applies pending migrations up to and including version
+func (m *Migrator) UpTo(ctx context.Context, version string) ([]MigrationRun, error) {
+	return Run(ctx, m.client, m.options(DirectionUp, version))
+}
+
+// Down rolls back the most recently applied migration
+func (m *Migrator) Down(ctx context.Context) ([]MigrationRun, error) {
+	return Run(ctx, m.client, m.options(DirectionDown, ""))
+}
+
+// DownTo rolls back applied migrations newer than version, which stays applied
+// A version of "0" rolls back everything
+func (m *Migrator) DownTo(ctx context.Context, version string) ([]MigrationRun, error) {
+	return Run(ctx, m.client, m.options(DirectionDown, version))
+}
+
+// Status returns every registered migration with whether it is applied, in version order
+// Applied migrations that are not registered are included with Registered false
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := m.tracker.GetApplied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []MigrationStatus
+	seen := make(map[string]bool)
+	for _, migration := range m.Migrations {
+		info, isApplied := applied[migration.Version]
+		statuses = append(statuses, MigrationStatus{
+			Version:    migration.Version,
+			Name:       migration.Name,
+			Applied:    isApplied,
+			AppliedAt:  info.AppliedAt,
+			Registered: true,
+		})
+		seen[migration.Version] = true
+	}
+	for version, info := range applied {
+		if !seen[version] {
+			statuses = append(statuses, MigrationStatus{
+				Version:   version,
+				Name:      info.Name,
+				Applied:   true,
+				AppliedAt: info.AppliedAt,
+			})
+		}
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+	return statuses, nil
+}
+
+// Pending returns the registered migrations that are not applied yet
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	applied, err := m.tracker.GetApplied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, migration := range sortMigrations(m.Migrations) {
+		if _, exists := applied[migration.Version]; !exists {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// options builds the Run options for a direction and target
+func (m *Migrator) options(direction Direction, target string) MigrateOptions {
+	migrations := m.Migrations
+	if migrations == nil {
+		migrations = []Migration{}
+	}
+	return MigrateOptions{
+		Direction:  direction,
+		Tracker:    m.tracker,
+		Target:     target,
+		Migrations: migrations,
+		Purge:      m.Purge,
+		OnEvent:    m.OnEvent,
+	}
+}
+
+// MemoryTracker keeps applied migrations and run history in memory
+// Nothing survives the process, so it suits tests and throwaway stores
+type MemoryTracker struct {
+	mu      sync.Mutex
+	applied map[string]MigrationInfo
+	history []MigrationRun
+	renames []RenameJournalEntry
+	renamed map[string][]Tuple // Replacements written by each unfinished rename
+}
+
+// NewMemoryTracker creates an empty in-memory tracker
+func NewMemoryTracker() *MemoryTracker {
+	return &MemoryTracker{applied: make(map[string]MigrationInfo)}
+}
+
+// GetApplied implements MigrationTracker
+func (t *MemoryTracker) GetApplied(ctx context.Context) (map[string]MigrationInfo, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	applied := make(map[string]MigrationInfo, len(t.applied))
+	for version, info := range t.applied {
+		applied[version] = info
+	}
+	return applied, nil
+}
+
+// RecordRunWithOptions implements MigrationTracker
+// Rolled back migrations are always forgotten, so opts has no effect
+func (t *MemoryTracker) RecordRunWithOptions(ctx context.Context, run MigrationRun, opts RemoveOptions) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch run.Status {
+	case RunStatusApplied:
+		if _, exists := t.applied[run.Version]; exists {
+			return fmt.Errorf("failed to record migration: %s is already applied", run.Version)
+		}
+		t.applied[run.Version] = MigrationInfo{Version: run.Version, Name: run.Name, AppliedAt: run.StartedAt}
+	case RunStatusRolledBack:
+		delete(t.applied, run.Version)
+	case RunStatusFailed:
+		// History only
+	default:
+		return fmt.Errorf("unknown run status '%s'", run.Status)
+	}
+
+	t.history = append(t.history, run)
+	return nil
+}
+
+// History returns every recorded run, oldest first
+func (t *MemoryTracker) History(ctx context.Context) ([]MigrationRun, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]MigrationRun(nil), t.history...), nil
+}
@@ -0,0 +1,129 @@
+package omg_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/demetere/omg/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingMigrations returns migrations that append "<direction> <version>" to calls
+func recordingMigrations(calls *[]string, versions ...string) []omg.Migration {
+	var migrations []omg.Migration
+	for _, version := range versions {
+		version := version
+		migrations = append(migrations, omg.Migration{
+			Version: version,
+			Name:    "migration_" + version,
+			Up: func(ctx context.Context, client *omg.Client) error {
+				*calls = append(*calls, "up "+version)
+				return nil
+			},
+			Down: func(ctx context.Context, client *omg.Client) error {
+				*calls = append(*calls, "down "+version)
+				return nil
+			},
+		})
+	}
+	return migrations
+}
+
+func TestMigrator_UpAndDown(t *testing.T) {
+	ctx := context.Background()
+	var calls []string
+
+	tracker := omg.NewMemoryTracker()
+	migrator := omg.NewMigrator(nil, tracker)
+	migrator.Migrations = recordingMigrations(&calls, "003", "001", "002")
+
+	runs, err := migrator.UpTo(ctx, "002")
+	require.NoError(t, err)
+	assert.Len(t, runs, 2)
+	assert.Equal(t, []string{"up 001", "up 002"}, calls)
+
+	runs, err = migrator.Up(ctx)
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	assert.Equal(t, "003", runs[0].Version)
+
+	runs, err = migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, runs)
+
+	_, err = migrator.Down(ctx)
+	require.NoError(t, err)
+	_, err = migrator.DownTo(ctx, "0")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"up 001", "up 002", "up 003", "down 003", "down 002", "down 001"}, calls)
+
+	applied, err := tracker.GetApplied(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, applied)
+
+	history, err := tracker.History(ctx)
+	require.NoError(t, err)
+	assert.Len(t, history, 6)
+
+	_, err = migrator.UpTo(ctx, "999")
+	assert.Error(t, err)
+}
+
+func TestMigrator_FailedMigrationStopsRun(t *testing.T) {
+	ctx := context.Background()
+	var calls []string
+
+	tracker := omg.NewMemoryTracker()
+	migrator := omg.NewMigrator(nil, tracker)
+	migrator.Migrations = recordingMigrations(&calls, "001", "002", "003")
+	migrator.Migrations[1].Up = func(ctx context.Context, client *omg.Client) error {
+		return errors.New("boom")
+	}
+
+	var events []omg.MigrationEventType
+	migrator.OnEvent = func(e omg.MigrationEvent) { events = append(events, e.Type) }
+
+	runs, err := migrator.Up(ctx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "boom")
+	require.Len(t, runs, 2)
+	assert.Equal(t, omg.RunStatusFailed, runs[1].Status)
+	assert.Equal(t, []string{"up 001"}, calls)
+	assert.Equal(t, []omg.MigrationEventType{
+		omg.EventRunStarted,
+		omg.EventMigrationStarted, omg.EventMigrationCompleted,
+		omg.EventMigrationStarted, omg.EventMigrationFailed,
+		omg.EventRunFinished,
+	}, events)
+
+	statuses, err := migrator.Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, statuses, 3)
+	assert.True(t, statuses[0].Applied)
+	assert.False(t, statuses[1].Applied)
+	assert.False(t, statuses[2].Applied)
+
+	pending, err := migrator.Pending(ctx)
+	require.NoError(t, err)
+	assert.Len(t, pending, 2)
+}
+
+func TestMigrator_StatusIncludesUnregistered(t *testing.T) {
+	ctx := context.Background()
+	var calls []string
+
+	tracker := omg.NewMemoryTracker()
+	require.NoError(t, tracker.RecordRunWithOptions(ctx, omg.MigrationRun{Version: "000", Name: "removed", Status: omg.RunStatusApplied}, omg.RemoveOptions{}))
+
+	migrator := omg.NewMigrator(nil, tracker)
+	migrator.Migrations = recordingMigrations(&calls, "001")
+
+	statuses, err := migrator.Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, statuses, 2)
+	assert.Equal(t, omg.MigrationStatus{Version: "000", Name: "removed", Applied: true}, statuses[0])
+	assert.True(t, statuses[1].Registered)
+	assert.False(t, statuses[1].Applied)
+}
@@ -0,0 +1,236 @@
+package omg
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// StrategyIncremental generates one model helper call per change (AddTypeToModel,
+	// AddRelationToType, ...). It is the default
+	StrategyIncremental = "incremental"
+
+	// StrategyModelApply embeds the target model in the migration and applies it as a
+	// whole with ApplyModelFromDSL; down applies the prior model. Helper calls are left
+	// for the tuples of renames, moves and removals
+	StrategyModelApply = "model-apply"
+)
+
+// checkStrategy validates the generation strategy of opts
+func checkStrategy(opts GenerateOptions) error {
+	switch opts.Strategy {
+	case "", StrategyIncremental:
+		return nil
+	case StrategyModelApply:
+		if strings.TrimSpace(opts.TargetModel) == "" {
+			return fmt.Errorf("the %s strategy needs the target model", StrategyModelApply)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown strategy '%s' (expected %s or %s)", opts.Strategy, StrategyIncremental, StrategyModelApply)
+}
+
+// generateModelApplyUp applies the target model, then migrates the tuples of renames
+// and moves and deletes those of removals. Removals, and low confidence renames treated
+// as removals, are backed up first, while the live model still defines them
+func generateModelApplyUp(changes []ModelChange, opts GenerateOptions) string {
+	var builder strings.Builder
+	ordered := orderChangesForUp(changes)
+
+	if !opts.ModelOnly {
+		for _, change := range ordered {
+			switch {
+			case change.Type == ChangeTypeRemoveRelation || change.Type == ChangeTypeRemoveType:
+				builder.WriteString(generateRemovalBackup(change, opts))
+			case change.Type == ChangeTypeRenameRelation && change.Confidence == ConfidenceLow:
+				builder.WriteString(generateRemovalBackup(ModelChange{TypeName: change.TypeName, RelationName: change.OldValue}, opts))
+			case change.Type == ChangeTypeRenameType && change.Confidence == ConfidenceLow:
+				builder.WriteString(generateRemovalBackup(ModelChange{TypeName: change.OldValue}, opts))
+			}
+		}
+	}
+
+	builder.WriteString(generateApplyModel("Apply the target model: every type, relation and condition change at once", opts.TargetModel))
+	if opts.ModelOnly {
+		return builder.String()
+	}
+
+	for _, change := range ordered {
+		switch change.Type {
+		case ChangeTypeUpdateRelation:
+			if opts.Backfill {
+				builder.WriteString(generateRelationBackfill(change))
+			}
+
+		case ChangeTypeRenameRelation:
+			if change.Confidence == ConfidenceLow {
+				builder.WriteString(generateDeleteRelationTuples(change.TypeName, change.OldValue,
+					fmt.Sprintf("Potential relation rename %s.%s -> %s.%s (low confidence), treated as separate relations",
+						change.TypeName, change.OldValue, change.TypeName, change.NewValue)))
+				continue
+			}
+			builder.WriteString(generateRenameRelation(change, opts))
+
+		case ChangeTypeMoveRelation:
+			builder.WriteString(generateMoveTuples(change.TypeName, change.TargetType, change.RelationName, opts))
+
+		case ChangeTypeRenameType:
+			if change.Confidence == ConfidenceLow {
+				builder.WriteString(generateDeleteTypeTuples(change.OldValue,
+					fmt.Sprintf("Potential type rename %s -> %s (low confidence), treated as separate types",
+						change.OldValue, change.NewValue)))
+				continue
+			}
+			builder.WriteString(generateRenameType(change, opts))
+
+		case ChangeTypeRemoveRelation:
+			builder.WriteString(generateDeleteRelationTuples(change.TypeName, change.RelationName,
+				fmt.Sprintf("Removed relation: %s.%s", change.TypeName, change.RelationName)))
+
+		case ChangeTypeRemoveType:
+			builder.WriteString(generateDeleteTypeTuples(change.TypeName, "Removed type: "+change.TypeName))
+		}
+	}
+	return builder.String()
+}
+
+// generateModelApplyDown applies the prior model, then moves renamed and moved tuples
+// back and restores removed tuples, including those of low confidence renames, from
+// the backups taken by up
+func generateModelApplyDown(changes []ModelChange, opts GenerateOptions) string {
+	var builder strings.Builder
+
+	if strings.TrimSpace(opts.PriorModel) == "" {
+		builder.WriteString("\t// The store had no model before this migration, so there is none to go back to\n\n")
+	} else {
+		builder.WriteString(generateApplyModel("Apply the model from before this migration", opts.PriorModel))
+	}
+	if opts.ModelOnly {
+		return builder.String()
+	}
+
+	for _, change := range orderChangesForDown(changes) {
+		switch change.Type {
+		case ChangeTypeRenameRelation:
+			if change.Confidence == ConfidenceLow {
+				builder.WriteString(generateRestoreFromBackup(ModelChange{TypeName: change.TypeName, RelationName: change.OldValue}))
+				continue
+			}
+			builder.WriteString(generateRenameRelation(ModelChange{
+				TypeName:     change.TypeName,
+				RelationName: change.NewValue,
+				OldValue:     change.NewValue,
+				NewValue:     change.OldValue,
+			}, opts))
+
+		case ChangeTypeMoveRelation:
+			builder.WriteString(generateMoveTuples(change.TargetType, change.TypeName, change.RelationName, opts))
+
+		case ChangeTypeRenameType:
+			if change.Confidence == ConfidenceLow {
+				builder.WriteString(generateRestoreFromBackup(ModelChange{TypeName: change.OldValue}))
+				continue
+			}
+			builder.WriteString(generateRenameType(ModelChange{
+				TypeName: change.NewValue,
+				OldValue: change.NewValue,
+				NewValue: change.OldValue,
+			}, opts))
+
+		case ChangeTypeRemoveRelation, ChangeTypeRemoveType:
+			builder.WriteString(generateRestoreFromBackup(change))
+		}
+	}
+	return builder.String()
+}
+
+// generateApplyModel writes a whole model with ApplyModelFromDSL
+func generateApplyModel(comment, dsl string) string {
+	return fmt.Sprintf(`	// %s
+	if err := omg.ApplyModelFromDSL(ctx, client, %s); err != nil {
+		return fmt.Errorf("failed to apply model: %%w", err)
+	}
+
+`, comment, goStringLiteral(dsl))
+}
+
+// goStringLiteral quotes s as a Go raw string when it can be one
+func goStringLiteral(s string) string {
+	if strings.Contains(s, "`") || strings.Contains(s, "\r") {
+		return strconv.Quote(s)
+	}
+	return "`" + s + "`"
+}
+
+// generateMoveTuples moves a relation's tuples to another type; the model already
+// defines the relation on both sides or neither
+func generateMoveTuples(fromType, toType, relation string, opts GenerateOptions) string {
+	return fmt.Sprintf(`	// Move relation tuples: %s.%s -> %s.%s
+	if err := %s; err != nil {
+		return fmt.Errorf("failed to move relation: %%w", err)
+	}
+
+`, fromType, relation, toType, relation, helperCall(opts, "MoveRelation", fmt.Sprintf(`"%s", "%s", "%s"`, fromType, toType, relation)))
+}
+
+// generateDeleteRelationTuples deletes the tuples of a relation the model no longer has
+func generateDeleteRelationTuples(typeName, relation, comment string) string {
+	return fmt.Sprintf(`	// %s
+	if err := omg.DeleteRelation(ctx, client, "%s", "%s"); err != nil {
+		return fmt.Errorf("failed to delete tuples: %%w", err)
+	}
+
+`, comment, typeName, relation)
+}
+
+// generateDeleteTypeTuples deletes the tuples of a type the model no longer has
+func generateDeleteTypeTuples(typeName, comment string) string {
+	return fmt.Sprintf(`	// %s
+	{
+		tuples, err := omg.ReadAllTuples(ctx, client, "%s", "")
+		if err != nil {
+			return fmt.Errorf("failed to read tuples: %%w", err)
+		}
+		if len(tuples) > 0 {
+			fmt.Printf("Deleting %%d tuples of type %s\n", len(tuples))
+			if err := omg.DeleteTuplesBatch(ctx, client, tuples); err != nil {
+				return fmt.Errorf("failed to delete tuples: %%w", err)
+			}
+		}
+	}
+
+`, comment, typeName, typeName)
+}
+
+// modelApplyOperations lists the helpers called for a change with StrategyModelApply
+func modelApplyOperations(change ModelChange, opts GenerateOptions) []string {
+	operations := []string{"ApplyModelFromDSL"}
+	if opts.ModelOnly {
+		return operations
+	}
+
+	switch change.Type {
+	case ChangeTypeUpdateRelation:
+		if opts.Backfill && len(addedComputedRelations(change.OldValue, change.NewValue)) > 0 {
+			operations = append(operations, "ReadAllTuples (backfill analysis)")
+		}
+	case ChangeTypeRenameRelation:
+		if change.Confidence == ConfidenceLow {
+			return []string{"BackupForRemoval", "ApplyModelFromDSL", "DeleteRelation"}
+		}
+		return append(operations, "RenameRelation")
+	case ChangeTypeMoveRelation:
+		return append(operations, "MoveRelation")
+	case ChangeTypeRenameType:
+		if change.Confidence == ConfidenceLow {
+			return []string{"BackupForRemoval", "ApplyModelFromDSL", "ReadAllTuples", "DeleteTuplesBatch"}
+		}
+		return append(operations, "RenameType")
+	case ChangeTypeRemoveRelation:
+		return []string{"BackupForRemoval", "ApplyModelFromDSL", "DeleteRelation"}
+	case ChangeTypeRemoveType:
+		return []string{"BackupForRemoval", "ApplyModelFromDSL", "ReadAllTuples", "DeleteTuplesBatch"}
+	}
+	return operations
+}
@@ -0,0 +1,114 @@
+package omg_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/demetere/omg/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	modelApplyPrior = `model
+  schema 1.1
+
+type user
+
+type document
+  relations
+    define reader: [user]
+    define legacy: [user]
+`
+	modelApplyTarget = `model
+  schema 1.1
+
+type user
+
+type folder
+  relations
+    define owner: [user]
+
+type document
+  relations
+    define viewer: [user]
+`
+)
+
+func TestGenerateMigration_ModelApply(t *testing.T) {
+	changes := []omg.ModelChange{
+		{Type: omg.ChangeTypeAddType, TypeName: "folder", Details: "New type 'folder' with 1 relations"},
+		{Type: omg.ChangeTypeAddRelation, TypeName: "folder", RelationName: "owner", NewValue: "[user]", Details: "Add relation 'folder.owner'"},
+		{Type: omg.ChangeTypeRenameRelation, TypeName: "document", RelationName: "reader", OldValue: "reader", NewValue: "viewer", Confidence: omg.ConfidenceHigh, Details: "Rename detected"},
+		{Type: omg.ChangeTypeRemoveRelation, TypeName: "document", RelationName: "legacy", OldValue: "[user]", Details: "Removed relation"},
+	}
+	opts := omg.GenerateOptions{Strategy: omg.StrategyModelApply, TargetModel: modelApplyTarget, PriorModel: modelApplyPrior}
+
+	filename, err := omg.GenerateMigrationFromChangesWithOptions(changes, "model_apply", "migrations", opts)
+	require.NoError(t, err)
+	defer os.Remove(filename)
+
+	content, err := os.ReadFile(filename)
+	require.NoError(t, err)
+
+	code := string(content)
+	downStart := strings.Index(code, "func down(")
+	upCode, downCode := code[:downStart], code[downStart:]
+
+	// up backs up removals, applies the target model, then migrates tuples
+	assert.Contains(t, upCode, "omg.ApplyModelFromDSL(ctx, client, `"+modelApplyTarget+"`)")
+	assert.NotContains(t, upCode, "AddTypeToModel")
+	assert.NotContains(t, upCode, "AddRelationToType")
+	assert.NotContains(t, upCode, "RemoveRelationFromType")
+	assert.Less(t, strings.Index(upCode, "BackupForRemoval"), strings.Index(upCode, "ApplyModelFromDSL"))
+	assert.Less(t, strings.Index(upCode, "ApplyModelFromDSL"), strings.Index(upCode, `omg.RenameRelation(ctx, client, "document", "reader", "viewer")`))
+	assert.Contains(t, upCode, `omg.DeleteRelation(ctx, client, "document", "legacy")`)
+
+	// down applies the prior model, then moves the tuples back
+	assert.Contains(t, downCode, "omg.ApplyModelFromDSL(ctx, client, `"+modelApplyPrior+"`)")
+	assert.Contains(t, downCode, `omg.RenameRelation(ctx, client, "document", "viewer", "reader")`)
+	assert.Contains(t, downCode, `omg.BackupFilePath(migrationVersion, "document", "legacy")`)
+
+	summary := omg.BuildGenerateSummary(filename, changes, opts)
+	require.Len(t, summary.Changes, 4)
+	assert.Equal(t, []string{"ApplyModelFromDSL"}, summary.Changes[0].Operations)
+	assert.Equal(t, []string{"ApplyModelFromDSL", "RenameRelation"}, summary.Changes[2].Operations)
+	assert.Equal(t, []string{"BackupForRemoval", "ApplyModelFromDSL", "DeleteRelation"}, summary.Changes[3].Operations)
+}
+
+func TestGenerateMigration_ModelApplyLowConfidenceRename(t *testing.T) {
+	changes := []omg.ModelChange{
+		{Type: omg.ChangeTypeRenameRelation, TypeName: "document", RelationName: "reader", OldValue: "reader", NewValue: "viewer", Confidence: omg.ConfidenceLow, Details: "Potential rename"},
+	}
+	opts := omg.GenerateOptions{Strategy: omg.StrategyModelApply, TargetModel: modelApplyTarget, PriorModel: modelApplyPrior}
+
+	filename, err := omg.GenerateMigrationFromChangesWithOptions(changes, "model_apply_low", t.TempDir(), opts)
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(filename)
+	require.NoError(t, err)
+
+	code := string(content)
+	downStart := strings.Index(code, "func down(")
+	upCode, downCode := code[:downStart], code[downStart:]
+
+	// The old relation is backed up while the live model still has it, then restored by down
+	assert.Less(t, strings.Index(upCode, `omg.BackupForRemoval(ctx, client, migrationVersion, "document", "reader")`), strings.Index(upCode, "ApplyModelFromDSL"))
+	assert.Contains(t, upCode, `omg.DeleteRelation(ctx, client, "document", "reader")`)
+	assert.Contains(t, downCode, `omg.BackupFilePath(migrationVersion, "document", "reader")`)
+}
+
+func TestGenerateMigration_ModelApplyOptions(t *testing.T) {
+	changes := []omg.ModelChange{{Type: omg.ChangeTypeAddType, TypeName: "folder", Details: "New type"}}
+	dir := t.TempDir()
+
+	_, err := omg.GenerateMigrationFromChangesWithOptions(changes, "no_target", dir, omg.GenerateOptions{Strategy: omg.StrategyModelApply})
+	assert.ErrorContains(t, err, "needs the target model")
+
+	_, err = omg.GenerateMigrationFromChangesWithOptions(changes, "unknown", dir, omg.GenerateOptions{Strategy: "replace"})
+	assert.ErrorContains(t, err, "unknown strategy")
+
+	_, err = omg.GenerateSplitMigrations(changes, "split", dir, omg.GenerateOptions{Strategy: omg.StrategyModelApply, TargetModel: modelApplyTarget})
+	assert.ErrorContains(t, err, "cannot be split")
+}
@@ -0,0 +1,97 @@
+package omg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	openfgaSdk "github.com/openfga/go-sdk"
+)
+
+// jsonModel is the OpenFGA API form of a model, as the fga CLI and the API's
+// WriteAuthorizationModel request hold it; the model ID is left out
+type jsonModel struct {
+	SchemaVersion   string                           `json:"schema_version"`
+	TypeDefinitions []openfgaSdk.TypeDefinition      `json:"type_definitions"`
+	Conditions      *map[string]openfgaSdk.Condition `json:"conditions,omitempty"`
+}
+
+// IsJSONModel reports whether a model file holds the JSON API format rather than DSL
+func IsJSONModel(content string) bool {
+	return strings.HasPrefix(strings.TrimSpace(content), "{")
+}
+
+// ParseJSONToModel parses a model in the OpenFGA JSON API format
+// Both a bare model and a ReadAuthorizationModel response ({"authorization_model": {...}}) are accepted
+func ParseJSONToModel(content string) (openfgaSdk.AuthorizationModel, error) {
+	var wrapped struct {
+		AuthorizationModel *openfgaSdk.AuthorizationModel `json:"authorization_model"`
+	}
+	if err := json.Unmarshal([]byte(content), &wrapped); err != nil {
+		return openfgaSdk.AuthorizationModel{}, fmt.Errorf("failed to parse JSON model: %w", err)
+	}
+	if wrapped.AuthorizationModel != nil {
+		return *wrapped.AuthorizationModel, ValidateModelSchema(*wrapped.AuthorizationModel)
+	}
+
+	var model openfgaSdk.AuthorizationModel
+	if err := json.Unmarshal([]byte(content), &model); err != nil {
+		return model, fmt.Errorf("failed to parse JSON model: %w", err)
+	}
+	if model.SchemaVersion == "" {
+		return model, fmt.Errorf("failed to parse JSON model: schema_version is missing")
+	}
+	if err := ValidateModelSchema(model); err != nil {
+		return model, err
+	}
+	return model, nil
+}
+
+// ParseModel parses a model file in either format: JSON when it starts with '{', DSL otherwise
+func ParseModel(content string) (openfgaSdk.AuthorizationModel, error) {
+	if IsJSONModel(content) {
+		return ParseJSONToModel(content)
+	}
+	return ParseDSLToModel(content)
+}
+
+// FormatModelAsJSON writes a model in the OpenFGA JSON API format, indented
+func FormatModelAsJSON(model openfgaSdk.AuthorizationModel) (string, error) {
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+
+	err := encoder.Encode(jsonModel{
+		SchemaVersion:   model.SchemaVersion,
+		TypeDefinitions: model.TypeDefinitions,
+		Conditions:      model.Conditions,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode model: %w", err)
+	}
+	return out.String(), nil
+}
+
+// FormatModelAsDSL writes a model in OpenFGA DSL, in the canonical form of FormatDSL
+func FormatModelAsDSL(model openfgaSdk.AuthorizationModel) (string, error) {
+	return FormatDSL(formatModelAsDSL(model))
+}
+
+// ConvertModel translates a model file to "dsl" or "json"
+func ConvertModel(content, format string) (string, error) {
+	model, err := ParseModel(content)
+	if err != nil {
+		return "", err
+	}
+
+	switch format {
+	case "dsl":
+		return FormatModelAsDSL(model)
+	case "json":
+		return FormatModelAsJSON(model)
+	default:
+		return "", fmt.Errorf("unknown model format '%s' (expected dsl or json)", format)
+	}
+}
@@ -0,0 +1,67 @@
+package omg_test
+
+import (
+	"testing"
+
+	"github.com/demetere/omg/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const jsonTestModel = `model
+  schema 1.1
+
+type user
+
+type folder
+  relations
+    define owner: [user]
+
+type document
+  relations
+    define owner: [user with non_expired]
+    define parent: [folder]
+    define viewer: [user, user:*] or owner from parent
+
+condition non_expired(expires_at: timestamp, now: timestamp) {
+  now < expires_at
+}
+`
+
+func TestConvertModel_RoundTrip(t *testing.T) {
+	modelJSON, err := omg.ConvertModel(jsonTestModel, "json")
+	require.NoError(t, err)
+	assert.True(t, omg.IsJSONModel(modelJSON))
+	assert.Contains(t, modelJSON, `"schema_version": "1.1"`)
+	assert.NotContains(t, modelJSON, `"id"`)
+
+	fromJSON, err := omg.ParseModel(modelJSON)
+	require.NoError(t, err)
+	fromDSL, err := omg.ParseModel(jsonTestModel)
+	require.NoError(t, err)
+	assert.Empty(t, omg.DetectChanges(omg.BuildModelState(fromDSL), omg.BuildModelState(fromJSON)))
+
+	dsl, err := omg.ConvertModel(modelJSON, "dsl")
+	require.NoError(t, err)
+	assert.Equal(t, jsonTestModel, dsl)
+}
+
+func TestParseJSONToModel_ReadResponse(t *testing.T) {
+	model, err := omg.ParseJSONToModel(`{"authorization_model": {"id": "01HVMMBCMGZNT3SED4Z17ECXCA", "schema_version": "1.1",
+		"type_definitions": [{"type": "user"}, {"type": "document", "relations": {"viewer": {"this": {}}},
+		"metadata": {"relations": {"viewer": {"directly_related_user_types": [{"type": "user"}]}}}}]}}`)
+	require.NoError(t, err)
+	assert.Equal(t, "01HVMMBCMGZNT3SED4Z17ECXCA", model.Id)
+	assert.Equal(t, "[user]", omg.BuildModelState(model).Types["document"].Relations["viewer"])
+}
+
+func TestParseJSONToModel_Invalid(t *testing.T) {
+	_, err := omg.ParseJSONToModel(`{"type_definitions": []}`)
+	assert.ErrorContains(t, err, "schema_version is missing")
+
+	_, err = omg.ParseJSONToModel(`{"schema_version": `)
+	assert.ErrorContains(t, err, "failed to parse JSON model")
+
+	_, err = omg.ConvertModel(jsonTestModel, "yaml")
+	assert.ErrorContains(t, err, "unknown model format 'yaml'")
+}
@@ -0,0 +1,140 @@
+package omg
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ModelLockFile is the default name of the model lock file
+const ModelLockFile = "model.lock"
+
+// ModelLock records the authorization model the store is expected to be on
+// It is written after migrations are applied and checked before the next run,
+// so out-of-band model edits are caught before migrations build on them
+type ModelLock struct {
+	ModelID   string    `json:"model_id"`
+	ModelHash string    `json:"model_hash"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// HashModelState returns a stable hash of a model state
+// Types and relations are sorted so the hash only changes when the model does
+func HashModelState(state *ModelState) string {
+	var b strings.Builder
+
+	typeNames := make([]string, 0, len(state.Types))
+	for name := range state.Types {
+		typeNames = append(typeNames, name)
+	}
+	sort.Strings(typeNames)
+
+	for _, typeName := range typeNames {
+		b.WriteString("type " + typeName + "\n")
+
+		relations := state.Types[typeName].Relations
+		relNames := make([]string, 0, len(relations))
+		for name := range relations {
+			relNames = append(relNames, name)
+		}
+		sort.Strings(relNames)
+
+		for _, relName := range relNames {
+			b.WriteString("  define " + relName + ": " + relations[relName] + "\n")
+		}
+	}
+
+	conditionNames := make([]string, 0, len(state.Conditions))
+	for name := range state.Conditions {
+		conditionNames = append(conditionNames, name)
+	}
+	sort.Strings(conditionNames)
+	for _, name := range conditionNames {
+		b.WriteString(state.Conditions[name] + "\n")
+	}
+
+	sum := sha256.Sum256([]byte(b.String()))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// LoadModelLockFromOpenFGA builds a lock from the store's latest authorization model
+func LoadModelLockFromOpenFGA(ctx context.Context, client *Client) (ModelLock, error) {
+	model, err := client.GetCurrentAuthorizationModel(ctx)
+	if err != nil {
+		return ModelLock{}, err
+	}
+
+	return ModelLock{
+		ModelID:   model.GetId(),
+		ModelHash: HashModelState(BuildModelStateFromAuthorizationModel(model)),
+		UpdatedAt: time.Now().UTC(),
+	}, nil
+}
+
+// ReadModelLock reads a lock file
+// Returns nil without error when the file does not exist
+func ReadModelLock(path string) (*ModelLock, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lock file: %w", err)
+	}
+
+	var lock ModelLock
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("failed to parse lock file %s: %w", path, err)
+	}
+	return &lock, nil
+}
+
+// WriteModelLock writes a lock file
+func WriteModelLock(path string, lock ModelLock) error {
+	data, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode lock file: %w", err)
+	}
+
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write lock file: %w", err)
+	}
+	return nil
+}
+
+// UpdateModelLock records the store's current model in the lock file
+func UpdateModelLock(ctx context.Context, client *Client, path string) (ModelLock, error) {
+	lock, err := LoadModelLockFromOpenFGA(ctx, client)
+	if err != nil {
+		return ModelLock{}, err
+	}
+	return lock, WriteModelLock(path, lock)
+}
+
+// VerifyModelLock checks that the store's live model matches the lock file
+// A missing lock file is not an error. A differing model ID with identical
+// content is accepted, since rewriting the same model creates a new ID
+func VerifyModelLock(ctx context.Context, client *Client, path string) error {
+	lock, err := ReadModelLock(path)
+	if err != nil || lock == nil {
+		return err
+	}
+
+	live, err := LoadModelLockFromOpenFGA(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	if live.ModelHash != lock.ModelHash {
+		return fmt.Errorf("live model %s (%s) does not match %s (model %s, %s); the store was changed outside of omg",
+			live.ModelID, live.ModelHash, path, lock.ModelID, lock.ModelHash)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package omg_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/demetere/omg/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashModelState_Stable(t *testing.T) {
+	state := &omg.ModelState{
+		Types: map[string]omg.TypeState{
+			"user": {Name: "user", Relations: map[string]string{}},
+			"document": {Name: "document", Relations: map[string]string{
+				"owner":  "[user]",
+				"viewer": "[user] or owner",
+			}},
+		},
+	}
+
+	hash := omg.HashModelState(state)
+	assert.Contains(t, hash, "sha256:")
+
+	// Same content, built in a different order, hashes the same
+	same := &omg.ModelState{
+		Types: map[string]omg.TypeState{
+			"document": {Name: "document", Relations: map[string]string{
+				"viewer": "[user] or owner",
+				"owner":  "[user]",
+			}},
+			"user": {Name: "user", Relations: map[string]string{}},
+		},
+	}
+	assert.Equal(t, hash, omg.HashModelState(same))
+
+	// Any definition change produces a different hash
+	state.Types["document"].Relations["viewer"] = "[user]"
+	assert.NotEqual(t, hash, omg.HashModelState(state))
+}
+
+func TestModelLock_ReadWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), omg.ModelLockFile)
+
+	// Missing lock file is not an error
+	lock, err := omg.ReadModelLock(path)
+	require.NoError(t, err)
+	assert.Nil(t, lock)
+
+	written := omg.ModelLock{
+		ModelID:   "01HXYZ",
+		ModelHash: "sha256:abc",
+		UpdatedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	require.NoError(t, omg.WriteModelLock(path, written))
+
+	lock, err = omg.ReadModelLock(path)
+	require.NoError(t, err)
+	require.NotNil(t, lock)
+	assert.Equal(t, written, *lock)
+}
@@ -0,0 +1,230 @@
+package omg
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ModelManifestFile is the manifest of a modular model, listing its module files
+const ModelManifestFile = "fga.mod"
+
+// modelManifest is the content of fga.mod:
+//
+//	schema: '1.2'
+//	contents:
+//	  - core.fga
+//	  - issue-tracker/projects.fga
+type modelManifest struct {
+	Schema   string   `yaml:"schema"`
+	Contents []string `yaml:"contents"`
+}
+
+// moduleBlock is a top-level block of a module file with the comment lines above it
+type moduleBlock struct {
+	kind  string // "type", "extend" or "condition"
+	name  string
+	lines []string
+}
+
+// isModelManifest reports whether a model path names a modular model: an fga.mod file,
+// or a directory holding one
+func isModelManifest(path string) bool {
+	if filepath.Base(path) == ModelManifestFile {
+		return true
+	}
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// LoadModularModel reads a modular model and merges its modules into one model in DSL
+// path is an fga.mod manifest or the directory holding it. Module files are read in the
+// order the manifest lists them; "extend type" blocks add their relations to the type they
+// extend, wherever it is defined. Module headers are dropped and the schema version is the
+// manifest's
+func LoadModularModel(path string) (string, error) {
+	if filepath.Base(path) != ModelManifestFile {
+		path = filepath.Join(path, ModelManifestFile)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	var manifest modelManifest
+	if err := yaml.Unmarshal(data, &manifest); err != nil {
+		return "", fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if len(manifest.Contents) == 0 {
+		return "", fmt.Errorf("%s lists no module files under 'contents'", path)
+	}
+	if manifest.Schema == "" {
+		manifest.Schema = LatestSchemaVersion
+	}
+	if err := validateModuleSchema(manifest.Schema); err != nil {
+		return "", fmt.Errorf("%s: %w", path, err)
+	}
+
+	var types, extensions, conditions []moduleBlock
+	for _, file := range manifest.Contents {
+		filePath := filepath.Join(filepath.Dir(path), file)
+		content, err := os.ReadFile(filePath)
+		if err != nil {
+			return "", fmt.Errorf("failed to read module %s: %w", file, err)
+		}
+		blocks, err := splitModuleBlocks(string(content))
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", file, err)
+		}
+		for _, block := range blocks {
+			switch block.kind {
+			case "type":
+				types = append(types, block)
+			case "extend":
+				extensions = append(extensions, block)
+			case "condition":
+				conditions = append(conditions, block)
+			}
+		}
+	}
+
+	return composeModules(manifest.Schema, types, extensions, conditions)
+}
+
+// splitModuleBlocks splits a module file into its type, extend type and condition blocks
+// The module and model headers are dropped
+func splitModuleBlocks(content string) ([]moduleBlock, error) {
+	var blocks []moduleBlock
+	var pending []string
+	var current *moduleBlock
+
+	lines := strings.Split(content, "\n")
+	for i := 0; i < len(lines); i++ {
+		raw := strings.TrimRight(lines[i], " \t\r")
+		line := strings.TrimSpace(raw)
+		indented := line != raw
+
+		switch {
+		case line == "":
+			continue
+
+		case indented:
+			// Body of the current block; indented lines of a header are dropped
+			if current != nil {
+				current.lines = append(current.lines, raw)
+			}
+			continue
+
+		case strings.HasPrefix(line, "#"):
+			pending = append(pending, raw)
+			continue
+		}
+
+		current = nil
+		switch {
+		case line == "model" || strings.HasPrefix(line, "module "):
+			pending = nil
+
+		case strings.HasPrefix(line, "type "):
+			name := strings.TrimSpace(strings.TrimPrefix(line, "type"))
+			blocks = append(blocks, moduleBlock{kind: "type", name: name, lines: append(pending, raw)})
+			current = &blocks[len(blocks)-1]
+			pending = nil
+
+		case strings.HasPrefix(line, "extend type "):
+			// Comments above the extension move into the extended type with its relations
+			name := strings.TrimSpace(strings.TrimPrefix(line, "extend type"))
+			var comments []string
+			for _, comment := range pending {
+				comments = append(comments, "    "+comment)
+			}
+			blocks = append(blocks, moduleBlock{kind: "extend", name: name, lines: comments})
+			current = &blocks[len(blocks)-1]
+			pending = nil
+
+		case strings.HasPrefix(line, "condition "):
+			end, err := conditionBlockEnd(lines, i)
+			if err != nil {
+				return nil, err
+			}
+			blocks = append(blocks, moduleBlock{kind: "condition", lines: append(pending, lines[i:end+1]...)})
+			pending = nil
+			i = end
+
+		default:
+			return nil, fmt.Errorf("line %d: unexpected %q", i+1, line)
+		}
+	}
+	return blocks, nil
+}
+
+// composeModules writes the blocks of all modules as one model, with each extension's
+// relations added to the type it extends
+func composeModules(schema string, types, extensions, conditions []moduleBlock) (string, error) {
+	byName := make(map[string]*moduleBlock, len(types))
+	for i := range types {
+		if _, exists := byName[types[i].name]; exists {
+			return "", fmt.Errorf("type '%s' is defined in more than one module", types[i].name)
+		}
+		byName[types[i].name] = &types[i]
+	}
+
+	for _, extension := range extensions {
+		typeBlock, exists := byName[extension.name]
+		if !exists {
+			return "", fmt.Errorf("cannot extend type '%s': it is not defined in any module", extension.name)
+		}
+		if !blockHasRelations(typeBlock.lines) {
+			typeBlock.lines = append(typeBlock.lines, "  relations")
+		}
+		for _, line := range extension.lines {
+			if strings.TrimSpace(line) == "relations" {
+				continue
+			}
+			if relation, ok := defineName(line); ok && blockDefines(typeBlock.lines, relation) {
+				return "", fmt.Errorf("relation '%s' of type '%s' is defined in more than one module", relation, extension.name)
+			}
+			typeBlock.lines = append(typeBlock.lines, line)
+		}
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "model\n  schema %s\n", schema)
+	for _, block := range append(types, conditions...) {
+		out.WriteString("\n" + strings.Join(block.lines, "\n") + "\n")
+	}
+	return out.String(), nil
+}
+
+// blockHasRelations reports whether a type block has a relations section
+func blockHasRelations(lines []string) bool {
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "relations" {
+			return true
+		}
+	}
+	return false
+}
+
+// blockDefines reports whether a type block defines the relation
+func blockDefines(lines []string, relation string) bool {
+	for _, line := range lines {
+		if name, ok := defineName(line); ok && name == relation {
+			return true
+		}
+	}
+	return false
+}
+
+// defineName returns the relation name of a "define name: ..." line
+func defineName(line string) (string, bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "define ") {
+		return "", false
+	}
+	name, _, found := strings.Cut(strings.TrimPrefix(line, "define "), ":")
+	return strings.TrimSpace(name), found
+}
@@ -0,0 +1,132 @@
+package omg_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/demetere/omg/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeModules writes module files into a temporary directory and returns it
+func writeModules(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	return dir
+}
+
+func TestLoadModularModel(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"fga.mod": "schema: '1.2'\ncontents:\n  - core.fga\n  - tracker/projects.fga\n",
+		"core.fga": `module core
+
+type user
+
+type organization
+  relations
+    define member: [user]
+    define admin: [user]
+`,
+		"tracker/projects.fga": `module issue-tracker
+
+# Projects can be created by organization admins
+extend type organization
+  relations
+    define can_create_project: admin
+
+type project
+  relations
+    define organization: [organization]
+    define viewer: member from organization
+
+condition in_region(region: string) {
+  region == 'eu'
+}
+`,
+	})
+
+	dsl, err := omg.LoadCurrentModelFromPath(dir)
+	require.NoError(t, err)
+	assert.Equal(t, `model
+  schema 1.2
+
+type user
+
+type organization
+  relations
+    define member: [user]
+    define admin: [user]
+    # Projects can be created by organization admins
+    define can_create_project: admin
+
+type project
+  relations
+    define organization: [organization]
+    define viewer: member from organization
+
+condition in_region(region: string) {
+  region == 'eu'
+}
+`, dsl)
+
+	model, err := omg.ParseDSLToModel(dsl)
+	require.NoError(t, err)
+	assert.Equal(t, "1.2", model.SchemaVersion)
+	state := omg.BuildModelState(model)
+	assert.Equal(t, "admin", state.Types["organization"].Relations["can_create_project"])
+	assert.Len(t, state.Conditions, 1)
+
+	fromManifest, err := omg.LoadCurrentModelFromPath(filepath.Join(dir, omg.ModelManifestFile))
+	require.NoError(t, err)
+	assert.Equal(t, dsl, fromManifest)
+}
+
+func TestLoadModularModel_ExtendsTypeWithoutRelations(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"fga.mod": "schema: '1.2'\ncontents: [a.fga, b.fga]\n",
+		"a.fga":   "module a\ntype user\n",
+		"b.fga":   "module b\nextend type user\n  relations\n    define self: [user]\n",
+	})
+
+	dsl, err := omg.LoadModularModel(dir)
+	require.NoError(t, err)
+	assert.Equal(t, "model\n  schema 1.2\n\ntype user\n  relations\n    define self: [user]\n", dsl)
+}
+
+func TestLoadModularModel_Conflicts(t *testing.T) {
+	tests := map[string]struct {
+		files map[string]string
+		err   string
+	}{
+		"duplicate type": {
+			files: map[string]string{"a.fga": "module a\ntype user\n", "b.fga": "module b\ntype user\n"},
+			err:   "type 'user' is defined in more than one module",
+		},
+		"unknown extended type": {
+			files: map[string]string{"a.fga": "module a\ntype user\n", "b.fga": "module b\nextend type team\n  relations\n    define member: [user]\n"},
+			err:   "cannot extend type 'team'",
+		},
+		"duplicate relation": {
+			files: map[string]string{"a.fga": "module a\ntype user\n  relations\n    define self: [user]\n", "b.fga": "module b\nextend type user\n  relations\n    define self: [user]\n"},
+			err:   "relation 'self' of type 'user' is defined in more than one module",
+		},
+		"missing module": {
+			files: map[string]string{"a.fga": "module a\ntype user\n"},
+			err:   "failed to read module b.fga",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt.files["fga.mod"] = "schema: '1.2'\ncontents: [a.fga, b.fga]\n"
+			_, err := omg.LoadModularModel(writeModules(t, tt.files))
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+}
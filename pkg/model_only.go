@@ -0,0 +1,85 @@
+package omg
+
+import (
+	"fmt"
+	"strings"
+)
+
+// checkModelOnly rejects changes that cannot be made without touching tuples
+func checkModelOnly(changes []ModelChange) error {
+	for _, change := range changes {
+		if change.Type == ChangeTypeRenameType || change.Type == ChangeTypeRenameRelation || change.Type == ChangeTypeMoveRelation {
+			return fmt.Errorf("rename %s -> %s migrates tuples, which model-only mode does not do; treat it as a removal and an addition",
+				renameFrom(change), renameTo(change))
+		}
+	}
+	return nil
+}
+
+// ModelOnlyWarnings describes the changes that would normally come with a data migration,
+// for model-only stores whose application has to take care of the tuples itself
+func ModelOnlyWarnings(changes []ModelChange) []string {
+	var warnings []string
+	for _, change := range changes {
+		switch change.Type {
+		case ChangeTypeRemoveType:
+			warnings = append(warnings, fmt.Sprintf("type %s is removed but its tuples are left in the store", change.TypeName))
+		case ChangeTypeRemoveRelation:
+			warnings = append(warnings, fmt.Sprintf("relation %s#%s is removed but its tuples are left in the store", change.TypeName, change.RelationName))
+		case ChangeTypeRenameType, ChangeTypeRenameRelation, ChangeTypeMoveRelation:
+			warnings = append(warnings, fmt.Sprintf("%s -> %s looks like a rename; tuples are not moved to the new name",
+				renameFrom(change), renameTo(change)))
+		case ChangeTypeUpdateRelation:
+			if len(addedComputedRelations(change.OldValue, change.NewValue)) > 0 {
+				warnings = append(warnings, fmt.Sprintf("relation %s#%s now includes computed relations; direct tuples it makes redundant are not cleaned up", change.TypeName, change.RelationName))
+			}
+		case ChangeTypeUpdateTypeRestrictions:
+			if removed := RemovedTypeRestrictions(change.OldValue, change.NewValue); len(removed) > 0 {
+				warnings = append(warnings, fmt.Sprintf("relation %s#%s no longer allows %s; existing tuples with them are left in the store", change.TypeName, change.RelationName, strings.Join(removed, ", ")))
+			}
+		}
+	}
+	return warnings
+}
+
+// renameFrom formats the old side of a rename or move as type or type#relation
+func renameFrom(change ModelChange) string {
+	switch change.Type {
+	case ChangeTypeRenameRelation:
+		return change.TypeName + "#" + change.OldValue
+	case ChangeTypeMoveRelation:
+		return change.TypeName + "#" + change.RelationName
+	}
+	return change.OldValue
+}
+
+// renameTo formats the new side of a rename or move as type or type#relation
+func renameTo(change ModelChange) string {
+	switch change.Type {
+	case ChangeTypeRenameRelation:
+		return change.TypeName + "#" + change.NewValue
+	case ChangeTypeMoveRelation:
+		return change.TargetType + "#" + change.RelationName
+	}
+	return change.NewValue
+}
+
+// generateModelOnlyRemoval removes a type or relation from the model and leaves its tuples
+func generateModelOnlyRemoval(change ModelChange, opts GenerateOptions) string {
+	if change.RelationName == "" {
+		return fmt.Sprintf(`	// Remove type: %s (model-only: its tuples are left in the store)
+	if err := %s; err != nil {
+		return fmt.Errorf("failed to remove type from model: %%w", err)
+	}
+
+`, change.TypeName, helperCall(opts, "RemoveTypeFromModel", fmt.Sprintf(`"%s"`, change.TypeName)))
+	}
+
+	return fmt.Sprintf(`	// Remove relation: %s.%s (model-only: its tuples are left in the store)
+	if err := %s; err != nil {
+		return fmt.Errorf("failed to remove relation from model: %%w", err)
+	}
+
+`, change.TypeName, change.RelationName,
+		helperCall(opts, "RemoveRelationFromType", fmt.Sprintf(`"%s", "%s"`, change.TypeName, change.RelationName)))
+}
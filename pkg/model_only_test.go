@@ -0,0 +1,75 @@
+package omg_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/demetere/omg/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateMigration_ModelOnly(t *testing.T) {
+	dir := t.TempDir()
+	changes := []omg.ModelChange{
+		{Type: omg.ChangeTypeRemoveRelation, TypeName: "document", RelationName: "legacy", OldValue: "[user]", Details: "Removed relation 'document.legacy'"},
+		{Type: omg.ChangeTypeRemoveType, TypeName: "folder", Details: "Removed type 'folder'"},
+	}
+
+	filename, err := omg.GenerateMigrationFromChangesWithOptions(changes, "drop_legacy", dir, omg.GenerateOptions{ModelOnly: true, Backfill: true})
+	require.NoError(t, err)
+	content, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	code := string(content)
+
+	assert.Contains(t, code, `omg.RemoveRelationFromType(ctx, client, "document", "legacy")`)
+	assert.Contains(t, code, `omg.RemoveTypeFromModel(ctx, client, "folder")`)
+	assert.NotContains(t, code, "BackupForRemoval")
+	assert.NotContains(t, code, "DeleteRelation")
+	assert.NotContains(t, code, "RestoreFromBackup")
+	assert.Contains(t, code, `omg.AddRelationToType(ctx, client, "document", "legacy", "[user]")`, "down adds the relation back")
+}
+
+func TestGenerateMigration_ModelOnlyRejectsRenames(t *testing.T) {
+	changes := []omg.ModelChange{
+		{Type: omg.ChangeTypeRenameRelation, TypeName: "document", OldValue: "viewer", NewValue: "reader", Confidence: omg.ConfidenceHigh},
+	}
+
+	_, err := omg.GenerateMigrationFromChangesWithOptions(changes, "rename", t.TempDir(), omg.GenerateOptions{ModelOnly: true})
+	assert.ErrorContains(t, err, "document#viewer -> document#reader")
+}
+
+func TestModelOnlyWarnings(t *testing.T) {
+	warnings := omg.ModelOnlyWarnings([]omg.ModelChange{
+		{Type: omg.ChangeTypeAddRelation, TypeName: "document", RelationName: "editor", NewValue: "[user]"},
+		{Type: omg.ChangeTypeRemoveRelation, TypeName: "document", RelationName: "legacy"},
+		{Type: omg.ChangeTypeRemoveType, TypeName: "folder"},
+		{Type: omg.ChangeTypeRenameType, OldValue: "team", NewValue: "group"},
+		{Type: omg.ChangeTypeUpdateTypeRestrictions, TypeName: "document", RelationName: "viewer", OldValue: "[user, team#member]", NewValue: "[user]"},
+	})
+
+	assert.Equal(t, []string{
+		"relation document#legacy is removed but its tuples are left in the store",
+		"type folder is removed but its tuples are left in the store",
+		"team -> group looks like a rename; tuples are not moved to the new name",
+		"relation document#viewer no longer allows team#member; existing tuples with them are left in the store",
+	}, warnings)
+}
+
+func TestExecutionPlan_ModelOnly(t *testing.T) {
+	source := omg.ModelLock{ModelID: "01HVMMBCMGZNT3SED4Z17ECXCA", ModelHash: "sha256:abc"}
+	changes := []omg.ModelChange{
+		{Type: omg.ChangeTypeRemoveRelation, TypeName: "document", RelationName: "legacy", OldValue: "[user]"},
+	}
+
+	plan := omg.NewExecutionPlanWithOptions("store-1", source, nil, changes, omg.GenerateOptions{ModelOnly: true})
+	assert.True(t, plan.ModelOnly)
+	require.Len(t, plan.Changes, 1)
+	assert.Equal(t, []string{"RemoveRelationFromType"}, plan.Changes[0].Operations)
+
+	data, err := plan.JSON()
+	require.NoError(t, err)
+	parsed, err := omg.ParseExecutionPlan(data)
+	require.NoError(t, err)
+	assert.True(t, parsed.ModelOnly)
+}
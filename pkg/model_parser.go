@@ -3,22 +3,21 @@ package omg
 import (
 	"fmt"
 	"os"
-	"regexp"
 	"strings"
 
 	openfgaSdk "github.com/openfga/go-sdk"
 )
 
 // ParseDSLToModel parses OpenFGA DSL format to an AuthorizationModel
-// This is a simplified parser - for production, consider using the official FGA parser
+// It uses the parser chosen with SetModelParser, the simplified parser by default
 func ParseDSLToModel(dsl string) (openfgaSdk.AuthorizationModel, error) {
 	return parseDSLToModel(dsl)
 }
 
-// parseDSLToModel parses OpenFGA DSL format to an AuthorizationModel (internal)
-func parseDSLToModel(dsl string) (openfgaSdk.AuthorizationModel, error) {
+// parseSimpleDSL parses OpenFGA DSL format to an AuthorizationModel with the simplified parser
+func parseSimpleDSL(dsl string) (openfgaSdk.AuthorizationModel, error) {
 	model := openfgaSdk.AuthorizationModel{
-		SchemaVersion:   "1.1",
+		SchemaVersion:   DefaultSchemaVersion,
 		TypeDefinitions: []openfgaSdk.TypeDefinition{},
 	}
 
@@ -26,14 +25,34 @@ func parseDSLToModel(dsl string) (openfgaSdk.AuthorizationModel, error) {
 	var currentType *openfgaSdk.TypeDefinition
 	var inRelations bool
 
-	for i, line := range lines {
-		line = strings.TrimSpace(line)
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
 
 		// Skip empty lines and comments
 		if line == "" || strings.HasPrefix(line, "#") {
 			continue
 		}
 
+		// Parse condition block, which may span lines: condition name(param: type) { expression }
+		if strings.HasPrefix(line, "condition ") {
+			end, err := conditionBlockEnd(lines, i)
+			if err != nil {
+				return model, err
+			}
+			condition, err := parseCondition(strings.Join(lines[i:end+1], "\n"))
+			if err != nil {
+				return model, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			conditions := model.GetConditions()
+			if conditions == nil {
+				conditions = make(map[string]openfgaSdk.Condition)
+			}
+			conditions[condition.Name] = condition
+			model.Conditions = &conditions
+			i = end
+			continue
+		}
+
 		// Parse schema version
 		if strings.HasPrefix(line, "schema ") {
 			version := strings.TrimSpace(strings.TrimPrefix(line, "schema"))
@@ -85,7 +104,7 @@ func parseDSLToModel(dsl string) (openfgaSdk.AuthorizationModel, error) {
 			relationName := strings.TrimSpace(parts[0])
 			relationDef := strings.TrimSpace(parts[1])
 
-			userset, err := parseRelationDefinition(relationDef)
+			userset, typeRestrictions, err := parseRelationDefinition(relationDef)
 			if err != nil {
 				return model, fmt.Errorf("failed to parse relation '%s' at line %d: %w", relationName, i+1, err)
 			}
@@ -93,34 +112,7 @@ func parseDSLToModel(dsl string) (openfgaSdk.AuthorizationModel, error) {
 			relations := currentType.GetRelations()
 			relations[relationName] = userset
 			currentType.Relations = &relations
-
-			// Extract and set type restrictions in metadata
-			// Only set metadata for direct relations (with brackets), not for computed relations
-			typeRestrictions := extractTypeRestrictions(relationDef)
-			// Only set DirectlyRelatedUserTypes for direct relations (those with [...])
-			// Tuple-to-userset relations (with 'from' or '->') should NOT have this metadata
-			isDirect := strings.Contains(relationDef, "[")
-			if len(typeRestrictions) > 0 && isDirect {
-				// Ensure metadata exists
-				if currentType.Metadata == nil {
-					currentType.Metadata = &openfgaSdk.Metadata{}
-				}
-				metadata := currentType.GetMetadata()
-
-				// Ensure relations metadata map exists
-				if metadata.Relations == nil {
-					relationsMetadata := make(map[string]openfgaSdk.RelationMetadata)
-					metadata.Relations = &relationsMetadata
-				}
-
-				// Set directly related user types for this relation
-				relationsMetadata := metadata.GetRelations()
-				relationsMetadata[relationName] = openfgaSdk.RelationMetadata{
-					DirectlyRelatedUserTypes: &typeRestrictions,
-				}
-				metadata.Relations = &relationsMetadata
-				currentType.Metadata = &metadata
-			}
+			setRelationTypeRestrictions(currentType, relationName, typeRestrictions)
 		}
 	}
 
@@ -132,190 +124,53 @@ func parseDSLToModel(dsl string) (openfgaSdk.AuthorizationModel, error) {
 	return model, nil
 }
 
-// parseRelationDefinition parses a relation definition into a Userset
-// Examples:
-//   - [user] -> direct relation to user type
-//   - [user, group#member] -> direct relation to multiple types
-//   - [user] or owner -> union of direct and computed relation
-//   - owner -> computed relation
-//   - parent->owner -> tuple-to-userset (arrow syntax)
-//   - owner from team -> tuple-to-userset (from syntax)
-func parseRelationDefinition(def string) (openfgaSdk.Userset, error) {
-	userset := openfgaSdk.Userset{}
-
-	// Handle "or" operator (union)
-	if strings.Contains(def, " or ") {
-		parts := strings.Split(def, " or ")
-		var children []openfgaSdk.Userset
-		for _, part := range parts {
-			child, err := parseRelationDefinition(strings.TrimSpace(part))
-			if err != nil {
-				return userset, err
-			}
-			children = append(children, child)
-		}
-		userset.Union = &openfgaSdk.Usersets{Child: children}
-		return userset, nil
-	}
-
-	// Handle "and" operator (intersection)
-	if strings.Contains(def, " and ") {
-		parts := strings.Split(def, " and ")
-		var children []openfgaSdk.Userset
-		for _, part := range parts {
-			child, err := parseRelationDefinition(strings.TrimSpace(part))
-			if err != nil {
-				return userset, err
-			}
-			children = append(children, child)
-		}
-		userset.Intersection = &openfgaSdk.Usersets{Child: children}
-		return userset, nil
-	}
-
-	// Handle "but not" operator (difference)
-	if strings.Contains(def, " but not ") {
-		parts := strings.Split(def, " but not ")
-		if len(parts) != 2 {
-			return userset, fmt.Errorf("invalid 'but not' syntax: %s", def)
-		}
-		base, err := parseRelationDefinition(strings.TrimSpace(parts[0]))
-		if err != nil {
-			return userset, err
-		}
-		subtract, err := parseRelationDefinition(strings.TrimSpace(parts[1]))
-		if err != nil {
-			return userset, err
-		}
-		userset.Difference = &openfgaSdk.Difference{
-			Base:     base,
-			Subtract: subtract,
-		}
-		return userset, nil
-	}
-
-	// Handle direct relation: [user] or [user, group#member]
-	if strings.HasPrefix(def, "[") && strings.HasSuffix(def, "]") {
-		typesStr := strings.TrimPrefix(strings.TrimSuffix(def, "]"), "[")
-		typeList := strings.Split(typesStr, ",")
-
-		var typeRestrictions []openfgaSdk.RelationReference
-		for _, t := range typeList {
-			t = strings.TrimSpace(t)
-			if t == "" {
-				continue
-			}
-
-			// Parse type#relation format
-			if strings.Contains(t, "#") {
-				parts := strings.Split(t, "#")
-				if len(parts) != 2 {
-					return userset, fmt.Errorf("invalid type#relation format: %s", t)
-				}
-				typeRestrictions = append(typeRestrictions, openfgaSdk.RelationReference{
-					Type:     parts[0],
-					Relation: openfgaSdk.PtrString(parts[1]),
-				})
-			} else {
-				// Simple type reference
-				typeRestrictions = append(typeRestrictions, openfgaSdk.RelationReference{
-					Type: t,
-				})
-			}
+// setRelationTypeRestrictions records a relation's directly related types, with their
+// conditions, in the type's metadata
+// Only relations with a [...] branch have them, wherever the branch sits ("[user] or owner
+// from parent" keeps [user]); tuple-to-userset and computed relations must not, so their
+// entry is removed
+func setRelationTypeRestrictions(typeDef *openfgaSdk.TypeDefinition, relationName string, typeRestrictions []openfgaSdk.RelationReference) {
+	if len(typeRestrictions) == 0 {
+		if typeDef.Metadata != nil && typeDef.Metadata.Relations != nil {
+			delete(*typeDef.Metadata.Relations, relationName)
 		}
-
-		// Direct userset - for simplified parsing, just set this
-		// In full implementation, you'd properly handle type restrictions
-		thisMap := make(map[string]interface{})
-		userset.This = &thisMap
-		return userset, nil
+		return
 	}
 
-	// Handle tuple-to-userset with 'from' syntax: owner from team
-	// This is equivalent to: team->owner
-	if strings.Contains(def, " from ") {
-		parts := strings.Split(def, " from ")
-		if len(parts) != 2 {
-			return userset, fmt.Errorf("invalid 'from' syntax: %s", def)
-		}
-		computedUserset := strings.TrimSpace(parts[0])
-		tupleset := strings.TrimSpace(parts[1])
-
-		userset.TupleToUserset = &openfgaSdk.TupleToUserset{
-			Tupleset: openfgaSdk.ObjectRelation{
-				Relation: openfgaSdk.PtrString(tupleset),
-			},
-			ComputedUserset: openfgaSdk.ObjectRelation{
-				Relation: openfgaSdk.PtrString(computedUserset),
-			},
-		}
-		return userset, nil
+	// Ensure metadata and its relations map exist
+	if typeDef.Metadata == nil {
+		typeDef.Metadata = &openfgaSdk.Metadata{}
 	}
-
-	// Handle tuple-to-userset with arrow syntax: parent->owner
-	if strings.Contains(def, "->") {
-		parts := strings.Split(def, "->")
-		if len(parts) != 2 {
-			return userset, fmt.Errorf("invalid tuple-to-userset format: %s", def)
-		}
-		userset.TupleToUserset = &openfgaSdk.TupleToUserset{
-			Tupleset: openfgaSdk.ObjectRelation{
-				Relation: openfgaSdk.PtrString(strings.TrimSpace(parts[0])),
-			},
-			ComputedUserset: openfgaSdk.ObjectRelation{
-				Relation: openfgaSdk.PtrString(strings.TrimSpace(parts[1])),
-			},
-		}
-		return userset, nil
+	if typeDef.Metadata.Relations == nil {
+		relationsMetadata := make(map[string]openfgaSdk.RelationMetadata)
+		typeDef.Metadata.Relations = &relationsMetadata
 	}
 
-	// Handle computed relation: owner, parent
-	if matched, _ := regexp.MatchString(`^[a-zA-Z_][a-zA-Z0-9_]*$`, def); matched {
-		userset.ComputedUserset = &openfgaSdk.ObjectRelation{
-			Relation: openfgaSdk.PtrString(def),
-		}
-		return userset, nil
+	(*typeDef.Metadata.Relations)[relationName] = openfgaSdk.RelationMetadata{
+		DirectlyRelatedUserTypes: &typeRestrictions,
 	}
+}
 
-	return userset, fmt.Errorf("unable to parse relation definition: %s", def)
+// parseRelationDefinition parses a relation definition into a Userset
+// Examples:
+//   - [user] -> direct relation to user type
+//   - [user, group#member] -> direct relation to multiple types
+//   - [user] or owner -> union of direct and computed relation
+//   - owner -> computed relation
+//   - parent->owner -> tuple-to-userset (arrow syntax)
+//   - owner from team -> tuple-to-userset (from syntax)
+//   - (owner or editor) and approved -> intersection of a union and a computed relation
+//
+// "but not" binds tightest, then "and", then "or"; parentheses group
+// The directly related types of the [...] branch are returned for the type's metadata
+func parseRelationDefinition(def string) (openfgaSdk.Userset, []openfgaSdk.RelationReference, error) {
+	return parseExpression(def)
 }
 
-// extractTypeRestrictions extracts type restrictions from a relation definition
-// For example: "[user]" returns [{Type: "user"}], "[user, group#member]" returns [{Type: "user"}, {Type: "group", Relation: "member"}]
-// For tuple-to-userset like "admin from team", returns [{Type: "team"}]
-func extractTypeRestrictions(def string) []openfgaSdk.RelationReference {
+// extractDirectTypeRestrictions extracts the types between the brackets of a definition
+func extractDirectTypeRestrictions(def string) []openfgaSdk.RelationReference {
 	var typeRestrictions []openfgaSdk.RelationReference
 
-	// Handle tuple-to-userset with 'from' syntax: admin from team
-	// The tupleset is the type that should be in DirectlyRelatedUserTypes
-	if strings.Contains(def, " from ") {
-		parts := strings.Split(def, " from ")
-		if len(parts) == 2 {
-			tupleset := strings.TrimSpace(parts[1])
-			typeRestrictions = append(typeRestrictions, openfgaSdk.RelationReference{
-				Type: tupleset,
-			})
-			return typeRestrictions
-		}
-	}
-
-	// Handle arrow syntax: parent->owner (tupleset is parent)
-	if strings.Contains(def, "->") {
-		parts := strings.Split(def, "->")
-		if len(parts) == 2 {
-			tupleset := strings.TrimSpace(parts[0])
-			typeRestrictions = append(typeRestrictions, openfgaSdk.RelationReference{
-				Type: tupleset,
-			})
-			return typeRestrictions
-		}
-	}
-
-	// Check if definition contains direct type restrictions [...]
-	if !strings.Contains(def, "[") || !strings.Contains(def, "]") {
-		return typeRestrictions
-	}
-
 	// Extract content between []
 	start := strings.Index(def, "[")
 	end := strings.Index(def, "]")
@@ -332,21 +187,37 @@ func extractTypeRestrictions(def string) []openfgaSdk.RelationReference {
 			continue
 		}
 
-		// Parse type#relation format
-		if strings.Contains(t, "#") {
+		// Conditional restriction: user with non_expired
+		t, condition, hasCondition := strings.Cut(t, " with ")
+		t = strings.TrimSpace(t)
+
+		var restriction openfgaSdk.RelationReference
+		if typeName, isWildcard := strings.CutSuffix(t, ":*"); isWildcard {
+			// Public access: user:* relates every user
+			restriction = openfgaSdk.RelationReference{
+				Type:     typeName,
+				Wildcard: &map[string]interface{}{},
+			}
+		} else if strings.Contains(t, "#") {
+			// Parse type#relation format
 			parts := strings.Split(t, "#")
-			if len(parts) == 2 {
-				typeRestrictions = append(typeRestrictions, openfgaSdk.RelationReference{
-					Type:     parts[0],
-					Relation: openfgaSdk.PtrString(parts[1]),
-				})
+			if len(parts) != 2 {
+				continue
+			}
+			restriction = openfgaSdk.RelationReference{
+				Type:     parts[0],
+				Relation: openfgaSdk.PtrString(parts[1]),
 			}
 		} else {
 			// Simple type reference
-			typeRestrictions = append(typeRestrictions, openfgaSdk.RelationReference{
+			restriction = openfgaSdk.RelationReference{
 				Type: t,
-			})
+			}
+		}
+		if hasCondition {
+			restriction.Condition = openfgaSdk.PtrString(strings.TrimSpace(condition))
 		}
+		typeRestrictions = append(typeRestrictions, restriction)
 	}
 
 	return typeRestrictions
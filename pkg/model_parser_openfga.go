@@ -0,0 +1,38 @@
+//go:build openfga_language
+
+package omg
+
+import (
+	"encoding/json"
+	"fmt"
+
+	openfgaSdk "github.com/openfga/go-sdk"
+	"github.com/openfga/language/pkg/go/transformer"
+)
+
+// LanguageModelParser parses models with the official transformer from
+// github.com/openfga/language, which covers the full DSL
+type LanguageModelParser struct{}
+
+func init() {
+	RegisterModelParser(LanguageModelParser{})
+}
+
+// Name implements ModelParser
+func (LanguageModelParser) Name() string {
+	return "openfga"
+}
+
+// Parse implements ModelParser
+func (LanguageModelParser) Parse(dsl string) (openfgaSdk.AuthorizationModel, error) {
+	var model openfgaSdk.AuthorizationModel
+
+	modelJSON, err := transformer.TransformDSLToJSON(dsl)
+	if err != nil {
+		return model, fmt.Errorf("failed to parse model: %w", err)
+	}
+	if err := json.Unmarshal([]byte(modelJSON), &model); err != nil {
+		return model, fmt.Errorf("failed to decode model: %w", err)
+	}
+	return model, nil
+}
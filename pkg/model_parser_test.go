@@ -553,17 +553,150 @@ type document
 	assert.NotNil(t, relations["can_edit"].Intersection)
 }
 
-// Note: The simplified parser doesn't support parentheses for grouping
-func TestParseDSLToModel_ParenthesesNotSupported(t *testing.T) {
+func TestParseDSLToModel_Parentheses(t *testing.T) {
 	dsl := `
 type user
 type document
   relations
     define owner: [user]
     define editor: [user]
-    define can_view: (owner or editor)
+    define approved: [user]
+    define can_view: (owner or editor) and approved
+    define can_edit: owner or editor and approved
 `
-	_, err := omg.ParseDSLToModel(dsl)
-	// This is expected to fail with the simplified parser
-	assert.Error(t, err)
+	model, err := omg.ParseDSLToModel(dsl)
+	require.NoError(t, err)
+
+	relations := model.TypeDefinitions[1].GetRelations()
+	canView := relations["can_view"]
+	require.NotNil(t, canView.Intersection)
+	children := canView.Intersection.GetChild()
+	require.Len(t, children, 2)
+	require.NotNil(t, children[0].Union)
+	assert.Len(t, children[0].Union.GetChild(), 2)
+	assert.Equal(t, "approved", children[1].ComputedUserset.GetRelation())
+
+	// "and" binds tighter than "or"
+	canEdit := relations["can_edit"]
+	require.NotNil(t, canEdit.Union)
+	require.Len(t, canEdit.Union.GetChild(), 2)
+	assert.NotNil(t, canEdit.Union.GetChild()[1].Intersection)
+
+	state := omg.BuildModelState(model)
+	assert.Equal(t, "approved and (editor or owner)", state.Types["document"].Relations["can_view"])
+	assert.Equal(t, "approved and editor or owner", state.Types["document"].Relations["can_edit"])
+}
+
+func TestParseDSLToModel_ParenthesesRoundTrip(t *testing.T) {
+	// Canonical forms, which BuildModelState writes back unchanged
+	definitions := []string{
+		"approved and (editor or owner)",
+		"[user] but not (blocked or suspended)",
+		"(owner or viewer from parent) but not blocked",
+		"(approved and viewer or editor) and owner",
+	}
+	for _, definition := range definitions {
+		t.Run(definition, func(t *testing.T) {
+			dsl := "type user\ntype document\n  relations\n    define parent: [document]\n" +
+				"    define owner: [user]\n    define editor: [user]\n    define viewer: [user]\n" +
+				"    define approved: [user]\n    define blocked: [user]\n    define suspended: [user]\n" +
+				"    define subject: " + definition + "\n"
+			model, err := omg.ParseDSLToModel(dsl)
+			require.NoError(t, err)
+			assert.Equal(t, definition, omg.BuildModelState(model).Types["document"].Relations["subject"])
+		})
+	}
+}
+
+func TestParseDSLToModel_UnbalancedParentheses(t *testing.T) {
+	for _, definition := range []string{"(owner or editor", "owner or editor)", "()", "owner and"} {
+		_, err := omg.ParseDSLToModel("type user\ntype document\n  relations\n    define owner: [user]\n    define editor: [user]\n    define subject: " + definition + "\n")
+		assert.Error(t, err, definition)
+	}
+}
+
+func TestParseDSLToModel_Wildcard(t *testing.T) {
+	dsl := `
+type user
+type document
+  relations
+    define viewer: [user, user:*, team#member]
+`
+	model, err := omg.ParseDSLToModel(dsl)
+	require.NoError(t, err)
+
+	viewer := model.TypeDefinitions[1].Metadata.GetRelations()["viewer"]
+	restrictions := viewer.GetDirectlyRelatedUserTypes()
+	require.Len(t, restrictions, 3)
+	assert.Nil(t, restrictions[0].Wildcard)
+	assert.Equal(t, "user", restrictions[1].Type)
+	assert.NotNil(t, restrictions[1].Wildcard)
+	assert.Nil(t, restrictions[1].Relation)
+
+	state := omg.BuildModelState(model)
+	assert.Equal(t, "[team#member, user, user:*]", state.Types["document"].Relations["viewer"])
+}
+
+func TestParseDSLToModel_InvalidWildcard(t *testing.T) {
+	_, err := omg.ParseDSLToModel("type user\ntype document\n  relations\n    define viewer: [user:alice]\n")
+	assert.ErrorContains(t, err, "invalid wildcard format")
+}
+
+func TestDetectChanges_Wildcard(t *testing.T) {
+	private, err := omg.ParseDSLToModel("type user\ntype document\n  relations\n    define viewer: [user]\n")
+	require.NoError(t, err)
+	public, err := omg.ParseDSLToModel("type user\ntype document\n  relations\n    define viewer: [user, user:*]\n")
+	require.NoError(t, err)
+
+	changes := omg.DetectChanges(omg.BuildModelState(private), omg.BuildModelState(public))
+	require.Len(t, changes, 1)
+	assert.Equal(t, omg.ChangeTypeUpdateTypeRestrictions, changes[0].Type)
+	assert.Equal(t, "[user]", changes[0].OldValue)
+	assert.Equal(t, "[user, user:*]", changes[0].NewValue)
+}
+
+func TestParseDSLToModel_MixedDirectAndTupleToUserset(t *testing.T) {
+	dsl := `
+type user
+type folder
+  relations
+    define owner: [user]
+type document
+  relations
+    define parent: [folder]
+    define owner: [user] or owner from parent
+    define viewer: owner from parent or ([user, user:*] but not owner)
+    define inherited: owner from parent
+`
+	model, err := omg.ParseDSLToModel(dsl)
+	require.NoError(t, err)
+
+	document := model.TypeDefinitions[2]
+	metadata := document.Metadata.GetRelations()
+
+	owner := document.GetRelations()["owner"]
+	require.NotNil(t, owner.Union)
+	children := owner.Union.GetChild()
+	require.Len(t, children, 2)
+	assert.NotNil(t, children[0].This)
+	assert.NotNil(t, children[1].TupleToUserset)
+	ownerMetadata := metadata["owner"]
+	assert.Equal(t, []openfgaSdk.RelationReference{{Type: "user"}}, ownerMetadata.GetDirectlyRelatedUserTypes())
+
+	viewerMetadata := metadata["viewer"]
+	restrictions := viewerMetadata.GetDirectlyRelatedUserTypes()
+	require.Len(t, restrictions, 2)
+	assert.NotNil(t, restrictions[1].Wildcard)
+
+	_, hasMetadata := metadata["inherited"]
+	assert.False(t, hasMetadata, "tuple-to-userset relations have no directly related types")
+
+	state := omg.BuildModelState(model)
+	assert.Equal(t, "[user] or owner from parent", state.Types["document"].Relations["owner"])
+	assert.Equal(t, "[user, user:*] but not owner or owner from parent", state.Types["document"].Relations["viewer"])
+}
+
+func TestParseDSLToModel_MultipleDirectTypeLists(t *testing.T) {
+	_, err := omg.ParseDSLToModel("type user\ntype document\n  relations\n    define viewer: [user] or [user:*]\n")
+	assert.ErrorContains(t, err, "more than one list of directly related types")
 }
@@ -0,0 +1,96 @@
+package omg
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	openfgaSdk "github.com/openfga/go-sdk"
+)
+
+// ModelParser turns a model in OpenFGA DSL into an authorization model
+// Everything that reads model files or rewrites the live model goes through the parser
+// chosen with SetModelParser
+type ModelParser interface {
+	// Name identifies the parser for SetModelParser, e.g. "simple"
+	Name() string
+
+	// Parse parses a model in OpenFGA DSL
+	Parse(dsl string) (openfgaSdk.AuthorizationModel, error)
+}
+
+// SimpleModelParser is the built-in parser: no dependencies, one model file, types,
+// relations with parentheses, wildcards and conditions
+type SimpleModelParser struct{}
+
+// Name implements ModelParser
+func (SimpleModelParser) Name() string {
+	return "simple"
+}
+
+// Parse implements ModelParser
+func (SimpleModelParser) Parse(dsl string) (openfgaSdk.AuthorizationModel, error) {
+	return parseSimpleDSL(dsl)
+}
+
+var (
+	modelParsersMu sync.RWMutex
+	modelParsers               = map[string]ModelParser{"simple": SimpleModelParser{}}
+	modelParser    ModelParser = SimpleModelParser{}
+)
+
+// RegisterModelParser makes a parser available to SetModelParser under its name
+// The parser backed by github.com/openfga/language registers itself as "openfga" when omg
+// is built with -tags openfga_language
+func RegisterModelParser(parser ModelParser) {
+	modelParsersMu.Lock()
+	defer modelParsersMu.Unlock()
+	modelParsers[parser.Name()] = parser
+}
+
+// SetModelParser selects the parser used from now on by name ("simple" or "openfga")
+func SetModelParser(name string) error {
+	modelParsersMu.Lock()
+	defer modelParsersMu.Unlock()
+
+	parser, exists := modelParsers[name]
+	if !exists {
+		if name == "openfga" {
+			return fmt.Errorf("model parser 'openfga' is not built in (build omg with -tags openfga_language)")
+		}
+		return fmt.Errorf("unknown model parser '%s' (available: %s)", name, strings.Join(modelParserNames(), ", "))
+	}
+	modelParser = parser
+	return nil
+}
+
+// CurrentModelParser returns the parser in use
+func CurrentModelParser() ModelParser {
+	modelParsersMu.RLock()
+	defer modelParsersMu.RUnlock()
+	return modelParser
+}
+
+// modelParserNames returns the registered parser names, sorted
+func modelParserNames() []string {
+	names := make([]string, 0, len(modelParsers))
+	for name := range modelParsers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseDSLToModel parses OpenFGA DSL format to an AuthorizationModel (internal)
+// The model must only use features of its declared schema version
+func parseDSLToModel(dsl string) (openfgaSdk.AuthorizationModel, error) {
+	model, err := CurrentModelParser().Parse(dsl)
+	if err != nil {
+		return model, err
+	}
+	if err := ValidateModelSchema(model); err != nil {
+		return model, err
+	}
+	return model, nil
+}
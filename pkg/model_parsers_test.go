@@ -0,0 +1,54 @@
+package omg_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/demetere/omg/pkg"
+	openfgaSdk "github.com/openfga/go-sdk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// upperCaseParser is a parser that upper-cases type names, to tell it apart
+type upperCaseParser struct{}
+
+func (upperCaseParser) Name() string { return "upper" }
+
+func (upperCaseParser) Parse(dsl string) (openfgaSdk.AuthorizationModel, error) {
+	model, err := omg.SimpleModelParser{}.Parse(dsl)
+	for i := range model.TypeDefinitions {
+		model.TypeDefinitions[i].Type = strings.ToUpper(model.TypeDefinitions[i].Type)
+	}
+	return model, err
+}
+
+func TestSetModelParser(t *testing.T) {
+	assert.Equal(t, "simple", omg.CurrentModelParser().Name())
+
+	omg.RegisterModelParser(upperCaseParser{})
+	require.NoError(t, omg.SetModelParser("upper"))
+	defer omg.SetModelParser("simple")
+
+	model, err := omg.ParseDSLToModel("type user\n")
+	require.NoError(t, err)
+	assert.Equal(t, "USER", model.TypeDefinitions[0].Type)
+
+	require.NoError(t, omg.SetModelParser("simple"))
+	model, err = omg.ParseDSLToModel("type user\n")
+	require.NoError(t, err)
+	assert.Equal(t, "user", model.TypeDefinitions[0].Type)
+}
+
+func TestSetModelParser_Unknown(t *testing.T) {
+	err := omg.SetModelParser("antlr")
+	assert.ErrorContains(t, err, "unknown model parser 'antlr'")
+	assert.Equal(t, "simple", omg.CurrentModelParser().Name())
+
+	// The openfga/language parser is only registered in builds with its tag
+	if omg.SetModelParser("openfga") == nil {
+		omg.SetModelParser("simple")
+		t.Skip("built with -tags openfga_language")
+	}
+	assert.ErrorContains(t, omg.SetModelParser("openfga"), "-tags openfga_language")
+}
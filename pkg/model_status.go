@@ -0,0 +1,61 @@
+package omg
+
+import (
+	"context"
+	"fmt"
+
+	openfgaSdk "github.com/openfga/go-sdk"
+)
+
+// ModelStatus summarizes a store's latest authorization model relative to a model file
+type ModelStatus struct {
+	ModelID       string `json:"model_id"`
+	SchemaVersion string `json:"schema_version"`
+	LiveHash      string `json:"live_hash"`
+	FileHash      string `json:"file_hash,omitempty"`     // Empty when no model file was given
+	SnapshotHash  string `json:"snapshot_hash,omitempty"` // Empty when there is no schema snapshot
+}
+
+// HasModel reports whether the store has an authorization model at all
+func (s ModelStatus) HasModel() bool {
+	return s.ModelID != ""
+}
+
+// InSync reports whether the live model matches the model file
+func (s ModelStatus) InSync() bool {
+	return s.FileHash != "" && s.LiveHash == s.FileHash
+}
+
+// SnapshotInSync reports whether the live model matches the schema snapshot of the migrations
+func (s ModelStatus) SnapshotInSync() bool {
+	return s.SnapshotHash != "" && s.LiveHash == s.SnapshotHash
+}
+
+// NewModelStatus builds a status from a live authorization model and the model file,
+// in DSL or JSON; modelDSL may be empty to skip the comparison
+func NewModelStatus(model openfgaSdk.AuthorizationModel, modelDSL string) (ModelStatus, error) {
+	status := ModelStatus{
+		ModelID:       model.GetId(),
+		SchemaVersion: model.GetSchemaVersion(),
+		LiveHash:      HashModelState(BuildModelStateFromAuthorizationModel(model)),
+	}
+
+	if modelDSL != "" {
+		fileModel, err := ParseModel(modelDSL)
+		if err != nil {
+			return ModelStatus{}, fmt.Errorf("failed to parse model: %w", err)
+		}
+		status.FileHash = HashModelState(BuildModelState(fileModel))
+	}
+
+	return status, nil
+}
+
+// GetModelStatus reads the store's latest authorization model and compares it with modelDSL
+func GetModelStatus(ctx context.Context, client *Client, modelDSL string) (ModelStatus, error) {
+	model, err := client.GetCurrentAuthorizationModel(ctx)
+	if err != nil {
+		return ModelStatus{}, err
+	}
+	return NewModelStatus(model, modelDSL)
+}
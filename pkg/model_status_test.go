@@ -0,0 +1,47 @@
+package omg_test
+
+import (
+	"testing"
+
+	"github.com/demetere/omg/pkg"
+	openfgaSdk "github.com/openfga/go-sdk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewModelStatus(t *testing.T) {
+	dsl := `model
+  schema 1.1
+
+type user
+
+type document
+  relations
+    define viewer: [user]
+`
+	model, err := omg.ParseDSLToModel(dsl)
+	require.NoError(t, err)
+	model.Id = "01HMODEL"
+
+	status, err := omg.NewModelStatus(model, dsl)
+	require.NoError(t, err)
+	assert.True(t, status.HasModel())
+	assert.Equal(t, "01HMODEL", status.ModelID)
+	assert.Equal(t, "1.1", status.SchemaVersion)
+	assert.True(t, status.InSync())
+
+	status, err = omg.NewModelStatus(model, dsl+"    define editor: [user]\n")
+	require.NoError(t, err)
+	assert.False(t, status.InSync())
+
+	status, err = omg.NewModelStatus(model, "")
+	require.NoError(t, err)
+	assert.Empty(t, status.FileHash)
+	assert.False(t, status.InSync())
+}
+
+func TestNewModelStatus_NoModel(t *testing.T) {
+	status, err := omg.NewModelStatus(openfgaSdk.AuthorizationModel{}, "")
+	require.NoError(t, err)
+	assert.False(t, status.HasModel())
+}
@@ -2,9 +2,11 @@ package omg
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"path"
+	"regexp"
 	"strings"
 
 	openfgaSdk "github.com/openfga/go-sdk"
@@ -17,24 +19,26 @@ const (
 // ModelState represents the state of an authorization model
 // This is built from either OpenFGA (current state) or model.fga (desired state)
 type ModelState struct {
-	Types map[string]TypeState
+	Types      map[string]TypeState
+	Conditions map[string]string // condition name -> one-line definition, parameters sorted
 }
 
 // TypeState represents the state of a single type
 type TypeState struct {
 	Name      string            `json:"name"`
-	Relations map[string]string `json:"relations"` // relation name -> definition
+	Relations map[string]string `json:"relations"` // relation name -> canonical definition in DSL
 }
 
 // ModelChange represents a detected change in the model
 type ModelChange struct {
-	Type         ChangeType
-	TypeName     string
-	RelationName string
-	OldValue     string
-	NewValue     string
-	Details      string
-	Confidence   ConfidenceLevel // For renames: high = auto-apply, medium = needs review, low = suggest only
+	Type         ChangeType      `json:"type" yaml:"type"`
+	TypeName     string          `json:"type_name" yaml:"type_name"`
+	RelationName string          `json:"relation,omitempty" yaml:"relation,omitempty"`
+	OldValue     string          `json:"old_value,omitempty" yaml:"old_value,omitempty"`
+	NewValue     string          `json:"new_value,omitempty" yaml:"new_value,omitempty"`
+	Details      string          `json:"details" yaml:"details"`
+	Confidence   ConfidenceLevel `json:"confidence,omitempty" yaml:"confidence,omitempty"` // For renames: high = auto-apply, medium = needs review, low = suggest only
+	TargetType   string          `json:"target_type,omitempty" yaml:"target_type,omitempty"` // For moves: the type the relation moves to
 }
 
 // ChangeType represents the kind of change detected
@@ -47,7 +51,14 @@ const (
 	ChangeTypeAddRelation     ChangeType = "add_relation"
 	ChangeTypeRemoveRelation  ChangeType = "remove_relation"
 	ChangeTypeRenameRelation  ChangeType = "rename_relation"  // Requires user confirmation
+	ChangeTypeMoveRelation    ChangeType = "move_relation"    // Same relation on another type; TargetType is the new type
 	ChangeTypeUpdateRelation  ChangeType = "update_relation"
+	ChangeTypeUpdateTypeRestrictions ChangeType = "update_type_restrictions" // Only the directly related types changed
+
+	// Condition changes carry the condition's name in TypeName
+	ChangeTypeAddCondition    ChangeType = "add_condition"
+	ChangeTypeRemoveCondition ChangeType = "remove_condition"
+	ChangeTypeUpdateCondition ChangeType = "update_condition"
 )
 
 // ConfidenceLevel represents how confident we are about a rename detection
@@ -70,40 +81,33 @@ func LoadModelStateFromOpenFGA(ctx context.Context, client *Client) (*ModelState
 	return BuildModelStateFromAuthorizationModel(model), nil
 }
 
-// BuildModelStateFromAuthorizationModel converts an OpenFGA authorization model to ModelState
-func BuildModelStateFromAuthorizationModel(model openfgaSdk.AuthorizationModel) *ModelState {
-	state := &ModelState{
-		Types: make(map[string]TypeState),
-	}
-
-	for _, typeDef := range model.GetTypeDefinitions() {
-		typeName := typeDef.GetType()
-		typeState := TypeState{
-			Name:      typeName,
-			Relations: make(map[string]string),
-		}
-
-		// Get metadata for type restrictions
-		var relationsMetadata map[string]openfgaSdk.RelationMetadata
-		if metadata := typeDef.Metadata; metadata != nil {
-			relationsMetadata = metadata.GetRelations()
-		}
-
-		relations := typeDef.GetRelations()
-		for relName, relDef := range relations {
-			// Get type restrictions from metadata
-			var typeRestrictions []openfgaSdk.RelationReference
-			if relMeta, exists := relationsMetadata[relName]; exists {
-				typeRestrictions = relMeta.GetDirectlyRelatedUserTypes()
-			}
-			// Convert to DSL format with metadata
-			typeState.Relations[relName] = formatUsersetWithMetadata(relDef, typeRestrictions)
+// LoadModelStateFromFile builds the model state of a model file without contacting OpenFGA
+// The file holds DSL or JSON, is an fga.mod modular model, or is a store snapshot archive
+// written by 'omg snapshot', whose model is used
+func LoadModelStateFromFile(path string) (*ModelState, error) {
+	if isSnapshotArchive(path) {
+		snapshot, err := ReadSnapshot(path)
+		if err != nil {
+			return nil, err
 		}
+		return BuildModelStateFromAuthorizationModel(snapshot.Model), nil
+	}
 
-		state.Types[typeName] = typeState
+	content, err := LoadCurrentModelFromPath(path)
+	if err != nil {
+		return nil, err
 	}
+	model, err := ParseModel(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return BuildModelState(model), nil
+}
 
-	return state
+// BuildModelStateFromAuthorizationModel converts an OpenFGA authorization model to ModelState
+// Live and parsed models are built alike, see BuildModelState
+func BuildModelStateFromAuthorizationModel(model openfgaSdk.AuthorizationModel) *ModelState {
+	return BuildModelState(model)
 }
 
 // LoadCurrentModel loads the current model from model.fga file
@@ -112,7 +116,21 @@ func LoadCurrentModel() (string, error) {
 }
 
 // LoadCurrentModelFromPath loads the current model from a specified file path
+// A path of "-" reads the model from stdin; an fga.mod manifest, or a directory holding
+// one, loads a modular model with its modules merged (see LoadModularModel)
 func LoadCurrentModelFromPath(path string) (string, error) {
+	if path == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return "", fmt.Errorf("failed to read model from stdin: %w", err)
+		}
+		return string(data), nil
+	}
+
+	if isModelManifest(path) {
+		return LoadModularModel(path)
+	}
+
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return "", fmt.Errorf("failed to read %s: %w", path, err)
@@ -146,25 +164,32 @@ func BuildModelState(model openfgaSdk.AuthorizationModel) *ModelState {
 			if relMeta, exists := relationsMetadata[relName]; exists {
 				typeRestrictions = relMeta.GetDirectlyRelatedUserTypes()
 			}
-			// Convert to DSL format with metadata
-			typeState.Relations[relName] = formatUsersetWithMetadata(relDef, typeRestrictions)
+			// Convert to canonical DSL, so definitions compare by meaning rather than operand order
+			typeState.Relations[relName] = canonicalRelation(relDef, typeRestrictions)
 		}
 
 		state.Types[typeDef.Type] = typeState
 	}
+	state.Conditions = conditionStates(model)
 
 	return state
 }
 
-// serializeUserset converts a Userset to a string representation
-func serializeUserset(userset openfgaSdk.Userset) string {
-	// Simplified serialization - good enough for comparison
-	data, _ := json.Marshal(userset)
-	return string(data)
+// conditionStates formats a model's conditions for comparison, nil when it has none
+func conditionStates(model openfgaSdk.AuthorizationModel) map[string]string {
+	conditions := model.GetConditions()
+	if len(conditions) == 0 {
+		return nil
+	}
+	states := make(map[string]string, len(conditions))
+	for name, condition := range conditions {
+		states[name] = conditionDSL(condition)
+	}
+	return states
 }
 
 // DetectChanges compares old and new model states and returns detected changes
-func DetectChanges(oldState, newState *ModelState) []ModelChange {
+func DetectChanges(oldState, newState *ModelState) ChangeSet {
 	var changes []ModelChange
 
 	// Detect type changes
@@ -248,25 +273,217 @@ func DetectChanges(oldState, newState *ModelState) []ModelChange {
 		// Modified relations
 		for relName, newRelDef := range newRels {
 			oldRelDef, exists := oldRels[relName]
-			if exists && oldRelDef != newRelDef {
-				changes = append(changes, ModelChange{
-					Type:         ChangeTypeUpdateRelation,
-					TypeName:     typeName,
-					RelationName: relName,
-					OldValue:     oldRelDef,
-					NewValue:     newRelDef,
-					Details:      fmt.Sprintf("Updated relation '%s.%s' definition", typeName, relName),
-				})
+			if !exists || oldRelDef == newRelDef {
+				continue
+			}
+			change := ModelChange{
+				Type:         ChangeTypeUpdateRelation,
+				TypeName:     typeName,
+				RelationName: relName,
+				OldValue:     oldRelDef,
+				NewValue:     newRelDef,
+				Details:      fmt.Sprintf("Updated relation '%s.%s': %s → %s", typeName, relName, oldRelDef, newRelDef),
+			}
+			if typeRestrictionsOnly(oldRelDef, newRelDef) {
+				change.Type = ChangeTypeUpdateTypeRestrictions
+				change.Details = fmt.Sprintf("Updated type restrictions of '%s.%s': %s → %s", typeName, relName,
+					typeRestrictionList.FindString(oldRelDef), typeRestrictionList.FindString(newRelDef))
 			}
+			changes = append(changes, change)
+		}
+	}
+
+	// Conditions, compared by parameters and expression
+	for name, newDef := range newState.Conditions {
+		oldDef, exists := oldState.Conditions[name]
+		switch {
+		case !exists:
+			changes = append(changes, ModelChange{
+				Type:     ChangeTypeAddCondition,
+				TypeName: name,
+				NewValue: newDef,
+				Details:  fmt.Sprintf("Added condition '%s'", name),
+			})
+		case oldDef != newDef:
+			changes = append(changes, ModelChange{
+				Type:     ChangeTypeUpdateCondition,
+				TypeName: name,
+				OldValue: oldDef,
+				NewValue: newDef,
+				Details:  fmt.Sprintf("Updated condition '%s': %s → %s", name, oldDef, newDef),
+			})
+		}
+	}
+	for name, oldDef := range oldState.Conditions {
+		if _, exists := newState.Conditions[name]; !exists {
+			changes = append(changes, ModelChange{
+				Type:     ChangeTypeRemoveCondition,
+				TypeName: name,
+				OldValue: oldDef,
+				Details:  fmt.Sprintf("Removed condition '%s'", name),
+			})
 		}
 	}
 
 	return changes
 }
 
+// typeRestrictionList matches the directly related types of a relation definition
+var typeRestrictionList = regexp.MustCompile(`\[[^\]]*\]`)
+
+// typeRestrictionsOnly reports whether two definitions of a relation differ only in their
+// directly related types, e.g. "[user] or owner" and "[user, group#member] or owner"
+func typeRestrictionsOnly(oldDef, newDef string) bool {
+	return typeRestrictionList.MatchString(oldDef) && typeRestrictionList.MatchString(newDef) &&
+		typeRestrictionList.ReplaceAllString(oldDef, "[]") == typeRestrictionList.ReplaceAllString(newDef, "[]")
+}
+
+// RemovedTypeRestrictions returns the directly related types oldDef allows and newDef does
+// not, e.g. group#member for "[user, group#member]" and "[user]"
+// Existing tuples with those users are no longer valid for the relation
+func RemovedTypeRestrictions(oldDef, newDef string) []string {
+	kept := make(map[string]bool)
+	for _, restriction := range splitTypeRestrictions(newDef) {
+		kept[restriction] = true
+	}
+
+	var removed []string
+	for _, restriction := range splitTypeRestrictions(oldDef) {
+		if !kept[restriction] {
+			removed = append(removed, restriction)
+		}
+	}
+	return removed
+}
+
+// splitTypeRestrictions returns the entries of a definition's directly related types
+func splitTypeRestrictions(def string) []string {
+	list := strings.Trim(typeRestrictionList.FindString(RelationDefinitionDSL(def)), "[]")
+	var restrictions []string
+	for _, restriction := range strings.Split(list, ",") {
+		if restriction = strings.TrimSpace(restriction); restriction != "" {
+			restrictions = append(restrictions, restriction)
+		}
+	}
+	return restrictions
+}
+
+// DetectOptions controls which parts of a model are compared
+// Ignored parts are left out of diffs and generated migrations, which is useful
+// for types that are intentionally managed outside of migrations
+type DetectOptions struct {
+	// IgnoreTypes lists type names to skip; shell patterns such as "experimental_*" are allowed
+	IgnoreTypes []string
+
+	// IgnoreRelations lists "type#relation" pairs to skip; either side may be a pattern
+	IgnoreRelations []string
+
+	// IgnoreChangeTypes lists kinds of change to drop, e.g. ChangeTypeRemoveRelation
+	IgnoreChangeTypes []ChangeType
+
+	// Similarity scores rename candidates; nil uses DefaultSimilarity
+	Similarity SimilarityScorer
+
+	// TupleCounts, if set, weighs rename candidates by the data they would migrate (see
+	// CountRemovedTuples): removed types and relations without tuples are never renames
+	TupleCounts TupleCounts
+}
+
+// Ignores reports whether a change is excluded by the options
+// Renames are ignored when either the old or the new name is ignored
+func (o DetectOptions) Ignores(change ModelChange) bool {
+	for _, changeType := range o.IgnoreChangeTypes {
+		if change.Type == changeType {
+			return true
+		}
+	}
+
+	switch change.Type {
+	case ChangeTypeRenameType:
+		return o.ignoresType(change.OldValue) || o.ignoresType(change.NewValue)
+	case ChangeTypeRenameRelation:
+		return o.ignoresType(change.TypeName) ||
+			o.ignoresRelation(change.TypeName, change.OldValue) ||
+			o.ignoresRelation(change.TypeName, change.NewValue)
+	case ChangeTypeMoveRelation:
+		return o.ignoresType(change.TypeName) || o.ignoresType(change.TargetType) ||
+			o.ignoresRelation(change.TypeName, change.RelationName) ||
+			o.ignoresRelation(change.TargetType, change.RelationName)
+	case ChangeTypeAddRelation, ChangeTypeRemoveRelation, ChangeTypeUpdateRelation, ChangeTypeUpdateTypeRestrictions:
+		return o.ignoresType(change.TypeName) || o.ignoresRelation(change.TypeName, change.RelationName)
+	case ChangeTypeAddCondition, ChangeTypeRemoveCondition, ChangeTypeUpdateCondition:
+		return false // Conditions are not types; only IgnoreChangeTypes drops them
+	default:
+		return o.ignoresType(change.TypeName)
+	}
+}
+
+func (o DetectOptions) ignoresType(typeName string) bool {
+	for _, pattern := range o.IgnoreTypes {
+		if matchPattern(pattern, typeName) {
+			return true
+		}
+	}
+	return false
+}
+
+func (o DetectOptions) ignoresRelation(typeName, relationName string) bool {
+	for _, rule := range o.IgnoreRelations {
+		typePattern, relationPattern, found := strings.Cut(rule, "#")
+		if !found {
+			continue
+		}
+		if matchPattern(typePattern, typeName) && matchPattern(relationPattern, relationName) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPattern matches a name against a shell pattern, treating invalid patterns as literals
+func matchPattern(pattern, name string) bool {
+	matched, err := path.Match(pattern, name)
+	if err != nil {
+		return pattern == name
+	}
+	return matched
+}
+
+// FilterChanges removes the changes excluded by the options
+func FilterChanges(changes []ModelChange, opts DetectOptions) ChangeSet {
+	var filtered ChangeSet
+	for _, change := range changes {
+		if !opts.Ignores(change) {
+			filtered = append(filtered, change)
+		}
+	}
+	return filtered
+}
+
+// DetectChangesWithOptions is DetectChanges with ignore rules applied
+func DetectChangesWithOptions(oldState, newState *ModelState, opts DetectOptions) ChangeSet {
+	return FilterChanges(DetectChanges(oldState, newState), opts)
+}
+
+// DetectPotentialRenamesWithOptions is DetectPotentialRenames with ignore rules applied,
+// so ignored types and relations are never matched as rename sources or targets
+func DetectPotentialRenamesWithOptions(changes []ModelChange, oldState, newState *ModelState, opts DetectOptions) ChangeSet {
+	scorer := opts.Similarity
+	if scorer == nil {
+		scorer = DefaultSimilarity{}
+	}
+	return FilterChanges(detectPotentialRenames(FilterChanges(changes, opts), oldState, newState, scorer, opts.TupleCounts), opts)
+}
+
 // DetectPotentialRenames attempts to detect renames by looking for similar type/relation names
 // It uses name similarity and relation similarity to determine confidence levels
-func DetectPotentialRenames(changes []ModelChange, oldState, newState *ModelState) []ModelChange {
+func DetectPotentialRenames(changes []ModelChange, oldState, newState *ModelState) ChangeSet {
+	return detectPotentialRenames(changes, oldState, newState, DefaultSimilarity{}, nil)
+}
+
+// detectPotentialRenames is DetectPotentialRenames with the given scorer and, if not nil,
+// tuple counts of the removed types and relations
+func detectPotentialRenames(changes []ModelChange, oldState, newState *ModelState, scorer SimilarityScorer, counts TupleCounts) ChangeSet {
 	var enhanced []ModelChange
 
 	// Group changes by type
@@ -306,7 +523,7 @@ func DetectPotentialRenames(changes []ModelChange, oldState, newState *ModelStat
 			}
 
 			// Calculate name similarity
-			nameSim := calculateSimilarity(removed.TypeName, added.TypeName)
+			nameSim := scorer.NameSimilarity(removed.TypeName, added.TypeName)
 
 			// Calculate relation similarity (if we have access to type states)
 			relSim := 0.0
@@ -314,12 +531,12 @@ func DetectPotentialRenames(changes []ModelChange, oldState, newState *ModelStat
 				oldTypeState, oldExists := oldState.Types[removed.TypeName]
 				newTypeState, newExists := newState.Types[added.TypeName]
 				if oldExists && newExists {
-					relSim = haveSimilarRelations(oldTypeState, newTypeState)
+					relSim = scorer.TypeSimilarity(oldTypeState, newTypeState)
 				}
 			}
 
 			// Determine confidence
-			confidence := determineRenameConfidence(nameSim, relSim)
+			confidence := counts.renameConfidence(removed.TypeName, determineRenameConfidence(nameSim, relSim))
 
 			// Track the best match
 			if confidence != ConfidenceNone && (bestMatch == -1 || confidence > bestConfidence ||
@@ -347,6 +564,7 @@ func DetectPotentialRenames(changes []ModelChange, oldState, newState *ModelStat
 				detailsMsg = fmt.Sprintf("Potential rename: '%s' -> '%s' (low confidence - verify before using)",
 					removed.TypeName, added.TypeName)
 			}
+			detailsMsg += counts.migrationNote(removed.TypeName)
 
 			enhanced = append(enhanced, ModelChange{
 				Type:       ChangeTypeRenameType,
@@ -391,6 +609,7 @@ func DetectPotentialRenames(changes []ModelChange, oldState, newState *ModelStat
 		relationsByType[change.TypeName] = entry
 	}
 
+	var unmatchedRemovals, unmatchedAdditions []ModelChange
 	for typeName, relations := range relationsByType {
 		usedRemovals := make(map[int]bool)
 		usedAdditions := make(map[int]bool)
@@ -399,6 +618,7 @@ func DetectPotentialRenames(changes []ModelChange, oldState, newState *ModelStat
 			bestMatch := -1
 			bestConfidence := ConfidenceNone
 			bestSim := 0.0
+			bestDefSim := 0.0
 
 			for j, added := range relations.added {
 				if usedAdditions[j] {
@@ -406,16 +626,19 @@ func DetectPotentialRenames(changes []ModelChange, oldState, newState *ModelStat
 				}
 
 				// Calculate relation name similarity
-				sim := calculateSimilarity(removed.RelationName, added.RelationName)
+				sim := scorer.NameSimilarity(removed.RelationName, added.RelationName)
 
-				// For relations, we only use name similarity (no sub-structure to compare)
-				confidence := determineRenameConfidence(sim, 0.0)
+				// Definitions play the role relation sets play for types
+				defSim := scorer.RelationSimilarity(removed.RelationName, removed.OldValue, added.RelationName, added.NewValue)
+
+				confidence := counts.renameConfidence(typeName+"#"+removed.RelationName, determineRenameConfidence(sim, defSim))
 
 				if confidence != ConfidenceNone && (bestMatch == -1 || confidence > bestConfidence ||
-					(confidence == bestConfidence && sim > bestSim)) {
+					(confidence == bestConfidence && sim+defSim > bestSim+bestDefSim)) {
 					bestMatch = j
 					bestConfidence = confidence
 					bestSim = sim
+					bestDefSim = defSim
 				}
 			}
 
@@ -426,8 +649,8 @@ func DetectPotentialRenames(changes []ModelChange, oldState, newState *ModelStat
 				detailsMsg := ""
 				switch bestConfidence {
 				case ConfidenceHigh:
-					detailsMsg = fmt.Sprintf("Rename detected: '%s.%s' -> '%s.%s' (high confidence: %.0f%%)",
-						typeName, removed.RelationName, typeName, added.RelationName, bestSim*100)
+					detailsMsg = fmt.Sprintf("Rename detected: '%s.%s' -> '%s.%s' (high confidence: %.0f%% name, %.0f%% definition)",
+						typeName, removed.RelationName, typeName, added.RelationName, bestSim*100, bestDefSim*100)
 				case ConfidenceMedium:
 					detailsMsg = fmt.Sprintf("Possible rename: '%s.%s' -> '%s.%s' (medium confidence - review required)",
 						typeName, removed.RelationName, typeName, added.RelationName)
@@ -435,6 +658,7 @@ func DetectPotentialRenames(changes []ModelChange, oldState, newState *ModelStat
 					detailsMsg = fmt.Sprintf("Potential rename: '%s.%s' -> '%s.%s' (low confidence - verify before using)",
 						typeName, removed.RelationName, typeName, added.RelationName)
 				}
+				detailsMsg += counts.migrationNote(typeName + "#" + removed.RelationName)
 
 				enhanced = append(enhanced, ModelChange{
 					Type:         ChangeTypeRenameRelation,
@@ -450,20 +674,83 @@ func DetectPotentialRenames(changes []ModelChange, oldState, newState *ModelStat
 			}
 		}
 
-		// Add remaining changes
+		// Remaining changes may still be moves to another type
 		for i, change := range relations.removed {
 			if !usedRemovals[i] {
-				enhanced = append(enhanced, change)
+				unmatchedRemovals = append(unmatchedRemovals, change)
 			}
 		}
 		for i, change := range relations.added {
 			if !usedAdditions[i] {
-				enhanced = append(enhanced, change)
+				unmatchedAdditions = append(unmatchedAdditions, change)
+			}
+		}
+	}
+
+	return append(enhanced, detectRelationMoves(unmatchedRemovals, unmatchedAdditions, scorer, counts)...)
+}
+
+// detectRelationMoves pairs a removed relation with an added relation of the same name on
+// another type when their definitions are alike, e.g. team.can_invite moving to
+// organization.can_invite. Unpaired changes are returned as they are
+func detectRelationMoves(removals, additions []ModelChange, scorer SimilarityScorer, counts TupleCounts) []ModelChange {
+	var changes []ModelChange
+	usedAdditions := make(map[int]bool)
+
+	for _, removed := range removals {
+		bestMatch := -1
+		bestSim := 0.0
+		for j, added := range additions {
+			if usedAdditions[j] || added.RelationName != removed.RelationName || added.TypeName == removed.TypeName {
+				continue
+			}
+			sim := scorer.RelationSimilarity(removed.RelationName, removed.OldValue, added.RelationName, added.NewValue)
+			if sim >= 0.7 && sim > bestSim {
+				bestMatch, bestSim = j, sim
 			}
 		}
+
+		confidence := ConfidenceNone
+		if bestMatch != -1 {
+			confidence = ConfidenceMedium
+			if bestSim == 1 {
+				confidence = ConfidenceHigh
+			}
+		}
+		subject := removed.TypeName + "#" + removed.RelationName
+		confidence = counts.renameConfidence(subject, confidence)
+		if confidence == ConfidenceNone {
+			changes = append(changes, removed)
+			continue
+		}
+
+		added := additions[bestMatch]
+		details := fmt.Sprintf("Move detected: '%s.%s' -> '%s.%s' (high confidence: identical definition)",
+			removed.TypeName, removed.RelationName, added.TypeName, added.RelationName)
+		if confidence == ConfidenceMedium {
+			details = fmt.Sprintf("Possible move: '%s.%s' -> '%s.%s' (medium confidence - review required)",
+				removed.TypeName, removed.RelationName, added.TypeName, added.RelationName)
+		}
+
+		changes = append(changes, ModelChange{
+			Type:         ChangeTypeMoveRelation,
+			TypeName:     removed.TypeName,
+			RelationName: removed.RelationName,
+			OldValue:     removed.OldValue,
+			NewValue:     added.NewValue,
+			TargetType:   added.TypeName,
+			Confidence:   confidence,
+			Details:      details + counts.migrationNote(subject),
+		})
+		usedAdditions[bestMatch] = true
 	}
 
-	return enhanced
+	for j, added := range additions {
+		if !usedAdditions[j] {
+			changes = append(changes, added)
+		}
+	}
+	return changes
 }
 
 // areSimilar checks if two names are similar (basic heuristic)
@@ -621,6 +908,47 @@ func haveSimilarRelations(removedTypeState, addedTypeState TypeState) float64 {
 	return float64(matchingRelations) / float64(totalRelations)
 }
 
+// relationDefinitionSimilarity compares two relation definitions
+// Identical definitions score 1.0, otherwise the Jaccard similarity of their tokens is used.
+// References to the relation itself (e.g. "viewer from parent") are normalized so a
+// recursive relation still matches after being renamed
+func relationDefinitionSimilarity(oldName, oldDef, newName, newDef string) float64 {
+	oldTokens := definitionTokens(oldDef, oldName)
+	newTokens := definitionTokens(newDef, newName)
+	if len(oldTokens) == 0 || len(newTokens) == 0 {
+		return 0.0
+	}
+
+	matching := 0
+	for token := range oldTokens {
+		if newTokens[token] {
+			matching++
+		}
+	}
+	if matching == len(oldTokens) && matching == len(newTokens) {
+		return 1.0
+	}
+
+	return float64(matching) / float64(len(oldTokens)+len(newTokens)-matching)
+}
+
+// definitionTokens splits a relation definition into a set of tokens,
+// replacing references to the relation itself with a placeholder
+func definitionTokens(definition, relationName string) map[string]bool {
+	fields := strings.FieldsFunc(definition, func(r rune) bool {
+		return r == ' ' || r == '[' || r == ']' || r == ',' || r == '(' || r == ')'
+	})
+
+	tokens := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		if field == relationName {
+			field = "$self"
+		}
+		tokens[field] = true
+	}
+	return tokens
+}
+
 // sortRelationsByDependency sorts relations so that dependencies come before dependents
 // Direct relations (e.g., [user]) come first, then computed relations (e.g., owner from team),
 // then derived permissions (e.g., can_view: member or owner)
@@ -2,6 +2,8 @@ package omg_test
 
 import (
 	"context"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/demetere/omg/internal/testhelpers"
@@ -456,6 +458,99 @@ func TestDetectPotentialRenames_RelationRename_HighConfidence(t *testing.T) {
 	}
 }
 
+func TestDetectPotentialRenames_RelationRename_IdenticalDefinition(t *testing.T) {
+	oldState := &omg.ModelState{
+		Types: map[string]omg.TypeState{
+			"document": {
+				Name:      "document",
+				Relations: map[string]string{"can_view": "[user] or editor"},
+			},
+		},
+	}
+
+	newState := &omg.ModelState{
+		Types: map[string]omg.TypeState{
+			"document": {
+				Name:      "document",
+				Relations: map[string]string{"viewer": "[user] or editor"},
+			},
+		},
+	}
+
+	changes := omg.DetectChanges(oldState, newState)
+	enhanced := omg.DetectPotentialRenames(changes, oldState, newState)
+
+	require.Len(t, enhanced, 1)
+	assert.Equal(t, omg.ChangeTypeRenameRelation, enhanced[0].Type)
+	assert.Equal(t, "can_view", enhanced[0].OldValue)
+	assert.Equal(t, "viewer", enhanced[0].NewValue)
+	assert.Equal(t, omg.ConfidenceMedium, enhanced[0].Confidence)
+}
+
+func TestDetectPotentialRenames_RelationMove(t *testing.T) {
+	oldState := &omg.ModelState{
+		Types: map[string]omg.TypeState{
+			"team":         {Name: "team", Relations: map[string]string{"member": "[user]", "can_invite": "[user] or admin"}},
+			"organization": {Name: "organization", Relations: map[string]string{"member": "[user]"}},
+		},
+	}
+	newState := &omg.ModelState{
+		Types: map[string]omg.TypeState{
+			"team":         {Name: "team", Relations: map[string]string{"member": "[user]"}},
+			"organization": {Name: "organization", Relations: map[string]string{"member": "[user]", "can_invite": "[user] or admin"}},
+		},
+	}
+
+	changes := omg.DetectChanges(oldState, newState)
+	enhanced := omg.DetectPotentialRenames(changes, oldState, newState)
+
+	require.Len(t, enhanced, 1)
+	move := enhanced[0]
+	assert.Equal(t, omg.ChangeTypeMoveRelation, move.Type)
+	assert.Equal(t, "team", move.TypeName)
+	assert.Equal(t, "organization", move.TargetType)
+	assert.Equal(t, "can_invite", move.RelationName)
+	assert.Equal(t, omg.ConfidenceHigh, move.Confidence)
+	assert.Equal(t, "Move detected: 'team.can_invite' -> 'organization.can_invite' (high confidence: identical definition)", move.Details)
+
+	// A different definition under the same name is not a move
+	newState.Types["organization"].Relations["can_invite"] = "[organization#owner]"
+	changes = omg.DetectChanges(oldState, newState)
+	enhanced = omg.DetectPotentialRenames(changes, oldState, newState)
+	require.Len(t, enhanced, 2)
+	assert.Equal(t, omg.ChangeTypeRemoveRelation, enhanced[0].Type)
+	assert.Equal(t, omg.ChangeTypeAddRelation, enhanced[1].Type)
+}
+
+func TestDetectPotentialRenames_RelationRename_DefinitionRaisesConfidence(t *testing.T) {
+	// "reader" -> "read_access" is only a medium match by name; the recursive
+	// definition matches once its self-reference is normalized
+	oldState := &omg.ModelState{
+		Types: map[string]omg.TypeState{
+			"folder": {
+				Name:      "folder",
+				Relations: map[string]string{"reader": "[user] or reader from parent"},
+			},
+		},
+	}
+
+	newState := &omg.ModelState{
+		Types: map[string]omg.TypeState{
+			"folder": {
+				Name:      "folder",
+				Relations: map[string]string{"read_access": "[user] or read_access from parent"},
+			},
+		},
+	}
+
+	changes := omg.DetectChanges(oldState, newState)
+	enhanced := omg.DetectPotentialRenames(changes, oldState, newState)
+
+	require.Len(t, enhanced, 1)
+	assert.Equal(t, omg.ChangeTypeRenameRelation, enhanced[0].Type)
+	assert.Equal(t, omg.ConfidenceHigh, enhanced[0].Confidence)
+}
+
 func TestDetectPotentialRenames_MultipleRenames(t *testing.T) {
 	oldState := &omg.ModelState{
 		Types: map[string]omg.TypeState{
@@ -695,3 +790,147 @@ func TestDetectPotentialRenames_EdgeCases(t *testing.T) {
 		})
 	}
 }
+
+func TestLoadCurrentModelFromPath_Stdin(t *testing.T) {
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+
+	stdin := os.Stdin
+	os.Stdin = r
+	defer func() { os.Stdin = stdin }()
+
+	dsl := "model\n  schema 1.1\n\ntype user\n"
+	_, err = w.WriteString(dsl)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	loaded, err := omg.LoadCurrentModelFromPath("-")
+	require.NoError(t, err)
+	assert.Equal(t, dsl, loaded)
+}
+
+func TestDetectChangesWithOptions_Ignore(t *testing.T) {
+	oldState := &omg.ModelState{
+		Types: map[string]omg.TypeState{
+			"document": {
+				Name:      "document",
+				Relations: map[string]string{"viewer": "[user]", "draft_editor": "[user]"},
+			},
+		},
+	}
+
+	newState := &omg.ModelState{
+		Types: map[string]omg.TypeState{
+			"document": {
+				Name:      "document",
+				Relations: map[string]string{"viewer": "[user] or editor", "editor": "[user]"},
+			},
+			"experimental_board": {
+				Name:      "experimental_board",
+				Relations: map[string]string{"member": "[user]"},
+			},
+		},
+	}
+
+	opts := omg.DetectOptions{
+		IgnoreTypes:       []string{"experimental_*"},
+		IgnoreRelations:   []string{"document#draft_*"},
+		IgnoreChangeTypes: []omg.ChangeType{omg.ChangeTypeUpdateRelation},
+	}
+
+	changes := omg.DetectChangesWithOptions(oldState, newState, opts)
+	changes = omg.DetectPotentialRenamesWithOptions(changes, oldState, newState, opts)
+
+	require.Len(t, changes, 1)
+	assert.Equal(t, omg.ChangeTypeAddRelation, changes[0].Type)
+	assert.Equal(t, "editor", changes[0].RelationName)
+}
+
+func TestDetectOptions_IgnoresRenames(t *testing.T) {
+	opts := omg.DetectOptions{IgnoreTypes: []string{"legacy_doc"}}
+
+	assert.True(t, opts.Ignores(omg.ModelChange{Type: omg.ChangeTypeRenameType, TypeName: "legacy_doc", OldValue: "legacy_doc", NewValue: "document"}))
+	assert.True(t, opts.Ignores(omg.ModelChange{Type: omg.ChangeTypeRenameType, TypeName: "doc", OldValue: "doc", NewValue: "legacy_doc"}))
+	assert.False(t, opts.Ignores(omg.ModelChange{Type: omg.ChangeTypeRenameType, TypeName: "doc", OldValue: "doc", NewValue: "document"}))
+}
+
+func TestLoadModelStateFromFile(t *testing.T) {
+	dir := t.TempDir()
+	oldPath := filepath.Join(dir, "old.fga")
+	require.NoError(t, os.WriteFile(oldPath, []byte(`model
+  schema 1.1
+
+type user
+
+type document
+  relations
+    define viewer: [user]
+`), 0644))
+
+	newModel, err := omg.ParseDSLToModel(`model
+  schema 1.1
+
+type user
+
+type document
+  relations
+    define viewer: [user]
+    define editor: [user]
+`)
+	require.NoError(t, err)
+	newPath := filepath.Join(dir, "new.snapshot")
+	require.NoError(t, omg.WriteSnapshot(newPath, &omg.StoreSnapshot{StoreID: "01HSTORE", Model: newModel}))
+
+	oldState, err := omg.LoadModelStateFromFile(oldPath)
+	require.NoError(t, err)
+	newState, err := omg.LoadModelStateFromFile(newPath)
+	require.NoError(t, err)
+
+	changes := omg.DetectChanges(oldState, newState)
+	require.Len(t, changes, 1)
+	assert.Equal(t, omg.ChangeTypeAddRelation, changes[0].Type)
+	assert.Equal(t, "editor", changes[0].RelationName)
+}
+
+func TestLoadModelStateFromFile_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "model.fga")
+	require.NoError(t, os.WriteFile(path, []byte("type document\n  relations\n    define viewer: [user\n"), 0644))
+
+	_, err := omg.LoadModelStateFromFile(path)
+	assert.Error(t, err)
+}
+
+func TestDetectChanges_UpdateTypeRestrictions(t *testing.T) {
+	oldState := &omg.ModelState{
+		Types: map[string]omg.TypeState{
+			"document": {Name: "document", Relations: map[string]string{
+				"viewer": "[user] or editor",
+				"editor": "[user, group#member]",
+			}},
+		},
+	}
+	newState := &omg.ModelState{
+		Types: map[string]omg.TypeState{
+			"document": {Name: "document", Relations: map[string]string{
+				"viewer": "[user, group#member] or editor",
+				"editor": "[user, group#member] or owner",
+			}},
+		},
+	}
+
+	changes := omg.DetectChanges(oldState, newState).ByType()
+	require.Len(t, changes[omg.ChangeTypeUpdateTypeRestrictions], 1)
+	restrictions := changes[omg.ChangeTypeUpdateTypeRestrictions][0]
+	assert.Equal(t, "viewer", restrictions.RelationName)
+	assert.Equal(t, "Updated type restrictions of 'document.viewer': [user] → [user, group#member]", restrictions.Details)
+
+	// A structural change is an update, even when the restrictions stay the same
+	require.Len(t, changes[omg.ChangeTypeUpdateRelation], 1)
+	assert.Equal(t, "editor", changes[omg.ChangeTypeUpdateRelation][0].RelationName)
+}
+
+func TestRemovedTypeRestrictions(t *testing.T) {
+	assert.Equal(t, []string{"group#member", "user:*"}, omg.RemovedTypeRestrictions("[user, group#member, user:*] or owner", "[user] or owner"))
+	assert.Empty(t, omg.RemovedTypeRestrictions("[user]", "[user, group#member]"))
+	assert.Empty(t, omg.RemovedTypeRestrictions("owner", "[user]"))
+}
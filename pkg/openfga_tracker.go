@@ -0,0 +1,228 @@
+package omg
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	openfgaSdk "github.com/openfga/go-sdk"
+	"github.com/openfga/go-sdk/client"
+)
+
+// Types and subject the OpenFGA tracker records applied migrations with:
+// omg_migration:<version> applied omg_system:omg
+// The types are namespaced so they do not collide with the application's own
+const (
+	TrackingObjectType = "omg_migration"
+	TrackingUserType   = "omg_system"
+	TrackingRelation   = "applied"
+	TrackingUser       = TrackingUserType + ":omg"
+)
+
+// CheckTrackingTypes fails when a model declares the types the OpenFGA tracker reserves
+// Change detection leaves them out under the tuples tracker, so edits to them would
+// silently never be migrated
+func CheckTrackingTypes(state *ModelState) error {
+	for _, name := range []string{TrackingObjectType, TrackingUserType} {
+		if _, exists := state.Types[name]; exists {
+			return fmt.Errorf("type %s is reserved for omg's tuple tracker (-tracker tuples); rename it", name)
+		}
+	}
+	return nil
+}
+
+// OpenFGATracker records applied migrations as tuples in the store itself, for teams
+// without a relational database. It keeps no run history: failed runs are not recorded
+// and rolled back migrations are forgotten
+type OpenFGATracker struct {
+	client *Client
+}
+
+// NewOpenFGATracker creates a tracker that stores migrations in the client's store
+// Call EnsureTrackingType before recording migrations in a store that has never been tracked
+func NewOpenFGATracker(client *Client) *OpenFGATracker {
+	return &OpenFGATracker{client: client}
+}
+
+// EnsureTrackingType adds the omg_system type and the omg_migration type with its applied
+// relation to the model when they are missing. Types of those names that omg did not
+// create are an error rather than being extended
+func (t *OpenFGATracker) EnsureTrackingType(ctx context.Context) error {
+	provisioned, err := t.provisioned(ctx)
+	if err != nil || provisioned {
+		return err
+	}
+
+	model, err := t.client.GetCurrentAuthorizationModel(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current model: %w", err)
+	}
+	userTypeExists := false
+	for _, typeDef := range model.GetTypeDefinitions() {
+		// An omg_system without relations is left by an interrupted EnsureTrackingType
+		if typeDef.GetType() == TrackingObjectType || typeDef.GetType() == TrackingUserType && len(typeDef.GetRelations()) > 0 {
+			return fmt.Errorf("the model already has a type %s that omg did not create; it is reserved for the tuple tracker", typeDef.GetType())
+		}
+		userTypeExists = userTypeExists || typeDef.GetType() == TrackingUserType
+	}
+
+	if !userTypeExists {
+		if err := AddTypeToModel(ctx, t.client, TrackingUserType, map[string]string{}); err != nil {
+			return fmt.Errorf("failed to add tracking type: %w", err)
+		}
+	}
+	definition := "[" + TrackingUserType + "]"
+	if err := AddTypeToModel(ctx, t.client, TrackingObjectType, map[string]string{TrackingRelation: definition}); err != nil {
+		return fmt.Errorf("failed to add tracking type: %w", err)
+	}
+	return nil
+}
+
+// provisioned reports whether the model defines omg_migration#applied
+// An omg_migration#applied that does not accept omg_system users is an error
+func (t *OpenFGATracker) provisioned(ctx context.Context) (bool, error) {
+	model, err := t.client.GetCurrentAuthorizationModel(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to get current model: %w", err)
+	}
+
+	for _, typeDef := range model.GetTypeDefinitions() {
+		if typeDef.GetType() != TrackingObjectType {
+			continue
+		}
+		if _, exists := typeDef.GetRelations()[TrackingRelation]; !exists {
+			return false, nil
+		}
+
+		metadata := typeDef.GetMetadata()
+		relationMetadata := metadata.GetRelations()[TrackingRelation]
+		for _, ref := range relationMetadata.GetDirectlyRelatedUserTypes() {
+			if ref.GetType() == TrackingUserType && ref.Relation == nil && ref.Wildcard == nil {
+				return true, nil
+			}
+		}
+		return false, fmt.Errorf("%s#%s exists but does not accept %s users", TrackingObjectType, TrackingRelation, TrackingUserType)
+	}
+	return false, nil
+}
+
+// keepTrackingTypes adds the tuple tracker's types of the live model to model when it
+// does not define them. Whole models, as model-apply migrations and their down apply,
+// come from model files that leave them out; dropping them would lose the record of
+// applied migrations
+func keepTrackingTypes(ctx context.Context, client *Client, model *openfgaSdk.AuthorizationModel) error {
+	live, err := client.GetCurrentAuthorizationModel(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current model: %w", err)
+	}
+
+	defined := make(map[string]bool, len(model.TypeDefinitions))
+	for _, typeDef := range model.TypeDefinitions {
+		defined[typeDef.GetType()] = true
+	}
+	for _, typeDef := range live.GetTypeDefinitions() {
+		name := typeDef.GetType()
+		if (name == TrackingObjectType || name == TrackingUserType) && !defined[name] {
+			model.TypeDefinitions = append(model.TypeDefinitions, typeDef)
+		}
+	}
+	return nil
+}
+
+// GetApplied implements MigrationTracker
+// Names are not stored, so only Version and AppliedAt are set. A store whose model has
+// no tracking type has no applied migrations
+func (t *OpenFGATracker) GetApplied(ctx context.Context) (map[string]MigrationInfo, error) {
+	applied := make(map[string]MigrationInfo)
+
+	provisioned, err := t.provisioned(ctx)
+	if err != nil || !provisioned {
+		return applied, err
+	}
+
+	body := client.ClientReadRequest{
+		User:     openfgaSdk.PtrString(TrackingUser),
+		Relation: openfgaSdk.PtrString(TrackingRelation),
+		Object:   openfgaSdk.PtrString(TrackingObjectType + ":"),
+	}
+	continuationToken := ""
+	for {
+		response, err := t.client.readPage(ctx, body, continuationToken)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+		}
+
+		for _, tuple := range response.GetTuples() {
+			key := tuple.GetKey()
+			version := strings.TrimPrefix(key.GetObject(), TrackingObjectType+":")
+			applied[version] = MigrationInfo{Version: version, AppliedAt: tuple.GetTimestamp()}
+		}
+
+		continuationToken = response.GetContinuationToken()
+		if continuationToken == "" {
+			break
+		}
+	}
+
+	return applied, nil
+}
+
+// Record marks a migration as applied
+func (t *OpenFGATracker) Record(ctx context.Context, version, name string) error {
+	return t.RecordRunWithOptions(ctx, MigrationRun{Version: version, Name: name, Status: RunStatusApplied}, RemoveOptions{})
+}
+
+// Remove forgets an applied migration
+func (t *OpenFGATracker) Remove(ctx context.Context, version string) error {
+	return t.RecordRunWithOptions(ctx, MigrationRun{Version: version, Status: RunStatusRolledBack}, RemoveOptions{})
+}
+
+// RecordRunWithOptions implements MigrationTracker
+// Applied runs write the tracking tuple and rolled back runs delete it; failed runs and
+// opts are ignored, since the store keeps no history
+func (t *OpenFGATracker) RecordRunWithOptions(ctx context.Context, run MigrationRun, opts RemoveOptions) error {
+	tuple := Tuple{User: TrackingUser, Relation: TrackingRelation, Object: TrackingObjectType + ":" + run.Version}
+
+	switch run.Status {
+	case RunStatusApplied:
+		stored, err := t.isStored(ctx, tuple)
+		if err != nil {
+			return fmt.Errorf("failed to record migration: %w", err)
+		}
+		if stored {
+			return fmt.Errorf("failed to record migration: %s is already applied", run.Version)
+		}
+		if err := t.client.WriteTuple(ctx, tuple); err != nil {
+			return fmt.Errorf("failed to record migration: %w", err)
+		}
+
+	case RunStatusRolledBack:
+		stored, err := t.isStored(ctx, tuple)
+		if err != nil {
+			return fmt.Errorf("failed to remove migration: %w", err)
+		}
+		if !stored {
+			return nil // Not applied, nothing to roll back
+		}
+		if err := t.client.DeleteTuple(ctx, tuple); err != nil {
+			return fmt.Errorf("failed to remove migration: %w", err)
+		}
+
+	case RunStatusFailed:
+		// No history to record in
+
+	default:
+		return fmt.Errorf("unknown run status '%s'", run.Status)
+	}
+
+	return nil
+}
+
+// isStored reports whether a tracking tuple is written, reading only that tuple
+func (t *OpenFGATracker) isStored(ctx context.Context, tuple Tuple) (bool, error) {
+	tuples, err := t.client.ReadAllTuples(ctx, ReadTuplesRequest{User: tuple.User, Relation: tuple.Relation, Object: tuple.Object})
+	if err != nil {
+		return false, err
+	}
+	return len(tuples) > 0, nil
+}
@@ -0,0 +1,140 @@
+package omg_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/demetere/omg/internal/testhelpers"
+	"github.com/demetere/omg/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenFGATracker_EnsureTrackingType(t *testing.T) {
+	ctx := context.Background()
+
+	container, client := testhelpers.SetupOpenFGAContainer(t, ctx, `
+type user
+`)
+	defer container.Terminate(ctx)
+
+	tracker := omg.NewOpenFGATracker(client)
+
+	// No tracking type yet: nothing applied, and recording is not possible
+	applied, err := tracker.GetApplied(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, applied)
+
+	require.NoError(t, tracker.EnsureTrackingType(ctx))
+	require.NoError(t, tracker.EnsureTrackingType(ctx)) // Idempotent
+
+	exists, err := omg.RelationExists(ctx, client, omg.TrackingObjectType, omg.TrackingRelation)
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	require.NoError(t, tracker.Record(ctx, "20240101000000", "first"))
+	assert.Error(t, tracker.Record(ctx, "20240101000000", "first"), "recording twice should fail")
+
+	applied, err = tracker.GetApplied(ctx)
+	require.NoError(t, err)
+	require.Contains(t, applied, "20240101000000")
+	assert.False(t, applied["20240101000000"].AppliedAt.IsZero())
+
+	// Failed runs are not recorded and removing an unapplied migration is a no-op
+	require.NoError(t, tracker.RecordRunWithOptions(ctx, omg.MigrationRun{Version: "20240102000000", Status: omg.RunStatusFailed}, omg.RemoveOptions{}))
+	require.NoError(t, tracker.Remove(ctx, "20240102000000"))
+
+	applied, err = tracker.GetApplied(ctx)
+	require.NoError(t, err)
+	assert.Len(t, applied, 1)
+}
+
+func TestOpenFGATracker_EnsureTrackingType_IncompatibleRelation(t *testing.T) {
+	ctx := context.Background()
+
+	container, client := testhelpers.SetupOpenFGAContainer(t, ctx, `
+type user
+`)
+	defer container.Terminate(ctx)
+
+	require.NoError(t, omg.AddTypeToModel(ctx, client, omg.TrackingObjectType, map[string]string{"applied": "[user]"}))
+
+	err := omg.NewOpenFGATracker(client).EnsureTrackingType(ctx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "does not accept omg_system users")
+}
+
+func TestOpenFGATracker_RecordReadsOnlyTheTrackingTuple(t *testing.T) {
+	store, client := newTupleStore(t, []omg.Tuple{{User: "user:anne", Relation: "viewer", Object: "doc:1"}})
+	tracker := omg.NewOpenFGATracker(client)
+	ctx := context.Background()
+	tuple := omg.Tuple{User: omg.TrackingUser, Relation: omg.TrackingRelation, Object: omg.TrackingObjectType + ":20240101000000"}
+
+	require.NoError(t, tracker.Record(ctx, "20240101000000", "add_docs"))
+	assert.Equal(t, []omg.Tuple{tuple}, store.reads)
+	assert.Equal(t, 1, store.count(omg.TrackingRelation))
+
+	require.Error(t, tracker.Record(ctx, "20240101000000", "add_docs"), "already applied")
+
+	store.reads = nil
+	require.NoError(t, tracker.Remove(ctx, "20240101000000"))
+	assert.Equal(t, []omg.Tuple{tuple}, store.reads)
+	assert.Equal(t, 0, store.count(omg.TrackingRelation))
+}
+
+func TestCheckTrackingTypes(t *testing.T) {
+	state := &omg.ModelState{Types: map[string]omg.TypeState{
+		"user":   {Name: "user"},
+		"system": {Name: "system", Relations: map[string]string{"admin": "[user]"}},
+	}}
+	assert.NoError(t, omg.CheckTrackingTypes(state), "the application's own system type is not the tracker's")
+
+	state.Types[omg.TrackingObjectType] = omg.TypeState{Name: omg.TrackingObjectType}
+	err := omg.CheckTrackingTypes(state)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "type omg_migration is reserved")
+}
+
+func TestApplyModelFromDSL_KeepsTrackingTypes(t *testing.T) {
+	var written []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodPost {
+			var body struct {
+				TypeDefinitions []struct {
+					Type string `json:"type"`
+				} `json:"type_definitions"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			for _, typeDef := range body.TypeDefinitions {
+				written = append(written, typeDef.Type)
+			}
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"authorization_model_id":"01MODEL3"}`))
+			return
+		}
+		// The live model, tracked with -tracker tuples
+		w.Write([]byte(`{"authorization_models":[{"id":"01MODEL2","schema_version":"1.1","type_definitions":[
+			{"type":"user"},
+			{"type":"omg_system"},
+			{"type":"omg_migration","relations":{"applied":{"this":{}}},"metadata":{"relations":{"applied":{"directly_related_user_types":[{"type":"omg_system"}]}}}}]}]}`))
+	}))
+	defer server.Close()
+
+	client, err := omg.NewClient(omg.Config{ApiURL: server.URL, StoreID: testStoreID, AuthMethod: "none"})
+	require.NoError(t, err)
+
+	require.NoError(t, omg.ApplyModelFromDSL(context.Background(), client, `model
+  schema 1.1
+
+type user
+
+type document
+  relations
+    define viewer: [user]
+`))
+	assert.Equal(t, []string{"user", "document", "omg_system", "omg_migration"}, written)
+}
@@ -0,0 +1,256 @@
+package omg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	progressBarWidth = 30
+
+	// terminalRedrawInterval and jsonProgressInterval bound how often progress is shown;
+	// the last update of an operation with a known total is always shown
+	terminalRedrawInterval = 100 * time.Millisecond
+	jsonProgressInterval   = time.Second
+)
+
+// ProgressReporter shows how far long tuple operations have got: batched writes and
+// deletes, renames and backups. Set one with SetProgressReporter
+type ProgressReporter interface {
+	// Progress is called as the operation advances. p.Total is 0 when the number of
+	// tuples is not known up front, as for renames and backups
+	Progress(p BatchProgress)
+
+	// Finish is called once when the operation has ended, with its error if it failed
+	Finish(p BatchProgress, err error)
+}
+
+var (
+	progressReporterMu sync.RWMutex
+	progressReporter   ProgressReporter // nil selects NewProgressReporter(os.Stdout)
+)
+
+// SetProgressReporter selects where tuple operations report their progress from now on
+// nil restores the default: a progress bar on a terminal, JSON progress events otherwise
+func SetProgressReporter(reporter ProgressReporter) {
+	progressReporterMu.Lock()
+	defer progressReporterMu.Unlock()
+	progressReporter = reporter
+}
+
+// currentProgressReporter returns the reporter set with SetProgressReporter, or the default
+func currentProgressReporter() ProgressReporter {
+	progressReporterMu.RLock()
+	defer progressReporterMu.RUnlock()
+	if progressReporter != nil {
+		return progressReporter
+	}
+	return NewProgressReporter(os.Stdout)
+}
+
+// NewProgressReporter returns a progress bar when f is a terminal, and JSON progress
+// events otherwise, e.g. when the output goes to a CI log
+func NewProgressReporter(f *os.File) ProgressReporter {
+	if info, err := f.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		return NewTerminalProgress(f)
+	}
+	return NewJSONProgress(f)
+}
+
+// NewTerminalProgress returns a reporter that redraws one line of w as a progress bar with
+// counts, rate and ETA, and replaces it with a summary when the operation has finished
+//
+//	Writing [===========>                  ] 4100/10000  41%  310 tuples/s, ETA 19s
+func NewTerminalProgress(w io.Writer) ProgressReporter {
+	return &terminalProgress{w: w}
+}
+
+// terminalProgress is the reporter of NewTerminalProgress
+type terminalProgress struct {
+	mu    sync.Mutex
+	w     io.Writer
+	shown time.Time // When the line was last drawn
+	drawn bool      // The line holds a progress bar
+}
+
+func (r *terminalProgress) Progress(p BatchProgress) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if time.Since(r.shown) < terminalRedrawInterval && (p.Total == 0 || p.Done < p.Total) {
+		return
+	}
+	r.shown = time.Now()
+	r.drawn = true
+	fmt.Fprintf(r.w, "\r\033[K%s", progressLine(p))
+}
+
+func (r *terminalProgress) Finish(p BatchProgress, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.shown = time.Time{}
+	if err != nil {
+		if r.drawn {
+			fmt.Fprintln(r.w) // Keep the bar, to show where it stopped
+		}
+		r.drawn = false
+		return
+	}
+	if r.drawn {
+		fmt.Fprint(r.w, "\r\033[K")
+	}
+	r.drawn = false
+	_, past := progressVerbs(p.Operation)
+	fmt.Fprintf(r.w, "%s %d tuples in %s (%.0f tuples/s)\n", past, p.Done, p.Elapsed.Round(time.Millisecond), p.Rate())
+}
+
+// progressLine formats the progress bar of NewTerminalProgress; without a total it shows
+// the count and rate only
+func progressLine(p BatchProgress) string {
+	verb, _ := progressVerbs(p.Operation)
+	if p.Total <= 0 {
+		return fmt.Sprintf("%s %d tuples  %.0f tuples/s", verb, p.Done, p.Rate())
+	}
+
+	filled := p.Done * progressBarWidth / p.Total
+	bar := strings.Repeat("=", filled)
+	if filled < progressBarWidth {
+		bar += ">" + strings.Repeat(" ", progressBarWidth-filled-1)
+	}
+	return fmt.Sprintf("%s [%s] %d/%d %3d%%  %s", verb, bar, p.Done, p.Total, p.Done*100/p.Total, p)
+}
+
+// progressVerbs names an operation in progress and once done, e.g. "Writing" and "Wrote"
+// Renames and moves report under their checkpoint operation, e.g. "rename_type a b"
+func progressVerbs(operation string) (string, string) {
+	switch operation {
+	case "write":
+		return "Writing", "Wrote"
+	case "delete":
+		return "Deleting", "Deleted"
+	case "backup":
+		return "Backing up", "Backed up"
+	}
+	return "Moving", "Moved"
+}
+
+// NewJSONProgress returns a reporter that writes progress events to w as JSON lines, at
+// most one a second per operation, and a final event when the operation has finished
+//
+//	{"event":"progress","operation":"write","done":4100,"total":10000,"elapsed_ms":13226,"rate":310,"eta_ms":19032}
+//	{"event":"finished","operation":"write","done":10000,"total":10000,"elapsed_ms":32258,"rate":310}
+func NewJSONProgress(w io.Writer) ProgressReporter {
+	return &jsonProgress{w: w, shown: map[string]time.Time{}}
+}
+
+// jsonProgress is the reporter of NewJSONProgress
+type jsonProgress struct {
+	mu    sync.Mutex
+	w     io.Writer
+	shown map[string]time.Time // When each operation last reported
+}
+
+// ProgressEvent is a line written by the reporter of NewJSONProgress
+type ProgressEvent struct {
+	Event     string  `json:"event"` // "progress", "finished" or "failed"
+	Operation string  `json:"operation"`
+	Done      int     `json:"done"`
+	Total     int     `json:"total,omitempty"` // 0 when not known up front
+	ElapsedMs int64   `json:"elapsed_ms"`
+	Rate      float64 `json:"rate"`             // Tuples per second
+	ETAMs     int64   `json:"eta_ms,omitempty"` // 0 when not known
+	Error     string  `json:"error,omitempty"`
+}
+
+func (r *jsonProgress) Progress(p BatchProgress) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if time.Since(r.shown[p.Operation]) < jsonProgressInterval && (p.Total == 0 || p.Done < p.Total) {
+		return
+	}
+	r.shown[p.Operation] = time.Now()
+	r.write("progress", p, nil)
+}
+
+func (r *jsonProgress) Finish(p BatchProgress, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.shown, p.Operation)
+	if err != nil {
+		r.write("failed", p, err)
+		return
+	}
+	r.write("finished", p, nil)
+}
+
+// write writes one event; a reporter has no way to fail the operation, so errors are dropped
+func (r *jsonProgress) write(event string, p BatchProgress, err error) {
+	line := ProgressEvent{
+		Event:     event,
+		Operation: p.Operation,
+		Done:      p.Done,
+		Total:     p.Total,
+		ElapsedMs: p.Elapsed.Milliseconds(),
+		Rate:      math.Round(p.Rate()*10) / 10,
+		ETAMs:     p.ETA().Milliseconds(),
+	}
+	if err != nil {
+		line.Error = err.Error()
+	}
+	data, _ := json.Marshal(line)
+	r.w.Write(append(data, '\n'))
+}
+
+// quietProgressKey is the context key withoutProgressReporter stores its flag under
+type quietProgressKey struct{}
+
+// withoutProgressReporter returns a context whose tuple operations do not show progress,
+// for the batches of an operation that reports its own, such as a rename
+func withoutProgressReporter(ctx context.Context) context.Context {
+	return context.WithValue(ctx, quietProgressKey{}, true)
+}
+
+// progressTracker reports one operation to the progress reporter, the caller's callback and
+// the event stream of the migration that is running
+type progressTracker struct {
+	ctx       context.Context
+	reporter  ProgressReporter // nil under withoutProgressReporter
+	callback  func(BatchProgress)
+	operation string
+	total     int
+	start     time.Time
+}
+
+// startProgress starts tracking an operation on total tuples, or an unknown number if 0
+func startProgress(ctx context.Context, operation string, total int, callback func(BatchProgress)) *progressTracker {
+	tracker := &progressTracker{ctx: ctx, callback: callback, operation: operation, total: total, start: time.Now()}
+	if quiet, _ := ctx.Value(quietProgressKey{}).(bool); !quiet {
+		tracker.reporter = currentProgressReporter()
+	}
+	return tracker
+}
+
+// update reports that done tuples have been processed
+func (t *progressTracker) update(done int) {
+	progress := BatchProgress{Operation: t.operation, Done: done, Total: t.total, Elapsed: time.Since(t.start)}
+	if t.reporter != nil {
+		t.reporter.Progress(progress)
+	}
+	if t.callback != nil {
+		t.callback(progress)
+	}
+	emitEvent(t.ctx, MigrationEvent{Type: EventBatchProgress, Batch: &progress})
+}
+
+// finish reports the end of the operation after done tuples
+func (t *progressTracker) finish(done int, err error) {
+	if t.reporter != nil {
+		t.reporter.Finish(BatchProgress{Operation: t.operation, Done: done, Total: t.total, Elapsed: time.Since(t.start)}, err)
+	}
+}
@@ -0,0 +1,128 @@
+package omg_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/demetere/omg/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingProgress records what it is reported
+type recordingProgress struct {
+	updates  []omg.BatchProgress
+	finished []omg.BatchProgress
+	errs     []error
+}
+
+func (r *recordingProgress) Progress(p omg.BatchProgress) { r.updates = append(r.updates, p) }
+
+func (r *recordingProgress) Finish(p omg.BatchProgress, err error) {
+	r.finished = append(r.finished, p)
+	r.errs = append(r.errs, err)
+}
+
+func TestTerminalProgress(t *testing.T) {
+	var out bytes.Buffer
+	reporter := omg.NewTerminalProgress(&out)
+
+	reporter.Progress(omg.BatchProgress{Operation: "write", Done: 500, Total: 2000, Elapsed: 2 * time.Second})
+	assert.Equal(t, "\r\033[KWriting [=======>                      ] 500/2000  25%  250 tuples/s, ETA 6s", out.String())
+
+	out.Reset()
+	reporter.Progress(omg.BatchProgress{Operation: "write", Done: 600, Total: 2000, Elapsed: 2 * time.Second})
+	assert.Empty(t, out.String(), "redraws are throttled")
+
+	reporter.Finish(omg.BatchProgress{Operation: "write", Done: 2000, Total: 2000, Elapsed: 8 * time.Second}, nil)
+	assert.Equal(t, "\r\033[KWrote 2000 tuples in 8s (250 tuples/s)\n", out.String())
+
+	// Without a total, e.g. a rename
+	out.Reset()
+	reporter.Progress(omg.BatchProgress{Operation: "rename_type team organization", Done: 3000, Elapsed: 2 * time.Second})
+	assert.Equal(t, "\r\033[KMoving 3000 tuples  1500 tuples/s", out.String())
+
+	out.Reset()
+	reporter.Finish(omg.BatchProgress{Operation: "rename_type team organization", Done: 3000}, errors.New("boom"))
+	assert.Equal(t, "\n", out.String(), "a failed operation keeps its bar")
+}
+
+func TestJSONProgress(t *testing.T) {
+	var out bytes.Buffer
+	reporter := omg.NewJSONProgress(&out)
+
+	reporter.Progress(omg.BatchProgress{Operation: "delete", Done: 500, Total: 2000, Elapsed: 2 * time.Second})
+	reporter.Progress(omg.BatchProgress{Operation: "delete", Done: 600, Total: 2000, Elapsed: 2 * time.Second})
+	reporter.Progress(omg.BatchProgress{Operation: "delete", Done: 2000, Total: 2000, Elapsed: 8 * time.Second})
+	reporter.Finish(omg.BatchProgress{Operation: "backup", Done: 120, Elapsed: time.Second}, errors.New("boom"))
+
+	var events []omg.ProgressEvent
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		var event omg.ProgressEvent
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		events = append(events, event)
+	}
+	assert.Equal(t, []omg.ProgressEvent{
+		{Event: "progress", Operation: "delete", Done: 500, Total: 2000, ElapsedMs: 2000, Rate: 250, ETAMs: 6000},
+		{Event: "progress", Operation: "delete", Done: 2000, Total: 2000, ElapsedMs: 8000, Rate: 250},
+		{Event: "failed", Operation: "backup", Done: 120, ElapsedMs: 1000, Rate: 120, Error: "boom"},
+	}, events, "updates within a second are dropped, but not the last one")
+}
+
+func TestSetProgressReporter(t *testing.T) {
+	server, _ := newStoreServer(t)
+	client, err := omg.NewClient(omg.Config{ApiURL: server.URL, StoreID: newStoreID, AuthMethod: "token", APIToken: "secret"})
+	require.NoError(t, err)
+
+	reporter := &recordingProgress{}
+	omg.SetProgressReporter(reporter)
+	defer omg.SetProgressReporter(nil)
+
+	tuples := make([]omg.Tuple, 250)
+	for i := range tuples {
+		tuples[i] = omg.Tuple{User: fmt.Sprintf("user:%d", i), Relation: "viewer", Object: "doc:1"}
+	}
+	require.NoError(t, omg.WriteTuplesBatch(context.Background(), client, tuples))
+
+	var done []int
+	for _, update := range reporter.updates {
+		assert.Equal(t, "write", update.Operation)
+		assert.Equal(t, 250, update.Total)
+		done = append(done, update.Done)
+	}
+	assert.Equal(t, []int{100, 200, 250}, done)
+	require.Len(t, reporter.finished, 1)
+	assert.Equal(t, 250, reporter.finished[0].Done)
+	assert.NoError(t, reporter.errs[0])
+
+	// Backups report each page read, without a total
+	*reporter = recordingProgress{}
+	backup, err := omg.BackupTuples(context.Background(), client)
+	require.NoError(t, err)
+	assert.Len(t, backup, 120)
+
+	done = nil
+	for _, update := range reporter.updates {
+		assert.Equal(t, "backup", update.Operation)
+		assert.Zero(t, update.Total)
+		done = append(done, update.Done)
+	}
+	assert.Equal(t, []int{50, 100, 120}, done)
+	require.Len(t, reporter.finished, 1)
+	assert.Equal(t, 120, reporter.finished[0].Done)
+
+	var out strings.Builder
+	*reporter = recordingProgress{}
+	count, err := omg.BackupTuplesTo(context.Background(), client, &out)
+	require.NoError(t, err)
+	assert.Equal(t, 120, count)
+	assert.Len(t, reporter.updates, 3)
+}
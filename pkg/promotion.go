@@ -0,0 +1,58 @@
+package omg
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// PromotionPlan compares the applied migrations of two environments, e.g. staging and prod
+type PromotionPlan struct {
+	// Missing are applied in the source but not in the target, by version
+	Missing []MigrationInfo
+
+	// Extra are applied in the target but not in the source, by version
+	Extra []MigrationInfo
+}
+
+// UpTo returns the last missing version: promoting applies the target's migrations up to it
+func (p PromotionPlan) UpTo() string {
+	if len(p.Missing) == 0 {
+		return ""
+	}
+	return p.Missing[len(p.Missing)-1].Version
+}
+
+// InSync reports whether both environments have applied the same migrations
+func (p PromotionPlan) InSync() bool {
+	return len(p.Missing) == 0 && len(p.Extra) == 0
+}
+
+// PlanPromotion compares what the source and target trackers have applied
+func PlanPromotion(ctx context.Context, source, target MigrationTracker) (PromotionPlan, error) {
+	var plan PromotionPlan
+
+	sourceApplied, err := source.GetApplied(ctx)
+	if err != nil {
+		return plan, fmt.Errorf("failed to read applied migrations of the source: %w", err)
+	}
+	targetApplied, err := target.GetApplied(ctx)
+	if err != nil {
+		return plan, fmt.Errorf("failed to read applied migrations of the target: %w", err)
+	}
+
+	for version, info := range sourceApplied {
+		if _, exists := targetApplied[version]; !exists {
+			plan.Missing = append(plan.Missing, info)
+		}
+	}
+	for version, info := range targetApplied {
+		if _, exists := sourceApplied[version]; !exists {
+			plan.Extra = append(plan.Extra, info)
+		}
+	}
+
+	sort.Slice(plan.Missing, func(i, j int) bool { return plan.Missing[i].Version < plan.Missing[j].Version })
+	sort.Slice(plan.Extra, func(i, j int) bool { return plan.Extra[i].Version < plan.Extra[j].Version })
+	return plan, nil
+}
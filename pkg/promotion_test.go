@@ -0,0 +1,43 @@
+package omg_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/demetere/omg/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanPromotion(t *testing.T) {
+	ctx := context.Background()
+
+	record := func(tracker *omg.MemoryTracker, versions ...string) {
+		for _, version := range versions {
+			run := omg.MigrationRun{Version: version, Name: "migration_" + version, Status: omg.RunStatusApplied}
+			require.NoError(t, tracker.RecordRunWithOptions(ctx, run, omg.RemoveOptions{}))
+		}
+	}
+
+	staging := omg.NewMemoryTracker()
+	prod := omg.NewMemoryTracker()
+	record(staging, "003", "001", "002", "004")
+	record(prod, "001", "005")
+
+	plan, err := omg.PlanPromotion(ctx, staging, prod)
+	require.NoError(t, err)
+	require.Len(t, plan.Missing, 3)
+	assert.Equal(t, "002", plan.Missing[0].Version)
+	assert.Equal(t, "migration_002", plan.Missing[0].Name)
+	assert.Equal(t, "004", plan.UpTo())
+	require.Len(t, plan.Extra, 1)
+	assert.Equal(t, "005", plan.Extra[0].Version)
+	assert.False(t, plan.InSync())
+
+	record(prod, "002", "003", "004")
+	record(staging, "005")
+	plan, err = omg.PlanPromotion(ctx, staging, prod)
+	require.NoError(t, err)
+	assert.True(t, plan.InSync())
+	assert.Empty(t, plan.UpTo())
+}
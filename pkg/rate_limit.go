@@ -0,0 +1,166 @@
+package omg
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// writePacer paces tuple writes using the rate-limit headers of earlier responses
+// OpenFGA and gateways in front of it announce their quota with X-RateLimit-Remaining /
+// X-RateLimit-Reset (or the RateLimit-* draft headers) and Retry-After on 429s. Writes
+// are spread evenly over what is left of the window and held back entirely once the
+// quota is used up, instead of running into 429s. Each write reserves its own slot, so
+// concurrent writers are spaced out rather than released together
+type writePacer struct {
+	base http.RoundTripper
+
+	mu       sync.Mutex
+	next     time.Time     // Earliest time the next write may be sent
+	interval time.Duration // Spacing between writes from the last headers with a quota; 0 for none
+}
+
+// newWritePacer wraps base (http.DefaultTransport when nil)
+func newWritePacer(base http.RoundTripper) *writePacer {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &writePacer{base: base}
+}
+
+// RoundTrip implements http.RoundTripper
+func (p *writePacer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if isTupleWrite(req) {
+		if err := p.wait(req); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := p.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	p.observe(resp, time.Now())
+	return resp, nil
+}
+
+// wait reserves the next write slot and blocks until it comes or the request is cancelled
+// Long pauses are reported as warning events to the migration run, if any
+func (p *writePacer) wait(req *http.Request) error {
+	p.mu.Lock()
+	now := time.Now()
+	slot := p.next
+	if slot.Before(now) {
+		slot = now
+	}
+	p.next = slot.Add(p.interval)
+	p.mu.Unlock()
+
+	delay := slot.Sub(now)
+	if delay <= 0 {
+		return nil
+	}
+	if delay >= time.Second {
+		emitEvent(req.Context(), MigrationEvent{
+			Type:    EventWarning,
+			Message: fmt.Sprintf("rate limited: pausing writes for %s", delay.Round(time.Second)),
+		})
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-req.Context().Done():
+		return req.Context().Err()
+	}
+}
+
+// observe updates the pacing from a response's rate-limit headers
+func (p *writePacer) observe(resp *http.Response, now time.Time) {
+	next, interval, ok := nextAllowedWrite(resp.Header, resp.StatusCode, now)
+	if !ok {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if next.After(p.next) {
+		p.next = next
+	}
+	if interval > 0 {
+		p.interval = interval
+	}
+}
+
+// nextAllowedWrite works out from rate-limit headers when the next write may be sent, and
+// how far apart writes should be (0 when the headers do not tell, as after a Retry-After or
+// once the quota is used up). Returns false when there is no usable rate-limit information
+func nextAllowedWrite(header http.Header, status int, now time.Time) (time.Time, time.Duration, bool) {
+	if status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable {
+		if retryAfter, ok := parseRetryAfter(header.Get("Retry-After"), now); ok {
+			return now.Add(retryAfter), 0, true
+		}
+	}
+
+	remaining, ok := parseHeaderInt(header, "X-RateLimit-Remaining", "RateLimit-Remaining")
+	if !ok {
+		return time.Time{}, 0, false
+	}
+	reset, ok := parseHeaderInt(header, "X-RateLimit-Reset", "RateLimit-Reset")
+	if !ok {
+		return time.Time{}, 0, false
+	}
+
+	// Reset is either seconds until the window resets or a Unix timestamp
+	untilReset := time.Duration(reset) * time.Second
+	if reset > 1_000_000_000 {
+		untilReset = time.Unix(reset, 0).Sub(now)
+	}
+	if untilReset <= 0 {
+		return time.Time{}, 0, false
+	}
+
+	if remaining <= 0 {
+		return now.Add(untilReset), 0, true
+	}
+	interval := untilReset / time.Duration(remaining)
+	return now.Add(interval), interval, true
+}
+
+// parseRetryAfter parses a Retry-After value in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return date.Sub(now), true
+	}
+	return 0, false
+}
+
+// parseHeaderInt returns the first of names that holds an integer
+func parseHeaderInt(header http.Header, names ...string) (int64, bool) {
+	for _, name := range names {
+		if value := header.Get(name); value != "" {
+			parsed, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+			if err == nil {
+				return parsed, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// isTupleWrite reports whether req writes or deletes tuples
+func isTupleWrite(req *http.Request) bool {
+	return req.Method == http.MethodPost && strings.HasSuffix(req.URL.Path, "/write")
+}
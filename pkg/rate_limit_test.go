@@ -0,0 +1,152 @@
+package omg_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/demetere/omg/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_PacesWritesByRateLimitHeaders(t *testing.T) {
+	var writes atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writes.Add(1)
+		// Quota used up; the window resets in a second
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", "1")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	newClient := func(disablePacing bool) *omg.Client {
+		client, err := omg.NewClient(omg.Config{
+			ApiURL:             server.URL,
+			StoreID:            "01HVMMBCMGZNT3SED4Z17ECXCA",
+			AuthMethod:         "none",
+			DisableWritePacing: disablePacing,
+		})
+		require.NoError(t, err)
+		return client
+	}
+
+	ctx := context.Background()
+	tuple := omg.Tuple{User: "user:anne", Relation: "viewer", Object: "document:readme"}
+
+	client := newClient(false)
+	require.NoError(t, client.WriteTuple(ctx, tuple))
+	start := time.Now()
+	require.NoError(t, client.WriteTuple(ctx, tuple))
+	assert.GreaterOrEqual(t, time.Since(start), 900*time.Millisecond, "second write waits for the window to reset")
+	assert.Equal(t, int32(2), writes.Load())
+
+	unpaced := newClient(true)
+	require.NoError(t, unpaced.WriteTuple(ctx, tuple))
+	start = time.Now()
+	require.NoError(t, unpaced.WriteTuple(ctx, tuple))
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+}
+
+func TestClient_SpacesConcurrentWrites(t *testing.T) {
+	var mu sync.Mutex
+	var arrivals []time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		arrivals = append(arrivals, time.Now())
+		mu.Unlock()
+		// Ten writes left in a one second window: one every 100ms
+		w.Header().Set("X-RateLimit-Remaining", "10")
+		w.Header().Set("X-RateLimit-Reset", "1")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	client, err := omg.NewClient(omg.Config{ApiURL: server.URL, StoreID: "01HVMMBCMGZNT3SED4Z17ECXCA", AuthMethod: "none"})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	tuple := omg.Tuple{User: "user:anne", Relation: "viewer", Object: "document:readme"}
+	require.NoError(t, client.WriteTuple(ctx, tuple))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, client.WriteTuple(ctx, tuple))
+		}()
+	}
+	wg.Wait()
+
+	require.Len(t, arrivals, 5)
+	sort.Slice(arrivals, func(i, j int) bool { return arrivals[i].Before(arrivals[j]) })
+	for i := 1; i < len(arrivals); i++ {
+		assert.GreaterOrEqual(t, arrivals[i].Sub(arrivals[i-1]), 80*time.Millisecond, "write %d is spaced from the one before", i)
+	}
+}
+
+func TestClient_ReportsRateLimitPauseAsWarning(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", "2")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	client, err := omg.NewClient(omg.Config{ApiURL: server.URL, StoreID: "01HVMMBCMGZNT3SED4Z17ECXCA", AuthMethod: "none"})
+	require.NoError(t, err)
+
+	tuple := omg.Tuple{User: "user:anne", Relation: "viewer", Object: "document:readme"}
+	var warnings []string
+	_, err = omg.Run(context.Background(), client, omg.MigrateOptions{
+		Tracker: omg.NewMemoryTracker(),
+		Migrations: []omg.Migration{{
+			Version: "001",
+			Name:    "write_twice",
+			Up: func(ctx context.Context, client *omg.Client) error {
+				if err := client.WriteTuple(ctx, tuple); err != nil {
+					return err
+				}
+				return client.WriteTuple(ctx, tuple)
+			},
+		}},
+		OnEvent: func(e omg.MigrationEvent) {
+			if e.Type == omg.EventWarning {
+				warnings = append(warnings, e.Message)
+			}
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"rate limited: pausing writes for 2s"}, warnings)
+}
+
+func TestClient_PacedClientKeepsTokenAuth(t *testing.T) {
+	var authorization atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization.Store(r.Header.Get("Authorization"))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	client, err := omg.NewClient(omg.Config{
+		ApiURL:     server.URL,
+		StoreID:    "01HVMMBCMGZNT3SED4Z17ECXCA",
+		AuthMethod: "token",
+		APIToken:   "secret",
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, client.WriteTuple(context.Background(), omg.Tuple{User: "user:anne", Relation: "viewer", Object: "document:readme"}))
+	assert.Equal(t, "Bearer secret", authorization.Load())
+}
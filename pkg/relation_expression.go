@@ -0,0 +1,262 @@
+package omg
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	openfgaSdk "github.com/openfga/go-sdk"
+)
+
+// wildcardPattern matches a public access restriction such as user:*
+var wildcardPattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*:\*$`)
+
+// arrowSpacing matches spaces around the arrow of "parent -> owner"
+var arrowSpacing = regexp.MustCompile(`\s*->\s*`)
+
+// expressionParser parses a relation definition with precedence, from loosest to tightest:
+// or, and, but not. Parentheses group, so "(owner or editor) and approved" is an
+// intersection whose first operand is a union
+type expressionParser struct {
+	def          string
+	tokens       []string
+	pos          int
+	restrictions []openfgaSdk.RelationReference // Directly related types of the [...] branch
+	direct       bool                           // Whether a [...] branch was parsed
+}
+
+// tokenizeDefinition splits a definition into words, parentheses and bracketed
+// type restrictions, which stay whole: "[user, team#member]"
+func tokenizeDefinition(def string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(def); {
+		switch c := def[i]; {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, string(c))
+			i++
+		case c == '[':
+			end := strings.IndexByte(def[i:], ']')
+			if end == -1 {
+				return nil, fmt.Errorf("unclosed '[' in relation definition: %s", def)
+			}
+			tokens = append(tokens, def[i:i+end+1])
+			i += end + 1
+		default:
+			end := strings.IndexAny(def[i:], " \t()[")
+			if end == -1 {
+				end = len(def) - i
+			}
+			tokens = append(tokens, def[i:i+end])
+			i += end
+		}
+	}
+	return tokens, nil
+}
+
+// parseExpression parses a whole definition, returning its userset and the directly
+// related types of its [...] branch, wherever that branch sits in the expression
+func parseExpression(def string) (openfgaSdk.Userset, []openfgaSdk.RelationReference, error) {
+	tokens, err := tokenizeDefinition(arrowSpacing.ReplaceAllString(def, "->"))
+	if err != nil {
+		return openfgaSdk.Userset{}, nil, err
+	}
+	if len(tokens) == 0 {
+		return openfgaSdk.Userset{}, nil, fmt.Errorf("unable to parse relation definition: %s", def)
+	}
+
+	p := &expressionParser{def: def, tokens: tokens}
+	userset, err := p.parseUnion()
+	if err != nil {
+		return userset, nil, err
+	}
+	if token, ok := p.peek(); ok {
+		if token == ")" {
+			return userset, nil, fmt.Errorf("unbalanced parentheses in relation definition: %s", def)
+		}
+		return userset, nil, fmt.Errorf("unable to parse relation definition: %s (unexpected '%s')", def, token)
+	}
+	return userset, p.restrictions, nil
+}
+
+// peek returns the next token without consuming it
+func (p *expressionParser) peek() (string, bool) {
+	if p.pos >= len(p.tokens) {
+		return "", false
+	}
+	return p.tokens[p.pos], true
+}
+
+// accept consumes the next token if it is the given word
+func (p *expressionParser) accept(word string) bool {
+	if token, ok := p.peek(); ok && token == word {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// parseUnion parses operands joined by "or"
+func (p *expressionParser) parseUnion() (openfgaSdk.Userset, error) {
+	return p.parseOperands("or", p.parseIntersection, func(children []openfgaSdk.Userset) openfgaSdk.Userset {
+		return openfgaSdk.Userset{Union: &openfgaSdk.Usersets{Child: children}}
+	})
+}
+
+// parseIntersection parses operands joined by "and"
+func (p *expressionParser) parseIntersection() (openfgaSdk.Userset, error) {
+	return p.parseOperands("and", p.parseDifference, func(children []openfgaSdk.Userset) openfgaSdk.Userset {
+		return openfgaSdk.Userset{Intersection: &openfgaSdk.Usersets{Child: children}}
+	})
+}
+
+// parseOperands parses one or more operands joined by an operator word
+func (p *expressionParser) parseOperands(operator string, operand func() (openfgaSdk.Userset, error), combine func([]openfgaSdk.Userset) openfgaSdk.Userset) (openfgaSdk.Userset, error) {
+	first, err := operand()
+	if err != nil {
+		return first, err
+	}
+	children := []openfgaSdk.Userset{first}
+	for p.accept(operator) {
+		child, err := operand()
+		if err != nil {
+			return child, err
+		}
+		children = append(children, child)
+	}
+	if len(children) == 1 {
+		return first, nil
+	}
+	return combine(children), nil
+}
+
+// parseDifference parses "base but not subtract"; chaining needs parentheses
+func (p *expressionParser) parseDifference() (openfgaSdk.Userset, error) {
+	base, err := p.parsePrimary()
+	if err != nil {
+		return base, err
+	}
+	if !p.acceptButNot() {
+		return base, nil
+	}
+
+	subtract, err := p.parsePrimary()
+	if err != nil {
+		return subtract, err
+	}
+	if p.acceptButNot() {
+		return base, fmt.Errorf("invalid 'but not' syntax: %s (group with parentheses)", p.def)
+	}
+	return openfgaSdk.Userset{Difference: &openfgaSdk.Difference{Base: base, Subtract: subtract}}, nil
+}
+
+// acceptButNot consumes "but not"
+func (p *expressionParser) acceptButNot() bool {
+	if p.pos+1 < len(p.tokens) && p.tokens[p.pos] == "but" && p.tokens[p.pos+1] == "not" {
+		p.pos += 2
+		return true
+	}
+	return false
+}
+
+// parsePrimary parses a parenthesized expression, type restrictions, a tuple-to-userset
+// or a computed relation
+func (p *expressionParser) parsePrimary() (openfgaSdk.Userset, error) {
+	token, ok := p.peek()
+	if !ok {
+		return openfgaSdk.Userset{}, fmt.Errorf("unable to parse relation definition: %s (unexpected end)", p.def)
+	}
+	p.pos++
+
+	switch {
+	case token == "(":
+		userset, err := p.parseUnion()
+		if err != nil {
+			return userset, err
+		}
+		if !p.accept(")") {
+			return userset, fmt.Errorf("unbalanced parentheses in relation definition: %s", p.def)
+		}
+		return userset, nil
+
+	case strings.HasPrefix(token, "["):
+		// A relation has one list of directly related types, recorded in its metadata
+		if p.direct {
+			return openfgaSdk.Userset{}, fmt.Errorf("relation definition has more than one list of directly related types: %s", p.def)
+		}
+		restrictions, err := parseDirectRestrictions(token)
+		if err != nil {
+			return openfgaSdk.Userset{}, err
+		}
+		p.restrictions, p.direct = restrictions, true
+		thisMap := make(map[string]interface{})
+		return openfgaSdk.Userset{This: &thisMap}, nil
+
+	case strings.Contains(token, "->"):
+		// Arrow syntax: parent->owner
+		parts := strings.Split(token, "->")
+		if len(parts) != 2 || !relationNamePattern.MatchString(parts[0]) || !relationNamePattern.MatchString(parts[1]) {
+			return openfgaSdk.Userset{}, fmt.Errorf("invalid tuple-to-userset format: %s", token)
+		}
+		return tupleToUserset(parts[0], parts[1]), nil
+
+	case relationNamePattern.MatchString(token) && !isExpressionKeyword(token):
+		// From syntax: owner from team
+		if p.accept("from") {
+			tupleset, ok := p.peek()
+			if !ok || !relationNamePattern.MatchString(tupleset) || isExpressionKeyword(tupleset) {
+				return openfgaSdk.Userset{}, fmt.Errorf("invalid 'from' syntax: %s", p.def)
+			}
+			p.pos++
+			return tupleToUserset(tupleset, token), nil
+		}
+		return openfgaSdk.Userset{ComputedUserset: &openfgaSdk.ObjectRelation{Relation: openfgaSdk.PtrString(token)}}, nil
+	}
+
+	return openfgaSdk.Userset{}, fmt.Errorf("unable to parse relation definition: %s (unexpected '%s')", p.def, token)
+}
+
+// isExpressionKeyword reports whether a word is an operator rather than a relation name
+func isExpressionKeyword(word string) bool {
+	switch word {
+	case "or", "and", "but", "not", "from":
+		return true
+	}
+	return false
+}
+
+// parseDirectRestrictions parses a bracketed list such as [user, user:*, group#member, user with cond]
+func parseDirectRestrictions(token string) ([]openfgaSdk.RelationReference, error) {
+	typesStr := strings.TrimPrefix(strings.TrimSuffix(token, "]"), "[")
+	for _, t := range strings.Split(typesStr, ",") {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			continue
+		}
+
+		// Validate type#relation and type with condition formats
+		restriction, condition, hasCondition := strings.Cut(t, " with ")
+		if hasCondition && strings.TrimSpace(condition) == "" {
+			return nil, fmt.Errorf("missing condition name: %s", t)
+		}
+		if strings.Count(restriction, "#") > 1 {
+			return nil, fmt.Errorf("invalid type#relation format: %s", t)
+		}
+		if strings.Contains(restriction, ":") && !wildcardPattern.MatchString(strings.TrimSpace(restriction)) {
+			return nil, fmt.Errorf("invalid wildcard format: %s (expected type:*)", t)
+		}
+	}
+
+	return extractDirectTypeRestrictions(token), nil
+}
+
+// tupleToUserset builds the userset for "computed from tupleset"
+func tupleToUserset(tupleset, computed string) openfgaSdk.Userset {
+	return openfgaSdk.Userset{
+		TupleToUserset: &openfgaSdk.TupleToUserset{
+			Tupleset:        openfgaSdk.ObjectRelation{Relation: openfgaSdk.PtrString(tupleset)},
+			ComputedUserset: openfgaSdk.ObjectRelation{Relation: openfgaSdk.PtrString(computed)},
+		},
+	}
+}
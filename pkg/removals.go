@@ -0,0 +1,145 @@
+package omg
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Removal is a type, or a relation of a type, that a migration removes along with its tuples
+type Removal struct {
+	Type     string
+	Relation string // Empty when the whole type is removed
+}
+
+// String formats the removal as type or type#relation
+func (r Removal) String() string {
+	if r.Relation == "" {
+		return r.Type
+	}
+	return r.Type + "#" + r.Relation
+}
+
+// removalCalls maps helpers that remove a type or relation to the index of their type argument
+// The relation, where there is one, is the argument after it
+var removalCalls = map[string]int{
+	"RemoveTypeFromModel":            2,
+	"RemoveTypeFromModelIfExists":    2,
+	"RemoveRelationFromType":         2,
+	"RemoveRelationFromTypeIfExists": 2,
+	"DeleteRelation":                 2,
+	"BackupForRemoval":               3,
+	"BackupForRemovalIfMissing":      3,
+}
+
+// FindRemovals lists the types and relations a migration file's up or down function removes
+// It recognizes the omg helpers generated migrations call with literal type and relation
+// names; removals computed at runtime are not found. A removed type covers its relations
+// Declarative migrations list the removals of their remove and backup operations
+func FindRemovals(path string, direction Direction) ([]Removal, error) {
+	if IsDeclarativeMigration(path) {
+		return declarativeRemovals(path, direction)
+	}
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, nil, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse migration: %w", err)
+	}
+
+	found := make(map[Removal]bool)
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv != nil || fn.Body == nil || !isDirectionFunc(fn.Name.Name, direction) {
+			continue
+		}
+
+		ast.Inspect(fn.Body, func(node ast.Node) bool {
+			call, ok := node.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			if removal, ok := removalFromCall(call); ok {
+				found[removal] = true
+			}
+			return true
+		})
+	}
+
+	return sortedRemovals(found), nil
+}
+
+// sortedRemovals lists found removals in order, leaving out relations of removed types
+func sortedRemovals(found map[Removal]bool) []Removal {
+	var removals []Removal
+	for removal := range found {
+		if removal.Relation != "" && found[Removal{Type: removal.Type}] {
+			continue
+		}
+		removals = append(removals, removal)
+	}
+	sort.Slice(removals, func(i, j int) bool {
+		return removals[i].String() < removals[j].String()
+	})
+	return removals
+}
+
+// isDirectionFunc reports whether name is a migration's up or down function:
+// up/down in 'go run' migrations, up<version>/down<version> in registered ones
+func isDirectionFunc(name string, direction Direction) bool {
+	rest, ok := strings.CutPrefix(name, string(direction))
+	if !ok {
+		return false
+	}
+	for _, r := range rest {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// removalFromCall returns what a call to one of removalCalls removes
+func removalFromCall(call *ast.CallExpr) (Removal, bool) {
+	var name string
+	switch fn := call.Fun.(type) {
+	case *ast.SelectorExpr:
+		name = fn.Sel.Name
+	case *ast.Ident:
+		name = fn.Name
+	}
+
+	index, ok := removalCalls[name]
+	if !ok || len(call.Args) <= index {
+		return Removal{}, false
+	}
+
+	removal := Removal{Type: stringLiteral(call.Args[index])}
+	if removal.Type == "" {
+		return Removal{}, false
+	}
+	if len(call.Args) > index+1 {
+		removal.Relation = stringLiteral(call.Args[index+1])
+		if removal.Relation == "" {
+			return Removal{}, false
+		}
+	}
+	return removal, true
+}
+
+// stringLiteral returns the value of a string literal expression, or "" for anything else
+func stringLiteral(expr ast.Expr) string {
+	lit, ok := expr.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return ""
+	}
+	value, err := strconv.Unquote(lit.Value)
+	if err != nil {
+		return ""
+	}
+	return value
+}
@@ -0,0 +1,67 @@
+package omg_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/demetere/omg/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const removalsMigration = `package migrations
+
+import (
+	"context"
+
+	"github.com/demetere/omg/pkg"
+)
+
+func up20240101120000(ctx context.Context, client *omg.Client) error {
+	if err := omg.BackupForRemoval(ctx, client, ".omg/backups", "document", "reader"); err != nil {
+		return err
+	}
+	if err := omg.RemoveRelationFromType(ctx, client, "document", "reader"); err != nil {
+		return err
+	}
+	if err := omg.RemoveRelationFromType(ctx, client, "team", "member"); err != nil {
+		return err
+	}
+	return omg.RemoveTypeFromModel(ctx, client, "team")
+}
+
+func down20240101120000(ctx context.Context, client *omg.Client) error {
+	relation := "editor"
+	if err := omg.DeleteRelation(ctx, client, "folder", relation); err != nil {
+		return err
+	}
+	return omg.AddTypeToModel(ctx, client, "team", nil)
+}
+`
+
+func TestFindRemovals(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "20240101120000_remove_team.go")
+	require.NoError(t, os.WriteFile(path, []byte(removalsMigration), 0644))
+
+	up, err := omg.FindRemovals(path, omg.DirectionUp)
+	require.NoError(t, err)
+	assert.Equal(t, []omg.Removal{
+		{Type: "document", Relation: "reader"},
+		{Type: "team"},
+	}, up)
+	assert.Equal(t, "document#reader", up[0].String())
+
+	// Names that are only known at runtime are not reported
+	down, err := omg.FindRemovals(path, omg.DirectionDown)
+	require.NoError(t, err)
+	assert.Empty(t, down)
+}
+
+func TestFindRemovals_InvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "broken.go")
+	require.NoError(t, os.WriteFile(path, []byte("package"), 0644))
+
+	_, err := omg.FindRemovals(path, omg.DirectionUp)
+	assert.Error(t, err)
+}
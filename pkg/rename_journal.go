@@ -0,0 +1,532 @@
+package omg
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// renameSpotChecks is how many renamed tuples a verify-and-swap rename reads back one by
+// one before deleting the originals
+const renameSpotChecks = 100
+
+// RenamePhase is how far a verify-and-swap rename has got
+type RenamePhase string
+
+const (
+	RenamePhaseWrite      RenamePhase = "write"  // Writing the replacements; the originals are untouched
+	RenamePhaseVerify     RenamePhase = "verify" // Checking the replacements
+	RenamePhaseDelete     RenamePhase = "delete" // Verified; deleting the originals
+	RenamePhaseDone       RenamePhase = "done"
+	RenamePhaseRolledBack RenamePhase = "rolled_back"
+)
+
+// RenameJournalEntry records that a rename entered a phase
+type RenameJournalEntry struct {
+	Operation  string // e.g. "rename_type team organization"
+	Phase      RenamePhase
+	Tuples     int // Tuples renamed; 0 in the write phase
+	RecordedAt time.Time
+}
+
+// RenameJournal records the phases of verify-and-swap renames, so an interrupted one can
+// be finished or rolled back with FinishRename and RollBackRename ('omg repair-rename')
+// *Tracker (PostgreSQL) and MemoryTracker implement it
+type RenameJournal interface {
+	// RecordRenamePhase adds an entry to the journal
+	RecordRenamePhase(ctx context.Context, entry RenameJournalEntry) error
+
+	// PendingRenames returns the latest entry of every rename that is neither done nor
+	// rolled back, oldest first
+	PendingRenames(ctx context.Context) ([]RenameJournalEntry, error)
+
+	// RecordRenamedTuples adds replacements a rename has written to the journal. They
+	// are dropped once the rename is done or rolled back
+	RecordRenamedTuples(ctx context.Context, operation string, tuples []Tuple) error
+
+	// RenamedTuples returns the replacements journaled for an unfinished rename
+	RenamedTuples(ctx context.Context, operation string) ([]Tuple, error)
+}
+
+// verifiedRenamesKey and renameJournalKey are the context keys of WithVerifiedRenames
+// and WithRenameJournal
+type (
+	verifiedRenamesKey struct{}
+	renameJournalKey   struct{}
+)
+
+// WithVerifiedRenames returns a context whose renames run in three phases, as with
+// WriteOptions.VerifyAndSwap. Setting OMG_VERIFY_RENAMES=true does the same, e.g. for
+// migration programs run by 'omg up -verify-renames'
+func WithVerifiedRenames(ctx context.Context) context.Context {
+	return context.WithValue(ctx, verifiedRenamesKey{}, true)
+}
+
+// WithRenameJournal returns a context whose verify-and-swap renames record their phases
+// in journal. Run and Migrator use their tracker when it is a RenameJournal; without one,
+// renames fall back to the PostgreSQL tracker at OMG_TRACKER_DATABASE_URL, if set
+func WithRenameJournal(ctx context.Context, journal RenameJournal) context.Context {
+	return context.WithValue(ctx, renameJournalKey{}, journal)
+}
+
+// verifiedRenames reports whether renames should verify and swap
+func verifiedRenames(ctx context.Context, opts WriteOptions) bool {
+	if opts.VerifyAndSwap {
+		return true
+	}
+	if verified, ok := ctx.Value(verifiedRenamesKey{}).(bool); ok {
+		return verified
+	}
+	verified, _ := strconv.ParseBool(os.Getenv("OMG_VERIFY_RENAMES"))
+	return verified
+}
+
+// renameJournalFor returns the journal of the context, or opens the tracker at
+// OMG_TRACKER_DATABASE_URL; nil when there is neither. Call close when done with it
+func renameJournalFor(ctx context.Context, client *Client) (journal RenameJournal, close func(), err error) {
+	if journal, ok := ctx.Value(renameJournalKey{}).(RenameJournal); ok {
+		return journal, func() {}, nil
+	}
+	dbURL := os.Getenv("OMG_TRACKER_DATABASE_URL")
+	if dbURL == "" {
+		return nil, func() {}, nil
+	}
+
+	db, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open rename journal: %w", err)
+	}
+	tracker, err := NewTrackerForStore(db, client.GetStoreID())
+	if err != nil {
+		db.Close()
+		return nil, nil, fmt.Errorf("failed to open rename journal: %w", err)
+	}
+	return tracker, func() { db.Close() }, nil
+}
+
+// swapTuples renames in three phases, recording each in the rename journal: it writes
+// every replacement, verifies them, and only then deletes the originals. A crash leaves
+// a journal entry saying which phase to finish or roll back. Returns how many tuples
+// were renamed
+func swapTuples(ctx context.Context, client *Client, operation string, opts WriteOptions) (int, error) {
+	rename, err := parseRenameOperation(operation)
+	if err != nil {
+		return 0, err
+	}
+	journal, closeJournal, err := renameJournalFor(ctx, client)
+	if err != nil {
+		return 0, err
+	}
+	defer closeJournal()
+	if journal == nil {
+		fmt.Println("No rename journal: an interrupted rename cannot be repaired with 'omg repair-rename'")
+	}
+	return swapFrom(ctx, client, journal, operation, rename, opts)
+}
+
+// swapFrom runs every phase of a verify-and-swap rename, from the write phase on
+func swapFrom(ctx context.Context, client *Client, journal RenameJournal, operation string, rename renameOperation, opts WriteOptions) (int, error) {
+	record := func(phase RenamePhase, tuples int) error {
+		if journal == nil {
+			return nil
+		}
+		err := journal.RecordRenamePhase(ctx, RenameJournalEntry{Operation: operation, Phase: phase, Tuples: tuples})
+		if err != nil {
+			return fmt.Errorf("failed to journal %s phase of %s: %w", phase, operation, err)
+		}
+		return nil
+	}
+
+	if err := record(RenamePhaseWrite, 0); err != nil {
+		return 0, err
+	}
+	// Replacements that already exist, whether written before a crash or before the
+	// rename, stay put; only those written now are journaled as the rename's own
+	var written []Tuple
+	renamed, err := forEachRenameChunk(ctx, client, rename, "write", func(ctx context.Context, chunk []Tuple) error {
+		replacements := make([]Tuple, len(chunk))
+		for i, t := range chunk {
+			replacements[i] = rename.replace(t)
+		}
+		chunkWritten, err := writeReplacements(ctx, client, journal, operation, replacements, opts)
+		written = append(written, chunkWritten...)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to write new tuples: %w", err)
+	}
+	if renamed == 0 {
+		return 0, record(RenamePhaseDone, 0)
+	}
+
+	if err := record(RenamePhaseVerify, renamed); err != nil {
+		return 0, err
+	}
+	if journal != nil {
+		// Includes the replacements of earlier, interrupted runs
+		if written, err = journal.RenamedTuples(ctx, operation); err != nil {
+			return 0, err
+		}
+	}
+	if err := verifySwap(ctx, client, rename, renamed, written); err != nil {
+		return 0, fmt.Errorf("rename %s failed verification, the originals are untouched: %w", operation, err)
+	}
+
+	if err := record(RenamePhaseDelete, renamed); err != nil {
+		return 0, err
+	}
+	if err := deleteOriginals(ctx, client, rename, opts); err != nil {
+		return 0, err
+	}
+	return renamed, record(RenamePhaseDone, renamed)
+}
+
+// writeReplacements writes a chunk of replacements in batches, skipping those that
+// already exist, and journals the ones it wrote batch by batch, so that a rollback deletes
+// those and nothing that was in the store before. Returns the tuples it wrote
+// A crash between a write and its journal entry leaves that batch in place on rollback
+func writeReplacements(ctx context.Context, client *Client, journal RenameJournal, operation string, replacements []Tuple, opts WriteOptions) ([]Tuple, error) {
+	var mu sync.Mutex
+	var written []Tuple
+	journalWritten := func(ctx context.Context, tuples []Tuple) error {
+		if len(tuples) == 0 {
+			return nil
+		}
+		mu.Lock()
+		written = append(written, tuples...)
+		mu.Unlock()
+		if journal == nil {
+			return nil
+		}
+		if err := journal.RecordRenamedTuples(ctx, operation, tuples); err != nil {
+			return fmt.Errorf("failed to journal renamed tuples: %w", err)
+		}
+		return nil
+	}
+
+	write := func(ctx context.Context, batch []Tuple) error {
+		err := client.WriteTuples(ctx, batch)
+		if err == nil {
+			return journalWritten(ctx, batch)
+		}
+		if !isTupleExistsError(err) {
+			return err
+		}
+
+		// Retried a tuple at a time to tell the rename's writes from existing tuples
+		var batchWritten []Tuple
+		for _, tuple := range batch {
+			if err := client.WriteTuples(ctx, []Tuple{tuple}); err != nil {
+				if isTupleExistsError(err) {
+					continue
+				}
+				if journalErr := journalWritten(ctx, batchWritten); journalErr != nil {
+					return journalErr
+				}
+				return fmt.Errorf("failed on tuple %s %s %s: %w", tuple.User, tuple.Relation, tuple.Object, err)
+			}
+			batchWritten = append(batchWritten, tuple)
+		}
+		return journalWritten(ctx, batchWritten)
+	}
+
+	err := runBatches(ctx, replacements, "write", batchOptions{opts.BatchSize, opts.Workers, opts.Progress}, write)
+	return written, err
+}
+
+// forEachRenameChunk calls fn with the tuples the rename replaces, a chunk of whole pages at
+// a time, reporting progress as operation. Returns how many tuples it passed to fn
+func forEachRenameChunk(ctx context.Context, client *Client, rename renameOperation, operation string, fn func(context.Context, []Tuple) error) (int, error) {
+	progress := startProgress(ctx, operation, 0, nil)
+	batchCtx := withoutProgressReporter(ctx)
+
+	done := 0
+	chunk := make([]Tuple, 0, moveChunkSize)
+	flush := func() error {
+		if len(chunk) == 0 {
+			return nil
+		}
+		if err := fn(batchCtx, chunk); err != nil {
+			return err
+		}
+		done += len(chunk)
+		chunk = chunk[:0]
+		progress.update(done)
+		return nil
+	}
+	err := client.iteratePages(ctx, rename.source, "", func(page []Tuple, next string) error {
+		chunk = append(chunk, rename.sourceTuples(page)...)
+		if len(chunk) < moveChunkSize {
+			return nil
+		}
+		return flush()
+	})
+	if err == nil {
+		err = flush()
+	}
+	if done > 0 || err != nil {
+		progress.finish(done, err)
+	}
+	return done, err
+}
+
+// verifySwap checks the replacements before the originals are deleted: there must still be
+// as many originals as were renamed, every replacement the rename wrote must be stored, and
+// a random sample of the originals must have their replacement
+func verifySwap(ctx context.Context, client *Client, rename renameOperation, renamed int, written []Tuple) error {
+	originals := 0
+	var sample []Tuple
+	err := client.IterateTuples(ctx, rename.source, func(t Tuple) error {
+		if !rename.inSource(t) {
+			return nil
+		}
+		originals++
+		if len(sample) < renameSpotChecks {
+			sample = append(sample, rename.replace(t))
+		} else if i := rand.Intn(originals); i < renameSpotChecks {
+			sample[i] = rename.replace(t)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to count tuples: %w", err)
+	}
+	if originals != renamed {
+		return fmt.Errorf("found %d tuples to rename but renamed %d: tuples changed during the rename", originals, renamed)
+	}
+
+	missing := make(map[Tuple]bool, len(written))
+	for _, t := range written {
+		missing[t] = true
+	}
+	err = client.IterateTuples(ctx, rename.target, func(t Tuple) error {
+		delete(missing, t)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read renamed tuples: %w", err)
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%d of the %d replacements written are not stored", len(missing), len(written))
+	}
+
+	fmt.Printf("Verified %d renamed tuples (%d checked one by one)\n", renamed, len(sample))
+	return VerifyTuples(ctx, client, sample, true, len(sample))
+}
+
+// deleteOriginals deletes the tuples a rename replaced
+func deleteOriginals(ctx context.Context, client *Client, rename renameOperation, opts WriteOptions) error {
+	deleteOpts := DeleteOptions{BatchSize: opts.BatchSize, Workers: opts.Workers, Idempotent: true}
+	_, err := forEachRenameChunk(ctx, client, rename, "delete", func(ctx context.Context, chunk []Tuple) error {
+		return DeleteTuplesBatchWithOptions(ctx, client, chunk, deleteOpts)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete old tuples: %w", err)
+	}
+	return nil
+}
+
+// pendingRename returns the journal entry of an unfinished rename
+func pendingRename(ctx context.Context, journal RenameJournal, operation string) (RenameJournalEntry, error) {
+	pending, err := journal.PendingRenames(ctx)
+	if err != nil {
+		return RenameJournalEntry{}, err
+	}
+	for _, entry := range pending {
+		if entry.Operation == operation {
+			return entry, nil
+		}
+	}
+	return RenameJournalEntry{}, fmt.Errorf("no unfinished rename '%s' in the journal", operation)
+}
+
+// FinishRename completes a verify-and-swap rename that was interrupted: one that stopped
+// before deleting the originals is run again from the write phase, skipping replacements
+// that are already written; one that stopped while deleting deletes the rest
+func FinishRename(ctx context.Context, client *Client, journal RenameJournal, operation string) error {
+	entry, err := pendingRename(ctx, journal, operation)
+	if err != nil {
+		return err
+	}
+	rename, err := parseRenameOperation(operation)
+	if err != nil {
+		return err
+	}
+
+	if entry.Phase != RenamePhaseDelete {
+		fmt.Printf("Finishing %s from the write phase\n", operation)
+		_, err := swapFrom(ctx, client, journal, operation, rename, WriteOptions{})
+		return err
+	}
+
+	fmt.Printf("Finishing %s: deleting the remaining originals\n", operation)
+	if err := deleteOriginals(ctx, client, rename, WriteOptions{}); err != nil {
+		return err
+	}
+	return journal.RecordRenamePhase(ctx, RenameJournalEntry{Operation: operation, Phase: RenamePhaseDone, Tuples: entry.Tuples})
+}
+
+// RollBackRename undoes a verify-and-swap rename that was interrupted before deleting the
+// originals, by deleting the replacements its journal shows it wrote; tuples that already
+// existed are left alone. Once deleting has started, the rename can only be finished
+func RollBackRename(ctx context.Context, client *Client, journal RenameJournal, operation string) error {
+	entry, err := pendingRename(ctx, journal, operation)
+	if err != nil {
+		return err
+	}
+	if entry.Phase == RenamePhaseDelete {
+		return fmt.Errorf("rename '%s' was verified and was deleting the originals: it can only be finished", operation)
+	}
+	written, err := journal.RenamedTuples(ctx, operation)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Rolling back %s: deleting the %d replacements written so far\n", operation, len(written))
+	if err := DeleteTuplesBatchWithOptions(ctx, client, written, DeleteOptions{Idempotent: true}); err != nil {
+		return fmt.Errorf("failed to delete new tuples: %w", err)
+	}
+	return journal.RecordRenamePhase(ctx, RenameJournalEntry{Operation: operation, Phase: RenamePhaseRolledBack})
+}
+
+// RecordRenamePhase implements RenameJournal
+func (t *Tracker) RecordRenamePhase(ctx context.Context, entry RenameJournalEntry) error {
+	query := `INSERT INTO omg_rename_journal (store_id, operation, phase, tuples, recorded_at) VALUES ($1, $2, $3, $4, $5)`
+	if _, err := t.db.ExecContext(ctx, query, t.storeID, entry.Operation, string(entry.Phase), entry.Tuples, time.Now().UTC()); err != nil {
+		return fmt.Errorf("failed to record rename phase: %w", err)
+	}
+	if entry.Phase == RenamePhaseDone || entry.Phase == RenamePhaseRolledBack {
+		query := `DELETE FROM omg_rename_tuples WHERE store_id = $1 AND operation = $2`
+		if _, err := t.db.ExecContext(ctx, query, t.storeID, entry.Operation); err != nil {
+			return fmt.Errorf("failed to clear renamed tuples: %w", err)
+		}
+	}
+	return nil
+}
+
+// RecordRenamedTuples implements RenameJournal
+func (t *Tracker) RecordRenamedTuples(ctx context.Context, operation string, tuples []Tuple) error {
+	tx, err := t.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO omg_rename_tuples (store_id, operation, tuple_user, relation, object) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT DO NOTHING`
+	for _, tuple := range tuples {
+		if _, err := tx.ExecContext(ctx, query, t.storeID, operation, tuple.User, tuple.Relation, tuple.Object); err != nil {
+			return fmt.Errorf("failed to record renamed tuple: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// RenamedTuples implements RenameJournal
+func (t *Tracker) RenamedTuples(ctx context.Context, operation string) ([]Tuple, error) {
+	query := `SELECT tuple_user, relation, object FROM omg_rename_tuples WHERE store_id = $1 AND operation = $2`
+	rows, err := t.db.QueryContext(ctx, query, t.storeID, operation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query renamed tuples: %w", err)
+	}
+	defer rows.Close()
+
+	var tuples []Tuple
+	for rows.Next() {
+		var tuple Tuple
+		if err := rows.Scan(&tuple.User, &tuple.Relation, &tuple.Object); err != nil {
+			return nil, fmt.Errorf("failed to scan renamed tuple: %w", err)
+		}
+		tuples = append(tuples, tuple)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating renamed tuples: %w", err)
+	}
+
+	return tuples, nil
+}
+
+// PendingRenames implements RenameJournal
+func (t *Tracker) PendingRenames(ctx context.Context) ([]RenameJournalEntry, error) {
+	query := `SELECT operation, phase, tuples, recorded_at FROM (
+			SELECT DISTINCT ON (operation) id, operation, phase, tuples, recorded_at
+			FROM omg_rename_journal WHERE store_id = $1 ORDER BY operation, id DESC
+		) latest WHERE phase NOT IN ('done', 'rolled_back') ORDER BY id`
+
+	rows, err := t.db.QueryContext(ctx, query, t.storeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rename journal: %w", err)
+	}
+	defer rows.Close()
+
+	var pending []RenameJournalEntry
+	for rows.Next() {
+		var entry RenameJournalEntry
+		var phase string
+		if err := rows.Scan(&entry.Operation, &phase, &entry.Tuples, &entry.RecordedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan rename journal row: %w", err)
+		}
+		entry.Phase = RenamePhase(phase)
+		pending = append(pending, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rename journal: %w", err)
+	}
+
+	return pending, nil
+}
+
+// RecordRenamePhase implements RenameJournal
+func (t *MemoryTracker) RecordRenamePhase(ctx context.Context, entry RenameJournalEntry) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	entry.RecordedAt = time.Now().UTC()
+	t.renames = append(t.renames, entry)
+	if entry.Phase == RenamePhaseDone || entry.Phase == RenamePhaseRolledBack {
+		delete(t.renamed, entry.Operation)
+	}
+	return nil
+}
+
+// RecordRenamedTuples implements RenameJournal
+func (t *MemoryTracker) RecordRenamedTuples(ctx context.Context, operation string, tuples []Tuple) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.renamed == nil {
+		t.renamed = make(map[string][]Tuple)
+	}
+	t.renamed[operation] = append(t.renamed[operation], tuples...)
+	return nil
+}
+
+// RenamedTuples implements RenameJournal
+func (t *MemoryTracker) RenamedTuples(ctx context.Context, operation string) ([]Tuple, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Tuple(nil), t.renamed[operation]...), nil
+}
+
+// PendingRenames implements RenameJournal
+func (t *MemoryTracker) PendingRenames(ctx context.Context) ([]RenameJournalEntry, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	latest := map[string]int{}
+	for i, entry := range t.renames {
+		latest[entry.Operation] = i
+	}
+	var pending []RenameJournalEntry
+	for i, entry := range t.renames {
+		if latest[entry.Operation] == i && entry.Phase != RenamePhaseDone && entry.Phase != RenamePhaseRolledBack {
+			pending = append(pending, entry)
+		}
+	}
+	return pending, nil
+}
@@ -0,0 +1,223 @@
+package omg_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/demetere/omg/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tupleStore is an OpenFGA API over a map of tuples: reads filter by object (or object
+// type), relation and user, 50 tuples a page; writes are rejected like OpenFGA rejects
+// them. reject and drop fail or silently skip chosen writes and deletes
+type tupleStore struct {
+	mu     sync.Mutex
+	tuples map[omg.Tuple]bool
+	reject func(t omg.Tuple, deleting bool) bool
+	drop   func(t omg.Tuple) bool
+	reads  []omg.Tuple // Filter of each read
+}
+
+func newTupleStore(t *testing.T, tuples []omg.Tuple) (*tupleStore, *omg.Client) {
+	store := &tupleStore{tuples: map[omg.Tuple]bool{}}
+	for _, tuple := range tuples {
+		store.tuples[tuple] = true
+	}
+	server := httptest.NewServer(http.HandlerFunc(store.serve(t)))
+	t.Cleanup(server.Close)
+
+	client, err := omg.NewClient(omg.Config{ApiURL: server.URL, StoreID: testStoreID, AuthMethod: "none", MaxRetries: -1})
+	require.NoError(t, err)
+	return store, client
+}
+
+func (s *tupleStore) serve(t *testing.T) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			TupleKey          omg.Tuple `json:"tuple_key"`
+			ContinuationToken string    `json:"continuation_token"`
+			Writes            struct {
+				TupleKeys []omg.Tuple `json:"tuple_keys"`
+			} `json:"writes"`
+			Deletes struct {
+				TupleKeys []omg.Tuple `json:"tuple_keys"`
+			} `json:"deletes"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Header().Set("Content-Type", "application/json")
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		reject := func(message string, tuple omg.Tuple) {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"code":"write_failed_due_to_invalid_input","message":"%s: user: '%s', relation: '%s', object: '%s'"}`, message, tuple.User, tuple.Relation, tuple.Object)
+		}
+
+		if strings.HasSuffix(r.URL.Path, "/write") {
+			for _, tuple := range body.Writes.TupleKeys {
+				if s.reject != nil && s.reject(tuple, false) {
+					reject("rejected", tuple)
+					return
+				}
+				if s.tuples[tuple] {
+					reject("cannot write a tuple which already exists", tuple)
+					return
+				}
+			}
+			for _, tuple := range body.Deletes.TupleKeys {
+				if s.reject != nil && s.reject(tuple, true) {
+					reject("rejected", tuple)
+					return
+				}
+				if !s.tuples[tuple] {
+					reject("cannot delete a tuple which does not exist", tuple)
+					return
+				}
+			}
+			for _, tuple := range body.Writes.TupleKeys {
+				if s.drop == nil || !s.drop(tuple) {
+					s.tuples[tuple] = true
+				}
+			}
+			for _, tuple := range body.Deletes.TupleKeys {
+				delete(s.tuples, tuple)
+			}
+			w.Write([]byte(`{}`))
+			return
+		}
+
+		// Read: the continuation token is the last key of the previous page
+		s.reads = append(s.reads, body.TupleKey)
+		key := func(t omg.Tuple) string { return t.Object + "#" + t.Relation + "@" + t.User }
+		var matching []omg.Tuple
+		for tuple := range s.tuples {
+			filter := body.TupleKey
+			if filter.Object != "" && tuple.Object != filter.Object &&
+				!(strings.HasSuffix(filter.Object, ":") && strings.HasPrefix(tuple.Object, filter.Object)) {
+				continue
+			}
+			if (filter.Relation != "" && tuple.Relation != filter.Relation) || (filter.User != "" && tuple.User != filter.User) {
+				continue
+			}
+			if key(tuple) > body.ContinuationToken {
+				matching = append(matching, tuple)
+			}
+		}
+		sort.Slice(matching, func(i, j int) bool { return key(matching[i]) < key(matching[j]) })
+
+		next := ""
+		if len(matching) > 50 {
+			matching = matching[:50]
+			next = key(matching[49])
+		}
+		keys := make([]string, len(matching))
+		for i, tuple := range matching {
+			data, _ := json.Marshal(tuple)
+			keys[i] = `{"key":` + string(data) + `}`
+		}
+		fmt.Fprintf(w, `{"tuples":[%s],"continuation_token":%q}`, strings.Join(keys, ","), next)
+	}
+}
+
+// count returns how many stored tuples have the relation
+func (s *tupleStore) count(relation string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for tuple := range s.tuples {
+		if tuple.Relation == relation {
+			n++
+		}
+	}
+	return n
+}
+
+func TestRenameRelation_VerifyAndSwap(t *testing.T) {
+	tuples := make([]omg.Tuple, 150)
+	for i := range tuples {
+		tuples[i] = omg.Tuple{User: fmt.Sprintf("user:%d", i), Relation: "old", Object: fmt.Sprintf("team:%d", i)}
+	}
+	store, client := newTupleStore(t, tuples)
+	journal := omg.NewMemoryTracker()
+	ctx := omg.WithRenameJournal(omg.WithVerifiedRenames(context.Background()), journal)
+	const operation = "rename_relation team old new"
+
+	require.NoError(t, omg.RenameRelation(ctx, client, "team", "old", "new"))
+	assert.Equal(t, 150, store.count("new"))
+	assert.Zero(t, store.count("old"))
+	pending, err := journal.PendingRenames(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+
+	// Interrupted while writing: rolled back, the replacements it wrote are deleted but
+	// not a replacement that was there before the rename
+	require.NoError(t, omg.RenameRelation(ctx, client, "team", "new", "old"))
+	existing := omg.Tuple{User: "user:5", Relation: "new", Object: "team:5"}
+	store.tuples[existing] = true
+	store.reject = func(t omg.Tuple, deleting bool) bool { return !deleting && t.User == "user:99" }
+	err = omg.RenameRelation(ctx, client, "team", "old", "new")
+	require.Error(t, err)
+	pending, err = journal.PendingRenames(ctx)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, operation, pending[0].Operation)
+	assert.Equal(t, omg.RenamePhaseWrite, pending[0].Phase)
+	assert.Equal(t, 150, store.count("old"))
+	assert.Positive(t, store.count("new"))
+
+	store.reject = nil
+	require.NoError(t, omg.RollBackRename(ctx, client, journal, operation))
+	assert.Equal(t, 150, store.count("old"))
+	assert.Equal(t, 1, store.count("new"))
+	assert.True(t, store.tuples[existing])
+	written, err := journal.RenamedTuples(ctx, operation)
+	require.NoError(t, err)
+	assert.Empty(t, written, "a rolled back rename's tuples are dropped from the journal")
+	pending, err = journal.PendingRenames(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+
+	// A replacement that was not stored fails verification; the originals stay
+	store.drop = func(t omg.Tuple) bool { return t.User == "user:7" }
+	err = omg.RenameRelationWithOptions(context.Background(), client, "team", "old", "new", omg.WriteOptions{VerifyAndSwap: true})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed verification")
+	assert.Contains(t, err.Error(), "1 of the 149 replacements written are not stored")
+	assert.Equal(t, 150, store.count("old"))
+	store.drop = nil
+
+	// Interrupted while deleting: can only be finished
+	store.reject = func(t omg.Tuple, deleting bool) bool { return deleting && t.User == "user:99" }
+	err = omg.RenameRelation(ctx, client, "team", "old", "new")
+	require.Error(t, err)
+	pending, err = journal.PendingRenames(ctx)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, omg.RenamePhaseDelete, pending[0].Phase)
+	assert.Equal(t, 150, pending[0].Tuples)
+
+	err = omg.RollBackRename(ctx, client, journal, operation)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "can only be finished")
+
+	store.reject = nil
+	require.NoError(t, omg.FinishRename(ctx, client, journal, operation))
+	assert.Equal(t, 150, store.count("new"))
+	assert.Zero(t, store.count("old"))
+	pending, err = journal.PendingRenames(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+
+	err = omg.FinishRename(ctx, client, journal, operation)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no unfinished rename")
+}
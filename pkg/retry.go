@@ -0,0 +1,188 @@
+package omg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	openfgaSdk "github.com/openfga/go-sdk"
+)
+
+const (
+	// DefaultMaxRetries is how often a failed tuple read, write or delete is retried
+	// unless Config.MaxRetries says otherwise
+	DefaultMaxRetries = 3
+
+	// DefaultBackoffBase is the wait before the first retry unless Config.BackoffBase says otherwise
+	DefaultBackoffBase = 200 * time.Millisecond
+
+	// maxBackoff caps the wait between two retries
+	maxBackoff = 30 * time.Second
+)
+
+// retryPolicy is how a client retries requests that failed transiently
+type retryPolicy struct {
+	maxRetries  int
+	backoffBase time.Duration
+}
+
+// newRetryPolicy applies the defaults to the retry settings of cfg
+func newRetryPolicy(cfg Config) retryPolicy {
+	policy := retryPolicy{maxRetries: cfg.MaxRetries, backoffBase: cfg.BackoffBase}
+	if policy.maxRetries == 0 {
+		policy.maxRetries = DefaultMaxRetries
+	} else if policy.maxRetries < 0 {
+		policy.maxRetries = 0
+	}
+	if policy.backoffBase <= 0 {
+		policy.backoffBase = DefaultBackoffBase
+	}
+	return policy
+}
+
+// backoff returns the wait before retry number attempt (0 for the first): the base doubled
+// for each earlier retry, with jitter so parallel batches do not retry in lockstep
+func (p retryPolicy) backoff(attempt int) time.Duration {
+	wait := p.backoffBase << attempt
+	if wait <= 0 || wait > maxBackoff {
+		wait = maxBackoff
+	}
+	return wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+}
+
+// retries returns the client's retry policy; a client without a pool does not retry
+func (c *Client) retries() retryPolicy {
+	if c.pool == nil {
+		return retryPolicy{}
+	}
+	return c.pool.retries
+}
+
+// retry runs op until it succeeds, fails permanently or runs out of retries
+// 429s, 5xx responses (but 501) and network errors are transient; a 429's Retry-After
+// is waited out when it is longer than the backoff
+func (c *Client) retry(ctx context.Context, op func() error) error {
+	return c.retryOn(ctx, transientError, op)
+}
+
+// retryOn runs op until it succeeds, fails with an error retryable rejects or runs out of retries
+func (c *Client) retryOn(ctx context.Context, retryable func(error) (string, time.Duration, bool), op func() error) error {
+	policy := c.retries()
+
+	for attempt := 0; ; attempt++ {
+		err := op()
+		if err == nil || attempt >= policy.maxRetries || ctx.Err() != nil {
+			return err
+		}
+
+		reason, retryAfter, ok := retryable(err)
+		if !ok {
+			return err
+		}
+
+		wait := policy.backoff(attempt)
+		if retryAfter > wait {
+			wait = retryAfter
+		}
+		fmt.Printf("%s, retrying in %s (%d/%d)\n", reason, wait.Round(time.Millisecond), attempt+1, policy.maxRetries)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+	}
+}
+
+// transientError reports whether a request that failed with err may succeed when retried,
+// why it failed, and how long the server asked to wait
+func transientError(err error) (reason string, retryAfter time.Duration, ok bool) {
+	var rateLimited openfgaSdk.FgaApiRateLimitExceededError
+	if errors.As(err, &rateLimited) {
+		retryAfter, _ := parseRetryAfter(rateLimited.ResponseHeader().Get("Retry-After"), time.Now())
+		return "Rate limited by OpenFGA", retryAfter, true
+	}
+
+	var internal openfgaSdk.FgaApiInternalError
+	if errors.As(err, &internal) {
+		status := internal.ResponseStatusCode()
+		if status == http.StatusNotImplemented {
+			return "", 0, false
+		}
+		return fmt.Sprintf("OpenFGA returned %d %s", status, http.StatusText(status)), 0, true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return fmt.Sprintf("Network error (%v)", netErr), 0, true
+	}
+	return "", 0, false
+}
+
+// unsentError reports whether a write that failed with err certainly was not applied, so
+// it can be sent again as is: OpenFGA rate limited it, or no connection could be made
+// After a 5xx, a timeout or a dropped connection the write may have been committed
+func unsentError(err error) (reason string, retryAfter time.Duration, ok bool) {
+	var rateLimited openfgaSdk.FgaApiRateLimitExceededError
+	if errors.As(err, &rateLimited) {
+		return transientError(err)
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return fmt.Sprintf("Could not connect to OpenFGA (%v)", opErr), 0, true
+	}
+	return "", 0, false
+}
+
+// requestLimiter spaces requests out to at most a number per second
+type requestLimiter struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time // Earliest time the next request may be sent
+}
+
+// newRequestLimiter returns a limiter for perSecond requests a second, or nil for no limit
+func newRequestLimiter(perSecond float64) *requestLimiter {
+	if perSecond <= 0 {
+		return nil
+	}
+	return &requestLimiter{interval: time.Duration(float64(time.Second) / perSecond)}
+}
+
+// wait blocks until the caller may send a request or ctx is cancelled
+func (l *requestLimiter) wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	slot := l.next
+	if slot.Before(now) {
+		slot = now
+	}
+	l.next = slot.Add(l.interval)
+	l.mu.Unlock()
+
+	delay := time.Until(slot)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
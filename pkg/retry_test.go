@@ -0,0 +1,125 @@
+package omg_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/demetere/omg/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingServer answers the first failures requests with status, and the rest with an empty result
+func failingServer(t *testing.T, failures int32, status int) (*httptest.Server, *atomic.Int32) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if requests.Add(1) <= failures {
+			w.WriteHeader(status)
+			w.Write([]byte(`{"code":"internal_error","message":"unavailable"}`))
+			return
+		}
+		w.Write([]byte(`{"tuples":[],"continuation_token":""}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func retryingClient(t *testing.T, url string, maxRetries int) *omg.Client {
+	client, err := omg.NewClient(omg.Config{
+		ApiURL:      url,
+		StoreID:     "01HVMMBCMGZNT3SED4Z17ECXCA",
+		AuthMethod:  "none",
+		MaxRetries:  maxRetries,
+		BackoffBase: time.Millisecond,
+	})
+	require.NoError(t, err)
+	return client
+}
+
+func TestClient_RetriesTransientFailures(t *testing.T) {
+	ctx := context.Background()
+	tuple := omg.Tuple{User: "user:anne", Relation: "viewer", Object: "document:readme"}
+
+	server, requests := failingServer(t, 2, http.StatusServiceUnavailable)
+	_, err := retryingClient(t, server.URL, 0).ReadAllTuples(ctx, omg.ReadTuplesRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), requests.Load())
+
+	server, requests = failingServer(t, 1, http.StatusTooManyRequests)
+	require.NoError(t, retryingClient(t, server.URL, 0).WriteTuple(ctx, tuple))
+	assert.Equal(t, int32(2), requests.Load())
+
+	// Retries used up: the delete is not resent as is after a 500, then retried on its own
+	server, requests = failingServer(t, 5, http.StatusInternalServerError)
+	require.Error(t, retryingClient(t, server.URL, 2).DeleteTuple(ctx, tuple))
+	assert.Equal(t, int32(4), requests.Load())
+
+	// Retries disabled
+	server, requests = failingServer(t, 1, http.StatusServiceUnavailable)
+	require.Error(t, retryingClient(t, server.URL, -1).WriteTuple(ctx, tuple))
+	assert.Equal(t, int32(1), requests.Load())
+
+	// Validation errors are permanent
+	server, requests = failingServer(t, 1, http.StatusBadRequest)
+	require.Error(t, retryingClient(t, server.URL, 0).WriteTuple(ctx, tuple))
+	assert.Equal(t, int32(1), requests.Load())
+}
+
+func TestClient_LimitsRequestRate(t *testing.T) {
+	server, requests := failingServer(t, 0, http.StatusOK)
+	client, err := omg.NewClient(omg.Config{
+		ApiURL:            server.URL,
+		StoreID:           "01HVMMBCMGZNT3SED4Z17ECXCA",
+		AuthMethod:        "none",
+		RequestsPerSecond: 20,
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		_, err := client.Clone().ReadAllTuples(ctx, omg.ReadTuplesRequest{})
+		require.NoError(t, err)
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 190*time.Millisecond, "5 requests at 20 per second take 200ms")
+	assert.Equal(t, int32(5), requests.Load())
+}
+
+func TestClient_WritesTupleByTupleAfterAmbiguousFailure(t *testing.T) {
+	existing := omg.Tuple{User: "user:bob", Relation: "viewer", Object: "document:readme"}
+	store := &tupleStore{tuples: map[omg.Tuple]bool{existing: true}}
+	serve := store.serve(t)
+
+	// The first write is committed, but the response is lost in a 500
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			serve(httptest.NewRecorder(), r)
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"code":"internal_error","message":"unavailable"}`))
+			return
+		}
+		serve(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	tuples := []omg.Tuple{
+		{User: "user:anne", Relation: "viewer", Object: "document:readme"},
+		{User: "user:carl", Relation: "viewer", Object: "document:readme"},
+	}
+	ctx := context.Background()
+	require.NoError(t, retryingClient(t, server.URL, 0).WriteTuples(ctx, tuples))
+	assert.Equal(t, int32(3), requests.Load(), "the batch is written a tuple at a time, not resent")
+	assert.Equal(t, 3, store.count("viewer"))
+
+	// A delete is not resent either; the tuple already gone counts as deleted
+	requests.Store(0)
+	require.NoError(t, retryingClient(t, server.URL, 0).DeleteTuples(ctx, []omg.Tuple{existing}))
+	assert.Equal(t, int32(2), requests.Load())
+	assert.Equal(t, 2, store.count("viewer"))
+}
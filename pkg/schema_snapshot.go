@@ -0,0 +1,74 @@
+package omg
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	openfgaSdk "github.com/openfga/go-sdk"
+)
+
+const (
+	// SchemaSnapshotFile is the model snapshot kept in the migrations directory, in DSL
+	SchemaSnapshotFile = "schema_snapshot.fga"
+
+	// SchemaSnapshotJSONFile is the JSON form of the model snapshot, used instead of the
+	// DSL one when it exists
+	SchemaSnapshotJSONFile = "schema_snapshot.json"
+)
+
+// SchemaSnapshotPath returns the model snapshot of a migrations directory: the JSON one
+// when it exists, the DSL one otherwise
+func SchemaSnapshotPath(dir string) string {
+	jsonPath := filepath.Join(dir, SchemaSnapshotJSONFile)
+	if _, err := os.Stat(jsonPath); err == nil {
+		return jsonPath
+	}
+	return filepath.Join(dir, SchemaSnapshotFile)
+}
+
+// ReadSchemaSnapshot reads the model the migrations of a directory build up to
+// Returns nil without error when the directory has no snapshot
+func ReadSchemaSnapshot(dir string) (*openfgaSdk.AuthorizationModel, error) {
+	path := SchemaSnapshotPath(dir)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema snapshot: %w", err)
+	}
+
+	model, err := ParseModel(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse schema snapshot %s: %w", path, err)
+	}
+	return &model, nil
+}
+
+// WriteSchemaSnapshot records the model the migrations of a directory build up to
+// It is written after each generated migration, so the next generate can diff against it
+// without the live store. The format of an existing snapshot is kept; new ones are DSL
+func WriteSchemaSnapshot(dir string, model openfgaSdk.AuthorizationModel) (string, error) {
+	path := SchemaSnapshotPath(dir)
+
+	var content string
+	var err error
+	if filepath.Base(path) == SchemaSnapshotJSONFile {
+		content, err = FormatModelAsJSON(model)
+	} else {
+		content, err = FormatModelAsDSL(model)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create migrations directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return "", fmt.Errorf("failed to write schema snapshot: %w", err)
+	}
+	return path, nil
+}
@@ -0,0 +1,69 @@
+package omg_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/demetere/omg/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const snapshotModel = `model
+  schema 1.1
+
+type user
+
+type document
+  relations
+    define viewer: [user]
+`
+
+func TestSchemaSnapshot_ReadWrite(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "migrations")
+
+	snapshot, err := omg.ReadSchemaSnapshot(dir)
+	require.NoError(t, err)
+	assert.Nil(t, snapshot)
+
+	model, err := omg.ParseDSLToModel(snapshotModel)
+	require.NoError(t, err)
+
+	path, err := omg.WriteSchemaSnapshot(dir, model)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, omg.SchemaSnapshotFile), path)
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, snapshotModel, string(content))
+
+	snapshot, err = omg.ReadSchemaSnapshot(dir)
+	require.NoError(t, err)
+	require.NotNil(t, snapshot)
+	assert.Equal(t, omg.HashModelState(omg.BuildModelState(model)), omg.HashModelState(omg.BuildModelStateFromAuthorizationModel(*snapshot)))
+}
+
+func TestSchemaSnapshot_KeepsJSONFormat(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, omg.SchemaSnapshotJSONFile), []byte(`{"schema_version": "1.1", "type_definitions": [{"type": "user"}]}`), 0644))
+
+	model, err := omg.ParseDSLToModel(snapshotModel)
+	require.NoError(t, err)
+
+	path, err := omg.WriteSchemaSnapshot(dir, model)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, omg.SchemaSnapshotJSONFile), path)
+	assert.NoFileExists(t, filepath.Join(dir, omg.SchemaSnapshotFile))
+
+	snapshot, err := omg.ReadSchemaSnapshot(dir)
+	require.NoError(t, err)
+	require.NotNil(t, snapshot)
+	assert.Len(t, snapshot.TypeDefinitions, 2)
+}
+
+func TestModelStatus_SnapshotInSync(t *testing.T) {
+	assert.False(t, omg.ModelStatus{LiveHash: "sha256:a"}.SnapshotInSync())
+	assert.True(t, omg.ModelStatus{LiveHash: "sha256:a", SnapshotHash: "sha256:a"}.SnapshotInSync())
+	assert.False(t, omg.ModelStatus{LiveHash: "sha256:a", SnapshotHash: "sha256:b"}.SnapshotInSync())
+}
@@ -0,0 +1,88 @@
+package omg
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	openfgaSdk "github.com/openfga/go-sdk"
+)
+
+const (
+	// DefaultSchemaVersion is the schema version of models that do not declare one
+	DefaultSchemaVersion = "1.1"
+
+	// LatestSchemaVersion is the newest schema version omg knows
+	LatestSchemaVersion = "1.2"
+)
+
+// schemaFeatures is what a schema version supports
+type schemaFeatures struct {
+	typeRestrictions bool // [user, team#member]
+	conditions       bool // condition blocks and [user with cond]
+	modules          bool // fga.mod modular models
+}
+
+// schemaVersions lists the schema versions omg knows
+var schemaVersions = map[string]schemaFeatures{
+	"1.0": {},
+	"1.1": {typeRestrictions: true, conditions: true},
+	"1.2": {typeRestrictions: true, conditions: true, modules: true},
+}
+
+// ValidateSchemaVersion checks that omg can write models with a schema version
+// 1.0 models are read, but OpenFGA no longer accepts them
+func ValidateSchemaVersion(version string) error {
+	if _, known := schemaVersions[version]; !known {
+		return fmt.Errorf("unknown schema version '%s' (supported: %s)", version, strings.Join(writableSchemaVersions(), ", "))
+	}
+	if version == "1.0" {
+		return fmt.Errorf("schema version 1.0 is no longer accepted by OpenFGA (use %s)", DefaultSchemaVersion)
+	}
+	return nil
+}
+
+// ValidateModelSchema checks that a model only uses features of its declared schema version
+func ValidateModelSchema(model openfgaSdk.AuthorizationModel) error {
+	version := model.GetSchemaVersion()
+	features, known := schemaVersions[version]
+	if !known {
+		return fmt.Errorf("unknown schema version '%s' (supported: %s)", version, strings.Join(writableSchemaVersions(), ", "))
+	}
+
+	if len(model.GetConditions()) > 0 && !features.conditions {
+		return fmt.Errorf("conditions need schema %s or later (the model declares %s)", DefaultSchemaVersion, version)
+	}
+	if !features.typeRestrictions {
+		for _, typeDef := range model.TypeDefinitions {
+			if typeDef.Metadata != nil && len(typeDef.Metadata.GetRelations()) > 0 {
+				return fmt.Errorf("type restrictions on '%s' need schema %s or later (the model declares %s)", typeDef.Type, DefaultSchemaVersion, version)
+			}
+		}
+	}
+	return nil
+}
+
+// validateModuleSchema checks that a modular model's schema version supports modules
+func validateModuleSchema(version string) error {
+	features, known := schemaVersions[version]
+	if !known {
+		return fmt.Errorf("unknown schema version '%s' (supported: %s)", version, strings.Join(writableSchemaVersions(), ", "))
+	}
+	if !features.modules {
+		return fmt.Errorf("modular models need schema %s or later (the manifest declares %s)", LatestSchemaVersion, version)
+	}
+	return nil
+}
+
+// writableSchemaVersions returns the schema versions OpenFGA accepts, sorted
+func writableSchemaVersions() []string {
+	var versions []string
+	for version := range schemaVersions {
+		if version != "1.0" {
+			versions = append(versions, version)
+		}
+	}
+	sort.Strings(versions)
+	return versions
+}
@@ -0,0 +1,74 @@
+package omg_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/demetere/omg/pkg"
+	openfgaSdk "github.com/openfga/go-sdk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDSLToModel_DefaultSchemaVersion(t *testing.T) {
+	model, err := omg.ParseDSLToModel("type user\n")
+	require.NoError(t, err)
+	assert.Equal(t, omg.DefaultSchemaVersion, model.SchemaVersion)
+}
+
+func TestParseDSLToModel_SchemaFeatures(t *testing.T) {
+	_, err := omg.ParseDSLToModel("model\n  schema 1.3\ntype user\n")
+	assert.ErrorContains(t, err, "unknown schema version '1.3' (supported: 1.1, 1.2)")
+
+	_, err = omg.ParseDSLToModel("model\n  schema 1.0\ntype user\ncondition small(x: int) {\n  x < 1\n}\n")
+	assert.ErrorContains(t, err, "conditions need schema 1.1 or later")
+
+	_, err = omg.ParseDSLToModel("model\n  schema 1.0\ntype user\ntype document\n  relations\n    define viewer: [user]\n")
+	assert.ErrorContains(t, err, "type restrictions on 'document' need schema 1.1")
+
+	_, err = omg.ParseDSLToModel("model\n  schema 1.0\ntype user\ntype document\n  relations\n    define viewer: owner\n    define owner: viewer\n")
+	assert.NoError(t, err)
+}
+
+func TestValidateSchemaVersion(t *testing.T) {
+	assert.NoError(t, omg.ValidateSchemaVersion("1.1"))
+	assert.NoError(t, omg.ValidateSchemaVersion(omg.LatestSchemaVersion))
+	assert.ErrorContains(t, omg.ValidateSchemaVersion("1.0"), "no longer accepted")
+	assert.ErrorContains(t, omg.ValidateSchemaVersion("2"), "unknown schema version")
+
+	_, err := omg.NewClient(omg.Config{ApiURL: "http://localhost:8080", StoreID: "01HVMMBCMGZNT3SED4Z17ECXCA", SchemaVersion: "9.9"})
+	assert.ErrorContains(t, err, "unknown schema version '9.9'")
+}
+
+func TestWriteAuthorizationModel_SchemaVersionOverride(t *testing.T) {
+	var written openfgaSdk.WriteAuthorizationModelRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		require.NoError(t, json.Unmarshal(body, &written))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"authorization_model_id": "01HVMMBCMGZNT3SED4Z17ECXCC"}`))
+	}))
+	defer server.Close()
+
+	model, err := omg.ParseDSLToModel("type user\n")
+	require.NoError(t, err)
+
+	client, err := omg.NewClient(omg.Config{ApiURL: server.URL, StoreID: "01HVMMBCMGZNT3SED4Z17ECXCA", AuthMethod: "none", SchemaVersion: "1.2"})
+	require.NoError(t, err)
+	require.NoError(t, client.WriteAuthorizationModel(context.Background(), model))
+	assert.Equal(t, "1.2", written.SchemaVersion)
+}
+
+func TestLoadModularModel_NeedsSchema12(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"fga.mod": "schema: '1.1'\ncontents: [a.fga]\n",
+		"a.fga":   "module a\ntype user\n",
+	})
+	_, err := omg.LoadModularModel(dir)
+	assert.ErrorContains(t, err, "modular models need schema 1.2")
+}
@@ -0,0 +1,48 @@
+package omg
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// GenerateSeedMigration writes a migration that seeds tuples, e.g. bootstrap admin grants
+// (omg create -seed). up writes the tuples that are not in the store yet, so re-running it
+// is safe; down deletes the seeded tuples that are still there, including any that were
+// already written before the seed. Only opts.Package and opts.Template apply
+func GenerateSeedMigration(name, migrationsDir string, tuples []Tuple, opts GenerateOptions) (string, error) {
+	if len(tuples) == 0 {
+		return "", fmt.Errorf("no tuples to seed")
+	}
+
+	tuples = DeduplicateTuples(tuples)
+	literal := seedTuplesLiteral(tuples)
+
+	up := fmt.Sprintf(`	// Seed %d tuples; tuples already in the store are skipped
+	tuples := %s
+	if err := omg.WriteTuplesBatchWithOptions(ctx, client, tuples, omg.WriteOptions{SkipExisting: true}); err != nil {
+		return fmt.Errorf("failed to seed tuples: %%w", err)
+	}
+`, len(tuples), literal)
+
+	down := fmt.Sprintf(`	// Delete the seeded tuples that are still in the store
+	tuples := %s
+	if err := omg.DeleteTuplesBatchWithOptions(ctx, client, tuples, omg.DeleteOptions{SkipMissing: true}); err != nil {
+		return fmt.Errorf("failed to delete seeded tuples: %%w", err)
+	}
+`, literal)
+
+	return writeHandwrittenMigration(name, fmt.Sprintf("Seed data: %d tuples", len(tuples)), migrationsDir, up, down, opts)
+}
+
+// seedTuplesLiteral writes tuples as a []omg.Tuple composite literal
+func seedTuplesLiteral(tuples []Tuple) string {
+	var b strings.Builder
+	b.WriteString("[]omg.Tuple{\n")
+	for _, tuple := range tuples {
+		fmt.Fprintf(&b, "\t\t{User: %s, Relation: %s, Object: %s},\n",
+			strconv.Quote(tuple.User), strconv.Quote(tuple.Relation), strconv.Quote(tuple.Object))
+	}
+	b.WriteString("\t}")
+	return b.String()
+}
@@ -0,0 +1,90 @@
+package omg_test
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/demetere/omg/internal/testhelpers"
+	"github.com/demetere/omg/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var seedFixture = []omg.Tuple{
+	{User: "user:anne", Relation: "admin", Object: "org:acme"},
+	{User: "user:bob", Relation: "admin", Object: "org:acme"},
+	{User: "user:anne", Relation: "admin", Object: "org:acme"},
+}
+
+func TestGenerateSeedMigration(t *testing.T) {
+	// The migration imports this module, so it must be built from inside it.
+	// A leading underscore keeps the directory out of ./... patterns
+	dir, err := os.MkdirTemp(".", "_seed")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	filename, err := omg.GenerateSeedMigration("bootstrap admins", dir, seedFixture, omg.GenerateOptions{})
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(filename, "_bootstrap_admins.go"))
+
+	content, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	code := string(content)
+	downStart := strings.Index(code, "func down(")
+	upCode, downCode := code[:downStart], code[downStart:]
+
+	assert.Contains(t, code, "// Seed data: 2 tuples")
+	assert.Equal(t, 1, strings.Count(upCode, `{User: "user:anne", Relation: "admin", Object: "org:acme"}`))
+	assert.Contains(t, upCode, "omg.WriteOptions{SkipExisting: true}")
+	assert.Contains(t, downCode, `{User: "user:bob", Relation: "admin", Object: "org:acme"}`)
+	assert.Contains(t, downCode, "omg.DeleteOptions{SkipMissing: true}")
+
+	_, err = omg.GenerateSeedMigration("empty", dir, nil, omg.GenerateOptions{})
+	assert.ErrorContains(t, err, "no tuples to seed")
+
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go toolchain not available")
+	}
+	out, err := exec.Command(goBin, "vet", "./"+filepath.Base(dir)).CombinedOutput()
+	assert.NoError(t, err, "seed migration does not compile:\n%s", out)
+}
+
+func TestDeleteTuplesBatch_SkipMissing(t *testing.T) {
+	ctx := context.Background()
+
+	container, client := testhelpers.SetupOpenFGAContainer(t, ctx, `
+type user
+type org
+  relations
+    define admin: [user]
+`)
+	defer container.Terminate(ctx)
+
+	require.NoError(t, omg.WriteTuplesBatch(ctx, client, seedFixture[:1]))
+
+	// user:bob was never written; deleting it would fail without SkipMissing
+	require.NoError(t, omg.DeleteTuplesBatchWithOptions(ctx, client, seedFixture[:2], omg.DeleteOptions{SkipMissing: true}))
+
+	tuples, err := omg.ReadAllTuples(ctx, client, "org", "admin")
+	require.NoError(t, err)
+	assert.Empty(t, tuples)
+}
+
+func TestWriteTuplesBatch_SkipExistingReadsOnlyTheInput(t *testing.T) {
+	store, client := newTupleStore(t, []omg.Tuple{
+		{User: "user:anne", Relation: "admin", Object: "org:acme"},
+		{User: "user:carl", Relation: "member", Object: "org:acme"},
+		{User: "user:dana", Relation: "admin", Object: "org:other"},
+	})
+
+	require.NoError(t, omg.WriteTuplesBatchWithOptions(context.Background(), client, seedFixture, omg.WriteOptions{SkipExisting: true}))
+
+	assert.Equal(t, []omg.Tuple{{Relation: "admin", Object: "org:acme"}}, store.reads, "one read of the input's object and relation, not of the type")
+	assert.True(t, store.tuples[omg.Tuple{User: "user:bob", Relation: "admin", Object: "org:acme"}])
+	assert.Len(t, store.tuples, 4)
+}
@@ -0,0 +1,73 @@
+package omg
+
+import "strings"
+
+// SimilarityScorer scores how likely a removed type or relation and an added one are the
+// same thing under a new name. Every score is between 0 (unrelated) and 1 (identical);
+// rename confidence is derived from the name score together with the type or relation score
+type SimilarityScorer interface {
+	// NameSimilarity compares a removed type or relation name with an added one
+	NameSimilarity(oldName, newName string) float64
+
+	// TypeSimilarity compares the relations of a removed type with those of an added type
+	TypeSimilarity(oldType, newType TypeState) float64
+
+	// RelationSimilarity compares the definition of a removed relation with an added one
+	RelationSimilarity(oldName, oldDef, newName, newDef string) float64
+}
+
+// DefaultSimilarity is the built-in scorer: Levenshtein similarity for names, Jaccard
+// similarity of relation names for types and of definition tokens for relations
+type DefaultSimilarity struct{}
+
+// NameSimilarity implements SimilarityScorer
+func (DefaultSimilarity) NameSimilarity(oldName, newName string) float64 {
+	return calculateSimilarity(oldName, newName)
+}
+
+// TypeSimilarity implements SimilarityScorer
+func (DefaultSimilarity) TypeSimilarity(oldType, newType TypeState) float64 {
+	return haveSimilarRelations(oldType, newType)
+}
+
+// RelationSimilarity implements SimilarityScorer
+func (DefaultSimilarity) RelationSimilarity(oldName, oldDef, newName, newDef string) float64 {
+	return relationDefinitionSimilarity(oldName, oldDef, newName, newDef)
+}
+
+// abbreviationScorer expands known abbreviations in names before scoring them
+type abbreviationScorer struct {
+	SimilarityScorer
+	abbreviations map[string]string
+}
+
+// WithAbbreviations wraps a scorer so names are compared with abbreviations expanded
+// Keys are matched against the underscore-separated parts of a name, case-insensitively:
+// with {"grp": "group"}, "user_grp" is scored as "user_group". A nil base uses DefaultSimilarity
+func WithAbbreviations(base SimilarityScorer, abbreviations map[string]string) SimilarityScorer {
+	if base == nil {
+		base = DefaultSimilarity{}
+	}
+
+	normalized := make(map[string]string, len(abbreviations))
+	for short, long := range abbreviations {
+		normalized[strings.ToLower(short)] = strings.ToLower(long)
+	}
+	return abbreviationScorer{SimilarityScorer: base, abbreviations: normalized}
+}
+
+// NameSimilarity implements SimilarityScorer
+func (s abbreviationScorer) NameSimilarity(oldName, newName string) float64 {
+	return s.SimilarityScorer.NameSimilarity(s.expand(oldName), s.expand(newName))
+}
+
+// expand replaces abbreviated parts of a name
+func (s abbreviationScorer) expand(name string) string {
+	parts := strings.Split(strings.ToLower(name), "_")
+	for i, part := range parts {
+		if long, exists := s.abbreviations[part]; exists {
+			parts[i] = long
+		}
+	}
+	return strings.Join(parts, "_")
+}
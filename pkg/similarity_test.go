@@ -0,0 +1,75 @@
+package omg_test
+
+import (
+	"testing"
+
+	"github.com/demetere/omg/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectPotentialRenames_Abbreviations(t *testing.T) {
+	oldState := &omg.ModelState{
+		Types: map[string]omg.TypeState{
+			"org": {Name: "org", Relations: map[string]string{"admin": `{"this":{}}`}},
+		},
+	}
+	newState := &omg.ModelState{
+		Types: map[string]omg.TypeState{
+			"organization": {Name: "organization", Relations: map[string]string{"owner": `{"this":{}}`}},
+		},
+	}
+	changes := omg.DetectChanges(oldState, newState)
+
+	renames := omg.DetectPotentialRenamesWithOptions(changes, oldState, newState, omg.DetectOptions{}).
+		Filter(func(c omg.ModelChange) bool { return c.Type == omg.ChangeTypeRenameType })
+	require.Len(t, renames, 1)
+	assert.Equal(t, omg.ConfidenceLow, renames[0].Confidence)
+
+	opts := omg.DetectOptions{
+		Similarity: omg.WithAbbreviations(nil, map[string]string{"org": "organization", "grp": "group"}),
+	}
+	renames = omg.DetectPotentialRenamesWithOptions(changes, oldState, newState, opts).
+		Filter(func(c omg.ModelChange) bool { return c.Type == omg.ChangeTypeRenameType })
+	require.Len(t, renames, 1)
+	assert.Equal(t, "org", renames[0].OldValue)
+	assert.Equal(t, "organization", renames[0].NewValue)
+	assert.Equal(t, omg.ConfidenceHigh, renames[0].Confidence)
+}
+
+// fixedScorer scores every candidate the same, to check the scorer is used throughout
+type fixedScorer struct{ score float64 }
+
+func (s fixedScorer) NameSimilarity(oldName, newName string) float64        { return s.score }
+func (s fixedScorer) TypeSimilarity(oldType, newType omg.TypeState) float64 { return s.score }
+func (s fixedScorer) RelationSimilarity(oldName, oldDef, newName, newDef string) float64 {
+	return s.score
+}
+
+func TestDetectPotentialRenames_CustomScorer(t *testing.T) {
+	oldState := &omg.ModelState{
+		Types: map[string]omg.TypeState{
+			"document": {Name: "document", Relations: map[string]string{"viewer": `{"this":{}}`}},
+		},
+	}
+	newState := &omg.ModelState{
+		Types: map[string]omg.TypeState{
+			"document": {Name: "document", Relations: map[string]string{"reader": `{"this":{}}`}},
+		},
+	}
+	changes := omg.DetectChanges(oldState, newState)
+
+	enhanced := omg.DetectPotentialRenamesWithOptions(changes, oldState, newState, omg.DetectOptions{Similarity: fixedScorer{0}})
+	assert.Empty(t, enhanced.ByType()[omg.ChangeTypeRenameRelation])
+
+	enhanced = omg.DetectPotentialRenamesWithOptions(changes, oldState, newState, omg.DetectOptions{Similarity: fixedScorer{1}})
+	renames := enhanced.ByType()[omg.ChangeTypeRenameRelation]
+	require.Len(t, renames, 1)
+	assert.Equal(t, omg.ConfidenceHigh, renames[0].Confidence)
+}
+
+func TestWithAbbreviations_ExpandsNameParts(t *testing.T) {
+	scorer := omg.WithAbbreviations(omg.DefaultSimilarity{}, map[string]string{"GRP": "group"})
+	assert.Equal(t, 1.0, scorer.NameSimilarity("user_grp", "user_group"))
+	assert.Less(t, omg.DefaultSimilarity{}.NameSimilarity("user_grp", "user_group"), 1.0)
+}
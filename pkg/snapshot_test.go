@@ -0,0 +1,86 @@
+package omg_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/demetere/omg/internal/testhelpers"
+	"github.com/demetere/omg/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshot_ReadWrite(t *testing.T) {
+	model, err := omg.ParseDSLToModel(`model
+  schema 1.1
+
+type user
+
+type document
+  relations
+    define viewer: [user]
+`)
+	require.NoError(t, err)
+
+	snapshot := &omg.StoreSnapshot{
+		StoreID:   "01HSTORE",
+		CreatedAt: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+		Model:     model,
+		Tuples:    []omg.Tuple{{User: "user:anne", Relation: "viewer", Object: "document:readme"}},
+	}
+
+	path := filepath.Join(t.TempDir(), "store.snapshot")
+	require.NoError(t, omg.WriteSnapshot(path, snapshot))
+
+	read, err := omg.ReadSnapshot(path)
+	require.NoError(t, err)
+	assert.Equal(t, snapshot.StoreID, read.StoreID)
+	assert.True(t, snapshot.CreatedAt.Equal(read.CreatedAt))
+	assert.Equal(t, snapshot.Tuples, read.Tuples)
+	assert.Len(t, read.Model.TypeDefinitions, 2)
+
+	// Not a gzip archive
+	plain := filepath.Join(t.TempDir(), "plain.json")
+	require.NoError(t, os.WriteFile(plain, []byte(`{}`), 0644))
+	_, err = omg.ReadSnapshot(plain)
+	assert.Error(t, err)
+}
+
+func TestSetupFromSnapshot(t *testing.T) {
+	ctx := context.Background()
+
+	model, err := omg.ParseDSLToModel(`model
+  schema 1.1
+
+type user
+
+type team
+  relations
+    define member: [user]
+`)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "store.snapshot")
+	require.NoError(t, omg.WriteSnapshot(path, &omg.StoreSnapshot{
+		Model: model,
+		Tuples: []omg.Tuple{
+			{User: "user:alice", Relation: "member", Object: "team:eng"},
+			{User: "user:bob", Relation: "member", Object: "team:eng"},
+		},
+	}))
+
+	container, client := testhelpers.SetupFromSnapshot(t, ctx, path)
+	defer container.Terminate(ctx)
+
+	count, err := omg.CountTuples(ctx, client, "team", "member")
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	snapshot, err := omg.TakeSnapshot(ctx, client)
+	require.NoError(t, err)
+	assert.Len(t, snapshot.Tuples, 2)
+	assert.Equal(t, client.GetStoreID(), snapshot.StoreID)
+}
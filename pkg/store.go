@@ -0,0 +1,324 @@
+package omg
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	openfgaSdk "github.com/openfga/go-sdk"
+	"github.com/openfga/go-sdk/client"
+)
+
+// maxCountedTuples bounds how many tuples GetStoreInfo counts, since OpenFGA can only
+// count them by reading them all
+const maxCountedTuples = 10000
+
+// cloneProgressInterval is how many copied tuples CloneStore reports progress after
+const cloneProgressInterval = 1000
+
+// StoreInfo describes a store and what it holds
+type StoreInfo struct {
+	ID            string    `json:"id"`
+	Name          string    `json:"name"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	LatestModelID string    `json:"latest_model_id"` // Empty when the store has no model yet
+	ModelCount    int       `json:"model_count"`
+	TupleCount    int       `json:"tuple_count"` // Tuples counted, at most 10000
+	MoreTuples    bool      `json:"more_tuples"` // The store has more tuples than TupleCount
+}
+
+// Store represents an OpenFGA store
+type Store struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// CreateStore creates a new OpenFGA store without authentication
+// Returns the store ID. Use CreateStoreWithConfig for authenticated servers
+func CreateStore(apiURL, storeName string) (string, error) {
+	return CreateStoreWithConfig(context.Background(), Config{ApiURL: apiURL, AuthMethod: "none"}, storeName)
+}
+
+// ListStores lists all stores in the OpenFGA instance without authentication
+// Use ListStoresWithConfig for authenticated servers
+func ListStores(apiURL string) ([]Store, error) {
+	return ListStoresWithConfig(context.Background(), Config{ApiURL: apiURL, AuthMethod: "none"})
+}
+
+// StoreExists checks if a store with the given ID exists, without authentication
+// Use StoreExistsWithConfig for authenticated servers
+func StoreExists(apiURL, storeID string) (bool, error) {
+	return StoreExistsWithConfig(context.Background(), Config{ApiURL: apiURL, AuthMethod: "none"}, storeID)
+}
+
+// CreateStoreWithConfig creates a new OpenFGA store with the credentials, TLS and proxy
+// settings of cfg, whose StoreID and StoreName are ignored
+// Returns the store ID
+func CreateStoreWithConfig(ctx context.Context, cfg Config, storeName string) (string, error) {
+	c, err := newClient(cfg)
+	if err != nil {
+		return "", err
+	}
+
+	var response *client.ClientCreateStoreResponse
+	err = c.call(ctx, func(ctx context.Context) error {
+		var err error
+		response, err = c.sdk.CreateStore(ctx).Body(client.ClientCreateStoreRequest{Name: storeName}).Execute()
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create store: %w", err)
+	}
+	return response.GetId(), nil
+}
+
+// ListStoresWithConfig lists all stores in the OpenFGA instance with the credentials, TLS
+// and proxy settings of cfg, whose StoreID and StoreName are ignored
+func ListStoresWithConfig(ctx context.Context, cfg Config) ([]Store, error) {
+	c, err := newClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return c.listStores(ctx)
+}
+
+// StoreExistsWithConfig checks if a store with the given ID exists, with the credentials,
+// TLS and proxy settings of cfg, whose StoreID and StoreName are ignored
+func StoreExistsWithConfig(ctx context.Context, cfg Config, storeID string) (bool, error) {
+	cfg.StoreID = storeID
+	cfg.StoreName = ""
+	c, err := NewClient(cfg)
+	if err != nil {
+		return false, err
+	}
+	return c.CheckStore(ctx)
+}
+
+// listStores reads every page of stores
+func (c *Client) listStores(ctx context.Context) ([]Store, error) {
+	stores := []Store{}
+	continuationToken := ""
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		options := client.ClientListStoresOptions{}
+		if continuationToken != "" {
+			options.ContinuationToken = openfgaSdk.PtrString(continuationToken)
+		}
+		var response *client.ClientListStoresResponse
+		err := c.retry(ctx, func() error {
+			return c.call(ctx, func(ctx context.Context) error {
+				var err error
+				response, err = c.sdk.ListStores(ctx).Options(options).Execute()
+				return err
+			})
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list stores: %w", err)
+		}
+
+		for _, store := range response.GetStores() {
+			stores = append(stores, Store{ID: store.GetId(), Name: store.GetName()})
+		}
+		continuationToken = response.GetContinuationToken()
+		if continuationToken == "" {
+			return stores, nil
+		}
+	}
+}
+
+// DeleteStore deletes the client's store with all its models and tuples
+func (c *Client) DeleteStore(ctx context.Context) error {
+	err := c.call(ctx, func(ctx context.Context) error {
+		_, err := c.sdk.DeleteStore(ctx).Execute()
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete store %s: %w", c.storeID, err)
+	}
+	return nil
+}
+
+// GetStoreInfo reads the client's store, counts its models and counts its tuples up to
+// 10000, reading them page by page
+func (c *Client) GetStoreInfo(ctx context.Context) (StoreInfo, error) {
+	var store *client.ClientGetStoreResponse
+	err := c.retry(ctx, func() error {
+		return c.call(ctx, func(ctx context.Context) error {
+			var err error
+			store, err = c.sdk.GetStore(ctx).Execute()
+			return err
+		})
+	})
+	if err != nil {
+		return StoreInfo{}, fmt.Errorf("failed to get store: %w", err)
+	}
+	info := StoreInfo{
+		ID:        store.GetId(),
+		Name:      store.GetName(),
+		CreatedAt: store.GetCreatedAt(),
+		UpdatedAt: store.GetUpdatedAt(),
+	}
+
+	// Models are listed newest first
+	continuationToken := ""
+	for {
+		options := client.ClientReadAuthorizationModelsOptions{}
+		if continuationToken != "" {
+			options.ContinuationToken = openfgaSdk.PtrString(continuationToken)
+		}
+		var response *client.ClientReadAuthorizationModelsResponse
+		err := c.retry(ctx, func() error {
+			return c.call(ctx, func(ctx context.Context) error {
+				var err error
+				response, err = c.sdk.ReadAuthorizationModels(ctx).Options(options).Execute()
+				return err
+			})
+		})
+		if err != nil {
+			return StoreInfo{}, fmt.Errorf("failed to list authorization models: %w", err)
+		}
+
+		models := response.GetAuthorizationModels()
+		if info.LatestModelID == "" && len(models) > 0 {
+			info.LatestModelID = models[0].GetId()
+		}
+		info.ModelCount += len(models)
+		continuationToken = response.GetContinuationToken()
+		if continuationToken == "" {
+			break
+		}
+	}
+
+	continuationToken = ""
+	for {
+		if err := ctx.Err(); err != nil {
+			return StoreInfo{}, err
+		}
+		response, err := c.readPage(ctx, client.ClientReadRequest{}, continuationToken)
+		if err != nil {
+			return StoreInfo{}, fmt.Errorf("failed to read tuples: %w", err)
+		}
+		info.TupleCount += len(response.GetTuples())
+		continuationToken = response.GetContinuationToken()
+		if continuationToken == "" {
+			break
+		}
+		if info.TupleCount >= maxCountedTuples {
+			info.MoreTuples = true
+			break
+		}
+	}
+	return info, nil
+}
+
+// CloneResult is what CloneStore copied
+type CloneResult struct {
+	StoreID     string // Of the new store
+	SourceModel string // ID of the source's latest model; empty if it had none
+	Tuples      int
+}
+
+// CloneOptions controls CloneStoreWithOptions
+type CloneOptions struct {
+	// Target is the OpenFGA instance the new store is created in, with its credentials
+	// Nil creates it next to the source; its StoreID and StoreName are ignored
+	Target *Config
+
+	// MaxTuples copies only the first MaxTuples tuples, e.g. a sample for a rehearsal
+	// 0 copies them all
+	MaxTuples int
+
+	// SkipTuples copies the model only
+	SkipTuples bool
+}
+
+// CloneStore creates a store called name with the credentials of cfg, writes the latest
+// model of cfg's store into it and copies every tuple across, page by page, so even large
+// stores are never held in memory. Useful for staging copies and migration rehearsals
+// Tuple conditions are not copied. When copying fails, the new store is left as it is
+func CloneStore(ctx context.Context, cfg Config, name string) (CloneResult, error) {
+	return CloneStoreWithOptions(ctx, cfg, name, CloneOptions{})
+}
+
+// CloneStoreWithOptions is CloneStore with a target instance or a limited number of tuples
+func CloneStoreWithOptions(ctx context.Context, cfg Config, name string, opts CloneOptions) (CloneResult, error) {
+	source, err := NewClient(cfg)
+	if err != nil {
+		return CloneResult{}, err
+	}
+
+	var result CloneResult
+	model, err := source.GetCurrentAuthorizationModel(ctx)
+	if err != nil {
+		return result, err
+	}
+	result.SourceModel = model.GetId()
+
+	targetCfg := cfg
+	if opts.Target != nil {
+		targetCfg = *opts.Target
+	}
+	result.StoreID, err = CreateStoreWithConfig(ctx, targetCfg, name)
+	if err != nil {
+		return result, err
+	}
+	incomplete := func(err error) (CloneResult, error) {
+		return result, fmt.Errorf("store %s was created but is incomplete, delete it before retrying: %w", result.StoreID, err)
+	}
+
+	targetCfg.StoreID = result.StoreID
+	targetCfg.StoreName = ""
+	targetCfg.AuthorizationModelID = ""
+	targetCfg.SchemaVersion = "" // Keep the model as it is
+	target, err := NewClient(targetCfg)
+	if err != nil {
+		return incomplete(err)
+	}
+	if result.SourceModel == "" {
+		return result, nil
+	}
+	if err := target.WriteAuthorizationModel(ctx, model); err != nil {
+		return incomplete(fmt.Errorf("failed to write model: %w", err))
+	}
+	if opts.SkipTuples {
+		return result, nil
+	}
+
+	start := time.Now()
+	continuationToken := ""
+	for {
+		if err := ctx.Err(); err != nil {
+			return incomplete(err)
+		}
+		response, err := source.readPage(ctx, client.ClientReadRequest{}, continuationToken)
+		if err != nil {
+			return incomplete(fmt.Errorf("failed to read tuples: %w", err))
+		}
+
+		tuples := make([]Tuple, 0, len(response.GetTuples()))
+		for _, t := range response.GetTuples() {
+			if opts.MaxTuples > 0 && result.Tuples+len(tuples) == opts.MaxTuples {
+				break
+			}
+			key := t.GetKey()
+			tuples = append(tuples, Tuple{User: key.GetUser(), Relation: key.GetRelation(), Object: key.GetObject()})
+		}
+		if err := target.WriteTuples(ctx, tuples); err != nil {
+			return incomplete(fmt.Errorf("failed to write tuples %d-%d: %w", result.Tuples+1, result.Tuples+len(tuples), err))
+		}
+		if result.Tuples/cloneProgressInterval < (result.Tuples+len(tuples))/cloneProgressInterval {
+			progress := BatchProgress{Operation: "write", Done: result.Tuples + len(tuples), Elapsed: time.Since(start)}
+			fmt.Printf("Copied %d tuples (%.0f tuples/s)\n", progress.Done, progress.Rate())
+		}
+		result.Tuples += len(tuples)
+
+		continuationToken = response.GetContinuationToken()
+		if continuationToken == "" || (opts.MaxTuples > 0 && result.Tuples >= opts.MaxTuples) {
+			return result, nil
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package omg_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/demetere/omg/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testStoreID  = "01HVMMBCMGZNT3SED4Z17ECXCA"
+	newStoreID   = "01HVMMBCMGZNT3SED4Z17ECXCE" // Of the store created by POST /stores
+	testModelDef = `"schema_version":"1.1","type_definitions":[{"type":"user"},{"type":"doc","relations":{"viewer":{"this":{}}},"metadata":{"relations":{"viewer":{"directly_related_user_types":[{"type":"user"}]}}}}]`
+)
+
+// newStoreServer starts an OpenFGA API that requires token "secret" and serves one store
+// with two models (on two pages) and 120 tuples (on three pages). It records every
+// request, and the number of tuples in each write to the store it creates
+func newStoreServer(t *testing.T) (*httptest.Server, *[]string) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"code":"unauthenticated","message":"unauthenticated"}`))
+			return
+		}
+
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/stores":
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id":"` + newStoreID + `","name":"billing"}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/stores":
+			w.Write([]byte(`{"stores":[{"id":"` + testStoreID + `","name":"billing"}],"continuation_token":""}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/stores/"+testStoreID:
+			w.Write([]byte(`{"id":"` + testStoreID + `","name":"billing","created_at":"2024-11-28T15:00:00Z","updated_at":"2024-11-29T09:00:00Z"}`))
+		case r.Method == http.MethodDelete && r.URL.Path == "/stores/"+testStoreID:
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodPost && r.URL.Path == "/stores/"+newStoreID+"/authorization-models":
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"authorization_model_id":"01MODEL3"}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/stores/"+newStoreID+"/write":
+			var body struct {
+				Writes struct {
+					TupleKeys []json.RawMessage `json:"tuple_keys"`
+				} `json:"writes"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			requests = append(requests, fmt.Sprintf("wrote %d tuples", len(body.Writes.TupleKeys)))
+			w.Write([]byte(`{}`))
+		case strings.HasSuffix(r.URL.Path, "/authorization-models"):
+			if r.URL.Query().Get("continuation_token") == "" {
+				w.Write([]byte(`{"authorization_models":[{"id":"01MODEL2",` + testModelDef + `}],"continuation_token":"next"}`))
+				return
+			}
+			w.Write([]byte(`{"authorization_models":[{"id":"01MODEL1",` + testModelDef + `}]}`))
+		case strings.HasSuffix(r.URL.Path, "/read"):
+			var body struct {
+				ContinuationToken string `json:"continuation_token"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			page, count, next := 0, 50, "1"
+			fmt.Sscan(body.ContinuationToken, &page)
+			if page == 2 {
+				count, next = 20, ""
+			} else if page == 1 {
+				next = "2"
+			}
+			tuples := make([]string, count)
+			for i := range tuples {
+				tuples[i] = fmt.Sprintf(`{"key":{"user":"user:%d-%d","relation":"viewer","object":"doc:1"},"timestamp":"2024-11-28T15:00:00Z"}`, page, i)
+			}
+			w.Write([]byte(`{"tuples":[` + strings.Join(tuples, ",") + `],"continuation_token":"` + next + `"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"code":"not_found","message":"not found"}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestStoresWithConfig(t *testing.T) {
+	server, _ := newStoreServer(t)
+	cfg := omg.Config{ApiURL: server.URL, AuthMethod: "token", APIToken: "secret"}
+
+	storeID, err := omg.CreateStoreWithConfig(context.Background(), cfg, "billing")
+	require.NoError(t, err)
+	assert.Equal(t, newStoreID, storeID)
+
+	stores, err := omg.ListStoresWithConfig(context.Background(), cfg)
+	require.NoError(t, err)
+	assert.Equal(t, []omg.Store{{ID: testStoreID, Name: "billing"}}, stores)
+
+	exists, err := omg.StoreExistsWithConfig(context.Background(), cfg, testStoreID)
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	exists, err = omg.StoreExistsWithConfig(context.Background(), cfg, "01HVMMBCMGZNT3SED4Z17ECXCB")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	// Without credentials
+	_, err = omg.ListStores(server.URL)
+	assert.Error(t, err)
+	_, err = omg.StoreExists(server.URL, testStoreID)
+	assert.Error(t, err)
+}
+
+func TestClient_GetStoreInfo(t *testing.T) {
+	server, _ := newStoreServer(t)
+	client, err := omg.NewClient(omg.Config{ApiURL: server.URL, StoreID: testStoreID, AuthMethod: "token", APIToken: "secret"})
+	require.NoError(t, err)
+
+	info, err := client.GetStoreInfo(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, omg.StoreInfo{
+		ID:            testStoreID,
+		Name:          "billing",
+		CreatedAt:     time.Date(2024, 11, 28, 15, 0, 0, 0, time.UTC),
+		UpdatedAt:     time.Date(2024, 11, 29, 9, 0, 0, 0, time.UTC),
+		LatestModelID: "01MODEL2",
+		ModelCount:    2,
+		TupleCount:    120,
+	}, info)
+}
+
+func TestClient_DeleteStore(t *testing.T) {
+	server, requests := newStoreServer(t)
+	client, err := omg.NewClient(omg.Config{ApiURL: server.URL, StoreID: testStoreID, AuthMethod: "token", APIToken: "secret"})
+	require.NoError(t, err)
+
+	require.NoError(t, client.DeleteStore(context.Background()))
+	assert.Contains(t, *requests, "DELETE /stores/"+testStoreID)
+}
+
+func TestCloneStore(t *testing.T) {
+	server, requests := newStoreServer(t)
+	cfg := omg.Config{ApiURL: server.URL, StoreID: testStoreID, AuthMethod: "token", APIToken: "secret"}
+
+	result, err := omg.CloneStore(context.Background(), cfg, "billing-rehearsal")
+	require.NoError(t, err)
+	assert.Equal(t, omg.CloneResult{StoreID: newStoreID, SourceModel: "01MODEL2", Tuples: 120}, result)
+
+	// One write per page read, so tuples stream across
+	assert.Contains(t, *requests, "POST /stores/"+newStoreID+"/authorization-models")
+	var writes []string
+	for _, request := range *requests {
+		if strings.HasPrefix(request, "wrote ") {
+			writes = append(writes, request)
+		}
+	}
+	assert.Equal(t, []string{"wrote 50 tuples", "wrote 50 tuples", "wrote 20 tuples"}, writes)
+
+	// A sample, then the model only
+	*requests = nil
+	result, err = omg.CloneStoreWithOptions(context.Background(), cfg, "sample", omg.CloneOptions{MaxTuples: 70})
+	require.NoError(t, err)
+	assert.Equal(t, 70, result.Tuples)
+	assert.Contains(t, *requests, "wrote 20 tuples")
+
+	*requests = nil
+	result, err = omg.CloneStoreWithOptions(context.Background(), cfg, "empty", omg.CloneOptions{SkipTuples: true})
+	require.NoError(t, err)
+	assert.Zero(t, result.Tuples)
+	assert.Contains(t, *requests, "POST /stores/"+newStoreID+"/authorization-models")
+	assert.NotContains(t, *requests, "POST /stores/"+newStoreID+"/write")
+}
@@ -3,21 +3,35 @@ package omg
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"os"
+	"os/user"
 	"time"
 
 	_ "github.com/lib/pq" // PostgreSQL driver
 )
 
 // Tracker manages migration state using a database table
-// Migrations are tracked in: omg_migrations table
+// Migrations are tracked in: omg_migrations table, keyed by store ID and version
+// so one database can track many stores
 type Tracker struct {
-	db *sql.DB
+	db      *sql.DB
+	storeID string
 }
 
 // NewTracker creates a new migration tracker with database connection
+// Migrations are recorded without a store ID; use NewTrackerForStore to share
+// the database between stores
 func NewTracker(db *sql.DB) (*Tracker, error) {
-	tracker := &Tracker{db: db}
+	return NewTrackerForStore(db, "")
+}
+
+// NewTrackerForStore creates a migration tracker that only sees the given store's migrations
+// Rows recorded before store tracking existed (with no store ID) stay hidden until a
+// store claims them with AdoptUnscopedRows
+func NewTrackerForStore(db *sql.DB, storeID string) (*Tracker, error) {
+	tracker := &Tracker{db: db, storeID: storeID}
 
 	// Ensure the migrations table exists
 	if err := tracker.ensureTable(context.Background()); err != nil {
@@ -27,32 +41,258 @@ func NewTracker(db *sql.DB) (*Tracker, error) {
 	return tracker, nil
 }
 
+// StoreID returns the store whose migrations the tracker records
+func (t *Tracker) StoreID() string {
+	return t.storeID
+}
+
 // MigrationInfo contains metadata about an applied migration
 type MigrationInfo struct {
+	Version      string
+	Name         string
+	AppliedAt    time.Time
+	RolledBackAt time.Time // Zero unless the migration was rolled back
+}
+
+// RemoveOptions controls RemoveWithOptions
+type RemoveOptions struct {
+	// Purge deletes the migration's row instead of marking it rolled back
+	// The run history is kept either way
+	Purge bool
+}
+
+// RunStatus is the outcome of a recorded migration run
+type RunStatus string
+
+const (
+	RunStatusApplied    RunStatus = "applied"
+	RunStatusRolledBack RunStatus = "rolled_back"
+	RunStatusFailed     RunStatus = "failed"
+)
+
+// MigrationRun is one entry in the migration run history
+type MigrationRun struct {
 	Version   string
 	Name      string
-	AppliedAt time.Time
+	Status    RunStatus
+	StartedAt time.Time
+	Duration  time.Duration
+	AppliedBy string
+	Error     string // Set for failed runs
 }
 
-// ensureTable creates the migrations table if it doesn't exist
-func (t *Tracker) ensureTable(ctx context.Context) error {
-	query := `
-		CREATE TABLE IF NOT EXISTS omg_migrations (
+// CurrentOperator identifies who is running migrations, for the run history
+// OMG_APPLIED_BY overrides the default of user@hostname (useful in CI)
+func CurrentOperator() string {
+	if operator := os.Getenv("OMG_APPLIED_BY"); operator != "" {
+		return operator
+	}
+
+	name := "unknown"
+	if current, err := user.Current(); err == nil {
+		name = current.Username
+	}
+	if host, err := os.Hostname(); err == nil {
+		name += "@" + host
+	}
+	return name
+}
+
+// trackerUpgrades evolves the tracker tables; entry i upgrades the schema to version i+1
+// Steps 1 and 2 are idempotent so installations that predate schema versioning (version 0)
+// can replay them safely. Append new steps; never edit released ones
+var trackerUpgrades = [][]string{
+	// 1: initial migrations table
+	{
+		`CREATE TABLE IF NOT EXISTS omg_migrations (
 			version VARCHAR(255) PRIMARY KEY,
 			name VARCHAR(255) NOT NULL,
 			applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
-		)
-	`
+		)`,
+	},
+	// 2: per-store tracking
+	{
+		`ALTER TABLE omg_migrations ADD COLUMN IF NOT EXISTS store_id VARCHAR(255) NOT NULL DEFAULT ''`,
+		`ALTER TABLE omg_migrations DROP CONSTRAINT IF EXISTS omg_migrations_pkey`,
+		`ALTER TABLE omg_migrations ADD PRIMARY KEY (store_id, version)`,
+	},
+	// 3: run history, seeded with the migrations applied so far
+	{
+		`CREATE TABLE IF NOT EXISTS omg_migration_history (
+			id SERIAL PRIMARY KEY,
+			store_id VARCHAR(255) NOT NULL DEFAULT '',
+			version VARCHAR(255) NOT NULL,
+			name VARCHAR(255) NOT NULL,
+			status VARCHAR(32) NOT NULL,
+			started_at TIMESTAMP NOT NULL,
+			duration_ms BIGINT NOT NULL DEFAULT 0,
+			applied_by VARCHAR(255) NOT NULL DEFAULT '',
+			error TEXT NOT NULL DEFAULT ''
+		)`,
+		`INSERT INTO omg_migration_history (store_id, version, name, status, started_at)
+			SELECT store_id, version, name, 'applied', applied_at FROM omg_migrations`,
+	},
+	// 4: rollbacks keep the row, marked rolled back
+	{
+		`ALTER TABLE omg_migrations ADD COLUMN IF NOT EXISTS rolled_back_at TIMESTAMP`,
+	},
+	// 5: expiry records for temporary tuples
+	{
+		`CREATE TABLE IF NOT EXISTS omg_tuple_expiry (
+			store_id VARCHAR(255) NOT NULL DEFAULT '',
+			tuple_user VARCHAR(512) NOT NULL,
+			relation VARCHAR(255) NOT NULL,
+			object VARCHAR(512) NOT NULL,
+			expires_at TIMESTAMP NOT NULL,
+			PRIMARY KEY (store_id, tuple_user, relation, object)
+		)`,
+	},
+	// 6: journal of verify-and-swap renames
+	{
+		`CREATE TABLE IF NOT EXISTS omg_rename_journal (
+			id SERIAL PRIMARY KEY,
+			store_id VARCHAR(255) NOT NULL DEFAULT '',
+			operation VARCHAR(1024) NOT NULL,
+			phase VARCHAR(32) NOT NULL,
+			tuples INTEGER NOT NULL DEFAULT 0,
+			recorded_at TIMESTAMP NOT NULL
+		)`,
+	},
+	// 7: the replacements each unfinished rename wrote, for rollbacks
+	{
+		`CREATE TABLE IF NOT EXISTS omg_rename_tuples (
+			store_id VARCHAR(255) NOT NULL DEFAULT '',
+			operation VARCHAR(1024) NOT NULL,
+			tuple_user VARCHAR(512) NOT NULL,
+			relation VARCHAR(255) NOT NULL,
+			object VARCHAR(512) NOT NULL,
+			PRIMARY KEY (store_id, operation, tuple_user, relation, object)
+		)`,
+	},
+}
+
+// trackerLockID is the advisory lock held while upgrading the tracker schema
+const trackerLockID = 7_014_011_501
+
+// TrackerSchemaVersion returns the tracker schema version this release of omg uses
+func TrackerSchemaVersion() int {
+	return len(trackerUpgrades)
+}
+
+// ensureTable creates the tracker tables and upgrades them to the current schema version
+// Upgrades run in a transaction under an advisory lock, so concurrent runs are safe
+func (t *Tracker) ensureTable(ctx context.Context) error {
+	tx, err := t.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, trackerLockID); err != nil {
+		return fmt.Errorf("failed to lock tracker schema: %w", err)
+	}
+
+	query := `CREATE TABLE IF NOT EXISTS omg_schema_version (version INTEGER NOT NULL)`
+	if _, err := tx.ExecContext(ctx, query); err != nil {
+		return err
+	}
+
+	current := 0
+	err = tx.QueryRowContext(ctx, `SELECT version FROM omg_schema_version LIMIT 1`).Scan(&current)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to read tracker schema version: %w", err)
+	}
+
+	if current > len(trackerUpgrades) {
+		return fmt.Errorf("tracker schema version %d is newer than this omg supports (%d); upgrade omg", current, len(trackerUpgrades))
+	}
+	if current == len(trackerUpgrades) {
+		return nil
+	}
+
+	for version := current + 1; version <= len(trackerUpgrades); version++ {
+		for _, statement := range trackerUpgrades[version-1] {
+			if _, err := tx.ExecContext(ctx, statement); err != nil {
+				return fmt.Errorf("failed to upgrade tracker schema to version %d: %w", version, err)
+			}
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM omg_schema_version`); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO omg_schema_version (version) VALUES ($1)`, len(trackerUpgrades)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UnscopedMigrations returns how many migrations were recorded without a store ID,
+// by versions of omg that predate store tracking
+func (t *Tracker) UnscopedMigrations(ctx context.Context) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM omg_migrations WHERE store_id = ''`
+
+	if err := t.db.QueryRowContext(ctx, query).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count unscoped migrations: %w", err)
+	}
+	return count, nil
+}
+
+// AdoptUnscopedRows assigns the migrations, run history and tuple expiries recorded
+// without a store ID to the tracker's store and returns how many migrations it adopted
+func (t *Tracker) AdoptUnscopedRows(ctx context.Context) (int, error) {
+	if t.storeID == "" {
+		return 0, fmt.Errorf("the tracker has no store ID to assign migrations to")
+	}
+
+	tx, err := t.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	adopted := 0
+	for _, table := range []string{"omg_migrations", "omg_migration_history", "omg_tuple_expiry"} {
+		query := `UPDATE ` + table + ` SET store_id = $1 WHERE store_id = ''`
+
+		result, err := tx.ExecContext(ctx, query, t.storeID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to assign migrations to store %s: %w", t.storeID, err)
+		}
+		if table == "omg_migrations" {
+			rows, err := result.RowsAffected()
+			if err != nil {
+				return 0, fmt.Errorf("failed to assign migrations to store %s: %w", t.storeID, err)
+			}
+			adopted = int(rows)
+		}
+	}
 
-	_, err := t.db.ExecContext(ctx, query)
-	return err
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return adopted, nil
 }
 
 // GetApplied returns all applied migrations
+// Migrations that were rolled back are not included; see GetRolledBack
 func (t *Tracker) GetApplied(ctx context.Context) (map[string]MigrationInfo, error) {
-	query := `SELECT version, name, applied_at FROM omg_migrations ORDER BY version`
+	return t.queryMigrations(ctx, `rolled_back_at IS NULL`)
+}
+
+// GetRolledBack returns migrations that were applied and later rolled back (and not purged)
+func (t *Tracker) GetRolledBack(ctx context.Context) (map[string]MigrationInfo, error) {
+	return t.queryMigrations(ctx, `rolled_back_at IS NOT NULL`)
+}
+
+// queryMigrations returns the store's migration rows matching condition
+func (t *Tracker) queryMigrations(ctx context.Context, condition string) (map[string]MigrationInfo, error) {
+	query := `SELECT version, name, applied_at, rolled_back_at FROM omg_migrations
+		WHERE store_id = $1 AND ` + condition + ` ORDER BY version`
 
-	rows, err := t.db.QueryContext(ctx, query)
+	rows, err := t.db.QueryContext(ctx, query, t.storeID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query migrations: %w", err)
 	}
@@ -61,9 +301,11 @@ func (t *Tracker) GetApplied(ctx context.Context) (map[string]MigrationInfo, err
 	applied := make(map[string]MigrationInfo)
 	for rows.Next() {
 		var info MigrationInfo
-		if err := rows.Scan(&info.Version, &info.Name, &info.AppliedAt); err != nil {
+		var rolledBackAt sql.NullTime
+		if err := rows.Scan(&info.Version, &info.Name, &info.AppliedAt, &rolledBackAt); err != nil {
 			return nil, fmt.Errorf("failed to scan migration row: %w", err)
 		}
+		info.RolledBackAt = rolledBackAt.Time
 		applied[info.Version] = info
 	}
 
@@ -76,26 +318,142 @@ func (t *Tracker) GetApplied(ctx context.Context) (map[string]MigrationInfo, err
 
 // Record marks a migration as applied
 func (t *Tracker) Record(ctx context.Context, version, name string) error {
-	query := `INSERT INTO omg_migrations (version, name, applied_at) VALUES ($1, $2, $3)`
+	return t.RecordRun(ctx, MigrationRun{
+		Version:   version,
+		Name:      name,
+		Status:    RunStatusApplied,
+		StartedAt: time.Now(),
+		AppliedBy: CurrentOperator(),
+	})
+}
 
-	_, err := t.db.ExecContext(ctx, query, version, name, time.Now())
+// Remove marks a migration as rolled back (used for rollback)
+// The row is kept with its rolled_back_at set; use RemoveWithOptions to purge it
+func (t *Tracker) Remove(ctx context.Context, version string) error {
+	return t.RemoveWithOptions(ctx, version, RemoveOptions{})
+}
+
+// RemoveWithOptions marks a migration as rolled back, or deletes its row when purging
+func (t *Tracker) RemoveWithOptions(ctx context.Context, version string, opts RemoveOptions) error {
+	return t.RecordRunWithOptions(ctx, MigrationRun{
+		Version:   version,
+		Status:    RunStatusRolledBack,
+		StartedAt: time.Now(),
+		AppliedBy: CurrentOperator(),
+	}, opts)
+}
+
+// PurgeRolledBack deletes the rows of rolled-back migrations, returning how many were removed
+// The run history is kept
+func (t *Tracker) PurgeRolledBack(ctx context.Context) (int64, error) {
+	query := `DELETE FROM omg_migrations WHERE store_id = $1 AND rolled_back_at IS NOT NULL`
+
+	result, err := t.db.ExecContext(ctx, query, t.storeID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge rolled back migrations: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// RecordRun adds a run to the history and updates the applied migrations to match:
+// an applied run records the migration, a rolled-back run marks it rolled back, a
+// failed run only appears in the history
+func (t *Tracker) RecordRun(ctx context.Context, run MigrationRun) error {
+	return t.RecordRunWithOptions(ctx, run, RemoveOptions{})
+}
+
+// RecordRunWithOptions records a run; opts apply to rolled-back runs
+func (t *Tracker) RecordRunWithOptions(ctx context.Context, run MigrationRun, opts RemoveOptions) error {
+	tx, err := t.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("failed to record migration: %w", err)
 	}
+	defer tx.Rollback()
 
-	return nil
+	switch run.Status {
+	case RunStatusApplied:
+		// Re-applying a rolled-back migration reuses its row
+		query := `INSERT INTO omg_migrations (store_id, version, name, applied_at) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (store_id, version) DO UPDATE
+			SET name = EXCLUDED.name, applied_at = EXCLUDED.applied_at, rolled_back_at = NULL
+			WHERE omg_migrations.rolled_back_at IS NOT NULL`
+		result, err := tx.ExecContext(ctx, query, t.storeID, run.Version, run.Name, run.StartedAt)
+		if err != nil {
+			return fmt.Errorf("failed to record migration: %w", err)
+		}
+		if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+			return fmt.Errorf("failed to record migration: %s is already applied", run.Version)
+		}
+
+	case RunStatusRolledBack:
+		query := `UPDATE omg_migrations SET rolled_back_at = $3
+			WHERE store_id = $1 AND version = $2 AND rolled_back_at IS NULL RETURNING name`
+		args := []any{t.storeID, run.Version, run.StartedAt}
+		if opts.Purge {
+			query = `DELETE FROM omg_migrations
+				WHERE store_id = $1 AND version = $2 AND rolled_back_at IS NULL RETURNING name`
+			args = args[:2]
+		}
+
+		var name string
+		err := tx.QueryRowContext(ctx, query, args...).Scan(&name)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil // Not applied, nothing to roll back
+		}
+		if err != nil {
+			return fmt.Errorf("failed to remove migration: %w", err)
+		}
+		if run.Name == "" {
+			run.Name = name
+		}
+
+	case RunStatusFailed:
+		// History only
+
+	default:
+		return fmt.Errorf("unknown run status '%s'", run.Status)
+	}
+
+	query := `INSERT INTO omg_migration_history (store_id, version, name, status, started_at, duration_ms, applied_by, error)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	_, err = tx.ExecContext(ctx, query, t.storeID, run.Version, run.Name, string(run.Status),
+		run.StartedAt, run.Duration.Milliseconds(), run.AppliedBy, run.Error)
+	if err != nil {
+		return fmt.Errorf("failed to record migration history: %w", err)
+	}
+
+	return tx.Commit()
 }
 
-// Remove removes a migration record (used for rollback)
-func (t *Tracker) Remove(ctx context.Context, version string) error {
-	query := `DELETE FROM omg_migrations WHERE version = $1`
+// History returns every recorded run for the store, oldest first
+func (t *Tracker) History(ctx context.Context) ([]MigrationRun, error) {
+	query := `SELECT version, name, status, started_at, duration_ms, applied_by, error
+		FROM omg_migration_history WHERE store_id = $1 ORDER BY started_at, id`
 
-	_, err := t.db.ExecContext(ctx, query, version)
+	rows, err := t.db.QueryContext(ctx, query, t.storeID)
 	if err != nil {
-		return fmt.Errorf("failed to remove migration: %w", err)
+		return nil, fmt.Errorf("failed to query migration history: %w", err)
 	}
+	defer rows.Close()
 
-	return nil
+	var runs []MigrationRun
+	for rows.Next() {
+		var run MigrationRun
+		var status string
+		var durationMS int64
+		if err := rows.Scan(&run.Version, &run.Name, &status, &run.StartedAt, &durationMS, &run.AppliedBy, &run.Error); err != nil {
+			return nil, fmt.Errorf("failed to scan migration history row: %w", err)
+		}
+		run.Status = RunStatus(status)
+		run.Duration = time.Duration(durationMS) * time.Millisecond
+		runs = append(runs, run)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating migration history: %w", err)
+	}
+
+	return runs, nil
 }
 
 // Close closes the database connection
@@ -0,0 +1,178 @@
+package omg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// TrackerExport is a dump of a tracker's records, written by 'omg tracker export'
+// It rebuilds migration state after the tracking database is lost, or moves it to
+// another tracker backend
+type TrackerExport struct {
+	StoreID    string              `json:"store_id,omitempty"`
+	ExportedAt time.Time           `json:"exported_at"`
+	Applied    []ExportedMigration `json:"applied"`
+
+	// History is every recorded run, for trackers that keep one. It is kept for the
+	// record; importing only restores the applied migrations
+	History []ExportedRun `json:"history,omitempty"`
+}
+
+// ExportedMigration is an applied migration in a TrackerExport
+type ExportedMigration struct {
+	Version   string    `json:"version"`
+	Name      string    `json:"name,omitempty"`
+	AppliedAt time.Time `json:"applied_at"`
+}
+
+// ExportedRun is a recorded run in a TrackerExport
+type ExportedRun struct {
+	Version    string    `json:"version"`
+	Name       string    `json:"name,omitempty"`
+	Status     RunStatus `json:"status"`
+	StartedAt  time.Time `json:"started_at"`
+	DurationMS int64     `json:"duration_ms"`
+	AppliedBy  string    `json:"applied_by,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// TrackerImportResult reports what ImportTracker changed
+type TrackerImportResult struct {
+	Imported []string // Versions recorded as applied
+	Skipped  []string // Versions the tracker already had applied
+	Extra    []string // Versions applied in the tracker but not in the export, left alone
+}
+
+// historyTracker is implemented by trackers that keep a run history
+type historyTracker interface {
+	History(ctx context.Context) ([]MigrationRun, error)
+}
+
+// ExportTracker dumps a tracker's applied migrations, and its run history if it keeps one
+func ExportTracker(ctx context.Context, tracker MigrationTracker, storeID string) (*TrackerExport, error) {
+	applied, err := tracker.GetApplied(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+
+	export := &TrackerExport{
+		StoreID:    storeID,
+		ExportedAt: time.Now().UTC(),
+		Applied:    []ExportedMigration{},
+	}
+	for _, info := range applied {
+		export.Applied = append(export.Applied, ExportedMigration{
+			Version:   info.Version,
+			Name:      info.Name,
+			AppliedAt: info.AppliedAt.UTC(),
+		})
+	}
+	sort.Slice(export.Applied, func(i, j int) bool {
+		return export.Applied[i].Version < export.Applied[j].Version
+	})
+
+	if history, ok := tracker.(historyTracker); ok {
+		runs, err := history.History(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration history: %w", err)
+		}
+		for _, run := range runs {
+			export.History = append(export.History, ExportedRun{
+				Version:    run.Version,
+				Name:       run.Name,
+				Status:     run.Status,
+				StartedAt:  run.StartedAt.UTC(),
+				DurationMS: run.Duration.Milliseconds(),
+				AppliedBy:  run.AppliedBy,
+				Error:      run.Error,
+			})
+		}
+	}
+
+	return export, nil
+}
+
+// ImportTracker records the export's applied migrations that the tracker does not have yet
+// Each is recorded as applied at its original time. Migrations already applied, and
+// migrations applied in the tracker but missing from the export, are left alone
+func ImportTracker(ctx context.Context, tracker MigrationTracker, export *TrackerExport) (TrackerImportResult, error) {
+	var result TrackerImportResult
+
+	applied, err := tracker.GetApplied(ctx)
+	if err != nil {
+		return result, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+
+	exported := make(map[string]bool, len(export.Applied))
+	for _, migration := range export.Applied {
+		exported[migration.Version] = true
+		if _, exists := applied[migration.Version]; exists {
+			result.Skipped = append(result.Skipped, migration.Version)
+			continue
+		}
+
+		run := MigrationRun{
+			Version:   migration.Version,
+			Name:      migration.Name,
+			Status:    RunStatusApplied,
+			StartedAt: migration.AppliedAt,
+			AppliedBy: CurrentOperator() + " (import)",
+		}
+		if run.StartedAt.IsZero() {
+			run.StartedAt = time.Now()
+		}
+		if err := tracker.RecordRunWithOptions(ctx, run, RemoveOptions{}); err != nil {
+			return result, fmt.Errorf("failed to import migration %s: %w", migration.Version, err)
+		}
+		result.Imported = append(result.Imported, migration.Version)
+	}
+
+	for version := range applied {
+		if !exported[version] {
+			result.Extra = append(result.Extra, version)
+		}
+	}
+	sort.Strings(result.Extra)
+
+	return result, nil
+}
+
+// JSON returns the export as indented JSON
+func (e *TrackerExport) JSON() ([]byte, error) {
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode tracker export: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// ParseTrackerExport parses an export written by 'omg tracker export'
+func ParseTrackerExport(data []byte) (*TrackerExport, error) {
+	var export TrackerExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, fmt.Errorf("failed to parse tracker export: %w", err)
+	}
+	for i, migration := range export.Applied {
+		if migration.Version == "" {
+			return nil, fmt.Errorf("applied migration %d has no version", i+1)
+		}
+	}
+	return &export, nil
+}
+
+// ReadTrackerExport reads an export file written by 'omg tracker export'
+func ReadTrackerExport(path string) (*TrackerExport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tracker export: %w", err)
+	}
+	export, err := ParseTrackerExport(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return export, nil
+}
@@ -0,0 +1,66 @@
+package omg_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/demetere/omg/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrackerExport_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	appliedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	source := omg.NewMemoryTracker()
+	for _, run := range []omg.MigrationRun{
+		{Version: "001", Name: "init", Status: omg.RunStatusApplied, StartedAt: appliedAt},
+		{Version: "002", Name: "rename", Status: omg.RunStatusApplied, StartedAt: appliedAt, Duration: 1500 * time.Millisecond},
+		{Version: "003", Name: "broken", Status: omg.RunStatusFailed, StartedAt: appliedAt, Error: "boom"},
+	} {
+		require.NoError(t, source.RecordRunWithOptions(ctx, run, omg.RemoveOptions{}))
+	}
+
+	export, err := omg.ExportTracker(ctx, source, "store-1")
+	require.NoError(t, err)
+	require.Len(t, export.Applied, 2)
+	assert.Equal(t, "001", export.Applied[0].Version)
+	require.Len(t, export.History, 3)
+	assert.Equal(t, int64(1500), export.History[1].DurationMS)
+	assert.Equal(t, "boom", export.History[2].Error)
+
+	data, err := export.JSON()
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"applied_at": "2024-03-01T12:00:00Z"`)
+
+	parsed, err := omg.ParseTrackerExport(data)
+	require.NoError(t, err)
+	assert.Equal(t, export.Applied, parsed.Applied)
+
+	// The target already has 001 and a migration the export does not know about
+	target := omg.NewMemoryTracker()
+	require.NoError(t, target.RecordRunWithOptions(ctx, omg.MigrationRun{Version: "001", Status: omg.RunStatusApplied}, omg.RemoveOptions{}))
+	require.NoError(t, target.RecordRunWithOptions(ctx, omg.MigrationRun{Version: "009", Status: omg.RunStatusApplied}, omg.RemoveOptions{}))
+
+	result, err := omg.ImportTracker(ctx, target, parsed)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"002"}, result.Imported)
+	assert.Equal(t, []string{"001"}, result.Skipped)
+	assert.Equal(t, []string{"009"}, result.Extra)
+
+	applied, err := target.GetApplied(ctx)
+	require.NoError(t, err)
+	require.Contains(t, applied, "002")
+	assert.Equal(t, "rename", applied["002"].Name)
+	assert.True(t, applied["002"].AppliedAt.Equal(appliedAt))
+}
+
+func TestParseTrackerExport_Invalid(t *testing.T) {
+	_, err := omg.ParseTrackerExport([]byte(`{"applied": [{"name": "no version"}]}`))
+	assert.ErrorContains(t, err, "has no version")
+
+	_, err = omg.ParseTrackerExport([]byte(`not json`))
+	assert.Error(t, err)
+}
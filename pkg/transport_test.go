@@ -0,0 +1,130 @@
+package omg_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/demetere/omg/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// emptyReadHandler answers tuple reads with no tuples
+var emptyReadHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"tuples":[],"continuation_token":""}`))
+})
+
+// writePEM writes a PEM block to a file in dir and returns its path
+func writePEM(t *testing.T, dir, name, blockType string, der []byte) string {
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600))
+	return path
+}
+
+// selfSignedClientCert writes a self-signed client certificate and key to dir
+func selfSignedClientCert(t *testing.T, dir string) (certPath, keyPath string, cert *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "omg"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err = x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	return writePEM(t, dir, "client.crt", "CERTIFICATE", der), writePEM(t, dir, "client.key", "EC PRIVATE KEY", keyDER), cert
+}
+
+func readWith(t *testing.T, cfg omg.Config) error {
+	cfg.StoreID = "01HVMMBCMGZNT3SED4Z17ECXCA"
+	cfg.AuthMethod = "none"
+	cfg.MaxRetries = -1
+	client, err := omg.NewClient(cfg)
+	require.NoError(t, err)
+	_, err = client.ReadAllTuples(context.Background(), omg.ReadTuplesRequest{})
+	return err
+}
+
+func TestClient_TLS(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath, clientCert := selfSignedClientCert(t, dir)
+
+	server := httptest.NewUnstartedServer(emptyReadHandler)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	defer server.Close()
+	caPath := writePEM(t, dir, "ca.crt", "CERTIFICATE", server.Certificate().Raw)
+
+	// The test server's certificate is not trusted by the system
+	err := readWith(t, omg.Config{ApiURL: server.URL})
+	assert.ErrorContains(t, err, "certificate")
+
+	// Trusted, but the server requires a client certificate
+	err = readWith(t, omg.Config{ApiURL: server.URL, TLSCAFile: caPath})
+	assert.Error(t, err)
+
+	require.NoError(t, readWith(t, omg.Config{ApiURL: server.URL, TLSCAFile: caPath, TLSClientCert: certPath, TLSClientKey: keyPath}))
+	require.NoError(t, readWith(t, omg.Config{ApiURL: server.URL, InsecureSkipVerify: true, TLSClientCert: certPath, TLSClientKey: keyPath}))
+}
+
+func TestClient_TLSConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	notPEM := filepath.Join(dir, "ca.crt")
+	require.NoError(t, os.WriteFile(notPEM, []byte("not a certificate"), 0600))
+
+	for _, tc := range []struct {
+		cfg  omg.Config
+		want string
+	}{
+		{omg.Config{TLSCAFile: filepath.Join(dir, "missing.crt")}, "failed to read CA file"},
+		{omg.Config{TLSCAFile: notPEM}, "contains no PEM certificates"},
+		{omg.Config{TLSClientCert: notPEM}, "needs both TLSClientCert and TLSClientKey"},
+		{omg.Config{TLSClientCert: notPEM, TLSClientKey: notPEM}, "failed to load client certificate"},
+		{omg.Config{ProxyURL: "::not a url"}, "invalid proxy URL"},
+	} {
+		tc.cfg.ApiURL = "https://fga.example"
+		tc.cfg.StoreID = "01HVMMBCMGZNT3SED4Z17ECXCA"
+		_, err := omg.NewClient(tc.cfg)
+		assert.ErrorContains(t, err, tc.want)
+	}
+}
+
+func TestClient_Proxy(t *testing.T) {
+	var proxied atomic.Int32
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A forward proxy receives the absolute URL of the target
+		if r.URL.Host == "fga.internal" {
+			proxied.Add(1)
+		}
+		emptyReadHandler(w, r)
+	}))
+	defer proxy.Close()
+
+	require.NoError(t, readWith(t, omg.Config{ApiURL: "http://fga.internal", ProxyURL: proxy.URL}))
+	assert.Equal(t, int32(1), proxied.Load())
+}
@@ -0,0 +1,66 @@
+package omg
+
+import (
+	"context"
+	"fmt"
+)
+
+// HeavyTupleCount is the number of tuples from which a removed type or relation counts as
+// heavily used: rename detection leans towards a rename, since removing it loses that data
+const HeavyTupleCount = 1000
+
+// TupleCounts holds the number of stored tuples of removed types and relations, keyed by
+// "type" or "type#relation"
+type TupleCounts map[string]int
+
+// CountRemovedTuples counts the stored tuples of the types and relations changes remove,
+// for DetectOptions.TupleCounts
+func CountRemovedTuples(ctx context.Context, client *Client, changes []ModelChange) (TupleCounts, error) {
+	counts := make(TupleCounts)
+	for _, change := range changes {
+		var relation, key string
+		switch change.Type {
+		case ChangeTypeRemoveType:
+			key = change.TypeName
+		case ChangeTypeRemoveRelation:
+			relation, key = change.RelationName, change.TypeName+"#"+change.RelationName
+		default:
+			continue
+		}
+
+		count, err := CountTuples(ctx, client, change.TypeName, relation)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count tuples of %s: %w", key, err)
+		}
+		counts[key] = count
+	}
+	return counts, nil
+}
+
+// renameConfidence adjusts the confidence of a rename of a removed type or relation by its
+// tuple count. Without tuples there is no data to keep, so it is a removal and an addition
+// whatever the similarity; a heavily used one makes a low confidence match worth reviewing
+func (c TupleCounts) renameConfidence(subject string, confidence ConfidenceLevel) ConfidenceLevel {
+	count, known := c[subject]
+	switch {
+	case !known || confidence == ConfidenceNone:
+		return confidence
+	case count == 0:
+		return ConfidenceNone
+	case count >= HeavyTupleCount && confidence == ConfidenceLow:
+		return ConfidenceMedium
+	}
+	return confidence
+}
+
+// migrationNote describes the tuples a rename of subject migrates, if they were counted
+func (c TupleCounts) migrationNote(subject string) string {
+	count, known := c[subject]
+	if !known {
+		return ""
+	}
+	if count >= HeavyTupleCount {
+		return fmt.Sprintf(" [%d tuples to migrate - plan for the data migration]", count)
+	}
+	return fmt.Sprintf(" [%d tuples to migrate]", count)
+}
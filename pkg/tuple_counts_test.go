@@ -0,0 +1,91 @@
+package omg_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/demetere/omg/internal/testhelpers"
+	"github.com/demetere/omg/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func relationRenameStates() (*omg.ModelState, *omg.ModelState) {
+	oldState := &omg.ModelState{
+		Types: map[string]omg.TypeState{
+			"document": {Name: "document", Relations: map[string]string{"viewer": "[user]"}},
+		},
+	}
+	newState := &omg.ModelState{
+		Types: map[string]omg.TypeState{
+			"document": {Name: "document", Relations: map[string]string{"reader": "[user]"}},
+		},
+	}
+	return oldState, newState
+}
+
+func TestDetectPotentialRenames_NoTuplesIsNotARename(t *testing.T) {
+	oldState, newState := relationRenameStates()
+	changes := omg.DetectChanges(oldState, newState)
+
+	opts := omg.DetectOptions{Similarity: fixedScorer{1}, TupleCounts: omg.TupleCounts{"document#viewer": 0}}
+	enhanced := omg.DetectPotentialRenamesWithOptions(changes, oldState, newState, opts).ByType()
+	assert.Empty(t, enhanced[omg.ChangeTypeRenameRelation])
+	assert.Len(t, enhanced[omg.ChangeTypeRemoveRelation], 1)
+	assert.Len(t, enhanced[omg.ChangeTypeAddRelation], 1)
+}
+
+func TestDetectPotentialRenames_HeavilyUsedLeansTowardsRename(t *testing.T) {
+	oldState, newState := relationRenameStates()
+	changes := omg.DetectChanges(oldState, newState)
+
+	renames := omg.DetectPotentialRenamesWithOptions(changes, oldState, newState, omg.DetectOptions{Similarity: fixedScorer{0.25}}).
+		ByType()[omg.ChangeTypeRenameRelation]
+	require.Len(t, renames, 1)
+	assert.Equal(t, omg.ConfidenceLow, renames[0].Confidence)
+
+	opts := omg.DetectOptions{Similarity: fixedScorer{0.25}, TupleCounts: omg.TupleCounts{"document#viewer": 25000}}
+	renames = omg.DetectPotentialRenamesWithOptions(changes, oldState, newState, opts).ByType()[omg.ChangeTypeRenameRelation]
+	require.Len(t, renames, 1)
+	assert.Equal(t, omg.ConfidenceMedium, renames[0].Confidence)
+	assert.Contains(t, renames[0].Details, "25000 tuples to migrate - plan for the data migration")
+}
+
+func TestDetectPotentialRenames_TupleCountsOfTypes(t *testing.T) {
+	oldState := &omg.ModelState{Types: map[string]omg.TypeState{"team": {Name: "team", Relations: map[string]string{}}}}
+	newState := &omg.ModelState{Types: map[string]omg.TypeState{"teams": {Name: "teams", Relations: map[string]string{}}}}
+	changes := omg.DetectChanges(oldState, newState)
+
+	opts := omg.DetectOptions{TupleCounts: omg.TupleCounts{"team": 12}}
+	renames := omg.DetectPotentialRenamesWithOptions(changes, oldState, newState, opts).ByType()[omg.ChangeTypeRenameType]
+	require.Len(t, renames, 1)
+	assert.Equal(t, omg.ConfidenceHigh, renames[0].Confidence)
+	assert.Contains(t, renames[0].Details, "[12 tuples to migrate]")
+}
+
+func TestCountRemovedTuples(t *testing.T) {
+	ctx := context.Background()
+
+	container, client := testhelpers.SetupOpenFGAContainer(t, ctx, `
+type user
+type team
+  relations
+    define member: [user]
+    define admin: [user]
+`)
+	defer container.Terminate(ctx)
+
+	require.NoError(t, omg.WriteTuplesBatch(ctx, client, []omg.Tuple{
+		{User: "user:alice", Relation: "member", Object: "team:eng"},
+		{User: "user:bob", Relation: "member", Object: "team:eng"},
+	}))
+
+	counts, err := omg.CountRemovedTuples(ctx, client, []omg.ModelChange{
+		{Type: omg.ChangeTypeRemoveRelation, TypeName: "team", RelationName: "member"},
+		{Type: omg.ChangeTypeRemoveRelation, TypeName: "team", RelationName: "admin"},
+		{Type: omg.ChangeTypeRemoveType, TypeName: "team"},
+		{Type: omg.ChangeTypeAddRelation, TypeName: "team", RelationName: "owner"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, omg.TupleCounts{"team#member": 2, "team#admin": 0, "team": 2}, counts)
+}
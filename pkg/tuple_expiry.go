@@ -0,0 +1,128 @@
+package omg
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// TupleExpiry records when a temporary tuple should be removed
+// Expiry records live next to the migration tracker (omg_tuple_expiry), so temporary
+// access granted by a migration can be cleaned up later with ExpireTuples or 'omg expire'
+type TupleExpiry struct {
+	Tuple     Tuple
+	ExpiresAt time.Time
+}
+
+// RecordExpiry records that tuples expire at expiresAt
+// Recording a tuple again replaces its expiry
+func (t *Tracker) RecordExpiry(ctx context.Context, tuples []Tuple, expiresAt time.Time) error {
+	tx, err := t.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to record expiry: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO omg_tuple_expiry (store_id, tuple_user, relation, object, expires_at) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (store_id, tuple_user, relation, object) DO UPDATE SET expires_at = EXCLUDED.expires_at`
+	for _, tuple := range tuples {
+		if _, err := tx.ExecContext(ctx, query, t.storeID, tuple.User, tuple.Relation, tuple.Object, expiresAt.UTC()); err != nil {
+			return fmt.Errorf("failed to record expiry: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ExpiredTuples returns the tuples whose expiry is at or before now, soonest first
+func (t *Tracker) ExpiredTuples(ctx context.Context, now time.Time) ([]TupleExpiry, error) {
+	query := `SELECT tuple_user, relation, object, expires_at FROM omg_tuple_expiry
+		WHERE store_id = $1 AND expires_at <= $2 ORDER BY expires_at, object, relation, tuple_user`
+
+	rows, err := t.db.QueryContext(ctx, query, t.storeID, now.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tuple expiry: %w", err)
+	}
+	defer rows.Close()
+
+	var expired []TupleExpiry
+	for rows.Next() {
+		var expiry TupleExpiry
+		if err := rows.Scan(&expiry.Tuple.User, &expiry.Tuple.Relation, &expiry.Tuple.Object, &expiry.ExpiresAt); err != nil {
+			return nil, fmt.Errorf("failed to scan tuple expiry row: %w", err)
+		}
+		expired = append(expired, expiry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating tuple expiry: %w", err)
+	}
+
+	return expired, nil
+}
+
+// ForgetExpiry deletes the expiry records of tuples
+func (t *Tracker) ForgetExpiry(ctx context.Context, tuples []Tuple) error {
+	tx, err := t.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to forget expiry: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `DELETE FROM omg_tuple_expiry WHERE store_id = $1 AND tuple_user = $2 AND relation = $3 AND object = $4`
+	for _, tuple := range tuples {
+		if _, err := tx.ExecContext(ctx, query, t.storeID, tuple.User, tuple.Relation, tuple.Object); err != nil {
+			return fmt.Errorf("failed to forget expiry: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// WriteTemporaryTuples writes tuples that expire after ttl
+// The expiry is recorded before the write, so a failed write never leaves a
+// tuple without an expiry
+// Example: WriteTemporaryTuples(ctx, client, tracker, []Tuple{{User: "user:oncall", Relation: "admin", Object: "org:acme"}}, 24*time.Hour)
+func WriteTemporaryTuples(ctx context.Context, client *Client, tracker *Tracker, tuples []Tuple, ttl time.Duration) error {
+	if err := tracker.RecordExpiry(ctx, tuples, time.Now().Add(ttl)); err != nil {
+		return err
+	}
+	return WriteTuplesBatchWithOptions(ctx, client, tuples, WriteOptions{SkipExisting: true})
+}
+
+// ExpireTuples deletes tuples whose expiry has passed and forgets their expiry records
+// Tuples that are already gone from the store are only forgotten. Returns the deleted tuples
+func ExpireTuples(ctx context.Context, client *Client, tracker *Tracker, now time.Time) ([]Tuple, error) {
+	expired, err := tracker.ExpiredTuples(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+	if len(expired) == 0 {
+		return nil, nil
+	}
+
+	tuples := make([]Tuple, len(expired))
+	for i, expiry := range expired {
+		tuples[i] = expiry.Tuple
+	}
+
+	stored, err := readStoredTuples(ctx, client, tuples)
+	if err != nil {
+		return nil, err
+	}
+	var present []Tuple
+	for _, tuple := range tuples {
+		if stored[tuple] {
+			present = append(present, tuple)
+		}
+	}
+
+	if err := DeleteTuplesBatch(ctx, client, present); err != nil {
+		return nil, fmt.Errorf("failed to delete expired tuples: %w", err)
+	}
+	if err := tracker.ForgetExpiry(ctx, tuples); err != nil {
+		return nil, err
+	}
+
+	return present, nil
+}
@@ -0,0 +1,151 @@
+package omg
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TupleDiff is what it takes to make a store match an imported tuple file
+type TupleDiff struct {
+	Missing    []Tuple // In the file but not in the store
+	Extraneous []Tuple // In the store, within the file's scope, but not in the file
+	Unchanged  int
+}
+
+// IsEmpty reports whether the store already matches the file
+func (d TupleDiff) IsEmpty() bool {
+	return len(d.Missing) == 0 && len(d.Extraneous) == 0
+}
+
+// ReadTupleFile reads tuples from a JSON file: an array of {"user", "relation", "object"}
+// objects, the same format 'fga tuple write --file' accepts. Files ending in .yaml or .yml
+// hold the same list in YAML, and .csv files have user,relation,object rows with an
+// optional header
+func ReadTupleFile(path string) ([]Tuple, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tuple file: %w", err)
+	}
+
+	var tuples []Tuple
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &tuples)
+	case ".csv":
+		tuples, err = parseTupleCSV(data)
+	default:
+		err = json.Unmarshal(data, &tuples)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse tuple file %s: %w", path, err)
+	}
+
+	for i, tuple := range tuples {
+		if tuple.User == "" || tuple.Relation == "" || tuple.Object == "" {
+			return nil, fmt.Errorf("tuple %d in %s is missing user, relation or object", i+1, path)
+		}
+	}
+	return tuples, nil
+}
+
+// parseTupleCSV reads user,relation,object rows, skipping a header row
+func parseTupleCSV(data []byte) ([]Tuple, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = 3
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) > 0 && strings.EqualFold(records[0][0], "user") &&
+		strings.EqualFold(records[0][1], "relation") && strings.EqualFold(records[0][2], "object") {
+		records = records[1:]
+	}
+
+	tuples := make([]Tuple, 0, len(records))
+	for _, record := range records {
+		tuples = append(tuples, Tuple{User: record[0], Relation: record[1], Object: record[2]})
+	}
+	return tuples, nil
+}
+
+// DiffTuplesInScope compares tuples with the store
+// The scope is every type#relation pair in tuples: store tuples with those object
+// types and relations that are not in tuples are extraneous, anything else is left alone
+func DiffTuplesInScope(ctx context.Context, client *Client, tuples []Tuple) (TupleDiff, error) {
+	scopes := make(map[[2]string]bool)
+	for _, tuple := range tuples {
+		objectType, _, _ := strings.Cut(tuple.Object, ":")
+		scopes[[2]string{objectType, tuple.Relation}] = true
+	}
+
+	keys := make([][2]string, 0, len(scopes))
+	for key := range scopes {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+
+	var current []Tuple
+	for _, key := range keys {
+		scoped, err := ReadAllTuples(ctx, client, key[0], key[1])
+		if err != nil {
+			return TupleDiff{}, fmt.Errorf("failed to read %s#%s tuples: %w", key[0], key[1], err)
+		}
+		current = append(current, scoped...)
+	}
+
+	return diffTupleSets(tuples, current), nil
+}
+
+// diffTupleSets compares the desired tuples with the current ones
+func diffTupleSets(desired, current []Tuple) TupleDiff {
+	wanted := make(map[Tuple]bool, len(desired))
+	for _, tuple := range desired {
+		wanted[tuple] = true
+	}
+	present := make(map[Tuple]bool, len(current))
+	for _, tuple := range current {
+		present[tuple] = true
+	}
+
+	var diff TupleDiff
+	for _, tuple := range DeduplicateTuples(desired) {
+		if present[tuple] {
+			diff.Unchanged++
+		} else {
+			diff.Missing = append(diff.Missing, tuple)
+		}
+	}
+	for _, tuple := range current {
+		if !wanted[tuple] {
+			diff.Extraneous = append(diff.Extraneous, tuple)
+		}
+	}
+	return diff
+}
+
+// ApplyTupleDiff writes the missing tuples, then deletes the extraneous ones
+func ApplyTupleDiff(ctx context.Context, client *Client, diff TupleDiff) error {
+	if err := WriteTuplesBatch(ctx, client, diff.Missing); err != nil {
+		return fmt.Errorf("failed to write missing tuples: %w", err)
+	}
+	if err := DeleteTuplesBatch(ctx, client, diff.Extraneous); err != nil {
+		return fmt.Errorf("failed to delete extraneous tuples: %w", err)
+	}
+	return nil
+}
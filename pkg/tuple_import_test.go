@@ -0,0 +1,104 @@
+package omg_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/demetere/omg/internal/testhelpers"
+	"github.com/demetere/omg/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadTupleFile(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "tuples.json")
+	require.NoError(t, os.WriteFile(path, []byte(`[{"user": "user:anne", "relation": "viewer", "object": "document:readme"}]`), 0644))
+
+	tuples, err := omg.ReadTupleFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, []omg.Tuple{{User: "user:anne", Relation: "viewer", Object: "document:readme"}}, tuples)
+
+	invalid := filepath.Join(dir, "invalid.json")
+	require.NoError(t, os.WriteFile(invalid, []byte(`[{"user": "user:anne", "object": "document:readme"}]`), 0644))
+
+	_, err = omg.ReadTupleFile(invalid)
+	assert.ErrorContains(t, err, "missing user, relation or object")
+}
+
+func TestReadTupleFile_YAMLAndCSV(t *testing.T) {
+	dir := t.TempDir()
+	expected := []omg.Tuple{
+		{User: "user:anne", Relation: "admin", Object: "org:acme"},
+		{User: "org:acme#member", Relation: "viewer", Object: "document:readme"},
+	}
+
+	yamlPath := filepath.Join(dir, "tuples.yaml")
+	require.NoError(t, os.WriteFile(yamlPath, []byte(`- user: user:anne
+  relation: admin
+  object: org:acme
+- {user: "org:acme#member", relation: viewer, object: "document:readme"}
+`), 0644))
+	tuples, err := omg.ReadTupleFile(yamlPath)
+	require.NoError(t, err)
+	assert.Equal(t, expected, tuples)
+
+	csvPath := filepath.Join(dir, "tuples.csv")
+	require.NoError(t, os.WriteFile(csvPath, []byte("user,relation,object\nuser:anne,admin,org:acme\norg:acme#member, viewer, document:readme\n"), 0644))
+	tuples, err = omg.ReadTupleFile(csvPath)
+	require.NoError(t, err)
+	assert.Equal(t, expected, tuples)
+
+	// Without a header every row is a tuple
+	require.NoError(t, os.WriteFile(csvPath, []byte("user:anne,admin,org:acme\n"), 0644))
+	tuples, err = omg.ReadTupleFile(csvPath)
+	require.NoError(t, err)
+	assert.Equal(t, expected[:1], tuples)
+
+	require.NoError(t, os.WriteFile(csvPath, []byte("user:anne,admin\n"), 0644))
+	_, err = omg.ReadTupleFile(csvPath)
+	assert.ErrorContains(t, err, "failed to parse tuple file")
+}
+
+func TestDiffTuplesInScope(t *testing.T) {
+	ctx := context.Background()
+
+	container, client := testhelpers.SetupOpenFGAContainer(t, ctx, `
+type user
+type document
+  relations
+    define viewer: [user]
+    define editor: [user]
+`)
+	defer container.Terminate(ctx)
+
+	require.NoError(t, omg.WriteTuplesBatch(ctx, client, []omg.Tuple{
+		{User: "user:anne", Relation: "viewer", Object: "document:readme"},
+		{User: "user:bob", Relation: "viewer", Object: "document:readme"},
+		{User: "user:bob", Relation: "editor", Object: "document:readme"},
+	}))
+
+	file := []omg.Tuple{
+		{User: "user:anne", Relation: "viewer", Object: "document:readme"},
+		{User: "user:carol", Relation: "viewer", Object: "document:readme"},
+	}
+
+	diff, err := omg.DiffTuplesInScope(ctx, client, file)
+	require.NoError(t, err)
+	assert.Equal(t, []omg.Tuple{{User: "user:carol", Relation: "viewer", Object: "document:readme"}}, diff.Missing)
+	assert.Equal(t, []omg.Tuple{{User: "user:bob", Relation: "viewer", Object: "document:readme"}}, diff.Extraneous, "editor is outside the file's scope")
+	assert.Equal(t, 1, diff.Unchanged)
+
+	require.NoError(t, omg.ApplyTupleDiff(ctx, client, diff))
+
+	diff, err = omg.DiffTuplesInScope(ctx, client, file)
+	require.NoError(t, err)
+	assert.True(t, diff.IsEmpty())
+
+	count, err := omg.CountTuples(ctx, client, "document", "editor")
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
@@ -0,0 +1,216 @@
+package omg
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// CheckExpectation describes an authorization result expected after a migration
+type CheckExpectation struct {
+	Tuple
+	Allowed bool
+}
+
+// String formats the expectation in the same form accepted by ParseCheckExpectation
+func (e CheckExpectation) String() string {
+	result := "allow"
+	if !e.Allowed {
+		result = "deny"
+	}
+	return fmt.Sprintf("%s %s %s %s", e.User, e.Relation, e.Object, result)
+}
+
+// CheckResult is the outcome of verifying a single expectation
+type CheckResult struct {
+	CheckExpectation
+	Actual bool
+}
+
+// Passed reports whether the actual result matches the expectation
+func (r CheckResult) Passed() bool {
+	return r.Actual == r.Allowed
+}
+
+// ParseCheckExpectation parses "<user> <relation> <object> [allow|deny]"
+// The result defaults to allow when omitted
+// Example: "user:alice viewer document:readme deny"
+func ParseCheckExpectation(s string) (CheckExpectation, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 3 && len(fields) != 4 {
+		return CheckExpectation{}, fmt.Errorf("invalid check %q: expected '<user> <relation> <object> [allow|deny]'", s)
+	}
+
+	expectation := CheckExpectation{
+		Tuple:   Tuple{User: fields[0], Relation: fields[1], Object: fields[2]},
+		Allowed: true,
+	}
+
+	if len(fields) == 4 {
+		switch fields[3] {
+		case "allow", "allowed":
+			expectation.Allowed = true
+		case "deny", "denied":
+			expectation.Allowed = false
+		default:
+			return CheckExpectation{}, fmt.Errorf("invalid check result %q: expected allow or deny", fields[3])
+		}
+	}
+
+	return expectation, nil
+}
+
+// VerifyChecks runs all expectations through BatchCheck
+// It returns every result, plus an error listing the expectations that failed
+func VerifyChecks(ctx context.Context, client *Client, checks []CheckExpectation) ([]CheckResult, error) {
+	if len(checks) == 0 {
+		return nil, nil
+	}
+
+	tuples := make([]Tuple, len(checks))
+	for i, check := range checks {
+		tuples[i] = check.Tuple
+	}
+
+	allowed, err := client.BatchCheck(ctx, tuples)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]CheckResult, len(checks))
+	var failed []string
+	for i, check := range checks {
+		results[i] = CheckResult{CheckExpectation: check, Actual: allowed[i]}
+		if !results[i].Passed() {
+			failed = append(failed, check.String())
+		}
+	}
+
+	if len(failed) > 0 {
+		return results, fmt.Errorf("%d of %d verification checks failed: %s", len(failed), len(checks), strings.Join(failed, "; "))
+	}
+	return results, nil
+}
+
+// TupleMismatch is a tuple whose stored state differs from what a batch operation expected
+type TupleMismatch struct {
+	Tuple
+	ExpectedPresent bool
+}
+
+// TupleVerificationError reports the tuples that failed read-your-writes verification
+type TupleVerificationError struct {
+	Checked    int
+	Mismatches []TupleMismatch
+}
+
+// Error lists every mismatched tuple
+func (e *TupleVerificationError) Error() string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("%d of %d verified tuples do not match the expected state:", len(e.Mismatches), e.Checked))
+	for _, mismatch := range e.Mismatches {
+		state := "missing"
+		if !mismatch.ExpectedPresent {
+			state = "still present"
+		}
+		b.WriteString(fmt.Sprintf("\n  %s %s %s: %s", mismatch.User, mismatch.Relation, mismatch.Object, state))
+	}
+	return b.String()
+}
+
+// VerifyTuples re-reads tuples and confirms each is present (or absent)
+// sample limits the check to that many randomly chosen tuples; 0 checks all of them.
+// Returns a *TupleVerificationError describing every mismatch
+func VerifyTuples(ctx context.Context, client *Client, tuples []Tuple, expectPresent bool, sample int) error {
+	checked := tuples
+	if sample > 0 && sample < len(tuples) {
+		checked = make([]Tuple, sample)
+		for i, j := range rand.Perm(len(tuples))[:sample] {
+			checked[i] = tuples[j]
+		}
+	}
+	if len(checked) == 0 {
+		return nil
+	}
+
+	present, err := readTuplesPresence(ctx, client, checked, sample > 0)
+	if err != nil {
+		return fmt.Errorf("failed to verify tuples: %w", err)
+	}
+
+	var mismatches []TupleMismatch
+	for _, tuple := range checked {
+		if present[tuple] != expectPresent {
+			mismatches = append(mismatches, TupleMismatch{Tuple: tuple, ExpectedPresent: expectPresent})
+		}
+	}
+
+	if len(mismatches) > 0 {
+		return &TupleVerificationError{Checked: len(checked), Mismatches: mismatches}
+	}
+
+	fmt.Printf("Verified %d tuples\n", len(checked))
+	return nil
+}
+
+// readTuplesPresence reports which of the tuples are stored
+// Small samples are read one tuple at a time; otherwise by object and relation
+func readTuplesPresence(ctx context.Context, client *Client, tuples []Tuple, perTuple bool) (map[Tuple]bool, error) {
+	if !perTuple {
+		return readStoredTuples(ctx, client, tuples)
+	}
+
+	present := make(map[Tuple]bool, len(tuples))
+	for _, tuple := range tuples {
+		found, err := client.ReadAllTuples(ctx, ReadTuplesRequest{
+			User:     tuple.User,
+			Relation: tuple.Relation,
+			Object:   tuple.Object,
+		})
+		if err != nil {
+			return nil, err
+		}
+		present[tuple] = len(found) > 0
+	}
+	return present, nil
+}
+
+// WaitForTupleCount polls CountTuples until it returns expected, backing off between reads
+// Reads served by replicas can lag behind large writes; use this before asserting on counts
+// Returns an error with the last observed count when timeout elapses
+// Example: WaitForTupleCount(ctx, client, "document", "viewer", 1200, 30*time.Second)
+func WaitForTupleCount(ctx context.Context, client *Client, objectType, relation string, expected int, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	delay := 100 * time.Millisecond
+	for {
+		count, err := CountTuples(ctx, client, objectType, relation)
+		if err == nil && count == expected {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			if err != nil {
+				return fmt.Errorf("timed out after %s waiting for %d tuples of %s: %w", timeout, expected, tupleFilterName(objectType, relation), err)
+			}
+			return fmt.Errorf("timed out after %s waiting for %d tuples of %s: found %d", timeout, expected, tupleFilterName(objectType, relation), count)
+		case <-time.After(delay):
+		}
+
+		if delay *= 2; delay > 5*time.Second {
+			delay = 5 * time.Second
+		}
+	}
+}
+
+// tupleFilterName formats an object type and optional relation, e.g. "document#viewer"
+func tupleFilterName(objectType, relation string) string {
+	if relation == "" {
+		return objectType
+	}
+	return objectType + "#" + relation
+}
@@ -0,0 +1,45 @@
+package omg
+
+import (
+	"runtime/debug"
+)
+
+// modulePath is the import path of the omg module
+const modulePath = "github.com/demetere/omg"
+
+// Version is the omg version sent in the default User-Agent. Release builds may set it with
+// -ldflags "-X github.com/demetere/omg/pkg.Version=v1.2.0"; when empty, it comes from the
+// build info of the binary, e.g. for 'go install github.com/demetere/omg/cmd/omg@v1.2.0'
+var Version = ""
+
+// CurrentVersion returns the omg version, or "dev" for local builds
+func CurrentVersion() string {
+	if Version != "" {
+		return Version
+	}
+
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "dev"
+	}
+	version := info.Main.Version
+	if info.Main.Path != modulePath {
+		// omg is a dependency of the binary, e.g. an application running omg.Migrator
+		version = ""
+		for _, dep := range info.Deps {
+			if dep.Path == modulePath {
+				version = dep.Version
+				break
+			}
+		}
+	}
+	if version == "" || version == "(devel)" {
+		return "dev"
+	}
+	return version
+}
+
+// DefaultUserAgent is the User-Agent of API requests unless Config.UserAgent says otherwise
+func DefaultUserAgent() string {
+	return "omg/" + CurrentVersion()
+}
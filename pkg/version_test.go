@@ -0,0 +1,55 @@
+package omg_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/demetere/omg/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCurrentVersion(t *testing.T) {
+	// Test binaries have no module version
+	assert.Equal(t, "dev", omg.CurrentVersion())
+	assert.Equal(t, "omg/dev", omg.DefaultUserAgent())
+
+	omg.Version = "v1.2.0"
+	defer func() { omg.Version = "" }()
+	assert.Equal(t, "omg/v1.2.0", omg.DefaultUserAgent())
+}
+
+func TestClient_Headers(t *testing.T) {
+	var headers atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers.Store(r.Header.Clone())
+		emptyReadHandler(w, r)
+	}))
+	defer server.Close()
+
+	read := func(cfg omg.Config) http.Header {
+		cfg.ApiURL = server.URL
+		cfg.StoreID = "01HVMMBCMGZNT3SED4Z17ECXCA"
+		client, err := omg.NewClient(cfg)
+		require.NoError(t, err)
+		_, err = client.ReadAllTuples(context.Background(), omg.ReadTuplesRequest{})
+		require.NoError(t, err)
+		return headers.Load().(http.Header)
+	}
+
+	got := read(omg.Config{AuthMethod: "none"})
+	assert.Equal(t, omg.DefaultUserAgent(), got.Get("User-Agent"))
+
+	got = read(omg.Config{
+		AuthMethod: "token",
+		APIToken:   "secret",
+		UserAgent:  "billing-migrations/3",
+		Headers:    map[string]string{"X-Tenant": "acme", "Authorization": "ignored"},
+	})
+	assert.Equal(t, "billing-migrations/3", got.Get("User-Agent"))
+	assert.Equal(t, "acme", got.Get("X-Tenant"))
+	assert.Equal(t, "Bearer secret", got.Get("Authorization"))
+}